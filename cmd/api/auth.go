@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mcclellann/fredLoan/pkg/api"
+	"github.com/mcclellann/fredLoan/pkg/oidc"
+)
+
+// tokenVerifierFromEnv configures bearer token authentication from OIDC_ISSUER, OIDC_AUDIENCE
+// and OIDC_ROLES_CLAIM. It returns nil, disabling authentication, when no issuer is set.
+func tokenVerifierFromEnv() api.TokenVerifier {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return nil
+	}
+	verifier := oidc.NewVerifier(issuer, os.Getenv("OIDC_AUDIENCE"))
+	if claim := os.Getenv("OIDC_ROLES_CLAIM"); claim != "" {
+		verifier.RolesClaim = claim
+	}
+	return verifier
+}
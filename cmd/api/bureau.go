@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mcclellann/fredLoan/pkg/metro2"
+)
+
+// defaultBureauExportDir is where the monthly bureau files are written when BUREAU_EXPORT_DIR is unset.
+const defaultBureauExportDir = "exports"
+
+// bureauFormatFromEnv loads the record layout named by BUREAU_FORMAT_FILE, falling back to
+// the default Metro 2 style layout.
+func bureauFormatFromEnv() (metro2.Format, error) {
+	path := os.Getenv("BUREAU_FORMAT_FILE")
+	if path == "" {
+		return metro2.DefaultFormat, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return metro2.Format{}, err
+	}
+	defer f.Close()
+	return metro2.LoadFormat(f)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+// faultConfigFromEnv builds a fault-injection configuration for staging from the
+// FAULT_ERROR_RATE, FAULT_PARTIAL_FAILURE_RATE, FAULT_LATENCY, FAULT_LATENCY_JITTER and
+// FAULT_METHODS environment variables. It reports false if none are set.
+func faultConfigFromEnv() (store.FaultConfig, bool, error) {
+	var cfg store.FaultConfig
+	enabled := false
+
+	rates := map[string]*float64{
+		"FAULT_ERROR_RATE":           &cfg.ErrorRate,
+		"FAULT_PARTIAL_FAILURE_RATE": &cfg.PartialFailureRate,
+	}
+	for name, dst := range rates {
+		if v := os.Getenv(name); v != "" {
+			rate, err := strconv.ParseFloat(v, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return cfg, false, fmt.Errorf("%s must be between 0 and 1", name)
+			}
+			*dst = rate
+			enabled = true
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"FAULT_LATENCY":        &cfg.Latency,
+		"FAULT_LATENCY_JITTER": &cfg.LatencyJitter,
+	}
+	for name, dst := range durations {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return cfg, false, fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = d
+			enabled = true
+		}
+	}
+
+	if v := os.Getenv("FAULT_METHODS"); v != "" {
+		cfg.Methods = strings.Split(v, ",")
+	}
+
+	return cfg, enabled, nil
+}
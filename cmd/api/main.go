@@ -1,218 +1,342 @@
+// Command api runs the loan API server, configured from a TOML file and the environment, with
+// the daily batch and webhook delivery on their schedules.
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"crypto/rand"
+	"flag"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/google/uuid"
-	"github.com/gorilla/mux"
-	"github.com/mcclellann/fredLoan/pkg/ledger"
-	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/api"
+	"github.com/mcclellann/fredLoan/pkg/config"
+	"github.com/mcclellann/fredLoan/pkg/fred"
+	"github.com/mcclellann/fredLoan/pkg/grpcapi"
+	"github.com/mcclellann/fredLoan/pkg/s3"
 	"github.com/mcclellann/fredLoan/pkg/store"
-	"github.com/shopspring/decimal"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-// Server holds the ledger instance.
-type Server struct {
-	ledger *ledger.Ledger
-	storage store.Storage // Keep a reference to the storage to close it
-}
+// autoChargeOffDaysPastDue is the delinquency at which the daily batch charges off a loan.
+const autoChargeOffDaysPastDue = 120
 
-func NewServer(s store.Storage) *Server {
-	return &Server{
-		ledger: ledger.NewLedger(s),
-		storage: s,
-	}
-}
+// defaultIndexSeries are the FRED series refreshed by the daily batch when FRED_SERIES is unset.
+var defaultIndexSeries = []string{"SOFR", "DPRIME"}
 
-func (s *Server) createLoanHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		CustomerKey          string          `json:"customer_key"`
-		Principal            decimal.Decimal `json:"principal"`
-		BaseInterestRate     decimal.Decimal `json:"base_interest_rate"`
-		InterestRateVariance decimal.Decimal `json:"interest_rate_variance"`
-	}
+func main() {
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "TOML configuration file")
+	flag.Parse()
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	cfg, err := config.Load(*configPath, nil)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
+	slog.SetLogLoggerLevel(cfg.LogLevel)
 
-	loan, err := s.ledger.CreateLoan(req.CustomerKey, req.Principal, req.BaseInterestRate, req.InterestRateVariance)
+	key, err := cfg.DatabaseKey()
 	if err != nil {
-		log.Printf("Error creating loan: %v\n", err)
-		http.Error(w, fmt.Sprintf("Failed to create loan: %v", err), http.StatusInternalServerError)
-		return
+		log.Fatalf("Invalid database encryption settings: %v", err)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(loan)
-}
-
-func (s *Server) getLoanHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	loanID, err := uuid.Parse(idStr)
+	dsn, err := store.WithEncryptionKey(cfg.DatabaseDriver, cfg.DatabaseDSN, key)
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
-		return
+		log.Fatalf("Invalid database encryption settings: %v", err)
 	}
-
-	loan, err := s.ledger.GetLoan(loanID)
+	dsn, err = store.WithSQLiteTuning(cfg.DatabaseDriver, dsn, store.SQLiteTuning{
+		BusyTimeout: cfg.DatabaseSQLiteBusyTimeout,
+		Synchronous: cfg.DatabaseSQLiteSynchronous,
+		CacheSize:   cfg.DatabaseSQLiteCacheSize,
+	})
 	if err != nil {
-		if err.Error() == "loan not found" {
-			http.Error(w, "Loan not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
+		log.Fatalf("Invalid SQLite settings: %v", err)
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(loan)
-}
-
-func (s *Server) listLoansHandler(w http.ResponseWriter, r *http.Request) {
-	loans, err := s.ledger.GetAllLoans()
+	database, err := store.Open(cfg.DatabaseDriver, dsn)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Fatalf("Failed to initialize %s store: %v", cfg.DatabaseDriver, err)
+	}
+	if pool, ok := database.(store.ConnectionPool); ok {
+		pool.SetConnectionLimits(cfg.DatabaseMaxOpenConns, cfg.DatabaseMaxIdleConns)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(loans)
-}
-
-func (s *Server) updateLoanHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	loanID, err := uuid.Parse(idStr)
+	// Store metrics measure the database itself, beneath injected faults and the cache, and
+	// count only the busy errors left once retries run out
+	var retried store.Storage = database
+	if cfg.DatabaseDriver == store.DriverSQLite && cfg.DatabaseSQLiteBusyRetries > 0 {
+		retried = store.NewRetryingStore(database, cfg.DatabaseSQLiteBusyRetries)
+	}
+	instrumented := store.NewInstrumentedStore(retried, cfg.DatabaseSlowQueryThreshold)
+	var storage store.Storage = instrumented
+	faults, faultsEnabled, err := faultConfigFromEnv()
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
-		return
+		log.Fatalf("Invalid fault injection settings: %v", err)
 	}
-
-	var loan models.Loan
-	if err := json.NewDecoder(r.Body).Decode(&loan); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	if faultsEnabled {
+		log.Printf("Fault injection enabled: %+v\n", faults)
+		storage = store.NewFaultyStore(storage, faults)
 	}
-	loan.ID = loanID // Ensure ID from URL is used
 
-	if err := s.ledger.UpdateLoan(&loan); err != nil {
-		if err.Error() == "loan not found" {
-			http.Error(w, "Loan not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	if cfg.CacheRedisURL != "" {
+		opts, err := redis.ParseURL(cfg.CacheRedisURL)
+		if err != nil {
+			log.Fatalf("Invalid cache Redis URL: %v", err)
+		}
+		client := redis.NewClient(opts)
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			log.Printf("Cache Redis server is unreachable; reading from the database until it is: %v", err)
 		}
-		return
+		log.Printf("Caching loans in Redis at %s for up to %s.", opts.Addr, cfg.CacheTTL)
+		storage = store.NewCachedStore(storage, client, cfg.CacheTTL)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(loan)
-}
-
-func (s *Server) deleteLoanHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	loanID, err := uuid.Parse(idStr)
+	tracer, err := tracerFromEnv()
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
-		return
+		log.Fatalf("Invalid tracing settings: %v", err)
+	}
+	if tracer != nil {
+		storage = store.NewTracedStore(storage, tracer)
+	} else {
+		log.Println("OTEL_EXPORTER_OTLP_ENDPOINT not set; traces are not exported.")
 	}
 
-	if err := s.ledger.DeleteLoan(loanID); err != nil {
-		if err.Error() == "loan not found" {
-			http.Error(w, "Loan not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	server := api.NewServer(storage)
+	server.Metrics().Register(instrumented.Collectors()...)
+	server.SetTracer(tracer)
+	server.Ledger().SetAutoChargeOff(autoChargeOffDaysPastDue)
+	server.Ledger().SetEventSourcing(os.Getenv("EVENT_SOURCING") == "true")
+	var replica store.ReplicaReader
+	if cfg.DatabaseReadReplicaDSN != "" {
+		replica, err = store.OpenReplica(cfg.DatabaseDriver, cfg.DatabaseReadReplicaDSN)
+		if err != nil {
+			log.Fatalf("Failed to connect to the read replica: %v", err)
 		}
-		return
+		if pool, ok := replica.(store.ConnectionPool); ok {
+			pool.SetConnectionLimits(cfg.DatabaseMaxOpenConns, cfg.DatabaseMaxIdleConns)
+		}
+		log.Printf("Serving reports from the read replica at %s.", store.RedactDataSource(cfg.DatabaseDriver, cfg.DatabaseReadReplicaDSN))
+		server.Ledger().SetReportReader(replica)
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
-
-func (s *Server) recordPaymentHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	loanID, err := uuid.Parse(idStr)
+	rounding, err := roundingPolicyFromEnv()
 	if err != nil {
-		http.Error(w, "Invalid loan ID", http.StatusBadRequest)
-		return
+		log.Fatalf("Invalid rounding policy: %v", err)
+	}
+	if err := server.Ledger().SetRoundingPolicy(rounding); err != nil {
+		log.Fatalf("Invalid rounding policy: %v", err)
 	}
 
-	var req struct {
-		Amount decimal.Decimal `json:"amount"`
+	linkSecret := []byte(os.Getenv("PAYMENT_LINK_SECRET"))
+	if len(linkSecret) == 0 {
+		linkSecret = make([]byte, 32)
+		if _, err := rand.Read(linkSecret); err != nil {
+			log.Fatalf("Failed to generate payment link secret: %v", err)
+		}
+		log.Println("PAYMENT_LINK_SECRET not set; payment links will not survive a restart.")
 	}
+	server.Ledger().SetPaymentLinkSecret(linkSecret)
+	server.SetPaymentWebhookSecret([]byte(os.Getenv("PAYMENT_WEBHOOK_SECRET")))
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	bureauFormat, err := bureauFormatFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid bureau format: %v", err)
+	}
+	server.SetBureauFormat(bureauFormat)
+	bureauExportDir := os.Getenv("BUREAU_EXPORT_DIR")
+	if bureauExportDir == "" {
+		bureauExportDir = defaultBureauExportDir
 	}
 
-	if req.Amount.LessThanOrEqual(decimal.Zero) {
-		http.Error(w, "Amount must be positive", http.StatusBadRequest)
-		return
+	indexSeries := defaultIndexSeries
+	if series := os.Getenv("FRED_SERIES"); series != "" {
+		indexSeries = strings.Split(series, ",")
+	}
+	if apiKey := os.Getenv("FRED_API_KEY"); apiKey != "" {
+		server.SetIndexSource(fred.NewClient(apiKey))
+	} else {
+		log.Println("FRED_API_KEY not set; index rates must be published manually.")
 	}
 
-	tx, err := s.ledger.RecordPayment(loanID, req.Amount)
-	if err != nil {
-		if err.Error() == "loan not found" {
-			http.Error(w, "Loan not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+	verifier := tokenVerifierFromEnv()
+	if verifier != nil {
+		server.SetTokenVerifier(verifier)
+	} else {
+		log.Println("OIDC_ISSUER not set; API requests are not authenticated.")
+	}
+	server.SetSwaggerUI(os.Getenv("SWAGGER_UI") == "true")
+	if backuper, ok := database.(store.Backuper); ok {
+		server.SetBackuper(backuper)
+	}
+	if cfg.ArchiveExportURL != "" {
+		archive, err := s3.NewClient(cfg.ArchiveExportURL, cfg.ArchiveExportRegion, cfg.ArchiveExportAccessKeyID, cfg.ArchiveExportSecretAccessKey)
+		if err != nil {
+			log.Fatalf("Invalid archive export: %v", err)
 		}
-		return
+		server.SetArchiveExport(archive, cfg.ArchiveExportAfterYears, cfg.ArchiveExportPrune)
+		slog.Info("Exporting archived loans to object storage", "url", cfg.ArchiveExportURL, "after_years", cfg.ArchiveExportAfterYears, "prune", cfg.ArchiveExportPrune)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(tx)
-}
+	// Stop on SIGINT or SIGTERM. A second signal exits at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func main() {
-	// Initialize SQLite Store
-	sqliteStore, err := store.NewSQLiteStore("fredloan.db")
-	if err != nil {
-		log.Fatalf("Failed to initialize SQLite store: %v", err)
-	}
-	defer sqliteStore.Close()
+	var jobs sync.WaitGroup
 
-	server := NewServer(sqliteStore)
-	router := mux.NewRouter()
+	// Start a goroutine for daily and monthly batch processing. A batch in progress at
+	// shutdown runs to completion.
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		ticker := time.NewTicker(cfg.BatchInterval)
+		defer ticker.Stop()
 
-	router.HandleFunc("/loans", server.listLoansHandler).Methods("GET")
-	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
-	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
-	router.HandleFunc("/loans/{id}", server.updateLoanHandler).Methods("PUT")
-	router.HandleFunc("/loans/{id}", server.deleteLoanHandler).Methods("DELETE")
-	router.HandleFunc("/loans/{id}/payments", server.recordPaymentHandler).Methods("POST")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				server.RunDailyBatch(indexSeries, bureauExportDir)
+			}
+		}
+	}()
 
-	// Start a goroutine for daily and monthly batch processing
+	// Relay the outbox and send queued webhook events more often than the daily batch, so
+	// subscribers hear of payments and new loans promptly
+	jobs.Add(1)
 	go func() {
-		ticker := time.NewTicker(10 * time.Second) // Simulate daily for testing
+		defer jobs.Done()
+		ticker := time.NewTicker(cfg.WebhookInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			log.Println("Running daily interest calculation...")
-			server.ledger.CalculateDailyInterest()
-			log.Println("Daily interest calculation complete.")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				server.DeliverWebhooks(ctx)
+			}
+		}
+	}()
+
+	tlsConfig, certManager, err := tlsConfigFor(cfg)
+	if err != nil {
+		log.Fatalf("Invalid TLS settings: %v", err)
+	}
 
-			log.Println("Running monthly interest application...")
-			server.ledger.ApplyMonthlyInterest()
-			log.Println("Monthly interest application complete.")
+	httpServer := &http.Server{
+		Addr:              cfg.ListenAddress,
+		Handler:           strictTransportSecurity(api.NewHandler(server, cfg)),
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	httpServer.RegisterOnShutdown(server.CloseEventStreams)
+
+	serveErr := make(chan error, 3)
+	go func() {
+		if tlsConfig == nil {
+			log.Printf("Server starting on %s\n", cfg.ListenAddress)
+			serveErr <- httpServer.ListenAndServe()
+			return
 		}
+		log.Printf("Server starting on %s with TLS\n", cfg.ListenAddress)
+		serveErr <- httpServer.ListenAndServeTLS("", "")
 	}()
 
-	log.Println("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	// With TLS, plain HTTP is only redirected to HTTPS (and answers ACME challenges)
+	var redirectServer *http.Server
+	if cfg.HTTPRedirectAddress != "" {
+		var redirect http.Handler = httpsRedirect(cfg.ListenAddress)
+		if certManager != nil {
+			redirect = certManager.HTTPHandler(redirect)
+		}
+		redirectServer = &http.Server{
+			Addr:              cfg.HTTPRedirectAddress,
+			Handler:           redirect,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			ReadTimeout:       cfg.ReadTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS\n", cfg.HTTPRedirectAddress)
+			serveErr <- redirectServer.ListenAndServe()
+		}()
+	}
+
+	// The gRPC ledger service shares the HTTP server's ledger, TLS settings and authentication
+	var ledgerService *grpcapi.Server
+	var grpcServer *grpc.Server
+	if cfg.GRPCListenAddress != "" {
+		ledgerService = grpcapi.NewServer(server.Ledger())
+		if verifier != nil {
+			ledgerService.SetTokenVerifier(verifier)
+		}
+		var opts []grpc.ServerOption
+		if tlsConfig != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcServer = ledgerService.NewGRPCServer(opts...)
+		listener, err := net.Listen("tcp", cfg.GRPCListenAddress)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC on %s: %v", cfg.GRPCListenAddress, err)
+		}
+		go func() {
+			log.Printf("gRPC ledger service starting on %s\n", cfg.GRPCListenAddress)
+			serveErr <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		log.Fatalf("Server failed: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Printf("Shutting down; waiting up to %s for requests and batch jobs to finish...\n", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining requests: %v\n", err)
+	}
+	if redirectServer != nil {
+		redirectServer.Shutdown(shutdownCtx)
+	}
+	if grpcServer != nil {
+		ledgerService.CloseStreams()
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+	jobs.Wait()
+	if err := tracer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error exporting the last spans: %v\n", err)
+	}
+	if err := storage.Close(); err != nil {
+		log.Printf("Error closing store: %v\n", err)
+	}
+	if replica != nil {
+		if err := replica.Close(); err != nil {
+			log.Printf("Error closing read replica: %v\n", err)
+		}
+	}
+	log.Println("Shutdown complete.")
 }
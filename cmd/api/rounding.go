@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// roundingPolicyFromEnv reads the interest rounding policy from ROUNDING_MODE (half_up or
+// half_even), ROUNDING_PLACES (2 or 3, default 2) and ROUNDING_TRACK_RESIDUAL. Without
+// ROUNDING_MODE interest keeps full precision.
+func roundingPolicyFromEnv() (models.RoundingPolicy, error) {
+	policy := models.RoundingPolicy{Mode: models.RoundingMode(os.Getenv("ROUNDING_MODE")), Places: 2}
+	if policy.Mode == models.RoundingNone {
+		return models.RoundingPolicy{}, nil
+	}
+	if v := os.Getenv("ROUNDING_PLACES"); v != "" {
+		places, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return policy, fmt.Errorf("ROUNDING_PLACES must be a number")
+		}
+		policy.Places = int32(places)
+	}
+	if v := os.Getenv("ROUNDING_TRACK_RESIDUAL"); v != "" {
+		track, err := strconv.ParseBool(v)
+		if err != nil {
+			return policy, fmt.Errorf("ROUNDING_TRACK_RESIDUAL must be true or false")
+		}
+		policy.TrackResidual = track
+	}
+	return policy, nil
+}
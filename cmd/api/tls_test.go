@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/config"
+)
+
+// writeTestCertificate writes a self-signed certificate for localhost and returns the file paths.
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestTLSConfigFor(t *testing.T) {
+	if tlsConfig, manager, err := tlsConfigFor(config.Default()); tlsConfig != nil || manager != nil || err != nil {
+		t.Errorf("Expected no TLS by default, got %v, %v, %v", tlsConfig, manager, err)
+	}
+
+	cfg := config.Default()
+	cfg.TLSCertFile, cfg.TLSKeyFile = writeTestCertificate(t)
+	tlsConfig, manager, err := tlsConfigFor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manager != nil || len(tlsConfig.Certificates) != 1 || tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Unexpected TLS config from files: %+v", tlsConfig)
+	}
+
+	// The API is served over TLS with the loaded certificate, and responses carry HSTS
+	ts := httptest.NewUnstartedServer(strictTransportSecurity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12}}}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil || resp.Header.Get("Strict-Transport-Security") == "" {
+		t.Errorf("Expected an HTTPS response with HSTS, got %+v", resp.Header)
+	}
+	if suite := resp.TLS.CipherSuite; suite != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 && suite != tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384 && suite != tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256 {
+		t.Errorf("Expected a forward-secret AEAD suite, got %s", tls.CipherSuiteName(suite))
+	}
+
+	cfg.TLSKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	if _, _, err := tlsConfigFor(cfg); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+
+	cfg = config.Default()
+	cfg.AutocertDomains = []string{"loans.example.com"}
+	cfg.AutocertCacheDir = t.TempDir()
+	tlsConfig, manager, err = tlsConfigFor(cfg)
+	if err != nil || manager == nil || tlsConfig.GetCertificate == nil {
+		t.Errorf("Expected autocert to supply certificates, got %+v, %v", tlsConfig, err)
+	}
+}
+
+func TestHTTPSRedirect(t *testing.T) {
+	tests := []struct {
+		listen   string
+		url      string
+		expected string
+	}{
+		{":443", "http://loans.example.com/loans?status=active", "https://loans.example.com/loans?status=active"},
+		{":8443", "http://loans.example.com:8080/loans/1/payments", "https://loans.example.com:8443/loans/1/payments"},
+		{"0.0.0.0:8443", "http://[::1]/loans", "https://[::1]:8443/loans"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		httpsRedirect(tt.listen).ServeHTTP(rr, httptest.NewRequest("POST", tt.url, nil))
+		if rr.Code != http.StatusPermanentRedirect || rr.Header().Get("Location") != tt.expected {
+			t.Errorf("%s on %s: expected 308 to %s, got %d to %s", tt.url, tt.listen, tt.expected, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	strictTransportSecurity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, httptest.NewRequest("GET", "/loans", nil))
+	if rr.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("Expected no HSTS header over plain HTTP")
+	}
+}
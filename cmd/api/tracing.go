@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+)
+
+// defaultServiceName is reported to the tracing backend when OTEL_SERVICE_NAME is unset.
+const defaultServiceName = "fredloan"
+
+// tracerFromEnv configures span export from the standard OpenTelemetry variables: spans are
+// posted to OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or to OTEL_EXPORTER_OTLP_ENDPOINT with
+// /v1/traces appended, with OTEL_EXPORTER_OTLP_HEADERS on each request. It returns nil, which
+// disables tracing, when neither endpoint is set.
+func tracerFromEnv() (*tracing.Tracer, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+
+	headers, err := tracing.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	return tracing.NewTracer(tracing.NewOTLPExporter(endpoint, serviceName, headers), 0), nil
+}
@@ -0,0 +1,42 @@
+// Command compliance runs externally supplied accrual test vectors against the ledger's
+// interest engine and exits non-zero if any vector does not match.
+//
+// Usage:
+//
+//	compliance -vectors accrual_vectors.csv
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/mcclellann/fredLoan/pkg/compliance"
+)
+
+func main() {
+	path := flag.String("vectors", "", "CSV file of accrual test vectors")
+	flag.Parse()
+
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("Failed to open vectors: %v", err)
+	}
+	defer f.Close()
+
+	vectors, err := compliance.LoadVectors(f)
+	if err != nil {
+		log.Fatalf("Failed to load vectors: %v", err)
+	}
+
+	report := compliance.Run(vectors)
+	report.Write(os.Stdout)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
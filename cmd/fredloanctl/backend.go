@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/client"
+	"github.com/mcclellann/fredLoan/pkg/config"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+// backend performs commands, either over the HTTP API (*client.Client) or directly on the
+// database (*directBackend).
+type backend interface {
+	ListLoans(ctx context.Context, query models.LoanQuery) (*models.LoanPage, error)
+	CreateLoan(ctx context.Context, req client.CreateLoanRequest) (*models.Loan, error)
+	RecordPayment(ctx context.Context, loanID uuid.UUID, payment client.Payment) (*models.Transaction, error)
+	RunJob(ctx context.Context, job models.JobName) (*models.JobRun, error)
+}
+
+// directBackend runs commands through a ledger on the database. It uses the ledger's default
+// policies rather than any the server sets from its environment, such as rounding and
+// automatic charge-off, and does not coordinate with the server's own batch.
+type directBackend struct {
+	store  store.Storage
+	ledger *ledger.Ledger
+}
+
+// openDatabase opens the configuration's database, or the one named by dsn if set, with the
+// configuration's driver. It also returns the data source with any password masked.
+func openDatabase(configPath string, dsn string) (store.Storage, string, error) {
+	driver, dsn, err := dataSource(configPath, dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	s, err := store.Open(driver, dsn)
+	return s, store.RedactDataSource(driver, dsn), err
+}
+
+// dataSource returns the configuration's database driver and data source, or dsn if set,
+// with the configuration's encryption key and SQLite settings.
+func dataSource(configPath string, dsn string) (string, string, error) {
+	cfg, err := config.Load(configPath, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid configuration: %w", err)
+	}
+	if dsn == "" {
+		dsn = cfg.DatabaseDSN
+	}
+	key, err := cfg.DatabaseKey()
+	if err != nil {
+		return "", "", err
+	}
+	dsn, err = store.WithEncryptionKey(cfg.DatabaseDriver, dsn, key)
+	if err != nil {
+		return "", "", err
+	}
+	dsn, err = store.WithSQLiteTuning(cfg.DatabaseDriver, dsn, store.SQLiteTuning{
+		BusyTimeout: cfg.DatabaseSQLiteBusyTimeout,
+		Synchronous: cfg.DatabaseSQLiteSynchronous,
+		CacheSize:   cfg.DatabaseSQLiteCacheSize,
+	})
+	return cfg.DatabaseDriver, dsn, err
+}
+
+// openDirect opens the database for direct commands.
+func openDirect(configPath string, dsn string) (*directBackend, error) {
+	s, _, err := openDatabase(configPath, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return &directBackend{store: s, ledger: ledger.NewLedger(s)}, nil
+}
+
+func (d *directBackend) Close() error {
+	return d.store.Close()
+}
+
+func (d *directBackend) ListLoans(ctx context.Context, query models.LoanQuery) (*models.LoanPage, error) {
+	return d.ledger.ListLoans(ctx, query)
+}
+
+func (d *directBackend) CreateLoan(ctx context.Context, req client.CreateLoanRequest) (*models.Loan, error) {
+	opts := []ledger.LoanOption{ledger.WithTerm(req.TermMonths)}
+	if req.ProductCode != "" {
+		opts = append(opts, ledger.WithProduct(req.ProductCode))
+	}
+	return d.ledger.CreateLoan(ctx, req.CustomerKey, req.Principal, req.BaseInterestRate, req.InterestRateVariance, opts...)
+}
+
+// RecordPayment records a payment. Idempotency keys apply to the API only and are ignored.
+func (d *directBackend) RecordPayment(ctx context.Context, loanID uuid.UUID, payment client.Payment) (*models.Transaction, error) {
+	var opts []ledger.PaymentOption
+	if payment.PaymentMethodID != nil {
+		opts = append(opts, ledger.WithPaymentMethod(*payment.PaymentMethodID))
+	}
+	if payment.EscrowAmount.IsPositive() {
+		return d.ledger.RecordPaymentWithEscrow(ctx, loanID, payment.Amount, payment.EscrowAmount, opts...)
+	}
+	return d.ledger.RecordPayment(ctx, loanID, payment.Amount, opts...)
+}
+
+func (d *directBackend) RunJob(ctx context.Context, job models.JobName) (*models.JobRun, error) {
+	runs := map[models.JobName]func(context.Context, ledger.JobScope) (*models.JobRun, error){
+		models.JobDailyInterest:   d.ledger.RunDailyInterest,
+		models.JobMonthlyInterest: d.ledger.RunMonthlyInterest,
+	}
+	runJob, ok := runs[job]
+	if !ok {
+		return nil, fmt.Errorf("job %s cannot be run on demand", job)
+	}
+	run, err := runJob(ctx, ledger.JobScope{})
+	if err != nil {
+		return nil, err
+	}
+	run.Trigger = models.JobTriggerManual
+	if err := d.ledger.RecordJobRun(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to record job run: %w", err)
+	}
+	return run, nil
+}
+
+// migrate moves the database schema to a version, or brings it up to date, and reports the
+// version it is left at. Unlike opening the store, it does not apply newer migrations before
+// reverting to an older version.
+func migrate(ctx context.Context, configPath string, dsn string, fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	to := fs.Int("to", -1, "schema version to migrate to, applying or reverting migrations (default the latest)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage // The flag package has printed the problem
+	}
+
+	m, dsn, err := openMigrator(configPath, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	defer m.Close()
+	var ran int
+	if *to < 0 {
+		ran, err = m.Migrate(ctx)
+	} else {
+		ran, err = m.MigrateTo(ctx, *to)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	statuses, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	version := 0
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			version = status.Version
+		}
+	}
+	fmt.Fprintf(stdout, "Schema of %s is at version %d (%d migrations run)\n", dsn, version, ran)
+	return nil
+}
+
+// migrationStatus lists the database's migrations and when each was applied, if it has been.
+func migrationStatus(ctx context.Context, configPath string, dsn string, fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage // The flag package has printed the problem
+	}
+
+	m, _, err := openMigrator(configPath, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer m.Close()
+	statuses, err := m.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(statuses)
+}
+
+// openMigrator opens the database for managing its migrations, without migrating it.
+func openMigrator(configPath string, dsn string) (store.Migrator, string, error) {
+	driver, dsn, err := dataSource(configPath, dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	m, err := store.OpenMigrator(driver, dsn)
+	return m, store.RedactDataSource(driver, dsn), err
+}
+
+// backup writes a consistent snapshot of the SQLite database to a new file, through the API
+// if c is set and directly otherwise. Either way the server can keep running.
+func backup(ctx context.Context, c *client.Client, configPath string, dsn string, fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	out := fs.String("out", "", "file to write the backup to, which must not exist (required)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage // The flag package has printed the problem
+	}
+	if *out == "" {
+		return fmt.Errorf("db backup needs -out")
+	}
+
+	if c != nil {
+		file, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to create backup file: %w", err)
+		}
+		err = c.Backup(ctx, file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(*out)
+			return fmt.Errorf("failed to back up database: %w", err)
+		}
+		fmt.Fprintf(stdout, "Backed up the database of %s to %s\n", c.BaseURL, *out)
+		return nil
+	}
+
+	b, dsn, err := openBackuper(configPath, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer b.Close()
+	if err := b.Backup(ctx, *out); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Backed up %s to %s\n", dsn, *out)
+	return nil
+}
+
+// restore replaces the SQLite database's contents with a backup and migrates it, reporting
+// the schema version it is left at. The server should be stopped while it runs.
+func restore(ctx context.Context, configPath string, dsn string, fs *flag.FlagSet, args []string, stdout io.Writer) error {
+	from := fs.String("from", "", "backup file to restore (required)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage // The flag package has printed the problem
+	}
+	if *from == "" {
+		return fmt.Errorf("db restore needs -from")
+	}
+
+	b, dsn, err := openBackuper(configPath, dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer b.Close()
+	version, err := b.Restore(ctx, *from)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Restored %s from %s; schema is at version %d\n", dsn, *from, version)
+	return nil
+}
+
+// openBackuper opens the database for backing it up or restoring it, without migrating it.
+func openBackuper(configPath string, dsn string) (store.Backuper, string, error) {
+	driver, dsn, err := dataSource(configPath, dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := store.OpenBackuper(driver, dsn)
+	return b, store.RedactDataSource(driver, dsn), err
+}
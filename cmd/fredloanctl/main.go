@@ -0,0 +1,258 @@
+// Command fredloanctl is an operator's tool for the loan ledger. It works either directly on
+// the database, like the server itself, or through a running server's HTTP API.
+//
+// Usage:
+//
+//	fredloanctl [-config file] [-db dsn] [-api url] [-token token] <command> [flags]
+//
+// Commands:
+//
+//	loans list      [-status s] [-customer key] [-sort s] [-limit n] [-offset n]
+//	loans create    -customer key -principal amount -rate rate [-variance v] [-product code] [-term months]
+//	payments post   -loan id -amount amount [-escrow amount] [-idempotency-key key]
+//	jobs run        daily-interest | monthly-interest
+//	db migrate      [-to version]
+//	db status
+//	db backup       -out file
+//	db restore      -from file
+//
+// Results are printed as JSON.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/client"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// errUsage reports a command line that could not be understood; the usage has been printed.
+var errUsage = errors.New("invalid usage")
+
+const usage = `Usage: fredloanctl [-config file] [-db dsn] [-api url] [-token token] <command> [flags]
+
+Commands:
+  loans list      [-status s] [-customer key] [-sort s] [-limit n] [-offset n]
+  loans create    -customer key -principal amount -rate rate [-variance v] [-product code] [-term months]
+  payments post   -loan id -amount amount [-escrow amount] [-idempotency-key key]
+  jobs run        daily-interest | monthly-interest
+  db migrate      [-to version]
+  db status
+  db backup       -out file
+  db restore      -from file
+
+Without -api, commands work directly on the database named by -db or the server configuration.
+Run "fredloanctl <command> -h" for a command's flags.
+
+Global flags:
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "fredloanctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run executes one command line, writing its result to stdout and usage to stderr.
+func run(args []string, stdout io.Writer, stderr io.Writer) error {
+	global := flag.NewFlagSet("fredloanctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		global.PrintDefaults()
+	}
+	configPath := global.String("config", os.Getenv("CONFIG_FILE"), "TOML configuration file of the server, for its database")
+	dsn := global.String("db", "", "data source of the configured database driver, overriding the configuration's")
+	apiURL := global.String("api", os.Getenv("FREDLOAN_API"), "base URL of a running server to send commands to instead of using the database")
+	token := global.String("token", os.Getenv("FREDLOAN_TOKEN"), "bearer token for the API")
+	timeout := global.Duration("timeout", 5*time.Minute, "time allowed for the command")
+	if err := global.Parse(args); err != nil {
+		return errUsage
+	}
+
+	rest := global.Args()
+	if len(rest) < 2 {
+		global.Usage()
+		return errUsage
+	}
+	name, args := rest[0]+" "+rest[1], rest[2:]
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n\n", name)
+		global.Usage()
+		return errUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if name == "db backup" {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		var c *client.Client
+		if *apiURL != "" {
+			c = client.NewClient(*apiURL)
+			c.Token = *token
+		}
+		return backup(ctx, c, *configPath, *dsn, fs, args, stdout)
+	}
+	if name == "db migrate" || name == "db status" || name == "db restore" {
+		if *apiURL != "" {
+			return fmt.Errorf("%s works on the database and cannot be used with -api", name)
+		}
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		switch name {
+		case "db status":
+			return migrationStatus(ctx, *configPath, *dsn, fs, args, stdout)
+		case "db restore":
+			return restore(ctx, *configPath, *dsn, fs, args, stdout)
+		}
+		return migrate(ctx, *configPath, *dsn, fs, args, stdout)
+	}
+
+	var b backend
+	if *apiURL != "" {
+		c := client.NewClient(*apiURL)
+		c.Token = *token
+		b = c
+	} else {
+		direct, err := openDirect(*configPath, *dsn)
+		if err != nil {
+			return err
+		}
+		defer direct.Close()
+		b = direct
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	result, err := cmd(ctx, b, fs, args)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
+// command parses its flags into fs and performs itself against a backend, returning what to
+// print.
+type command func(ctx context.Context, b backend, fs *flag.FlagSet, args []string) (any, error)
+
+var commands = map[string]command{
+	"loans list":    listLoans,
+	"loans create":  createLoan,
+	"payments post": postPayment,
+	"jobs run":      runJob,
+	"db migrate":    nil, // Handled before a backend is chosen
+	"db status":     nil,
+	"db backup":     nil,
+	"db restore":    nil,
+}
+
+func listLoans(ctx context.Context, b backend, fs *flag.FlagSet, args []string) (any, error) {
+	status := fs.String("status", "", "only loans with this status (voided loans are listed only when asked for)")
+	customer := fs.String("customer", "", "only loans of this customer key")
+	sort := fs.String("sort", "", "created_at or balance; prefix - for descending")
+	limit := fs.Int("limit", 100, "loans per page, at most 1000")
+	offset := fs.Int("offset", 0, "loans to skip")
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage // The flag package has printed the problem
+	}
+
+	return b.ListLoans(ctx, models.LoanQuery{
+		Status:      models.LoanStatus(*status),
+		CustomerKey: *customer,
+		Sort:        models.LoanSort(*sort),
+		Limit:       *limit,
+		Offset:      *offset,
+	})
+}
+
+func createLoan(ctx context.Context, b backend, fs *flag.FlagSet, args []string) (any, error) {
+	customer := fs.String("customer", "", "customer key (required)")
+	principal := decimalFlag(fs, "principal", "amount disbursed (required)")
+	rate := decimalFlag(fs, "rate", "base annual interest rate as a fraction, e.g. 0.08 (required)")
+	variance := decimalFlag(fs, "variance", "added to the base rate for the effective rate")
+	product := fs.String("product", "", "product code")
+	term := fs.Int("term", 0, "term in months")
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage // The flag package has printed the problem
+	}
+	if *customer == "" || principal.IsZero() || rate.IsZero() {
+		return nil, fmt.Errorf("loans create needs -customer, -principal and -rate")
+	}
+
+	return b.CreateLoan(ctx, client.CreateLoanRequest{
+		CustomerKey:          *customer,
+		Principal:            *principal,
+		BaseInterestRate:     *rate,
+		InterestRateVariance: *variance,
+		ProductCode:          *product,
+		TermMonths:           *term,
+	})
+}
+
+func postPayment(ctx context.Context, b backend, fs *flag.FlagSet, args []string) (any, error) {
+	loan := fs.String("loan", "", "loan ID (required)")
+	amount := decimalFlag(fs, "amount", "amount paid (required)")
+	escrow := decimalFlag(fs, "escrow", "portion of the amount deposited into escrow")
+	key := fs.String("idempotency-key", "", "key that makes repeating the command safe (with -api)")
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage // The flag package has printed the problem
+	}
+	loanID, err := uuid.Parse(*loan)
+	if err != nil {
+		return nil, fmt.Errorf("payments post needs the -loan ID: %w", err)
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("payments post needs a positive -amount")
+	}
+
+	return b.RecordPayment(ctx, loanID, client.Payment{Amount: *amount, EscrowAmount: *escrow, IdempotencyKey: *key})
+}
+
+func runJob(ctx context.Context, b backend, fs *flag.FlagSet, args []string) (any, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage // The flag package has printed the problem
+	}
+	jobs := map[string]models.JobName{
+		"daily-interest":   models.JobDailyInterest,
+		"monthly-interest": models.JobMonthlyInterest,
+	}
+	job, ok := jobs[fs.Arg(0)]
+	if fs.NArg() != 1 || !ok {
+		return nil, fmt.Errorf("jobs run needs one job: daily-interest or monthly-interest")
+	}
+	return b.RunJob(ctx, job)
+}
+
+// decimalFlag defines a flag holding a decimal amount, zero by default.
+func decimalFlag(fs *flag.FlagSet, name string, usage string) *decimal.Decimal {
+	value := new(decimal.Decimal)
+	fs.Func(name, usage, func(s string) error {
+		parsed, err := decimal.NewFromString(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("not a number")
+		}
+		*value = parsed
+		return nil
+	})
+	return value
+}
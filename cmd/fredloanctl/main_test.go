@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+func TestRunDirect(t *testing.T) {
+	dbFile := "test_fredloanctl.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	exec := func(out any, args ...string) error {
+		var stdout bytes.Buffer
+		err := run(append([]string{"-db", dbFile}, args...), &stdout, io.Discard)
+		if err == nil && out != nil {
+			if err := json.Unmarshal(stdout.Bytes(), out); err != nil {
+				t.Fatalf("Failed to decode output of %v: %v\n%s", args, err, stdout.String())
+			}
+		}
+		return err
+	}
+
+	if err := exec(nil, "db", "migrate"); err != nil {
+		t.Fatalf("db migrate failed: %v", err)
+	}
+	var statuses []store.MigrationStatus
+	if err := exec(&statuses, "db", "status"); err != nil {
+		t.Fatalf("db status failed: %v", err)
+	}
+	if len(statuses) == 0 || statuses[len(statuses)-1].AppliedAt == nil {
+		t.Errorf("Expected every migration applied, got %+v", statuses)
+	}
+	if err := exec(nil, "db", "migrate", "-to", "0"); err != nil {
+		t.Fatalf("db migrate -to 0 failed: %v", err)
+	}
+	var reverted []store.MigrationStatus
+	exec(&reverted, "db", "status")
+	if reverted[0].AppliedAt != nil {
+		t.Errorf("Expected no migration applied at version 0, got %+v", reverted)
+	}
+	if err := exec(nil, "db", "migrate"); err != nil {
+		t.Fatalf("db migrate failed: %v", err)
+	}
+
+	var loan models.Loan
+	if err := exec(&loan, "loans", "create", "-customer", "cust_a", "-principal", "1000", "-rate", "0.1", "-term", "12"); err != nil {
+		t.Fatalf("loans create failed: %v", err)
+	}
+	if loan.CustomerKey != "cust_a" || loan.TermMonths != 12 {
+		t.Errorf("Expected a 12-month loan for cust_a, got %+v", loan)
+	}
+
+	var tx models.Transaction
+	if err := exec(&tx, "payments", "post", "-loan", loan.ID.String(), "-amount", "100.50"); err != nil {
+		t.Fatalf("payments post failed: %v", err)
+	}
+	if tx.Type != models.TransactionTypePayment || tx.Amount.String() != "100.5" {
+		t.Errorf("Expected a payment of 100.50, got %s of %s", tx.Type, tx.Amount)
+	}
+
+	var page models.LoanPage
+	if err := exec(&page, "loans", "list", "-customer", "cust_a"); err != nil {
+		t.Fatalf("loans list failed: %v", err)
+	}
+	if page.Total != 1 || page.Loans[0].ID != loan.ID || page.Loans[0].Balance.String() != "899.5" {
+		t.Errorf("Expected the paid-down loan, got %+v", page)
+	}
+
+	var jobRun models.JobRun
+	if err := exec(&jobRun, "jobs", "run", "daily-interest"); err != nil {
+		t.Fatalf("jobs run failed: %v", err)
+	}
+	if jobRun.Job != models.JobDailyInterest || jobRun.Trigger != models.JobTriggerManual {
+		t.Errorf("Expected a manual daily interest run, got %+v", jobRun)
+	}
+
+	backupFile := "test_fredloanctl.bak.db"
+	os.Remove(backupFile)
+	defer os.Remove(backupFile)
+	if err := exec(nil, "db", "backup", "-out", backupFile); err != nil {
+		t.Fatalf("db backup failed: %v", err)
+	}
+	if err := exec(nil, "payments", "post", "-loan", loan.ID.String(), "-amount", "99.50"); err != nil {
+		t.Fatalf("payments post failed: %v", err)
+	}
+	if err := exec(nil, "db", "restore", "-from", backupFile); err != nil {
+		t.Fatalf("db restore failed: %v", err)
+	}
+	var restored models.LoanPage
+	exec(&restored, "loans", "list", "-customer", "cust_a")
+	if restored.Total != 1 || restored.Loans[0].Balance.String() != "899.5" {
+		t.Errorf("Expected the balance as backed up, got %+v", restored)
+	}
+
+	for _, args := range [][]string{{"loans"}, {"loans", "delete"}, {"loans", "list", "-bogus"}} {
+		if err := exec(nil, args...); !errors.Is(err, errUsage) {
+			t.Errorf("Expected a usage error for %v, got %v", args, err)
+		}
+	}
+	if err := exec(nil, "payments", "post", "-loan", "not-a-uuid", "-amount", "1"); err == nil {
+		t.Error("Expected an invalid loan ID to be rejected")
+	}
+	if err := run([]string{"-api", "http://localhost:1", "db", "migrate"}, io.Discard, io.Discard); err == nil {
+		t.Error("Expected db migrate to refuse -api")
+	}
+	if err := run([]string{"-api", "http://localhost:1", "db", "status"}, io.Discard, io.Discard); err == nil {
+		t.Error("Expected db status to refuse -api")
+	}
+	if err := run([]string{"-api", "http://localhost:1", "db", "restore", "-from", backupFile}, io.Discard, io.Discard); err == nil {
+		t.Error("Expected db restore to refuse -api")
+	}
+}
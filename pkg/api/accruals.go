@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getAccrualsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -defaultHistoryDays)
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+
+	accruals, err := s.ledger.GetAccruals(r.Context(), loanID, from, to)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accruals)
+}
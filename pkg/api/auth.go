@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/mcclellann/fredLoan/pkg/oidc"
+)
+
+// role is a caller's level of access. Each role includes the permissions of the roles below it.
+type role int
+
+const (
+	roleNone role = iota
+	roleReadOnly
+	roleServicer
+	roleAdmin
+)
+
+// roleNames are the role values accepted in the token's roles claim.
+var roleNames = map[string]role{
+	"read-only": roleReadOnly,
+	"servicer":  roleServicer,
+	"admin":     roleAdmin,
+}
+
+func (r role) String() string {
+	for name, value := range roleNames {
+		if value == r {
+			return name
+		}
+	}
+	return "none"
+}
+
+// routeRoles overrides the default role required for a route, keyed "METHOD /path/template".
+// By default reads need read-only, changes need servicer, and /admin routes need admin. Routes
+// mapped to roleNone are public: they carry their own credentials (payment link tokens and
+// signed processor webhooks) or only describe the API.
+var routeRoles = map[string]role{
+	"DELETE /loans/{id}":                          roleAdmin,
+	"POST /loans/{id}/charge-off":                 roleAdmin,
+	"DELETE /customers/{customer_key}":            roleAdmin,
+	"POST /products":                              roleAdmin,
+	"PUT /products/{code}":                        roleAdmin,
+	"GET /webhooks/subscriptions":                 roleAdmin,
+	"POST /webhooks/subscriptions":                roleAdmin,
+	"DELETE /webhooks/subscriptions/{id}":         roleAdmin,
+	"GET /webhooks/subscriptions/{id}/deliveries": roleAdmin,
+	"POST /graphql":                               roleReadOnly, // Queries only
+	"GET /payment-links/{token}":                  roleNone,
+	"POST /webhooks/payment-links":                roleNone,
+	"GET /openapi.json":                           roleNone,
+	"GET /docs":                                   roleNone,
+}
+
+// requiredRole returns the role a request to the route needs.
+func requiredRole(method string, template string) role {
+	if required, ok := routeRoles[method+" "+template]; ok {
+		return required
+	}
+	switch {
+	case strings.HasPrefix(template, "/admin/"):
+		return roleAdmin
+	case method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions:
+		return roleReadOnly
+	default:
+		return roleServicer
+	}
+}
+
+// TokenVerifier validates a bearer token and returns its claims. *oidc.Verifier is one.
+type TokenVerifier interface {
+	Verify(token string) (*oidc.Claims, error)
+}
+
+type principalKey struct{}
+
+// principal is the authenticated caller of a request.
+type principal struct {
+	Subject string
+	Role    role
+}
+
+// principalFromContext returns the caller authenticated by the authenticate middleware, if any.
+func principalFromContext(ctx context.Context) (principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(principal)
+	return p, ok
+}
+
+// authenticate requires a valid bearer token carrying a role that the route permits. It lets
+// every request through when no token verifier is configured.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tokenVerifier == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := routeTemplate(r)
+		required := requiredRole(r.Method, route)
+		if required == roleNone {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer`)
+			writeError(w, "Bearer token required", http.StatusUnauthorized)
+			return
+		}
+		claims, err := s.tokenVerifier.Verify(strings.TrimSpace(token))
+		if err != nil {
+			slog.Warn("Rejected bearer token", "method", r.Method, "route", route, "err", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, "Invalid bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		granted := roleNone
+		for _, name := range claims.Roles {
+			granted = max(granted, roleNames[name])
+		}
+		if granted < required {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope"`)
+			writeError(w, "This operation requires the "+required.String()+" role", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, principal{Subject: claims.Subject, Role: granted})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/oidc"
+)
+
+// fakeVerifier accepts tokens of the form "subject:role1,role2".
+type fakeVerifier struct{}
+
+func (fakeVerifier) Verify(token string) (*oidc.Claims, error) {
+	subject, roles, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid token: malformed")
+	}
+	return &oidc.Claims{Subject: subject, Roles: strings.Split(roles, ",")}, nil
+}
+
+func TestAuthenticate_RolesGuardRoutes(t *testing.T) {
+	server := &Server{tokenVerifier: fakeVerifier{}}
+
+	var caller principal
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		caller, _ = principalFromContext(r.Context())
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}", handler).Methods("GET", "DELETE")
+	router.HandleFunc("/loans/{id}/payments", handler).Methods("POST")
+	router.HandleFunc("/admin/usage", handler).Methods("GET")
+	router.HandleFunc("/payment-links/{token}", handler).Methods("GET")
+	router.Use(server.authenticate)
+
+	tests := []struct {
+		method, path, token string
+		want                int
+	}{
+		{"GET", "/loans/1", "", http.StatusUnauthorized},
+		{"GET", "/loans/1", "bad-token", http.StatusUnauthorized},
+		{"GET", "/loans/1", "ann:read-only", http.StatusOK},
+		{"GET", "/loans/1", "ann:unknown", http.StatusForbidden},
+		{"POST", "/loans/1/payments", "ann:read-only", http.StatusForbidden},
+		{"POST", "/loans/1/payments", "sam:servicer", http.StatusOK},
+		{"DELETE", "/loans/1", "sam:servicer", http.StatusForbidden},
+		{"DELETE", "/loans/1", "ada:read-only,admin", http.StatusOK},
+		{"GET", "/admin/usage", "sam:servicer", http.StatusForbidden},
+		{"GET", "/admin/usage", "ada:admin", http.StatusOK},
+		{"GET", "/payment-links/abc", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s with %q: expected status %d, got %d", tt.method, tt.path, tt.token, tt.want, rr.Code)
+		}
+		if rr.Code == http.StatusUnauthorized && rr.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s with %q: expected a WWW-Authenticate challenge", tt.method, tt.path, tt.token)
+		}
+	}
+
+	caller = principal{}
+	req := httptest.NewRequest("DELETE", "/loans/1", nil)
+	req.Header.Set("Authorization", "Bearer ada:read-only,admin")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if caller.Subject != "ada" || caller.Role != roleAdmin {
+		t.Errorf("Expected the handler to see ada as admin, got %+v", caller)
+	}
+
+	// Without a verifier every request is let through
+	server.tokenVerifier = nil
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/loans/1", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected unauthenticated access when auth is disabled, got %d", rr.Code)
+	}
+}
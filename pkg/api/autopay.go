@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func (s *Server) enrollAutopayHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		AmountType      models.AutopayAmountType `json:"amount_type"`
+		Amount          decimal.Decimal          `json:"amount"`
+		DayOfMonth      int                      `json:"day_of_month"`
+		PaymentMethodID uuid.UUID                `json:"payment_method_id"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	enrollment := &models.AutopayEnrollment{
+		LoanID:          loanID,
+		AmountType:      req.AmountType,
+		Amount:          req.Amount,
+		DayOfMonth:      req.DayOfMonth,
+		PaymentMethodID: req.PaymentMethodID,
+	}
+	if err := s.ledger.EnrollAutopay(r.Context(), enrollment); err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (s *Server) getAutopayHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	enrollment, err := s.ledger.GetAutopayEnrollment(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (s *Server) cancelAutopayHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.ledger.CancelAutopay(r.Context(), loanID); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sqliteContentType is the media type of SQLite database files.
+const sqliteContentType = "application/vnd.sqlite3"
+
+// backupHandler downloads a consistent snapshot of the database, taken while the server
+// keeps serving. The snapshot is written to a temporary file first, so a client reading it
+// slowly does not hold the database's read transaction open.
+func (s *Server) backupHandler(w http.ResponseWriter, r *http.Request) {
+	if s.backuper == nil {
+		writeError(w, "The database cannot be backed up online; use its own backup tools", http.StatusServiceUnavailable)
+		return
+	}
+
+	dir, err := os.MkdirTemp("", "fredloan-backup-")
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fredloan.db")
+	if err := s.backuper.Backup(r.Context(), path); err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	filename := "fredloan-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+	w.Header().Set("Content-Type", sqliteContentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	withoutWriteDeadline(w)
+	if _, err := io.Copy(w, file); err != nil {
+		slog.Error("Backup download failed", "path", r.URL.Path, "err", err)
+	}
+}
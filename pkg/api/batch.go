@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+)
+
+// runJob runs one step of a batch as a child span of ctx and records it in the job history.
+// The step is given the span's context, so the store calls it makes appear under the job.
+// Steps that count the loans they touch return their run; for the rest, runJob records the
+// times and any error. Failures are logged, and at debug level so is how long the step took.
+func (s *Server) runJob(ctx context.Context, job models.JobName, step func(ctx context.Context) (*models.JobRun, error)) {
+	ctx, span := s.tracer.Start(ctx, "batch."+string(job), tracing.SpanKindInternal)
+	defer span.End()
+
+	start := time.Now()
+	run, err := step(ctx)
+	if run == nil {
+		run = &models.JobRun{Job: job, Date: start.UTC().Truncate(24 * time.Hour), StartedAt: start}
+	}
+	run.Trigger = models.JobTriggerSchedule
+	run.FinishedAt = time.Now()
+	if err != nil {
+		run.Errors = append(run.Errors, err.Error())
+		span.SetError(err)
+		slog.Error("Batch job failed", "job", job, "err", err)
+	}
+	if run.Failed > 0 {
+		slog.Error("Batch job failed for some loans", "job", job, "failed", run.Failed, "processed", run.Processed)
+	}
+	if err := s.ledger.RecordJobRun(ctx, run); err != nil {
+		slog.Error("Failed to record batch job run", "job", job, "err", err)
+	}
+	slog.Debug("Batch job finished", "job", job, "duration", run.FinishedAt.Sub(start))
+}
+
+// RunDailyBatch runs the daily and monthly jobs in order, traced as one batch.daily span with
+// a child per job. indexSeries are the index rates to refresh and bureauExportDir is where
+// monthly bureau files are written.
+func (s *Server) RunDailyBatch(indexSeries []string, bureauExportDir string) {
+	s.batchMu.Lock()
+	defer s.batchMu.Unlock()
+
+	ctx, span := s.tracer.Start(context.Background(), "batch.daily", tracing.SpanKindInternal)
+	defer span.End()
+
+	if s.indexSource != nil {
+		s.runJob(ctx, models.JobRefreshIndexRates, func(ctx context.Context) (*models.JobRun, error) {
+			slog.Info("Refreshing index rates...")
+			s.ledger.RefreshIndexRates(ctx, s.indexSource, indexSeries)
+			slog.Info("Index rate refresh complete.")
+			return nil, nil
+		})
+	}
+
+	s.runJob(ctx, models.JobDailyInterest, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running daily interest calculation...")
+		run, err := s.ledger.RunDailyInterest(ctx, ledger.JobScope{})
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Daily interest calculation complete.", "processed", run.Processed, "skipped", run.Skipped, "failed", run.Failed)
+		return run, nil
+	})
+
+	s.runJob(ctx, models.JobPostChargeOffInterest, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running post-charge-off interest calculation...")
+		s.ledger.CalculatePostChargeOffInterest(ctx)
+		slog.Info("Post-charge-off interest calculation complete.")
+		return nil, nil
+	})
+
+	s.runJob(ctx, models.JobMonthlyInterest, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running monthly interest application...")
+		run, err := s.ledger.RunMonthlyInterest(ctx, ledger.JobScope{})
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Monthly interest application complete.", "processed", run.Processed, "skipped", run.Skipped, "failed", run.Failed)
+		return run, nil
+	})
+
+	s.runJob(ctx, models.JobStatements, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running statement generation...")
+		s.ledger.GenerateStatements(ctx)
+		slog.Info("Statement generation complete.")
+		return nil, nil
+	})
+
+	s.runJob(ctx, models.JobAutopay, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running autopay...")
+		s.ledger.ProcessAutopay(ctx)
+		slog.Info("Autopay complete.")
+		return nil, nil
+	})
+
+	s.runJob(ctx, models.JobDelinquency, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running delinquency aging...")
+		s.ledger.UpdateDelinquency(ctx)
+		slog.Info("Delinquency aging complete.")
+		return nil, nil
+	})
+
+	s.runJob(ctx, models.JobAutoChargeOff, func(ctx context.Context) (*models.JobRun, error) {
+		slog.Info("Running automatic charge-off...")
+		s.ledger.AutoChargeOff(ctx)
+		slog.Info("Automatic charge-off complete.")
+		return nil, nil
+	})
+
+	s.runJob(ctx, models.JobPortfolioSnapshot, func(ctx context.Context) (*models.JobRun, error) {
+		_, err := s.ledger.TakePortfolioSnapshot(ctx)
+		return nil, err
+	})
+
+	s.runJob(ctx, models.JobBureauExport, func(ctx context.Context) (*models.JobRun, error) {
+		exported, err := s.exportBureauFile(ctx, bureauExportDir)
+		if exported != "" {
+			slog.Info("Exported bureau file.", "path", exported)
+		}
+		return nil, err
+	})
+
+	s.runJob(ctx, models.JobArchive, func(ctx context.Context) (*models.JobRun, error) {
+		start := time.Now()
+		archived, err := s.ledger.ArchiveClosedLoans(ctx, defaultArchiveAfterMonths)
+		if archived > 0 {
+			slog.Info("Archived closed loans.", "loans", archived)
+		}
+		return &models.JobRun{Job: models.JobArchive, Date: start.UTC().Truncate(24 * time.Hour), StartedAt: start, Processed: archived}, err
+	})
+
+	if s.archiveUploader != nil {
+		s.runJob(ctx, models.JobArchiveExport, func(ctx context.Context) (*models.JobRun, error) {
+			start := time.Now()
+			run := &models.JobRun{Job: models.JobArchiveExport, Date: start.UTC().Truncate(24 * time.Hour), StartedAt: start}
+			export, err := s.ledger.ExportArchive(ctx, s.archiveUploader, s.archiveExportYears, s.archivePrune)
+			if export != nil && export.Loans > 0 {
+				slog.Info("Exported archived loans.", "loans", export.Loans, "objects", len(export.Objects), "pruned", export.Pruned)
+				run.Processed = export.Loans
+			}
+			return run, err
+		})
+	}
+}
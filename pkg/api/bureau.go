@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *Server) bureauExportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	period := query.Get("period")
+	if period == "" {
+		// Default to the last complete month
+		period = time.Now().UTC().AddDate(0, -1, 0).Format("2006-01")
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.ledger.ExportBureauFile(r.Context(), &buf, s.bureauFormat, period, query.Get("product")); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=us-ascii")
+	w.Write(buf.Bytes())
+}
+
+// exportBureauFile writes the previous month's bureau file to dir, once per month. It is
+// called from the daily batch and does nothing when the file already exists.
+func (s *Server) exportBureauFile(ctx context.Context, dir string) (string, error) {
+	period := time.Now().UTC().AddDate(0, -1, 0).Format("2006-01")
+	path := filepath.Join(dir, fmt.Sprintf("metro2_%s.txt", period))
+	if _, err := os.Stat(path); err == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	count, err := s.ledger.ExportBureauFile(ctx, &buf, s.bureauFormat, period, "")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create bureau export directory: %w", err)
+	}
+	// Write to a temporary file first so that a crash never leaves a partial file behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o640); err != nil {
+		return "", fmt.Errorf("failed to write bureau file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to write bureau file: %w", err)
+	}
+	return fmt.Sprintf("%s (%d records)", path, count), nil
+}
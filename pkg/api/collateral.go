@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// collateralRequest is the body accepted when adding or updating collateral.
+type collateralRequest struct {
+	Type          models.CollateralType `json:"type"`
+	Description   string                `json:"description"`
+	Valuation     decimal.Decimal       `json:"valuation"`
+	ValuationDate string                `json:"valuation_date"` // YYYY-MM-DD, defaults to today
+}
+
+// decodeCollateralRequest reads a collateral request and parses its valuation date.
+func decodeCollateralRequest(w http.ResponseWriter, r *http.Request) (*collateralRequest, time.Time, bool) {
+	var req collateralRequest
+	if !decodeJSON(w, r, &req) {
+		return nil, time.Time{}, false
+	}
+	var valuationDate time.Time
+	if req.ValuationDate != "" {
+		var err error
+		if valuationDate, err = time.Parse("2006-01-02", req.ValuationDate); err != nil {
+			writeError(w, "Invalid valuation_date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return nil, time.Time{}, false
+		}
+	}
+	return &req, valuationDate, true
+}
+
+// parseCollateralVars parses the loan and collateral IDs from the request path.
+func parseCollateralVars(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	vars := mux.Vars(r)
+	loanID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	collateralID, err := uuid.Parse(vars["collateralID"])
+	if err != nil {
+		writeError(w, "Invalid collateral ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return loanID, collateralID, true
+}
+
+// writeCollateralError maps collateral errors to HTTP statuses.
+func (s *Server) addCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	req, valuationDate, ok := decodeCollateralRequest(w, r)
+	if !ok {
+		return
+	}
+
+	collateral, err := s.ledger.AddCollateral(r.Context(), loanID, req.Type, req.Description, req.Valuation, valuationDate)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(collateral)
+}
+
+func (s *Server) getCollateralForLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	collateral, err := s.ledger.GetCollateralForLoan(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+func (s *Server) getCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, collateralID, ok := parseCollateralVars(w, r)
+	if !ok {
+		return
+	}
+
+	collateral, err := s.ledger.GetCollateral(r.Context(), loanID, collateralID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+func (s *Server) updateCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, collateralID, ok := parseCollateralVars(w, r)
+	if !ok {
+		return
+	}
+
+	req, valuationDate, ok := decodeCollateralRequest(w, r)
+	if !ok {
+		return
+	}
+
+	collateral, err := s.ledger.UpdateCollateral(r.Context(), loanID, collateralID, req.Type, req.Description, req.Valuation, valuationDate)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collateral)
+}
+
+func (s *Server) deleteCollateralHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, collateralID, ok := parseCollateralVars(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.ledger.DeleteCollateral(r.Context(), loanID, collateralID); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the smallest response worth compressing; below it, gzip's framing costs
+// more than it saves.
+const minCompressSize = 1024
+
+var gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+
+// compressResponses gzips response bodies for clients that accept it, once a response has
+// grown past minCompressSize or is flushed. Event streams, responses without a body and
+// responses the handler encoded itself are sent as they are. Brotli is not offered, as the
+// standard library has no encoder for it.
+func compressResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows a gzip response, naming it or
+// "*" with a non-zero quality.
+func acceptsGzip(header string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		accepted := true
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+				accepted = false
+			}
+		}
+		switch coding {
+		case "gzip", "x-gzip":
+			return accepted
+		case "*":
+			wildcard = accepted
+		}
+	}
+	return wildcard
+}
+
+// compressWriter holds back the start of a response until it knows whether to compress it:
+// when the body passes minCompressSize, is flushed, or ends.
+type compressWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer // Set once the response is being compressed
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status) // Informational responses pass straight through
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if !w.compressible() {
+		w.start(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= minCompressSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what has been written so far, compressing it if the response can be, since a
+// flushed response is one that streams.
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.start(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to change its deadlines.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the response's status and headers allow compressing its body.
+func (w *compressWriter) compressible() bool {
+	if w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	return !strings.HasPrefix(header.Get("Content-Type"), "text/event-stream")
+}
+
+// start sends the status line and headers, compressing the body from here on if asked to and
+// the response allows it, then whatever was held back.
+func (w *compressWriter) start(compress bool) error {
+	w.decided = true
+	if compress && w.compressible() {
+		header := w.Header()
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		// The compressed bytes differ from the identity representation's, so its tag is weak
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close ends the response once the handler has returned, sending a short body as it is.
+func (w *compressWriter) close() {
+	if !w.decided {
+		if w.status == 0 {
+			return // Nothing was written; the server sends its default response
+		}
+		w.start(false)
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(nil)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompressResponses(t *testing.T) {
+	large := strings.Repeat(`{"id": "loan", "balance": "1000.00"},`, 100)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"3"`)
+		io.WriteString(w, large)
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"ok": true}`)
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "id,amount\n")
+		http.NewResponseController(w).Flush()
+		io.WriteString(w, "1,100\n")
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, large)
+		w.(http.Flusher).Flush()
+	})
+	handler := compressResponses(mux)
+
+	get := func(path string, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	gunzip := func(rr *httptest.ResponseRecorder) string {
+		zr, err := gzip.NewReader(rr.Body)
+		if err != nil {
+			t.Fatalf("Expected a gzip body: %v", err)
+		}
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("Failed to decompress: %v", err)
+		}
+		return string(body)
+	}
+
+	rr := get("/large", "br, gzip;q=0.8")
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("Expected a gzip response varying on Accept-Encoding, got %+v", rr.Header())
+	}
+	if rr.Body.Len() >= len(large) || gunzip(rr) != large {
+		t.Errorf("Expected the body compressed from %d bytes, got %d", len(large), rr.Body.Len())
+	}
+	if rr.Header().Get("ETag") != `W/"3"` {
+		t.Errorf("Expected a weak ETag, got %q", rr.Header().Get("ETag"))
+	}
+
+	for name, acceptEncoding := range map[string]string{
+		"no Accept-Encoding": "",
+		"gzip refused":       "gzip;q=0, deflate",
+		"identity only":      "identity",
+	} {
+		rr := get("/large", acceptEncoding)
+		if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != large {
+			t.Errorf("%s: expected an uncompressed body, got %+v", name, rr.Header())
+		}
+	}
+	if rr := get("/large", "*"); rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("Expected * to accept gzip, got %+v", rr.Header())
+	}
+
+	rr = get("/small", "gzip")
+	if rr.Code != http.StatusCreated || rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != `{"ok": true}` {
+		t.Errorf("Expected a small response sent as it is, got %d %+v", rr.Code, rr.Header())
+	}
+
+	rr = get("/stream", "gzip")
+	if rr.Header().Get("Content-Encoding") != "gzip" || gunzip(rr) != "id,amount\n1,100\n" {
+		t.Errorf("Expected a flushed response compressed, got %+v", rr.Header())
+	}
+	if !rr.Flushed {
+		t.Error("Expected the flush to reach the client")
+	}
+
+	rr = get("/events", "gzip")
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != large {
+		t.Errorf("Expected an event stream sent as it is, got %+v", rr.Header())
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/config"
+)
+
+// corsPolicy lets browser consoles on other origins call the API. It wraps the router rather
+// than being router middleware because preflight OPTIONS requests match no route. Credentials
+// (cookies) are never allowed; callers authenticate with bearer tokens or API keys, which are
+// allowed request headers.
+type corsPolicy struct {
+	router *mux.Router // Checked so preflights succeed only for methods a route supports
+
+	anyOrigin bool
+	origins   map[string]bool // Lower-cased scheme://host[:port]
+	suffixes  []string        // From *. wildcards: scheme plus ".example.com"
+
+	methods map[string]bool
+	headers map[string]bool // Lower-cased
+
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+// newCORSPolicy builds the policy from the configuration, or returns nil when no origins are
+// allowed.
+func newCORSPolicy(cfg config.Config, router *mux.Router) *corsPolicy {
+	if len(cfg.CORSAllowedOrigins) == 0 {
+		return nil
+	}
+
+	p := &corsPolicy{
+		router:        router,
+		origins:       make(map[string]bool),
+		methods:       make(map[string]bool),
+		headers:       make(map[string]bool),
+		allowMethods:  strings.Join(cfg.CORSAllowedMethods, ", "),
+		allowHeaders:  strings.Join(cfg.CORSAllowedHeaders, ", "),
+		exposeHeaders: strings.Join(cfg.CORSExposedHeaders, ", "),
+		maxAge:        strconv.Itoa(int(cfg.CORSMaxAge.Seconds())),
+	}
+	for _, origin := range cfg.CORSAllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			p.suffixes = append(p.suffixes, strings.Replace(origin, "://*.", "://.", 1))
+		default:
+			p.origins[origin] = true
+		}
+	}
+	for _, method := range cfg.CORSAllowedMethods {
+		p.methods[method] = true
+	}
+	for _, header := range cfg.CORSAllowedHeaders {
+		p.headers[strings.ToLower(header)] = true
+	}
+	return p
+}
+
+func (p *corsPolicy) allowsOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, suffix := range p.suffixes {
+		// https://.example.com matches https://console.example.com but not https://example.com
+		scheme, domain, _ := strings.Cut(suffix, "://")
+		if rest, ok := strings.CutPrefix(origin, scheme+"://"); ok && strings.HasSuffix(rest, domain) && len(rest) > len(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every header in a preflight's comma-separated
+// Access-Control-Request-Headers is allowed.
+func (p *corsPolicy) allowsHeaders(requested string) bool {
+	for _, header := range strings.Split(requested, ",") {
+		if header = strings.TrimSpace(header); header != "" && !p.headers[strings.ToLower(header)] {
+			return false
+		}
+	}
+	return true
+}
+
+// routeAllows reports whether a route serves the path with the method.
+func (p *corsPolicy) routeAllows(r *http.Request, method string) bool {
+	probe := r.Clone(r.Context())
+	probe.Method = method
+	var match mux.RouteMatch
+	return p.router.Match(probe, &match)
+}
+
+// handler answers preflight requests and adds CORS headers to responses for allowed origins.
+// Requests from other origins are served without them, so browsers withhold the response.
+func (p *corsPolicy) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		requestedMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method == http.MethodOptions && requestedMethod != "" {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			switch {
+			case !p.allowsOrigin(origin):
+				writeError(w, "Origin "+origin+" is not allowed", http.StatusForbidden)
+			case !p.methods[requestedMethod] || !p.routeAllows(r, requestedMethod):
+				writeError(w, "Method "+requestedMethod+" is not allowed for "+r.URL.Path, http.StatusForbidden)
+			case !p.allowsHeaders(r.Header.Get("Access-Control-Request-Headers")):
+				writeError(w, "Request headers are not allowed: "+r.Header.Get("Access-Control-Request-Headers"), http.StatusForbidden)
+			default:
+				p.setAllowOrigin(w, origin)
+				w.Header().Set("Access-Control-Allow-Methods", p.allowMethods)
+				w.Header().Set("Access-Control-Allow-Headers", p.allowHeaders)
+				w.Header().Set("Access-Control-Max-Age", p.maxAge)
+				w.WriteHeader(http.StatusNoContent)
+			}
+			return
+		}
+
+		if p.allowsOrigin(origin) {
+			p.setAllowOrigin(w, origin)
+			if p.exposeHeaders != "" {
+				w.Header().Set("Access-Control-Expose-Headers", p.exposeHeaders)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *corsPolicy) setAllowOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
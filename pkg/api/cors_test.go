@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/config"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/payments", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"2"`)
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST")
+
+	cfg := config.Default()
+	if newCORSPolicy(cfg, router) != nil {
+		t.Fatal("Expected CORS to be disabled without allowed origins")
+	}
+	cfg.CORSAllowedOrigins = []string{"https://console.example.com", "https://*.lender.example"}
+	handler := newCORSPolicy(cfg, router).handler(router)
+
+	preflight := func(origin, method, headers string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/loans/123/payments", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", method)
+		if headers != "" {
+			req.Header.Set("Access-Control-Request-Headers", headers)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A console posting a payment with a bearer token and an idempotency key
+	rr := preflight("https://console.example.com", "POST", "authorization, content-type, idempotency-key")
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for an allowed preflight, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://console.example.com" ||
+		rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST, PUT, DELETE" ||
+		rr.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("Unexpected preflight headers %+v", rr.Header())
+	}
+
+	if rr := preflight("https://ops.lender.example", "POST", ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected a wildcard subdomain to be allowed, got %d", rr.Code)
+	}
+	for name, rr := range map[string]*httptest.ResponseRecorder{
+		"unknown origin":        preflight("https://evil.example", "POST", ""),
+		"bare wildcard domain":  preflight("https://lender.example", "POST", ""),
+		"unsupported by route":  preflight("https://console.example.com", "DELETE", ""),
+		"not an allowed method": preflight("https://console.example.com", "PATCH", ""),
+		"disallowed header":     preflight("https://console.example.com", "POST", "content-type, x-debug"),
+	} {
+		if rr.Code != http.StatusForbidden || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s: expected 403 without CORS headers, got %d %+v", name, rr.Code, rr.Header())
+		}
+	}
+
+	// The actual request is served with the origin allowed and headers exposed
+	req := httptest.NewRequest("POST", "/loans/123/payments", nil)
+	req.Header.Set("Origin", "https://console.example.com")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Access-Control-Allow-Origin") != "https://console.example.com" ||
+		rr.Header().Get("Access-Control-Expose-Headers") == "" || rr.Header().Get("Vary") != "Origin" {
+		t.Errorf("Unexpected response %d %+v", rr.Code, rr.Header())
+	}
+
+	req.Header.Set("Origin", "https://evil.example")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated || rr.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("Expected other origins to be served without CORS headers, got %d %+v", rr.Code, rr.Header())
+	}
+
+	cfg.CORSAllowedOrigins = []string{"*"}
+	handler = newCORSPolicy(cfg, router).handler(router)
+	if rr := preflight("https://anyone.example", "POST", "content-type"); rr.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected any origin to be allowed, got %+v", rr.Header())
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+func (s *Server) createCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var customer models.Customer
+	if !decodeJSON(w, r, &customer) {
+		return
+	}
+
+	var v validator
+	v.required("customer_key", customer.CustomerKey)
+	if v.write(w) {
+		return
+	}
+
+	if err := s.ledger.CreateCustomer(r.Context(), &customer); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(customer)
+}
+
+func (s *Server) listCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	customers, err := s.ledger.GetAllCustomers(r.Context())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if customers == nil {
+		customers = []*models.Customer{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customers)
+}
+
+func (s *Server) getCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customerKey := mux.Vars(r)["customer_key"]
+
+	customer, err := s.ledger.GetCustomer(r.Context(), customerKey)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+func (s *Server) updateCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customerKey := mux.Vars(r)["customer_key"]
+
+	var customer models.Customer
+	if !decodeJSON(w, r, &customer) {
+		return
+	}
+	customer.CustomerKey = customerKey // The key identifies the customer and cannot change
+
+	if err := s.ledger.UpdateCustomer(r.Context(), &customer); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(customer)
+}
+
+func (s *Server) deleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	customerKey := mux.Vars(r)["customer_key"]
+
+	if err := s.ledger.DeleteCustomer(r.Context(), customerKey); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getCustomerLoansHandler(w http.ResponseWriter, r *http.Request) {
+	customerKey := mux.Vars(r)["customer_key"]
+
+	loans, err := s.ledger.GetCustomerLoans(r.Context(), customerKey)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loans)
+}
+
+func (s *Server) getCustomerSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	customerKey := mux.Vars(r)["customer_key"]
+
+	summary, err := s.ledger.GetCustomerSummary(r.Context(), customerKey)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func (s *Server) drawHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Amount decimal.Decimal `json:"amount"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	tx, err := s.ledger.Draw(r.Context(), loanID, req.Amount)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tx)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// loanETag is the entity tag of a loan representation: its version, quoted.
+func loanETag(loan *models.Loan) string {
+	return fmt.Sprintf(`"%d"`, loan.Version)
+}
+
+// matchesETag reports whether an If-None-Match or If-Match header lists the entity tag. Weak
+// tags compare equal to strong ones, and "*" matches any tag.
+func matchesETag(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setLoanValidators sets the headers a client can make its next read of the loan conditional on:
+// the ETag and, when the loan has an update time, Last-Modified.
+func setLoanValidators(w http.ResponseWriter, loan *models.Loan) {
+	w.Header().Set("ETag", loanETag(loan))
+	if !loan.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", loan.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// loanNotModified reports whether a conditional GET already has the current loan. If-None-Match
+// takes precedence; If-Modified-Since is only consulted without it, and holds when the loan has
+// not changed since that time, to the second. Unparseable dates are ignored.
+func loanNotModified(r *http.Request, loan *models.Loan) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return matchesETag(match, loanETag(loan))
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || loan.UpdatedAt.IsZero() {
+		return false
+	}
+	return !loan.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+// ifMatchVersion reads the loan version a conditional update expects from its If-Match header.
+// A wildcard returns version 0, meaning any version. It writes an error response and returns
+// false if the header is missing or is not a single loan version.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		writeError(w, "If-Match with the loan's ETag is required to update it", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version <= 0 {
+		writeError(w, "Invalid If-Match, expected the loan's ETag", http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// streamKeepAliveInterval is how often an idle event stream sends a comment line, so proxies
+// do not close the connection.
+const streamKeepAliveInterval = 15 * time.Second
+
+// eventStreamHandler pushes new transactions, loan status changes and accruals to the client as
+// Server-Sent Events until it disconnects or the server shuts down. ?loan_id= limits the stream to one loan and
+// ?types= to a comma-separated list of event types.
+func (s *Server) eventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	var loanID uuid.UUID
+	if value := r.URL.Query().Get("loan_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			writeError(w, "Invalid loan ID", http.StatusBadRequest)
+			return
+		}
+		loanID = id
+	}
+	var types map[models.StreamEventType]bool
+	if value := r.URL.Query().Get("types"); value != "" {
+		types = make(map[models.StreamEventType]bool)
+		for _, name := range strings.Split(value, ",") {
+			eventType := models.StreamEventType(strings.TrimSpace(name))
+			switch eventType {
+			case models.StreamTransactionCreated, models.StreamLoanStatusChanged, models.StreamAccrualRecorded:
+			default:
+				writeError(w, fmt.Sprintf("invalid event type %q", eventType), http.StatusBadRequest)
+				return
+			}
+			types[eventType] = true
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := s.ledger.SubscribeEvents()
+	defer unsubscribe()
+
+	withoutWriteDeadline(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.streamsClosed:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event := <-events:
+			if loanID != uuid.Nil && event.LoanID != loanID {
+				continue
+			}
+			if types != nil && !types[event.Type] {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.Error("Error encoding stream event", "type", event.Type, "err", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// CloseEventStreams ends every open event stream, so that shutdown does not wait for clients
+// to disconnect. It must be called at most once, e.g. through http.Server.RegisterOnShutdown.
+func (s *Server) CloseEventStreams() {
+	close(s.streamsClosed)
+}
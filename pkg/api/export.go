@@ -0,0 +1,102 @@
+package api
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// exportLoansHandler serves GET /loans/export, the loans matching a search as CSV.
+func (s *Server) exportLoansHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := loanSearch(w, r)
+	if !ok {
+		return
+	}
+
+	streamDownload(w, r, "loans.csv", csvContentType, func(out io.Writer) error {
+		return s.ledger.ExportLoansCSV(r.Context(), out, search)
+	})
+}
+
+// Content types of the download formats.
+const (
+	csvContentType = "text/csv; charset=utf-8"
+	ofxContentType = "application/x-ofx"
+	qifContentType = "application/qif"
+	pdfContentType = "application/pdf"
+)
+
+// exportTransactionsHandler serves GET /loans/{id}/transactions/export, a loan's
+// transactions as CSV, OFX or QIF, filtered like the transaction listing.
+func (s *Server) exportTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	query, ok := transactionQuery(w, r)
+	if !ok {
+		return
+	}
+
+	filename := "loan-" + loanID.String() + "-transactions"
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		streamDownload(w, r, filename+".csv", csvContentType, func(out io.Writer) error {
+			return s.ledger.ExportTransactionsCSV(r.Context(), out, loanID, query)
+		})
+	case "ofx":
+		streamDownload(w, r, filename+".ofx", ofxContentType, func(out io.Writer) error {
+			return s.ledger.ExportTransactionsOFX(r.Context(), out, loanID, query)
+		})
+	case "qif":
+		streamDownload(w, r, filename+".qif", qifContentType, func(out io.Writer) error {
+			return s.ledger.ExportTransactionsQIF(r.Context(), out, loanID, query)
+		})
+	default:
+		writeError(w, "Invalid format: must be csv, ofx or qif", http.StatusBadRequest)
+	}
+}
+
+// streamDownload sends what export writes as a file download, flushing each chunk to the client
+// as it is written, however long that takes. An error before anything was written is reported
+// as usual; once the download has started, it can only be logged and the response cut short.
+func streamDownload(w http.ResponseWriter, r *http.Request, filename, contentType string, export func(io.Writer) error) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	withoutWriteDeadline(w)
+	out := &flushWriter{w: w, controller: http.NewResponseController(w)}
+	if err := export(out); err != nil {
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			writeLedgerError(w, err)
+			return
+		}
+		slog.Error("Export failed", "path", r.URL.Path, "err", err)
+	}
+}
+
+// flushWriter flushes every write through to the client.
+type flushWriter struct {
+	w          io.Writer
+	controller *http.ResponseController
+	written    bool
+}
+
+func (f *flushWriter) Write(b []byte) (int, error) {
+	f.written = true
+	n, err := f.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	f.controller.Flush() // Best effort: a writer that cannot flush still gets the whole file
+	return n, nil
+}
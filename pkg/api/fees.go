@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func (s *Server) assessFeeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Type       models.TransactionType `json:"type"` // origination_fee or servicing_fee
+		Amount     decimal.Decimal        `json:"amount"`
+		Capitalize bool                   `json:"capitalize"` // Add the fee to the balance instead of billing it
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	tx, err := s.ledger.AssessFee(r.Context(), loanID, req.Type, req.Amount, req.Capitalize)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tx)
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func (s *Server) placeInForbearanceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		StartDate string          `json:"start_date"` // YYYY-MM-DD
+		EndDate   string          `json:"end_date"`   // YYYY-MM-DD, inclusive
+		Rate      decimal.Decimal `json:"rate"`       // APR during the window; zero suspends accrual
+		Reason    string          `json:"reason"`
+		Author    string          `json:"author"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		writeError(w, "Invalid start_date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		writeError(w, "Invalid end_date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	forbearance, err := s.ledger.PlaceInForbearance(r.Context(), loanID, start, end, req.Rate, req.Reason, req.Author)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(forbearance)
+}
+
+func (s *Server) getForbearancesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	forbearances, err := s.ledger.GetForbearances(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forbearances)
+}
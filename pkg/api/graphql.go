@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/graphql"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// graphQLMaxDepth bounds how deeply a GraphQL query may nest, so a query cycling through
+// loan { customer { loans { customer ... } } } cannot fan out without limit.
+const graphQLMaxDepth = 8
+
+// newGraphQLSchema builds the GraphQL schema. Loan, Transaction and Statement have a field for
+// each field of their JSON representation, plus the relations between them, so a client can
+// fetch a loan with its transactions, statements and customer in one query.
+func (s *Server) newGraphQLSchema() *graphql.Schema {
+	loan := graphql.ObjectFromStruct("Loan", models.Loan{})
+	transaction := graphql.ObjectFromStruct("Transaction", models.Transaction{})
+	statement := graphql.ObjectFromStruct("Statement", models.Statement{})
+	summary := graphql.ObjectFromStruct("CustomerSummary", models.CustomerSummary{})
+
+	// A customer is known only by their key, which is the source of the Customer fields
+	customer := &graphql.Object{Name: "Customer", Fields: map[string]*graphql.Field{
+		"customer_key": {Resolve: func(p graphql.ResolveParams) (any, error) {
+			return p.Source, nil
+		}},
+		"loans": {Object: loan, List: true, Resolve: func(p graphql.ResolveParams) (any, error) {
+			return s.ledger.GetCustomerLoans(p.Context, p.Source.(string))
+		}},
+		"summary": {Object: summary, Resolve: func(p graphql.ResolveParams) (any, error) {
+			return s.ledger.GetCustomerSummary(p.Context, p.Source.(string))
+		}},
+	}}
+
+	loan.Fields["transactions"] = &graphql.Field{Object: transaction, List: true, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return s.ledger.GetTransactions(p.Context, p.Source.(*models.Loan).ID)
+	}}
+	loan.Fields["statements"] = &graphql.Field{Object: statement, List: true, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return s.ledger.GetStatements(p.Context, p.Source.(*models.Loan).ID)
+	}}
+	loan.Fields["customer"] = &graphql.Field{Object: customer, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return p.Source.(*models.Loan).CustomerKey, nil
+	}}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"loan": {Object: loan, Resolve: func(p graphql.ResolveParams) (any, error) {
+			id, err := uuid.Parse(p.String("id"))
+			if err != nil {
+				return nil, fmt.Errorf("invalid loan ID")
+			}
+			return s.ledger.GetLoan(p.Context, id)
+		}},
+		"loans": {Object: loan, List: true, Resolve: func(p graphql.ResolveParams) (any, error) {
+			query := models.LoanQuery{
+				Status:      models.LoanStatus(p.String("status")),
+				CustomerKey: p.String("customer_key"),
+				Sort:        models.LoanSort(p.String("sort")),
+			}
+			var err error
+			if query.Limit, err = p.Int("limit"); err != nil {
+				return nil, err
+			}
+			if query.Offset, err = p.Int("offset"); err != nil {
+				return nil, err
+			}
+			page, err := s.ledger.ListLoans(p.Context, query)
+			if err != nil {
+				return nil, err
+			}
+			return page.Loans, nil
+		}},
+		"customer": {Object: customer, Resolve: func(p graphql.ResolveParams) (any, error) {
+			key := p.String("customer_key")
+			if key == "" {
+				return nil, fmt.Errorf("customer_key is required")
+			}
+			return key, nil
+		}},
+	}}
+
+	return &graphql.Schema{Query: query, MaxDepth: graphQLMaxDepth}
+}
+
+// graphQLHandler runs a GraphQL query sent as a JSON body, or in the query string of a GET.
+// Field errors are reported in the response's errors alongside the data that did resolve, so
+// the status is 200 unless the request itself is malformed.
+func (s *Server) graphQLHandler(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeError(w, "Invalid variables: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	} else if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.Query == "" {
+		writeError(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+
+	result := s.graphQL.Execute(r.Context(), req)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// idempotencyKeyHeader lets a client retry a request safely: a request repeated with the same
+// key gets the original response rather than being processed a second time.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayHeader marks a response replayed from an earlier request.
+const idempotentReplayHeader = "Idempotent-Replayed"
+
+// responseCapture passes a response through while keeping a copy of its status and body.
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// idempotent wraps a handler so requests carrying an Idempotency-Key are processed at most
+// once. The response is stored under the key and replayed for retries. Server errors release
+// the key so the retry is processed afresh, unless the request took effect all the same, in
+// which case the error is stored and replayed like any response. A key whose response cannot
+// be stored stays reserved rather than risk processing the request twice. Requests without
+// the header pass straight through.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJSONBodySize))
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256([]byte(r.Method + " " + r.URL.Path + "\n" + string(body)))
+
+		existing, err := s.ledger.ReserveIdempotencyKey(r.Context(), key, hex.EncodeToString(hash[:]))
+		if err != nil {
+			writeLedgerError(w, err)
+			return
+		}
+		if existing != nil {
+			if existing.StatusCode == 0 {
+				if existing.AppliedAt != nil {
+					writeError(w, "A request with this Idempotency-Key was processed but its response was not recorded", http.StatusConflict)
+				} else {
+					writeError(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+				}
+				return
+			}
+			if existing.ContentType != "" {
+				w.Header().Set("Content-Type", existing.ContentType)
+			}
+			w.Header().Set(idempotentReplayHeader, "true")
+			w.WriteHeader(existing.StatusCode)
+			w.Write(existing.Body)
+			return
+		}
+
+		capture := &responseCapture{ResponseWriter: w}
+		next(capture, r.WithContext(ledger.WithIdempotencyKey(r.Context(), key)))
+
+		// The key is settled even if the client has gone or the request timed out
+		ctx := context.WithoutCancel(r.Context())
+		if capture.status == 0 || capture.status >= http.StatusInternalServerError {
+			err := s.ledger.ReleaseIdempotencyKey(ctx, key)
+			if err == nil {
+				return
+			}
+			if !errors.Is(err, models.ErrIdempotencyKeyApplied) {
+				slog.Error("Error releasing idempotency key", "key", key, "err", err)
+				return
+			}
+		}
+		if capture.status == 0 {
+			capture.status = http.StatusInternalServerError
+		}
+		if err := s.ledger.CompleteIdempotencyKey(ctx, key, capture.status, w.Header().Get("Content-Type"), capture.body.Bytes()); err != nil {
+			// The request may have taken effect, so the key stays reserved: retries are refused
+			// rather than processed again
+			slog.Error("Error recording response for idempotency key", "key", key, "err", err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// indexRateResponse reports a published index rate and how many loans it repriced.
+type indexRateResponse struct {
+	Rate     *models.IndexRate `json:"rate"`
+	Repriced int               `json:"repriced"`
+}
+
+func (s *Server) listIndexRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates, err := s.ledger.GetIndexRates(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+func (s *Server) publishIndexRateHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Rate            decimal.Decimal `json:"rate"`
+		ObservationDate string          `json:"observation_date"` // YYYY-MM-DD, defaults to today
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	observed := time.Now()
+	if req.ObservationDate != "" {
+		var err error
+		observed, err = time.Parse("2006-01-02", req.ObservationDate)
+		if err != nil {
+			writeError(w, "Invalid observation_date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rate, repriced, err := s.ledger.PublishIndexRate(r.Context(), mux.Vars(r)["code"], req.Rate, observed, ledger.IndexSourceManual)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(indexRateResponse{Rate: rate, Repriced: repriced})
+}
+
+func (s *Server) refreshIndexRateHandler(w http.ResponseWriter, r *http.Request) {
+	if s.indexSource == nil {
+		writeError(w, "No index rate feed is configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	rate, repriced, err := s.ledger.RefreshIndexRate(r.Context(), s.indexSource, mux.Vars(r)["code"])
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(indexRateResponse{Rate: rate, Repriced: repriced})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// listInterestIntentsHandler shows which loans the monthly interest job touched in a
+// statement cycle, for post-mortems. Without a cycle, pending intents from any cycle are
+// listed when status=pending, otherwise the current cycle is shown.
+func (s *Server) listInterestIntentsHandler(w http.ResponseWriter, r *http.Request) {
+	cycle := r.URL.Query().Get("cycle")
+	status := models.IntentStatus(r.URL.Query().Get("status"))
+
+	if cycle != "" {
+		if _, err := time.Parse("2006-01", cycle); err != nil {
+			writeError(w, "Invalid cycle, expected YYYY-MM", http.StatusBadRequest)
+			return
+		}
+	} else if status != models.IntentPending {
+		cycle = time.Now().Format("2006-01")
+	}
+
+	switch status {
+	case "", models.IntentPending, models.IntentCompleted:
+	default:
+		writeError(w, "Invalid status, expected pending or completed", http.StatusBadRequest)
+		return
+	}
+
+	intents, err := s.ledger.GetInterestIntents(r.Context(), cycle, status)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(intents)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// defaultJobRunLimit is how many recent job runs are listed when no limit is given.
+const defaultJobRunLimit = 50
+
+// jobScope reads the optional loan_id and date query parameters of an on-demand job run,
+// writing the error response itself when they are invalid.
+func jobScope(w http.ResponseWriter, r *http.Request) (ledger.JobScope, bool) {
+	var scope ledger.JobScope
+	query := r.URL.Query()
+	if v := query.Get("loan_id"); v != "" {
+		loanID, err := uuid.Parse(v)
+		if err != nil {
+			writeError(w, "Invalid loan ID", http.StatusBadRequest)
+			return scope, false
+		}
+		scope.LoanID = loanID
+	}
+	if v := query.Get("date"); v != "" {
+		date, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return scope, false
+		}
+		if date.After(time.Now().UTC()) {
+			writeError(w, "Date cannot be in the future", http.StatusBadRequest)
+			return scope, false
+		}
+		scope.Date = date
+	}
+	return scope, true
+}
+
+// runJobHandler serves POST /admin/jobs/<job>, running the job at once rather than waiting for
+// the next batch. Runs are serialized with the scheduled batch and recorded in the job history.
+func (s *Server) runJobHandler(run func(context.Context, ledger.JobScope) (*models.JobRun, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scope, ok := jobScope(w, r)
+		if !ok {
+			return
+		}
+
+		s.batchMu.Lock()
+		result, err := run(r.Context(), scope)
+		s.batchMu.Unlock()
+		if err != nil {
+			writeLedgerError(w, err)
+			return
+		}
+		result.Trigger = models.JobTriggerManual
+		if err := s.ledger.RecordJobRun(r.Context(), result); err != nil {
+			slog.Error("Failed to record job run", "job", result.Job, "err", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// listJobRunsHandler serves GET /admin/jobs, the most recent batch job runs, newest first.
+// Filter with job=daily_interest and page size with limit.
+func (s *Server) listJobRunsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := defaultJobRunLimit
+	if v := query.Get("limit"); v != "" {
+		var err error
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	runs, err := s.ledger.GetJobRuns(r.Context(), models.JobName(query.Get("job")), limit)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
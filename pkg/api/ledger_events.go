@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getLedgerEventsHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	events, err := s.ledger.GetLedgerEvents(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+func (s *Server) getProjectionHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	projection, err := s.ledger.ProjectLoan(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projection)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxJSONBodySize caps the size of a JSON request body.
+const maxJSONBodySize = 1 << 20
+
+// decodeJSON decodes the request's JSON body into v, writing the error response itself if the
+// body is too large or malformed.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodySize)
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeBodyError(w, err)
+		return false
+	}
+	return true
+}
+
+// writeBodyError writes the response for an error reading a request body: 413 if it was over
+// its size limit, 400 otherwise.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	writeError(w, err.Error(), http.StatusBadRequest)
+}
+
+// untimedRoutes stream their responses or work through the whole portfolio, so they are
+// exempt from the request timeout. They are still ended by the client disconnecting or by shutdown.
+var untimedRoutes = map[string]bool{
+	"/events/stream":                  true,
+	"/loans/export":                   true,
+	"/loans/{id}/transactions/export": true,
+	"/reports/bureau":                 true,
+	"/payments/import":                true,
+	"/admin/archive":                  true,
+	"/admin/archive/export":           true,
+	"/admin/backup":                   true,
+	"/admin/jobs/daily-interest":      true,
+	"/admin/jobs/monthly-interest":    true,
+}
+
+// requestTimeout gives each request a deadline on its context and, if the handler has not
+// finished when it passes, answers 503 so a hung query or a slow handler cannot hold the
+// client indefinitely. The response is buffered until the handler returns, so routes that
+// stream are listed in untimedRoutes instead.
+func requestTimeout(timeout time.Duration) mux.MiddlewareFunc {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusServiceUnavailable),
+		Status: http.StatusServiceUnavailable,
+		Detail: fmt.Sprintf("The request did not complete within %s", timeout),
+		Code:   codeServiceUnavailable,
+	})
+
+	return func(next http.Handler) http.Handler {
+		timed := http.TimeoutHandler(next, timeout, body.String())
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if route := mux.CurrentRoute(r); route != nil {
+				if template, err := route.GetPathTemplate(); err == nil && untimedRoutes[template] {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			timed.ServeHTTP(&timeoutProblemWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// timeoutProblemWriter labels http.TimeoutHandler's 503 body, which carries no content type
+// of its own, as a problem response.
+type timeoutProblemWriter struct {
+	http.ResponseWriter
+}
+
+func (w *timeoutProblemWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", problemContentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *timeoutProblemWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withoutWriteDeadline lifts the server's write timeout from a response that streams for as
+// long as it takes. Writers that cannot change their deadline are left as they are.
+func withoutWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
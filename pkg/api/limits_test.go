@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func TestRequestBodyLimit(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/payments", server.paymentsHandler()).Methods("POST")
+
+	body := `{"customer_key": "` + strings.Repeat("x", maxJSONBodySize) + `"}`
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", strings.NewReader(body)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status 413, got %d", rr.Code)
+	}
+	var p problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if p.Code != codeTooLarge {
+		t.Errorf("Expected code %s, got %+v", codeTooLarge, p)
+	}
+
+	// Idempotent requests read the body before decoding it
+	req := httptest.NewRequest("POST", "/loans/"+uuid.NewString()+"/payments", bytes.NewBufferString(body))
+	req.Header.Set("Idempotency-Key", "big-1")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 for an idempotent request, got %d", rr.Code)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok && mux.CurrentRoute(r).GetName() == "timed" {
+			t.Error("Expected the request context to have a deadline")
+		}
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/export", slow).Methods("GET")
+	router.HandleFunc("/loans/{id}", slow).Methods("GET").Name("timed")
+	router.Use(requestTimeout(20 * time.Millisecond))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/123", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("Expected a problem response, got %q", ct)
+	}
+	var p problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil || p.Code != codeServiceUnavailable || !strings.Contains(p.Detail, "20ms") {
+		t.Errorf("Unexpected problem %+v (%v)", p, err)
+	}
+
+	// Streaming routes are exempt
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/export", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the export to run to completion, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/metrics"
+)
+
+// httpMetrics counts and times routed requests. Routes are labelled by their path template so
+// loan IDs do not multiply the series.
+type httpMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+}
+
+func newHTTPMetrics() *httpMetrics {
+	return &httpMetrics{
+		requests: metrics.NewCounterVec("http_requests_total", "HTTP requests served, by method, route and status code.", "method", "route", "status"),
+		duration: metrics.NewHistogramVec("http_request_duration_seconds", "Time taken to serve HTTP requests, by method and route.", nil, "method", "route"),
+	}
+}
+
+// SetMetricsRegistry serves the registry at /metrics and registers the HTTP and runtime
+// collectors on it. Operators wanting their own collectors register them on the same registry.
+func (s *Server) SetMetricsRegistry(registry *metrics.Registry) {
+	registry.Register(
+		s.httpMetrics.requests,
+		s.httpMetrics.duration,
+		metrics.NewGaugeFunc("go_goroutines", "Number of goroutines that currently exist.", func() float64 {
+			return float64(runtime.NumGoroutine())
+		}),
+	)
+	s.metrics = registry
+}
+
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	s.metrics.Handler().ServeHTTP(w, r)
+}
+
+// statusRecorder remembers the status code written through it. It passes Flush through so
+// streaming handlers keep working when instrumented.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to change its deadlines.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// middleware records the count, status and latency of every routed request.
+func (m *httpMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeTemplate(r)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		m.requests.Inc(r.Method, route, strconv.Itoa(recorder.status))
+		m.duration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware is one named step of the chain every routed request passes through.
+type Middleware struct {
+	Name string
+	Wrap func(http.Handler) http.Handler
+}
+
+// Names of the built-in middleware, in the order requests pass through them, outermost first.
+const (
+	MiddlewareRecovery       = "recovery"
+	MiddlewareTracing        = "tracing"
+	MiddlewareLogging        = "logging"
+	MiddlewareMetrics        = "metrics"
+	MiddlewareAuthentication = "authentication"
+	MiddlewareUsage          = "usage"
+	MiddlewareTimeout        = "timeout"
+)
+
+// defaultMiddleware returns the built-in chain. The timeout step's Wrap is left nil: NewRouter
+// fills it in with the deadline it is given.
+func (s *Server) defaultMiddleware() []Middleware {
+	return []Middleware{
+		{MiddlewareRecovery, recoverPanics},
+		{MiddlewareTracing, s.traceRequests},
+		{MiddlewareLogging, logRequests},
+		{MiddlewareMetrics, s.httpMetrics.middleware},
+		{MiddlewareAuthentication, s.authenticate},
+		{MiddlewareUsage, s.usage.middleware},
+		{MiddlewareTimeout, nil},
+	}
+}
+
+// Use adds middleware at the end of the chain, closest to the handlers: it sees requests
+// after authentication and within their deadline. Like the other setters, it applies to
+// routers created afterwards.
+func (s *Server) Use(m Middleware) error {
+	return s.insertMiddleware(len(s.middleware), m)
+}
+
+// UseBefore adds middleware to the chain just before the one named, so requests reach it
+// first.
+func (s *Server) UseBefore(name string, m Middleware) error {
+	i, err := s.middlewareIndex(name)
+	if err != nil {
+		return err
+	}
+	return s.insertMiddleware(i, m)
+}
+
+// UseAfter adds middleware to the chain just after the one named.
+func (s *Server) UseAfter(name string, m Middleware) error {
+	i, err := s.middlewareIndex(name)
+	if err != nil {
+		return err
+	}
+	return s.insertMiddleware(i+1, m)
+}
+
+// MiddlewareNames lists the chain in the order requests pass through it.
+func (s *Server) MiddlewareNames() []string {
+	names := make([]string, len(s.middleware))
+	for i, m := range s.middleware {
+		names[i] = m.Name
+	}
+	return names
+}
+
+func (s *Server) middlewareIndex(name string) (int, error) {
+	i := slices.IndexFunc(s.middleware, func(m Middleware) bool { return m.Name == name })
+	if i < 0 {
+		return 0, fmt.Errorf("no middleware named %q", name)
+	}
+	return i, nil
+}
+
+func (s *Server) insertMiddleware(i int, m Middleware) error {
+	if m.Name == "" || m.Wrap == nil {
+		return fmt.Errorf("middleware needs a name and a Wrap function")
+	}
+	if _, err := s.middlewareIndex(m.Name); err == nil {
+		return fmt.Errorf("middleware %q is already in the chain", m.Name)
+	}
+	s.middleware = slices.Insert(s.middleware, i, m)
+	return nil
+}
+
+// routeTemplate returns the path template of the route serving r, or its path when no route
+// matched.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
+
+// recoverPanics answers a request whose handler panicked with 500, if nothing has been sent
+// yet, and logs the panic with its stack rather than letting it reach the server, which
+// would drop the connection.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p) // The handler asked for the response to be cut short
+			}
+			slog.Error("Handler panicked", "method", r.Method, "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+			if recorder.status == 0 {
+				writeError(recorder, fmt.Sprintf("panic: %v", p), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// logRequests logs each request's method, route, status and duration once it has been served.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		slog.Info("Request", "method", r.Method, "route", routeTemplate(r), "status", recorder.status, "duration", time.Since(start))
+	})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+	server.SetTokenVerifier(fakeVerifier{})
+
+	var order []string
+	step := func(name string) Middleware {
+		return Middleware{Name: name, Wrap: func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}}
+	}
+	var caller principal
+	audit := Middleware{Name: "audit", Wrap: func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "audit")
+			caller, _ = principalFromContext(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	}}
+
+	if err := server.Use(audit); err != nil {
+		t.Fatalf("Use failed: %v", err)
+	}
+	if err := server.UseBefore(MiddlewareRecovery, step("request-id")); err != nil {
+		t.Fatalf("UseBefore failed: %v", err)
+	}
+	if err := server.UseAfter(MiddlewareAuthentication, step("tenant")); err != nil {
+		t.Fatalf("UseAfter failed: %v", err)
+	}
+	want := []string{"request-id", MiddlewareRecovery, MiddlewareTracing, MiddlewareLogging, MiddlewareMetrics,
+		MiddlewareAuthentication, "tenant", MiddlewareUsage, MiddlewareTimeout, "audit"}
+	if names := server.MiddlewareNames(); !slices.Equal(names, want) {
+		t.Errorf("Expected the chain %v, got %v", want, names)
+	}
+
+	if err := server.Use(step("tenant")); err == nil {
+		t.Error("Expected a second middleware of the same name to be rejected")
+	}
+	if err := server.UseBefore("missing", step("other")); err == nil {
+		t.Error("Expected an unknown middleware name to be rejected")
+	}
+	if err := server.Use(Middleware{Name: "empty"}); err == nil {
+		t.Error("Expected middleware without Wrap to be rejected")
+	}
+
+	router := NewRouter(server, time.Minute)
+	router.HandleFunc("/reports/custom", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	req := httptest.NewRequest("GET", "/reports/custom", nil)
+	req.Header.Set("Authorization", "Bearer ops:read-only")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !slices.Equal(order, []string{"request-id", "tenant", "audit"}) {
+		t.Errorf("Expected the added middleware in order, got %d %v", rr.Code, order)
+	}
+	if caller.Subject != "ops" {
+		t.Errorf("Expected middleware after authentication to see the caller, got %+v", caller)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	handler := recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil map")
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/loans", nil))
+	var p problem
+	json.Unmarshal(rr.Body.Bytes(), &p)
+	if rr.Code != http.StatusInternalServerError || p.Code != codeInternalError || strings.Contains(p.Detail, "nil map") {
+		t.Errorf("Expected an opaque 500 problem, got %d %+v", rr.Code, p)
+	}
+
+	// A panic after the response started cannot change its status
+	handler = recoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		panic("late")
+	}))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", nil))
+	if rr.Code != http.StatusCreated || rr.Body.Len() != 0 {
+		t.Errorf("Expected the started response left alone, got %d %q", rr.Code, rr.Body.String())
+	}
+}
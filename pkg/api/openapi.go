@@ -0,0 +1,481 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/graphql"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// openAPIOperation documents a route in the OpenAPI document. Routes without an entry in
+// openAPIOperations are still listed, with their path parameters and a generic response.
+type openAPIOperation struct {
+	OperationID string         // Overrides the ID derived from the handler's name
+	Summary     string         // One line describing the route
+	Query       []openAPIParam // Query string parameters
+	Headers     []openAPIParam // Request headers the route reads
+	Request     any            // Zero value of the JSON request body, if any
+	RequestType string         // Media type of a non-JSON request body
+	Response    any            // Zero value of the JSON response body, if any
+	Status      int            // Success status; 200 when zero
+}
+
+// openAPIParam is a query string parameter or request header.
+type openAPIParam struct {
+	Name        string
+	Type        string // JSON schema type; string when empty
+	Description string
+}
+
+// openAPIOperations documents the routes client SDKs are generated against, keyed by method
+// and path template.
+var openAPIOperations = map[string]openAPIOperation{
+	"GET /loans": {
+		Summary: "List loans a page at a time, optionally filtered and sorted",
+		Query: []openAPIParam{
+			{Name: "status", Description: "Only loans with this status"},
+			{Name: "customer_key", Description: "Only this customer's loans"},
+			{Name: "min_balance", Description: "Only loans with at least this balance"},
+			{Name: "created_after", Description: "Only loans created on or after this date (YYYY-MM-DD)"},
+			{Name: "sort", Description: "created_at, -created_at, balance or -balance"},
+			{Name: "limit", Type: "integer", Description: "Page size, at most 1000"},
+			{Name: "offset", Type: "integer", Description: "Loans to skip"},
+		},
+		Response: models.LoanPage{},
+	},
+	"POST /loans": {
+		Summary:  "Create a loan",
+		Request:  createLoanRequest{},
+		Response: models.Loan{},
+		Status:   http.StatusCreated,
+	},
+	"GET /loans/delinquent": {
+		Summary:  "List delinquent loans, optionally in one aging bucket",
+		Query:    []openAPIParam{{Name: "bucket", Description: "Delinquency bucket, e.g. 30-59"}},
+		Response: []*models.Loan{},
+	},
+	"GET /loans/search": {
+		Summary: "Search loans by balance, rate and creation date ranges, statuses and customer key prefix",
+		Query: append(loanSearchParams,
+			openAPIParam{Name: "limit", Type: "integer", Description: "Page size, at most 1000"},
+			openAPIParam{Name: "offset", Type: "integer", Description: "Loans to skip"},
+		),
+		Response: models.LoanPage{},
+	},
+	"GET /loans/{id}": {
+		Summary:  "Get a loan",
+		Response: models.Loan{},
+	},
+	"PUT /loans/{id}": {
+		Summary:  "Update a loan's terms; fields left out keep their values",
+		Request:  updateLoanRequest{},
+		Response: models.Loan{},
+	},
+	"DELETE /loans/{id}": {
+		Summary: "Void a loan, keeping it and its transactions on record",
+		Status:  http.StatusNoContent,
+	},
+	"POST /loans/{id}/payments": {
+		OperationID: "recordPayment",
+		Summary:     "Record a payment for a loan (a recovery if charged off)",
+		Query:       []openAPIParam{{Name: "dry_run", Type: "boolean", Description: "Return how the payment would be split and the resulting balance and status, with status 200, without recording it"}},
+		Headers:     []openAPIParam{{Name: "Idempotency-Key", Description: "Makes retries safe: a repeat with the same key and body replays the original response"}},
+		Request:     recordPaymentRequest{},
+		Response:    models.Transaction{},
+		Status:      http.StatusCreated,
+	},
+	"GET /loans/export": {
+		Summary: "Download the loans matching a search as CSV",
+		Query:   loanSearchParams,
+	},
+	"GET /loans/{id}/statements/{statementId}.pdf": {
+		Summary: "Download a statement as a PDF for mailing or the customer",
+	},
+	"GET /loans/{id}/transactions/export": {
+		Summary: "Download a loan's transactions as CSV, OFX or QIF, oldest first",
+		Query: append(transactionQueryParams,
+			openAPIParam{Name: "format", Description: "csv (default), ofx or qif"},
+		),
+	},
+	"GET /loans/{id}/transactions": {
+		Summary: "List a loan's transactions, oldest first, optionally filtered and a page at a time",
+		Query: append(transactionQueryParams,
+			openAPIParam{Name: "limit", Type: "integer", Description: "Page size, at most 1000; every match when unset"},
+			openAPIParam{Name: "offset", Type: "integer", Description: "Transactions to skip"},
+		),
+		Response: []*models.Transaction{},
+	},
+	"GET /loans/{id}/accruals": {
+		Summary: "List a loan's daily interest accruals",
+		Query: []openAPIParam{
+			{Name: "from", Description: "First day (YYYY-MM-DD)"},
+			{Name: "to", Description: "Last day (YYYY-MM-DD)"},
+		},
+		Response: []*models.Accrual{},
+	},
+	"GET /loans/{id}/ledger-events": {
+		Summary:  "List a loan's event log, kept in event-sourced mode, in order",
+		Response: []*models.LedgerEvent{},
+	},
+	"GET /loans/{id}/projection": {
+		Summary:  "Rebuild a loan's amounts from its event log and report any differences from the stored loan",
+		Response: models.LoanProjection{},
+	},
+	"POST /payments/import": {
+		Summary:     "Apply a CSV file of payments (loan_id,amount,date,reference) and report each row",
+		RequestType: "text/csv",
+		Response:    models.PaymentImportReport{},
+	},
+	"GET /customers": {
+		Summary:  "List customers ordered by customer key",
+		Response: []*models.Customer{},
+	},
+	"POST /customers": {
+		Summary:  "Register a customer",
+		Request:  models.Customer{},
+		Response: models.Customer{},
+		Status:   http.StatusCreated,
+	},
+	"GET /customers/{customer_key}": {
+		Summary:  "Get a customer",
+		Response: models.Customer{},
+	},
+	"PUT /customers/{customer_key}": {
+		Summary:  "Update a customer's details and status",
+		Request:  models.Customer{},
+		Response: models.Customer{},
+	},
+	"DELETE /customers/{customer_key}": {
+		Summary: "Delete a customer who has never had a loan",
+		Status:  http.StatusNoContent,
+	},
+	"GET /customers/{customer_key}/loans": {
+		Summary:  "List a customer's loans, oldest first",
+		Response: []*models.Loan{},
+	},
+	"GET /customers/{customer_key}/summary": {
+		Summary:  "Total a customer's loans",
+		Response: models.CustomerSummary{},
+	},
+	"GET /events/stream": {
+		Summary: "Stream new transactions and loan status changes as Server-Sent Events",
+		Query: []openAPIParam{
+			{Name: "loan_id", Description: "Only events for this loan"},
+			{Name: "types", Description: "Comma-separated event types (transaction.created, loan.status_changed, accrual.recorded)"},
+		},
+	},
+	"POST /graphql": {
+		Summary:  "Run a GraphQL query over loans, transactions, statements and customers",
+		Request:  graphql.Request{},
+		Response: graphql.Result{},
+	},
+	"GET /metrics": {
+		Summary: "Request counts, latencies and operator-registered collectors in Prometheus text format",
+	},
+	"GET /openapi.json": {
+		OperationID: "getOpenAPIDocument",
+		Summary:     "This OpenAPI document",
+	},
+	"GET /docs": {
+		Summary: "Swagger UI for this document",
+	},
+}
+
+// loanSearchParams are the filters and sort shared by the loan search and its CSV export.
+var loanSearchParams = []openAPIParam{
+	{Name: "status", Description: "Comma-separated statuses; loans with any of them"},
+	{Name: "customer_key_prefix", Description: "Only customers whose key starts with this (case-sensitive)"},
+	{Name: "min_balance", Description: "Only loans with at least this balance"},
+	{Name: "max_balance", Description: "Only loans with at most this balance"},
+	{Name: "min_rate", Description: "Only loans with at least this effective rate"},
+	{Name: "max_rate", Description: "Only loans with at most this effective rate"},
+	{Name: "created_from", Description: "Only loans created on or after this date (YYYY-MM-DD)"},
+	{Name: "created_to", Description: "Only loans created on or before this date (YYYY-MM-DD)"},
+	{Name: "sort", Description: "created_at, -created_at, balance or -balance"},
+}
+
+// transactionQueryParams are the filters shared by the transaction listing and its export.
+var transactionQueryParams = []openAPIParam{
+	{Name: "type", Description: "Comma-separated transaction types, e.g. payment,interest,fee"},
+	{Name: "from", Description: "Only transactions on or after this date (YYYY-MM-DD)"},
+	{Name: "to", Description: "Only transactions on or before this date (YYYY-MM-DD)"},
+	{Name: "min_amount", Description: "Only transactions of at least this amount"},
+	{Name: "max_amount", Description: "Only transactions of at most this amount"},
+	{Name: "metadata", Description: "A key:value the transaction's metadata must have; repeat for several"},
+}
+
+// pathParamPattern matches a variable in a mux path template, with or without a pattern.
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// buildOpenAPIDocument describes every route registered on the router as an OpenAPI 3
+// document, adding the details in openAPIOperations where a route has an entry.
+func buildOpenAPIDocument(router *mux.Router) (map[string]any, error) {
+	schemas := openAPISchemas{}
+	paths := map[string]map[string]any{}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // Not a path route
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // Matches any method, e.g. a subrouter
+		}
+
+		path := pathParamPattern.ReplaceAllString(template, "{$1}")
+		var pathParams []any
+		for _, match := range pathParamPattern.FindAllStringSubmatch(template, -1) {
+			pathParams = append(pathParams, map[string]any{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]any{"type": "string"},
+			})
+		}
+
+		for _, method := range methods {
+			doc := openAPIOperations[method+" "+path]
+			id := doc.OperationID
+			if id == "" {
+				id = operationID(route.GetHandler(), method, path)
+			}
+			operation := map[string]any{
+				"operationId": id,
+				"tags":        []string{strings.Split(strings.TrimPrefix(path, "/"), "/")[0]},
+			}
+			if doc.Summary != "" {
+				operation["summary"] = doc.Summary
+			}
+
+			params := append([]any{}, pathParams...)
+			for _, p := range doc.Query {
+				params = append(params, p.parameter("query"))
+			}
+			for _, p := range doc.Headers {
+				params = append(params, p.parameter("header"))
+			}
+			if len(params) > 0 {
+				operation["parameters"] = params
+			}
+
+			switch {
+			case doc.Request != nil:
+				operation["requestBody"] = map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(doc.Request))},
+					},
+				}
+			case doc.RequestType != "":
+				operation["requestBody"] = map[string]any{
+					"required": true,
+					"content": map[string]any{
+						doc.RequestType: map[string]any{"schema": map[string]any{"type": "string"}},
+					},
+				}
+			}
+
+			status := doc.Status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			success := map[string]any{"description": http.StatusText(status)}
+			if doc.Response != nil {
+				success["content"] = map[string]any{
+					"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(doc.Response))},
+				}
+			}
+			operation["responses"] = map[string]any{
+				strconv.Itoa(status): success,
+				"default": map[string]any{
+					"description": "Error, described by an RFC 7807 problem document",
+					"content": map[string]any{
+						problemContentType: map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(problem{}))},
+					},
+				},
+			}
+
+			if paths[path] == nil {
+				paths[path] = map[string]any{}
+			}
+			paths[path][strings.ToLower(method)] = operation
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "fredLoan API",
+			"description": "Loan servicing ledger: loans, payments, transactions, statements and reporting.",
+			"version":     "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}, nil
+}
+
+// parameter describes the parameter in an operation's parameter list.
+func (p openAPIParam) parameter(in string) map[string]any {
+	schemaType := p.Type
+	if schemaType == "" {
+		schemaType = "string"
+	}
+	param := map[string]any{
+		"name":   p.Name,
+		"in":     in,
+		"schema": map[string]any{"type": schemaType},
+	}
+	if p.Description != "" {
+		param["description"] = p.Description
+	}
+	return param
+}
+
+// operationID names an operation after its handler, e.g. getLoan for getLoanHandler. Handlers
+// wrapped in a closure fall back to a name built from the method and path.
+func operationID(handler http.Handler, method, path string) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+		name := fn.Name()
+		name = strings.TrimSuffix(name[strings.LastIndex(name, ".")+1:], "-fm")
+		if strings.HasSuffix(name, "Handler") {
+			return strings.TrimSuffix(name, "Handler")
+		}
+	}
+
+	id := strings.ToLower(method)
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '{' || r == '}' || r == '_' }) {
+		id += strings.ToUpper(segment[:1]) + segment[1:]
+	}
+	return id
+}
+
+// openAPISchemas collects the named schemas referenced by a document, keyed by Go type name.
+type openAPISchemas map[string]any
+
+// schemaFor describes how a Go type is encoded as JSON. Named structs are added to the
+// collection and referenced rather than inlined.
+func (c openAPISchemas) schemaFor(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeOf(decimal.Decimal{}):
+		return map[string]any{"type": "string", "format": "decimal", "example": "100.00"}
+	case reflect.TypeOf(uuid.UUID{}):
+		return map[string]any{"type": "string", "format": "uuid"}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return c.schemaFor(t.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": c.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": c.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return c.structSchema(t)
+		}
+		if _, ok := c[t.Name()]; !ok {
+			c[t.Name()] = map[string]any{} // Placeholder in case the type refers to itself
+			c[t.Name()] = c.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{}
+	}
+}
+
+// structSchema describes a struct's exported fields by their JSON names.
+func (c openAPISchemas) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = c.schemaFor(field.Type)
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+// openAPIHandler serves the OpenAPI document for the router. The document is built on the
+// first request, once every route has been registered.
+func openAPIHandler(router *mux.Router) http.HandlerFunc {
+	var once sync.Once
+	var body []byte
+	var err error
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			var doc map[string]any
+			if doc, err = buildOpenAPIDocument(router); err == nil {
+				body, err = json.MarshalIndent(doc, "", "  ")
+			}
+		})
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+// swaggerUIPage renders the OpenAPI document with Swagger UI, loaded from a CDN.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>fredLoan API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// swaggerUIHandler serves Swagger UI for the document at /openapi.json.
+func swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
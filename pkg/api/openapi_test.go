@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPIDocument_DescribesRegisteredRoutes(t *testing.T) {
+	server := NewServer(nil)
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.listLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/payments", server.paymentsHandler()).Methods("POST")
+	router.HandleFunc("/loans/{id}/timeline", server.getTimelineHandler).Methods("GET")
+	router.HandleFunc("/openapi.json", openAPIHandler(router)).Methods("GET")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody map[string]any `json:"requestBody"`
+			Responses   map[string]any `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+
+	if doc.OpenAPI != "3.0.3" {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+	if op := doc.Paths["/loans/{id}"]["get"]; op.OperationID != "getLoan" || len(op.Parameters) != 1 || op.Parameters[0].In != "path" {
+		t.Errorf("Expected getLoan with an id path parameter, got %+v", op)
+	}
+	payment := doc.Paths["/loans/{id}/payments"]["post"]
+	if payment.OperationID != "recordPayment" || payment.RequestBody == nil || payment.Responses["201"] == nil {
+		t.Errorf("Expected recordPayment with a body and a 201 response, got %+v", payment)
+	}
+	if op, ok := doc.Paths["/loans/{id}/timeline"]["get"]; !ok || op.OperationID != "getTimeline" {
+		t.Errorf("Expected undocumented routes to be listed, got %+v", op)
+	}
+	if len(doc.Paths["/loans"]["get"].Parameters) == 0 {
+		t.Error("Expected the loan listing's query parameters")
+	}
+
+	loan := doc.Components.Schemas["Loan"]
+	if loan.Properties["balance"]["format"] != "decimal" || loan.Properties["id"]["format"] != "uuid" {
+		t.Errorf("Expected decimal balance and uuid id in the Loan schema, got %+v", loan.Properties)
+	}
+	if _, ok := doc.Components.Schemas["LoanPage"]; !ok {
+		t.Error("Expected the LoanPage schema")
+	}
+}
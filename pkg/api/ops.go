@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// recalculateLoanHandler recomputes a loan from its transactions and rate history and
+// reports the differences. Pass commit=true to write the corrected values.
+func (s *Server) recalculateLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["loanID"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	commit := false
+	if v := r.URL.Query().Get("commit"); v != "" {
+		commit, err = strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, "Invalid commit, expected true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := s.ledger.RecalculateLoan(r.Context(), loanID, commit)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
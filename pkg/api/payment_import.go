@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxPaymentImportSize caps the size of an uploaded payment file.
+const maxPaymentImportSize = 32 << 20
+
+// importPaymentsHandler applies a CSV file of payments, sent either as the request body or as
+// the "file" field of a multipart form, and reports which rows were applied and which failed.
+func (s *Server) importPaymentsHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPaymentImportSize)
+
+	var file io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		part, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, "Missing payment file: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer part.Close()
+		file = part
+	}
+
+	report, err := s.ledger.ImportPayments(r.Context(), file)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// defaultPaymentLinkHours is how long a payment link stays valid when no lifetime is given.
+const defaultPaymentLinkHours = 72
+
+// webhookSignatureHeader carries the hex HMAC-SHA256 of the webhook body, keyed with the
+// secret shared with the payment processor.
+const webhookSignatureHeader = "X-Webhook-Signature"
+
+// paymentLinkView is the borrower-facing view of a payment link; it omits the loan ID.
+type paymentLinkView struct {
+	MinAmount decimal.Decimal          `json:"min_amount"`
+	MaxAmount decimal.Decimal          `json:"max_amount"`
+	Status    models.PaymentLinkStatus `json:"status"`
+	ExpiresAt time.Time                `json:"expires_at"`
+}
+
+func (s *Server) createPaymentLinkHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		MinAmount      decimal.Decimal `json:"min_amount"`
+		MaxAmount      decimal.Decimal `json:"max_amount"`
+		ExpiresInHours int             `json:"expires_in_hours"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = defaultPaymentLinkHours
+	}
+
+	link, token, err := s.ledger.CreatePaymentLink(r.Context(), loanID, req.MinAmount, req.MaxAmount, time.Duration(req.ExpiresInHours)*time.Hour)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(struct {
+		Token string `json:"token"`
+		paymentLinkView
+	}{token, viewPaymentLink(link)})
+}
+
+func viewPaymentLink(link *models.PaymentLink) paymentLinkView {
+	return paymentLinkView{MinAmount: link.MinAmount, MaxAmount: link.MaxAmount, Status: link.Status, ExpiresAt: link.ExpiresAt}
+}
+
+func (s *Server) getPaymentLinkHandler(w http.ResponseWriter, r *http.Request) {
+	link, err := s.ledger.ValidatePaymentLink(r.Context(), mux.Vars(r)["token"])
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(viewPaymentLink(link))
+}
+
+// paymentLinkWebhookHandler receives payment notifications from the payment processor for
+// payments made through a payment link. Deliveries must be signed with the shared webhook
+// secret. A repeated delivery for a link that was already redeemed is acknowledged without
+// recording a second payment.
+func (s *Server) paymentLinkWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if len(s.paymentWebhookSecret) == 0 {
+		writeError(w, "Payment webhooks are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validWebhookSignature(s.paymentWebhookSecret, body, r.Header.Get(webhookSignatureHeader)) {
+		writeError(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Token           string          `json:"token"`
+		Amount          decimal.Decimal `json:"amount"`
+		PaymentMethodID *uuid.UUID      `json:"payment_method_id"`
+	}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts []ledger.PaymentOption
+	if req.PaymentMethodID != nil {
+		opts = append(opts, ledger.WithPaymentMethod(*req.PaymentMethodID))
+	}
+
+	tx, err := s.ledger.RedeemPaymentLink(r.Context(), req.Token, req.Amount, opts...)
+	if err != nil {
+		if errors.Is(err, models.ErrPaymentLinkRedeemed) {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tx)
+}
+
+// validWebhookSignature reports whether signature is the hex HMAC-SHA256 of body under secret.
+func validWebhookSignature(secret []byte, body []byte, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
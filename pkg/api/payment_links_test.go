@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestAPI_PaymentLinkWebhook(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	server.ledger.SetPaymentLinkSecret([]byte("link-secret"))
+	server.paymentWebhookSecret = []byte("webhook-secret")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/payment-links", server.createPaymentLinkHandler).Methods("POST")
+	router.HandleFunc("/payment-links/{token}", server.getPaymentLinkHandler).Methods("GET")
+	router.HandleFunc("/webhooks/payment-links", server.paymentLinkWebhookHandler).Methods("POST")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	body, _ := json.Marshal(map[string]interface{}{"min_amount": "25", "max_amount": "100"})
+	req := httptest.NewRequest("POST", "/loans/"+loan.ID.String()+"/payment-links", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+	var created struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &created)
+
+	// The borrower-facing view does not reveal the loan
+	req = httptest.NewRequest("GET", "/payment-links/"+created.Token, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || bytes.Contains(rr.Body.Bytes(), []byte(loan.ID.String())) {
+		t.Fatalf("Expected link view without loan ID, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	webhook, _ := json.Marshal(map[string]interface{}{"token": created.Token, "amount": "60"})
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write(webhook)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	// Unsigned deliveries are rejected
+	req = httptest.NewRequest("POST", "/webhooks/payment-links", bytes.NewBuffer(webhook))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for unsigned webhook, got %d", rr.Code)
+	}
+
+	for i, expected := range []int{http.StatusCreated, http.StatusOK} {
+		req = httptest.NewRequest("POST", "/webhooks/payment-links", bytes.NewBuffer(webhook))
+		req.Header.Set(webhookSignatureHeader, signature)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != expected {
+			t.Errorf("Delivery %d: expected status %d, got %d. Body: %s", i+1, expected, rr.Code, rr.Body.String())
+		}
+	}
+
+	updated, _ := server.ledger.GetLoan(ctx, loan.ID)
+	if !updated.Balance.Equal(decimal.NewFromInt(940)) {
+		t.Errorf("Expected a single payment of 60, balance %s", updated.Balance)
+	}
+	txs, _ := server.storage.GetTransactionsForLoan(ctx, loan.ID)
+	payments := 0
+	for _, tx := range txs {
+		if tx.Type == models.TransactionTypePayment {
+			payments++
+		}
+	}
+	if payments != 1 {
+		t.Errorf("Expected 1 payment transaction, got %d", payments)
+	}
+}
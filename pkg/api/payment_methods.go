@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+func (s *Server) addPaymentMethodHandler(w http.ResponseWriter, r *http.Request) {
+	var method models.PaymentMethod
+	if !decodeJSON(w, r, &method) {
+		return
+	}
+
+	if err := s.ledger.AddPaymentMethod(r.Context(), &method); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(method)
+}
+
+func (s *Server) listPaymentMethodsHandler(w http.ResponseWriter, r *http.Request) {
+	customerKey := r.URL.Query().Get("customer_key")
+	var v validator
+	v.required("customer_key", customerKey)
+	if v.write(w) {
+		return
+	}
+
+	methods, err := s.ledger.GetPaymentMethodsForCustomer(r.Context(), customerKey)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(methods)
+}
+
+func (s *Server) getPaymentMethodHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid payment method ID", http.StatusBadRequest)
+		return
+	}
+
+	method, err := s.ledger.GetPaymentMethod(r.Context(), id)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(method)
+}
+
+func (s *Server) verifyPaymentMethodHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid payment method ID", http.StatusBadRequest)
+		return
+	}
+
+	method, err := s.ledger.VerifyPaymentMethod(r.Context(), id)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(method)
+}
+
+func (s *Server) expirePaymentMethodHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid payment method ID", http.StatusBadRequest)
+		return
+	}
+
+	method, err := s.ledger.ExpirePaymentMethod(r.Context(), id)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(method)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getPayoffQuoteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	date := time.Now()
+	if v := r.URL.Query().Get("date"); v != "" {
+		date, err = time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+
+	quote, err := s.ledger.GetPayoffQuote(r.Context(), loanID, date)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quote)
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// problemContentType is the media type of error responses (RFC 7807).
+const problemContentType = "application/problem+json"
+
+// Machine-readable error codes, sent in the "code" member of every problem response.
+const (
+	codeInvalidRequest     = "invalid_request"
+	codeValidationFailed   = "validation_failed"
+	codeUnauthorized       = "unauthorized"
+	codeForbidden          = "forbidden"
+	codeNotFound           = "not_found"
+	codeConflict           = "conflict"
+	codeGone               = "gone"
+	codePreconditionFailed = "precondition_failed"
+	codePreconditionNeeded = "precondition_required"
+	codeUnprocessable      = "unprocessable"
+	codeTooLarge           = "request_too_large"
+	codeInternalError      = "internal_error"
+	codeBadGateway         = "bad_gateway"
+	codeServiceUnavailable = "service_unavailable"
+)
+
+// statusCodes is the error code sent for each status when the handler gives no more specific one.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            codeInvalidRequest,
+	http.StatusUnauthorized:          codeUnauthorized,
+	http.StatusForbidden:             codeForbidden,
+	http.StatusNotFound:              codeNotFound,
+	http.StatusConflict:              codeConflict,
+	http.StatusGone:                  codeGone,
+	http.StatusPreconditionFailed:    codePreconditionFailed,
+	http.StatusPreconditionRequired:  codePreconditionNeeded,
+	http.StatusRequestEntityTooLarge: codeTooLarge,
+	http.StatusUnprocessableEntity:   codeUnprocessable,
+	http.StatusInternalServerError:   codeInternalError,
+	http.StatusBadGateway:            codeBadGateway,
+	http.StatusServiceUnavailable:    codeServiceUnavailable,
+}
+
+// problem is an RFC 7807 problem details object. Type is always about:blank, so Title is the
+// status text; Code and Errors are extension members.
+type problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   string       `json:"code"`
+	Errors []fieldError `json:"errors,omitempty"` // One entry per invalid field for validation_failed
+}
+
+// fieldError describes one invalid request field.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // required, must_be_positive, must_not_be_negative, out_of_range or invalid_format
+	Message string `json:"message"`
+}
+
+// writeProblem writes an application/problem+json error response.
+func writeProblem(w http.ResponseWriter, status int, code string, detail string, errors ...fieldError) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+		Errors: errors,
+	})
+}
+
+// writeError writes detail as a problem response with the status's default error code. The
+// detail of a server error is logged rather than returned, so internal failures such as
+// database errors are not exposed to clients.
+func writeError(w http.ResponseWriter, detail string, status int) {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = codeInvalidRequest
+		if status >= http.StatusInternalServerError {
+			code = codeInternalError
+		}
+	}
+	if status == http.StatusInternalServerError {
+		slog.Error("Internal error", "detail", detail)
+		detail = "An internal error occurred"
+	}
+	writeProblem(w, status, code, detail)
+}
+
+// errorResponses maps the ledger's and store's error values to responses. Detail replaces the
+// error's message when set.
+var errorResponses = []struct {
+	err    error
+	status int
+	detail string
+}{
+	{models.ErrLoanNotFound, http.StatusNotFound, "Loan not found"},
+	{models.ErrProductNotFound, http.StatusNotFound, "Product not found"},
+	{models.ErrCollateralNotFound, http.StatusNotFound, "Collateral not found"},
+	{models.ErrPaymentMethodNotFound, http.StatusNotFound, "Payment method not found"},
+	{models.ErrPaymentLinkNotFound, http.StatusNotFound, "Payment link not found"},
+	{models.ErrWebhookSubscriptionNotFound, http.StatusNotFound, "Webhook subscription not found"},
+	{models.ErrStatementNotFound, http.StatusNotFound, "Statement not found"},
+	{models.ErrCustomerNotFound, http.StatusNotFound, "Customer not found"},
+	{models.ErrNotEnrolledInAutopay, http.StatusNotFound, "Loan is not enrolled in autopay"},
+	{models.ErrInvalidPaymentLink, http.StatusNotFound, "Payment link not found"},
+	{models.ErrLoanNotActive, http.StatusConflict, ""},
+	{models.ErrInvalidStatusTransition, http.StatusConflict, ""},
+	{models.ErrNoBalanceToRefinance, http.StatusConflict, ""},
+	{models.ErrRateChangeBeforeAccrual, http.StatusConflict, ""},
+	{models.ErrForbearanceOverlap, http.StatusConflict, ""},
+	{models.ErrPaymentMethodNotPending, http.StatusConflict, ""},
+	{models.ErrPaymentLinkRedeemed, http.StatusConflict, ""},
+	{models.ErrIdempotencyKeyInUse, http.StatusConflict, ""},
+	{models.ErrCustomerExists, http.StatusConflict, ""},
+	{models.ErrCustomerNotActive, http.StatusConflict, ""},
+	{models.ErrCustomerHasLoans, http.StatusConflict, "Customer has loans; set their status to inactive instead"},
+	{models.ErrLoanVersionMismatch, http.StatusPreconditionFailed, "The loan has changed since it was read; fetch it again and retry with its new ETag"},
+	{models.ErrInsufficientCredit, http.StatusUnprocessableEntity, ""},
+	{models.ErrNoEscrowAccount, http.StatusUnprocessableEntity, ""},
+	{models.ErrNotLineOfCredit, http.StatusUnprocessableEntity, ""},
+	{models.ErrLoanHasNoTerm, http.StatusUnprocessableEntity, "Loan has no term to schedule"},
+	{models.ErrInsufficientEscrow, http.StatusUnprocessableEntity, ""},
+	{models.ErrEscrowExceedsPayment, http.StatusUnprocessableEntity, ""},
+	{models.ErrPaymentMethodNotVerified, http.StatusUnprocessableEntity, ""},
+	{models.ErrPaymentMethodExpired, http.StatusUnprocessableEntity, ""},
+	{models.ErrPaymentMethodNotCustomers, http.StatusUnprocessableEntity, ""},
+	{models.ErrPaymentLinkAmount, http.StatusUnprocessableEntity, ""},
+	{models.ErrIdempotencyKeyMismatch, http.StatusUnprocessableEntity, ""},
+	{models.ErrPaymentLinkExpired, http.StatusGone, ""},
+	{models.ErrPaymentLinksNotConfigured, http.StatusServiceUnavailable, ""},
+}
+
+// writeLedgerError writes the response for an error returned by the ledger: the mapped status
+// for a known error value, 400 for a models.ValidationError, and 500 otherwise. Handlers
+// check for errors specific to their operation first and pass the rest here.
+func writeLedgerError(w http.ResponseWriter, err error) {
+	for _, known := range errorResponses {
+		if errors.Is(err, known.err) {
+			detail := known.detail
+			if detail == "" {
+				detail = err.Error()
+			}
+			writeError(w, detail, known.status)
+			return
+		}
+	}
+	var invalid *models.ValidationError
+	if errors.As(err, &invalid) {
+		writeError(w, invalid.Message, http.StatusBadRequest)
+		return
+	}
+	writeError(w, err.Error(), http.StatusInternalServerError)
+}
+
+// validator collects every invalid field in a request so they can be reported together.
+type validator struct {
+	errors []fieldError
+}
+
+func (v *validator) add(field string, code string, message string) {
+	v.errors = append(v.errors, fieldError{Field: field, Code: code, Message: message})
+}
+
+// required checks that a string field is present.
+func (v *validator) required(field string, value string) {
+	if value == "" {
+		v.add(field, "required", field+" is required")
+	}
+}
+
+// positive checks that an amount is greater than zero.
+func (v *validator) positive(field string, value decimal.Decimal) {
+	if !value.IsPositive() {
+		v.add(field, "must_be_positive", field+" must be positive")
+	}
+}
+
+// nonNegative checks that an amount is zero or more.
+func (v *validator) nonNegative(field string, value decimal.Decimal) {
+	if value.IsNegative() {
+		v.add(field, "must_not_be_negative", field+" must not be negative")
+	}
+}
+
+// nonNegativeInt checks that a count is zero or more.
+func (v *validator) nonNegativeInt(field string, value int) {
+	if value < 0 {
+		v.add(field, "must_not_be_negative", field+" must not be negative")
+	}
+}
+
+// between checks that a value lies in [low, high].
+func (v *validator) between(field string, value decimal.Decimal, low decimal.Decimal, high decimal.Decimal) {
+	if value.LessThan(low) || value.GreaterThan(high) {
+		v.add(field, "out_of_range", fmt.Sprintf("%s must be between %s and %s", field, low, high))
+	}
+}
+
+// invalidFormat records a field that could not be parsed.
+func (v *validator) invalidFormat(field string, message string) {
+	v.add(field, "invalid_format", message)
+}
+
+// write reports the collected errors as a validation_failed problem. It returns false, writing
+// nothing, when the request was valid.
+func (v *validator) write(w http.ResponseWriter) bool {
+	if len(v.errors) == 0 {
+		return false
+	}
+	detail := v.errors[0].Message
+	if len(v.errors) > 1 {
+		detail = fmt.Sprintf("%s (and %d more)", detail, len(v.errors)-1)
+	}
+	writeProblem(w, http.StatusBadRequest, codeValidationFailed, detail, v.errors...)
+	return true
+}
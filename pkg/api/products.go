@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+func (s *Server) createProductHandler(w http.ResponseWriter, r *http.Request) {
+	var product models.Product
+	if !decodeJSON(w, r, &product) {
+		return
+	}
+
+	var v validator
+	v.required("code", product.Code)
+	if v.write(w) {
+		return
+	}
+
+	if err := s.ledger.CreateProduct(r.Context(), &product); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(product)
+}
+
+func (s *Server) listProductsHandler(w http.ResponseWriter, r *http.Request) {
+	products, err := s.ledger.GetAllProducts(r.Context())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
+
+func (s *Server) getProductHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	product, err := s.ledger.GetProduct(r.Context(), code)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+func (s *Server) updateProductHandler(w http.ResponseWriter, r *http.Request) {
+	code := mux.Vars(r)["code"]
+
+	var product models.Product
+	if !decodeJSON(w, r, &product) {
+		return
+	}
+	product.Code = code // Ensure code from URL is used
+
+	if err := s.ledger.UpdateProduct(r.Context(), &product); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
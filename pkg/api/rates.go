@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+func (s *Server) createRateChangeHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		BaseInterestRate     decimal.Decimal `json:"base_interest_rate"`
+		InterestRateVariance decimal.Decimal `json:"interest_rate_variance"`
+		EffectiveDate        string          `json:"effective_date"` // YYYY-MM-DD
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	effective, err := time.Parse("2006-01-02", req.EffectiveDate)
+	if err != nil {
+		writeError(w, "Invalid effective_date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	change, err := s.ledger.ChangeRate(r.Context(), loanID, req.BaseInterestRate, req.InterestRateVariance, effective)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(change)
+}
+
+func (s *Server) listRateChangesHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	history, err := s.ledger.GetRateHistory(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+)
+
+// defaultHistoryDays is how far back the portfolio history reaches when no start date is given.
+const defaultHistoryDays = 90
+
+func (s *Server) portfolioHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	granularity := ledger.Granularity(query.Get("granularity"))
+	if granularity == "" {
+		granularity = ledger.GranularityDaily
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if v := query.Get("to"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -defaultHistoryDays)
+	if v := query.Get("from"); v != "" {
+		parsed, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		from = parsed
+	}
+
+	history, err := s.ledger.GetPortfolioHistory(r.Context(), granularity, from, to)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/config"
+)
+
+// NewRouter returns the loan API's routes, served by s. Every route, including any the caller
+// adds to the router, runs behind s's middleware chain: panic recovery, request tracing,
+// logging, metrics, authentication, usage tracking and a deadline of timeout (see
+// untimedRoutes for the exceptions), with any middleware added through Use and its siblings.
+func NewRouter(s *Server, timeout time.Duration) *mux.Router {
+	router := mux.NewRouter()
+
+	router.HandleFunc("/loans", s.listLoansHandler).Methods("GET")
+	router.HandleFunc("/loans", s.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/delinquent", s.listDelinquentLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/search", s.searchLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/export", s.exportLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", s.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", s.updateLoanHandler).Methods("PUT")
+	router.HandleFunc("/loans/{id}", s.deleteLoanHandler).Methods("DELETE")
+	router.HandleFunc("/loans/{id}/payments", s.paymentsHandler()).Methods("POST")
+	router.HandleFunc("/loans/{id}/transactions", s.getTransactionsHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/transactions/export", s.exportTransactionsHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/autopay", s.getAutopayHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/autopay", s.enrollAutopayHandler).Methods("PUT")
+	router.HandleFunc("/loans/{id}/autopay", s.cancelAutopayHandler).Methods("DELETE")
+	router.HandleFunc("/loans/{id}/escrow/disbursements", s.disburseEscrowHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/fees", s.assessFeeHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/draws", s.drawHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/forbearance", s.placeInForbearanceHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/forbearance", s.getForbearancesHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/accruals", s.getAccrualsHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/ledger-events", s.getLedgerEventsHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/projection", s.getProjectionHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/collateral", s.addCollateralHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/collateral", s.getCollateralForLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/collateral/{collateralID}", s.getCollateralHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/collateral/{collateralID}", s.updateCollateralHandler).Methods("PUT")
+	router.HandleFunc("/loans/{id}/collateral/{collateralID}", s.deleteCollateralHandler).Methods("DELETE")
+	router.HandleFunc("/loans/{id}/charge-off", s.chargeOffLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/refinance", s.refinanceLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/rate-changes", s.listRateChangesHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/rate-changes", s.createRateChangeHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/payment-links", s.createPaymentLinkHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/payoff", s.getPayoffQuoteHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/schedule", s.getScheduleHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/statements", s.listStatementsHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/statements/{statementId}.pdf", s.statementPDFHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/timeline", s.getTimelineHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/notes", s.addNoteHandler).Methods("POST")
+	router.HandleFunc("/index-rates/{code}", s.listIndexRatesHandler).Methods("GET")
+	router.HandleFunc("/index-rates/{code}", s.publishIndexRateHandler).Methods("POST")
+	router.HandleFunc("/index-rates/{code}/refresh", s.refreshIndexRateHandler).Methods("POST")
+	router.HandleFunc("/customers", s.listCustomersHandler).Methods("GET")
+	router.HandleFunc("/customers", s.createCustomerHandler).Methods("POST")
+	router.HandleFunc("/customers/{customer_key}", s.getCustomerHandler).Methods("GET")
+	router.HandleFunc("/customers/{customer_key}", s.updateCustomerHandler).Methods("PUT")
+	router.HandleFunc("/customers/{customer_key}", s.deleteCustomerHandler).Methods("DELETE")
+	router.HandleFunc("/customers/{customer_key}/loans", s.getCustomerLoansHandler).Methods("GET")
+	router.HandleFunc("/customers/{customer_key}/summary", s.getCustomerSummaryHandler).Methods("GET")
+	router.HandleFunc("/payments/import", s.importPaymentsHandler).Methods("POST")
+	router.HandleFunc("/payment-methods", s.listPaymentMethodsHandler).Methods("GET")
+	router.HandleFunc("/payment-methods", s.addPaymentMethodHandler).Methods("POST")
+	router.HandleFunc("/payment-methods/{id}", s.getPaymentMethodHandler).Methods("GET")
+	router.HandleFunc("/payment-methods/{id}/verify", s.verifyPaymentMethodHandler).Methods("POST")
+	router.HandleFunc("/payment-methods/{id}/expire", s.expirePaymentMethodHandler).Methods("POST")
+	router.HandleFunc("/payment-links/{token}", s.getPaymentLinkHandler).Methods("GET")
+	router.HandleFunc("/webhooks/payment-links", s.paymentLinkWebhookHandler).Methods("POST")
+	router.HandleFunc("/webhooks/subscriptions", s.listWebhookSubscriptionsHandler).Methods("GET")
+	router.HandleFunc("/webhooks/subscriptions", s.createWebhookSubscriptionHandler).Methods("POST")
+	router.HandleFunc("/webhooks/subscriptions/{id}", s.deleteWebhookSubscriptionHandler).Methods("DELETE")
+	router.HandleFunc("/webhooks/subscriptions/{id}/deliveries", s.listWebhookDeliveriesHandler).Methods("GET")
+	router.HandleFunc("/events/stream", s.eventStreamHandler).Methods("GET")
+	router.HandleFunc("/products", s.listProductsHandler).Methods("GET")
+	router.HandleFunc("/products", s.createProductHandler).Methods("POST")
+	router.HandleFunc("/products/{code}", s.getProductHandler).Methods("GET")
+	router.HandleFunc("/products/{code}", s.updateProductHandler).Methods("PUT")
+	router.HandleFunc("/reports/bureau", s.bureauExportHandler).Methods("GET")
+	router.HandleFunc("/reports/portfolio/history", s.portfolioHistoryHandler).Methods("GET")
+	router.HandleFunc("/archive/loans/{id}", s.getArchivedLoanHandler).Methods("GET")
+	router.HandleFunc("/archive/loans/{id}/transactions", s.getArchivedTransactionsHandler).Methods("GET")
+	router.HandleFunc("/admin/archive", s.archiveLoansHandler).Methods("POST")
+	router.HandleFunc("/admin/archive/export", s.exportArchiveHandler).Methods("POST")
+	router.HandleFunc("/admin/backup", s.backupHandler).Methods("POST")
+	router.HandleFunc("/admin/interest-intents", s.listInterestIntentsHandler).Methods("GET")
+	router.HandleFunc("/admin/jobs", s.listJobRunsHandler).Methods("GET")
+	router.HandleFunc("/admin/jobs/daily-interest", s.runJobHandler(s.ledger.RunDailyInterest)).Methods("POST")
+	router.HandleFunc("/admin/jobs/monthly-interest", s.runJobHandler(s.ledger.RunMonthlyInterest)).Methods("POST")
+	router.HandleFunc("/admin/ops/recalculate/{loanID}", s.recalculateLoanHandler).Methods("POST")
+	router.HandleFunc("/admin/usage", s.usageReportHandler).Methods("GET")
+	router.HandleFunc("/admin/usage/{key}/quota", s.setQuotaHandler).Methods("PUT")
+	router.HandleFunc("/graphql", s.graphQLHandler).Methods("GET", "POST")
+	router.HandleFunc("/metrics", s.metricsHandler).Methods("GET")
+	router.HandleFunc("/openapi.json", openAPIHandler(router)).Methods("GET")
+	if s.swaggerUI {
+		router.HandleFunc("/docs", swaggerUIHandler).Methods("GET")
+	}
+	for _, m := range s.middleware {
+		wrap := m.Wrap
+		if m.Name == MiddlewareTimeout {
+			wrap = requestTimeout(timeout)
+		}
+		router.Use(wrap)
+	}
+	return router
+}
+
+// NewHandler returns the loan API served by s, ready to mount in an HTTP server: NewRouter's
+// routes behind the CORS policy and response compression cfg calls for.
+func NewHandler(s *Server, cfg config.Config) http.Handler {
+	router := NewRouter(s, cfg.RequestTimeout)
+	var handler http.Handler = router
+	if cors := newCORSPolicy(cfg, router); cors != nil {
+		handler = cors.handler(router)
+	}
+	return compressResponses(handler)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/config"
+	"github.com/shopspring/decimal"
+)
+
+func TestNewHandler(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	loan, _ := server.Ledger().CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	// A program of its own mounts the API under a prefix, next to its own routes
+	mux := http.NewServeMux()
+	mux.Handle("/lending/", http.StripPrefix("/lending", NewHandler(server, config.Default())))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/lending/loans/"+loan.ID.String(), nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"1"` {
+		t.Errorf("Expected the loan through the mounted API, got %d %+v", rr.Code, rr.Header())
+	}
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected the program's own route, got %d", rr.Code)
+	}
+
+	// Routes added to the router run behind the same middleware, authentication included
+	server.SetTokenVerifier(fakeVerifier{})
+	router := NewRouter(server, time.Minute)
+	router.HandleFunc("/reports/custom", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("GET")
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/reports/custom", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an added route to require a token, got %d", rr.Code)
+	}
+	req := httptest.NewRequest("GET", "/reports/custom", nil)
+	req.Header.Set("Authorization", "Bearer ops:read-only")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the added route with a token, got %d", rr.Code)
+	}
+}
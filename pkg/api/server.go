@@ -0,0 +1,1008 @@
+// Package api serves the loan ledger over HTTP. NewHandler returns the whole API for mounting
+// in a server of the caller's own; NewRouter returns its routes for adding others around them.
+// The daily batch and webhook delivery are run by the caller, through RunDailyBatch and
+// DeliverWebhooks, on whatever schedule it keeps.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/graphql"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/metrics"
+	"github.com/mcclellann/fredLoan/pkg/metro2"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+	"github.com/shopspring/decimal"
+)
+
+// defaultArchiveAfterMonths is how long a closed loan stays in the hot tables before it
+// is moved to cold storage.
+const defaultArchiveAfterMonths = 12
+
+// Server holds the ledger instance.
+type Server struct {
+	ledger  *ledger.Ledger
+	storage store.Storage // Keep a reference to the storage to close it
+	usage   *usageTracker // Per-API-key request counts and soft quotas
+
+	indexSource ledger.IndexRateSource // Feed for benchmark index rates; nil when not configured
+
+	paymentWebhookSecret []byte // Shared secret verifying payment processor webhooks; nil disables them
+
+	bureauFormat metro2.Format // Fixed-width layout of credit bureau exports
+
+	graphQL *graphql.Schema // Schema served at /graphql
+
+	tokenVerifier TokenVerifier // Validates bearer tokens; nil disables authentication
+
+	metrics     *metrics.Registry // Collectors served at /metrics
+	httpMetrics *httpMetrics      // Request counts and latencies, registered on metrics
+
+	tracer *tracing.Tracer // Records request and batch job spans; nil disables tracing
+
+	webhookSender ledger.WebhookSender // Delivers webhook events to subscribers
+
+	swaggerUI bool // Serve Swagger UI at /docs
+
+	backuper store.Backuper // Takes the snapshots served at /admin/backup; nil when the database has no online backup
+
+	archiveUploader    ledger.ArchiveUploader // Object storage old archived loans are exported to; nil disables the export
+	archiveExportYears int                    // How many years closed loans are kept before they are exported
+	archivePrune       bool                   // Delete exported loans from the archive
+
+	middleware []Middleware // Chain wrapping every route, outermost first
+
+	streamsClosed chan struct{} // Closed at shutdown to end open event streams
+
+	batchMu sync.Mutex // Held while batch jobs run, so on-demand runs don't overlap the schedule
+}
+
+// NewServer returns a server for a ledger on s, with authentication off, the default bureau
+// format and a metrics registry of its own. Configure it with its setters before serving it.
+func NewServer(s store.Storage) *Server {
+	server := &Server{
+		ledger:  ledger.NewLedger(s),
+		storage: s,
+		usage:   newUsageTracker(),
+
+		bureauFormat:  metro2.DefaultFormat,
+		httpMetrics:   newHTTPMetrics(),
+		webhookSender: newHTTPWebhookSender(),
+
+		streamsClosed: make(chan struct{}),
+	}
+	server.graphQL = server.newGraphQLSchema()
+	server.middleware = server.defaultMiddleware()
+	server.SetMetricsRegistry(metrics.NewRegistry())
+	return server
+}
+
+// Ledger returns the ledger the server works on, for setting its policies.
+func (s *Server) Ledger() *ledger.Ledger {
+	return s.ledger
+}
+
+// SetTracer records request and batch job spans with tracer; nil disables tracing.
+func (s *Server) SetTracer(tracer *tracing.Tracer) {
+	s.tracer = tracer
+}
+
+// SetTokenVerifier requires every request, except to public routes, to carry a bearer token
+// the verifier accepts; nil disables authentication.
+func (s *Server) SetTokenVerifier(verifier TokenVerifier) {
+	s.tokenVerifier = verifier
+}
+
+// SetIndexSource sets the feed the daily batch and /index-rates/{code}/refresh read benchmark
+// index rates from; nil leaves rates to be published manually.
+func (s *Server) SetIndexSource(source ledger.IndexRateSource) {
+	s.indexSource = source
+}
+
+// SetPaymentWebhookSecret sets the secret payment processor webhooks are signed with; empty
+// rejects them all.
+func (s *Server) SetPaymentWebhookSecret(secret []byte) {
+	s.paymentWebhookSecret = secret
+}
+
+// SetBureauFormat sets the record layout of credit bureau exports.
+func (s *Server) SetBureauFormat(format metro2.Format) {
+	s.bureauFormat = format
+}
+
+// SetBackuper serves snapshots of the database b takes at /admin/backup; nil, the default,
+// answers 503 there.
+func (s *Server) SetBackuper(b store.Backuper) {
+	s.backuper = b
+}
+
+// SetArchiveExport has the daily batch, and /admin/archive/export on demand, export loans
+// closed for longer than olderThanYears to object storage through uploader, deleting them
+// from the archive afterwards if prune is set. A nil uploader, the default, disables the
+// export.
+func (s *Server) SetArchiveExport(uploader ledger.ArchiveUploader, olderThanYears int, prune bool) {
+	s.archiveUploader = uploader
+	s.archiveExportYears = olderThanYears
+	s.archivePrune = prune
+}
+
+// SetSwaggerUI serves Swagger UI at /docs in routers created afterwards.
+func (s *Server) SetSwaggerUI(enabled bool) {
+	s.swaggerUI = enabled
+}
+
+// Metrics returns the registry served at /metrics, for registering collectors of the caller's
+// own.
+func (s *Server) Metrics() *metrics.Registry {
+	return s.metrics
+}
+
+// DeliverWebhooks relays the events waiting in the outbox to their subscribers' queues, then
+// sends the webhook events that are due, traced as a batch.DeliverWebhooks span.
+func (s *Server) DeliverWebhooks(ctx context.Context) {
+	ctx, span := s.tracer.Start(ctx, "batch.DeliverWebhooks", tracing.SpanKindInternal)
+	published, err := s.ledger.RelayOutbox(ctx)
+	if err != nil {
+		slog.Error("Outbox relay failed; the remaining events are retried on the next run.", "err", err)
+	}
+	span.SetAttribute("outbox.published", published)
+	delivered, failed := s.ledger.DeliverWebhooks(ctx, s.webhookSender)
+	span.SetAttribute("webhooks.delivered", delivered)
+	span.SetAttribute("webhooks.failed", failed)
+	span.End()
+	if delivered+failed > 0 {
+		slog.Info("Webhook delivery complete.", "delivered", delivered, "failed", failed)
+	}
+}
+
+// createLoanRequest is the body of POST /loans.
+type createLoanRequest struct {
+	CustomerKey             string              `json:"customer_key"`
+	Principal               decimal.Decimal     `json:"principal"`
+	BaseInterestRate        decimal.Decimal     `json:"base_interest_rate"`
+	InterestRateVariance    decimal.Decimal     `json:"interest_rate_variance"`
+	ProductCode             string              `json:"product_code"`
+	TermMonths              int                 `json:"term_months"`
+	AmortizationMonths      int                 `json:"amortization_months"`
+	PrepaymentPenalty       decimal.Decimal     `json:"prepayment_penalty_rate"`
+	PrepaymentMonths        int                 `json:"prepayment_penalty_months"`
+	IndexCode               string              `json:"index_code"`
+	PromoRate               decimal.Decimal     `json:"promo_rate"`
+	PromoStartDate          string              `json:"promo_start_date"` // YYYY-MM-DD, defaults to today
+	PromoEndDate            string              `json:"promo_end_date"`   // YYYY-MM-DD, last day of the promo
+	LoanType                models.LoanType     `json:"loan_type"`        // line_of_credit, or empty for an installment loan
+	CreditLimit             decimal.Decimal     `json:"credit_limit"`
+	Escrow                  bool                `json:"escrow"`
+	AccrualGraceDays        int                 `json:"accrual_grace_days"`        // Overrides the product's grace period
+	InterestMode            models.InterestMode `json:"interest_mode"`             // compound (default) or simple
+	NegativeAmortizationCap decimal.Decimal     `json:"negative_amortization_cap"` // Multiple of principal the balance may grow to, e.g. 1.10
+	OriginationFee          decimal.Decimal     `json:"origination_fee"`
+	CapitalizeFee           bool                `json:"capitalize_origination_fee"` // Add the fee to the balance instead of billing it
+}
+
+// maxInterestRate bounds the annual rates accepted when creating a loan (1 is 100% APR).
+var maxInterestRate = decimal.NewFromInt(1)
+
+// validate checks a create-loan request and writes a validation problem listing every invalid
+// field. It returns true if the request was rejected.
+func (req *createLoanRequest) validate(w http.ResponseWriter) bool {
+	var v validator
+	v.required("customer_key", req.CustomerKey)
+	switch req.LoanType {
+	case "":
+		v.positive("principal", req.Principal)
+	case models.LoanTypeLineOfCredit:
+		v.nonNegative("principal", req.Principal) // A line may be opened without an initial draw
+	default:
+		v.add("loan_type", "out_of_range", "loan_type must be line_of_credit or empty")
+	}
+	v.between("base_interest_rate", req.BaseInterestRate, decimal.Zero, maxInterestRate)
+	v.between("interest_rate_variance", req.InterestRateVariance, maxInterestRate.Neg(), maxInterestRate)
+	if req.IndexCode == "" && req.BaseInterestRate.Add(req.InterestRateVariance).IsNegative() {
+		v.add("interest_rate_variance", "out_of_range", "base_interest_rate plus interest_rate_variance must not be negative")
+	}
+	v.between("promo_rate", req.PromoRate, decimal.Zero, maxInterestRate)
+	v.nonNegativeInt("term_months", req.TermMonths)
+	v.nonNegativeInt("amortization_months", req.AmortizationMonths)
+	v.nonNegativeInt("prepayment_penalty_months", req.PrepaymentMonths)
+	v.nonNegativeInt("accrual_grace_days", req.AccrualGraceDays)
+	v.nonNegative("credit_limit", req.CreditLimit)
+	v.nonNegative("origination_fee", req.OriginationFee)
+	v.nonNegative("prepayment_penalty_rate", req.PrepaymentPenalty)
+	if _, err := time.Parse("2006-01-02", req.PromoEndDate); req.PromoEndDate != "" && err != nil {
+		v.invalidFormat("promo_end_date", "promo_end_date must be a date (YYYY-MM-DD)")
+	}
+	if _, err := time.Parse("2006-01-02", req.PromoStartDate); req.PromoStartDate != "" && err != nil {
+		v.invalidFormat("promo_start_date", "promo_start_date must be a date (YYYY-MM-DD)")
+	}
+	return v.write(w)
+}
+
+func (s *Server) createLoanHandler(w http.ResponseWriter, r *http.Request) {
+	var req createLoanRequest
+
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.validate(w) {
+		return
+	}
+
+	opts := []ledger.LoanOption{
+		ledger.WithTerm(req.TermMonths),
+		ledger.WithAmortization(req.AmortizationMonths),
+		ledger.WithPrepaymentPenalty(req.PrepaymentPenalty, req.PrepaymentMonths),
+	}
+	if req.ProductCode != "" {
+		opts = append(opts, ledger.WithProduct(req.ProductCode))
+	}
+	if req.IndexCode != "" {
+		opts = append(opts, ledger.WithIndex(req.IndexCode))
+	}
+	switch req.LoanType {
+	case models.LoanTypeLineOfCredit:
+		opts = append(opts, ledger.WithLineOfCredit(req.CreditLimit))
+	}
+	if req.Escrow {
+		opts = append(opts, ledger.WithEscrow())
+	}
+	if req.AccrualGraceDays > 0 {
+		opts = append(opts, ledger.WithAccrualGrace(req.AccrualGraceDays))
+	}
+	if req.InterestMode != "" {
+		opts = append(opts, ledger.WithInterestMode(req.InterestMode))
+	}
+	if !req.NegativeAmortizationCap.IsZero() {
+		opts = append(opts, ledger.WithNegativeAmortizationCap(req.NegativeAmortizationCap))
+	}
+	if req.PromoEndDate != "" {
+		promoEnd, _ := time.Parse("2006-01-02", req.PromoEndDate) // Checked by validate
+		promoStart := time.Now()
+		if req.PromoStartDate != "" {
+			promoStart, _ = time.Parse("2006-01-02", req.PromoStartDate)
+		}
+		opts = append(opts, ledger.WithPromo(req.PromoRate, promoStart, promoEnd))
+	}
+
+	loan, err := s.ledger.CreateLoan(r.Context(), req.CustomerKey, req.Principal, req.BaseInterestRate, req.InterestRateVariance, opts...)
+	if err != nil {
+		if errors.Is(err, models.ErrProductNotFound) {
+			writeError(w, "Unknown product code", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, models.ErrCustomerNotActive) {
+			writeError(w, "Customer is not active and cannot take new loans", http.StatusConflict)
+			return
+		}
+		var invalid *models.ValidationError
+		if errors.As(err, &invalid) {
+			writeError(w, invalid.Message, http.StatusBadRequest)
+			return
+		}
+		slog.Error("Error creating loan", "err", err)
+		writeError(w, fmt.Sprintf("Failed to create loan: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if req.OriginationFee.IsPositive() {
+		if _, err := s.ledger.AssessFee(r.Context(), loan.ID, models.TransactionTypeOriginationFee, req.OriginationFee, req.CapitalizeFee); err != nil {
+			slog.Error("Error assessing origination fee", "loan_id", loan.ID, "err", err)
+			writeError(w, fmt.Sprintf("Failed to assess origination fee: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if loan, err = s.ledger.GetLoan(r.Context(), loan.ID); err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (s *Server) getLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	loan, err := s.ledger.GetLoan(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	setLoanValidators(w, loan)
+	if loanNotModified(r, loan) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (s *Server) listLoansHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := models.LoanQuery{
+		Status:      models.LoanStatus(params.Get("status")),
+		CustomerKey: params.Get("customer_key"),
+		Sort:        models.LoanSort(params.Get("sort")),
+	}
+	if v := params.Get("min_balance"); v != "" {
+		minBalance, err := decimal.NewFromString(v)
+		if err != nil {
+			writeError(w, "Invalid min_balance", http.StatusBadRequest)
+			return
+		}
+		query.MinBalance = minBalance
+	}
+	if v := params.Get("created_after"); v != "" {
+		createdAfter, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid created_after, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		query.CreatedAfter = &createdAfter
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = limit
+	}
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+		query.Offset = offset
+	}
+
+	page, err := s.ledger.ListLoans(r.Context(), query)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// searchLoansHandler serves GET /loans/search.
+func (s *Server) searchLoansHandler(w http.ResponseWriter, r *http.Request) {
+	search, ok := loanSearch(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := s.ledger.SearchLoans(r.Context(), search)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// loanSearch reads the filters, sort and paging of a loan search, writing the error response
+// itself when they are invalid. status takes a comma-separated set, ranges are inclusive, and
+// created_to covers the whole of its day.
+func loanSearch(w http.ResponseWriter, r *http.Request) (models.LoanSearch, bool) {
+	params := r.URL.Query()
+
+	search := models.LoanSearch{
+		CustomerKeyPrefix: params.Get("customer_key_prefix"),
+		Sort:              models.LoanSort(params.Get("sort")),
+	}
+	if v := params.Get("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			search.Statuses = append(search.Statuses, models.LoanStatus(strings.TrimSpace(status)))
+		}
+	}
+	amounts := []struct {
+		name string
+		dest **decimal.Decimal
+	}{
+		{"min_balance", &search.MinBalance},
+		{"max_balance", &search.MaxBalance},
+		{"min_rate", &search.MinRate},
+		{"max_rate", &search.MaxRate},
+	}
+	for _, amount := range amounts {
+		if v := params.Get(amount.name); v != "" {
+			parsed, err := decimal.NewFromString(v)
+			if err != nil {
+				writeError(w, "Invalid "+amount.name, http.StatusBadRequest)
+				return search, false
+			}
+			*amount.dest = &parsed
+		}
+	}
+	if v := params.Get("created_from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid created_from, expected YYYY-MM-DD", http.StatusBadRequest)
+			return search, false
+		}
+		search.CreatedFrom = &from
+	}
+	if v := params.Get("created_to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid created_to, expected YYYY-MM-DD", http.StatusBadRequest)
+			return search, false
+		}
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		search.CreatedTo = &to
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return search, false
+		}
+		search.Limit = limit
+	}
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, "Invalid offset", http.StatusBadRequest)
+			return search, false
+		}
+		search.Offset = offset
+	}
+	return search, true
+}
+
+// updateLoanRequest is the body of PUT /loans/{id}: the loan's editable terms. Fields left
+// out keep their current values, and the amounts owed, which change only through
+// transactions, are not editable.
+type updateLoanRequest struct {
+	CustomerKey             *string              `json:"customer_key"`
+	Status                  *models.LoanStatus   `json:"status"`
+	BaseInterestRate        *decimal.Decimal     `json:"base_interest_rate"`
+	InterestRateVariance    *decimal.Decimal     `json:"interest_rate_variance"`
+	PromoRate               *decimal.Decimal     `json:"promo_rate"`
+	PromoStartDate          *time.Time           `json:"promo_start_date"`
+	PromoEndDate            *time.Time           `json:"promo_end_date"`
+	TermMonths              *int                 `json:"term_months"`
+	AmortizationMonths      *int                 `json:"amortization_months"`
+	PrepaymentPenalty       *decimal.Decimal     `json:"prepayment_penalty_rate"`
+	PrepaymentMonths        *int                 `json:"prepayment_penalty_months"`
+	CreditLimit             *decimal.Decimal     `json:"credit_limit"`
+	InterestMode            *models.InterestMode `json:"interest_mode"`
+	NegativeAmortizationCap *decimal.Decimal     `json:"negative_amortization_cap"`
+}
+
+// validate checks an update-loan request and writes a validation problem listing every
+// invalid field. It returns true if the request was rejected.
+func (req *updateLoanRequest) validate(w http.ResponseWriter) bool {
+	var v validator
+	if req.CustomerKey != nil {
+		v.required("customer_key", *req.CustomerKey)
+	}
+	if req.Status != nil && !req.Status.Valid() {
+		v.add("status", "out_of_range", fmt.Sprintf("status %q is not a loan status", *req.Status))
+	}
+	if req.BaseInterestRate != nil {
+		v.between("base_interest_rate", *req.BaseInterestRate, decimal.Zero, maxInterestRate)
+	}
+	if req.InterestRateVariance != nil {
+		v.between("interest_rate_variance", *req.InterestRateVariance, maxInterestRate.Neg(), maxInterestRate)
+	}
+	if req.PromoRate != nil {
+		v.between("promo_rate", *req.PromoRate, decimal.Zero, maxInterestRate)
+	}
+	if req.PrepaymentPenalty != nil {
+		v.between("prepayment_penalty_rate", *req.PrepaymentPenalty, decimal.Zero, decimal.NewFromInt(1))
+	}
+	if req.CreditLimit != nil {
+		v.nonNegative("credit_limit", *req.CreditLimit)
+	}
+	if req.NegativeAmortizationCap != nil {
+		v.nonNegative("negative_amortization_cap", *req.NegativeAmortizationCap)
+	}
+	counts := []struct {
+		name  string
+		value *int
+	}{
+		{"term_months", req.TermMonths},
+		{"amortization_months", req.AmortizationMonths},
+		{"prepayment_penalty_months", req.PrepaymentMonths},
+	}
+	for _, count := range counts {
+		if count.value != nil {
+			v.nonNegativeInt(count.name, *count.value)
+		}
+	}
+	if req.InterestMode != nil && !req.InterestMode.Valid() {
+		v.add("interest_mode", "out_of_range", "interest_mode must be compound or simple")
+	}
+	return v.write(w)
+}
+
+// apply copies the fields set in the request onto the loan.
+func (req *updateLoanRequest) apply(loan *models.Loan) {
+	set := func(dest *decimal.Decimal, value *decimal.Decimal) {
+		if value != nil {
+			*dest = *value
+		}
+	}
+	setInt := func(dest *int, value *int) {
+		if value != nil {
+			*dest = *value
+		}
+	}
+	if req.CustomerKey != nil {
+		loan.CustomerKey = *req.CustomerKey
+	}
+	if req.Status != nil {
+		loan.Status = *req.Status
+	}
+	set(&loan.BaseInterestRate, req.BaseInterestRate)
+	set(&loan.InterestRateVariance, req.InterestRateVariance)
+	set(&loan.PromoRate, req.PromoRate)
+	if req.PromoStartDate != nil {
+		loan.PromoStartDate = req.PromoStartDate
+	}
+	if req.PromoEndDate != nil {
+		loan.PromoEndDate = req.PromoEndDate
+	}
+	setInt(&loan.TermMonths, req.TermMonths)
+	setInt(&loan.AmortizationMonths, req.AmortizationMonths)
+	set(&loan.PrepaymentPenaltyRate, req.PrepaymentPenalty)
+	setInt(&loan.PrepaymentPenaltyMonths, req.PrepaymentMonths)
+	set(&loan.CreditLimit, req.CreditLimit)
+	if req.InterestMode != nil {
+		loan.InterestMode = *req.InterestMode
+	}
+	set(&loan.NegativeAmortizationCap, req.NegativeAmortizationCap)
+}
+
+func (s *Server) updateLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	version, ok := ifMatchVersion(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateLoanRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.validate(w) {
+		return
+	}
+
+	loan, err := s.ledger.GetLoan(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+	req.apply(loan)
+
+	if version > 0 {
+		err = s.ledger.UpdateLoanIfVersion(r.Context(), loan, version)
+	} else {
+		err = s.ledger.UpdateLoan(r.Context(), loan)
+	}
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	setLoanValidators(w, loan)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (s *Server) deleteLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.ledger.VoidLoan(r.Context(), loanID); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordPaymentRequest is the body of POST /loans/{id}/payments.
+type recordPaymentRequest struct {
+	Amount          decimal.Decimal   `json:"amount"`
+	EscrowAmount    decimal.Decimal   `json:"escrow_amount"` // Portion of the amount deposited into escrow
+	PaymentMethodID *uuid.UUID        `json:"payment_method_id"`
+	Metadata        map[string]string `json:"metadata"` // Free-form details such as the payment channel or operator ID
+}
+
+// paymentsHandler serves POST /loans/{id}/payments: a payment, or with dry_run=true a preview
+// of how it would be applied. Previews bypass idempotency so that they never take up a key.
+func (s *Server) paymentsHandler() http.HandlerFunc {
+	record := s.idempotent(s.recordPaymentHandler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if v := r.URL.Query().Get("dry_run"); v != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(v); err != nil {
+				writeError(w, "Invalid dry_run: must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+		if dryRun {
+			s.previewPaymentHandler(w, r)
+		} else {
+			record(w, r)
+		}
+	}
+}
+
+// paymentRequest decodes and validates the body of POST /loans/{id}/payments, writing the
+// error response itself if the request is invalid.
+func paymentRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, recordPaymentRequest, []ledger.PaymentOption, bool) {
+	var req recordPaymentRequest
+
+	loanID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return uuid.Nil, req, nil, false
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return uuid.Nil, req, nil, false
+	}
+
+	var v validator
+	v.positive("amount", req.Amount)
+	v.nonNegative("escrow_amount", req.EscrowAmount)
+	if v.write(w) {
+		return uuid.Nil, req, nil, false
+	}
+
+	var opts []ledger.PaymentOption
+	if req.PaymentMethodID != nil {
+		opts = append(opts, ledger.WithPaymentMethod(*req.PaymentMethodID))
+	}
+	opts = append(opts, ledger.WithMetadata(req.Metadata))
+	return loanID, req, opts, true
+}
+
+// writePaymentError writes the response for an error recording or previewing a payment.
+func writePaymentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrPaymentMethodNotFound) {
+		writeError(w, err.Error(), http.StatusUnprocessableEntity)
+	} else {
+		writeLedgerError(w, err)
+	}
+}
+
+func (s *Server) recordPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, req, opts, ok := paymentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := s.ledger.RecordPaymentWithEscrow(r.Context(), loanID, req.Amount, req.EscrowAmount, opts...)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tx)
+}
+
+// previewPaymentHandler serves POST /loans/{id}/payments?dry_run=true.
+func (s *Server) previewPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	loanID, req, opts, ok := paymentRequest(w, r)
+	if !ok {
+		return
+	}
+
+	preview, err := s.ledger.PreviewPayment(r.Context(), loanID, req.Amount, req.EscrowAmount, opts...)
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+func (s *Server) chargeOffLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	loan, err := s.ledger.ChargeOffLoan(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (s *Server) refinanceLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		BaseInterestRate     decimal.Decimal `json:"base_interest_rate"`
+		InterestRateVariance decimal.Decimal `json:"interest_rate_variance"`
+		TermMonths           int             `json:"term_months"`
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.between("base_interest_rate", req.BaseInterestRate, decimal.Zero, maxInterestRate)
+	v.between("interest_rate_variance", req.InterestRateVariance, maxInterestRate.Neg(), maxInterestRate)
+	v.nonNegativeInt("term_months", req.TermMonths)
+	if v.write(w) {
+		return
+	}
+
+	loan, err := s.ledger.RefinanceLoan(r.Context(), loanID, req.BaseInterestRate, req.InterestRateVariance, req.TermMonths)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (s *Server) listDelinquentLoansHandler(w http.ResponseWriter, r *http.Request) {
+	bucket := models.DelinquencyBucket(r.URL.Query().Get("bucket"))
+	if bucket != "" && !bucket.Valid() {
+		writeError(w, "Invalid delinquency bucket", http.StatusBadRequest)
+		return
+	}
+
+	loans, err := s.ledger.GetDelinquentLoans(r.Context(), bucket)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loans)
+}
+
+func (s *Server) archiveLoansHandler(w http.ResponseWriter, r *http.Request) {
+	months := defaultArchiveAfterMonths
+	if v := r.URL.Query().Get("older_than_months"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 0 {
+			writeError(w, "Invalid older_than_months", http.StatusBadRequest)
+			return
+		}
+		months = parsed
+	}
+
+	archived, err := s.ledger.ArchiveClosedLoans(r.Context(), months)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"archived": archived})
+}
+
+func (s *Server) exportArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	if s.archiveUploader == nil {
+		writeError(w, "Archive export is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	export, err := s.ledger.ExportArchive(r.Context(), s.archiveUploader, s.archiveExportYears, s.archivePrune)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(export)
+}
+
+func (s *Server) getArchivedLoanHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	loan, err := s.ledger.GetArchivedLoan(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(loan)
+}
+
+func (s *Server) getTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	query, ok := transactionQuery(w, r)
+	if !ok {
+		return
+	}
+
+	txs, total, err := s.ledger.QueryTransactions(r.Context(), loanID, query)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	// The body stays a plain array; paging details travel in headers
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if query.Limit > 0 && query.Offset+len(txs) < total {
+		next := r.URL.Query()
+		next.Set("offset", strconv.Itoa(query.Offset+len(txs)))
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, next.Encode()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(txs)
+}
+
+// transactionQuery reads the filters and paging of GET /loans/{id}/transactions, writing the
+// error response itself when they are invalid. to covers the whole of its day.
+func transactionQuery(w http.ResponseWriter, r *http.Request) (models.TransactionQuery, bool) {
+	params := r.URL.Query()
+
+	var query models.TransactionQuery
+	if v := params.Get("type"); v != "" {
+		for _, txType := range strings.Split(v, ",") {
+			query.Types = append(query.Types, models.TransactionType(strings.TrimSpace(txType)))
+		}
+	}
+	if v := params.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return query, false
+		}
+		query.From = &from
+	}
+	if v := params.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			writeError(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return query, false
+		}
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		query.To = &to
+	}
+	amounts := []struct {
+		name string
+		dest **decimal.Decimal
+	}{
+		{"min_amount", &query.MinAmount},
+		{"max_amount", &query.MaxAmount},
+	}
+	for _, amount := range amounts {
+		if v := params.Get(amount.name); v != "" {
+			parsed, err := decimal.NewFromString(v)
+			if err != nil {
+				writeError(w, "Invalid "+amount.name, http.StatusBadRequest)
+				return query, false
+			}
+			*amount.dest = &parsed
+		}
+	}
+	for _, v := range params["metadata"] {
+		key, value, ok := strings.Cut(v, ":")
+		if !ok {
+			writeError(w, "Invalid metadata filter, expected key:value", http.StatusBadRequest)
+			return query, false
+		}
+		if query.Metadata == nil {
+			query.Metadata = map[string]string{}
+		}
+		query.Metadata[key] = value
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return query, false
+		}
+		query.Limit = limit
+	}
+	if v := params.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil {
+			writeError(w, "Invalid offset", http.StatusBadRequest)
+			return query, false
+		}
+		query.Offset = offset
+	}
+	return query, true
+}
+
+func (s *Server) getArchivedTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	txs, err := s.ledger.GetArchivedTransactions(r.Context(), loanID)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(txs)
+}
@@ -0,0 +1,1799 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/metrics"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+	"github.com/shopspring/decimal"
+)
+
+func setupTestServer(t *testing.T) (*Server, string) {
+	dbFile := "test_api_dec.db"
+	os.Remove(dbFile)
+
+	s, err := store.NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	return NewServer(s), dbFile
+}
+
+func TestAPI_CreateAndGetLoan(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+
+	// Create Loan
+	loanReq := map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              5000.0,
+		"base_interest_rate":     0.08,
+		"interest_rate_variance": 0.01,
+	}
+	body, _ := json.Marshal(loanReq)
+	req := httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", rr.Code)
+	}
+
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	expectedRate := decimal.NewFromFloat(0.09)
+	if !createdLoan.InterestRate.Equal(expectedRate) {
+		t.Errorf("Expected effective rate %s, got %s", expectedRate, createdLoan.InterestRate)
+	}
+
+	// Get Loan
+	req = httptest.NewRequest("GET", "/loans/"+createdLoan.ID.String(), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rr.Code)
+	}
+
+	var fetchedLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &fetchedLoan)
+
+	if fetchedLoan.ID != createdLoan.ID {
+		t.Errorf("Expected ID %s, got %s", createdLoan.ID, fetchedLoan.ID)
+	}
+}
+
+func TestAPI_RecordPayment(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/payments", server.recordPaymentHandler).Methods("POST")
+
+	// Create Loan
+	loanReq := map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	}
+	body, _ := json.Marshal(loanReq)
+	req := httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	// Record Payment
+	paymentAmount := 200.0
+	payReq := map[string]interface{}{
+		"amount":   paymentAmount,
+		"metadata": map[string]string{"channel": "branch", "operator_id": "op-7"},
+	}
+	body, _ = json.Marshal(payReq)
+	req = httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/payments", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	var tx models.Transaction
+	json.Unmarshal(rr.Body.Bytes(), &tx)
+	if !tx.Amount.Equal(decimal.NewFromFloat(paymentAmount)) {
+		t.Errorf("Expected amount %f, got %s", paymentAmount, tx.Amount)
+	}
+	if tx.Metadata["channel"] != "branch" || tx.Metadata["operator_id"] != "op-7" {
+		t.Errorf("Expected the payment's metadata, got %v", tx.Metadata)
+	}
+
+	// Metadata keys are limited to what can be filtered on
+	body, _ = json.Marshal(map[string]interface{}{"amount": 10.0, "metadata": map[string]string{"bad key": "x"}})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/payments", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid metadata key, got %d", rr.Code)
+	}
+}
+
+func TestAPI_VoidLoan(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.listLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", server.deleteLoanHandler).Methods("DELETE")
+	router.HandleFunc("/loans/{id}/transactions", server.getTransactionsHandler).Methods("GET")
+
+	loan, err := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/loans/"+loan.ID.String(), nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	// The loan is still there, voided, with its transactions
+	req = httptest.NewRequest("GET", "/loans/"+loan.ID.String(), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var voided models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &voided)
+	if rr.Code != http.StatusOK || voided.Status != models.LoanStatusVoided || voided.DeletedAt == nil {
+		t.Errorf("Expected the loan voided with deleted_at, got status %d and loan status %s", rr.Code, voided.Status)
+	}
+	req = httptest.NewRequest("GET", "/loans/"+loan.ID.String()+"/transactions", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var transactions []models.Transaction
+	json.Unmarshal(rr.Body.Bytes(), &transactions)
+	if len(transactions) == 0 {
+		t.Error("Expected the voided loan's disbursement to be kept")
+	}
+
+	// It is only listed when asked for
+	for query, want := range map[string]int{"": 0, "?status=voided": 1} {
+		req = httptest.NewRequest("GET", "/loans"+query, nil)
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var page models.LoanPage
+		json.Unmarshal(rr.Body.Bytes(), &page)
+		if page.Total != want {
+			t.Errorf("Expected %d loans listed for %q, got %d", want, query, page.Total)
+		}
+	}
+}
+
+func TestAPI_Timeline(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/notes", server.addNoteHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/timeline", server.getTimelineHandler).Methods("GET")
+
+	loanReq := map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	}
+	body, _ := json.Marshal(loanReq)
+	req := httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	body, _ = json.Marshal(map[string]string{"author": "agent1", "text": "Promised to pay Friday"})
+	req = httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/notes", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/loans/"+createdLoan.ID.String()+"/timeline", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	var timeline []models.TimelineEntry
+	json.Unmarshal(rr.Body.Bytes(), &timeline)
+	if len(timeline) != 3 {
+		t.Fatalf("Expected 3 timeline entries, got %d", len(timeline))
+	}
+	if timeline[0].Type != "disbursement" || timeline[1].Type != "notification" || timeline[2].Type != "note" {
+		t.Errorf("Unexpected timeline order: %s, %s, %s", timeline[0].Type, timeline[1].Type, timeline[2].Type)
+	}
+}
+
+func TestAPI_RefinanceLoan(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/refinance", server.refinanceLoanHandler).Methods("POST")
+
+	loanReq := map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.12,
+		"interest_rate_variance": 0.0,
+		"term_months":            36,
+	}
+	body, _ := json.Marshal(loanReq)
+	req := httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	body, _ = json.Marshal(map[string]interface{}{"base_interest_rate": 0.07, "interest_rate_variance": 0.0, "term_months": 60})
+	req = httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/refinance", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	var refinanced models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &refinanced)
+
+	// Fetch from the store to verify the link survives persistence
+	req = httptest.NewRequest("GET", "/loans/"+refinanced.ID.String(), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var fetched models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &fetched)
+
+	if fetched.RefinancedFrom == nil || *fetched.RefinancedFrom != createdLoan.ID {
+		t.Errorf("Expected refinanced_from %s, got %v", createdLoan.ID, fetched.RefinancedFrom)
+	}
+	if fetched.TermMonths != 60 {
+		t.Errorf("Expected term 60, got %d", fetched.TermMonths)
+	}
+
+	req = httptest.NewRequest("GET", "/loans/"+createdLoan.ID.String(), nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var old models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &old)
+	if old.Status != "closed" {
+		t.Errorf("Expected original loan to be closed, got %s", old.Status)
+	}
+}
+
+func TestAPI_OriginationFee(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/fees", server.assessFeeHandler).Methods("POST")
+
+	loanReq := map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+		"origination_fee":        40.0,
+	}
+	body, _ := json.Marshal(loanReq)
+	req := httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+	if !createdLoan.Balance.Equal(decimal.NewFromInt(1000)) || !createdLoan.FeesDue.Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Expected balance 1000 and fees due 40, got %s and %s", createdLoan.Balance, createdLoan.FeesDue)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{"type": "servicing_fee", "amount": 15.0, "capitalize": true})
+	req = httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/fees", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{"type": "payment", "amount": 15.0})
+	req = httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/fees", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a non-fee type, got %d", rr.Code)
+	}
+}
+
+func TestAPI_ListTransactions(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/payments", server.recordPaymentHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/transactions", server.getTransactionsHandler).Methods("GET")
+
+	loanReq := map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	}
+	body, _ := json.Marshal(loanReq)
+	req := httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	body, _ = json.Marshal(map[string]interface{}{"amount": 150.0})
+	req = httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/payments", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	req = httptest.NewRequest("GET", "/loans/"+createdLoan.ID.String()+"/transactions", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	var txs []models.Transaction
+	json.Unmarshal(rr.Body.Bytes(), &txs)
+	if len(txs) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(txs))
+	}
+	if txs[0].Type != models.TransactionTypeDisbursement || txs[1].Type != models.TransactionTypePayment {
+		t.Errorf("Unexpected transaction order: %s, %s", txs[0].Type, txs[1].Type)
+	}
+
+	req = httptest.NewRequest("GET", "/loans/"+uuid.New().String()+"/transactions", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown loan, got %d", rr.Code)
+	}
+}
+
+func TestAPI_QueryTransactions(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/transactions", server.getTransactionsHandler).Methods("GET")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	for _, amount := range []int64{10, 20, 30, 40, 50} {
+		channel := map[string]string{"channel": "online"}
+		if amount == 40 {
+			channel["channel"] = "branch"
+		}
+		if _, err := server.ledger.RecordPayment(ctx, loan.ID, decimal.NewFromInt(amount), ledger.WithMetadata(channel)); err != nil {
+			t.Fatalf("Failed to record payment: %v", err)
+		}
+	}
+	path := "/loans/" + loan.ID.String() + "/transactions"
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?type=payment&min_amount=20&max_amount=50&limit=2", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var txs []models.Transaction
+	json.NewDecoder(rr.Body).Decode(&txs)
+	if len(txs) != 2 || !txs[0].Amount.Equal(decimal.NewFromInt(20)) || !txs[1].Amount.Equal(decimal.NewFromInt(30)) {
+		t.Fatalf("Expected the 20 and 30 payments, got %d transactions", len(txs))
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "4" {
+		t.Errorf("Expected X-Total-Count 4, got %q", total)
+	}
+	link := rr.Header().Get("Link")
+	if !strings.Contains(link, "offset=2") || !strings.HasSuffix(link, `rel="next"`) {
+		t.Fatalf("Expected a link to the next page, got %q", link)
+	}
+
+	// Following the link reaches the last page, which has no next link
+	next := strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", next, nil))
+	json.NewDecoder(rr.Body).Decode(&txs)
+	if len(txs) != 2 || !txs[1].Amount.Equal(decimal.NewFromInt(50)) || rr.Header().Get("Link") != "" {
+		t.Errorf("Expected the last 2 payments and no next link, got %d transactions and %q", len(txs), rr.Header().Get("Link"))
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?type=disbursement&from="+today+"&to="+today, nil))
+	json.NewDecoder(rr.Body).Decode(&txs)
+	if len(txs) != 1 || txs[0].Type != models.TransactionTypeDisbursement {
+		t.Errorf("Expected today's disbursement, got %d transactions", len(txs))
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?metadata=channel:branch", nil))
+	json.NewDecoder(rr.Body).Decode(&txs)
+	if len(txs) != 1 || !txs[0].Amount.Equal(decimal.NewFromInt(40)) || txs[0].Metadata["channel"] != "branch" {
+		t.Errorf("Expected the payment made at the branch, got %d transactions", len(txs))
+	}
+
+	for _, query := range []string{"type=refund", "from=yesterday", "min_amount=50&max_amount=10", "limit=0", "limit=5000", "offset=-1", "metadata=channel", "metadata=chan%22nel:branch"} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAPI_CustomerLoans(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/customers/{customer_key}/loans", server.getCustomerLoansHandler).Methods("GET")
+
+	for _, customerKey := range []string{"cust_a", "cust_b", "cust_a"} {
+		body, _ := json.Marshal(map[string]interface{}{
+			"customer_key":           customerKey,
+			"principal":              1000.0,
+			"base_interest_rate":     0.10,
+			"interest_rate_variance": 0.0,
+		})
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body)))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/customers/cust_a/loans", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+	var loans []models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &loans)
+	if len(loans) != 2 {
+		t.Fatalf("Expected 2 loans for cust_a, got %d", len(loans))
+	}
+	for _, loan := range loans {
+		if loan.CustomerKey != "cust_a" {
+			t.Errorf("Expected only cust_a's loans, got one for %s", loan.CustomerKey)
+		}
+	}
+}
+
+func TestAPI_Customers(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/customers", server.listCustomersHandler).Methods("GET")
+	router.HandleFunc("/customers", server.createCustomerHandler).Methods("POST")
+	router.HandleFunc("/customers/{customer_key}", server.getCustomerHandler).Methods("GET")
+	router.HandleFunc("/customers/{customer_key}", server.updateCustomerHandler).Methods("PUT")
+	router.HandleFunc("/customers/{customer_key}", server.deleteCustomerHandler).Methods("DELETE")
+
+	send := func(method, target string, body any) *httptest.ResponseRecorder {
+		var reader *bytes.Buffer
+		if body == nil {
+			reader = &bytes.Buffer{}
+		} else {
+			encoded, _ := json.Marshal(body)
+			reader = bytes.NewBuffer(encoded)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, target, reader))
+		return rr
+	}
+
+	if rr := send("POST", "/customers", map[string]any{"name": "No Key"}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a customer_key, got %d", rr.Code)
+	}
+	rr := send("POST", "/customers", map[string]any{"customer_key": "cust_a", "name": "Ada Borrower", "email": "ada@example.com"})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("POST", "/customers", map[string]any{"customer_key": "cust_a"}); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a taken key, got %d", rr.Code)
+	}
+
+	rr = send("PUT", "/customers/cust_a", map[string]any{"name": "Ada Borrower", "status": "inactive"})
+	var updated models.Customer
+	json.Unmarshal(rr.Body.Bytes(), &updated)
+	if rr.Code != http.StatusOK || updated.Status != models.CustomerStatusInactive || updated.CustomerKey != "cust_a" {
+		t.Errorf("Expected the customer made inactive, got %d: %s", rr.Code, rr.Body.String())
+	}
+	loanReq := map[string]any{"customer_key": "cust_a", "principal": 1000.0, "base_interest_rate": 0.10}
+	if rr := send("POST", "/loans", loanReq); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a loan to an inactive customer, got %d", rr.Code)
+	}
+
+	// A loan for an unknown key registers the customer, who then cannot be deleted
+	loanReq["customer_key"] = "cust_b"
+	if rr := send("POST", "/loans", loanReq); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+	if rr := send("GET", "/customers/cust_b", nil); rr.Code != http.StatusOK {
+		t.Errorf("Expected the loan's customer to be registered, got %d", rr.Code)
+	}
+	if rr := send("DELETE", "/customers/cust_b", nil); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 deleting a customer with loans, got %d", rr.Code)
+	}
+
+	rr = send("GET", "/customers", nil)
+	var customers []models.Customer
+	json.Unmarshal(rr.Body.Bytes(), &customers)
+	if len(customers) != 2 || customers[0].CustomerKey != "cust_a" {
+		t.Errorf("Expected both customers ordered by key, got %s", rr.Body.String())
+	}
+
+	if rr := send("DELETE", "/customers/cust_a", nil); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rr.Code)
+	}
+	if rr := send("GET", "/customers/cust_a", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after deleting, got %d", rr.Code)
+	}
+}
+
+func TestAPI_PreviewPayment(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/payments", server.paymentsHandler()).Methods("POST")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "acme-1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	pay := func(query string, amount float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"amount": amount})
+		req := httptest.NewRequest("POST", "/loans/"+loan.ID.String()+"/payments"+query, bytes.NewBuffer(body))
+		req.Header.Set("Idempotency-Key", "pay-1")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := pay("?dry_run=true", 1200)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var preview models.PaymentPreview
+	json.Unmarshal(rr.Body.Bytes(), &preview)
+	// A payoff settles today's interest, 0.27 at 10% on 1000, before principal
+	if !preview.InterestPaid.Equal(decimal.NewFromFloat(0.27)) || !preview.PrincipalPaid.Equal(decimal.NewFromInt(1000)) ||
+		!preview.Unapplied.Equal(decimal.NewFromFloat(199.73)) || !preview.Balance.IsZero() || preview.Status != models.LoanStatusClosed {
+		t.Errorf("Expected a payoff with 199.73 unapplied, got %+v", preview)
+	}
+	if stored, _ := server.ledger.GetLoan(ctx, loan.ID); !stored.Balance.Equal(decimal.NewFromInt(1000)) || stored.Status != models.LoanStatusActive {
+		t.Errorf("Expected the preview to leave the loan untouched, got balance %s and status %s", stored.Balance, stored.Status)
+	}
+
+	// The preview did not use up the idempotency key
+	if rr := pay("", 100); rr.Code != http.StatusCreated || rr.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("Expected the payment to be recorded, got %d", rr.Code)
+	}
+	if rr := pay("?dry_run=maybe", 100); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid dry_run, got %d", rr.Code)
+	}
+	if rr := pay("?dry_run=true", -5); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a negative amount, got %d", rr.Code)
+	}
+}
+
+func TestAPI_IdempotentPayment(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/payments", server.idempotent(server.recordPaymentHandler)).Methods("POST")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body)))
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	pay := func(key string, amount float64) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"amount": amount})
+		req := httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/payments", bytes.NewBuffer(body))
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := pay("retry-1", 100)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", first.Code, first.Body.String())
+	}
+	retry := pay("retry-1", 100)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected the original response replayed, got %d: %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Expected the replayed response to be marked")
+	}
+	if reused := pay("retry-1", 250); reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status 422 for a key reused with a different body, got %d", reused.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+createdLoan.ID.String(), nil))
+	var loan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &loan)
+	if !loan.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected the payment posted once for a balance of 900, got %s", loan.Balance)
+	}
+}
+
+// unrecordedResponseStore fails to record responses for idempotency keys, outside of any
+// transaction, as a database that went away after a payment committed would.
+type unrecordedResponseStore struct {
+	store.Storage
+}
+
+func (s unrecordedResponseStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	return errors.New("database is unavailable")
+}
+
+func TestAPI_IdempotentPaymentUnrecordedResponse(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+	server = NewServer(unrecordedResponseStore{server.storage})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/payments", server.idempotent(server.recordPaymentHandler)).Methods("POST")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	pay := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"amount": 100})
+		req := httptest.NewRequest("POST", "/loans/"+loan.ID.String()+"/payments", bytes.NewBuffer(body))
+		req.Header.Set("Idempotency-Key", "lost-response-1")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := pay(); rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// The payment posted, so the key is kept even though its response was lost
+	if rr := pay(); rr.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for a retry of a payment whose response was lost, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stored, _ := server.ledger.GetLoan(ctx, loan.ID); !stored.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected the payment posted once for a balance of 900, got %s", stored.Balance)
+	}
+}
+
+func TestAPI_ImportPayments(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/transactions", server.getTransactionsHandler).Methods("GET")
+	router.HandleFunc("/payments/import", server.importPaymentsHandler).Methods("POST")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body)))
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	loanID := createdLoan.ID.String()
+	today := time.Now().Format("2006-01-02")
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	file := "loan_id,amount,date,reference\n" +
+		loanID + ",100.00," + today + ",BANK-1\n" +
+		loanID + ",100.00," + today + ",BANK-1\n" +
+		uuid.New().String() + ",50.00," + today + ",BANK-2\n" +
+		loanID + ",abc," + today + "\n" +
+		loanID + ",25.00," + tomorrow + "\n" +
+		loanID + ",25.00," + yesterday + "\n"
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/payments/import", bytes.NewBufferString(file)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+	var report models.PaymentImportReport
+	json.Unmarshal(rr.Body.Bytes(), &report)
+	if report.Applied != 1 || report.Failed != 5 || len(report.Rows) != 6 {
+		t.Fatalf("Expected 1 applied and 5 failed rows, got %+v", report)
+	}
+	if report.Rows[0].Line != 2 || report.Rows[0].TransactionID == nil {
+		t.Errorf("Expected line 2 applied, got %+v", report.Rows[0])
+	}
+	for i, want := range []string{"duplicate reference", "loan not found", "invalid amount", "payment date must not be in the future", "payment date is before the loan was opened"} {
+		if row := report.Rows[i+1]; !strings.HasPrefix(row.Error, want) {
+			t.Errorf("Expected line %d to fail with %q, got %q", row.Line, want, row.Error)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+loanID, nil))
+	var loan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &loan)
+	if !loan.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected a balance of 900, got %s", loan.Balance)
+	}
+	if loan.LastPaymentDate == nil || loan.LastPaymentDate.Format("2006-01-02") != today {
+		t.Errorf("Expected the last payment date to be %s, got %v", today, loan.LastPaymentDate)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+loanID+"/transactions", nil))
+	var transactions []models.Transaction
+	json.Unmarshal(rr.Body.Bytes(), &transactions)
+	payment := transactions[len(transactions)-1]
+	if payment.Source != models.TransactionSourceImport || payment.Reference != "BANK-1" {
+		t.Errorf("Expected an imported payment with reference BANK-1, got %+v", payment)
+	}
+}
+
+// failingReader returns err once its data has been read, as a body over its size limit does.
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestAPI_ImportPaymentsReadError(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/payments/import", server.importPaymentsHandler).Methods("POST")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	today := time.Now().Format("2006-01-02")
+	rows := func(file string) string {
+		return loan.ID.String() + ",100.00," + today + "," + file + "-1\n" +
+			loan.ID.String() + ",50.00," + today + "," + file + "-2\n"
+	}
+
+	// A file that breaks off after payments were posted still reports them
+	for name, body := range map[string]io.Reader{
+		"malformed": strings.NewReader(rows("A") + loan.ID.String() + `,"25.00,` + today + "\n" + loan.ID.String() + ",25.00," + today + ",A-4\n"),
+		"truncated": &failingReader{data: strings.NewReader(rows("B")), err: &http.MaxBytesError{Limit: 100}},
+	} {
+		loan, _ = server.ledger.GetLoan(ctx, loan.ID)
+		balance := loan.Balance
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/payments/import", body))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d. Body: %s", name, rr.Code, rr.Body.String())
+		}
+		var report models.PaymentImportReport
+		json.Unmarshal(rr.Body.Bytes(), &report)
+		if len(report.Rows) != 3 || report.Failed != 1 || report.Rows[2].Line != 3 || !strings.HasPrefix(report.Rows[2].Error, "invalid payment file") {
+			t.Errorf("%s: expected two rows then the read error on line 3, got %+v", name, report)
+		}
+		posted := decimal.Zero
+		for _, row := range report.Rows {
+			if row.TransactionID != nil {
+				amount, _ := decimal.NewFromString(row.Amount)
+				posted = posted.Add(amount)
+			}
+		}
+		if loan, _ = server.ledger.GetLoan(ctx, loan.ID); !loan.Balance.Equal(balance.Sub(posted)) {
+			t.Errorf("%s: expected the report to account for every payment posted, balance %s went to %s with %s reported", name, balance, loan.Balance, posted)
+		}
+	}
+
+	// A file unreadable from the start is refused
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/payments/import", strings.NewReader(`"`+loan.ID.String()+",1\n")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unreadable file, got %d", rr.Code)
+	}
+}
+
+func TestAPI_GraphQL(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}/payments", server.recordPaymentHandler).Methods("POST")
+	router.HandleFunc("/graphql", server.graphQLHandler).Methods("GET", "POST")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"customer_key":           "gql_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body)))
+	var createdLoan models.Loan
+	json.Unmarshal(rr.Body.Bytes(), &createdLoan)
+
+	body, _ = json.Marshal(map[string]interface{}{"amount": 100.0})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans/"+createdLoan.ID.String()+"/payments", bytes.NewBuffer(body)))
+
+	body, _ = json.Marshal(map[string]interface{}{
+		"query": `query Loan($id: ID!) {
+			loan(id: $id) {
+				balance
+				transactions { type amount }
+				statements { cycle }
+				customer { customer_key summary { loan_count } }
+			}
+			unknown: loan(id: "nope") { id }
+		}`,
+		"variables": map[string]interface{}{"id": createdLoan.ID.String()},
+	})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+
+	var result struct {
+		Data struct {
+			Loan struct {
+				Balance      decimal.Decimal `json:"balance"`
+				Transactions []struct {
+					Type   models.TransactionType `json:"type"`
+					Amount decimal.Decimal        `json:"amount"`
+				} `json:"transactions"`
+				Statements []map[string]any `json:"statements"`
+				Customer   struct {
+					CustomerKey string `json:"customer_key"`
+					Summary     struct {
+						LoanCount int `json:"loan_count"`
+					} `json:"summary"`
+				} `json:"customer"`
+			} `json:"loan"`
+			Unknown *models.Loan `json:"unknown"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+			Path    []any  `json:"path"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	loan := result.Data.Loan
+	if !loan.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected a balance of 900, got %s", loan.Balance)
+	}
+	if len(loan.Transactions) != 2 || loan.Transactions[1].Type != models.TransactionTypePayment || !loan.Transactions[1].Amount.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected the disbursement and payment, got %+v", loan.Transactions)
+	}
+	if loan.Statements == nil || len(loan.Statements) != 0 {
+		t.Errorf("Expected no statements yet, got %v", loan.Statements)
+	}
+	if loan.Customer.CustomerKey != "gql_cust" || loan.Customer.Summary.LoanCount != 1 {
+		t.Errorf("Expected the loan's customer with one loan, got %+v", loan.Customer)
+	}
+	if result.Data.Unknown != nil || len(result.Errors) != 1 || result.Errors[0].Message != "invalid loan ID" {
+		t.Errorf("Expected the invalid loan ID reported as an error, got %+v", result.Errors)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ customer(customer_key: "gql_cust") { loans { id } } }`), nil))
+	if !strings.Contains(rr.Body.String(), createdLoan.ID.String()) {
+		t.Errorf("Expected the customer's loan from a GET query, got %s", rr.Body.String())
+	}
+}
+
+func TestAPI_WebhookSubscriptions(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/webhooks/subscriptions", server.createWebhookSubscriptionHandler).Methods("POST")
+	router.HandleFunc("/webhooks/subscriptions/{id}", server.deleteWebhookSubscriptionHandler).Methods("DELETE")
+	router.HandleFunc("/webhooks/subscriptions/{id}/deliveries", server.listWebhookDeliveriesHandler).Methods("GET")
+
+	var received []*http.Request
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r)
+	}))
+	defer subscriber.Close()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks/subscriptions", strings.NewReader(`{"url": "`+subscriber.URL+`", "events": ["loan.bounced"]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown event, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks/subscriptions", strings.NewReader(`{"url": "`+subscriber.URL+`", "events": ["loan.created"]}`)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", rr.Code, rr.Body.String())
+	}
+	var subscription models.WebhookSubscription
+	json.Unmarshal(rr.Body.Bytes(), &subscription)
+	if subscription.Secret == "" {
+		t.Error("Expected the signing secret in the create response")
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"customer_key":           "test_cust",
+		"principal":              1000.0,
+		"base_interest_rate":     0.10,
+		"interest_rate_variance": 0.0,
+	})
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body)))
+
+	if published, err := server.ledger.RelayOutbox(ctx); err != nil || published != 1 {
+		t.Fatalf("Expected the loan.created event published, got %d (%v)", published, err)
+	}
+	if delivered, failed := server.ledger.DeliverWebhooks(ctx, newHTTPWebhookSender()); delivered != 1 || failed != 0 {
+		t.Fatalf("Expected 1 delivery, got %d delivered and %d failed", delivered, failed)
+	}
+	if len(received) != 1 || received[0].Header.Get("X-Webhook-Event") != "loan.created" || received[0].Header.Get("X-Webhook-Signature") == "" {
+		t.Fatalf("Expected a signed loan.created request, got %v", received)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/webhooks/subscriptions/"+subscription.ID.String()+"/deliveries", nil))
+	var deliveries []models.WebhookDelivery
+	json.Unmarshal(rr.Body.Bytes(), &deliveries)
+	if len(deliveries) != 1 || deliveries[0].Status != models.WebhookDeliveryDelivered || deliveries[0].Attempts != 1 {
+		t.Errorf("Expected one delivered delivery, got %+v", deliveries)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/webhooks/subscriptions/"+subscription.ID.String(), nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/webhooks/subscriptions/"+subscription.ID.String()+"/deliveries", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", rr.Code)
+	}
+}
+
+func TestAPI_EventStream(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/events/stream", server.eventStreamHandler).Methods("GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events/stream?loan_id=bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid loan ID, got %d", resp.StatusCode)
+	}
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	other, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	resp, err = http.Get(ts.URL + "/events/stream?types=transaction.created&loan_id=" + loan.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("Expected the connected comment, got %q", line)
+	}
+	reader.ReadString('\n')
+
+	server.ledger.RecordPayment(ctx, other.ID, decimal.NewFromInt(50))
+	server.ledger.RecordPayment(ctx, loan.ID, decimal.NewFromInt(1000))
+
+	var lines []string
+	for len(lines) < 3 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	if lines[1] != "event: transaction.created" {
+		t.Fatalf("Expected a transaction.created event, got %q", lines)
+	}
+	var event struct {
+		LoanID uuid.UUID          `json:"loan_id"`
+		Data   models.Transaction `json:"data"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event)
+	if event.LoanID != loan.ID || !event.Data.Amount.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected the 1000 payment on the filtered loan, got %+v", event)
+	}
+}
+
+func TestAPI_ProblemResponses(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", server.createLoanHandler).Methods("POST")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/payments", server.recordPaymentHandler).Methods("POST")
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"principal":              -5,
+		"base_interest_rate":     1.5,
+		"interest_rate_variance": 0.0,
+		"term_months":            -1,
+		"promo_end_date":         "soon",
+	})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans", bytes.NewBuffer(body)))
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("Expected a 400 problem response, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var validation problem
+	json.Unmarshal(rr.Body.Bytes(), &validation)
+	if validation.Code != "validation_failed" || validation.Status != http.StatusBadRequest || validation.Title != "Bad Request" {
+		t.Errorf("Unexpected problem %+v", validation)
+	}
+	fields := map[string]string{}
+	for _, fe := range validation.Errors {
+		fields[fe.Field] = fe.Code
+	}
+	expected := map[string]string{
+		"customer_key":       "required",
+		"principal":          "must_be_positive",
+		"base_interest_rate": "out_of_range",
+		"term_months":        "must_not_be_negative",
+		"promo_end_date":     "invalid_format",
+	}
+	for field, code := range expected {
+		if fields[field] != code {
+			t.Errorf("Expected %s to fail with %s, got %q", field, code, fields[field])
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+uuid.New().String(), nil))
+	var notFound problem
+	json.Unmarshal(rr.Body.Bytes(), &notFound)
+	if rr.Code != http.StatusNotFound || notFound.Code != "not_found" || notFound.Detail != "Loan not found" {
+		t.Errorf("Expected a not_found problem, got %d %+v", rr.Code, notFound)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/loans/"+uuid.New().String()+"/payments", strings.NewReader(`{"amount": "0"}`)))
+	var payment problem
+	json.Unmarshal(rr.Body.Bytes(), &payment)
+	if rr.Code != http.StatusBadRequest || len(payment.Errors) != 1 || payment.Errors[0].Field != "amount" {
+		t.Errorf("Expected the zero amount rejected, got %d %+v", rr.Code, payment)
+	}
+
+	// Server errors do not expose the underlying failure
+	server.storage.Close()
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+uuid.New().String(), nil))
+	var internal problem
+	json.Unmarshal(rr.Body.Bytes(), &internal)
+	if rr.Code != http.StatusInternalServerError || internal.Code != "internal_error" || strings.Contains(internal.Detail, "sql") {
+		t.Errorf("Expected an opaque internal_error problem, got %d %+v", rr.Code, internal)
+	}
+}
+
+func TestAPI_LoanETag(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", server.updateLoanHandler).Methods("PUT")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	path := "/loans/" + loan.ID.String()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	etag := rr.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("Expected ETag \"1\", got %q", etag)
+	}
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("Expected status 304 for a current ETag, got %d", rr.Code)
+	}
+
+	put := func(ifMatch string, customerKey string) *httptest.ResponseRecorder {
+		fetched, _ := server.ledger.GetLoan(ctx, loan.ID)
+		fetched.CustomerKey = customerKey
+		body, _ := json.Marshal(fetched)
+		req := httptest.NewRequest("PUT", path, bytes.NewBuffer(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := put("", "no_precondition"); rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("Expected status 428 without If-Match, got %d", rr.Code)
+	}
+	rr = put(etag, "first_writer")
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"2"` {
+		t.Fatalf("Expected the update to succeed with ETag \"2\", got %d %q. Body: %s", rr.Code, rr.Header().Get("ETag"), rr.Body.String())
+	}
+	rr = put(etag, "second_writer")
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status 412 for a stale ETag, got %d", rr.Code)
+	}
+	if current, _ := server.ledger.GetLoan(ctx, loan.ID); current.CustomerKey != "first_writer" {
+		t.Errorf("Expected the stale write rejected, got customer %s", current.CustomerKey)
+	}
+	if rr := put("*", "forced"); rr.Code != http.StatusOK {
+		t.Errorf("Expected If-Match: * to update unconditionally, got %d", rr.Code)
+	}
+}
+
+func TestAPI_UpdateLoanValidation(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}", server.updateLoanHandler).Methods("PUT")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	put := func(body map[string]any) *httptest.ResponseRecorder {
+		encoded, _ := json.Marshal(body)
+		req := httptest.NewRequest("PUT", "/loans/"+loan.ID.String(), bytes.NewBuffer(encoded))
+		req.Header.Set("If-Match", "*")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := put(map[string]any{"base_interest_rate": 1.5, "interest_rate_variance": -2, "term_months": -1, "status": "frozen"})
+	var validation problem
+	json.Unmarshal(rr.Body.Bytes(), &validation)
+	if rr.Code != http.StatusBadRequest || validation.Code != "validation_failed" || len(validation.Errors) != 4 {
+		t.Errorf("Expected every invalid field reported, got %d %+v", rr.Code, validation)
+	}
+
+	// A line of credit's terms are checked by the ledger as they are at creation
+	if rr := put(map[string]any{"credit_limit": 5000}); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a credit limit on an installment loan refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// The amounts owed are not editable, and fields left out keep their values
+	rr = put(map[string]any{"customer_key": "new_cust", "balance": 1, "accrued_interest": 50, "fees_due": 10, "interest_due": 5})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the update to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	stored, _ := server.ledger.GetLoan(ctx, loan.ID)
+	if stored.CustomerKey != "new_cust" || !stored.Balance.Equal(decimal.NewFromInt(1000)) || !stored.AccruedInterest.IsZero() ||
+		!stored.FeesDue.IsZero() || !stored.InterestDue.IsZero() || !stored.InterestRate.Equal(decimal.NewFromFloat(0.10)) {
+		t.Errorf("Expected only the customer changed, got %+v", stored)
+	}
+}
+
+func TestAPI_LoanLastModified(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	path := "/loans/" + loan.ID.String()
+
+	get := func(header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	lastModified := rr.Header().Get("Last-Modified")
+	if modified, err := http.ParseTime(lastModified); err != nil || !modified.Equal(loan.UpdatedAt.Truncate(time.Second)) {
+		t.Fatalf("Expected Last-Modified of %s, got %q", loan.UpdatedAt, lastModified)
+	}
+
+	if rr := get("If-Modified-Since", lastModified); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Errorf("Expected status 304 for the current Last-Modified, got %d", rr.Code)
+	}
+	earlier := loan.UpdatedAt.Add(-time.Hour).UTC().Format(http.TimeFormat)
+	if rr := get("If-Modified-Since", earlier); rr.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a loan modified since, got %d", rr.Code)
+	}
+	if rr := get("If-Modified-Since", "yesterday"); rr.Code != http.StatusOK {
+		t.Errorf("Expected an invalid date to be ignored, got %d", rr.Code)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("If-None-Match", `"99"`)
+	req.Header.Set("If-Modified-Since", lastModified)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected a stale ETag to override If-Modified-Since, got %d", rr.Code)
+	}
+}
+
+func TestWriteLedgerError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{models.ErrLoanNotFound, http.StatusNotFound},
+		{fmt.Errorf("failed to load statement: %w", models.ErrLoanNotFound), http.StatusNotFound},
+		{models.ErrLoanNotActive, http.StatusConflict},
+		{models.ErrLoanVersionMismatch, http.StatusPreconditionFailed},
+		{models.ErrInsufficientCredit, http.StatusUnprocessableEntity},
+		{fmt.Errorf("%w from active to closed", models.ErrInvalidStatusTransition), http.StatusConflict},
+		{models.Invalidf("invalid cycle"), http.StatusBadRequest},
+		{fmt.Errorf("invalid cycle"), http.StatusInternalServerError},
+		{fmt.Errorf("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		writeLedgerError(rr, tt.err)
+		if rr.Code != tt.want {
+			t.Errorf("%v: expected status %d, got %d", tt.err, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestAPI_Metrics(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.HandleFunc("/metrics", server.metricsHandler).Methods("GET")
+	router.Use(server.httpMetrics.middleware)
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	for _, id := range []string{loan.ID.String(), loan.ID.String(), "bad"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/loans/"+id, nil))
+	}
+
+	// Operators can add their own collectors to the server's registry
+	server.metrics.Register(metrics.NewGaugeFunc("custom_gauge", "An operator's collector.", func() float64 { return 42 }))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="/loans/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="/loans/{id}",status="400"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/loans/{id}"} 3`,
+		`custom_gauge 42`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in metrics, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestAPI_Tracing(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	exporter := &tracing.MemoryExporter{}
+	server.tracer = tracing.NewTracer(exporter, time.Hour)
+	defer server.tracer.Shutdown(context.Background())
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+	router.Use(server.traceRequests)
+
+	req := httptest.NewRequest("GET", "/loans/"+uuid.New().String(), nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	server.tracer.Flush()
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /loans/{id}" || span.Kind != tracing.SpanKindServer {
+		t.Errorf("Unexpected span %+v", span)
+	}
+	if span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID.String() != "00f067aa0ba902b7" {
+		t.Errorf("Expected the caller's trace to be continued, got %+v", span.SpanContext)
+	}
+	if span.Attributes["http.response.status_code"] != http.StatusNotFound {
+		t.Errorf("Expected status 404 to be recorded, got %v", span.Attributes["http.response.status_code"])
+	}
+}
+
+func TestAPI_EventStreamEndsOnShutdown(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/events/stream", server.eventStreamHandler).Methods("GET")
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if line, _ := reader.ReadString('\n'); line != ": connected\n" {
+		t.Fatalf("Expected the connected comment, got %q", line)
+	}
+
+	server.CloseEventStreams()
+
+	ended := make(chan struct{})
+	go func() {
+		for {
+			if _, err := reader.ReadString('\n'); err != nil {
+				close(ended)
+				return
+			}
+		}
+	}()
+	select {
+	case <-ended:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the stream to end at shutdown")
+	}
+}
+
+func TestAPI_RunJobs(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/jobs/daily-interest", server.runJobHandler(server.ledger.RunDailyInterest)).Methods("POST")
+	router.HandleFunc("/admin/jobs/monthly-interest", server.runJobHandler(server.ledger.RunMonthlyInterest)).Methods("POST")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	server.ledger.CreateLoan(ctx, "other_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/jobs/daily-interest?loan_id="+loan.ID.String(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var run models.JobRun
+	json.NewDecoder(rr.Body).Decode(&run)
+	if run.Job != models.JobDailyInterest || run.Processed != 1 || run.LoanID == nil || *run.LoanID != loan.ID {
+		t.Errorf("Expected a daily interest run over the loan, got %+v", run)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/jobs/monthly-interest", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+	for _, query := range []string{"loan_id=bad", "date=01/02/2024", "date=" + tomorrow} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/jobs/daily-interest?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/jobs/daily-interest?loan_id="+uuid.NewString(), nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown loan, got %d", rr.Code)
+	}
+}
+
+func TestAPI_JobHistory(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/jobs", server.listJobRunsHandler).Methods("GET")
+	router.HandleFunc("/admin/jobs/daily-interest", server.runJobHandler(server.ledger.RunDailyInterest)).Methods("POST")
+
+	server.ledger.CreateLoan(ctx, "test_cust", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	server.RunDailyBatch(nil, t.TempDir())
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/admin/jobs/daily-interest", nil))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/jobs?job=daily_interest", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var runs []models.JobRun
+	json.NewDecoder(rr.Body).Decode(&runs)
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 daily interest runs, got %d", len(runs))
+	}
+	// The manual rerun found the loan already accrued by the scheduled batch
+	if runs[0].Trigger != models.JobTriggerManual || runs[0].Skipped != 1 {
+		t.Errorf("Expected the manual run first, got %+v", runs[0])
+	}
+	if runs[1].Trigger != models.JobTriggerSchedule || runs[1].Processed != 1 {
+		t.Errorf("Expected the scheduled run to accrue the loan, got %+v", runs[1])
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/jobs", nil))
+	json.NewDecoder(rr.Body).Decode(&runs)
+	if len(runs) != 11 {
+		t.Errorf("Expected a run for every batch job plus the manual run, got %d", len(runs))
+	}
+
+	for _, query := range []string{"job=nightly", "limit=0"} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/jobs?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAPI_SearchLoans(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/search", server.searchLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}", server.getLoanHandler).Methods("GET")
+
+	small, _ := server.ledger.CreateLoan(ctx, "acme-1", decimal.NewFromInt(500), decimal.NewFromFloat(0.05), decimal.Zero)
+	large, _ := server.ledger.CreateLoan(ctx, "acme-2", decimal.NewFromInt(5000), decimal.NewFromFloat(0.09), decimal.Zero)
+	server.ledger.CreateLoan(ctx, "bolt-1", decimal.NewFromInt(5000), decimal.NewFromFloat(0.09), decimal.Zero)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/search?customer_key_prefix=acme&status=active,delinquent&min_balance=1000&min_rate=0.08&max_rate=0.1&created_from="+today+"&created_to="+today, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var page models.LoanPage
+	json.NewDecoder(rr.Body).Decode(&page)
+	if page.Total != 1 || len(page.Loans) != 1 || page.Loans[0].ID != large.ID {
+		t.Errorf("Expected only loan %s, got %d loans", large.ID, page.Total)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/search?max_balance=1000", nil))
+	json.NewDecoder(rr.Body).Decode(&page)
+	if page.Total != 1 || page.Loans[0].ID != small.ID {
+		t.Errorf("Expected only loan %s, got %d loans", small.ID, page.Total)
+	}
+
+	for _, query := range []string{"min_balance=abc", "status=open", "min_rate=0.2&max_rate=0.1", "created_to=yesterday", "limit=5000"} {
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/search?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestAPI_ExportCSV(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/export", server.exportLoansHandler).Methods("GET")
+	router.HandleFunc("/loans/{id}/transactions/export", server.exportTransactionsHandler).Methods("GET")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "acme-1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	server.ledger.CreateLoan(ctx, "bolt-1", decimal.NewFromInt(2000), decimal.NewFromFloat(0.10), decimal.Zero)
+	server.ledger.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(150.5))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/export?customer_key_prefix=acme", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Expected text/csv, got %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename="loans.csv"` {
+		t.Errorf("Unexpected Content-Disposition %q", cd)
+	}
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(rows) != 2 || rows[1][0] != loan.ID.String() || rows[1][6] != "849.50" {
+		t.Errorf("Expected the header and loan %s with balance 849.50, got %v", loan.ID, rows)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+loan.ID.String()+"/transactions/export?type=payment", nil))
+	rows, _ = csv.NewReader(rr.Body).ReadAll()
+	if len(rows) != 2 || rows[1][3] != "payment" || rows[1][4] != "150.50" {
+		t.Errorf("Expected the header and the 150.50 payment, got %v", rows)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+uuid.NewString()+"/transactions/export", nil))
+	if rr.Code != http.StatusNotFound || rr.Header().Get("Content-Disposition") != "" {
+		t.Errorf("Expected a 404 problem for an unknown loan, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/export?status=open", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid status, got %d", rr.Code)
+	}
+}
+
+func TestAPI_StatementPDF(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/statements/{statementId}.pdf", server.statementPDFHandler).Methods("GET")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "acme-1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	now := time.Now().UTC()
+	statement := &models.Statement{
+		ID:            uuid.New(),
+		LoanID:        loan.ID,
+		Cycle:         now.Format("2006-01"),
+		PeriodStart:   now.Truncate(24 * time.Hour),
+		StatementDate: now.Truncate(24 * time.Hour),
+		Balance:       decimal.NewFromInt(1000),
+		MinimumDue:    decimal.NewFromInt(25),
+		DueDate:       now.AddDate(0, 0, 25),
+		CreatedAt:     now,
+	}
+	if err := server.storage.CreateStatement(ctx, statement); err != nil {
+		t.Fatalf("Failed to create statement: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+loan.ID.String()+"/statements/"+statement.ID.String()+".pdf", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("Expected application/pdf, got %q", ct)
+	}
+	if !strings.HasPrefix(rr.Body.String(), "%PDF-") {
+		t.Errorf("Expected a PDF body")
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+loan.ID.String()+"/statements/"+uuid.NewString()+".pdf", nil))
+	if rr.Code != http.StatusNotFound || rr.Header().Get("Content-Disposition") != "" {
+		t.Errorf("Expected a 404 problem for an unknown statement, got %d", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/loans/"+loan.ID.String()+"/statements/latest.pdf", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid statement ID, got %d", rr.Code)
+	}
+}
+
+func TestAPI_ExportOFXAndQIF(t *testing.T) {
+	ctx := context.Background()
+
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans/{id}/transactions/export", server.exportTransactionsHandler).Methods("GET")
+
+	loan, _ := server.ledger.CreateLoan(ctx, "acme-1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	payment, _ := server.ledger.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(150.5))
+	path := "/loans/" + loan.ID.String() + "/transactions/export"
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?format=ofx", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ofx" {
+		t.Errorf("Expected application/x-ofx, got %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); !strings.HasSuffix(cd, `-transactions.ofx"`) {
+		t.Errorf("Unexpected Content-Disposition %q", cd)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{
+		"<ACCTID>" + loan.ID.String() + "</ACCTID>",
+		"<FITID>" + payment.ID.String() + "</FITID>",
+		"<TRNTYPE>PAYMENT</TRNTYPE>",
+		"<TRNAMT>150.50</TRNAMT>",
+		"<BALAMT>-849.50</BALAMT>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in OFX:\n%s", want, body)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?format=qif&type=payment", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "application/qif" {
+		t.Errorf("Expected application/qif, got %q", ct)
+	}
+	if body := rr.Body.String(); !strings.HasPrefix(body, "!Type:Oth L\n") || !strings.Contains(body, "T150.50\nPPayment\n") {
+		t.Errorf("Unexpected QIF:\n%s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", path+"?format=xlsx", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown format, got %d", rr.Code)
+	}
+}
+
+func TestAPI_Backup(t *testing.T) {
+	server, dbFile := setupTestServer(t)
+	defer os.Remove(dbFile)
+	defer server.storage.Close()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/admin/backup", server.backupHandler).Methods("POST")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/backup", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a backuper, got %d", rr.Code)
+	}
+
+	server.SetBackuper(server.storage.(store.Backuper))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/backup", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != sqliteContentType {
+		t.Errorf("Expected a SQLite download, got %q", ct)
+	}
+	if !strings.HasPrefix(rr.Body.String(), "SQLite format 3\x00") {
+		t.Errorf("Expected a SQLite database file, got %q", rr.Body.String()[:min(rr.Body.Len(), 16)])
+	}
+}
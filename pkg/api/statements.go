@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) listStatementsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	statements, err := s.ledger.GetStatements(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statements)
+}
+
+// statementPDFHandler serves GET /loans/{id}/statements/{statementId}.pdf, a statement
+// rendered for mailing or download.
+func (s *Server) statementPDFHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	loanID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+	statementID, err := uuid.Parse(vars["statementId"])
+	if err != nil {
+		writeError(w, "Invalid statement ID", http.StatusBadRequest)
+		return
+	}
+
+	streamDownload(w, r, "statement-"+statementID.String()+".pdf", pdfContentType, func(out io.Writer) error {
+		return s.ledger.RenderStatementPDF(r.Context(), out, loanID, statementID)
+	})
+}
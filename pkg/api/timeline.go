@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+func (s *Server) getTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	timeline, err := s.ledger.GetTimeline(r.Context(), loanID)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timeline)
+}
+
+func (s *Server) addNoteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	loanID, err := uuid.Parse(idStr)
+	if err != nil {
+		writeError(w, "Invalid loan ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Author string `json:"author"`
+		Text   string `json:"text"`
+	}
+
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var v validator
+	v.required("text", req.Text)
+	if v.write(w) {
+		return
+	}
+	if caller, ok := principalFromContext(r.Context()); ok {
+		req.Author = caller.Subject // Authenticated notes are attributed to the token's subject
+	}
+
+	note, err := s.ledger.AddNote(r.Context(), loanID, req.Author, req.Text)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+)
+
+// traceRequests wraps every routed request in a server span named after its route, continuing
+// the caller's trace when the request carries a traceparent header. Handlers find the span in
+// the request context.
+func (s *Server) traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.tracer == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		route := routeTemplate(r)
+
+		ctx := r.Context()
+		if parent, ok := tracing.Extract(r.Header); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+		ctx, span := s.tracer.Start(ctx, r.Method+" "+route, tracing.SpanKindServer)
+		defer span.End()
+		span.SetAttribute("http.request.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("url.path", r.URL.Path)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		span.SetAttribute("http.response.status_code", recorder.status)
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetError(errors.New(http.StatusText(recorder.status)))
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiKeyHeader carries the integrator's API key. Requests without it are counted as anonymous.
+const (
+	apiKeyHeader = "X-API-Key"
+	anonymousKey = "anonymous"
+	overflowKey  = "other" // Clients past maxTrackedClients, counted together
+)
+
+// maxTrackedClients bounds how many clients are counted separately in a window, so requests
+// with made-up API keys cannot grow the tracker without limit.
+const maxTrackedClients = 10000
+
+// usageClient identifies the client a request is counted against: the authenticated subject
+// when there is one, and otherwise a truncated hash of the API key, so that keys are never
+// held, reported or logged.
+func usageClient(r *http.Request) string {
+	if p, ok := principalFromContext(r.Context()); ok {
+		return "user:" + p.Subject
+	}
+	key := r.Header.Get(apiKeyHeader)
+	if key == "" {
+		return anonymousKey
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:6])
+}
+
+// keyQuota is a soft daily limit for one client. Zero means unlimited.
+type keyQuota struct {
+	Requests  int `json:"requests"`
+	Mutations int `json:"mutations"`
+}
+
+// keyUsage is the traffic recorded for one client in the current window.
+type keyUsage struct {
+	Client        string         `json:"client"` // As returned by usageClient
+	Requests      int            `json:"requests"`
+	Mutations     int            `json:"mutations"`
+	Routes        map[string]int `json:"routes"` // Request counts per "METHOD /path/template"
+	Quota         keyQuota       `json:"quota"`
+	OverQuota     bool           `json:"over_quota"`
+	LastRequestAt time.Time      `json:"last_request_at"`
+}
+
+// usageTracker counts requests and mutations per client over a daily window and flags clients
+// that exceed their soft quota. Over-quota requests are still served; the response carries
+// quota headers so integrators can back off before hard limits are introduced.
+type usageTracker struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	usage       map[string]*keyUsage
+	quotas      map[string]keyQuota
+	now         func() time.Time
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{
+		usage:  make(map[string]*keyUsage),
+		quotas: make(map[string]keyQuota),
+		now:    time.Now,
+	}
+}
+
+// record counts a request and returns the client's usage after counting it.
+func (t *usageTracker) record(key string, route string, mutation bool) keyUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	if window := now.UTC().Truncate(24 * time.Hour); !window.Equal(t.windowStart) {
+		t.windowStart = window
+		t.usage = make(map[string]*keyUsage)
+	}
+
+	u, ok := t.usage[key]
+	if !ok && len(t.usage) >= maxTrackedClients {
+		key = overflowKey
+		u, ok = t.usage[key]
+	}
+	if !ok {
+		u = &keyUsage{Client: key, Routes: make(map[string]int)}
+		t.usage[key] = u
+	}
+	u.Requests++
+	if mutation {
+		u.Mutations++
+	}
+	u.Routes[route]++
+	u.LastRequestAt = now
+	u.Quota = t.quotas[key]
+	u.OverQuota = (u.Quota.Requests > 0 && u.Requests > u.Quota.Requests) ||
+		(u.Quota.Mutations > 0 && u.Mutations > u.Quota.Mutations)
+
+	snapshot := *u
+	return snapshot
+}
+
+func (t *usageTracker) setQuota(key string, quota keyQuota) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas[key] = quota
+}
+
+// report returns a copy of the current window's usage, heaviest users first.
+func (t *usageTracker) report() (time.Time, []keyUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	report := make([]keyUsage, 0, len(t.usage))
+	for _, u := range t.usage {
+		routes := make(map[string]int, len(u.Routes))
+		for route, count := range u.Routes {
+			routes[route] = count
+		}
+		snapshot := *u
+		snapshot.Routes = routes
+		report = append(report, snapshot)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Requests != report[j].Requests {
+			return report[i].Requests > report[j].Requests
+		}
+		return report[i].Client < report[j].Client
+	})
+	return t.windowStart, report
+}
+
+// middleware records every routed request against its client and sets soft quota headers.
+func (t *usageTracker) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := usageClient(r)
+		route := routeTemplate(r)
+		mutation := r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
+
+		u := t.record(key, r.Method+" "+route, mutation)
+		if u.Quota.Requests > 0 {
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(u.Quota.Requests))
+			w.Header().Set("X-Quota-Remaining", strconv.Itoa(max(u.Quota.Requests-u.Requests, 0)))
+		}
+		if u.OverQuota {
+			w.Header().Set("X-Quota-Exceeded", "true")
+			slog.Warn("Client is over its soft quota", "client", u.Client, "requests", u.Requests, "mutations", u.Mutations)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) usageReportHandler(w http.ResponseWriter, r *http.Request) {
+	windowStart, usage := s.usage.report()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		WindowStart time.Time  `json:"window_start"`
+		Keys        []keyUsage `json:"keys"`
+	}{windowStart, usage})
+}
+
+func (s *Server) setQuotaHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	var quota keyQuota
+	if !decodeJSON(w, r, &quota) {
+		return
+	}
+
+	if quota.Requests < 0 || quota.Mutations < 0 {
+		writeError(w, "Quotas must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	s.usage.setQuota(key, quota)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quota)
+}
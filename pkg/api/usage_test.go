@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// keyClient returns the client a request with the API key is counted against.
+func keyClient(key string) string {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(apiKeyHeader, key)
+	return usageClient(req)
+}
+
+func TestUsageTracker_CountsPerKeyAndFlagsQuota(t *testing.T) {
+	tracker := newUsageTracker()
+	tracker.setQuota(keyClient("integrator-a"), keyQuota{Requests: 2})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/loans", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET", "POST")
+	router.Use(tracker.middleware)
+
+	send := func(method, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/loans", nil)
+		if key != "" {
+			req.Header.Set(apiKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	send("GET", "integrator-a")
+	send("POST", "integrator-a")
+	rr := send("GET", "integrator-a")
+	send("GET", "")
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected over-quota request to still be served, got %d", rr.Code)
+	}
+	if rr.Header().Get("X-Quota-Exceeded") != "true" {
+		t.Error("Expected X-Quota-Exceeded header on over-quota request")
+	}
+
+	_, report := tracker.report()
+	if len(report) != 2 {
+		t.Fatalf("Expected usage for 2 keys, got %d", len(report))
+	}
+	top := report[0]
+	if top.Client != keyClient("integrator-a") || top.Requests != 3 || top.Mutations != 1 || !top.OverQuota {
+		t.Errorf("Unexpected usage for integrator-a: %+v", top)
+	}
+	if top.Routes["GET /loans"] != 2 {
+		t.Errorf("Expected 2 GET /loans requests, got %d", top.Routes["GET /loans"])
+	}
+	if report[1].Client != anonymousKey {
+		t.Errorf("Expected anonymous usage, got %s", report[1].Client)
+	}
+}
+
+func TestUsageClient(t *testing.T) {
+	client := keyClient("sk_live_secret")
+	if strings.Contains(client, "sk_live_secret") || !strings.HasPrefix(client, "key:") || len(client) != len("key:")+12 {
+		t.Errorf("Expected a truncated hash of the API key, got %q", client)
+	}
+	if keyClient("sk_live_other") == client {
+		t.Error("Expected different keys to be counted apart")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(apiKeyHeader, "sk_live_secret")
+	req = req.WithContext(context.WithValue(req.Context(), principalKey{}, principal{Subject: "ada", Role: roleAdmin}))
+	if client := usageClient(req); client != "user:ada" {
+		t.Errorf("Expected the authenticated subject, got %q", client)
+	}
+}
+
+func TestUsageTracker_BoundsClients(t *testing.T) {
+	tracker := newUsageTracker()
+	for i := range maxTrackedClients + 5 {
+		tracker.record(keyClient(strconv.Itoa(i)), "GET /loans", false)
+	}
+	tracker.record(keyClient("0"), "GET /loans", false)
+
+	_, report := tracker.report()
+	if len(report) != maxTrackedClients+1 {
+		t.Fatalf("Expected %d clients plus the overflow, got %d", maxTrackedClients, len(report))
+	}
+	counts := make(map[string]int, len(report))
+	for _, u := range report {
+		counts[u.Client] = u.Requests
+	}
+	if counts[keyClient("0")] != 2 {
+		t.Errorf("Expected a tracked client to keep its own count, got %d", counts[keyClient("0")])
+	}
+	if counts[overflowKey] != 5 {
+		t.Errorf("Expected 5 requests from untracked clients counted together, got %d", counts[overflowKey])
+	}
+}
+
+func TestUsageTracker_ResetsDaily(t *testing.T) {
+	tracker := newUsageTracker()
+	now := time.Date(2025, 1, 1, 23, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	tracker.record("k", "GET /loans", false)
+	now = now.Add(2 * time.Hour)
+	u := tracker.record("k", "GET /loans", false)
+
+	if u.Requests != 1 {
+		t.Errorf("Expected counts to reset in a new day, got %d requests", u.Requests)
+	}
+}
+
+func TestAPI_UsageReport(t *testing.T) {
+	server := &Server{usage: newUsageTracker()}
+	server.usage.record(keyClient("integrator-b"), "GET /loans", false)
+
+	req := httptest.NewRequest("GET", "/admin/usage", nil)
+	rr := httptest.NewRecorder()
+	server.usageReportHandler(rr, req)
+
+	var body struct {
+		Keys []keyUsage `json:"keys"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &body)
+	if len(body.Keys) != 1 || body.Keys[0].Client != keyClient("integrator-b") || strings.Contains(rr.Body.String(), "integrator-b") {
+		t.Errorf("Unexpected usage report: %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// defaultWebhookDeliveryLimit is how many recent deliveries are listed when no limit is given.
+const defaultWebhookDeliveryLimit = 50
+
+// httpWebhookSender POSTs webhook deliveries to subscriber URLs.
+type httpWebhookSender struct {
+	client *http.Client
+}
+
+func newHTTPWebhookSender() *httpWebhookSender {
+	return &httpWebhookSender{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Send POSTs the body and treats any 2xx response as acknowledged.
+func (s *httpWebhookSender) Send(url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Server) createWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string                    `json:"url"`
+		Events []models.WebhookEventType `json:"events"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	subscription, err := s.ledger.CreateWebhookSubscription(r.Context(), req.URL, req.Events)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(subscription)
+}
+
+func (s *Server) listWebhookSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := s.ledger.GetWebhookSubscriptions(r.Context())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if subscriptions == nil {
+		subscriptions = []*models.WebhookSubscription{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(subscriptions)
+}
+
+func (s *Server) deleteWebhookSubscriptionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid webhook subscription ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.ledger.DeleteWebhookSubscription(r.Context(), id); err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, "Invalid webhook subscription ID", http.StatusBadRequest)
+		return
+	}
+	limit := defaultWebhookDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	deliveries, err := s.ledger.GetWebhookDeliveries(r.Context(), id, limit)
+	if err != nil {
+		writeLedgerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,371 @@
+// Package client is a typed Go client for the fredLoan HTTP API, so services can create and
+// list loans, post payments, read transactions and run jobs without building requests and
+// decoding responses by hand.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// Default retry policy used by NewClient.
+const (
+	DefaultMaxRetries = 3
+	DefaultRetryDelay = 200 * time.Millisecond
+)
+
+// Client calls the fredLoan API. Requests that are safe to repeat, reads and payments sent
+// with an Idempotency-Key, are retried after network errors and 429, 502, 503 and 504
+// responses, waiting RetryDelay before the first retry and twice as long before each one after.
+type Client struct {
+	BaseURL    string // e.g. https://loans.internal:8080, without a trailing slash
+	Token      string // Bearer token sent with every request when set
+	HTTPClient *http.Client
+	MaxRetries int
+	RetryDelay time.Duration
+}
+
+// NewClient creates a client for the API at baseURL with the default retry policy.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: DefaultMaxRetries,
+		RetryDelay: DefaultRetryDelay,
+	}
+}
+
+// Error is an error response from the API, decoded from its problem details.
+type Error struct {
+	StatusCode int          `json:"status"`
+	Title      string       `json:"title"`
+	Detail     string       `json:"detail"`
+	Code       string       `json:"code"` // Machine-readable, e.g. not_found or validation_failed
+	Errors     []FieldError `json:"errors"`
+}
+
+// FieldError describes one invalid field of a rejected request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("fredloan: %d %s", e.StatusCode, e.Title)
+	}
+	return fmt.Sprintf("fredloan: %d %s: %s", e.StatusCode, e.Title, e.Detail)
+}
+
+// CreateLoanRequest is the body of a new loan. Zero values are left to the server's defaults.
+type CreateLoanRequest struct {
+	CustomerKey             string              `json:"customer_key"`
+	Principal               decimal.Decimal     `json:"principal"`
+	BaseInterestRate        decimal.Decimal     `json:"base_interest_rate"`
+	InterestRateVariance    decimal.Decimal     `json:"interest_rate_variance"`
+	ProductCode             string              `json:"product_code,omitempty"`
+	TermMonths              int                 `json:"term_months,omitempty"`
+	AmortizationMonths      int                 `json:"amortization_months,omitempty"`
+	PrepaymentPenalty       decimal.Decimal     `json:"prepayment_penalty_rate"`
+	PrepaymentMonths        int                 `json:"prepayment_penalty_months,omitempty"`
+	IndexCode               string              `json:"index_code,omitempty"`
+	PromoRate               decimal.Decimal     `json:"promo_rate"`
+	PromoStartDate          string              `json:"promo_start_date,omitempty"` // YYYY-MM-DD, defaults to today
+	PromoEndDate            string              `json:"promo_end_date,omitempty"`   // YYYY-MM-DD, last day of the promo
+	LoanType                models.LoanType     `json:"loan_type,omitempty"`
+	CreditLimit             decimal.Decimal     `json:"credit_limit"`
+	Escrow                  bool                `json:"escrow,omitempty"`
+	AccrualGraceDays        int                 `json:"accrual_grace_days,omitempty"`
+	InterestMode            models.InterestMode `json:"interest_mode,omitempty"`
+	NegativeAmortizationCap decimal.Decimal     `json:"negative_amortization_cap"`
+	OriginationFee          decimal.Decimal     `json:"origination_fee"`
+	CapitalizeFee           bool                `json:"capitalize_origination_fee,omitempty"`
+}
+
+// Payment is a payment to record against a loan.
+type Payment struct {
+	Amount          decimal.Decimal   `json:"amount"`
+	EscrowAmount    decimal.Decimal   `json:"escrow_amount"` // Portion of the amount deposited into escrow
+	PaymentMethodID *uuid.UUID        `json:"payment_method_id,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"` // Free-form details such as the payment channel or operator ID
+
+	// IdempotencyKey makes the payment safe to repeat: a payment sent again with the same key
+	// is recorded once. A new key is used when it is empty, covering this call's own retries;
+	// set it to cover retries across calls, such as after a crash.
+	IdempotencyKey string `json:"-"`
+}
+
+// CreateLoan originates a loan. It is not retried, since a repeat would open a second loan.
+func (c *Client) CreateLoan(ctx context.Context, req CreateLoanRequest) (*models.Loan, error) {
+	var loan models.Loan
+	if err := c.do(ctx, http.MethodPost, "/loans", req, nil, false, &loan); err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// GetLoan retrieves a loan.
+func (c *Client) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	var loan models.Loan
+	if err := c.do(ctx, http.MethodGet, "/loans/"+id.String(), nil, nil, true, &loan); err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+// ListLoans retrieves a page of the loans matching the query.
+func (c *Client) ListLoans(ctx context.Context, query models.LoanQuery) (*models.LoanPage, error) {
+	params := url.Values{}
+	if query.Status != "" {
+		params.Set("status", string(query.Status))
+	}
+	if query.CustomerKey != "" {
+		params.Set("customer_key", query.CustomerKey)
+	}
+	if !query.MinBalance.IsZero() {
+		params.Set("min_balance", query.MinBalance.String())
+	}
+	if query.CreatedAfter != nil {
+		params.Set("created_after", query.CreatedAfter.Format("2006-01-02"))
+	}
+	if query.Sort != "" {
+		params.Set("sort", string(query.Sort))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset > 0 {
+		params.Set("offset", strconv.Itoa(query.Offset))
+	}
+	path := "/loans"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var page models.LoanPage
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, true, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// RecordPayment records a payment for a loan, or a recovery if it is charged off.
+func (c *Client) RecordPayment(ctx context.Context, loanID uuid.UUID, payment Payment) (*models.Transaction, error) {
+	key := payment.IdempotencyKey
+	if key == "" {
+		key = uuid.NewString()
+	}
+	header := http.Header{"Idempotency-Key": {key}}
+
+	var transaction models.Transaction
+	if err := c.do(ctx, http.MethodPost, "/loans/"+loanID.String()+"/payments", payment, header, true, &transaction); err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// ListTransactions retrieves a page of a loan's transactions matching the query, oldest
+// first, along with the number of matching transactions across all pages.
+func (c *Client) ListTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	params := url.Values{}
+	if len(query.Types) > 0 {
+		types := make([]string, len(query.Types))
+		for i, t := range query.Types {
+			types[i] = string(t)
+		}
+		params.Set("type", strings.Join(types, ","))
+	}
+	if query.From != nil {
+		params.Set("from", query.From.Format("2006-01-02"))
+	}
+	if query.To != nil {
+		params.Set("to", query.To.Format("2006-01-02"))
+	}
+	if query.MinAmount != nil {
+		params.Set("min_amount", query.MinAmount.String())
+	}
+	if query.MaxAmount != nil {
+		params.Set("max_amount", query.MaxAmount.String())
+	}
+	for _, key := range slices.Sorted(maps.Keys(query.Metadata)) {
+		params.Add("metadata", key+":"+query.Metadata[key])
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Offset > 0 {
+		params.Set("offset", strconv.Itoa(query.Offset))
+	}
+	path := "/loans/" + loanID.String() + "/transactions"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	var transactions []*models.Transaction
+	resp, err := c.send(ctx, http.MethodGet, path, nil, nil, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(&transactions); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode transactions: %w", err)
+	}
+	total := len(transactions)
+	if v := resp.Header.Get("X-Total-Count"); v != "" {
+		if total, err = strconv.Atoi(v); err != nil {
+			return nil, 0, fmt.Errorf("invalid X-Total-Count %q: %w", v, err)
+		}
+	}
+	return transactions, total, nil
+}
+
+// RunJob runs a batch job at once, models.JobDailyInterest or models.JobMonthlyInterest, and
+// returns the record of the run. It is not retried; run it again to pick up anything missed.
+func (c *Client) RunJob(ctx context.Context, job models.JobName) (*models.JobRun, error) {
+	var run models.JobRun
+	path := "/admin/jobs/" + strings.ReplaceAll(string(job), "_", "-")
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, false, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// Backup downloads a consistent snapshot of the server's SQLite database into w. Only the
+// request is retried; a download cut short is an error, and w then holds part of a snapshot.
+func (c *Client) Backup(ctx context.Context, w io.Writer) error {
+	resp, err := c.send(ctx, http.MethodPost, "/admin/backup", nil, nil, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download backup: %w", err)
+	}
+	return nil
+}
+
+// do sends a request and decodes a successful JSON response into out.
+func (c *Client) do(ctx context.Context, method string, path string, body any, header http.Header, retry bool, out any) error {
+	resp, err := c.send(ctx, method, path, body, header, retry)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send makes a request, retrying it if allowed, and returns a successful response for the
+// caller to read and close. Error responses are returned as *Error.
+func (c *Client) send(ctx context.Context, method string, path string, body any, header http.Header, retry bool) (*http.Response, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode %s %s request: %w", method, path, err)
+		}
+	}
+
+	attempts := 1
+	if retry {
+		attempts += c.MaxRetries
+	}
+	delay := c.RetryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := c.attempt(ctx, method, path, payload, header)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+		if err == nil {
+			err = decodeError(resp)
+		}
+		if attempt == attempts || !retryable(ctx, err) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// attempt sends a request once.
+func (c *Client) attempt(ctx context.Context, method string, path string, payload []byte, header http.Header) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s %s request: %w", method, path, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	return resp, nil
+}
+
+// decodeError reads an error response and closes it. Bodies that are not problem details,
+// such as from a proxy, are reported by status alone.
+func decodeError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	apiErr := &Error{}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(apiErr)
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
+
+// retryable reports whether a failed attempt is worth repeating: a network error, or a
+// response saying the server is overloaded or briefly unavailable. Nothing is retried once
+// the context is done.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	apiErr, ok := err.(*Error)
+	if !ok {
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
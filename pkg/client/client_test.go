@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestClient(t *testing.T) {
+	loanID := uuid.New()
+	paymentAttempts := 0
+	var paymentKeys []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /loans", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req CreateLoanRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.Loan{ID: loanID, CustomerKey: req.CustomerKey, Principal: req.Principal})
+	})
+	mux.HandleFunc("GET /loans/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != loanID.String() {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"Loan not found","code":"not_found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(models.Loan{ID: loanID, Status: models.LoanStatusActive})
+	})
+	mux.HandleFunc("POST /loans/{id}/payments", func(w http.ResponseWriter, r *http.Request) {
+		paymentAttempts++
+		paymentKeys = append(paymentKeys, r.Header.Get("Idempotency-Key"))
+		if paymentAttempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payment Payment
+		json.NewDecoder(r.Body).Decode(&payment)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(models.Transaction{LoanID: loanID, Amount: payment.Amount, Type: models.TransactionTypePayment})
+	})
+	mux.HandleFunc("GET /loans/{id}/transactions", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("type") != "payment,fee" || query.Get("from") != "2024-01-01" || query.Get("limit") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Total-Count", "7")
+		json.NewEncoder(w).Encode([]models.Transaction{{LoanID: loanID, Type: models.TransactionTypePayment}})
+	})
+	mux.HandleFunc("GET /loans", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") != "active" || r.URL.Query().Get("limit") != "10" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(models.LoanPage{Loans: []*models.Loan{{ID: loanID}}, Total: 1, Limit: 10})
+	})
+	mux.HandleFunc("POST /admin/jobs/daily-interest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.JobRun{Job: models.JobDailyInterest, Trigger: models.JobTriggerManual})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	client := NewClient(srv.URL + "/")
+	client.Token = "secret"
+	client.RetryDelay = time.Millisecond
+	ctx := context.Background()
+
+	loan, err := client.CreateLoan(ctx, CreateLoanRequest{CustomerKey: "cust_a", Principal: decimal.NewFromInt(1000), BaseInterestRate: decimal.RequireFromString("0.1")})
+	if err != nil {
+		t.Fatalf("CreateLoan failed: %v", err)
+	}
+	if loan.ID != loanID || loan.CustomerKey != "cust_a" || !loan.Principal.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected the created loan, got %+v", loan)
+	}
+
+	if loan, err = client.GetLoan(ctx, loanID); err != nil || loan.Status != models.LoanStatusActive {
+		t.Errorf("Expected the active loan, got %+v (%v)", loan, err)
+	}
+	_, err = client.GetLoan(ctx, uuid.New())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "not_found" || apiErr.Detail != "Loan not found" {
+		t.Errorf("Expected a not_found Error, got %v", err)
+	}
+
+	// A payment is retried after a 503 with the same idempotency key
+	tx, err := client.RecordPayment(ctx, loanID, Payment{Amount: decimal.NewFromInt(100)})
+	if err != nil {
+		t.Fatalf("RecordPayment failed: %v", err)
+	}
+	if !tx.Amount.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected a payment of 100, got %s", tx.Amount)
+	}
+	if len(paymentKeys) != 2 || paymentKeys[0] == "" || paymentKeys[0] != paymentKeys[1] {
+		t.Errorf("Expected two attempts with the same idempotency key, got %q", paymentKeys)
+	}
+
+	page, err := client.ListLoans(ctx, models.LoanQuery{Status: models.LoanStatusActive, Limit: 10})
+	if err != nil || page.Total != 1 || page.Loans[0].ID != loanID {
+		t.Errorf("Expected a page with the loan, got %+v (%v)", page, err)
+	}
+	if run, err := client.RunJob(ctx, models.JobDailyInterest); err != nil || run.Job != models.JobDailyInterest {
+		t.Errorf("Expected a daily interest run, got %+v (%v)", run, err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	transactions, total, err := client.ListTransactions(ctx, loanID, models.TransactionQuery{
+		Types: []models.TransactionType{models.TransactionTypePayment, models.TransactionTypeFee},
+		From:  &from,
+		Limit: 1,
+	})
+	if err != nil {
+		t.Fatalf("ListTransactions failed: %v", err)
+	}
+	if len(transactions) != 1 || total != 7 {
+		t.Errorf("Expected 1 transaction of 7, got %d of %d", len(transactions), total)
+	}
+}
+
+func TestClientRetries(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL)
+	client.RetryDelay = time.Millisecond
+	client.MaxRetries = 2
+
+	_, err := client.GetLoan(context.Background(), uuid.New())
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 Error, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	// Loan creation is never repeated
+	attempts = 0
+	if _, err := client.CreateLoan(context.Background(), CreateLoanRequest{CustomerKey: "cust_a"}); err == nil || attempts != 1 {
+		t.Errorf("Expected one failed attempt, got %d (%v)", attempts, err)
+	}
+
+	// Retries stop when the context is done
+	attempts = 0
+	client.RetryDelay = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetLoan(ctx, uuid.New()); !errors.Is(err, context.DeadlineExceeded) || attempts != 1 {
+		t.Errorf("Expected the deadline to end the retries after one attempt, got %d (%v)", attempts, err)
+	}
+}
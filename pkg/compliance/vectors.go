@@ -0,0 +1,141 @@
+// Package compliance certifies the interest accrual engine against externally supplied
+// test vectors, the way auditors verify servicing systems.
+//
+// Vectors are read from CSV with a header row naming the columns:
+//
+//	scenario,day,balance,annual_rate,expected_interest,places
+//
+// Each row is one accrual day of a scenario. expected_interest is the interest the auditor
+// expects to accrue on balance for that day. places, if present and non-empty, is the
+// number of decimal places both values are rounded to before comparison; otherwise the
+// values must match exactly.
+package compliance
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/shopspring/decimal"
+)
+
+// exactMatch is the Places value for vectors compared without rounding.
+const exactMatch = -1
+
+var requiredColumns = []string{"scenario", "day", "balance", "annual_rate", "expected_interest"}
+
+// Vector is a single accrual-day scenario with the interest expected for it.
+type Vector struct {
+	Scenario         string
+	Day              int
+	Balance          decimal.Decimal
+	AnnualRate       decimal.Decimal
+	ExpectedInterest decimal.Decimal
+	Places           int32 // Decimal places compared, or -1 for an exact match
+	Line             int   // CSV line the vector was read from
+}
+
+// Mismatch records a vector whose computed interest differs from the expected value.
+type Mismatch struct {
+	Vector Vector
+	Actual decimal.Decimal
+}
+
+// Report summarizes a compliance run.
+type Report struct {
+	Total      int
+	Passed     int
+	Mismatches []Mismatch
+}
+
+// OK reports whether every vector matched.
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// LoadVectors parses test vectors from CSV.
+func LoadVectors(r io.Reader) ([]Vector, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing required column %q", name)
+		}
+	}
+	placesCol, hasPlaces := index["places"]
+
+	var vectors []Vector
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		v := Vector{Scenario: record[index["scenario"]], Places: exactMatch, Line: line}
+		if v.Day, err = strconv.Atoi(record[index["day"]]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid day: %w", line, err)
+		}
+		if v.Balance, err = decimal.NewFromString(record[index["balance"]]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid balance: %w", line, err)
+		}
+		if v.AnnualRate, err = decimal.NewFromString(record[index["annual_rate"]]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid annual_rate: %w", line, err)
+		}
+		if v.ExpectedInterest, err = decimal.NewFromString(record[index["expected_interest"]]); err != nil {
+			return nil, fmt.Errorf("line %d: invalid expected_interest: %w", line, err)
+		}
+		if hasPlaces && record[placesCol] != "" {
+			places, err := strconv.ParseInt(record[placesCol], 10, 32)
+			if err != nil || places < 0 {
+				return nil, fmt.Errorf("line %d: invalid places %q", line, record[placesCol])
+			}
+			v.Places = int32(places)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+// Run computes each vector's daily interest with the ledger's accrual engine and reports
+// the vectors that do not match.
+func Run(vectors []Vector) *Report {
+	report := &Report{Total: len(vectors)}
+	for _, v := range vectors {
+		actual := ledger.DailyInterest(v.Balance, v.AnnualRate)
+		expected := v.ExpectedInterest
+		if v.Places != exactMatch {
+			actual = actual.Round(v.Places)
+			expected = expected.Round(v.Places)
+		}
+		if actual.Equal(expected) {
+			report.Passed++
+			continue
+		}
+		report.Mismatches = append(report.Mismatches, Mismatch{Vector: v, Actual: actual})
+	}
+	return report
+}
+
+// Write prints the report in a form suitable for an audit record.
+func (r *Report) Write(w io.Writer) {
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(w, "MISMATCH line %d: scenario %s day %d balance %s rate %s: expected %s, got %s\n",
+			m.Vector.Line, m.Vector.Scenario, m.Vector.Day, m.Vector.Balance, m.Vector.AnnualRate, m.Vector.ExpectedInterest, m.Actual)
+	}
+	fmt.Fprintf(w, "%d of %d vectors passed, %d mismatches\n", r.Passed, r.Total, len(r.Mismatches))
+}
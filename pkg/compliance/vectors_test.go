@@ -0,0 +1,55 @@
+package compliance
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAccrualVectors(t *testing.T) {
+	f, err := os.Open("testdata/accrual_vectors.csv")
+	if err != nil {
+		t.Fatalf("Failed to open vectors: %v", err)
+	}
+	defer f.Close()
+
+	vectors, err := LoadVectors(f)
+	if err != nil {
+		t.Fatalf("Failed to load vectors: %v", err)
+	}
+	if len(vectors) == 0 {
+		t.Fatal("Expected vectors to be loaded")
+	}
+
+	report := Run(vectors)
+	if !report.OK() {
+		var out strings.Builder
+		report.Write(&out)
+		t.Errorf("Accrual engine failed compliance vectors:\n%s", out.String())
+	}
+}
+
+func TestRunReportsMismatches(t *testing.T) {
+	csv := "scenario,day,balance,annual_rate,expected_interest,places\n" +
+		"ok,1,1000,0.10,0.27,2\n" +
+		"wrong,1,1000,0.10,0.28,2\n" +
+		"exact,1,1000,0.0365,0.1,\n"
+	vectors, err := LoadVectors(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("Failed to load vectors: %v", err)
+	}
+
+	report := Run(vectors)
+	if report.Total != 3 || report.Passed != 2 {
+		t.Errorf("Expected 2 of 3 passed, got %d of %d", report.Passed, report.Total)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].Vector.Scenario != "wrong" || report.Mismatches[0].Vector.Line != 3 {
+		t.Errorf("Expected a single mismatch for scenario wrong on line 3, got %+v", report.Mismatches)
+	}
+}
+
+func TestLoadVectorsRequiresColumns(t *testing.T) {
+	if _, err := LoadVectors(strings.NewReader("scenario,balance\nx,1\n")); err == nil {
+		t.Error("Expected error for missing columns")
+	}
+}
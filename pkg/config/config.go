@@ -0,0 +1,479 @@
+// Package config loads the server's settings from an optional TOML file and environment
+// variables, which override the file, and validates them at startup.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the server's runtime configuration.
+type Config struct {
+	// ListenAddress is the host:port the HTTP server listens on.
+	ListenAddress string
+	// ShutdownTimeout bounds how long shutdown waits for in-flight requests to drain.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout and ReadTimeout bound how long a client may take to send a request's
+	// headers and the whole request, WriteTimeout how long the server has to handle a request
+	// and send the response, and IdleTimeout how long a keep-alive connection may sit idle.
+	// Streaming responses lift the write timeout.
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// RequestTimeout is the deadline each request's context gets; a request still running
+	// when it passes is answered with 503. It must be shorter than WriteTimeout.
+	RequestTimeout time.Duration
+	// TLSCertFile and TLSKeyFile are PEM files for serving HTTPS with a certificate of
+	// the operator's own.
+	TLSCertFile string
+	TLSKeyFile  string
+	// AutocertDomains, when set, serve HTTPS with certificates obtained from Let's Encrypt
+	// for these host names, cached in AutocertCacheDir. AutocertEmail is given to the CA
+	// for expiry notices.
+	AutocertDomains  []string
+	AutocertCacheDir string
+	AutocertEmail    string
+	// HTTPRedirectAddress, when set with TLS, is a plain HTTP listener that redirects to
+	// HTTPS and answers ACME HTTP-01 challenges.
+	HTTPRedirectAddress string
+	// GRPCListenAddress, when set, is the host:port the gRPC ledger service listens on, with
+	// the same TLS settings and token authentication as the HTTP server.
+	GRPCListenAddress string
+	// CORSAllowedOrigins are the browser origins allowed to call the API, such as
+	// https://console.example.com, https://*.example.com, or * for any. Empty disables CORS.
+	CORSAllowedOrigins []string
+	// CORSAllowedMethods and CORSAllowedHeaders are what cross-origin requests may use;
+	// CORSExposedHeaders are the response headers their scripts may read.
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+	CORSExposedHeaders []string
+	// CORSMaxAge is how long browsers may cache a preflight response.
+	CORSMaxAge time.Duration
+	// DatabaseDriver is the database the ledger is stored in: sqlite, postgres, mysql, bolt
+	// for a file that needs no cgo, or memory to keep it in memory until the process exits.
+	DatabaseDriver string
+	// DatabaseDSN is the data source: for SQLite a file path, or a file: URI with options; for
+	// PostgreSQL a postgres:// URL or key=value connection string; for MySQL and MariaDB a
+	// user:password@tcp(host:port)/database DSN; for Bolt a file path.
+	DatabaseDSN string
+	// DatabaseEncryptionKey, or the contents of DatabaseEncryptionKeyFile, is the passphrase
+	// a SQLite database is encrypted at rest with by SQLCipher. The file suits keys a secret
+	// manager or KMS agent decrypts and mounts for the server. Empty leaves the database in
+	// plaintext.
+	DatabaseEncryptionKey     string
+	DatabaseEncryptionKeyFile string
+	// DatabaseReadReplicaDSN, when set, is the data source of a PostgreSQL or MySQL read
+	// replica that serves the reporting queries instead of the primary.
+	DatabaseReadReplicaDSN string
+	// DatabaseMaxOpenConns caps the connections open to the database at once, 0 for no cap,
+	// and DatabaseMaxIdleConns how many are kept open idle for reuse.
+	DatabaseMaxOpenConns int
+	DatabaseMaxIdleConns int
+	// DatabaseSQLiteBusyTimeout is how long a SQLite connection waits for another's lock
+	// before failing, DatabaseSQLiteSynchronous how often SQLite waits for writes to reach the
+	// disk (off, normal, full or extra), and DatabaseSQLiteCacheSize each connection's page
+	// cache, in pages or, when negative, KiB; 0 keeps SQLite's default.
+	DatabaseSQLiteBusyTimeout time.Duration
+	DatabaseSQLiteSynchronous string
+	DatabaseSQLiteCacheSize   int
+	// DatabaseSQLiteBusyRetries is how many times a SQLite call turned away as busy is retried,
+	// with backoff, before its error is returned; 0 returns it at once.
+	DatabaseSQLiteBusyRetries int
+	// DatabaseSlowQueryThreshold, when set, logs a warning for every store call that takes at
+	// least this long, naming the method.
+	DatabaseSlowQueryThreshold time.Duration
+	// CacheRedisURL, when set, is a redis:// or rediss:// URL of a Redis server to cache
+	// loans and their transaction histories in, each entry for up to CacheTTL.
+	CacheRedisURL string
+	CacheTTL      time.Duration
+	// ArchiveExportURL, when set, is the S3 or S3-compatible bucket, and optional prefix, that
+	// the daily batch exports loans closed for longer than ArchiveExportAfterYears to, such as
+	// https://bucket.s3.eu-west-1.amazonaws.com/ledger or http://minio:9000/bucket/ledger.
+	// Requests are signed for ArchiveExportRegion with the access key. ArchiveExportPrune
+	// deletes exported loans from the database's archive.
+	ArchiveExportURL             string
+	ArchiveExportRegion          string
+	ArchiveExportAccessKeyID     string
+	ArchiveExportSecretAccessKey string
+	ArchiveExportAfterYears      int
+	ArchiveExportPrune           bool
+	// BatchInterval is how often the daily and monthly batch jobs run.
+	BatchInterval time.Duration
+	// WebhookInterval is how often queued webhook events are delivered.
+	WebhookInterval time.Duration
+	// LogLevel is the least severe level logged: debug, info, warn or error.
+	LogLevel slog.Level
+}
+
+// Default returns the settings used when neither the file nor the environment sets a value.
+func Default() Config {
+	return Config{
+		ListenAddress:             ":8080",
+		ShutdownTimeout:           30 * time.Second,
+		ReadHeaderTimeout:         10 * time.Second,
+		ReadTimeout:               time.Minute,
+		WriteTimeout:              time.Minute,
+		IdleTimeout:               2 * time.Minute,
+		RequestTimeout:            30 * time.Second,
+		AutocertCacheDir:          "autocert-cache",
+		CORSAllowedMethods:        []string{"GET", "POST", "PUT", "DELETE"},
+		CORSAllowedHeaders:        []string{"Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-Modified-Since", "If-None-Match", "X-API-Key", "traceparent"},
+		CORSExposedHeaders:        []string{"ETag", "Idempotent-Replayed", "Link", "WWW-Authenticate", "X-Quota-Limit", "X-Quota-Remaining", "X-Quota-Exceeded", "X-Total-Count"},
+		CORSMaxAge:                10 * time.Minute,
+		DatabaseDriver:            "sqlite",
+		DatabaseDSN:               "fredloan.db",
+		DatabaseMaxIdleConns:      2, // database/sql's default
+		DatabaseSQLiteBusyTimeout: 5 * time.Second,
+		DatabaseSQLiteSynchronous: "normal",
+		DatabaseSQLiteBusyRetries: 3,
+		CacheTTL:                  5 * time.Minute,
+		ArchiveExportRegion:       "us-east-1",
+		ArchiveExportAfterYears:   7,
+		BatchInterval:             10 * time.Second, // Simulates a day for testing
+		WebhookInterval:           5 * time.Second,
+		LogLevel:                  slog.LevelInfo,
+	}
+}
+
+// setting is one configuration value, named by its key in the file and its environment
+// variable.
+type setting struct {
+	key    string
+	env    string
+	target any // *string, *[]string, *int, *bool, *time.Duration or *slog.Level
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.listen_address", "LISTEN_ADDRESS", &c.ListenAddress},
+		{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", &c.ShutdownTimeout},
+		{"server.read_header_timeout", "READ_HEADER_TIMEOUT", &c.ReadHeaderTimeout},
+		{"server.read_timeout", "READ_TIMEOUT", &c.ReadTimeout},
+		{"server.write_timeout", "WRITE_TIMEOUT", &c.WriteTimeout},
+		{"server.idle_timeout", "IDLE_TIMEOUT", &c.IdleTimeout},
+		{"server.request_timeout", "REQUEST_TIMEOUT", &c.RequestTimeout},
+		{"server.tls_cert_file", "TLS_CERT_FILE", &c.TLSCertFile},
+		{"server.tls_key_file", "TLS_KEY_FILE", &c.TLSKeyFile},
+		{"server.autocert_domains", "AUTOCERT_DOMAINS", &c.AutocertDomains},
+		{"server.autocert_cache_dir", "AUTOCERT_CACHE_DIR", &c.AutocertCacheDir},
+		{"server.autocert_email", "AUTOCERT_EMAIL", &c.AutocertEmail},
+		{"server.http_redirect_address", "HTTP_REDIRECT_ADDRESS", &c.HTTPRedirectAddress},
+		{"server.grpc_listen_address", "GRPC_LISTEN_ADDRESS", &c.GRPCListenAddress},
+		{"cors.allowed_origins", "CORS_ALLOWED_ORIGINS", &c.CORSAllowedOrigins},
+		{"cors.allowed_methods", "CORS_ALLOWED_METHODS", &c.CORSAllowedMethods},
+		{"cors.allowed_headers", "CORS_ALLOWED_HEADERS", &c.CORSAllowedHeaders},
+		{"cors.exposed_headers", "CORS_EXPOSED_HEADERS", &c.CORSExposedHeaders},
+		{"cors.max_age", "CORS_MAX_AGE", &c.CORSMaxAge},
+		{"database.driver", "DATABASE_DRIVER", &c.DatabaseDriver},
+		{"database.dsn", "DATABASE_DSN", &c.DatabaseDSN},
+		{"database.encryption_key", "DATABASE_ENCRYPTION_KEY", &c.DatabaseEncryptionKey},
+		{"database.encryption_key_file", "DATABASE_ENCRYPTION_KEY_FILE", &c.DatabaseEncryptionKeyFile},
+		{"database.read_replica_dsn", "DATABASE_READ_REPLICA_DSN", &c.DatabaseReadReplicaDSN},
+		{"database.max_open_conns", "DATABASE_MAX_OPEN_CONNS", &c.DatabaseMaxOpenConns},
+		{"database.max_idle_conns", "DATABASE_MAX_IDLE_CONNS", &c.DatabaseMaxIdleConns},
+		{"database.sqlite_busy_timeout", "DATABASE_SQLITE_BUSY_TIMEOUT", &c.DatabaseSQLiteBusyTimeout},
+		{"database.sqlite_synchronous", "DATABASE_SQLITE_SYNCHRONOUS", &c.DatabaseSQLiteSynchronous},
+		{"database.sqlite_cache_size", "DATABASE_SQLITE_CACHE_SIZE", &c.DatabaseSQLiteCacheSize},
+		{"database.sqlite_busy_retries", "DATABASE_SQLITE_BUSY_RETRIES", &c.DatabaseSQLiteBusyRetries},
+		{"database.slow_query_threshold", "DATABASE_SLOW_QUERY_THRESHOLD", &c.DatabaseSlowQueryThreshold},
+		{"cache.redis_url", "CACHE_REDIS_URL", &c.CacheRedisURL},
+		{"cache.ttl", "CACHE_TTL", &c.CacheTTL},
+		{"archive.export_url", "ARCHIVE_EXPORT_URL", &c.ArchiveExportURL},
+		{"archive.export_region", "ARCHIVE_EXPORT_REGION", &c.ArchiveExportRegion},
+		{"archive.export_access_key_id", "ARCHIVE_EXPORT_ACCESS_KEY_ID", &c.ArchiveExportAccessKeyID},
+		{"archive.export_secret_access_key", "ARCHIVE_EXPORT_SECRET_ACCESS_KEY", &c.ArchiveExportSecretAccessKey},
+		{"archive.export_after_years", "ARCHIVE_EXPORT_AFTER_YEARS", &c.ArchiveExportAfterYears},
+		{"archive.export_prune", "ARCHIVE_EXPORT_PRUNE", &c.ArchiveExportPrune},
+		{"schedule.batch_interval", "BATCH_INTERVAL", &c.BatchInterval},
+		{"schedule.webhook_interval", "WEBHOOK_INTERVAL", &c.WebhookInterval},
+		{"log.level", "LOG_LEVEL", &c.LogLevel},
+	}
+}
+
+// Load reads the TOML file at path, if path is not empty, over the defaults, then applies the
+// environment variables read through getenv (os.Getenv when nil), and validates the result.
+// Every problem found is reported, not just the first.
+func Load(path string, getenv func(string) string) (Config, error) {
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	cfg := Default()
+	settings := cfg.settings()
+	var errs []error
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("could not read config file: %w", err)
+		}
+		values, err := parseTOML(string(data))
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", path, err)
+		}
+
+		known := make(map[string]setting, len(settings))
+		for _, s := range settings {
+			known[s.key] = s
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s, ok := known[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown setting %s", path, key))
+				continue
+			}
+			text, err := fileText(s.target, values[key])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s %w", path, key, err))
+				continue
+			}
+			if err := set(s.target, text); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := set(s.target, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		errs = cfg.validate()
+	}
+	return cfg, errors.Join(errs...)
+}
+
+// fileText returns a value from the file as the text set parses. Number and boolean settings
+// take TOML integers and booleans as well as strings; the rest take only strings.
+func fileText(target any, value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64:
+		if _, ok := target.(*int); ok {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case bool:
+		if _, ok := target.(*bool); ok {
+			return strconv.FormatBool(v), nil
+		}
+	}
+	switch target.(type) {
+	case *int:
+		return "", errors.New("must be an integer")
+	case *bool:
+		return "", errors.New("must be true or false")
+	}
+	return "", errors.New("must be a string")
+}
+
+// set parses text into the setting's target.
+func set(target any, text string) error {
+	switch t := target.(type) {
+	case *string:
+		*t = text
+	case *[]string: // Comma-separated
+		*t = nil
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*t = append(*t, item)
+			}
+		}
+	case *int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", text)
+		}
+		*t = n
+	case *bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q: expected true or false", text)
+		}
+		*t = b
+	case *time.Duration:
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", text)
+		}
+		*t = d
+	case *slog.Level:
+		switch strings.ToLower(text) {
+		case "debug":
+			*t = slog.LevelDebug
+		case "info":
+			*t = slog.LevelInfo
+		case "warn", "warning":
+			*t = slog.LevelWarn
+		case "error":
+			*t = slog.LevelError
+		default:
+			return fmt.Errorf("invalid log level %q: expected debug, info, warn or error", text)
+		}
+	}
+	return nil
+}
+
+func (c Config) validate() []error {
+	var errs []error
+	if _, port, err := net.SplitHostPort(c.ListenAddress); err != nil || port == "" {
+		errs = append(errs, fmt.Errorf("listen address %q must be host:port", c.ListenAddress))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS certificate and key files must be set together"))
+	}
+	if c.TLSCertFile != "" && len(c.AutocertDomains) > 0 {
+		errs = append(errs, errors.New("TLS certificate files and autocert domains cannot both be set"))
+	}
+	if len(c.AutocertDomains) > 0 && c.AutocertCacheDir == "" {
+		errs = append(errs, errors.New("autocert cache directory must not be empty"))
+	}
+	if c.HTTPRedirectAddress != "" {
+		if !c.TLSEnabled() {
+			errs = append(errs, errors.New("HTTP redirect address needs TLS to redirect to"))
+		} else if _, port, err := net.SplitHostPort(c.HTTPRedirectAddress); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("HTTP redirect address %q must be host:port", c.HTTPRedirectAddress))
+		}
+	}
+	if c.GRPCListenAddress != "" {
+		if _, port, err := net.SplitHostPort(c.GRPCListenAddress); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("gRPC listen address %q must be host:port", c.GRPCListenAddress))
+		}
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if !validOrigin(origin) {
+			errs = append(errs, fmt.Errorf("CORS origin %q must be *, or scheme://host[:port] with an optional *. subdomain wildcard", origin))
+		}
+	}
+	for _, method := range c.CORSAllowedMethods {
+		if method != strings.ToUpper(method) || strings.ContainsAny(method, " \t") {
+			errs = append(errs, fmt.Errorf("CORS method %q must be an upper-case HTTP method", method))
+		}
+	}
+	if c.CORSMaxAge < 0 {
+		errs = append(errs, errors.New("CORS max age must not be negative"))
+	}
+	if c.DatabaseDriver != "sqlite" && c.DatabaseDriver != "postgres" && c.DatabaseDriver != "mysql" && c.DatabaseDriver != "bolt" && c.DatabaseDriver != "memory" {
+		errs = append(errs, fmt.Errorf("database driver %q must be sqlite, postgres, mysql, bolt or memory", c.DatabaseDriver))
+	}
+	if c.DatabaseDriver != "memory" && strings.TrimSpace(c.DatabaseDSN) == "" {
+		errs = append(errs, errors.New("database DSN must not be empty"))
+	}
+	if c.DatabaseEncryptionKey != "" || c.DatabaseEncryptionKeyFile != "" {
+		if c.DatabaseEncryptionKey != "" && c.DatabaseEncryptionKeyFile != "" {
+			errs = append(errs, errors.New("database encryption key and key file cannot both be set"))
+		}
+		if c.DatabaseDriver != "sqlite" {
+			errs = append(errs, fmt.Errorf("database encryption is for sqlite; %s databases are encrypted by the database server", c.DatabaseDriver))
+		}
+	}
+	if c.DatabaseReadReplicaDSN != "" && c.DatabaseDriver != "postgres" && c.DatabaseDriver != "mysql" {
+		errs = append(errs, fmt.Errorf("database read replicas are for postgres and mysql, not %s", c.DatabaseDriver))
+	}
+	if c.DatabaseMaxOpenConns < 0 || c.DatabaseMaxIdleConns < 0 {
+		errs = append(errs, errors.New("database max open and idle connections must not be negative"))
+	} else if c.DatabaseMaxOpenConns > 0 && c.DatabaseMaxIdleConns > c.DatabaseMaxOpenConns {
+		errs = append(errs, fmt.Errorf("database max idle connections %d must not exceed the max open connections %d", c.DatabaseMaxIdleConns, c.DatabaseMaxOpenConns))
+	}
+	if c.DatabaseSQLiteBusyTimeout < 0 {
+		errs = append(errs, errors.New("SQLite busy timeout must not be negative"))
+	}
+	if c.DatabaseSQLiteBusyRetries < 0 {
+		errs = append(errs, errors.New("SQLite busy retries must not be negative"))
+	}
+	switch strings.ToLower(c.DatabaseSQLiteSynchronous) {
+	case "off", "normal", "full", "extra":
+	default:
+		errs = append(errs, fmt.Errorf("SQLite synchronous mode %q must be off, normal, full or extra", c.DatabaseSQLiteSynchronous))
+	}
+	if c.DatabaseSlowQueryThreshold < 0 {
+		errs = append(errs, errors.New("database slow query threshold must not be negative"))
+	}
+	if c.CacheRedisURL != "" {
+		if u, err := url.Parse(c.CacheRedisURL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			errs = append(errs, errors.New("cache Redis URL must be redis://host:port or rediss://host:port"))
+		}
+	}
+	if c.CacheTTL <= 0 {
+		errs = append(errs, errors.New("cache TTL must be positive"))
+	}
+	if c.ArchiveExportURL != "" {
+		if u, err := url.Parse(c.ArchiveExportURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			errs = append(errs, errors.New("archive export URL must be http:// or https:// with a host and no query"))
+		}
+		if c.ArchiveExportAccessKeyID == "" || c.ArchiveExportSecretAccessKey == "" {
+			errs = append(errs, errors.New("archive export needs an access key ID and secret access key"))
+		}
+		if c.ArchiveExportAfterYears < 1 {
+			errs = append(errs, errors.New("archive export age must be at least one year"))
+		}
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("shutdown timeout must be positive"))
+	}
+	if c.ReadHeaderTimeout <= 0 || c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("read header, read, write and idle timeouts must be positive"))
+	}
+	if c.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("request timeout must be positive"))
+	} else if c.RequestTimeout >= c.WriteTimeout {
+		errs = append(errs, fmt.Errorf("request timeout %s must be shorter than the write timeout %s", c.RequestTimeout, c.WriteTimeout))
+	}
+	if c.BatchInterval <= 0 {
+		errs = append(errs, errors.New("batch interval must be positive"))
+	}
+	if c.WebhookInterval <= 0 {
+		errs = append(errs, errors.New("webhook interval must be positive"))
+	}
+	return errs
+}
+
+// validOrigin reports whether an allowed origin is *, or a scheme and host with no path, whose
+// host may start with a *. wildcard for any subdomain.
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// DatabaseKey returns the key the database is encrypted with, reading it from the key file
+// if one is set, or "" when the database is not encrypted.
+func (c Config) DatabaseKey() (string, error) {
+	if c.DatabaseEncryptionKeyFile == "" {
+		return c.DatabaseEncryptionKey, nil
+	}
+	data, err := os.ReadFile(c.DatabaseEncryptionKeyFile)
+	if err != nil {
+		return "", fmt.Errorf("could not read database encryption key: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("database encryption key file %s is empty", c.DatabaseEncryptionKeyFile)
+	}
+	return key, nil
+}
+
+// TLSEnabled reports whether the server serves HTTPS, from certificate files or autocert.
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || len(c.AutocertDomains) > 0
+}
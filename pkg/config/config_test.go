@@ -0,0 +1,275 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fredloan.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+# Staging settings
+[server]
+listen_address = "127.0.0.1:9090" # Loopback only
+shutdown_timeout = "1m"
+
+[database]
+driver = "postgres"
+dsn = 'postgres://fredloan@db.staging:5432/fredloan'
+max_open_conns = 20
+max_idle_conns = 10
+
+[cache]
+redis_url = "redis://cache.staging:6379/0"
+
+[schedule]
+batch_interval = "24h"
+
+[archive]
+export_url = "https://ledger-archive.s3.eu-west-1.amazonaws.com/fredloan"
+export_region = "eu-west-1"
+export_access_key_id = "AKIAEXAMPLE"
+export_after_years = 10
+export_prune = true
+
+[cors]
+allowed_origins = "https://console.example.com, https://*.example.com"
+max_age = "1h"
+
+[log]
+level = "warn"
+`)
+	env := map[string]string{"BATCH_INTERVAL": "1h", "LOG_LEVEL": "debug", "AUTOCERT_DOMAINS": "loans.example.com, api.example.com,", "ARCHIVE_EXPORT_SECRET_ACCESS_KEY": "secret"}
+
+	cfg, err := Load(path, func(name string) string { return env[name] })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Default()
+	expected.ListenAddress = "127.0.0.1:9090"
+	expected.ShutdownTimeout = time.Minute
+	expected.AutocertDomains = []string{"loans.example.com", "api.example.com"}
+	expected.CORSAllowedOrigins = []string{"https://console.example.com", "https://*.example.com"}
+	expected.CORSMaxAge = time.Hour
+	expected.DatabaseDriver = "postgres"
+	expected.DatabaseDSN = "postgres://fredloan@db.staging:5432/fredloan"
+	expected.DatabaseMaxOpenConns = 20
+	expected.DatabaseMaxIdleConns = 10
+	expected.CacheRedisURL = "redis://cache.staging:6379/0"
+	expected.ArchiveExportURL = "https://ledger-archive.s3.eu-west-1.amazonaws.com/fredloan"
+	expected.ArchiveExportRegion = "eu-west-1"
+	expected.ArchiveExportAccessKeyID = "AKIAEXAMPLE"
+	expected.ArchiveExportSecretAccessKey = "secret"
+	expected.ArchiveExportAfterYears = 10
+	expected.ArchiveExportPrune = true
+	expected.BatchInterval = time.Hour // The environment overrides the file
+	expected.LogLevel = slog.LevelDebug
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cfg)
+	}
+
+	defaults, err := Load("", func(string) string { return "" })
+	if err != nil || !reflect.DeepEqual(defaults, Default()) {
+		t.Errorf("Expected the defaults without a file or environment, got %+v (%v)", defaults, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			name:     "unknown key and wrong type",
+			file:     "[server]\nlisten_adress = \":80\"\nshutdown_timeout = 30\n",
+			expected: []string{"unknown setting server.listen_adress", "server.shutdown_timeout must be a string"},
+		},
+		{
+			name:     "invalid values",
+			env:      map[string]string{"LISTEN_ADDRESS": "8080", "BATCH_INTERVAL": "daily", "WEBHOOK_INTERVAL": "-5s", "LOG_LEVEL": "verbose"},
+			expected: []string{"BATCH_INTERVAL: invalid duration", "LOG_LEVEL: invalid log level"},
+		},
+		{
+			name:     "validation",
+			env:      map[string]string{"LISTEN_ADDRESS": "8080", "GRPC_LISTEN_ADDRESS": "9090", "WEBHOOK_INTERVAL": "-5s", "DATABASE_DRIVER": "oracle", "DATABASE_DSN": " "},
+			expected: []string{"listen address \"8080\" must be host:port", "gRPC listen address \"9090\" must be host:port", "database driver \"oracle\" must be sqlite, postgres, mysql, bolt or memory", "database DSN must not be empty", "webhook interval must be positive"},
+		},
+		{
+			name:     "TLS",
+			env:      map[string]string{"TLS_CERT_FILE": "cert.pem", "AUTOCERT_DOMAINS": "loans.example.com", "AUTOCERT_CACHE_DIR": " ", "HTTP_REDIRECT_ADDRESS": "80"},
+			expected: []string{"certificate and key files must be set together", "cannot both be set", "HTTP redirect address \"80\" must be host:port"},
+		},
+		{
+			name:     "CORS",
+			env:      map[string]string{"CORS_ALLOWED_ORIGINS": "https://console.example.com/app,console.example.com", "CORS_ALLOWED_METHODS": "get", "CORS_MAX_AGE": "-1s"},
+			expected: []string{"CORS origin \"https://console.example.com/app\"", "CORS origin \"console.example.com\"", "CORS method \"get\"", "CORS max age must not be negative"},
+		},
+		{
+			name:     "timeouts",
+			env:      map[string]string{"IDLE_TIMEOUT": "0s", "REQUEST_TIMEOUT": "2m"},
+			expected: []string{"read header, read, write and idle timeouts must be positive", "request timeout 2m0s must be shorter than the write timeout 1m0s"},
+		},
+		{
+			name:     "cache",
+			env:      map[string]string{"CACHE_REDIS_URL": "cache.staging:6379", "CACHE_TTL": "0s"},
+			expected: []string{"cache Redis URL must be redis://host:port", "cache TTL must be positive"},
+		},
+		{
+			name:     "database encryption",
+			env:      map[string]string{"DATABASE_DRIVER": "postgres", "DATABASE_ENCRYPTION_KEY": "secret", "DATABASE_ENCRYPTION_KEY_FILE": "key.txt"},
+			expected: []string{"database encryption key and key file cannot both be set", "database encryption is for sqlite"},
+		},
+		{
+			name:     "database tuning",
+			env:      map[string]string{"DATABASE_MAX_OPEN_CONNS": "1", "DATABASE_MAX_IDLE_CONNS": "4", "DATABASE_SQLITE_BUSY_TIMEOUT": "-1s", "DATABASE_SQLITE_SYNCHRONOUS": "sometimes", "DATABASE_SQLITE_BUSY_RETRIES": "-1"},
+			expected: []string{"database max idle connections 4 must not exceed the max open connections 1", "SQLite busy timeout must not be negative", "SQLite busy retries must not be negative", "SQLite synchronous mode \"sometimes\" must be off, normal, full or extra"},
+		},
+		{
+			name:     "invalid connection count",
+			env:      map[string]string{"DATABASE_MAX_OPEN_CONNS": "many"},
+			expected: []string{"DATABASE_MAX_OPEN_CONNS: invalid number \"many\""},
+		},
+		{
+			name:     "negative slow query threshold",
+			env:      map[string]string{"DATABASE_SLOW_QUERY_THRESHOLD": "-1s"},
+			expected: []string{"database slow query threshold must not be negative"},
+		},
+		{
+			name:     "read replica",
+			env:      map[string]string{"DATABASE_READ_REPLICA_DSN": "replica.db"},
+			expected: []string{"database read replicas are for postgres and mysql, not sqlite"},
+		},
+		{
+			name:     "archive export types",
+			file:     "[archive]\nexport_prune = \"yes\"\nexport_after_years = 7.5\n",
+			expected: []string{"archive.export_prune: invalid boolean \"yes\"", "archive.export_after_years must be an integer"},
+		},
+		{
+			name:     "archive export",
+			env:      map[string]string{"ARCHIVE_EXPORT_URL": "s3://ledger-archive", "ARCHIVE_EXPORT_ACCESS_KEY_ID": "AKIAEXAMPLE"},
+			expected: []string{"archive export URL must be http:// or https://", "archive export needs an access key ID and secret access key"},
+		},
+		{
+			name:     "archive export age",
+			env:      map[string]string{"ARCHIVE_EXPORT_URL": "http://minio:9000/archive", "ARCHIVE_EXPORT_ACCESS_KEY_ID": "minio", "ARCHIVE_EXPORT_SECRET_ACCESS_KEY": "secret", "ARCHIVE_EXPORT_AFTER_YEARS": "0"},
+			expected: []string{"archive export age must be at least one year"},
+		},
+		{
+			name:     "redirect without TLS",
+			env:      map[string]string{"HTTP_REDIRECT_ADDRESS": ":80"},
+			expected: []string{"HTTP redirect address needs TLS"},
+		},
+		{
+			name:     "syntax",
+			file:     "[server]\nlisten_address = [\":80\"]\n",
+			expected: []string{"line 2: server.listen_address: arrays and inline tables are not supported"},
+		},
+		{
+			name:     "duplicate",
+			file:     "log.level = \"info\"\n[log]\nlevel = \"warn\"\n",
+			expected: []string{"line 3: log.level is set more than once"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = writeConfig(t, tt.file)
+			}
+			_, err := Load(path, func(name string) string { return tt.env[name] })
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected %q in %q", expected, err)
+				}
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.toml"), nil); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestParseTOML(t *testing.T) {
+	values, err := parseTOML(`
+title = "Loans # not a comment" # A comment
+path = 'C:\data\loans.db'
+escaped = "tab\there"
+count = 1_000
+rate = 0.05
+enabled = true
+[a.b]
+c = -1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"title":   "Loans # not a comment",
+		"path":    `C:\data\loans.db`,
+		"escaped": "tab\there",
+		"count":   int64(1000),
+		"rate":    0.05,
+		"enabled": true,
+		"a.b.c":   int64(-1),
+	}
+	if len(values) != len(expected) {
+		t.Errorf("Expected %d values, got %+v", len(expected), values)
+	}
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("Expected %s = %#v, got %#v", key, value, values[key])
+		}
+	}
+
+	for _, invalid := range []string{"novalue", "key = ", "[table", "[[array]]", "key = \"open", "bad key = 1", "key = nope", "key = \"\"\"multi\"\"\""} {
+		if _, err := parseTOML(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestDatabaseKey(t *testing.T) {
+	cfg := Default()
+	if key, err := cfg.DatabaseKey(); key != "" || err != nil {
+		t.Errorf("Expected no key by default, got %q (%v)", key, err)
+	}
+	cfg.DatabaseEncryptionKey = "secret"
+	if key, _ := cfg.DatabaseKey(); key != "secret" {
+		t.Errorf("Expected the configured key, got %q", key)
+	}
+
+	cfg.DatabaseEncryptionKey = ""
+	cfg.DatabaseEncryptionKeyFile = writeConfig(t, "from-kms\n")
+	if key, err := cfg.DatabaseKey(); key != "from-kms" || err != nil {
+		t.Errorf("Expected the key file's contents, got %q (%v)", key, err)
+	}
+	cfg.DatabaseEncryptionKeyFile = writeConfig(t, " \n")
+	if _, err := cfg.DatabaseKey(); err == nil {
+		t.Error("Expected an empty key file to be refused")
+	}
+	cfg.DatabaseEncryptionKeyFile = filepath.Join(t.TempDir(), "missing.key")
+	if _, err := cfg.DatabaseKey(); err == nil {
+		t.Error("Expected a missing key file to be refused")
+	}
+}
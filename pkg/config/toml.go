@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML reads the subset of TOML the configuration file needs: [table] headers, bare or
+// dotted keys, and string, integer, float and boolean values. Keys are returned with their
+// table prefix, e.g. "schedule.batch_interval". Arrays, inline tables and multi-line strings
+// are rejected.
+func parseTOML(data string) (map[string]any, error) {
+	values := make(map[string]any)
+	table := ""
+
+	for n, line := range strings.Split(data, "\n") {
+		lineNo := n + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table header %q", lineNo, line)
+			}
+			name, err := parseKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			table = name
+			continue
+		}
+
+		rawKey, rawValue, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key, err := parseKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if table != "" {
+			key = table + "." + key
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: %s is set more than once", lineNo, key)
+		}
+		value, err := parseValue(strings.TrimSpace(rawValue))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && c == '#':
+			return line[:i]
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++ // Skip the escaped character
+		case c == quote:
+			quote = 0
+		}
+	}
+	return line
+}
+
+// parseKey validates a bare or dotted key, such as schedule.batch_interval.
+func parseKey(raw string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" || strings.IndexFunc(part, func(r rune) bool {
+			return !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+		}) >= 0 {
+			return "", fmt.Errorf("invalid key %q", raw)
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func parseValue(raw string) (any, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(raw, `"""`), strings.HasPrefix(raw, "'''"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", raw)
+		}
+		return s, nil
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") || strings.Contains(raw[1:len(raw)-1], "'") {
+			return nil, fmt.Errorf("invalid literal string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw == "true" || raw == "false":
+		return raw == "true", nil
+	case strings.HasPrefix(raw, "["), strings.HasPrefix(raw, "{"):
+		return nil, fmt.Errorf("arrays and inline tables are not supported")
+	}
+
+	number := strings.ReplaceAll(raw, "_", "")
+	if i, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %s", raw)
+}
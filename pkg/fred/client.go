@@ -0,0 +1,94 @@
+// Package fred fetches benchmark interest rates from the Federal Reserve Bank of St. Louis
+// FRED API (https://fred.stlouisfed.org/docs/api/fred/).
+package fred
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultBaseURL is the FRED API endpoint used by NewClient.
+const DefaultBaseURL = "https://api.stlouisfed.org/fred"
+
+// observationLookback is how many recent observations are requested so that a rate can
+// still be found when the latest days are unpublished (FRED reports them as ".").
+const observationLookback = 10
+
+var hundred = decimal.NewFromInt(100)
+
+// Client retrieves series observations from FRED.
+type Client struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// NewClient creates a FRED client with the given API key.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		APIKey:     apiKey,
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Name identifies FRED as the source of the rates it returns.
+func (c *Client) Name() string {
+	return "fred"
+}
+
+type observationsResponse struct {
+	Observations []struct {
+		Date  string `json:"date"`
+		Value string `json:"value"`
+	} `json:"observations"`
+}
+
+// LatestRate returns the most recent published value of a FRED series (e.g. "SOFR" or
+// "DPRIME") along with its observation date. FRED publishes rates in percent; the value
+// is converted to a fraction to match loan pricing.
+func (c *Client) LatestRate(seriesID string) (decimal.Decimal, time.Time, error) {
+	params := url.Values{}
+	params.Set("series_id", seriesID)
+	params.Set("api_key", c.APIKey)
+	params.Set("file_type", "json")
+	params.Set("sort_order", "desc")
+	params.Set("limit", fmt.Sprint(observationLookback))
+
+	resp, err := c.HTTPClient.Get(c.BaseURL + "/series/observations?" + params.Encode())
+	if err != nil {
+		return decimal.Zero, time.Time{}, fmt.Errorf("failed to fetch series %s: %w", seriesID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decimal.Zero, time.Time{}, fmt.Errorf("failed to fetch series %s: unexpected status %s", seriesID, resp.Status)
+	}
+
+	var body observationsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return decimal.Zero, time.Time{}, fmt.Errorf("failed to decode series %s: %w", seriesID, err)
+	}
+
+	for _, obs := range body.Observations {
+		if obs.Value == "." {
+			continue // Not yet published for this date
+		}
+		value, err := decimal.NewFromString(obs.Value)
+		if err != nil {
+			return decimal.Zero, time.Time{}, fmt.Errorf("invalid value %q for series %s: %w", obs.Value, seriesID, err)
+		}
+		date, err := time.Parse("2006-01-02", obs.Date)
+		if err != nil {
+			return decimal.Zero, time.Time{}, fmt.Errorf("invalid date %q for series %s: %w", obs.Date, seriesID, err)
+		}
+		return value.Div(hundred), date, nil
+	}
+
+	return decimal.Zero, time.Time{}, fmt.Errorf("no observations published for series %s", seriesID)
+}
@@ -0,0 +1,38 @@
+package fred
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLatestRate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/series/observations" || r.URL.Query().Get("series_id") != "SOFR" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"observations":[{"date":"2024-06-04","value":"."},{"date":"2024-06-03","value":"5.31"}]}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient("key")
+	client.BaseURL = srv.URL
+
+	rate, date, err := client.LatestRate("SOFR")
+	if err != nil {
+		t.Fatalf("LatestRate failed: %v", err)
+	}
+	if !rate.Equal(decimal.RequireFromString("0.0531")) {
+		t.Errorf("Expected rate 0.0531, got %s", rate)
+	}
+	if date.Format("2006-01-02") != "2024-06-03" {
+		t.Errorf("Expected observation date 2024-06-03, got %s", date.Format("2006-01-02"))
+	}
+
+	if _, _, err := client.LatestRate("UNKNOWN"); err == nil {
+		t.Error("Expected error for unknown series")
+	}
+}
@@ -0,0 +1,461 @@
+// Package graphql executes GraphQL queries against a schema of resolver functions. It covers
+// what read-only API clients need: queries with arguments, variables, aliases, fragments and
+// the @include and @skip directives. Mutations, subscriptions and introspection beyond
+// __typename are not supported, and every field is nullable: a field whose resolver fails is
+// returned as null with an error, and the rest of the query still resolves.
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Schema is the entry point of queries.
+type Schema struct {
+	Query    *Object
+	MaxDepth int // Deepest nesting of fields allowed in a query; 0 is unlimited
+}
+
+// Object is an object type: a named set of fields.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type. A field with an Object returns that type, or a list of
+// it when List is set, and must be queried with a selection of subfields; a field without one
+// returns a scalar, encoded as JSON.
+type Field struct {
+	Object  *Object
+	List    bool
+	Resolve ResolveFunc // Nil reads the source's struct field or map entry with the same JSON name
+}
+
+// ResolveFunc computes a field's value.
+type ResolveFunc func(p ResolveParams) (any, error)
+
+// ResolveParams is the input of a resolver.
+type ResolveParams struct {
+	Context context.Context
+	Source  any            // Value of the parent field; nil for fields of Query
+	Args    map[string]any // Arguments, with variables substituted
+}
+
+// String returns a string argument, or "" if it is missing or not a string.
+func (p ResolveParams) String(name string) string {
+	s, _ := p.Args[name].(string)
+	return s
+}
+
+// Int returns an integer argument, or 0 if it is missing or not a whole number. Numbers from
+// variables arrive as float64 and are converted.
+func (p ResolveParams) Int(name string) (int, error) {
+	switch v := p.Args[name].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return v, nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("argument %q must be an integer", name)
+		}
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("argument %q must be an integer", name)
+	}
+}
+
+// Request is the body of a GraphQL HTTP request.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Result is the response to a GraphQL request.
+type Result struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is an error raised while parsing or executing a query.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"` // Response keys and list indexes leading to the field
+}
+
+// Location is a position in a query.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Execute parses and runs a query. Errors are reported in the result rather than returned, as
+// GraphQL responses carry them alongside whatever data could be resolved.
+func (s *Schema) Execute(ctx context.Context, req Request) *Result {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Result{Errors: []*Error{{Message: err.Error()}}}
+	}
+	if op.Type != "query" {
+		return &Result{Errors: []*Error{{Message: fmt.Sprintf("%s operations are not supported", op.Type)}}}
+	}
+
+	variables := map[string]any{}
+	for _, def := range op.Variables {
+		if v, ok := req.Variables[def.Name]; ok {
+			variables[def.Name] = v
+		} else if def.Default != nil {
+			variables[def.Name] = def.Default.Resolve(nil)
+		}
+	}
+
+	e := &executor{ctx: ctx, schema: s, fragments: doc.Fragments, variables: variables}
+	data := e.executeSelections(s.Query, nil, op.Selections, nil, 1)
+	return &Result{Data: data, Errors: e.errors}
+}
+
+// operation picks the operation to run from a document.
+func (d *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return d.Operations[0], nil
+	}
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type executor struct {
+	ctx       context.Context
+	schema    *Schema
+	fragments map[string]*Fragment
+	variables map[string]any
+	errors    []*Error
+}
+
+func (e *executor) fail(field *FieldNode, path []any, format string, args ...any) {
+	e.errors = append(e.errors, &Error{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: []Location{{Line: field.Line, Column: field.Column}},
+		Path:      append([]any{}, path...),
+	})
+}
+
+// executeSelections resolves the fields selected on an object.
+func (e *executor) executeSelections(object *Object, source any, selections []*Selection, path []any, depth int) *orderedMap {
+	result := &orderedMap{values: map[string]any{}}
+	for _, group := range e.collectFields(object, selections, map[string]bool{}) {
+		field := group[0]
+		key := field.ResponseKey()
+		fieldPath := append(path[:len(path):len(path)], key)
+
+		if field.Name == "__typename" {
+			result.set(key, object.Name)
+			continue
+		}
+		def, ok := object.Fields[field.Name]
+		if !ok {
+			e.fail(field, fieldPath, "cannot query field %q on type %q", field.Name, object.Name)
+			result.set(key, nil)
+			continue
+		}
+		if e.schema.MaxDepth > 0 && depth > e.schema.MaxDepth {
+			e.fail(field, fieldPath, "query is nested more than %d levels deep", e.schema.MaxDepth)
+			result.set(key, nil)
+			continue
+		}
+
+		args := make(map[string]any, len(field.Arguments))
+		for name, value := range field.Arguments {
+			args[name] = value.Resolve(e.variables)
+		}
+		var value any
+		var err error
+		if def.Resolve != nil {
+			value, err = def.Resolve(ResolveParams{Context: e.ctx, Source: source, Args: args})
+		} else {
+			value, err = defaultResolve(source, field.Name)
+		}
+		if err != nil {
+			e.fail(field, fieldPath, "%s", err.Error())
+			result.set(key, nil)
+			continue
+		}
+
+		// Fields selected more than once under the same key merge their subfields
+		var subselections []*Selection
+		for _, f := range group {
+			subselections = append(subselections, f.Selections...)
+		}
+		result.set(key, e.complete(def, field, value, subselections, fieldPath, depth))
+	}
+	return result
+}
+
+// complete shapes a resolved value for the response according to the field's type.
+func (e *executor) complete(def *Field, field *FieldNode, value any, selections []*Selection, path []any, depth int) any {
+	if isNil(value) {
+		if def.List && value != nil && reflect.ValueOf(value).Kind() == reflect.Slice {
+			return []any{} // A nil slice is an empty list, not a missing one
+		}
+		return nil
+	}
+	if def.Object == nil {
+		if len(selections) > 0 {
+			e.fail(field, path, "field %q is a scalar and cannot have a selection of subfields", field.Name)
+			return nil
+		}
+		return value
+	}
+	if len(selections) == 0 {
+		e.fail(field, path, "field %q of type %q must have a selection of subfields", field.Name, def.Object.Name)
+		return nil
+	}
+	if !def.List {
+		return e.executeSelections(def.Object, value, selections, path, depth+1)
+	}
+
+	items := reflect.ValueOf(value)
+	if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+		e.fail(field, path, "field %q did not resolve to a list", field.Name)
+		return nil
+	}
+	list := make([]any, items.Len())
+	for i := range list {
+		item := items.Index(i).Interface()
+		if isNil(item) {
+			continue
+		}
+		list[i] = e.executeSelections(def.Object, item, selections, append(path[:len(path):len(path)], i), depth+1)
+	}
+	return list
+}
+
+// collectFields flattens fragments into the fields they select, grouped by response key in
+// the order the keys first appear.
+func (e *executor) collectFields(object *Object, selections []*Selection, visited map[string]bool) [][]*FieldNode {
+	var groups [][]*FieldNode
+	index := map[string]int{}
+	add := func(field *FieldNode) {
+		key := field.ResponseKey()
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], field)
+			return
+		}
+		index[key] = len(groups)
+		groups = append(groups, []*FieldNode{field})
+	}
+
+	for _, selection := range selections {
+		if !e.included(selection.Directives) {
+			continue
+		}
+		switch {
+		case selection.Field != nil:
+			add(selection.Field)
+		case selection.InlineFragment != nil:
+			if fragmentApplies(selection.InlineFragment, object) {
+				for _, group := range e.collectFields(object, selection.InlineFragment.Selections, visited) {
+					for _, field := range group {
+						add(field)
+					}
+				}
+			}
+		default:
+			fragment, ok := e.fragments[selection.FragmentName]
+			if !ok || visited[fragment.Name] || !fragmentApplies(fragment, object) {
+				continue
+			}
+			visited[fragment.Name] = true
+			for _, group := range e.collectFields(object, fragment.Selections, visited) {
+				for _, field := range group {
+					add(field)
+				}
+			}
+		}
+	}
+	return groups
+}
+
+func fragmentApplies(fragment *Fragment, object *Object) bool {
+	return fragment.TypeCondition == "" || fragment.TypeCondition == object.Name
+}
+
+// included applies the @include and @skip directives.
+func (e *executor) included(directives []Directive) bool {
+	for _, d := range directives {
+		condition, _ := d.Arguments["if"].Resolve(e.variables).(bool)
+		if d.Name == "include" && !condition || d.Name == "skip" && condition {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultResolve reads the field of a struct, or the entry of a map, with the given JSON name.
+func defaultResolve(source any, name string) (any, error) {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		entry := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !entry.IsValid() {
+			return nil, nil
+		}
+		return entry.Interface(), nil
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if jsonName(t.Field(i)) == name {
+				return v.Field(i).Interface(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no value for field %q", name)
+}
+
+// jsonName is the name a struct field is encoded under by encoding/json, or "" if it is not
+// encoded.
+func jsonName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return field.Name
+	}
+	return name
+}
+
+func isNil(value any) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// ObjectFromStruct builds an object type with a field for each JSON-encoded field of a struct
+// type, resolved by reading the struct field. Nested structs and slices of structs become
+// object types of their own, named after their Go types; other values, including types with
+// their own JSON encoding such as times and decimals, are scalars. Fields can be added to the
+// returned object, or replaced, before it is used.
+func ObjectFromStruct(name string, sample any) *Object {
+	return objectFromType(name, reflect.TypeOf(sample), map[reflect.Type]*Object{})
+}
+
+func objectFromType(name string, t reflect.Type, seen map[reflect.Type]*Object) *Object {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if object, ok := seen[t]; ok {
+		return object
+	}
+	object := &Object{Name: name, Fields: map[string]*Field{}}
+	seen[t] = object
+
+	for i := 0; i < t.NumField(); i++ {
+		name := jsonName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		field := &Field{}
+		fieldType := t.Field(i).Type
+		if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() != reflect.Uint8 {
+			field.List = true
+			fieldType = fieldType.Elem()
+		}
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && !encodesItself(fieldType) {
+			field.Object = objectFromType(fieldType.Name(), fieldType, seen)
+		} else {
+			field.List = false // A list of scalars is itself a scalar
+		}
+		object.Fields[name] = field
+	}
+	return object
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*interface{ MarshalText() ([]byte, error) })(nil)).Elem()
+)
+
+func encodesItself(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// orderedMap is a response object, whose keys are encoded in the order they were selected.
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, value any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
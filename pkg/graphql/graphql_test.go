@@ -0,0 +1,157 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type testBook struct {
+	ID      string       `json:"id"`
+	Title   string       `json:"title"`
+	Authors []testAuthor `json:"authors"`
+	secret  string
+}
+
+type testAuthor struct {
+	Name string `json:"name"`
+}
+
+func testSchema() *Schema {
+	books := map[string]*testBook{
+		"1": {ID: "1", Title: "Loans", Authors: []testAuthor{{Name: "Ann"}, {Name: "Bo"}}},
+		"2": {ID: "2", Title: "Interest"},
+	}
+	book := ObjectFromStruct("Book", testBook{})
+	book.Fields["shout"] = &Field{Resolve: func(p ResolveParams) (any, error) {
+		return strings.ToUpper(p.Source.(*testBook).Title), nil
+	}}
+	query := &Object{Name: "Query", Fields: map[string]*Field{
+		"book": {Object: book, Resolve: func(p ResolveParams) (any, error) {
+			b, ok := books[p.String("id")]
+			if !ok {
+				return nil, fmt.Errorf("book not found")
+			}
+			return b, nil
+		}},
+		"books": {Object: book, List: true, Resolve: func(p ResolveParams) (any, error) {
+			limit, err := p.Int("limit")
+			if err != nil {
+				return nil, err
+			}
+			return []*testBook{books["1"], books["2"]}[:limit], nil
+		}},
+	}}
+	return &Schema{Query: query, MaxDepth: 3}
+}
+
+func execute(t *testing.T, req Request) (string, []*Error) {
+	t.Helper()
+	result := testSchema().Execute(context.Background(), req)
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		t.Fatalf("Failed to encode result: %v", err)
+	}
+	return string(data), result.Errors
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "nested selection in query order",
+			req:  Request{Query: `{ book(id: "1") { title id authors { name } } }`},
+			want: `{"book":{"title":"Loans","id":"1","authors":[{"name":"Ann"},{"name":"Bo"}]}}`,
+		},
+		{
+			name: "variables, aliases and resolvers",
+			req: Request{
+				Query:     `query Get($id: ID!, $n: Int = 1) { first: book(id: $id) { shout } books(limit: $n) { id } }`,
+				Variables: map[string]any{"id": "2"},
+			},
+			want: `{"first":{"shout":"INTEREST"},"books":[{"id":"1"}]}`,
+		},
+		{
+			name: "fragments and directives",
+			req: Request{
+				Query: `query($full: Boolean!) { book(id: "1") { ...Parts ... on Book { __typename } title @skip(if: true) } }
+					fragment Parts on Book { id authors @include(if: $full) { name } }`,
+				Variables: map[string]any{"full": false},
+			},
+			want: `{"book":{"id":"1","__typename":"Book"}}`,
+		},
+		{
+			name: "operation by name",
+			req: Request{
+				Query:         `query A { book(id: "1") { id } } query B { book(id: "2") { id } }`,
+				OperationName: "B",
+			},
+			want: `{"book":{"id":"2"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := execute(t, tt.req)
+			if len(errs) > 0 {
+				t.Fatalf("Unexpected errors: %v", errs[0])
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecute_FieldErrorsAreNull(t *testing.T) {
+	got, errs := execute(t, Request{Query: `{ missing: book(id: "9") { id } found: book(id: "1") { id nope secret } }`})
+	if got != `{"missing":null,"found":{"id":"1","nope":null,"secret":null}}` {
+		t.Errorf("Expected failed fields as null, got %s", got)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 errors, got %d", len(errs))
+	}
+	if errs[0].Message != "book not found" || fmt.Sprint(errs[0].Path) != "[missing]" || errs[0].Locations[0].Column != 3 {
+		t.Errorf("Expected the resolver error at missing, got %+v", errs[0])
+	}
+	if fmt.Sprint(errs[1].Path) != "[found nope]" {
+		t.Errorf("Expected an unknown field error at found.nope, got %+v", errs[1])
+	}
+}
+
+func TestExecute_RejectsInvalidRequests(t *testing.T) {
+	tests := map[string]string{
+		`{ book(id: "1") }`:                               "must have a selection of subfields",
+		`{ book(id: "1") { title { x } } }`:               "cannot have a selection of subfields",
+		`{ book(id: "1") { id `:                           "unexpected end of document",
+		`mutation { book { id } }`:                        "mutation operations are not supported",
+		`query A { book { id } } query B { book { id } }`: "operationName is required",
+		`{ books(limit: 1) { authors { name } } }`:        "",
+		`{ book(id: "1") { authors { name } } }`:          "",
+	}
+	for query, want := range tests {
+		schema := testSchema()
+		schema.MaxDepth = 3
+		result := schema.Execute(context.Background(), Request{Query: query})
+		if want == "" {
+			if len(result.Errors) != 0 {
+				t.Errorf("%s: unexpected error %v", query, result.Errors[0])
+			}
+			continue
+		}
+		if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Message, want) {
+			t.Errorf("%s: expected an error containing %q, got %v", query, want, result.Errors)
+		}
+	}
+
+	schema := testSchema()
+	schema.MaxDepth = 2
+	result := schema.Execute(context.Background(), Request{Query: `{ book(id: "1") { authors { name } } }`})
+	if len(result.Errors) == 0 || !strings.Contains(result.Errors[0].Message, "nested more than 2 levels") {
+		t.Errorf("Expected the depth limit to apply, got %v", result.Errors)
+	}
+}
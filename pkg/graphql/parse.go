@@ -0,0 +1,724 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Document is a parsed GraphQL request document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// Operation is a query, mutation or subscription in a document.
+type Operation struct {
+	Type       string // query, mutation or subscription
+	Name       string
+	Variables  []VariableDefinition
+	Selections []*Selection
+}
+
+// VariableDefinition declares a variable of an operation. Its type is not checked.
+type VariableDefinition struct {
+	Name    string
+	Default *Value // Nil when the variable has no default
+}
+
+// Fragment is a named fragment definition.
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []*Selection
+}
+
+// Selection is a field, a fragment spread or an inline fragment. Exactly one of Field,
+// FragmentName and InlineFragment is set.
+type Selection struct {
+	Field          *FieldNode
+	FragmentName   string
+	InlineFragment *Fragment // Unnamed; TypeCondition may be empty
+	Directives     []Directive
+}
+
+// FieldNode is a field selected in a query.
+type FieldNode struct {
+	Alias      string // Empty when the field is not aliased
+	Name       string
+	Arguments  map[string]Value
+	Selections []*Selection
+	Line       int
+	Column     int
+}
+
+// ResponseKey is the key the field's value is returned under.
+func (f *FieldNode) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Directive is a directive such as @include(if: $flag) on a selection.
+type Directive struct {
+	Name      string
+	Arguments map[string]Value
+}
+
+// ValueKind distinguishes the kinds of literal value.
+type ValueKind int
+
+const (
+	ValueNull ValueKind = iota
+	ValueInt
+	ValueFloat
+	ValueString
+	ValueBoolean
+	ValueEnum
+	ValueVariable
+	ValueList
+	ValueObject
+)
+
+// Value is an argument value or variable default, as written in the document.
+type Value struct {
+	Kind   ValueKind
+	Raw    string // Literal text, or the variable name without its $
+	List   []Value
+	Object map[string]Value
+}
+
+// Resolve converts the value to Go, substituting variables. Ints become int, floats float64,
+// enums their name as a string, lists []any and objects map[string]any.
+func (v Value) Resolve(variables map[string]any) any {
+	switch v.Kind {
+	case ValueInt:
+		n, _ := strconv.Atoi(v.Raw)
+		return n
+	case ValueFloat:
+		f, _ := strconv.ParseFloat(v.Raw, 64)
+		return f
+	case ValueString, ValueEnum:
+		return v.Raw
+	case ValueBoolean:
+		return v.Raw == "true"
+	case ValueVariable:
+		return variables[v.Raw]
+	case ValueList:
+		list := make([]any, len(v.List))
+		for i, item := range v.List {
+			list[i] = item.Resolve(variables)
+		}
+		return list
+	case ValueObject:
+		object := make(map[string]any, len(v.Object))
+		for name, item := range v.Object {
+			object[name] = item.Resolve(variables)
+		}
+		return object
+	default:
+		return nil
+	}
+}
+
+// Parse parses a GraphQL request document. Type system definitions are not accepted.
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: lexer{source: source, line: 1, lineStart: 0}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.token.kind != tokenEOF {
+		switch {
+		case p.token.is(tokenPunctuator, "{"):
+			selections, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: selections})
+		case p.token.is(tokenName, "query"), p.token.is(tokenName, "mutation"), p.token.is(tokenName, "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.token.is(tokenName, "fragment"):
+			fragment, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.Fragments[fragment.Name]; ok {
+				return nil, p.errorf("fragment %q is defined more than once", fragment.Name)
+			}
+			doc.Fragments[fragment.Name] = fragment
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lexer lexer
+	token token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at %d:%d: %s", p.token.line, p.token.column, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) unexpected() error {
+	if p.token.kind == tokenEOF {
+		return p.errorf("unexpected end of document")
+	}
+	return p.errorf("unexpected %q", p.token.value)
+}
+
+// expect consumes a punctuator, failing if the current token is something else.
+func (p *parser) expect(punctuator string) error {
+	if p.token.kind == tokenEOF {
+		return p.unexpected()
+	}
+	if !p.token.is(tokenPunctuator, punctuator) {
+		return p.errorf("expected %q, found %q", punctuator, p.token.value)
+	}
+	return p.advance()
+}
+
+// skip consumes a punctuator if it is the current token and reports whether it did.
+func (p *parser) skip(punctuator string) (bool, error) {
+	if !p.token.is(tokenPunctuator, punctuator) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) parseName() (string, error) {
+	if p.token.kind == tokenEOF {
+		return "", p.unexpected()
+	}
+	if p.token.kind != tokenName {
+		return "", p.errorf("expected a name, found %q", p.token.value)
+	}
+	name := p.token.value
+	return name, p.advance()
+}
+
+func (p *parser) parseOperation() (*Operation, error) {
+	op := &Operation{Type: p.token.value}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.token.kind == tokenName {
+		op.Name = p.token.value
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := p.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !p.token.is(tokenPunctuator, ")") {
+			def, err := p.parseVariableDefinition()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = append(op.Variables, def)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = selections
+	return op, nil
+}
+
+func (p *parser) parseVariableDefinition() (VariableDefinition, error) {
+	var def VariableDefinition
+	if err := p.expect("$"); err != nil {
+		return def, err
+	}
+	name, err := p.parseName()
+	if err != nil {
+		return def, err
+	}
+	def.Name = name
+	if err := p.expect(":"); err != nil {
+		return def, err
+	}
+	if err := p.parseType(); err != nil {
+		return def, err
+	}
+	if ok, err := p.skip("="); err != nil {
+		return def, err
+	} else if ok {
+		value, err := p.parseValue(true)
+		if err != nil {
+			return def, err
+		}
+		def.Default = &value
+	}
+	_, err = p.parseDirectives()
+	return def, err
+}
+
+// parseType consumes a type reference such as [ID!]!, which is not otherwise used.
+func (p *parser) parseType() error {
+	if ok, err := p.skip("["); err != nil {
+		return err
+	} else if ok {
+		if err := p.parseType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.parseName(); err != nil {
+		return err
+	}
+	_, err := p.skip("!")
+	return err
+}
+
+func (p *parser) parseFragment() (*Fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, p.errorf("fragment cannot be named \"on\"")
+	}
+	if !p.token.is(tokenName, "on") {
+		return nil, p.errorf("expected \"on\", found %q", p.token.value)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typeCondition, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	selections, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, TypeCondition: typeCondition, Selections: selections}, nil
+}
+
+func (p *parser) parseSelectionSet() ([]*Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []*Selection
+	for !p.token.is(tokenPunctuator, "}") {
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf("selection set must not be empty")
+	}
+	return selections, p.advance()
+}
+
+func (p *parser) parseSelection() (*Selection, error) {
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		return p.parseFragmentSelection()
+	}
+
+	field := &FieldNode{Line: p.token.line, Column: p.token.column}
+	name, err := p.parseName()
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.Alias = name
+		if name, err = p.parseName(); err != nil {
+			return nil, err
+		}
+	}
+	field.Name = name
+
+	if field.Arguments, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.is(tokenPunctuator, "{") {
+		if field.Selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return &Selection{Field: field, Directives: directives}, nil
+}
+
+// parseFragmentSelection parses what follows the "..." of a fragment spread or inline
+// fragment.
+func (p *parser) parseFragmentSelection() (*Selection, error) {
+	if p.token.kind == tokenName && p.token.value != "on" {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		directives, err := p.parseDirectives()
+		if err != nil {
+			return nil, err
+		}
+		return &Selection{FragmentName: name, Directives: directives}, nil
+	}
+
+	inline := &Fragment{}
+	if p.token.is(tokenName, "on") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		typeCondition, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		inline.TypeCondition = typeCondition
+	}
+	directives, err := p.parseDirectives()
+	if err != nil {
+		return nil, err
+	}
+	if inline.Selections, err = p.parseSelectionSet(); err != nil {
+		return nil, err
+	}
+	return &Selection{InlineFragment: inline, Directives: directives}, nil
+}
+
+func (p *parser) parseArguments() (map[string]Value, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	args := map[string]Value{}
+	for !p.token.is(tokenPunctuator, ")") {
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseValue(false)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = value
+	}
+	return args, p.advance()
+}
+
+func (p *parser) parseDirectives() ([]Directive, error) {
+	var directives []Directive
+	for p.token.is(tokenPunctuator, "@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.parseName()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, Directive{Name: name, Arguments: args})
+	}
+	return directives, nil
+}
+
+// parseValue parses a value. Variables are not allowed in constant values such as variable
+// defaults.
+func (p *parser) parseValue(constant bool) (Value, error) {
+	tok := p.token
+	switch {
+	case tok.is(tokenPunctuator, "$"):
+		if constant {
+			return Value{}, p.errorf("variables are not allowed here")
+		}
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		name, err := p.parseName()
+		return Value{Kind: ValueVariable, Raw: name}, err
+	case tok.is(tokenPunctuator, "["):
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		list := Value{Kind: ValueList, List: []Value{}}
+		for !p.token.is(tokenPunctuator, "]") {
+			item, err := p.parseValue(constant)
+			if err != nil {
+				return Value{}, err
+			}
+			list.List = append(list.List, item)
+		}
+		return list, p.advance()
+	case tok.is(tokenPunctuator, "{"):
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		object := Value{Kind: ValueObject, Object: map[string]Value{}}
+		for !p.token.is(tokenPunctuator, "}") {
+			name, err := p.parseName()
+			if err != nil {
+				return Value{}, err
+			}
+			if err := p.expect(":"); err != nil {
+				return Value{}, err
+			}
+			if object.Object[name], err = p.parseValue(constant); err != nil {
+				return Value{}, err
+			}
+		}
+		return object, p.advance()
+	}
+
+	var value Value
+	switch tok.kind {
+	case tokenInt:
+		value = Value{Kind: ValueInt, Raw: tok.value}
+	case tokenFloat:
+		value = Value{Kind: ValueFloat, Raw: tok.value}
+	case tokenString:
+		value = Value{Kind: ValueString, Raw: tok.value}
+	case tokenName:
+		switch tok.value {
+		case "true", "false":
+			value = Value{Kind: ValueBoolean, Raw: tok.value}
+		case "null":
+			value = Value{Kind: ValueNull}
+		default:
+			value = Value{Kind: ValueEnum, Raw: tok.value}
+		}
+	default:
+		return Value{}, p.unexpected()
+	}
+	return value, p.advance()
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind         tokenKind
+	value        string // Decoded for strings
+	line, column int
+}
+
+func (t token) is(kind tokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+type lexer struct {
+	source    string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func (l *lexer) errorf(format string, args ...any) error {
+	return fmt.Errorf("syntax error at %d:%d: %s", l.line, l.pos-l.lineStart+1, fmt.Sprintf(format, args...))
+}
+
+// next returns the next token, skipping whitespace, commas and comments.
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.source) {
+		c := l.source[l.pos]
+		switch {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.lineStart = l.pos
+			continue
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.pos++
+			continue
+		case c == '#':
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	tok := token{line: l.line, column: l.pos - l.lineStart + 1}
+	if l.pos >= len(l.source) {
+		tok.kind = tokenEOF
+		return tok, nil
+	}
+
+	c := l.source[l.pos]
+	switch {
+	case strings.HasPrefix(l.source[l.pos:], "..."):
+		tok.kind, tok.value = tokenPunctuator, "..."
+		l.pos += 3
+	case strings.ContainsRune("!$():=@[]{|}&", rune(c)):
+		tok.kind, tok.value = tokenPunctuator, string(c)
+		l.pos++
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.pos++
+		}
+		tok.kind, tok.value = tokenName, l.source[start:l.pos]
+	case c == '-' || isDigit(c):
+		return l.number(tok)
+	case c == '"':
+		return l.string(tok)
+	default:
+		return tok, l.errorf("unexpected character %q", c)
+	}
+	return tok, nil
+}
+
+func (l *lexer) number(tok token) (token, error) {
+	start := l.pos
+	if l.source[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.source) && isDigit(l.source[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return tok, l.errorf("invalid number")
+	}
+	tok.kind = tokenInt
+	if l.pos < len(l.source) && l.source[l.pos] == '.' {
+		l.pos++
+		if digits() == 0 {
+			return tok, l.errorf("invalid number")
+		}
+		tok.kind = tokenFloat
+	}
+	if l.pos < len(l.source) && (l.source[l.pos] == 'e' || l.source[l.pos] == 'E') {
+		l.pos++
+		if l.pos < len(l.source) && (l.source[l.pos] == '+' || l.source[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return tok, l.errorf("invalid number")
+		}
+		tok.kind = tokenFloat
+	}
+	tok.value = l.source[start:l.pos]
+	return tok, nil
+}
+
+// string lexes a quoted string. Block strings are not supported.
+func (l *lexer) string(tok token) (token, error) {
+	if strings.HasPrefix(l.source[l.pos:], `"""`) {
+		return tok, l.errorf("block strings are not supported")
+	}
+	l.pos++
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.source) || l.source[l.pos] == '\n' {
+			return tok, l.errorf("unterminated string")
+		}
+		c := l.source[l.pos]
+		if c == '"' {
+			l.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			l.pos++
+			continue
+		}
+		if l.pos+1 >= len(l.source) {
+			return tok, l.errorf("unterminated string")
+		}
+		escape := l.source[l.pos+1]
+		l.pos += 2
+		switch escape {
+		case '"', '\\', '/':
+			b.WriteByte(escape)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if l.pos+4 > len(l.source) {
+				return tok, l.errorf("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(l.source[l.pos:l.pos+4], 16, 32)
+			if err != nil {
+				return tok, l.errorf("invalid unicode escape")
+			}
+			b.WriteRune(rune(r))
+			l.pos += 4
+		default:
+			return tok, l.errorf("invalid escape \\%c", escape)
+		}
+	}
+	tok.kind, tok.value = tokenString, b.String()
+	return tok, nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,86 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/mcclellann/fredLoan/pkg/grpcapi/fredloanv1"
+	"github.com/mcclellann/fredLoan/pkg/oidc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenVerifier validates a bearer token and returns its claims. *oidc.Verifier is one.
+type TokenVerifier interface {
+	Verify(token string) (*oidc.Claims, error)
+}
+
+// roleRanks are the role values accepted in the token's roles claim, as in the REST API. Each
+// role includes the permissions of the roles ranked below it.
+var roleRanks = map[string]int{
+	"read-only": 1,
+	"servicer":  2,
+	"admin":     3,
+}
+
+// methodRoles is the role each method needs: reads need read-only and changes need servicer.
+// Methods not listed need admin.
+var methodRoles = map[string]string{
+	fredloanv1.LedgerService_CreateLoan_FullMethodName:       "servicer",
+	fredloanv1.LedgerService_GetLoan_FullMethodName:          "read-only",
+	fredloanv1.LedgerService_RecordPayment_FullMethodName:    "servicer",
+	fredloanv1.LedgerService_ListTransactions_FullMethodName: "read-only",
+	fredloanv1.LedgerService_StreamAccruals_FullMethodName:   "read-only",
+}
+
+// authorize requires the call to carry a valid bearer token in its authorization metadata,
+// with a role that the method permits. It lets every call through when no token verifier is
+// configured.
+func (s *Server) authorize(ctx context.Context, method string) error {
+	if s.tokenVerifier == nil {
+		return nil
+	}
+
+	var token string
+	if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	if strings.TrimSpace(token) == "" {
+		return status.Error(codes.Unauthenticated, "bearer token required")
+	}
+	claims, err := s.tokenVerifier.Verify(strings.TrimSpace(token))
+	if err != nil {
+		slog.Warn("Rejected bearer token", "method", method, "err", err)
+		return status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+
+	required, ok := methodRoles[method]
+	if !ok {
+		required = "admin"
+	}
+	granted := 0
+	for _, name := range claims.Roles {
+		granted = max(granted, roleRanks[name])
+	}
+	if granted < roleRanks[required] {
+		return status.Error(codes.PermissionDenied, "this operation requires the "+required+" role")
+	}
+	return nil
+}
+
+func (s *Server) authenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := s.authorize(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) authenticateStream(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authorize(stream.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
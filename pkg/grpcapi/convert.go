@@ -0,0 +1,82 @@
+package grpcapi
+
+import (
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/grpcapi/fredloanv1"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func loanToProto(loan *models.Loan) *fredloanv1.Loan {
+	return &fredloanv1.Loan{
+		Id:                   loan.ID.String(),
+		CustomerKey:          loan.CustomerKey,
+		Principal:            loan.Principal.String(),
+		Balance:              loan.Balance.String(),
+		BaseInterestRate:     loan.BaseInterestRate.String(),
+		InterestRateVariance: loan.InterestRateVariance.String(),
+		InterestRate:         loan.InterestRate.String(),
+		Status:               string(loan.Status),
+		StatementCycleDay:    int32(loan.StatementCycleDay),
+		AccruedInterest:      loan.AccruedInterest.String(),
+		DaysPastDue:          int32(loan.DaysPastDue),
+		DelinquencyBucket:    string(loan.DelinquencyBucket),
+		ProductCode:          loan.ProductCode,
+		TermMonths:           int32(loan.TermMonths),
+		LoanType:             string(loan.LoanType),
+		CreditLimit:          loan.CreditLimit.String(),
+		FeesDue:              loan.FeesDue.String(),
+		InterestDue:          loan.InterestDue.String(),
+		InterestMode:         string(loan.InterestMode),
+		Apr:                  optionalDecimal(loan.APR),
+		Apy:                  optionalDecimal(loan.APY),
+		LastPaymentDate:      optionalTimestamp(loan.LastPaymentDate),
+		CreatedAt:            timestamppb.New(loan.CreatedAt),
+		UpdatedAt:            timestamppb.New(loan.UpdatedAt),
+	}
+}
+
+func transactionToProto(tx *models.Transaction) *fredloanv1.Transaction {
+	out := &fredloanv1.Transaction{
+		Id:          tx.ID.String(),
+		LoanId:      tx.LoanID.String(),
+		Amount:      tx.Amount.String(),
+		Type:        string(tx.Type),
+		Timestamp:   timestamppb.New(tx.Timestamp),
+		Source:      tx.Source,
+		Capitalized: tx.Capitalized,
+		Reference:   tx.Reference,
+	}
+	if tx.PaymentMethodID != nil {
+		out.PaymentMethodId = tx.PaymentMethodID.String()
+	}
+	return out
+}
+
+func accrualToProto(accrual *models.Accrual) *fredloanv1.Accrual {
+	return &fredloanv1.Accrual{
+		LoanId:  accrual.LoanID.String(),
+		Date:    timestamppb.New(accrual.Date),
+		Balance: accrual.Balance.String(),
+		Rate:    accrual.Rate.String(),
+		Amount:  accrual.Amount.String(),
+	}
+}
+
+// optionalDecimal returns the decimal string of d, or "" if it is not set.
+func optionalDecimal(d *decimal.Decimal) string {
+	if d == nil {
+		return ""
+	}
+	return d.String()
+}
+
+// optionalTimestamp returns t as a timestamp, or nil if it is not set.
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
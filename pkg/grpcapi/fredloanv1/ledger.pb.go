@@ -0,0 +1,994 @@
+// Ledger service for internal service-to-service callers. It mirrors the REST API's loan,
+// payment and transaction routes, and cmd/api serves it from the same Ledger as the REST
+// server when server.grpc_listen_address is set. The Go code in pkg/grpcapi/fredloanv1 is
+// generated from this file with
+//
+//   protoc -I proto --go_out=. --go_opt=module=github.com/mcclellann/fredLoan \
+//     --go-grpc_out=. --go-grpc_opt=module=github.com/mcclellann/fredLoan \
+//     fredloan/v1/ledger.proto
+//
+// Money and rates are decimal strings (e.g. "1250.00", "0.0725"), exactly as in the JSON API,
+// so no precision is lost to floating point.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: fredloan/v1/ledger.proto
+
+package fredloanv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Loan struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Id                   string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	CustomerKey          string                 `protobuf:"bytes,2,opt,name=customer_key,json=customerKey,proto3" json:"customer_key,omitempty"`
+	Principal            string                 `protobuf:"bytes,3,opt,name=principal,proto3" json:"principal,omitempty"`
+	Balance              string                 `protobuf:"bytes,4,opt,name=balance,proto3" json:"balance,omitempty"`
+	BaseInterestRate     string                 `protobuf:"bytes,5,opt,name=base_interest_rate,json=baseInterestRate,proto3" json:"base_interest_rate,omitempty"`
+	InterestRateVariance string                 `protobuf:"bytes,6,opt,name=interest_rate_variance,json=interestRateVariance,proto3" json:"interest_rate_variance,omitempty"`
+	InterestRate         string                 `protobuf:"bytes,7,opt,name=interest_rate,json=interestRate,proto3" json:"interest_rate,omitempty"`
+	Status               string                 `protobuf:"bytes,8,opt,name=status,proto3" json:"status,omitempty"` // pending, active, delinquent, closed or charged_off
+	StatementCycleDay    int32                  `protobuf:"varint,9,opt,name=statement_cycle_day,json=statementCycleDay,proto3" json:"statement_cycle_day,omitempty"`
+	AccruedInterest      string                 `protobuf:"bytes,10,opt,name=accrued_interest,json=accruedInterest,proto3" json:"accrued_interest,omitempty"`
+	DaysPastDue          int32                  `protobuf:"varint,11,opt,name=days_past_due,json=daysPastDue,proto3" json:"days_past_due,omitempty"`
+	DelinquencyBucket    string                 `protobuf:"bytes,12,opt,name=delinquency_bucket,json=delinquencyBucket,proto3" json:"delinquency_bucket,omitempty"`
+	ProductCode          string                 `protobuf:"bytes,13,opt,name=product_code,json=productCode,proto3" json:"product_code,omitempty"`
+	TermMonths           int32                  `protobuf:"varint,14,opt,name=term_months,json=termMonths,proto3" json:"term_months,omitempty"`
+	LoanType             string                 `protobuf:"bytes,15,opt,name=loan_type,json=loanType,proto3" json:"loan_type,omitempty"` // Empty for installment loans
+	CreditLimit          string                 `protobuf:"bytes,16,opt,name=credit_limit,json=creditLimit,proto3" json:"credit_limit,omitempty"`
+	FeesDue              string                 `protobuf:"bytes,17,opt,name=fees_due,json=feesDue,proto3" json:"fees_due,omitempty"`
+	InterestDue          string                 `protobuf:"bytes,18,opt,name=interest_due,json=interestDue,proto3" json:"interest_due,omitempty"`
+	InterestMode         string                 `protobuf:"bytes,19,opt,name=interest_mode,json=interestMode,proto3" json:"interest_mode,omitempty"` // Empty compounds monthly
+	Apr                  string                 `protobuf:"bytes,20,opt,name=apr,proto3" json:"apr,omitempty"`
+	Apy                  string                 `protobuf:"bytes,21,opt,name=apy,proto3" json:"apy,omitempty"`
+	LastPaymentDate      *timestamppb.Timestamp `protobuf:"bytes,22,opt,name=last_payment_date,json=lastPaymentDate,proto3" json:"last_payment_date,omitempty"`
+	CreatedAt            *timestamppb.Timestamp `protobuf:"bytes,23,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt            *timestamppb.Timestamp `protobuf:"bytes,24,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *Loan) Reset() {
+	*x = Loan{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Loan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Loan) ProtoMessage() {}
+
+func (x *Loan) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Loan.ProtoReflect.Descriptor instead.
+func (*Loan) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Loan) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Loan) GetCustomerKey() string {
+	if x != nil {
+		return x.CustomerKey
+	}
+	return ""
+}
+
+func (x *Loan) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *Loan) GetBalance() string {
+	if x != nil {
+		return x.Balance
+	}
+	return ""
+}
+
+func (x *Loan) GetBaseInterestRate() string {
+	if x != nil {
+		return x.BaseInterestRate
+	}
+	return ""
+}
+
+func (x *Loan) GetInterestRateVariance() string {
+	if x != nil {
+		return x.InterestRateVariance
+	}
+	return ""
+}
+
+func (x *Loan) GetInterestRate() string {
+	if x != nil {
+		return x.InterestRate
+	}
+	return ""
+}
+
+func (x *Loan) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Loan) GetStatementCycleDay() int32 {
+	if x != nil {
+		return x.StatementCycleDay
+	}
+	return 0
+}
+
+func (x *Loan) GetAccruedInterest() string {
+	if x != nil {
+		return x.AccruedInterest
+	}
+	return ""
+}
+
+func (x *Loan) GetDaysPastDue() int32 {
+	if x != nil {
+		return x.DaysPastDue
+	}
+	return 0
+}
+
+func (x *Loan) GetDelinquencyBucket() string {
+	if x != nil {
+		return x.DelinquencyBucket
+	}
+	return ""
+}
+
+func (x *Loan) GetProductCode() string {
+	if x != nil {
+		return x.ProductCode
+	}
+	return ""
+}
+
+func (x *Loan) GetTermMonths() int32 {
+	if x != nil {
+		return x.TermMonths
+	}
+	return 0
+}
+
+func (x *Loan) GetLoanType() string {
+	if x != nil {
+		return x.LoanType
+	}
+	return ""
+}
+
+func (x *Loan) GetCreditLimit() string {
+	if x != nil {
+		return x.CreditLimit
+	}
+	return ""
+}
+
+func (x *Loan) GetFeesDue() string {
+	if x != nil {
+		return x.FeesDue
+	}
+	return ""
+}
+
+func (x *Loan) GetInterestDue() string {
+	if x != nil {
+		return x.InterestDue
+	}
+	return ""
+}
+
+func (x *Loan) GetInterestMode() string {
+	if x != nil {
+		return x.InterestMode
+	}
+	return ""
+}
+
+func (x *Loan) GetApr() string {
+	if x != nil {
+		return x.Apr
+	}
+	return ""
+}
+
+func (x *Loan) GetApy() string {
+	if x != nil {
+		return x.Apy
+	}
+	return ""
+}
+
+func (x *Loan) GetLastPaymentDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastPaymentDate
+	}
+	return nil
+}
+
+func (x *Loan) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Loan) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type Transaction struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	LoanId          string                 `protobuf:"bytes,2,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	Amount          string                 `protobuf:"bytes,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Type            string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"` // disbursement, payment, interest, fee, recovery, ...
+	Timestamp       *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	PaymentMethodId string                 `protobuf:"bytes,6,opt,name=payment_method_id,json=paymentMethodId,proto3" json:"payment_method_id,omitempty"`
+	Source          string                 `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"`
+	Capitalized     bool                   `protobuf:"varint,8,opt,name=capitalized,proto3" json:"capitalized,omitempty"`
+	Reference       string                 `protobuf:"bytes,9,opt,name=reference,proto3" json:"reference,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Transaction) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Transaction) GetLoanId() string {
+	if x != nil {
+		return x.LoanId
+	}
+	return ""
+}
+
+func (x *Transaction) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *Transaction) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Transaction) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *Transaction) GetPaymentMethodId() string {
+	if x != nil {
+		return x.PaymentMethodId
+	}
+	return ""
+}
+
+func (x *Transaction) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Transaction) GetCapitalized() bool {
+	if x != nil {
+		return x.Capitalized
+	}
+	return false
+}
+
+func (x *Transaction) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+type Accrual struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        string                 `protobuf:"bytes,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	Date          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=date,proto3" json:"date,omitempty"`
+	Balance       string                 `protobuf:"bytes,3,opt,name=balance,proto3" json:"balance,omitempty"` // Balance interest accrued on
+	Rate          string                 `protobuf:"bytes,4,opt,name=rate,proto3" json:"rate,omitempty"`       // APR in effect, after any promo or forbearance
+	Amount        string                 `protobuf:"bytes,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Accrual) Reset() {
+	*x = Accrual{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Accrual) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Accrual) ProtoMessage() {}
+
+func (x *Accrual) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Accrual.ProtoReflect.Descriptor instead.
+func (*Accrual) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Accrual) GetLoanId() string {
+	if x != nil {
+		return x.LoanId
+	}
+	return ""
+}
+
+func (x *Accrual) GetDate() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Date
+	}
+	return nil
+}
+
+func (x *Accrual) GetBalance() string {
+	if x != nil {
+		return x.Balance
+	}
+	return ""
+}
+
+func (x *Accrual) GetRate() string {
+	if x != nil {
+		return x.Rate
+	}
+	return ""
+}
+
+func (x *Accrual) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+type CreateLoanRequest struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	CustomerKey          string                 `protobuf:"bytes,1,opt,name=customer_key,json=customerKey,proto3" json:"customer_key,omitempty"`
+	Principal            string                 `protobuf:"bytes,2,opt,name=principal,proto3" json:"principal,omitempty"`
+	BaseInterestRate     string                 `protobuf:"bytes,3,opt,name=base_interest_rate,json=baseInterestRate,proto3" json:"base_interest_rate,omitempty"`
+	InterestRateVariance string                 `protobuf:"bytes,4,opt,name=interest_rate_variance,json=interestRateVariance,proto3" json:"interest_rate_variance,omitempty"`
+	ProductCode          string                 `protobuf:"bytes,5,opt,name=product_code,json=productCode,proto3" json:"product_code,omitempty"`
+	TermMonths           int32                  `protobuf:"varint,6,opt,name=term_months,json=termMonths,proto3" json:"term_months,omitempty"`
+	AmortizationMonths   int32                  `protobuf:"varint,7,opt,name=amortization_months,json=amortizationMonths,proto3" json:"amortization_months,omitempty"`
+	IndexCode            string                 `protobuf:"bytes,8,opt,name=index_code,json=indexCode,proto3" json:"index_code,omitempty"`
+	LoanType             string                 `protobuf:"bytes,9,opt,name=loan_type,json=loanType,proto3" json:"loan_type,omitempty"`
+	CreditLimit          string                 `protobuf:"bytes,10,opt,name=credit_limit,json=creditLimit,proto3" json:"credit_limit,omitempty"`
+	InterestMode         string                 `protobuf:"bytes,11,opt,name=interest_mode,json=interestMode,proto3" json:"interest_mode,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
+}
+
+func (x *CreateLoanRequest) Reset() {
+	*x = CreateLoanRequest{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateLoanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateLoanRequest) ProtoMessage() {}
+
+func (x *CreateLoanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateLoanRequest.ProtoReflect.Descriptor instead.
+func (*CreateLoanRequest) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateLoanRequest) GetCustomerKey() string {
+	if x != nil {
+		return x.CustomerKey
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetPrincipal() string {
+	if x != nil {
+		return x.Principal
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetBaseInterestRate() string {
+	if x != nil {
+		return x.BaseInterestRate
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetInterestRateVariance() string {
+	if x != nil {
+		return x.InterestRateVariance
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetProductCode() string {
+	if x != nil {
+		return x.ProductCode
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetTermMonths() int32 {
+	if x != nil {
+		return x.TermMonths
+	}
+	return 0
+}
+
+func (x *CreateLoanRequest) GetAmortizationMonths() int32 {
+	if x != nil {
+		return x.AmortizationMonths
+	}
+	return 0
+}
+
+func (x *CreateLoanRequest) GetIndexCode() string {
+	if x != nil {
+		return x.IndexCode
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetLoanType() string {
+	if x != nil {
+		return x.LoanType
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetCreditLimit() string {
+	if x != nil {
+		return x.CreditLimit
+	}
+	return ""
+}
+
+func (x *CreateLoanRequest) GetInterestMode() string {
+	if x != nil {
+		return x.InterestMode
+	}
+	return ""
+}
+
+type GetLoanRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLoanRequest) Reset() {
+	*x = GetLoanRequest{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLoanRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLoanRequest) ProtoMessage() {}
+
+func (x *GetLoanRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLoanRequest.ProtoReflect.Descriptor instead.
+func (*GetLoanRequest) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetLoanRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type RecordPaymentRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	LoanId          string                 `protobuf:"bytes,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	Amount          string                 `protobuf:"bytes,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	EscrowAmount    string                 `protobuf:"bytes,3,opt,name=escrow_amount,json=escrowAmount,proto3" json:"escrow_amount,omitempty"`
+	PaymentMethodId string                 `protobuf:"bytes,4,opt,name=payment_method_id,json=paymentMethodId,proto3" json:"payment_method_id,omitempty"`
+	// Makes retries safe, as the REST API's Idempotency-Key header does.
+	IdempotencyKey string `protobuf:"bytes,5,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RecordPaymentRequest) Reset() {
+	*x = RecordPaymentRequest{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecordPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecordPaymentRequest) ProtoMessage() {}
+
+func (x *RecordPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecordPaymentRequest.ProtoReflect.Descriptor instead.
+func (*RecordPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RecordPaymentRequest) GetLoanId() string {
+	if x != nil {
+		return x.LoanId
+	}
+	return ""
+}
+
+func (x *RecordPaymentRequest) GetAmount() string {
+	if x != nil {
+		return x.Amount
+	}
+	return ""
+}
+
+func (x *RecordPaymentRequest) GetEscrowAmount() string {
+	if x != nil {
+		return x.EscrowAmount
+	}
+	return ""
+}
+
+func (x *RecordPaymentRequest) GetPaymentMethodId() string {
+	if x != nil {
+		return x.PaymentMethodId
+	}
+	return ""
+}
+
+func (x *RecordPaymentRequest) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+type ListTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        string                 `protobuf:"bytes,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsRequest) Reset() {
+	*x = ListTransactionsRequest{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsRequest) ProtoMessage() {}
+
+func (x *ListTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*ListTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListTransactionsRequest) GetLoanId() string {
+	if x != nil {
+		return x.LoanId
+	}
+	return ""
+}
+
+type ListTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTransactionsResponse) Reset() {
+	*x = ListTransactionsResponse{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTransactionsResponse) ProtoMessage() {}
+
+func (x *ListTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*ListTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListTransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type StreamAccrualsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LoanId        string                 `protobuf:"bytes,1,opt,name=loan_id,json=loanId,proto3" json:"loan_id,omitempty"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"` // Defaults to 90 days ago, as in the REST API
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamAccrualsRequest) Reset() {
+	*x = StreamAccrualsRequest{}
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamAccrualsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamAccrualsRequest) ProtoMessage() {}
+
+func (x *StreamAccrualsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fredloan_v1_ledger_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamAccrualsRequest.ProtoReflect.Descriptor instead.
+func (*StreamAccrualsRequest) Descriptor() ([]byte, []int) {
+	return file_fredloan_v1_ledger_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *StreamAccrualsRequest) GetLoanId() string {
+	if x != nil {
+		return x.LoanId
+	}
+	return ""
+}
+
+func (x *StreamAccrualsRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+var File_fredloan_v1_ledger_proto protoreflect.FileDescriptor
+
+const file_fredloan_v1_ledger_proto_rawDesc = "" +
+	"\n" +
+	"\x18fredloan/v1/ledger.proto\x12\vfredloan.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x89\a\n" +
+	"\x04Loan\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12!\n" +
+	"\fcustomer_key\x18\x02 \x01(\tR\vcustomerKey\x12\x1c\n" +
+	"\tprincipal\x18\x03 \x01(\tR\tprincipal\x12\x18\n" +
+	"\abalance\x18\x04 \x01(\tR\abalance\x12,\n" +
+	"\x12base_interest_rate\x18\x05 \x01(\tR\x10baseInterestRate\x124\n" +
+	"\x16interest_rate_variance\x18\x06 \x01(\tR\x14interestRateVariance\x12#\n" +
+	"\rinterest_rate\x18\a \x01(\tR\finterestRate\x12\x16\n" +
+	"\x06status\x18\b \x01(\tR\x06status\x12.\n" +
+	"\x13statement_cycle_day\x18\t \x01(\x05R\x11statementCycleDay\x12)\n" +
+	"\x10accrued_interest\x18\n" +
+	" \x01(\tR\x0faccruedInterest\x12\"\n" +
+	"\rdays_past_due\x18\v \x01(\x05R\vdaysPastDue\x12-\n" +
+	"\x12delinquency_bucket\x18\f \x01(\tR\x11delinquencyBucket\x12!\n" +
+	"\fproduct_code\x18\r \x01(\tR\vproductCode\x12\x1f\n" +
+	"\vterm_months\x18\x0e \x01(\x05R\n" +
+	"termMonths\x12\x1b\n" +
+	"\tloan_type\x18\x0f \x01(\tR\bloanType\x12!\n" +
+	"\fcredit_limit\x18\x10 \x01(\tR\vcreditLimit\x12\x19\n" +
+	"\bfees_due\x18\x11 \x01(\tR\afeesDue\x12!\n" +
+	"\finterest_due\x18\x12 \x01(\tR\vinterestDue\x12#\n" +
+	"\rinterest_mode\x18\x13 \x01(\tR\finterestMode\x12\x10\n" +
+	"\x03apr\x18\x14 \x01(\tR\x03apr\x12\x10\n" +
+	"\x03apy\x18\x15 \x01(\tR\x03apy\x12F\n" +
+	"\x11last_payment_date\x18\x16 \x01(\v2\x1a.google.protobuf.TimestampR\x0flastPaymentDate\x129\n" +
+	"\n" +
+	"created_at\x18\x17 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x18 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"\xa0\x02\n" +
+	"\vTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\aloan_id\x18\x02 \x01(\tR\x06loanId\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\tR\x06amount\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x128\n" +
+	"\ttimestamp\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12*\n" +
+	"\x11payment_method_id\x18\x06 \x01(\tR\x0fpaymentMethodId\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\x12 \n" +
+	"\vcapitalized\x18\b \x01(\bR\vcapitalized\x12\x1c\n" +
+	"\treference\x18\t \x01(\tR\treference\"\x98\x01\n" +
+	"\aAccrual\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\tR\x06loanId\x12.\n" +
+	"\x04date\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04date\x12\x18\n" +
+	"\abalance\x18\x03 \x01(\tR\abalance\x12\x12\n" +
+	"\x04rate\x18\x04 \x01(\tR\x04rate\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\tR\x06amount\"\xb1\x03\n" +
+	"\x11CreateLoanRequest\x12!\n" +
+	"\fcustomer_key\x18\x01 \x01(\tR\vcustomerKey\x12\x1c\n" +
+	"\tprincipal\x18\x02 \x01(\tR\tprincipal\x12,\n" +
+	"\x12base_interest_rate\x18\x03 \x01(\tR\x10baseInterestRate\x124\n" +
+	"\x16interest_rate_variance\x18\x04 \x01(\tR\x14interestRateVariance\x12!\n" +
+	"\fproduct_code\x18\x05 \x01(\tR\vproductCode\x12\x1f\n" +
+	"\vterm_months\x18\x06 \x01(\x05R\n" +
+	"termMonths\x12/\n" +
+	"\x13amortization_months\x18\a \x01(\x05R\x12amortizationMonths\x12\x1d\n" +
+	"\n" +
+	"index_code\x18\b \x01(\tR\tindexCode\x12\x1b\n" +
+	"\tloan_type\x18\t \x01(\tR\bloanType\x12!\n" +
+	"\fcredit_limit\x18\n" +
+	" \x01(\tR\vcreditLimit\x12#\n" +
+	"\rinterest_mode\x18\v \x01(\tR\finterestMode\" \n" +
+	"\x0eGetLoanRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xc1\x01\n" +
+	"\x14RecordPaymentRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\tR\x06loanId\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\tR\x06amount\x12#\n" +
+	"\rescrow_amount\x18\x03 \x01(\tR\fescrowAmount\x12*\n" +
+	"\x11payment_method_id\x18\x04 \x01(\tR\x0fpaymentMethodId\x12'\n" +
+	"\x0fidempotency_key\x18\x05 \x01(\tR\x0eidempotencyKey\"2\n" +
+	"\x17ListTransactionsRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\tR\x06loanId\"X\n" +
+	"\x18ListTransactionsResponse\x12<\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x18.fredloan.v1.TransactionR\ftransactions\"`\n" +
+	"\x15StreamAccrualsRequest\x12\x17\n" +
+	"\aloan_id\x18\x01 \x01(\tR\x06loanId\x12.\n" +
+	"\x04from\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04from2\x88\x03\n" +
+	"\rLedgerService\x12?\n" +
+	"\n" +
+	"CreateLoan\x12\x1e.fredloan.v1.CreateLoanRequest\x1a\x11.fredloan.v1.Loan\x129\n" +
+	"\aGetLoan\x12\x1b.fredloan.v1.GetLoanRequest\x1a\x11.fredloan.v1.Loan\x12L\n" +
+	"\rRecordPayment\x12!.fredloan.v1.RecordPaymentRequest\x1a\x18.fredloan.v1.Transaction\x12_\n" +
+	"\x10ListTransactions\x12$.fredloan.v1.ListTransactionsRequest\x1a%.fredloan.v1.ListTransactionsResponse\x12L\n" +
+	"\x0eStreamAccruals\x12\".fredloan.v1.StreamAccrualsRequest\x1a\x14.fredloan.v1.Accrual0\x01B7Z5github.com/mcclellann/fredLoan/pkg/grpcapi/fredloanv1b\x06proto3"
+
+var (
+	file_fredloan_v1_ledger_proto_rawDescOnce sync.Once
+	file_fredloan_v1_ledger_proto_rawDescData []byte
+)
+
+func file_fredloan_v1_ledger_proto_rawDescGZIP() []byte {
+	file_fredloan_v1_ledger_proto_rawDescOnce.Do(func() {
+		file_fredloan_v1_ledger_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fredloan_v1_ledger_proto_rawDesc), len(file_fredloan_v1_ledger_proto_rawDesc)))
+	})
+	return file_fredloan_v1_ledger_proto_rawDescData
+}
+
+var file_fredloan_v1_ledger_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_fredloan_v1_ledger_proto_goTypes = []any{
+	(*Loan)(nil),                     // 0: fredloan.v1.Loan
+	(*Transaction)(nil),              // 1: fredloan.v1.Transaction
+	(*Accrual)(nil),                  // 2: fredloan.v1.Accrual
+	(*CreateLoanRequest)(nil),        // 3: fredloan.v1.CreateLoanRequest
+	(*GetLoanRequest)(nil),           // 4: fredloan.v1.GetLoanRequest
+	(*RecordPaymentRequest)(nil),     // 5: fredloan.v1.RecordPaymentRequest
+	(*ListTransactionsRequest)(nil),  // 6: fredloan.v1.ListTransactionsRequest
+	(*ListTransactionsResponse)(nil), // 7: fredloan.v1.ListTransactionsResponse
+	(*StreamAccrualsRequest)(nil),    // 8: fredloan.v1.StreamAccrualsRequest
+	(*timestamppb.Timestamp)(nil),    // 9: google.protobuf.Timestamp
+}
+var file_fredloan_v1_ledger_proto_depIdxs = []int32{
+	9,  // 0: fredloan.v1.Loan.last_payment_date:type_name -> google.protobuf.Timestamp
+	9,  // 1: fredloan.v1.Loan.created_at:type_name -> google.protobuf.Timestamp
+	9,  // 2: fredloan.v1.Loan.updated_at:type_name -> google.protobuf.Timestamp
+	9,  // 3: fredloan.v1.Transaction.timestamp:type_name -> google.protobuf.Timestamp
+	9,  // 4: fredloan.v1.Accrual.date:type_name -> google.protobuf.Timestamp
+	1,  // 5: fredloan.v1.ListTransactionsResponse.transactions:type_name -> fredloan.v1.Transaction
+	9,  // 6: fredloan.v1.StreamAccrualsRequest.from:type_name -> google.protobuf.Timestamp
+	3,  // 7: fredloan.v1.LedgerService.CreateLoan:input_type -> fredloan.v1.CreateLoanRequest
+	4,  // 8: fredloan.v1.LedgerService.GetLoan:input_type -> fredloan.v1.GetLoanRequest
+	5,  // 9: fredloan.v1.LedgerService.RecordPayment:input_type -> fredloan.v1.RecordPaymentRequest
+	6,  // 10: fredloan.v1.LedgerService.ListTransactions:input_type -> fredloan.v1.ListTransactionsRequest
+	8,  // 11: fredloan.v1.LedgerService.StreamAccruals:input_type -> fredloan.v1.StreamAccrualsRequest
+	0,  // 12: fredloan.v1.LedgerService.CreateLoan:output_type -> fredloan.v1.Loan
+	0,  // 13: fredloan.v1.LedgerService.GetLoan:output_type -> fredloan.v1.Loan
+	1,  // 14: fredloan.v1.LedgerService.RecordPayment:output_type -> fredloan.v1.Transaction
+	7,  // 15: fredloan.v1.LedgerService.ListTransactions:output_type -> fredloan.v1.ListTransactionsResponse
+	2,  // 16: fredloan.v1.LedgerService.StreamAccruals:output_type -> fredloan.v1.Accrual
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_fredloan_v1_ledger_proto_init() }
+func file_fredloan_v1_ledger_proto_init() {
+	if File_fredloan_v1_ledger_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fredloan_v1_ledger_proto_rawDesc), len(file_fredloan_v1_ledger_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fredloan_v1_ledger_proto_goTypes,
+		DependencyIndexes: file_fredloan_v1_ledger_proto_depIdxs,
+		MessageInfos:      file_fredloan_v1_ledger_proto_msgTypes,
+	}.Build()
+	File_fredloan_v1_ledger_proto = out.File
+	file_fredloan_v1_ledger_proto_goTypes = nil
+	file_fredloan_v1_ledger_proto_depIdxs = nil
+}
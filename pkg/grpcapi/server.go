@@ -0,0 +1,395 @@
+// Package grpcapi serves the ledger over gRPC to internal service-to-service callers, as
+// defined by proto/fredloan/v1/ledger.proto. It shares its Ledger with the REST API, so both
+// read and change the same books, and the same idempotency keys and live events.
+package grpcapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/grpcapi/fredloanv1"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultHistoryDays is how far back StreamAccruals starts when the request gives no date,
+// as the REST API's accrual history does.
+const defaultHistoryDays = 90
+
+// maxInterestRate bounds the annual rates accepted when creating a loan (1 is 100% APR).
+var maxInterestRate = decimal.NewFromInt(1)
+
+// Server implements fredloanv1.LedgerServiceServer on a Ledger.
+type Server struct {
+	fredloanv1.UnimplementedLedgerServiceServer
+	ledger        *ledger.Ledger
+	tokenVerifier TokenVerifier
+	streamsClosed chan struct{} // Closed to end every accrual stream at shutdown
+}
+
+// NewServer creates a gRPC ledger service over l.
+func NewServer(l *ledger.Ledger) *Server {
+	return &Server{ledger: l, streamsClosed: make(chan struct{})}
+}
+
+// SetTokenVerifier requires every call to carry a bearer token accepted by verifier, with a
+// role permitting the method, as the REST API does. Without one calls are not authenticated.
+func (s *Server) SetTokenVerifier(verifier TokenVerifier) {
+	s.tokenVerifier = verifier
+}
+
+// NewGRPCServer returns a gRPC server with the ledger service registered on it, authenticating
+// calls before they reach it.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.authenticateUnary), grpc.ChainStreamInterceptor(s.authenticateStream))
+	server := grpc.NewServer(opts...)
+	fredloanv1.RegisterLedgerServiceServer(server, s)
+	return server
+}
+
+// CreateLoan creates a loan and disburses its principal.
+func (s *Server) CreateLoan(ctx context.Context, req *fredloanv1.CreateLoanRequest) (*fredloanv1.Loan, error) {
+	var v validator
+	v.required("customer_key", req.CustomerKey)
+	principal := v.decimal("principal", req.Principal)
+	baseRate := v.decimal("base_interest_rate", req.BaseInterestRate)
+	variance := v.decimal("interest_rate_variance", req.InterestRateVariance)
+	creditLimit := v.decimal("credit_limit", req.CreditLimit)
+
+	opts := []ledger.LoanOption{
+		ledger.WithTerm(int(req.TermMonths)),
+		ledger.WithAmortization(int(req.AmortizationMonths)),
+	}
+	switch models.LoanType(req.LoanType) {
+	case "":
+		v.check(principal.IsPositive(), "principal must be positive")
+	case models.LoanTypeLineOfCredit:
+		v.check(!principal.IsNegative(), "principal must not be negative") // A line may be opened without an initial draw
+		opts = append(opts, ledger.WithLineOfCredit(creditLimit))
+	default:
+		v.add("loan_type must be line_of_credit or empty")
+	}
+	v.check(!baseRate.IsNegative() && baseRate.LessThanOrEqual(maxInterestRate), "base_interest_rate must be between 0 and 1")
+	v.check(variance.Abs().LessThanOrEqual(maxInterestRate), "interest_rate_variance must be between -1 and 1")
+	if req.IndexCode == "" {
+		v.check(!baseRate.Add(variance).IsNegative(), "base_interest_rate plus interest_rate_variance must not be negative")
+	}
+	v.check(req.TermMonths >= 0, "term_months must not be negative")
+	v.check(req.AmortizationMonths >= 0, "amortization_months must not be negative")
+	v.check(!creditLimit.IsNegative(), "credit_limit must not be negative")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	if req.ProductCode != "" {
+		opts = append(opts, ledger.WithProduct(req.ProductCode))
+	}
+	if req.IndexCode != "" {
+		opts = append(opts, ledger.WithIndex(req.IndexCode))
+	}
+	if req.InterestMode != "" {
+		opts = append(opts, ledger.WithInterestMode(models.InterestMode(req.InterestMode)))
+	}
+
+	loan, err := s.ledger.CreateLoan(ctx, req.CustomerKey, principal, baseRate, variance, opts...)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return loanToProto(loan), nil
+}
+
+// GetLoan gets a loan by ID.
+func (s *Server) GetLoan(ctx context.Context, req *fredloanv1.GetLoanRequest) (*fredloanv1.Loan, error) {
+	loanID, err := parseID("id", req.Id)
+	if err != nil {
+		return nil, err
+	}
+	loan, err := s.ledger.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return loanToProto(loan), nil
+}
+
+// RecordPayment records a payment, or a recovery if the loan is charged off. With an
+// idempotency key it is recorded at most once; see recordPaymentOnce.
+func (s *Server) RecordPayment(ctx context.Context, req *fredloanv1.RecordPaymentRequest) (*fredloanv1.Transaction, error) {
+	loanID, err := parseID("loan_id", req.LoanId)
+	if err != nil {
+		return nil, err
+	}
+	var v validator
+	amount := v.decimal("amount", req.Amount)
+	escrowAmount := v.decimal("escrow_amount", req.EscrowAmount)
+	v.check(amount.IsPositive(), "amount must be positive")
+	v.check(!escrowAmount.IsNegative(), "escrow_amount must not be negative")
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	var opts []ledger.PaymentOption
+	if req.PaymentMethodId != "" {
+		methodID, err := parseID("payment_method_id", req.PaymentMethodId)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, ledger.WithPaymentMethod(methodID))
+	}
+
+	record := func(ctx context.Context) (*models.Transaction, error) {
+		return s.ledger.RecordPaymentWithEscrow(ctx, loanID, amount, escrowAmount, opts...)
+	}
+	var tx *models.Transaction
+	if req.IdempotencyKey == "" {
+		tx, err = record(ctx)
+	} else {
+		tx, err = s.recordPaymentOnce(ctx, req, record)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return transactionToProto(tx), nil
+}
+
+// recordPaymentOnce records a payment under the request's idempotency key, which shares the
+// REST API's key store. A retry with the same key and request gets the payment first recorded
+// without recording it again, and a key reused for another request is refused. A payment that
+// fails releases the key so the retry is processed afresh, unless it took effect all the same.
+func (s *Server) recordPaymentOnce(ctx context.Context, req *fredloanv1.RecordPaymentRequest, record func(context.Context) (*models.Transaction, error)) (*models.Transaction, error) {
+	key := req.IdempotencyKey
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(append([]byte(fredloanv1.LedgerService_RecordPayment_FullMethodName+"\n"), body...))
+
+	existing, err := s.ledger.ReserveIdempotencyKey(ctx, key, hex.EncodeToString(hash[:]))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		switch {
+		case existing.StatusCode == 0 && existing.AppliedAt != nil:
+			return nil, status.Error(codes.Aborted, "a request with this idempotency key was processed but its response was not recorded")
+		case existing.StatusCode == 0:
+			return nil, status.Error(codes.Aborted, "a request with this idempotency key is still being processed")
+		case existing.StatusCode != http.StatusCreated:
+			return nil, status.Error(codes.Internal, "a request with this idempotency key failed after it took effect")
+		}
+		var tx models.Transaction
+		if err := json.Unmarshal(existing.Body, &tx); err != nil {
+			return nil, err
+		}
+		return &tx, nil
+	}
+
+	tx, err := record(ledger.WithIdempotencyKey(ctx, key))
+
+	// The key is settled even if the caller has gone or the deadline passed
+	settleCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		releaseErr := s.ledger.ReleaseIdempotencyKey(settleCtx, key)
+		if errors.Is(releaseErr, models.ErrIdempotencyKeyApplied) {
+			releaseErr = s.ledger.CompleteIdempotencyKey(settleCtx, key, http.StatusInternalServerError, "text/plain", []byte(err.Error()))
+		}
+		if releaseErr != nil {
+			slog.Error("Error settling idempotency key", "key", key, "err", releaseErr)
+		}
+		return nil, err
+	}
+	response, err := json.Marshal(tx)
+	if err == nil {
+		err = s.ledger.CompleteIdempotencyKey(settleCtx, key, http.StatusCreated, "application/json", response)
+	}
+	if err != nil {
+		// The payment has posted, so the key stays reserved: retries are refused rather than
+		// recorded again
+		slog.Error("Error recording response for idempotency key", "key", key, "err", err)
+	}
+	return tx, nil
+}
+
+// ListTransactions lists a loan's transactions, oldest first.
+func (s *Server) ListTransactions(ctx context.Context, req *fredloanv1.ListTransactionsRequest) (*fredloanv1.ListTransactionsResponse, error) {
+	loanID, err := parseID("loan_id", req.LoanId)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := s.ledger.GetTransactions(ctx, loanID)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &fredloanv1.ListTransactionsResponse{Transactions: make([]*fredloanv1.Transaction, 0, len(txs))}
+	for _, tx := range txs {
+		resp.Transactions = append(resp.Transactions, transactionToProto(tx))
+	}
+	return resp, nil
+}
+
+// StreamAccruals sends a loan's accruals from the requested date, then each accrual the daily
+// batch records for it until the caller cancels. Like the REST event stream, a caller that
+// falls far behind misses live accruals rather than holding up the ledger.
+func (s *Server) StreamAccruals(req *fredloanv1.StreamAccrualsRequest, stream grpc.ServerStreamingServer[fredloanv1.Accrual]) error {
+	ctx := stream.Context()
+	loanID, err := parseID("loan_id", req.LoanId)
+	if err != nil {
+		return err
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -defaultHistoryDays)
+	if req.From != nil {
+		from = req.From.AsTime()
+	}
+
+	// Subscribe before reading the history, so an accrual recorded in between is not missed
+	events, unsubscribe := s.ledger.SubscribeEvents()
+	defer unsubscribe()
+
+	history, err := s.ledger.GetAccruals(ctx, loanID, from, to)
+	if err != nil {
+		return statusError(err)
+	}
+	var last time.Time
+	for _, accrual := range history {
+		if err := stream.Send(accrualToProto(accrual)); err != nil {
+			return err
+		}
+		last = accrual.Date
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.streamsClosed:
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if event.Type != models.StreamAccrualRecorded || event.LoanID != loanID {
+				continue
+			}
+			accrual := event.Data.(*models.Accrual)
+			if !accrual.Date.After(last) {
+				continue // Already sent from the history
+			}
+			if err := stream.Send(accrualToProto(accrual)); err != nil {
+				return err
+			}
+			last = accrual.Date
+		}
+	}
+}
+
+// CloseStreams ends every open accrual stream, so that a graceful stop does not wait for
+// callers to cancel them. It must be called at most once.
+func (s *Server) CloseStreams() {
+	close(s.streamsClosed)
+}
+
+// errorCodes maps the ledger's and store's error values to status codes, as the REST API's
+// errorResponses maps them to HTTP statuses.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{models.ErrLoanNotFound, codes.NotFound},
+	{models.ErrProductNotFound, codes.InvalidArgument},
+	{models.ErrPaymentMethodNotFound, codes.FailedPrecondition}, // The payment's method, not the resource called
+	{models.ErrLoanNotActive, codes.FailedPrecondition},
+	{models.ErrCustomerNotActive, codes.FailedPrecondition},
+	{models.ErrInsufficientCredit, codes.FailedPrecondition},
+	{models.ErrNoEscrowAccount, codes.FailedPrecondition},
+	{models.ErrNotLineOfCredit, codes.FailedPrecondition},
+	{models.ErrEscrowExceedsPayment, codes.FailedPrecondition},
+	{models.ErrPaymentMethodNotVerified, codes.FailedPrecondition},
+	{models.ErrPaymentMethodExpired, codes.FailedPrecondition},
+	{models.ErrPaymentMethodNotCustomers, codes.FailedPrecondition},
+	{models.ErrLoanVersionMismatch, codes.Aborted},
+	{models.ErrIdempotencyKeyInUse, codes.Aborted},
+	{models.ErrIdempotencyKeyMismatch, codes.InvalidArgument},
+}
+
+// statusError converts an error returned by the ledger to a gRPC status: the mapped code for
+// a known error value, InvalidArgument for a models.ValidationError, and Internal otherwise.
+// The message of an internal error is logged rather than returned, so database errors and
+// the like are not exposed to callers.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return status.Error(known.code, err.Error())
+		}
+	}
+	var invalid *models.ValidationError
+	if errors.As(err, &invalid) {
+		return status.Error(codes.InvalidArgument, invalid.Message)
+	}
+	slog.Error("Internal error", "err", err)
+	return status.Error(codes.Internal, "an internal error occurred")
+}
+
+// parseID parses a UUID field of a request.
+func parseID(field string, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s", field)
+	}
+	return id, nil
+}
+
+// validator collects every problem with a request so they can be reported together.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) add(problem string) {
+	v.problems = append(v.problems, problem)
+}
+
+// check records problem unless ok.
+func (v *validator) check(ok bool, problem string) {
+	if !ok {
+		v.add(problem)
+	}
+}
+
+// required checks that a string field is present.
+func (v *validator) required(field string, value string) {
+	v.check(value != "", field+" is required")
+}
+
+// decimal parses a decimal string field. An empty field is zero.
+func (v *validator) decimal(field string, value string) decimal.Decimal {
+	if value == "" {
+		return decimal.Zero
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		v.add(field + " must be a decimal number")
+	}
+	return d
+}
+
+// err returns an InvalidArgument status listing the problems found, or nil if there are none.
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return status.Error(codes.InvalidArgument, strings.Join(v.problems, "; "))
+}
@@ -0,0 +1,184 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/grpcapi/fredloanv1"
+	"github.com/mcclellann/fredLoan/pkg/ledger"
+	"github.com/mcclellann/fredLoan/pkg/oidc"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startTestServer serves the ledger service over an in-memory connection and returns a
+// client for it.
+func startTestServer(t *testing.T, service *Server) fredloanv1.LedgerServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := service.NewGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return fredloanv1.NewLedgerServiceClient(conn)
+}
+
+func TestLedgerService(t *testing.T) {
+	ctx := context.Background()
+	client := startTestServer(t, NewServer(ledger.NewLedger(store.NewMemoryStore())))
+
+	loan, err := client.CreateLoan(ctx, &fredloanv1.CreateLoanRequest{CustomerKey: "cust-1", Principal: "1000.00", BaseInterestRate: "0.10"})
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	if loan.Balance != "1000" || loan.Status != "active" {
+		t.Errorf("Expected an active loan with balance 1000, got %s with %s", loan.Status, loan.Balance)
+	}
+	got, err := client.GetLoan(ctx, &fredloanv1.GetLoanRequest{Id: loan.Id})
+	if err != nil || got.Id != loan.Id {
+		t.Fatalf("Expected to get the loan, got %v (%v)", got, err)
+	}
+
+	payment := &fredloanv1.RecordPaymentRequest{LoanId: loan.Id, Amount: "100.00", IdempotencyKey: "payment-1"}
+	first, err := client.RecordPayment(ctx, payment)
+	if err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	retry, err := client.RecordPayment(ctx, payment)
+	if err != nil || retry.Id != first.Id {
+		t.Errorf("Expected the retry to return the payment first recorded, got %v (%v)", retry, err)
+	}
+	_, err = client.RecordPayment(ctx, &fredloanv1.RecordPaymentRequest{LoanId: loan.Id, Amount: "50.00", IdempotencyKey: "payment-1"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected a key reused for another payment to be refused, got %v", err)
+	}
+
+	list, err := client.ListTransactions(ctx, &fredloanv1.ListTransactionsRequest{LoanId: loan.Id})
+	if err != nil {
+		t.Fatalf("Failed to list transactions: %v", err)
+	}
+	var payments int
+	for _, tx := range list.Transactions {
+		if tx.Type == "payment" {
+			payments++
+		}
+	}
+	if len(list.Transactions) != 2 || payments != 1 {
+		t.Errorf("Expected the disbursement and one payment, got %v", list.Transactions)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+	}{
+		{"unknown loan", func() error {
+			_, err := client.GetLoan(ctx, &fredloanv1.GetLoanRequest{Id: "00000000-0000-0000-0000-000000000001"})
+			return err
+		}, codes.NotFound},
+		{"malformed ID", func() error {
+			_, err := client.ListTransactions(ctx, &fredloanv1.ListTransactionsRequest{LoanId: "loan-1"})
+			return err
+		}, codes.InvalidArgument},
+		{"malformed amount", func() error {
+			_, err := client.RecordPayment(ctx, &fredloanv1.RecordPaymentRequest{LoanId: loan.Id, Amount: "ten"})
+			return err
+		}, codes.InvalidArgument},
+		{"missing customer", func() error {
+			_, err := client.CreateLoan(ctx, &fredloanv1.CreateLoanRequest{Principal: "1000.00"})
+			return err
+		}, codes.InvalidArgument},
+		{"unknown product", func() error {
+			_, err := client.CreateLoan(ctx, &fredloanv1.CreateLoanRequest{CustomerKey: "cust-1", Principal: "1000.00", ProductCode: "NOPE"})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); status.Code(err) != tt.code {
+			t.Errorf("%s: expected %s, got %v", tt.name, tt.code, err)
+		}
+	}
+}
+
+func TestLedgerService_StreamAccruals(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	l := ledger.NewLedger(store.NewMemoryStore())
+	client := startTestServer(t, NewServer(l))
+
+	loan, err := client.CreateLoan(ctx, &fredloanv1.CreateLoanRequest{CustomerKey: "cust-1", Principal: "1000.00", BaseInterestRate: "0.10"})
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	stream, err := client.StreamAccruals(ctx, &fredloanv1.StreamAccrualsRequest{LoanId: loan.Id})
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	// Whether the accrual is recorded before the stream subscribes or after, it is sent: from
+	// the history or live
+	l.CalculateDailyInterest(ctx)
+
+	accrual, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Failed to receive accrual: %v", err)
+	}
+	if accrual.LoanId != loan.Id || accrual.Amount == "" || accrual.Rate != "0.1" {
+		t.Errorf("Expected the loan's accrual at 0.1, got %v", accrual)
+	}
+}
+
+// testVerifier accepts tokens of the form "subject:role,role".
+type testVerifier struct{}
+
+func (testVerifier) Verify(token string) (*oidc.Claims, error) {
+	subject, roles, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, errors.New("malformed token")
+	}
+	return &oidc.Claims{Subject: subject, Roles: strings.Split(roles, ",")}, nil
+}
+
+func TestLedgerService_Authentication(t *testing.T) {
+	ctx := context.Background()
+	service := NewServer(ledger.NewLedger(store.NewMemoryStore()))
+	service.SetTokenVerifier(testVerifier{})
+	client := startTestServer(t, service)
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+	create := &fredloanv1.CreateLoanRequest{CustomerKey: "cust-1", Principal: "1000.00", BaseInterestRate: "0.10"}
+
+	if _, err := client.CreateLoan(ctx, create); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected a call without a token to be refused, got %v", err)
+	}
+	if _, err := client.CreateLoan(withToken("garbage"), create); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected an invalid token to be refused, got %v", err)
+	}
+	if _, err := client.CreateLoan(withToken("reader:read-only"), create); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected a read-only caller not to create loans, got %v", err)
+	}
+	loan, err := client.CreateLoan(withToken("svc:servicer"), create)
+	if err != nil {
+		t.Fatalf("Expected a servicer to create a loan, got %v", err)
+	}
+	if _, err := client.GetLoan(withToken("reader:read-only"), &fredloanv1.GetLoanRequest{Id: loan.Id}); err != nil {
+		t.Errorf("Expected a read-only caller to get a loan, got %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// WithAccrualGrace delays the start of interest accrual until the given number of days after
+// disbursement, overriding the product's grace period.
+func WithAccrualGrace(days int) LoanOption {
+	return func(loan *models.Loan) {
+		if days <= 0 {
+			return
+		}
+		start := loan.CreatedAt.UTC().Truncate(24*time.Hour).AddDate(0, 0, days)
+		loan.AccrualStartDate = &start
+	}
+}
+
+// applyAccrualGrace sets the loan's accrual start date from its product's grace period,
+// unless the loan was given its own.
+func applyAccrualGrace(loan *models.Loan, product *models.Product) {
+	if loan.AccrualStartDate != nil || product == nil || product.AccrualGraceDays == 0 {
+		return
+	}
+	WithAccrualGrace(product.AccrualGraceDays)(loan)
+}
+
+// beforeAccrualStart reports whether the day falls in the loan's grace period, before
+// interest starts accruing.
+func beforeAccrualStart(loan *models.Loan, day time.Time) bool {
+	return loan.AccrualStartDate != nil && day.Before(loan.AccrualStartDate.UTC())
+}
@@ -0,0 +1,21 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// GetAccruals retrieves a loan's daily accruals from through to (inclusive), so the interest
+// accrued on each day can be audited against the balance and rate it was computed from.
+func (l *Ledger) GetAccruals(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	if to.Before(from) {
+		return nil, models.Invalidf("invalid date range: from is after to")
+	}
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetAccrualsForLoan(ctx, loanID, from.UTC().Truncate(24*time.Hour), to.UTC().Truncate(24*time.Hour))
+}
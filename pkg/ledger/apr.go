@@ -0,0 +1,37 @@
+package ledger
+
+import (
+	"math"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// APYFromAPR converts a nominal annual rate to the effective annual yield under the given
+// interest mode. Compound interest is capitalized monthly, so it earns interest on interest
+// twelve times a year; simple interest never does, so its yield is the nominal rate.
+func APYFromAPR(apr decimal.Decimal, mode models.InterestMode) decimal.Decimal {
+	if mode == models.InterestSimple {
+		return apr
+	}
+	return one.Add(apr.Div(monthsInYear)).Pow(monthsInYear).Sub(one)
+}
+
+// APRFromAPY converts an effective annual yield back to the nominal annual rate that
+// produces it under the given interest mode.
+func APRFromAPY(apy decimal.Decimal, mode models.InterestMode) decimal.Decimal {
+	if mode == models.InterestSimple {
+		return apy
+	}
+	monthly := math.Pow(1+apy.InexactFloat64(), 1/12.0) - 1
+	return decimal.NewFromFloat(monthly).Mul(monthsInYear).Round(8)
+}
+
+// setDisclosureRates fills in the computed APR and APY of a loan at the rate accruing today.
+func setDisclosureRates(loan *models.Loan) {
+	apr := accrualRate(loan, time.Now().UTC().Truncate(24*time.Hour))
+	apy := APYFromAPR(apr, loan.InterestMode).Round(6)
+	loan.APR = &apr
+	loan.APY = &apy
+}
@@ -0,0 +1,131 @@
+package ledger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// archiveExportBatch is how many loans each object of an archive export holds.
+const archiveExportBatch = 500
+
+// ArchiveUploader stores the objects an archive export writes, such as an S3 bucket.
+type ArchiveUploader interface {
+	PutObject(ctx context.Context, key string, body []byte, contentType string) error
+}
+
+// archivedLoanRecord is one line of an archive export: a loan and everything archived with it.
+type archivedLoanRecord struct {
+	Loan         *models.Loan          `json:"loan"`
+	Transactions []*models.Transaction `json:"transactions"`
+	Events       []*models.LoanEvent   `json:"events"`
+	RateHistory  []*models.RateChange  `json:"rate_history"`
+}
+
+// ArchiveClosedLoans moves loans that have been closed for longer than the given number
+// of months into cold storage, keeping the tables scanned by the batch jobs small.
+func (l *Ledger) ArchiveClosedLoans(ctx context.Context, olderThanMonths int) (int, error) {
+	if olderThanMonths < 0 {
+		return 0, models.Invalidf("archive age must not be negative")
+	}
+	cutoff := time.Now().AddDate(0, -olderThanMonths, 0)
+	return l.storage.ArchiveClosedLoans(ctx, cutoff)
+}
+
+// GetArchivedLoan retrieves a loan from cold storage by its ID.
+func (l *Ledger) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	return l.storage.GetArchivedLoan(ctx, id)
+}
+
+// GetArchivedTransactions retrieves the transaction history of an archived loan.
+func (l *Ledger) GetArchivedTransactions(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	return l.storage.GetArchivedTransactionsForLoan(ctx, loanID)
+}
+
+// ExportArchive writes loans closed for longer than the given number of years, with their
+// transactions, events and rate history, to object storage through uploader. Closed loans of
+// that age still in the hot tables are archived first. Each object is gzip-compressed JSON
+// Lines, one loan per line, holding a batch of loans under
+// loans/YYYY/MM/DD/HHMMSS-RUN-NNNN.jsonl.gz, for the run's start time and a random run ID
+// that keeps runs from overwriting each other's objects. Once an object is stored, its
+// loans are marked exported, or with prune deleted from the archive, so the next run passes
+// over them. A run cut short between the two exports its last batch again next time.
+func (l *Ledger) ExportArchive(ctx context.Context, uploader ArchiveUploader, olderThanYears int, prune bool) (*models.ArchiveExport, error) {
+	if olderThanYears < 1 {
+		return nil, models.Invalidf("invalid archive export age: must be at least one year")
+	}
+	start := time.Now().UTC()
+	run := uuid.NewString()[:8]
+	cutoff := start.AddDate(-olderThanYears, 0, 0)
+	if _, err := l.storage.ArchiveClosedLoans(ctx, cutoff); err != nil {
+		return nil, err
+	}
+
+	export := &models.ArchiveExport{Objects: []string{}, Pruned: prune}
+	for {
+		loans, err := l.storage.GetArchivedLoansToExport(ctx, cutoff, archiveExportBatch)
+		if err != nil || len(loans) == 0 {
+			return export, err
+		}
+		body, transactions, err := l.encodeArchivedLoans(ctx, loans)
+		if err != nil {
+			return export, err
+		}
+		key := fmt.Sprintf("loans/%s-%s-%04d.jsonl.gz", start.Format("2006/01/02/150405"), run, len(export.Objects)+1)
+		if err := uploader.PutObject(ctx, key, body, "application/gzip"); err != nil {
+			return export, err
+		}
+
+		ids := make([]uuid.UUID, len(loans))
+		for i, loan := range loans {
+			ids[i] = loan.ID
+		}
+		if prune {
+			err = l.storage.DeleteArchivedLoans(ctx, ids)
+		} else {
+			err = l.storage.MarkArchivedLoansExported(ctx, ids, time.Now())
+		}
+		if err != nil {
+			return export, fmt.Errorf("exported %s but failed to record it: %w", key, err)
+		}
+		export.Objects = append(export.Objects, key)
+		export.Loans += len(loans)
+		export.Transactions += transactions
+	}
+}
+
+// encodeArchivedLoans returns the archived loans with their records as gzip-compressed JSON
+// Lines, and the number of transactions among them.
+func (l *Ledger) encodeArchivedLoans(ctx context.Context, loans []*models.Loan) ([]byte, int, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	transactions := 0
+	for _, loan := range loans {
+		record := archivedLoanRecord{Loan: loan}
+		var err error
+		if record.Transactions, err = l.storage.GetArchivedTransactionsForLoan(ctx, loan.ID); err != nil {
+			return nil, 0, err
+		}
+		if record.Events, err = l.storage.GetArchivedLoanEventsForLoan(ctx, loan.ID); err != nil {
+			return nil, 0, err
+		}
+		if record.RateHistory, err = l.storage.GetArchivedRateHistory(ctx, loan.ID); err != nil {
+			return nil, 0, err
+		}
+		if err := enc.Encode(record); err != nil {
+			return nil, 0, err
+		}
+		transactions += len(record.Transactions)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), transactions, nil
+}
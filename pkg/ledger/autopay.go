@@ -0,0 +1,146 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithSource records the channel that originated a payment.
+func WithSource(source string) PaymentOption {
+	return func(tx *models.Transaction) {
+		tx.Source = source
+	}
+}
+
+// EnrollAutopay enrolls an active loan in autopay, replacing any existing enrollment. The
+// payment method must be verified and belong to the loan's customer.
+func (l *Ledger) EnrollAutopay(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	loan, err := l.storage.GetLoan(ctx, enrollment.LoanID)
+	if err != nil {
+		return err
+	}
+	if !loan.Status.IsOpen() {
+		return models.ErrLoanNotActive
+	}
+
+	switch enrollment.AmountType {
+	case models.AutopayFixedAmount:
+		if !enrollment.Amount.IsPositive() {
+			return models.Invalidf("autopay amount must be positive")
+		}
+	case models.AutopayMinimumDue, models.AutopayStatementBalance:
+		enrollment.Amount = decimal.Zero
+	default:
+		return models.Invalidf("invalid autopay amount type %q", enrollment.AmountType)
+	}
+	if enrollment.DayOfMonth < minStatementDay || enrollment.DayOfMonth > maxStatementDay {
+		return models.Invalidf("autopay day of month must be between %d and %d", minStatementDay, maxStatementDay)
+	}
+	if err := l.usablePaymentMethod(ctx, enrollment.PaymentMethodID, loan); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	enrollment.CreatedAt = now
+	if existing, err := l.storage.GetAutopayEnrollment(ctx, loan.ID); err != nil {
+		return err
+	} else if existing != nil {
+		enrollment.CreatedAt = existing.CreatedAt
+	}
+	enrollment.UpdatedAt = now
+	return l.storage.SaveAutopayEnrollment(ctx, enrollment)
+}
+
+// GetAutopayEnrollment retrieves a loan's autopay enrollment.
+func (l *Ledger) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	enrollment, err := l.storage.GetAutopayEnrollment(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		return nil, models.ErrNotEnrolledInAutopay
+	}
+	return enrollment, nil
+}
+
+// CancelAutopay removes a loan's autopay enrollment.
+func (l *Ledger) CancelAutopay(ctx context.Context, loanID uuid.UUID) error {
+	return l.storage.DeleteAutopayEnrollment(ctx, loanID)
+}
+
+// ProcessAutopay posts the payments scheduled for today. Each enrollment pays at most once
+// per month, so the batch can run more than once a day.
+func (l *Ledger) ProcessAutopay(ctx context.Context) {
+	now := time.Now()
+	enrollments, err := l.storage.GetAutopayEnrollmentsForDay(ctx, now.Day())
+	if err != nil {
+		fmt.Printf("Error getting autopay enrollments: %v\n", err)
+		return
+	}
+
+	for _, enrollment := range enrollments {
+		tx, err := l.postAutopay(ctx, enrollment, statementCycle(now))
+		if err != nil {
+			fmt.Printf("Error posting autopay for loan %s: %v\n", enrollment.LoanID, err)
+			continue
+		}
+		if tx != nil {
+			fmt.Printf("Posted autopay of %s for Loan %s\n", tx.Amount.StringFixed(2), enrollment.LoanID)
+		}
+	}
+}
+
+// postAutopay makes the enrollment's payment for the month, returning nil if there is
+// nothing to pay or the month's payment was already made.
+func (l *Ledger) postAutopay(ctx context.Context, enrollment *models.AutopayEnrollment, cycle string) (*models.Transaction, error) {
+	loan, err := l.storage.GetLoan(ctx, enrollment.LoanID)
+	if err != nil {
+		return nil, err
+	}
+	if !loan.Status.IsOpen() {
+		return nil, nil
+	}
+
+	transactions, err := l.storage.GetTransactionsForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range transactions {
+		if tx.Source == models.TransactionSourceAutopay && statementCycle(tx.Timestamp) == cycle {
+			return nil, nil
+		}
+	}
+
+	amount := enrollment.Amount
+	if enrollment.AmountType != models.AutopayFixedAmount {
+		statements, err := l.storage.GetStatementsForLoan(ctx, loan.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(statements) == 0 {
+			return nil, nil
+		}
+		latest := statements[len(statements)-1]
+		amount = latest.Balance
+		if enrollment.AmountType == models.AutopayMinimumDue {
+			amount = latest.MinimumDue
+		}
+		// Payments already made since the statement count towards it
+		for _, tx := range transactions {
+			if tx.Type == models.TransactionTypePayment && !tx.Timestamp.Before(latest.StatementDate) {
+				amount = amount.Sub(tx.Amount)
+			}
+		}
+	}
+	amount = decimal.Min(amount, loan.Balance)
+	if !amount.IsPositive() {
+		return nil, nil
+	}
+
+	return l.RecordPayment(ctx, loan.ID, amount, WithPaymentMethod(enrollment.PaymentMethodID), WithSource(models.TransactionSourceAutopay))
+}
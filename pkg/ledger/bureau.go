@@ -0,0 +1,122 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/metro2"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// reportingPeriodLayout is the format of bureau reporting periods.
+const reportingPeriodLayout = "2006-01"
+
+// BuildBureauRecords produces the credit bureau records for a reporting period (YYYY-MM):
+// one per loan open at any point in the period, optionally restricted to a single product.
+// Each record is stored so that later periods can report it in their payment history, and
+// rebuilding a period replaces its records. Balances are those at the time of the build.
+func (l *Ledger) BuildBureauRecords(ctx context.Context, period string, productCode string) ([]*models.BureauRecord, error) {
+	start, err := time.Parse(reportingPeriodLayout, period)
+	if err != nil {
+		return nil, models.Invalidf("invalid reporting period %q", period)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if start.After(today) {
+		return nil, models.Invalidf("reporting period %s has not started", period)
+	}
+	next := start.AddDate(0, 1, 0)
+	asOf := next.AddDate(0, 0, -1)
+	if asOf.After(today) {
+		asOf = today
+	}
+
+	loans, err := l.reportReader().GetAllLoans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loans for bureau reporting: %w", err)
+	}
+	loans = withoutVoided(loans)
+
+	records := []*models.BureauRecord{}
+	for _, loan := range loans {
+		if productCode != "" && loan.ProductCode != productCode {
+			continue
+		}
+		if !loan.CreatedAt.Before(next) {
+			continue
+		}
+		// Closed loans are reported once more, as paid, in the period they closed in
+		if loan.Status == models.LoanStatusClosed && loan.UpdatedAt.Before(start) {
+			continue
+		}
+
+		record, err := l.bureauRecord(ctx, loan, period, start, asOf)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.storage.SaveBureauRecord(ctx, record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (l *Ledger) bureauRecord(ctx context.Context, loan *models.Loan, period string, start time.Time, asOf time.Time) (*models.BureauRecord, error) {
+	dpd := 0
+	switch loan.Status {
+	case models.LoanStatusActive, models.LoanStatusDelinquent:
+		var err error
+		if dpd, err = l.loanDaysPastDue(ctx, loan, asOf); err != nil {
+			return nil, err
+		}
+	case models.LoanStatusChargedOff:
+		dpd = loan.DaysPastDue
+	}
+
+	earlier, err := l.reportReader().GetBureauRecordsForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+	statusByPeriod := make(map[string]string, len(earlier))
+	for _, record := range earlier {
+		statusByPeriod[record.Period] = record.AccountStatus
+	}
+	previous := make([]string, metro2.PaymentHistoryMonths)
+	for i := range previous {
+		previous[i] = statusByPeriod[start.AddDate(0, -(i+1), 0).Format(reportingPeriodLayout)]
+	}
+
+	return &models.BureauRecord{
+		LoanID:         loan.ID,
+		Period:         period,
+		CustomerKey:    loan.CustomerKey,
+		ProductCode:    loan.ProductCode,
+		DateOpened:     loan.CreatedAt.UTC().Truncate(24 * time.Hour),
+		OriginalAmount: loan.Principal,
+		CurrentBalance: loan.Balance,
+		TermMonths:     loan.TermMonths,
+		AccountStatus:  metro2.AccountStatus(string(loan.Status), dpd),
+		DaysPastDue:    dpd,
+		PaymentHistory: metro2.PaymentHistory(previous),
+		AsOf:           asOf,
+		CreatedAt:      time.Now(),
+	}, nil
+}
+
+// ExportBureauFile builds the bureau records for a reporting period and writes them to w in
+// the given fixed-width format. It returns the number of records written.
+func (l *Ledger) ExportBureauFile(ctx context.Context, w io.Writer, format metro2.Format, period string, productCode string) (int, error) {
+	if err := format.Validate(); err != nil {
+		return 0, models.Invalidf("invalid bureau format: %v", err)
+	}
+	records, err := l.BuildBureauRecords(ctx, period, productCode)
+	if err != nil {
+		return 0, err
+	}
+	if err := metro2.Write(w, format, records); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
@@ -0,0 +1,139 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// SetAutoChargeOff configures the number of days past due after which the daily batch
+// charges off a loan automatically. Zero disables automatic charge-off.
+func (l *Ledger) SetAutoChargeOff(daysPastDue int) {
+	l.autoChargeOffDays = daysPastDue
+}
+
+// ChargeOffLoan writes off an active loan's outstanding receivable. Accrued interest is
+// capitalized first so the charge-off amount reflects everything the borrower owes; the
+// balance is retained so later recoveries can be tracked against it.
+func (l *Ledger) ChargeOffLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	var chargedOff *models.Loan
+	err := l.changeLoan(ctx, id, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		if !loan.Status.IsOpen() {
+			return models.ErrLoanNotActive
+		}
+
+		previousStatus := loan.Status
+		now := time.Now()
+		loan.Balance = loan.Balance.Add(loan.AccruedInterest).Add(amountsDue(loan))
+		loan.AccruedInterest = decimal.Zero
+		loan.FeesDue = decimal.Zero
+		loan.InterestDue = decimal.Zero
+		loan.ChargeOffAmount = loan.Balance
+		loan.ChargedOffAt = &now
+		loan.Status = models.LoanStatusChargedOff
+		loan.UpdatedAt = now
+
+		transaction := &models.Transaction{
+			ID:        uuid.New(),
+			LoanID:    loan.ID,
+			Amount:    loan.ChargeOffAmount,
+			Type:      models.TransactionTypeChargeOff,
+			Timestamp: now,
+		}
+		if err := tl.updateLoanWithTransactions(ctx, loan, transaction); err != nil {
+			return fmt.Errorf("failed to charge off loan: %w", err)
+		}
+		chargedOff = loan
+		return tl.recordStatusChange(ctx, loan.ID, previousStatus, loan.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return chargedOff, nil
+}
+
+// AutoChargeOff charges off every active loan whose days past due have reached the
+// configured threshold. It does nothing when automatic charge-off is disabled.
+func (l *Ledger) AutoChargeOff(ctx context.Context) {
+	if l.autoChargeOffDays <= 0 {
+		return
+	}
+
+	loans, err := l.storage.GetDelinquentLoans(ctx, l.autoChargeOffDays)
+	if err != nil {
+		fmt.Printf("Error getting delinquent loans for automatic charge-off: %v\n", err)
+		return
+	}
+
+	for _, loan := range loans {
+		chargedOff, err := l.ChargeOffLoan(ctx, loan.ID)
+		if err != nil {
+			fmt.Printf("Error charging off loan %s: %v\n", loan.ID, err)
+			continue
+		}
+		fmt.Printf("Charged off Loan %s at %d days past due (Amount: %s)\n", chargedOff.ID, loan.DaysPastDue, chargedOff.ChargeOffAmount.StringFixed(2))
+	}
+}
+
+// CalculatePostChargeOffInterest accrues daily interest on charged-off loans whose product
+// continues accrual for legal recovery. The interest is kept in PostChargeOffInterest, apart
+// from AccruedInterest and the balance, so it never appears in customer-facing amounts.
+func (l *Ledger) CalculatePostChargeOffInterest(ctx context.Context) {
+	loans, err := l.storage.GetLoansByStatus(ctx, models.LoanStatusChargedOff)
+	if err != nil {
+		fmt.Printf("Error getting charged-off loans for recovery interest calculation: %v\n", err)
+		return
+	}
+
+	products, err := l.productsByCode(ctx)
+	if err != nil {
+		fmt.Printf("Error getting products for recovery interest calculation: %v\n", err)
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	for _, listed := range loans {
+		product, ok := products[listed.ProductCode]
+		if !ok || !product.AccrueAfterChargeOff {
+			continue
+		}
+
+		// The loan is read again as it is updated, so a recovery posted since it was listed
+		// is kept
+		var interestAmount decimal.Decimal
+		var loan *models.Loan
+		err := l.changeLoan(ctx, listed.ID, func(ctx context.Context, tl *Ledger, current *models.Loan) error {
+			loan, interestAmount = current, decimal.Zero
+			if loan.Status != models.LoanStatusChargedOff {
+				return nil
+			}
+			if loan.LastInterestCalculationDate != nil && loan.LastInterestCalculationDate.UTC().Truncate(24*time.Hour).Equal(today) {
+				return nil
+			}
+
+			interestAmount = roundAccrual(l.rounding, loan, DailyInterest(loan.Balance, loan.InterestRate))
+			if !interestAmount.GreaterThan(decimal.Zero) {
+				return nil
+			}
+
+			loan.PostChargeOffInterest = loan.PostChargeOffInterest.Add(interestAmount)
+			loan.LastInterestCalculationDate = &today
+			loan.UpdatedAt = time.Now()
+			return tl.storage.UpdateLoanIfVersion(ctx, loan, loan.Version)
+		})
+		if err != nil {
+			fmt.Printf("Error updating loan %s during recovery interest calculation: %v\n", listed.ID, err)
+			continue
+		}
+		if !interestAmount.IsPositive() {
+			continue
+		}
+
+		fmt.Printf("Accrued %s recovery interest for charged-off Loan %s (Total: %s)\n", interestAmount.StringFixed(2), loan.ID, loan.PostChargeOffInterest.StringFixed(2))
+	}
+}
@@ -0,0 +1,127 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// validateCollateral checks a collateral record's type and valuation.
+func validateCollateral(collateral *models.Collateral) error {
+	if !collateral.Type.Valid() {
+		return models.Invalidf("invalid collateral type: %s", collateral.Type)
+	}
+	if !collateral.Valuation.IsPositive() {
+		return models.Invalidf("invalid collateral valuation: must be positive")
+	}
+	if collateral.ValuationDate.After(time.Now()) {
+		return models.Invalidf("invalid collateral valuation date: must not be in the future")
+	}
+	return nil
+}
+
+// AddCollateral pledges an asset to secure a loan. A zero valuation date defaults to today.
+func (l *Ledger) AddCollateral(ctx context.Context, loanID uuid.UUID, collateralType models.CollateralType, description string, valuation decimal.Decimal, valuationDate time.Time) (*models.Collateral, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if valuationDate.IsZero() {
+		valuationDate = now.UTC().Truncate(24 * time.Hour)
+	}
+	collateral := &models.Collateral{
+		ID:            uuid.New(),
+		LoanID:        loanID,
+		Type:          collateralType,
+		Description:   description,
+		Valuation:     valuation,
+		ValuationDate: valuationDate,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := validateCollateral(collateral); err != nil {
+		return nil, err
+	}
+	if err := l.storage.CreateCollateral(ctx, collateral); err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+// GetCollateral retrieves one of a loan's collateral records.
+func (l *Ledger) GetCollateral(ctx context.Context, loanID uuid.UUID, id uuid.UUID) (*models.Collateral, error) {
+	collateral, err := l.storage.GetCollateral(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if collateral.LoanID != loanID {
+		return nil, models.ErrCollateralNotFound
+	}
+	return collateral, nil
+}
+
+// GetCollateralForLoan lists the collateral securing a loan.
+func (l *Ledger) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetCollateralForLoan(ctx, loanID)
+}
+
+// UpdateCollateral replaces a collateral record's type, description and valuation, e.g.
+// after a reappraisal. A zero valuation date defaults to today.
+func (l *Ledger) UpdateCollateral(ctx context.Context, loanID uuid.UUID, id uuid.UUID, collateralType models.CollateralType, description string, valuation decimal.Decimal, valuationDate time.Time) (*models.Collateral, error) {
+	collateral, err := l.GetCollateral(ctx, loanID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if valuationDate.IsZero() {
+		valuationDate = now.UTC().Truncate(24 * time.Hour)
+	}
+	collateral.Type = collateralType
+	collateral.Description = description
+	collateral.Valuation = valuation
+	collateral.ValuationDate = valuationDate
+	collateral.UpdatedAt = now
+	if err := validateCollateral(collateral); err != nil {
+		return nil, err
+	}
+	if err := l.storage.UpdateCollateral(ctx, collateral); err != nil {
+		return nil, err
+	}
+	return collateral, nil
+}
+
+// DeleteCollateral releases a collateral record from a loan.
+func (l *Ledger) DeleteCollateral(ctx context.Context, loanID uuid.UUID, id uuid.UUID) error {
+	if _, err := l.GetCollateral(ctx, loanID, id); err != nil {
+		return err
+	}
+	return l.storage.DeleteCollateral(ctx, id)
+}
+
+// setLoanToValue fills in the computed LoanToValue of a loan secured by collateral: its
+// balance over the total collateral valuation, rounded to four places.
+func (l *Ledger) setLoanToValue(ctx context.Context, loan *models.Loan) error {
+	collateral, err := l.storage.GetCollateralForLoan(ctx, loan.ID)
+	if err != nil {
+		return err
+	}
+	loan.LoanToValue = nil
+	total := decimal.Zero
+	for _, item := range collateral {
+		total = total.Add(item.Valuation)
+	}
+	if !total.IsPositive() {
+		return nil
+	}
+	ltv := loan.Balance.Div(total).Round(4)
+	loan.LoanToValue = &ltv
+	return nil
+}
@@ -0,0 +1,108 @@
+package ledger
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// CreateCustomer registers a new customer. The status defaults to active.
+func (l *Ledger) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	if customer.CustomerKey == "" {
+		return models.Invalidf("customer key is required")
+	}
+	if customer.Status == "" {
+		customer.Status = models.CustomerStatusActive
+	}
+	if !customer.Status.Valid() {
+		return models.Invalidf("invalid customer status: %q", customer.Status)
+	}
+	customer.ID = uuid.New()
+	customer.CreatedAt = time.Now()
+	customer.UpdatedAt = customer.CreatedAt
+	return l.storage.CreateCustomer(ctx, customer)
+}
+
+// GetCustomer retrieves a customer by their customer key.
+func (l *Ledger) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	return l.storage.GetCustomer(ctx, customerKey)
+}
+
+// GetAllCustomers retrieves all customers ordered by customer key.
+func (l *Ledger) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	return l.storage.GetAllCustomers(ctx)
+}
+
+// UpdateCustomer replaces a customer's details, and their status unless it is left empty.
+// Making a customer inactive keeps their existing loans in service but stops new loans being
+// opened for them.
+func (l *Ledger) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	existing, err := l.storage.GetCustomer(ctx, customer.CustomerKey)
+	if err != nil {
+		return err
+	}
+	if customer.Status == "" {
+		customer.Status = existing.Status
+	}
+	if !customer.Status.Valid() {
+		return models.Invalidf("invalid customer status: %q", customer.Status)
+	}
+	customer.ID = existing.ID
+	customer.CreatedAt = existing.CreatedAt
+	customer.UpdatedAt = time.Now()
+	return l.storage.UpdateCustomer(ctx, customer)
+}
+
+// DeleteCustomer deletes a customer who has never had a loan. Customers with loans, even
+// voided or archived ones, are kept for the loans' record and can be made inactive instead.
+func (l *Ledger) DeleteCustomer(ctx context.Context, customerKey string) error {
+	return l.storage.DeleteCustomer(ctx, customerKey)
+}
+
+// checkCustomerActive returns models.ErrCustomerNotActive if a customer takes no new loans,
+// and models.ErrCustomerNotFound if there is no such customer.
+func (l *Ledger) checkCustomerActive(ctx context.Context, customerKey string) error {
+	customer, err := l.storage.GetCustomer(ctx, customerKey)
+	if err != nil {
+		return err
+	}
+	if customer.Status != models.CustomerStatusActive {
+		return models.ErrCustomerNotActive
+	}
+	return nil
+}
+
+// GetCustomerLoans retrieves a customer's loans, oldest first.
+func (l *Ledger) GetCustomerLoans(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	loans, err := l.storage.GetLoansByCustomerKey(ctx, customerKey)
+	if err != nil {
+		return nil, err
+	}
+	loans = withoutVoided(loans)
+	for _, loan := range loans {
+		setAvailableCredit(loan)
+		setDisclosureRates(loan)
+	}
+	return loans, nil
+}
+
+// GetCustomerSummary totals a customer's loans and gives the next statement date of each of
+// their open loans, soonest first.
+func (l *Ledger) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	summary, err := l.storage.GetCustomerSummary(ctx, customerKey)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range summary.NextStatements {
+		next := &summary.NextStatements[i]
+		next.StatementDate = nextStatementDate(now, next.StatementCycleDay)
+	}
+	sort.SliceStable(summary.NextStatements, func(i, j int) bool {
+		return summary.NextStatements[i].StatementDate.Before(summary.NextStatements[j].StatementDate)
+	})
+	return summary, nil
+}
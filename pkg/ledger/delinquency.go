@@ -0,0 +1,199 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// paymentGracePeriodDays is the number of days after a statement date before the payment
+// it bills is due.
+const paymentGracePeriodDays = 25
+
+// delinquentStatusDays is the number of days past due at which an active loan's status
+// becomes delinquent.
+const delinquentStatusDays = 30
+
+// nextStatementDate returns the first statement date strictly after the given time.
+func nextStatementDate(after time.Time, cycleDay int) time.Time {
+	after = after.UTC()
+	d := time.Date(after.Year(), after.Month(), cycleDay, 0, 0, 0, 0, time.UTC)
+	if !d.After(after) {
+		d = d.AddDate(0, 1, 0)
+	}
+	return d
+}
+
+// PaymentDueDate returns the date by which the loan's next payment is due: the grace period
+// after the first statement following the last payment (or disbursement, if none).
+func PaymentDueDate(loan *models.Loan) time.Time {
+	reference := loan.CreatedAt
+	if loan.LastPaymentDate != nil {
+		reference = *loan.LastPaymentDate
+	}
+	return nextStatementDate(reference, loan.StatementCycleDay).AddDate(0, 0, paymentGracePeriodDays)
+}
+
+// daysPastDue returns how many whole days the loan's payment is overdue as of today.
+func daysPastDue(loan *models.Loan, today time.Time) int {
+	due := PaymentDueDate(loan)
+	if !today.After(due) {
+		return 0
+	}
+	return int(today.Sub(due).Hours() / 24)
+}
+
+// loanDaysPastDue ages a loan against the minimum due on its statements. Loans without
+// statements yet are aged from their last payment, as in daysPastDue. Days spent in
+// forbearance are not counted.
+func (l *Ledger) loanDaysPastDue(ctx context.Context, loan *models.Loan, today time.Time) (int, error) {
+	statements, err := l.storage.GetStatementsForLoan(ctx, loan.ID)
+	if err != nil {
+		return 0, err
+	}
+	dpd := daysPastDue(loan, today)
+	if len(statements) > 0 {
+		transactions, err := l.storage.GetTransactionsForLoan(ctx, loan.ID)
+		if err != nil {
+			return 0, err
+		}
+		dpd = statementDaysPastDue(statements, transactions, today)
+	}
+	if dpd == 0 {
+		return 0, nil
+	}
+	forbearances, err := l.storage.GetForbearancesForLoan(ctx, loan.ID)
+	if err != nil {
+		return 0, err
+	}
+	return dpd - forbearanceDays(forbearances, dpd, today), nil
+}
+
+// statementDaysPastDue returns the days since the due date of the oldest statement in the
+// most recent run of statements whose minimum due was not paid. A statement's minimum is
+// met by payments received between its statement date and the next statement.
+func statementDaysPastDue(statements []*models.Statement, transactions []*models.Transaction, today time.Time) int {
+	var oldestUnpaid *models.Statement
+	for i := len(statements) - 1; i >= 0; i-- {
+		statement := statements[i]
+		var until *time.Time
+		if i+1 < len(statements) {
+			until = &statements[i+1].StatementDate
+		}
+
+		paid := decimal.Zero
+		for _, tx := range transactions {
+			if tx.Type != models.TransactionTypePayment || tx.Timestamp.Before(statement.StatementDate) {
+				continue
+			}
+			if until != nil && !tx.Timestamp.Before(*until) {
+				continue
+			}
+			paid = paid.Add(tx.Amount)
+		}
+		if paid.GreaterThanOrEqual(statement.MinimumDue) {
+			break
+		}
+		oldestUnpaid = statement
+	}
+
+	if oldestUnpaid == nil || !today.After(oldestUnpaid.DueDate) {
+		return 0
+	}
+	return int(today.Sub(oldestUnpaid.DueDate).Hours() / 24)
+}
+
+// UpdateDelinquency recomputes days past due for all open loans and moves them between
+// aging buckets, marking loans delinquent from delinquentStatusDays past due and active again
+// once they fall below it. It is intended to run as part of the daily batch.
+func (l *Ledger) UpdateDelinquency(ctx context.Context) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	err := l.storage.ForEachActiveLoan(ctx, func(listed *models.Loan) error {
+		// The loan is aged as it is when updated, so a payment posted since the scan read it
+		// is counted and kept
+		var previous, bucket models.DelinquencyBucket
+		var dpd int
+		err := l.changeLoan(ctx, listed.ID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+			previous = loan.DelinquencyBucket
+			bucket = previous
+			if !loan.Status.IsOpen() {
+				return nil
+			}
+			var err error
+			if dpd, err = tl.loanDaysPastDue(ctx, loan, today); err != nil {
+				return fmt.Errorf("aging loan: %w", err)
+			}
+			bucket = models.BucketForDaysPastDue(dpd)
+			status := models.LoanStatusActive
+			if dpd >= delinquentStatusDays {
+				status = models.LoanStatusDelinquent
+			}
+			if dpd == loan.DaysPastDue && bucket == loan.DelinquencyBucket && status == loan.Status {
+				return nil
+			}
+
+			previousStatus := loan.Status
+			loan.DaysPastDue = dpd
+			loan.DelinquencyBucket = bucket
+			loan.Status = status
+			loan.UpdatedAt = time.Now()
+
+			if err := tl.storage.UpdateLoanIfVersion(ctx, loan, loan.Version); err != nil {
+				return err
+			}
+			return tl.recordStatusChange(ctx, loan.ID, previousStatus, status)
+		})
+		if err != nil {
+			fmt.Printf("Error updating delinquency for loan %s: %v\n", listed.ID, err)
+			return nil
+		}
+
+		if bucket != previous {
+			fmt.Printf("Loan %s moved from delinquency bucket %q to %q (%d days past due)\n", listed.ID, previous, bucket, dpd)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error getting active loans for delinquency aging: %v\n", err)
+	}
+}
+
+// GetDelinquentLoans retrieves past-due loans, optionally restricted to a single aging bucket.
+// An empty bucket returns every delinquent loan.
+func (l *Ledger) GetDelinquentLoans(ctx context.Context, bucket models.DelinquencyBucket) ([]*models.Loan, error) {
+	minDaysPastDue := 1
+	switch bucket {
+	case "":
+	case models.Delinquency1To29:
+	case models.Delinquency30:
+		minDaysPastDue = 30
+	case models.Delinquency60:
+		minDaysPastDue = 60
+	case models.Delinquency90:
+		minDaysPastDue = 90
+	case models.Delinquency120:
+		minDaysPastDue = 120
+	default:
+		return nil, models.Invalidf("unknown delinquency bucket %q", bucket)
+	}
+
+	loans, err := l.storage.GetDelinquentLoans(ctx, minDaysPastDue)
+	if err != nil {
+		return nil, err
+	}
+	if bucket == "" {
+		return loans, nil
+	}
+
+	filtered := []*models.Loan{}
+	for _, loan := range loans {
+		if models.BucketForDaysPastDue(loan.DaysPastDue) == bucket {
+			filtered = append(filtered, loan)
+		}
+	}
+	return filtered, nil
+}
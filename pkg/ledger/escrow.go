@@ -0,0 +1,120 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithEscrow opens an escrow account for the loan so that payments can fund taxes and
+// insurance.
+func WithEscrow() LoanOption {
+	return func(loan *models.Loan) {
+		loan.EscrowEnabled = true
+		loan.EscrowBalance = decimal.Zero
+	}
+}
+
+// escrowLoan retrieves an active loan that has an escrow account.
+func (l *Ledger) escrowLoan(ctx context.Context, loanID uuid.UUID) (*models.Loan, error) {
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if !loan.EscrowEnabled {
+		return nil, models.ErrNoEscrowAccount
+	}
+	if !loan.Status.IsOpen() {
+		return nil, models.ErrLoanNotActive
+	}
+	return loan, nil
+}
+
+// RecordPaymentWithEscrow records a payment of which escrowAmount is deposited into the
+// loan's escrow account; the remainder is applied to the loan as an ordinary payment. It
+// returns the payment transaction.
+func (l *Ledger) RecordPaymentWithEscrow(ctx context.Context, loanID uuid.UUID, amount decimal.Decimal, escrowAmount decimal.Decimal, opts ...PaymentOption) (*models.Transaction, error) {
+	if !escrowAmount.IsPositive() {
+		return l.RecordPayment(ctx, loanID, amount, opts...)
+	}
+	if _, err := l.escrowLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	if !escrowAmount.LessThan(amount) {
+		return nil, models.ErrEscrowExceedsPayment
+	}
+
+	// The payment and the deposit are posted together or not at all
+	var payment *models.Transaction
+	err := l.inTransaction(ctx, func(ctx context.Context, tl *Ledger) error {
+		var err error
+		if payment, err = tl.RecordPayment(ctx, loanID, amount.Sub(escrowAmount), opts...); err != nil {
+			return err
+		}
+		credit := &models.Transaction{PaymentMethodID: payment.PaymentMethodID, Source: payment.Source, Metadata: payment.Metadata}
+		_, err = tl.postEscrow(ctx, loanID, models.TransactionTypeEscrowCredit, escrowAmount, credit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// DisburseEscrow pays a tax or insurance bill out of the loan's escrow account. The payee is
+// noted on the loan's timeline.
+func (l *Ledger) DisburseEscrow(ctx context.Context, loanID uuid.UUID, amount decimal.Decimal, payee string) (*models.Transaction, error) {
+	if !amount.IsPositive() {
+		return nil, models.Invalidf("escrow disbursement amount must be positive")
+	}
+	if payee == "" {
+		return nil, models.Invalidf("escrow payee is required")
+	}
+	if _, err := l.escrowLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+
+	transaction, err := l.postEscrow(ctx, loanID, models.TransactionTypeEscrowDebit, amount, &models.Transaction{})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := l.recordEvent(ctx, loanID, models.LoanEventNote, systemAuthor, fmt.Sprintf("Escrow disbursement of %s to %s", amount.StringFixed(2), payee)); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// postEscrow moves funds into or out of the loan's escrow account. Funds are never taken out
+// beyond the escrow balance.
+func (l *Ledger) postEscrow(ctx context.Context, loanID uuid.UUID, txType models.TransactionType, amount decimal.Decimal, transaction *models.Transaction) (*models.Transaction, error) {
+	err := l.changeLoan(ctx, loanID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		now := time.Now()
+		if txType == models.TransactionTypeEscrowDebit {
+			if amount.GreaterThan(loan.EscrowBalance) {
+				return models.ErrInsufficientEscrow
+			}
+			loan.EscrowBalance = loan.EscrowBalance.Sub(amount)
+		} else {
+			loan.EscrowBalance = loan.EscrowBalance.Add(amount)
+		}
+		loan.UpdatedAt = now
+
+		transaction.ID = uuid.New()
+		transaction.LoanID = loanID
+		transaction.Amount = amount
+		transaction.Type = txType
+		transaction.Timestamp = now
+		if err := tl.updateLoanWithTransactions(ctx, loan, transaction); err != nil {
+			return fmt.Errorf("failed to post escrow: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
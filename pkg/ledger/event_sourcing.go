@@ -0,0 +1,164 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"github.com/shopspring/decimal"
+)
+
+// SetEventSourcing turns event-sourced mode on or off. In event-sourced mode every write that
+// changes a loan's amounts also appends an event to the loan's log in the same unit of work,
+// so the amounts can always be rebuilt from the log with ProjectLoan.
+func (l *Ledger) SetEventSourcing(enabled bool) {
+	l.eventSourcing = enabled
+}
+
+// loanAmounts returns the figures of a loan its event log accounts for.
+func loanAmounts(loan *models.Loan) models.LoanAmounts {
+	return models.LoanAmounts{
+		Balance:         loan.Balance,
+		AccruedInterest: loan.AccruedInterest,
+		InterestDue:     loan.InterestDue,
+		FeesDue:         loan.FeesDue,
+		EscrowBalance:   loan.EscrowBalance,
+	}
+}
+
+// addAmounts returns a with each of b's amounts added, or subtracted if sign is negative.
+func addAmounts(a models.LoanAmounts, b models.LoanAmounts, sign int64) models.LoanAmounts {
+	s := decimal.NewFromInt(sign)
+	return models.LoanAmounts{
+		Balance:         a.Balance.Add(b.Balance.Mul(s)),
+		AccruedInterest: a.AccruedInterest.Add(b.AccruedInterest.Mul(s)),
+		InterestDue:     a.InterestDue.Add(b.InterestDue.Mul(s)),
+		FeesDue:         a.FeesDue.Add(b.FeesDue.Mul(s)),
+		EscrowBalance:   a.EscrowBalance.Add(b.EscrowBalance.Mul(s)),
+	}
+}
+
+// newLedgerEvent describes a change of the loan's amounts from before to the loan's current
+// ones. A new loan starts from zero.
+func newLedgerEvent(eventType models.LedgerEventType, before models.LoanAmounts, loan *models.Loan, amount decimal.Decimal, transactionID *uuid.UUID) *models.LedgerEvent {
+	after := loanAmounts(loan)
+	return &models.LedgerEvent{
+		ID:            uuid.New(),
+		LoanID:        loan.ID,
+		Type:          eventType,
+		Amount:        amount,
+		TransactionID: transactionID,
+		Changes:       addAmounts(after, before, -1),
+		Amounts:       after,
+		OccurredAt:    time.Now(),
+	}
+}
+
+// unchanged reports whether the event left every amount as it was.
+func unchanged(event *models.LedgerEvent) bool {
+	c := event.Changes
+	return c.Balance.IsZero() && c.AccruedInterest.IsZero() && c.InterestDue.IsZero() && c.FeesDue.IsZero() && c.EscrowBalance.IsZero()
+}
+
+// appendLedgerEvent adds the event to its loan's log through s. An adjustment that changed
+// nothing is left out, as it has nothing to account for.
+func appendLedgerEvent(ctx context.Context, s store.Storage, event *models.LedgerEvent) error {
+	if event.Type == models.LedgerLoanAdjusted && unchanged(event) {
+		return nil
+	}
+	if err := s.AppendLedgerEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to record %s event: %w", event.Type, err)
+	}
+	return nil
+}
+
+// transactionsEvent describes the change a write posting transactions made to a loan: a
+// payment if the write took money from the borrower, and an adjustment otherwise.
+func transactionsEvent(before models.LoanAmounts, loan *models.Loan, transactions []*models.Transaction) *models.LedgerEvent {
+	eventType, amount := models.LedgerLoanAdjusted, decimal.Zero
+	var transactionID *uuid.UUID
+	for _, transaction := range transactions {
+		if transaction.Type == models.TransactionTypePayment || transaction.Type == models.TransactionTypeRecovery {
+			eventType, amount, transactionID = models.LedgerPaymentRecorded, transaction.Amount, &transaction.ID
+			break
+		}
+		if transactionID == nil {
+			amount, transactionID = transaction.Amount, &transaction.ID
+		}
+	}
+	return newLedgerEvent(eventType, before, loan, amount, transactionID)
+}
+
+// updateLoanWithEvent stores the loan with write as one unit of work and, in event-sourced
+// mode, appends an event of the change to its amounts in the same unit.
+func (l *Ledger) updateLoanWithEvent(ctx context.Context, loan *models.Loan, eventType models.LedgerEventType, amount decimal.Decimal, transactionID *uuid.UUID, write func(ctx context.Context, s store.Storage) error) error {
+	return l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+		if !l.eventSourcing {
+			return write(ctx, tx)
+		}
+		before, err := tx.GetLoan(ctx, loan.ID)
+		if err != nil {
+			return err
+		}
+		if err := write(ctx, tx); err != nil {
+			return err
+		}
+		return appendLedgerEvent(ctx, tx, newLedgerEvent(eventType, loanAmounts(before), loan, amount, transactionID))
+	})
+}
+
+// GetLedgerEvents returns a loan's event log in order. Loans stored before event-sourced mode
+// was turned on have no events from before then.
+func (l *Ledger) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetLedgerEvents(ctx, loanID)
+}
+
+// ProjectLoan rebuilds a loan's amounts from its event log and compares them with those
+// stored on the loan. The log starts from the amounts before its first event, which are zero
+// for a loan created in event-sourced mode; every event's changes are added to them in turn.
+// Any difference means the amounts were changed without an event being recorded.
+func (l *Ledger) ProjectLoan(ctx context.Context, loanID uuid.UUID) (*models.LoanProjection, error) {
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := l.storage.GetLedgerEvents(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	projection := &models.LoanProjection{LoanID: loanID, Events: len(events), Differences: []models.FieldChange{}}
+	amounts := loanAmounts(loan)
+	if len(events) == 0 {
+		projection.Amounts = amounts
+		return projection, nil
+	}
+	projected := addAmounts(events[0].Amounts, events[0].Changes, -1)
+	for _, event := range events {
+		projected = addAmounts(projected, event.Changes, 1)
+	}
+	projection.Amounts = projected
+
+	fields := []struct {
+		field             string
+		stored, projected decimal.Decimal
+	}{
+		{"balance", amounts.Balance, projected.Balance},
+		{"accrued_interest", amounts.AccruedInterest, projected.AccruedInterest},
+		{"interest_due", amounts.InterestDue, projected.InterestDue},
+		{"fees_due", amounts.FeesDue, projected.FeesDue},
+		{"escrow_balance", amounts.EscrowBalance, projected.EscrowBalance},
+	}
+	for _, f := range fields {
+		if !f.stored.Equal(f.projected) {
+			projection.Differences = append(projection.Differences, models.FieldChange{Field: f.field, Before: f.stored.String(), After: f.projected.String()})
+		}
+	}
+	return projection, nil
+}
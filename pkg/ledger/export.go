@@ -0,0 +1,241 @@
+package ledger
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/ofx"
+	"github.com/mcclellann/fredLoan/pkg/qif"
+	"github.com/shopspring/decimal"
+)
+
+// exportPageSize is how many rows an export reads from the store at a time.
+const exportPageSize = 500
+
+var loanCSVHeader = []string{
+	"id", "customer_key", "status", "loan_type", "product_code", "principal", "balance", "interest_rate",
+	"accrued_interest", "fees_due", "interest_due", "credit_limit", "statement_cycle_day", "days_past_due",
+	"delinquency_bucket", "term_months", "created_at", "updated_at",
+}
+
+var transactionCSVHeader = []string{"id", "loan_id", "timestamp", "type", "amount", "source", "reference", "capitalized"}
+
+// csvAmount formats a currency amount for spreadsheets: fixed cents, never an exponent.
+func csvAmount(d decimal.Decimal) string {
+	return d.StringFixed(2)
+}
+
+// csvTime formats a timestamp as RFC 3339 in UTC.
+func csvTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ExportLoansCSV writes the loans matching the search to w as CSV with a header row, ignoring
+// the search's limit and offset. Loans are read a page at a time and each page is flushed to
+// w as it is written, so large portfolios stream rather than build up in memory. Nothing is
+// written when the search is invalid.
+func (l *Ledger) ExportLoansCSV(ctx context.Context, w io.Writer, search models.LoanSearch) error {
+	search.Limit = exportPageSize
+	search.Offset = 0
+	page, err := l.searchLoans(ctx, l.reportReader(), search)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	out.Write(loanCSVHeader)
+	for {
+		for _, loan := range page.Loans {
+			out.Write([]string{
+				loan.ID.String(), loan.CustomerKey, string(loan.Status), string(loan.LoanType), loan.ProductCode,
+				csvAmount(loan.Principal), csvAmount(loan.Balance), loan.InterestRate.String(),
+				csvAmount(loan.AccruedInterest), csvAmount(loan.FeesDue), csvAmount(loan.InterestDue), csvAmount(loan.CreditLimit),
+				fmt.Sprint(loan.StatementCycleDay), fmt.Sprint(loan.DaysPastDue), string(loan.DelinquencyBucket),
+				fmt.Sprint(loan.TermMonths), csvTime(loan.CreatedAt), csvTime(loan.UpdatedAt),
+			})
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+
+		search.Offset += len(page.Loans)
+		if len(page.Loans) < exportPageSize || search.Offset >= page.Total {
+			return nil
+		}
+		if page, err = l.searchLoans(ctx, l.reportReader(), search); err != nil {
+			return err
+		}
+	}
+}
+
+// ExportTransactionsCSV writes a loan's transactions matching the query to w as CSV with a
+// header row, oldest first, ignoring the query's limit and offset. Like ExportLoansCSV, it
+// streams a page at a time and writes nothing when the query is invalid or the loan does not
+// exist.
+func (l *Ledger) ExportTransactionsCSV(ctx context.Context, w io.Writer, loanID uuid.UUID, query models.TransactionQuery) error {
+	query.Limit = exportPageSize
+	query.Offset = 0
+	txs, total, err := l.QueryTransactions(ctx, loanID, query)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	out.Write(transactionCSVHeader)
+	for {
+		for _, tx := range txs {
+			out.Write([]string{
+				tx.ID.String(), tx.LoanID.String(), csvTime(tx.Timestamp), string(tx.Type), csvAmount(tx.Amount),
+				tx.Source, tx.Reference, fmt.Sprint(tx.Capitalized),
+			})
+		}
+		out.Flush()
+		if err := out.Error(); err != nil {
+			return err
+		}
+
+		query.Offset += len(txs)
+		if len(txs) < exportPageSize || query.Offset >= total {
+			return nil
+		}
+		if txs, _, err = l.storage.QueryTransactions(ctx, loanID, query); err != nil {
+			return err
+		}
+	}
+}
+
+// statementBankID identifies the ledger in OFX downloads. Importers match accounts on it and
+// the account ID, so it must never change.
+const statementBankID = "FREDLOAN"
+
+// statementCurrency is the currency of every loan.
+const statementCurrency = "USD"
+
+// borrowerEntry is how a transaction type appears in the borrower's books, where the loan is
+// a liability: what adds to the amount owed is negative and what pays it down is positive.
+type borrowerEntry struct {
+	sign    int64
+	ofxType string
+	payee   string
+}
+
+// borrowerEntries covers the transaction types that change what the borrower owes. Escrow
+// movements belong to a separate account and a charge-off changes how the lender carries the
+// debt, not its amount, so they are left out of borrower exports.
+var borrowerEntries = map[models.TransactionType]borrowerEntry{
+	models.TransactionTypeDisbursement:   {-1, ofx.TypeDebit, "Loan disbursement"},
+	models.TransactionTypeInterest:       {-1, ofx.TypeInt, "Interest charged"},
+	models.TransactionTypeFee:            {-1, ofx.TypeFee, "Prepayment penalty"},
+	models.TransactionTypeOriginationFee: {-1, ofx.TypeFee, "Origination fee"},
+	models.TransactionTypeServicingFee:   {-1, ofx.TypeFee, "Servicing fee"},
+	models.TransactionTypePayment:        {1, ofx.TypePayment, "Payment"},
+	models.TransactionTypeRecovery:       {1, ofx.TypePayment, "Recovery payment"},
+	models.TransactionTypeRefinance:      {1, ofx.TypeCredit, "Refinance payoff"},
+}
+
+// borrowerTransactions retrieves the loan and its transactions matching the query that
+// belong in a borrower export, ignoring the query's paging.
+func (l *Ledger) borrowerTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) (*models.Loan, []*models.Transaction, error) {
+	query.Limit = 0
+	query.Offset = 0
+	txs, _, err := l.QueryTransactions(ctx, loanID, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []*models.Transaction
+	for _, tx := range txs {
+		if _, ok := borrowerEntries[tx.Type]; ok {
+			entries = append(entries, tx)
+		}
+	}
+	return loan, entries, nil
+}
+
+// borrowerMemo describes where a transaction came from, for the memo line.
+func borrowerMemo(tx *models.Transaction) string {
+	memo := tx.Reference
+	if tx.Source != "" {
+		if memo != "" {
+			memo += " "
+		}
+		memo += "(" + tx.Source + ")"
+	}
+	return memo
+}
+
+// ExportTransactionsOFX writes a loan's transactions matching the query to w as an OFX
+// statement for a credit line account, for import into personal finance and accounting
+// software. Amounts are signed from the borrower's side, and the ledger balance is what the
+// borrower owes now, excluding interest accrued but not yet charged. Each transaction's ID is
+// its OFX FITID, so importing overlapping downloads does not duplicate activity.
+func (l *Ledger) ExportTransactionsOFX(ctx context.Context, w io.Writer, loanID uuid.UUID, query models.TransactionQuery) error {
+	loan, txs, err := l.borrowerTransactions(ctx, loanID, query)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	statement := ofx.Statement{
+		BankID:      statementBankID,
+		AccountID:   loan.ID.String(),
+		AccountType: ofx.AccountCreditLine,
+		Currency:    statementCurrency,
+		Start:       loan.CreatedAt,
+		End:         now,
+		Balance:     loan.Balance.Add(loan.FeesDue).Add(loan.InterestDue).Neg(),
+		BalanceAsOf: now,
+		Generated:   now,
+	}
+	if query.From != nil {
+		statement.Start = *query.From
+	}
+	if query.To != nil && query.To.Before(now) {
+		statement.End = *query.To
+	}
+	for _, tx := range txs {
+		entry := borrowerEntries[tx.Type]
+		statement.Transactions = append(statement.Transactions, ofx.Transaction{
+			ID:     tx.ID.String(),
+			Type:   entry.ofxType,
+			Posted: tx.Timestamp,
+			Amount: tx.Amount.Mul(decimal.NewFromInt(entry.sign)),
+			Name:   entry.payee,
+			Memo:   borrowerMemo(tx),
+		})
+	}
+	return ofx.Write(w, statement)
+}
+
+// ExportTransactionsQIF writes a loan's transactions matching the query to w as a QIF
+// register for a liability account, signed from the borrower's side as in
+// ExportTransactionsOFX.
+func (l *Ledger) ExportTransactionsQIF(ctx context.Context, w io.Writer, loanID uuid.UUID, query models.TransactionQuery) error {
+	_, txs, err := l.borrowerTransactions(ctx, loanID, query)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]qif.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		entry := borrowerEntries[tx.Type]
+		entries = append(entries, qif.Transaction{
+			Date:   tx.Timestamp.UTC(),
+			Amount: tx.Amount.Mul(decimal.NewFromInt(entry.sign)),
+			Number: tx.Reference,
+			Payee:  entry.payee,
+			Memo:   borrowerMemo(tx),
+		})
+	}
+	return qif.Write(w, qif.TypeOtherLiability, entries)
+}
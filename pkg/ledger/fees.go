@@ -0,0 +1,74 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// AssessFee charges a fee of the given category to an active loan. A capitalized fee is
+// added to the balance and accrues interest with it; otherwise the fee is billed separately
+// as fees due, which later payments cover before reducing the balance.
+func (l *Ledger) AssessFee(ctx context.Context, loanID uuid.UUID, feeType models.TransactionType, amount decimal.Decimal, capitalize bool) (*models.Transaction, error) {
+	switch feeType {
+	case models.TransactionTypeOriginationFee, models.TransactionTypeServicingFee:
+	default:
+		return nil, models.Invalidf("invalid fee type: %s", feeType)
+	}
+	if !amount.IsPositive() {
+		return nil, models.Invalidf("fee amount must be positive")
+	}
+
+	var transaction *models.Transaction
+	err := l.changeLoan(ctx, loanID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		if !loan.Status.IsOpen() {
+			return models.ErrLoanNotActive
+		}
+
+		now := time.Now()
+		if capitalize {
+			loan.Balance = loan.Balance.Add(amount)
+		} else {
+			loan.FeesDue = loan.FeesDue.Add(amount)
+		}
+		loan.UpdatedAt = now
+
+		transaction = &models.Transaction{
+			ID:          uuid.New(),
+			LoanID:      loan.ID,
+			Amount:      amount,
+			Type:        feeType,
+			Timestamp:   now,
+			Capitalized: capitalize,
+		}
+		if err := tl.updateLoanWithTransactions(ctx, loan, transaction); err != nil {
+			return fmt.Errorf("failed to assess fee: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// applyToAmountsDue applies a payment to the loan's billed fees and then its billed interest,
+// and returns the remainder, which goes to the balance.
+func applyToAmountsDue(loan *models.Loan, amount decimal.Decimal) decimal.Decimal {
+	paid := decimal.Min(loan.FeesDue, amount)
+	loan.FeesDue = loan.FeesDue.Sub(paid)
+	amount = amount.Sub(paid)
+
+	paid = decimal.Min(loan.InterestDue, amount)
+	loan.InterestDue = loan.InterestDue.Sub(paid)
+	return amount.Sub(paid)
+}
+
+// amountsDue is the total billed to the loan outside its balance.
+func amountsDue(loan *models.Loan) decimal.Decimal {
+	return loan.FeesDue.Add(loan.InterestDue)
+}
@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// PlaceInForbearance grants an open loan forbearance from start through end, inclusive.
+// During the window interest accrues at rate instead of the loan's rate (zero suspends
+// accrual) and delinquency aging is paused. The grant is recorded on the loan's timeline.
+func (l *Ledger) PlaceInForbearance(ctx context.Context, loanID uuid.UUID, start time.Time, end time.Time, rate decimal.Decimal, reason string, author string) (*models.Forbearance, error) {
+	start = start.UTC().Truncate(24 * time.Hour)
+	end = end.UTC().Truncate(24 * time.Hour)
+	if end.Before(start) {
+		return nil, models.Invalidf("forbearance end date must not be before its start date")
+	}
+	if rate.IsNegative() {
+		return nil, models.Invalidf("forbearance rate must not be negative")
+	}
+	if reason == "" {
+		return nil, models.Invalidf("forbearance reason is required")
+	}
+
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if !loan.Status.IsOpen() {
+		return nil, models.ErrLoanNotActive
+	}
+	existing, err := l.storage.GetForbearancesForLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range existing {
+		if !start.After(other.EndDate) && !end.Before(other.StartDate) {
+			return nil, models.ErrForbearanceOverlap
+		}
+	}
+
+	forbearance := &models.Forbearance{
+		ID:        uuid.New(),
+		LoanID:    loanID,
+		StartDate: start,
+		EndDate:   end,
+		Rate:      rate,
+		Reason:    reason,
+		CreatedBy: author,
+		CreatedAt: time.Now(),
+	}
+	if err := l.storage.CreateForbearance(ctx, forbearance); err != nil {
+		return nil, err
+	}
+
+	if author == "" {
+		author = systemAuthor
+	}
+	description := fmt.Sprintf("Forbearance from %s to %s at %s: %s", start.Format("2006-01-02"), end.Format("2006-01-02"), rate.String(), reason)
+	if _, err := l.recordEvent(ctx, loanID, models.LoanEventForbearance, author, description); err != nil {
+		return nil, err
+	}
+	return forbearance, nil
+}
+
+// GetForbearances lists a loan's forbearance windows.
+func (l *Ledger) GetForbearances(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetForbearancesForLoan(ctx, loanID)
+}
+
+// forbearanceOn returns the forbearance window covering day, or nil if there is none.
+func forbearanceOn(forbearances []*models.Forbearance, day time.Time) *models.Forbearance {
+	day = day.UTC().Truncate(24 * time.Hour)
+	for _, forbearance := range forbearances {
+		if !day.Before(forbearance.StartDate.UTC()) && !day.After(forbearance.EndDate.UTC()) {
+			return forbearance
+		}
+	}
+	return nil
+}
+
+// forbearanceDays counts the days in the dpd days up to and including today that fall within
+// a forbearance window. Aging is paused on those days, so they do not count as past due.
+func forbearanceDays(forbearances []*models.Forbearance, dpd int, today time.Time) int {
+	paused := 0
+	for i := 0; i < dpd; i++ {
+		if forbearanceOn(forbearances, today.AddDate(0, 0, -i)) != nil {
+			paused++
+		}
+	}
+	return paused
+}
@@ -0,0 +1,115 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// maxIdempotencyKeyLength bounds the Idempotency-Key a client may send.
+const maxIdempotencyKeyLength = 255
+
+// ReserveIdempotencyKey claims a key for a request before it is processed. If the key was
+// claimed before by the same request, the earlier record is returned instead: with a status
+// code it carries the original response to replay, and without one the original request is
+// still in flight. A key reused for a different request is rejected.
+func (l *Ledger) ReserveIdempotencyKey(ctx context.Context, key string, requestHash string) (*models.IdempotencyRecord, error) {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return nil, models.Invalidf("invalid idempotency key: must be 1 to %d characters", maxIdempotencyKeyLength)
+	}
+
+	record := &models.IdempotencyRecord{Key: key, RequestHash: requestHash, CreatedAt: time.Now()}
+	err := l.storage.CreateIdempotencyRecord(ctx, record)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, models.ErrIdempotencyKeyExists) {
+		return nil, err
+	}
+
+	existing, err := l.storage.GetIdempotencyRecord(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		// Released between the two calls; the caller may retry the request
+		return nil, models.ErrIdempotencyKeyInUse
+	}
+	if existing.RequestHash != requestHash {
+		return nil, models.ErrIdempotencyKeyMismatch
+	}
+	return existing, nil
+}
+
+// CompleteIdempotencyKey records the response to a reserved key's request for later replay.
+func (l *Ledger) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	record, err := l.storage.GetIdempotencyRecord(ctx, key)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return models.ErrIdempotencyRecordNotFound
+	}
+	now := time.Now()
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = body
+	record.CompletedAt = &now
+	return l.storage.UpdateIdempotencyRecord(ctx, record)
+}
+
+// ReleaseIdempotencyKey frees a reserved key without recording a response, so a request that
+// failed for transient reasons can be retried with the same key. A key whose request took
+// effect before failing is kept, returning models.ErrIdempotencyKeyApplied, since a retry
+// would apply it again; its response should be recorded instead.
+func (l *Ledger) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	record, err := l.storage.GetIdempotencyRecord(ctx, key)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return nil
+	}
+	if record.AppliedAt != nil {
+		return models.ErrIdempotencyKeyApplied
+	}
+	return l.storage.DeleteIdempotencyRecord(ctx, key)
+}
+
+// idempotencyKeyContext is the context key under which WithIdempotencyKey stores a key.
+type idempotencyKeyContext struct{}
+
+// WithIdempotencyKey returns a context for processing the request that reserved key with
+// ReserveIdempotencyKey. A payment recorded under it marks the key applied in the same unit of
+// work as the payment, so that the key is never released once the money has posted.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContext{}, key)
+}
+
+// markIdempotencyKeyApplied records that the request whose key ctx carries has taken effect.
+// It belongs in the unit of work of the request's changes.
+func (l *Ledger) markIdempotencyKeyApplied(ctx context.Context) error {
+	key, _ := ctx.Value(idempotencyKeyContext{}).(string)
+	if key == "" {
+		return nil
+	}
+	record, err := l.storage.GetIdempotencyRecord(ctx, key)
+	if err != nil {
+		return err
+	}
+	if record == nil {
+		return models.ErrIdempotencyRecordNotFound
+	}
+	if record.AppliedAt != nil {
+		return nil
+	}
+	now := time.Now()
+	record.AppliedAt = &now
+	if err := l.storage.UpdateIdempotencyRecord(ctx, record); err != nil {
+		return fmt.Errorf("failed to mark idempotency key applied: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// IndexRateSource supplies the latest published value of a benchmark rate.
+type IndexRateSource interface {
+	// Name identifies the source, recorded on each observation it supplies.
+	Name() string
+	// LatestRate returns the most recent rate for the index as a fraction together with
+	// the date it was observed.
+	LatestRate(indexCode string) (decimal.Decimal, time.Time, error)
+}
+
+// IndexSourceManual marks index rates entered by hand rather than pulled from a feed.
+const IndexSourceManual = "manual"
+
+// WithIndex ties the loan's base rate to a benchmark index. The base rate passed to
+// CreateLoan is replaced by the index's latest published rate, and the variance acts as
+// the margin over the index.
+func WithIndex(indexCode string) LoanOption {
+	return func(loan *models.Loan) {
+		loan.IndexCode = indexCode
+	}
+}
+
+// priceFromIndex sets an indexed loan's base rate to the index's latest published rate.
+func (l *Ledger) priceFromIndex(ctx context.Context, loan *models.Loan) error {
+	latest, err := l.storage.GetLatestIndexRate(ctx, loan.IndexCode)
+	if err != nil {
+		return err
+	}
+	if latest == nil {
+		return models.Invalidf("no rate published for index %s", loan.IndexCode)
+	}
+	loan.BaseInterestRate = latest.Rate
+	loan.InterestRate = latest.Rate.Add(loan.InterestRateVariance)
+	return nil
+}
+
+// PublishIndexRate records a new observation of a benchmark rate and reprices every active
+// loan tied to the index. It returns the stored observation and the number of loans
+// repriced; loans that fail to reprice are logged and skipped.
+func (l *Ledger) PublishIndexRate(ctx context.Context, indexCode string, rate decimal.Decimal, observed time.Time, source string) (*models.IndexRate, int, error) {
+	if indexCode == "" {
+		return nil, 0, models.Invalidf("index code is required")
+	}
+	if rate.IsNegative() {
+		return nil, 0, models.Invalidf("index rate must not be negative")
+	}
+
+	observation := &models.IndexRate{
+		ID:              uuid.New(),
+		IndexCode:       indexCode,
+		Rate:            rate,
+		ObservationDate: observed.UTC().Truncate(24 * time.Hour),
+		Source:          source,
+		CreatedAt:       time.Now(),
+	}
+	if err := l.storage.CreateIndexRate(ctx, observation); err != nil {
+		return nil, 0, fmt.Errorf("failed to store index rate: %w", err)
+	}
+
+	repriced := 0
+	err := l.storage.ForEachActiveLoan(ctx, func(loan *models.Loan) error {
+		if loan.IndexCode != indexCode {
+			return nil
+		}
+		changed, err := l.repriceLoan(ctx, loan.ID, rate)
+		if err != nil {
+			fmt.Printf("Error repricing loan %s to index %s: %v\n", loan.ID, indexCode, err)
+			return nil
+		}
+		if changed {
+			repriced++
+		}
+		return nil
+	})
+	if err != nil {
+		return observation, repriced, fmt.Errorf("failed to get active loans for repricing: %w", err)
+	}
+
+	return observation, repriced, nil
+}
+
+// RefreshIndexRate pulls the latest rate for an index from src and publishes it if it is
+// a new observation.
+func (l *Ledger) RefreshIndexRate(ctx context.Context, src IndexRateSource, indexCode string) (*models.IndexRate, int, error) {
+	rate, observed, err := src.LatestRate(indexCode)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	latest, err := l.storage.GetLatestIndexRate(ctx, indexCode)
+	if err != nil {
+		return nil, 0, err
+	}
+	observed = observed.UTC().Truncate(24 * time.Hour)
+	if latest != nil && latest.ObservationDate.Equal(observed) && latest.Rate.Equal(rate) {
+		return latest, 0, nil
+	}
+
+	return l.PublishIndexRate(ctx, indexCode, rate, observed, src.Name())
+}
+
+// RefreshIndexRates pulls and publishes the latest rate for each index, logging failures.
+func (l *Ledger) RefreshIndexRates(ctx context.Context, src IndexRateSource, indexCodes []string) {
+	for _, code := range indexCodes {
+		rate, repriced, err := l.RefreshIndexRate(ctx, src, code)
+		if err != nil {
+			fmt.Printf("Error refreshing index %s: %v\n", code, err)
+			continue
+		}
+		if repriced > 0 {
+			fmt.Printf("Index %s is now %s; repriced %d loans.\n", code, rate.Rate, repriced)
+		}
+	}
+}
+
+// GetIndexRates retrieves the observation history of an index.
+func (l *Ledger) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	return l.storage.GetIndexRates(ctx, indexCode)
+}
+
+// repriceLoan schedules a rate change moving an indexed loan to the new base rate, keeping
+// its margin. The change takes effect today, or tomorrow if today's interest has already
+// accrued. It reports whether a change was recorded. The rate change, its timeline event and
+// the loan's new pricing are committed together, so a loan is never left with a change that
+// was recorded but not applied.
+func (l *Ledger) repriceLoan(ctx context.Context, loanID uuid.UUID, baseRate decimal.Decimal) (bool, error) {
+	changed := false
+	err := l.changeLoan(ctx, loanID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		changed = false
+		if !loan.Status.IsOpen() {
+			return nil
+		}
+		// Compare against the latest scheduled pricing so a pending change isn't repeated
+		current := loan.BaseInterestRate
+		history, err := tl.storage.GetRateHistory(ctx, loan.ID)
+		if err != nil {
+			return err
+		}
+		if len(history) > 0 {
+			current = history[len(history)-1].BaseInterestRate
+		}
+		if current.Equal(baseRate) {
+			return nil
+		}
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		effective := today
+		if loan.LastInterestCalculationDate != nil && !effective.After(loan.LastInterestCalculationDate.UTC().Truncate(24*time.Hour)) {
+			effective = today.AddDate(0, 0, 1)
+		}
+
+		change, err := tl.recordRateChange(ctx, loan, baseRate, loan.InterestRateVariance, effective)
+		if err != nil {
+			return err
+		}
+
+		if !effective.After(today) {
+			applyRateChange(loan, change)
+			loan.UpdatedAt = time.Now()
+			if err := tl.storage.UpdateLoanIfVersion(ctx, loan, loan.Version); err != nil {
+				return fmt.Errorf("failed to apply rate change: %w", err)
+			}
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
@@ -0,0 +1,155 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+// statementCycle identifies the statement cycle containing t, e.g. "2024-06".
+func statementCycle(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// recordInterestIntent writes the intent to apply the loan's accrued interest for a cycle.
+// It returns nil if the cycle already has an intent, which means its interest has been (or
+// is being) applied.
+func (l *Ledger) recordInterestIntent(ctx context.Context, loan *models.Loan, cycle string) (*models.InterestIntent, error) {
+	existing, err := l.storage.GetInterestIntent(ctx, loan.ID, cycle)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, nil
+	}
+
+	intent := &models.InterestIntent{
+		ID:            uuid.New(),
+		LoanID:        loan.ID,
+		Cycle:         cycle,
+		Amount:        capitalizedInterest(l.rounding, loan.AccruedInterest),
+		TransactionID: uuid.New(),
+		Status:        models.IntentPending,
+		CreatedAt:     time.Now(),
+	}
+	if err := l.storage.CreateInterestIntent(ctx, intent); err != nil {
+		return nil, err
+	}
+	return intent, nil
+}
+
+// postInterestIntent carries out an intent. Each step checks whether it already happened,
+// so an intent can be replayed safely after a crash at any point.
+func (l *Ledger) postInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	loan, err := l.storage.GetLoan(ctx, intent.LoanID)
+	if err != nil {
+		return err
+	}
+
+	// The cycle marker is written in the same update as the balance. Simple interest, and
+	// compound interest beyond the negative amortization cap, is billed as interest due rather
+	// than capitalized.
+	if loan.InterestAppliedCycle != intent.Cycle {
+		reachedCap := applyInterest(loan, intent.Amount)
+		settleAccruedInterest(l.rounding, loan, intent.Amount)
+		loan.InterestAppliedCycle = intent.Cycle
+		loan.UpdatedAt = time.Now()
+		err := l.updateLoanWithEvent(ctx, loan, models.LedgerInterestApplied, intent.Amount, &intent.TransactionID, func(ctx context.Context, s store.Storage) error {
+			return s.UpdateLoan(ctx, loan)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update loan after monthly interest application: %w", err)
+		}
+		fmt.Printf("Applied %s accrued interest to Loan %s on statement day (New Balance: %s, Interest Due: %s)\n", intent.Amount.StringFixed(2), loan.ID, loan.Balance.StringFixed(2), loan.InterestDue.StringFixed(2))
+		if reachedCap {
+			text := fmt.Sprintf("Negative amortization cap of %s reached; further interest is billed as due", negativeAmortizationLimit(loan).StringFixed(2))
+			if _, err := l.recordEvent(ctx, loan.ID, models.LoanEventNegativeAmortizationCap, systemAuthor, text); err != nil {
+				return err
+			}
+		}
+	}
+
+	posted, err := l.transactionExists(ctx, loan.ID, intent.TransactionID)
+	if err != nil {
+		return err
+	}
+	if !posted {
+		transaction := &models.Transaction{
+			ID:        intent.TransactionID,
+			LoanID:    loan.ID,
+			Amount:    intent.Amount,
+			Type:      models.TransactionTypeInterest,
+			Timestamp: time.Now(),
+		}
+		err := l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+			if err := tx.CreateTransaction(ctx, transaction); err != nil {
+				return err
+			}
+			return publishEvents(ctx, tx, newWebhookEvent(models.WebhookInterestApplied, transaction))
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store monthly interest transaction: %w", err)
+		}
+		l.broadcast(models.StreamTransactionCreated, transaction.LoanID, transaction)
+	}
+
+	now := time.Now()
+	intent.Status = models.IntentCompleted
+	intent.CompletedAt = &now
+	if err := l.storage.UpdateInterestIntent(ctx, intent); err != nil {
+		return fmt.Errorf("failed to complete interest intent: %w", err)
+	}
+	return nil
+}
+
+// resumeInterestIntents finishes intents left pending by an interrupted run.
+func (l *Ledger) resumeInterestIntents(ctx context.Context) {
+	pending, err := l.storage.GetInterestIntentsByStatus(ctx, models.IntentPending)
+	if err != nil {
+		fmt.Printf("Error getting pending interest intents: %v\n", err)
+		return
+	}
+	for _, intent := range pending {
+		fmt.Printf("Resuming interest intent %s for loan %s (cycle %s)\n", intent.ID, intent.LoanID, intent.Cycle)
+		if err := l.postInterestIntent(ctx, intent); err != nil {
+			fmt.Printf("Error resuming interest intent %s: %v\n", intent.ID, err)
+		}
+	}
+}
+
+// transactionExists reports whether the loan has a transaction with the given ID.
+func (l *Ledger) transactionExists(ctx context.Context, loanID uuid.UUID, transactionID uuid.UUID) (bool, error) {
+	txs, err := l.storage.GetTransactionsForLoan(ctx, loanID)
+	if err != nil {
+		return false, err
+	}
+	for _, tx := range txs {
+		if tx.ID == transactionID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetInterestIntents lists the intents recorded for a statement cycle (YYYY-MM), optionally
+// only those with the given status.
+func (l *Ledger) GetInterestIntents(ctx context.Context, cycle string, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	if status != "" && cycle == "" {
+		return l.storage.GetInterestIntentsByStatus(ctx, status)
+	}
+	intents, err := l.storage.GetInterestIntentsForCycle(ctx, cycle)
+	if err != nil || status == "" {
+		return intents, err
+	}
+	filtered := []*models.InterestIntent{}
+	for _, intent := range intents {
+		if intent.Status == status {
+			filtered = append(filtered, intent)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,369 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"github.com/shopspring/decimal"
+)
+
+// JobScope narrows an on-demand run of a batch job. The zero value covers every open loan as
+// of today, as the scheduled batch does.
+type JobScope struct {
+	LoanID uuid.UUID // Only this loan, when set
+	Date   time.Time // Accrual or statement date, when set; today otherwise
+}
+
+// forEachJobLoan calls fn with each open loan a run covers, streaming them from the store
+// rather than loading the whole portfolio.
+func (l *Ledger) forEachJobLoan(ctx context.Context, scope JobScope, fn func(loan *models.Loan)) error {
+	if scope.LoanID == uuid.Nil {
+		return l.storage.ForEachActiveLoan(ctx, func(loan *models.Loan) error {
+			fn(loan)
+			return nil
+		})
+	}
+	loan, err := l.storage.GetLoan(ctx, scope.LoanID)
+	if err != nil {
+		return err
+	}
+	if !loan.Status.IsOpen() {
+		return models.ErrLoanNotActive
+	}
+	fn(loan)
+	return nil
+}
+
+func newJobRun(job models.JobName, scope JobScope, date time.Time) *models.JobRun {
+	run := &models.JobRun{Job: job, Date: date, StartedAt: time.Now()}
+	if scope.LoanID != uuid.Nil {
+		loanID := scope.LoanID
+		run.LoanID = &loanID
+	}
+	return run
+}
+
+func recordJobFailure(run *models.JobRun, loanID uuid.UUID, err error) {
+	run.Failed++
+	run.Errors = append(run.Errors, fmt.Sprintf("loan %s: %v", loanID, err))
+}
+
+// RunDailyInterest accrues a day's interest on the loans in scope. A loan that already accrued
+// for the date is skipped, so a run can be repeated safely. A date before a loan's latest
+// accrual is a backfill: it accrues on the loan's current balance at the rate in effect on
+// that date, and leaves the loan's current rate and last accrual date alone. The error is
+// for the run as a whole; failures on individual loans are counted in the result.
+func (l *Ledger) RunDailyInterest(ctx context.Context, scope JobScope) (*models.JobRun, error) {
+	day := time.Now().UTC().Truncate(24 * time.Hour) // Truncate to get just the date
+	if !scope.Date.IsZero() {
+		day = scope.Date.UTC().Truncate(24 * time.Hour)
+	}
+	run := newJobRun(models.JobDailyInterest, scope, day)
+
+	batch := &accrualBatch{day: day}
+	err := l.forEachJobLoan(ctx, scope, func(loan *models.Loan) {
+		l.accrueForRun(ctx, run, loan, batch)
+		if len(batch.loans) == accrualBatchSize {
+			l.saveAccrualBatch(ctx, run, batch)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.saveAccrualBatch(ctx, run, batch)
+	run.FinishedAt = time.Now()
+	return run, nil
+}
+
+// accrualBatchSize is how many changed loans a daily interest run stores at a time.
+const accrualBatchSize = 1000
+
+// accrualRetries is how many times a daily interest run accrues again on a loan that changed
+// between being read and its batch being stored.
+const accrualRetries = 3
+
+// accrualBatch collects the loans a daily interest run has changed, their accruals and, in
+// event-sourced mode, their events, so they are stored a batch at a time rather than one
+// loan at a time.
+type accrualBatch struct {
+	day      time.Time
+	retry    int // How many times the batch's loans have been accrued again
+	loans    []*models.Loan
+	accruals []*models.Accrual
+	events   []*models.LedgerEvent
+}
+
+// accrueForRun accrues the loan's interest for the batch's day into the batch, counting the
+// loan in the run.
+func (l *Ledger) accrueForRun(ctx context.Context, run *models.JobRun, loan *models.Loan, batch *accrualBatch) {
+	accrued, err := l.accrueDailyInterest(ctx, loan, batch.day, batch)
+	switch {
+	case err != nil:
+		recordJobFailure(run, loan.ID, err)
+	case accrued:
+		run.Processed++
+	default:
+		run.Skipped++
+	}
+}
+
+// uncount takes back the run's count of a loan in the batch, which is counted again as it
+// turns out.
+func (b *accrualBatch) uncount(run *models.JobRun, loanID uuid.UUID) {
+	for _, accrual := range b.accruals {
+		if accrual.LoanID == loanID {
+			run.Processed--
+			return
+		}
+	}
+	run.Skipped--
+}
+
+// saveAccrualBatch stores the batch's loans, accruals and events together and empties it. If that
+// fails, every loan in the batch is counted as failed rather than processed or skipped. A loan
+// that changed since it was read, by a payment say, is left for its accrual to be worked out
+// again from the loan as it now is, rather than overwritten.
+func (l *Ledger) saveAccrualBatch(ctx context.Context, run *models.JobRun, batch *accrualBatch) {
+	if len(batch.loans) == 0 {
+		return
+	}
+	var stale map[uuid.UUID]bool
+	err := l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+		skipped, err := tx.UpdateLoans(ctx, batch.loans)
+		if err != nil {
+			return fmt.Errorf("updating loans during daily interest calculation: %w", err)
+		}
+		stale = make(map[uuid.UUID]bool, len(skipped))
+		for _, id := range skipped {
+			stale[id] = true
+		}
+		for _, accrual := range batch.accruals {
+			if stale[accrual.LoanID] {
+				continue
+			}
+			if err := tx.SaveAccrual(ctx, accrual); err != nil {
+				return fmt.Errorf("recording accrual history: %w", err)
+			}
+		}
+		for _, event := range batch.events {
+			if stale[event.LoanID] {
+				continue
+			}
+			if err := appendLedgerEvent(ctx, tx, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, loan := range batch.loans {
+			batch.uncount(run, loan.ID)
+			recordJobFailure(run, loan.ID, err)
+		}
+		*batch = accrualBatch{day: batch.day}
+		return
+	}
+	for _, accrual := range batch.accruals {
+		if !stale[accrual.LoanID] {
+			l.broadcast(models.StreamAccrualRecorded, accrual.LoanID, accrual)
+			fmt.Printf("Accrued %s daily interest for Loan %s on %s\n", accrual.Amount.StringFixed(2), accrual.LoanID, accrual.Date.Format("2006-01-02"))
+		}
+	}
+
+	retry := &accrualBatch{day: batch.day, retry: batch.retry + 1}
+	for _, loan := range batch.loans {
+		if !stale[loan.ID] {
+			continue
+		}
+		batch.uncount(run, loan.ID)
+		if batch.retry == accrualRetries {
+			recordJobFailure(run, loan.ID, models.ErrLoanVersionMismatch)
+			continue
+		}
+		current, err := l.storage.GetLoan(ctx, loan.ID)
+		switch {
+		case err != nil:
+			recordJobFailure(run, loan.ID, err)
+		case !current.Status.IsOpen():
+			run.Skipped++
+		default:
+			l.accrueForRun(ctx, run, current, retry)
+		}
+	}
+	*batch = accrualBatch{day: batch.day}
+	l.saveAccrualBatch(ctx, run, retry)
+}
+
+// accrueDailyInterest accrues the loan's interest for one day and reports whether it did. The
+// changed loan and its accrual are added to the batch for storing.
+func (l *Ledger) accrueDailyInterest(ctx context.Context, loan *models.Loan, day time.Time, batch *accrualBatch) (bool, error) {
+	// Check if interest has already been calculated for the day
+	if loan.LastInterestCalculationDate != nil && loan.LastInterestCalculationDate.UTC().Truncate(24*time.Hour).Equal(day) {
+		fmt.Printf("Daily interest for Loan %s already calculated for %s. Skipping.\n", loan.ID, day.Format("2006-01-02"))
+		return false, nil
+	}
+
+	backfill := loan.LastInterestCalculationDate != nil && day.Before(*loan.LastInterestCalculationDate)
+	if backfill {
+		existing, err := l.storage.GetAccrualsForLoan(ctx, loan.ID, day, day)
+		if err != nil {
+			return false, fmt.Errorf("looking up accruals: %w", err)
+		}
+		if len(existing) > 0 {
+			return false, nil
+		}
+	}
+
+	// Use the rate in effect for this accrual day. A backfill works on a copy, so the loan
+	// keeps today's rate.
+	rated := loan
+	if backfill {
+		snapshot := *loan
+		rated = &snapshot
+	}
+	rateChanged, err := l.rateInEffect(ctx, rated, day)
+	if err != nil {
+		return false, fmt.Errorf("looking up rate in effect: %w", err)
+	}
+
+	// Interest for the stub before the first statement is fixed at origination or waived,
+	// and none accrues during a grace period
+	if inOddDaysStub(loan, day) || beforeAccrualStart(loan, day) {
+		return false, nil
+	}
+
+	// Promotional rates override the effective rate while the promo window is open, and
+	// forbearance overrides both
+	rate := accrualRate(rated, day)
+	forbearances, err := l.storage.GetForbearancesForLoan(ctx, loan.ID)
+	if err != nil {
+		return false, fmt.Errorf("looking up forbearance: %w", err)
+	}
+	if forbearance := forbearanceOn(forbearances, day); forbearance != nil {
+		rate = forbearance.Rate
+	}
+	interestAmount := DailyInterest(loan.Balance, rate)
+	if loan.OddDaysPolicy == models.OddDaysCharge && loan.LastInterestCalculationDate == nil {
+		// The first accrual after the stub bills the odd-days interest disclosed at origination
+		interestAmount = interestAmount.Add(loan.OddDaysInterest)
+	}
+	residual := loan.InterestResidual
+	interestAmount = roundAccrual(l.rounding, loan, interestAmount)
+	// A day that rounds to nothing still counts when its fraction is carried forward
+	accrued := interestAmount.GreaterThan(decimal.Zero) || !loan.InterestResidual.Equal(residual)
+
+	if !accrued {
+		if rateChanged && !backfill {
+			loan.UpdatedAt = time.Now()
+			batch.loans = append(batch.loans, loan)
+		}
+		return false, nil
+	}
+
+	before := loanAmounts(loan)
+	loan.AccruedInterest = loan.AccruedInterest.Add(interestAmount)
+	loan.UpdatedAt = time.Now()
+	// Update LastInterestCalculationDate, which a backfill never moves backwards
+	if !backfill {
+		loan.LastInterestCalculationDate = &day
+	}
+
+	batch.loans = append(batch.loans, loan)
+	batch.accruals = append(batch.accruals, &models.Accrual{
+		LoanID:    loan.ID,
+		Date:      day,
+		Balance:   loan.Balance,
+		Rate:      rate,
+		Amount:    interestAmount,
+		CreatedAt: time.Now(),
+	})
+	if l.eventSourcing {
+		batch.events = append(batch.events, newLedgerEvent(models.LedgerInterestAccrued, before, loan, interestAmount, nil))
+	}
+	return true, nil
+}
+
+// RunMonthlyInterest applies accrued interest on the loans in scope whose statement cycle day
+// falls on the date. Loans on another cycle day are skipped. Any intents an interrupted run
+// left pending are finished first. A cycle's interest is applied at most once, so a run can
+// be repeated safely; a run for a missed statement date applies all interest accrued so far.
+func (l *Ledger) RunMonthlyInterest(ctx context.Context, scope JobScope) (*models.JobRun, error) {
+	// Finish whatever an interrupted run left behind before starting new work
+	l.resumeInterestIntents(ctx)
+
+	now := time.Now()
+	if !scope.Date.IsZero() {
+		now = scope.Date
+	}
+	run := newJobRun(models.JobMonthlyInterest, scope, now)
+
+	todayDay := now.Day()
+	cycle := statementCycle(now)
+
+	var intents []*models.InterestIntent
+	err := l.forEachJobLoan(ctx, scope, func(loan *models.Loan) {
+		if loan.StatementCycleDay != todayDay {
+			run.Skipped++
+			return
+		}
+		if !loan.AccruedInterest.GreaterThan(decimal.Zero) {
+			fmt.Printf("No accrued interest to apply for Loan %s on statement day.\n", loan.ID)
+			run.Skipped++
+			return
+		}
+
+		intent, err := l.recordInterestIntent(ctx, loan, cycle)
+		if err != nil {
+			fmt.Printf("Error recording interest intent for loan %s: %v\n", loan.ID, err)
+			recordJobFailure(run, loan.ID, err)
+			return
+		}
+		if intent == nil {
+			run.Skipped++ // Applied already this cycle
+			return
+		}
+		intents = append(intents, intent)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, intent := range intents {
+		if err := l.postInterestIntent(ctx, intent); err != nil {
+			fmt.Printf("Error applying monthly interest to loan %s: %v\n", intent.LoanID, err)
+			recordJobFailure(run, intent.LoanID, err)
+			continue
+		}
+		run.Processed++
+	}
+	run.FinishedAt = time.Now()
+	return run, nil
+}
+
+// RecordJobRun stores a finished job run in the job history, assigning its ID.
+func (l *Ledger) RecordJobRun(ctx context.Context, run *models.JobRun) error {
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+	return l.storage.CreateJobRun(ctx, run)
+}
+
+// GetJobRuns lists the most recent runs of a job, or of every job when job is empty, newest
+// first.
+func (l *Ledger) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	if job != "" && !job.Valid() {
+		return nil, models.Invalidf("invalid job: %s", job)
+	}
+	runs, err := l.storage.GetJobRuns(ctx, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []*models.JobRun{}
+	}
+	return runs, nil
+}
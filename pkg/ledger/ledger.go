@@ -1,8 +1,11 @@
 package ledger
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,10 +23,29 @@ var (
 	daysInYear = decimal.NewFromInt(365)
 )
 
+// DailyInterest returns one day's interest on a balance at an annual rate:
+// Balance * (APR / 365). It is the accrual engine shared by the daily batch jobs.
+func DailyInterest(balance decimal.Decimal, annualRate decimal.Decimal) decimal.Decimal {
+	return balance.Mul(annualRate.Div(daysInYear))
+}
+
 // Ledger handles the business logic for loans and transactions.
 type Ledger struct {
 	storage store.Storage // Use the Storage interface
 	randSrc rand.Source   // Random source for assigning statement cycle day
+
+	autoChargeOffDays int    // Days past due that trigger automatic charge-off (0 disables)
+	paymentLinkSecret []byte // Key signing payment link tokens (nil disables payment links)
+
+	minimumPayment models.MinimumPaymentPolicy // Minimum due for loans whose product sets no policy
+	rounding       models.RoundingPolicy       // Rounding of accrued and capitalized interest
+
+	stream  *eventStream // Live subscribers to new transactions and status changes
+	pending *[]func()    // Set inside inTransaction: broadcasts held back until it commits
+
+	eventSourcing bool // Record every change to a loan's amounts in its event log
+
+	reports store.Reader // Serves reporting queries, typically from a read replica (nil reads the primary)
 }
 
 // NewLedger creates a new Ledger with a given Storage implementation.
@@ -31,17 +53,48 @@ func NewLedger(s store.Storage) *Ledger {
 	return &Ledger{
 		storage: s,
 		randSrc: rand.NewSource(time.Now().UnixNano()), // Initialize with a changing seed
+		stream:  &eventStream{},
+
+		minimumPayment: defaultMinimumPayment,
 	}
 }
 
+// validateLoanTerms checks the terms a loan is created or edited with.
+func validateLoanTerms(loan *models.Loan) error {
+	for _, validate := range []func(*models.Loan) error{
+		validatePrincipal,
+		validateCreditLimit,
+		validatePromo,
+		validateAmortization,
+		validatePrepaymentPenalty,
+		validateInterestMode,
+		validateNegativeAmortizationCap,
+	} {
+		if err := validate(loan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // assignStatementCycleDay assigns a day of the month (1-28) for the statement cycle.
 func (l *Ledger) assignStatementCycleDay() int {
 	r := rand.New(l.randSrc)
 	return r.Intn(maxStatementDay-minStatementDay+1) + minStatementDay
 }
 
+// LoanOption customizes a loan before it is stored by CreateLoan.
+type LoanOption func(*models.Loan)
+
+// WithProduct originates the loan under the product with the given code.
+func WithProduct(code string) LoanOption {
+	return func(loan *models.Loan) {
+		loan.ProductCode = code
+	}
+}
+
 // CreateLoan initializes a new loan for a customer.
-func (l *Ledger) CreateLoan(customerKey string, principal decimal.Decimal, baseRate decimal.Decimal, variance decimal.Decimal) (*models.Loan, error) {
+func (l *Ledger) CreateLoan(ctx context.Context, customerKey string, principal decimal.Decimal, baseRate decimal.Decimal, variance decimal.Decimal, opts ...LoanOption) (*models.Loan, error) {
 	loan := &models.Loan{
 		ID:                          uuid.New(),
 		CustomerKey:                 customerKey,
@@ -50,169 +103,449 @@ func (l *Ledger) CreateLoan(customerKey string, principal decimal.Decimal, baseR
 		BaseInterestRate:            baseRate,
 		InterestRateVariance:        variance,
 		InterestRate:                baseRate.Add(variance), // Effective rate
-		Status:                      "active",
+		Status:                      models.LoanStatusActive,
+		Version:                     1,
 		CreatedAt:                   time.Now(),
 		UpdatedAt:                   time.Now(),
 		LastInterestCalculationDate: nil,                         // Initially nil
 		StatementCycleDay:           l.assignStatementCycleDay(), // Assign statement cycle day
 		AccruedInterest:             decimal.Zero,
+		DelinquencyBucket:           models.DelinquencyCurrent,
+		OddDaysInterest:             decimal.Zero,
+	}
+	for _, opt := range opts {
+		opt(loan)
 	}
 
-	if err := l.storage.CreateLoan(loan); err != nil {
-		return nil, fmt.Errorf("failed to store loan: %w", err)
+	if err := validateLoanTerms(loan); err != nil {
+		return nil, err
 	}
 
-	// Record disbursement
-	transaction := models.Transaction{
-		ID:        uuid.New(),
-		LoanID:    loan.ID,
-		Amount:    principal,
-		Type:      models.TransactionTypeDisbursement,
-		Timestamp: time.Now(),
+	// A loan for a new customer key registers its customer as it is stored, but an inactive
+	// customer takes no new loans
+	if err := l.checkCustomerActive(ctx, loan.CustomerKey); err != nil && !errors.Is(err, models.ErrCustomerNotFound) {
+		return nil, err
 	}
-	if err := l.storage.CreateTransaction(&transaction); err != nil {
-		return nil, fmt.Errorf("failed to store disbursement transaction: %w", err)
+
+	var product *models.Product
+	if loan.ProductCode != "" {
+		var err error
+		if product, err = l.storage.GetProduct(ctx, loan.ProductCode); err != nil {
+			return nil, err
+		}
 	}
 
+	if loan.IndexCode != "" {
+		if err := l.priceFromIndex(ctx, loan); err != nil {
+			return nil, err
+		}
+	}
+	applyAccrualGrace(loan, product)
+	applyOddDays(loan, product)
+
+	// Record disbursement; lines of credit may open without an initial draw. The loan is
+	// stored together with it, so a loan never appears without its disbursement.
+	var disbursement *models.Transaction
+	if principal.IsPositive() {
+		disbursement = &models.Transaction{
+			ID:        uuid.New(),
+			LoanID:    loan.ID,
+			Amount:    principal,
+			Type:      models.TransactionTypeDisbursement,
+			Timestamp: time.Now(),
+		}
+	}
+	err := l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+		if err := tx.CreateLoan(ctx, loan); err != nil {
+			return fmt.Errorf("failed to store loan: %w", err)
+		}
+		var disbursementID *uuid.UUID
+		if disbursement != nil {
+			if err := tx.CreateTransaction(ctx, disbursement); err != nil {
+				return fmt.Errorf("failed to store disbursement transaction: %w", err)
+			}
+			disbursementID = &disbursement.ID
+		}
+		if l.eventSourcing {
+			if err := appendLedgerEvent(ctx, tx, newLedgerEvent(models.LedgerLoanCreated, models.LoanAmounts{}, loan, principal, disbursementID)); err != nil {
+				return err
+			}
+		}
+		setAvailableCredit(loan)
+		setDisclosureRates(loan)
+		return publishEvents(ctx, tx, newWebhookEvent(models.WebhookLoanCreated, loan))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if disbursement != nil {
+		l.broadcast(models.StreamTransactionCreated, loan.ID, disbursement)
+	}
 	return loan, nil
 }
 
 // CalculateDailyInterest iterates through all active loans and accrues daily interest.
-func (l *Ledger) CalculateDailyInterest() {
-	loans, err := l.storage.GetAllActiveLoans()
-	if err != nil {
+// Closed and charged-off loans are not streamed by ForEachActiveLoan and so never accrue.
+func (l *Ledger) CalculateDailyInterest(ctx context.Context) {
+	if _, err := l.RunDailyInterest(ctx, JobScope{}); err != nil {
 		fmt.Printf("Error getting active loans for daily interest calculation: %v\n", err)
-		return
 	}
+}
 
-	today := time.Now().UTC().Truncate(24 * time.Hour) // Truncate to get just the date
-
-	for _, loan := range loans {
-		// Check if interest has already been calculated for today
-		if loan.LastInterestCalculationDate != nil && loan.LastInterestCalculationDate.UTC().Truncate(24*time.Hour).Equal(today) {
-			fmt.Printf("Daily interest for Loan %s already calculated for today. Skipping.\n", loan.ID)
-			continue
-		}
-
-		// Daily interest = Balance * (APR / 365)
-		dailyRate := loan.InterestRate.Div(daysInYear)
-		interestAmount := loan.Balance.Mul(dailyRate)
+// ApplyMonthlyInterest checks if today is the statement cycle day for any loans
+// and applies accrued interest to the balance, or bills it as interest due on simple
+// interest loans. An intent is recorded for every loan
+// before any loan is changed, so a run interrupted part-way is finished exactly by the
+// next run and never applies a cycle's interest twice.
+func (l *Ledger) ApplyMonthlyInterest(ctx context.Context) {
+	if _, err := l.RunMonthlyInterest(ctx, JobScope{}); err != nil {
+		fmt.Printf("Error getting active loans for monthly interest application: %v\n", err)
+	}
+}
 
-		if interestAmount.GreaterThan(decimal.Zero) {
-			loan.AccruedInterest = loan.AccruedInterest.Add(interestAmount)
-			loan.UpdatedAt = time.Now()
-			// Update LastInterestCalculationDate
-			loan.LastInterestCalculationDate = &today
+// GetLoan retrieves a loan by its ID, along with its computed loan-to-value ratio and
+// disclosure rates.
+func (l *Ledger) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	loan, err := l.storage.GetLoan(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	setAvailableCredit(loan)
+	setDisclosureRates(loan)
+	if err := l.setLoanToValue(ctx, loan); err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
 
-			if err := l.storage.UpdateLoan(loan); err != nil {
-				fmt.Printf("Error updating loan %s during daily interest calculation: %v\n", loan.ID, err)
-				continue
-			}
+// GetTransactions retrieves a loan's transaction history, oldest first.
+func (l *Ledger) GetTransactions(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetTransactionsForLoan(ctx, loanID)
+}
 
-			fmt.Printf("Accrued %s daily interest for Loan %s (Total Accrued: %s)\n", interestAmount.StringFixed(2), loan.ID, loan.AccruedInterest.StringFixed(2))
+// QueryTransactions retrieves a page of a loan's transactions matching the query, oldest
+// first, with the total number matching so clients can page through them all.
+func (l *Ledger) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	for _, txType := range query.Types {
+		if !txType.Valid() {
+			return nil, 0, models.Invalidf("invalid transaction type filter: %q", txType)
 		}
 	}
+	if query.From != nil && query.To != nil && query.From.After(*query.To) {
+		return nil, 0, models.Invalidf("invalid date range: from is after to")
+	}
+	if query.MinAmount != nil && query.MaxAmount != nil && query.MinAmount.GreaterThan(*query.MaxAmount) {
+		return nil, 0, models.Invalidf("invalid amount range: min_amount is greater than max_amount")
+	}
+	if query.Limit < 0 || query.Limit > maxTransactionPageSize {
+		return nil, 0, models.Invalidf("invalid limit: must be between 1 and %d", maxTransactionPageSize)
+	}
+	if query.Offset < 0 {
+		return nil, 0, models.Invalidf("invalid offset: must not be negative")
+	}
+	if err := validateMetadata(query.Metadata); err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, 0, err
+	}
+	return l.storage.QueryTransactions(ctx, loanID, query)
 }
 
-// ApplyMonthlyInterest checks if today is the statement cycle day for any loans
-// and applies accrued interest to the balance.
-func (l *Ledger) ApplyMonthlyInterest() {
-	loans, err := l.storage.GetAllActiveLoans()
+// GetAllLoans retrieves all loans that have not been voided.
+func (l *Ledger) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	loans, err := l.storage.GetAllLoans(ctx)
 	if err != nil {
-		fmt.Printf("Error getting active loans for monthly interest application: %v\n", err)
-		return
+		return nil, err
+	}
+	loans = withoutVoided(loans)
+	for _, loan := range loans {
+		setAvailableCredit(loan)
+		setDisclosureRates(loan)
 	}
+	return loans, nil
+}
 
-	todayDay := time.Now().Day()
+// UpdateLoan updates an existing loan, recording status and rate changes on its timeline. A
+// status change must be a permitted lifecycle transition.
+func (l *Ledger) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	return l.updateLoan(ctx, loan, 0)
+}
 
-	for _, loan := range loans {
-		if loan.StatementCycleDay == todayDay {
-			if loan.AccruedInterest.GreaterThan(decimal.Zero) {
-				loan.Balance = loan.Balance.Add(loan.AccruedInterest)
-				loan.UpdatedAt = time.Now()
-
-				transaction := models.Transaction{
-					ID:        uuid.New(),
-					LoanID:    loan.ID,
-					Amount:    loan.AccruedInterest,
-					Type:      models.TransactionTypeInterest,
-					Timestamp: time.Now(),
-				}
-				if err := l.storage.CreateTransaction(&transaction); err != nil {
-					fmt.Printf("Error creating monthly interest transaction for loan %s: %v\n", loan.ID, err)
-					continue
-				}
-
-				fmt.Printf("Applied %s accrued interest to Loan %s on statement day (New Balance: %s)\n", loan.AccruedInterest.StringFixed(2), loan.ID, loan.Balance.StringFixed(2))
-				loan.AccruedInterest = decimal.Zero // Reset accrued interest after application
-
-				if err := l.storage.UpdateLoan(loan); err != nil {
-					fmt.Printf("Error updating loan %s after monthly interest application: %v\n", loan.ID, err)
-					continue
-				}
-			} else {
-				fmt.Printf("No accrued interest to apply for Loan %s on statement day.\n", loan.ID)
+// UpdateLoanIfVersion updates a loan like UpdateLoan, but only if it is still at the given
+// version. A stale version is rejected with models.ErrLoanVersionMismatch so concurrent edits do not
+// silently overwrite each other.
+func (l *Ledger) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	return l.updateLoan(ctx, loan, version)
+}
+
+// updateLoan validates and stores an edited loan, requiring the given version unless it is zero.
+// The loan is read, written and its status and rate changes recorded in one transaction, and
+// written only if no other update has changed it since it was read.
+func (l *Ledger) updateLoan(ctx context.Context, loan *models.Loan, version int) error {
+	return l.inTransaction(ctx, func(ctx context.Context, tl *Ledger) error {
+		existing, err := tl.storage.GetLoan(ctx, loan.ID)
+		if err != nil {
+			return err
+		}
+		if existing.Status == models.LoanStatusVoided {
+			return models.ErrLoanNotActive
+		}
+		if version > 0 && existing.Version != version {
+			return models.ErrLoanVersionMismatch
+		}
+		previousStatus, previousRate := existing.Status, existing.InterestRate
+
+		if !loan.Status.Valid() {
+			return models.Invalidf("invalid loan status: %q", loan.Status)
+		}
+		if !previousStatus.CanTransitionTo(loan.Status) {
+			return fmt.Errorf("%w from %s to %s", models.ErrInvalidStatusTransition, previousStatus, loan.Status)
+		}
+
+		// What the borrower owes changes only through transactions, so an edit keeps the stored
+		// amounts, and the effective rate follows the base rate and variance
+		keepAmounts(loan, existing)
+		loan.InterestRate = loan.BaseInterestRate.Add(loan.InterestRateVariance)
+		if err := validateRates(loan); err != nil {
+			return err
+		}
+		if err := validateLoanTerms(loan); err != nil {
+			return err
+		}
+		if loan.CustomerKey != existing.CustomerKey {
+			if err := tl.checkCustomerActive(ctx, loan.CustomerKey); err != nil && !errors.Is(err, models.ErrCustomerNotFound) {
+				return err
 			}
 		}
-	}
-}
 
-// GetLoan retrieves a loan by its ID.
-func (l *Ledger) GetLoan(id uuid.UUID) (*models.Loan, error) {
-	return l.storage.GetLoan(id)
+		loan.UpdatedAt = time.Now()
+		err = tl.updateLoanWithEvent(ctx, loan, models.LedgerLoanAdjusted, decimal.Zero, nil, func(ctx context.Context, s store.Storage) error {
+			if err := s.UpdateLoanIfVersion(ctx, loan, existing.Version); err != nil {
+				return err
+			}
+			return publishEvents(ctx, s, loanClosedEvents(previousStatus, loan)...)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := tl.recordStatusChange(ctx, loan.ID, previousStatus, loan.Status); err != nil {
+			return err
+		}
+		// Rate edits take effect immediately but are kept in the rate history
+		if !previousRate.Equal(loan.InterestRate) {
+			today := time.Now().UTC().Truncate(24 * time.Hour)
+			if _, err := tl.recordRateChange(ctx, existing, loan.BaseInterestRate, loan.InterestRateVariance, today); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// GetAllLoans retrieves all loans.
-func (l *Ledger) GetAllLoans() ([]*models.Loan, error) {
-	return l.storage.GetAllLoans()
+// keepAmounts copies the amounts owed on the stored loan, and the interest tracked toward
+// them, onto an edit of it.
+func keepAmounts(loan *models.Loan, stored *models.Loan) {
+	loan.Balance = stored.Balance
+	loan.AccruedInterest = stored.AccruedInterest
+	loan.InterestResidual = stored.InterestResidual
+	loan.FeesDue = stored.FeesDue
+	loan.InterestDue = stored.InterestDue
+	loan.EscrowBalance = stored.EscrowBalance
+	loan.OddDaysInterest = stored.OddDaysInterest
+	loan.ChargeOffAmount = stored.ChargeOffAmount
+	loan.PostChargeOffInterest = stored.PostChargeOffInterest
 }
 
-// UpdateLoan updates an existing loan.
-func (l *Ledger) UpdateLoan(loan *models.Loan) error {
-	loan.UpdatedAt = time.Now()
-	return l.storage.UpdateLoan(loan)
+// VoidLoan takes a loan off the books without erasing it: the loan is marked voided with the
+// time it was deleted and its transactions are kept as they are. Voided loans are left out of
+// listings and reports and cannot be edited or paid. Voiding a voided loan changes nothing.
+func (l *Ledger) VoidLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	var voided *models.Loan
+	err := l.changeLoan(ctx, id, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		voided = loan
+		if loan.Status == models.LoanStatusVoided {
+			return nil
+		}
+
+		previousStatus := loan.Status
+		now := time.Now()
+		loan.Status = models.LoanStatusVoided
+		loan.DeletedAt = &now
+		loan.UpdatedAt = now
+		if err := tl.storage.UpdateLoanIfVersion(ctx, loan, loan.Version); err != nil {
+			return fmt.Errorf("failed to void loan: %w", err)
+		}
+		return tl.recordStatusChange(ctx, loan.ID, previousStatus, loan.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return voided, nil
 }
 
-// DeleteLoan deletes a loan.
-func (l *Ledger) DeleteLoan(id uuid.UUID) error {
-	return l.storage.DeleteLoan(id)
+// withoutVoided drops voided loans from a list of loans.
+func withoutVoided(loans []*models.Loan) []*models.Loan {
+	return slices.DeleteFunc(loans, func(loan *models.Loan) bool {
+		return loan.Status == models.LoanStatusVoided
+	})
 }
 
 // RecordPayment processes a payment for a loan.
-func (l *Ledger) RecordPayment(loanID uuid.UUID, amount decimal.Decimal) (*models.Transaction, error) {
-	loan, err := l.storage.GetLoan(loanID)
+func (l *Ledger) RecordPayment(ctx context.Context, loanID uuid.UUID, amount decimal.Decimal, opts ...PaymentOption) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := l.changeLoan(ctx, loanID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		transaction = &models.Transaction{
+			ID:     uuid.New(),
+			LoanID: loan.ID,
+			Amount: amount,
+		}
+		for _, opt := range opts {
+			opt(transaction)
+		}
+
+		now := time.Now()
+		transactionType, paidAt, err := tl.preparePayment(ctx, loan, transaction, now)
+		if err != nil {
+			return err
+		}
+
+		// A payoff settles the interest accrued through the day it is paid
+		var transactions []*models.Transaction
+		if transactionType == models.TransactionTypePayment {
+			interest, err := tl.billPayoffInterest(ctx, loan, amount, paidAt)
+			if err != nil {
+				return err
+			}
+			if interest != nil {
+				transactions = append(transactions, interest)
+			}
+		}
+
+		previousStatus := loan.Status
+		allocation := applyPayment(loan, amount, transactionType, paidAt)
+		loan.UpdatedAt = now
+
+		transaction.Type = transactionType
+		transaction.Timestamp = paidAt
+
+		// The prepayment penalty is charged to the loan before the payment covers it
+		if allocation.penalty.IsPositive() {
+			transactions = append(transactions, &models.Transaction{
+				ID:        uuid.New(),
+				LoanID:    loan.ID,
+				Amount:    allocation.penalty,
+				Type:      models.TransactionTypeFee,
+				Timestamp: paidAt,
+			})
+		}
+		transactions = append(transactions, transaction)
+
+		// The new balance, the transactions behind it, the status change and the request's
+		// idempotency key are committed together, so that nothing fails once the payment is posted
+		events := append([]*models.WebhookEvent{newWebhookEvent(models.WebhookPaymentRecorded, transaction)}, loanClosedEvents(previousStatus, loan)...)
+		if err := tl.updateLoanPublishing(ctx, loan, events, transactions...); err != nil {
+			return fmt.Errorf("failed to record payment: %w", err)
+		}
+		if err := tl.recordStatusChange(ctx, loan.ID, previousStatus, loan.Status); err != nil {
+			return err
+		}
+		return tl.markIdempotencyKeyApplied(ctx)
+	})
 	if err != nil {
 		return nil, err
 	}
+	return transaction, nil
+}
 
-	if loan.Status != "active" {
-		return nil, fmt.Errorf("loan is not active")
+// preparePayment checks that the loan can take the payment and returns the payment's type and
+// the date it was received.
+func (l *Ledger) preparePayment(ctx context.Context, loan *models.Loan, transaction *models.Transaction, now time.Time) (models.TransactionType, time.Time, error) {
+	if err := validateMetadata(transaction.Metadata); err != nil {
+		return "", time.Time{}, err
+	}
+	if transaction.PaymentMethodID != nil {
+		if err := l.usablePaymentMethod(ctx, *transaction.PaymentMethodID, loan); err != nil {
+			return "", time.Time{}, err
+		}
 	}
 
-	loan.Balance = loan.Balance.Sub(amount)
-	loan.UpdatedAt = time.Now()
+	// Payments on charged-off loans are recoveries against the written-off receivable
+	transactionType := models.TransactionTypePayment
+	switch loan.Status {
+	case models.LoanStatusActive, models.LoanStatusDelinquent:
+	case models.LoanStatusChargedOff:
+		transactionType = models.TransactionTypeRecovery
+	default:
+		return "", time.Time{}, models.ErrLoanNotActive
+	}
 
-	// If balance is 0 or negative, close the loan
-	if loan.Balance.LessThanOrEqual(decimal.Zero) {
-		loan.Status = "closed"
-		loan.Balance = decimal.Zero // Ensure balance is not negative
+	// A payment is dated now unless an option supplied the date it was received
+	paidAt := now
+	if !transaction.Timestamp.IsZero() {
+		y, m, d := loan.CreatedAt.Date()
+		if transaction.Timestamp.Before(time.Date(y, m, d, 0, 0, 0, 0, loan.CreatedAt.Location())) {
+			return "", time.Time{}, models.Invalidf("payment date is before the loan was opened")
+		}
+		paidAt = transaction.Timestamp
+		if paidAt.Before(loan.CreatedAt) {
+			paidAt = loan.CreatedAt
+		}
 	}
+	return transactionType, paidAt, nil
+}
+
+// paymentAllocation is how a payment was split across what the borrower owed.
+type paymentAllocation struct {
+	fees      decimal.Decimal // Billed fees due
+	interest  decimal.Decimal // Billed interest due
+	penalty   decimal.Decimal // Prepayment penalty charged for this payment, which it pays first
+	principal decimal.Decimal
+	unapplied decimal.Decimal // Beyond the balance, and dropped as the balance floors at zero
+}
 
-	if err := l.storage.UpdateLoan(loan); err != nil {
-		return nil, fmt.Errorf("failed to update loan balance: %w", err)
+// applyPayment applies a payment to the loan in memory. Prepaying principal early in the loan
+// charges a penalty, which the payment covers after billed fees and interest; the rest reduces
+// the balance, and the loan is brought current and closed once paid off.
+func applyPayment(loan *models.Loan, amount decimal.Decimal, transactionType models.TransactionType, paidAt time.Time) paymentAllocation {
+	allocation := paymentAllocation{penalty: decimal.Zero}
+	if transactionType == models.TransactionTypePayment {
+		allocation.penalty = prepaymentPenalty(loan, amount, paidAt)
 	}
 
-	transaction := &models.Transaction{
-		ID:        uuid.New(),
-		LoanID:    loan.ID,
-		Amount:    amount,
-		Type:      models.TransactionTypePayment,
-		Timestamp: time.Now(),
+	// Fees and interest billed outside the balance are paid before principal
+	feesDue, interestDue := loan.FeesDue, loan.InterestDue
+	remaining := applyToAmountsDue(loan, amount)
+	allocation.fees = feesDue.Sub(loan.FeesDue)
+	allocation.interest = interestDue.Sub(loan.InterestDue)
+
+	penaltyPaid := decimal.Min(allocation.penalty, remaining)
+	allocation.principal = decimal.Max(decimal.Min(remaining.Sub(penaltyPaid), loan.Balance), decimal.Zero)
+	allocation.unapplied = remaining.Sub(penaltyPaid).Sub(allocation.principal)
+	loan.Balance = loan.Balance.Add(allocation.penalty).Sub(remaining)
+
+	if loan.LastPaymentDate == nil || paidAt.After(*loan.LastPaymentDate) {
+		loan.LastPaymentDate = &paidAt
 	}
 
-	if err := l.storage.CreateTransaction(transaction); err != nil {
-		return nil, fmt.Errorf("failed to store payment transaction: %w", err)
+	// A payment brings the loan current; the next due date is derived from it
+	loan.DaysPastDue = 0
+	loan.DelinquencyBucket = models.DelinquencyCurrent
+	if loan.Status == models.LoanStatusDelinquent {
+		loan.Status = models.LoanStatusActive
 	}
 
-	return transaction, nil
+	// If balance is 0 or negative, close the loan; a line of credit stays open for further draws
+	if loan.Balance.LessThanOrEqual(decimal.Zero) {
+		if loan.LoanType != models.LoanTypeLineOfCredit {
+			loan.Status = models.LoanStatusClosed
+		}
+		loan.Balance = decimal.Zero // Ensure balance is not negative
+	}
+	return allocation
 }
@@ -1,90 +1,33 @@
 package ledger
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/pdf"
+	"github.com/mcclellann/fredLoan/pkg/store"
 	"github.com/shopspring/decimal"
 )
 
-// MockStore is a simple in-memory implementation of the Storage interface for testing.
-type MockStore struct {
-	loans        map[uuid.UUID]*models.Loan
-	transactions []*models.Transaction
-}
-
-func NewMockStore() *MockStore {
-	return &MockStore{
-		loans:        make(map[uuid.UUID]*models.Loan),
-		transactions: []*models.Transaction{},
-	}
-}
-
-func (m *MockStore) CreateLoan(loan *models.Loan) error {
-	m.loans[loan.ID] = loan
-	return nil
-}
-
-func (m *MockStore) GetLoan(id uuid.UUID) (*models.Loan, error) {
-	loan, ok := m.loans[id]
-	if !ok {
-		return nil, fmt.Errorf("loan not found")
-	}
-	return loan, nil
-}
-
-func (m *MockStore) UpdateLoan(loan *models.Loan) error {
-	m.loans[loan.ID] = loan
-	return nil
-}
-
-func (m *MockStore) DeleteLoan(id uuid.UUID) error {
-	delete(m.loans, id)
-	return nil
-}
-
-func (m *MockStore) GetAllLoans() ([]*models.Loan, error) {
-	loans := []*models.Loan{}
-	for _, l := range m.loans {
-		loans = append(loans, l)
-	}
-	return loans, nil
-}
-
-func (m *MockStore) GetAllActiveLoans() ([]*models.Loan, error) {
-	loans := []*models.Loan{}
-	for _, l := range m.loans {
-		if l.Status == "active" {
-			loans = append(loans, l)
-		}
-	}
-	return loans, nil
-}
-
-func (m *MockStore) CreateTransaction(tx *models.Transaction) error {
-	m.transactions = append(m.transactions, tx)
-	return nil
-}
-
-func (m *MockStore) GetTransactionsForLoan(loanID uuid.UUID) ([]*models.Transaction, error) {
-	txs := []*models.Transaction{}
-	for _, tx := range m.transactions {
-		if tx.LoanID == loanID {
-			txs = append(txs, tx)
-		}
-	}
-	return txs, nil
-}
-
-func (m *MockStore) Close() error {
-	return nil
-}
-
 func TestCreateLoan(t *testing.T) {
-	store := NewMockStore()
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
 	l := NewLedger(store)
 
 	principal := decimal.NewFromFloat(1000.0)
@@ -92,7 +35,7 @@ func TestCreateLoan(t *testing.T) {
 	variance := decimal.NewFromFloat(-0.02)
 	expectedRate := decimal.NewFromFloat(0.10)
 
-	loan, err := l.CreateLoan("cust123", principal, baseRate, variance)
+	loan, err := l.CreateLoan(ctx, "cust123", principal, baseRate, variance)
 	if err != nil {
 		t.Fatalf("Failed to create loan: %v", err)
 	}
@@ -105,21 +48,24 @@ func TestCreateLoan(t *testing.T) {
 		t.Errorf("Expected effective interest rate %s, got %s", expectedRate, loan.InterestRate)
 	}
 
-	if len(store.transactions) != 1 {
-		t.Errorf("Expected 1 transaction (disbursement), got %d", len(store.transactions))
+	if txs, _ := store.GetTransactionsForLoan(ctx, loan.ID); len(txs) != 1 {
+		t.Errorf("Expected 1 transaction (disbursement), got %d", len(txs))
 	}
 }
 
 func TestCalculateDailyInterest(t *testing.T) {
-	store := NewMockStore()
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
 	l := NewLedger(store)
 
 	principal := decimal.NewFromFloat(1000.0)
 	baseRate := decimal.NewFromFloat(0.10)
-	loan, _ := l.CreateLoan("cust123", principal, baseRate, decimal.Zero)
+	loan, _ := l.CreateLoan(ctx, "cust123", principal, baseRate, decimal.Zero)
 
 	// Run interest calculation
-	l.CalculateDailyInterest()
+	l.CalculateDailyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
 
 	if loan.AccruedInterest.Equal(decimal.Zero) {
 		t.Error("Expected accrued interest to be greater than 0")
@@ -132,22 +78,27 @@ func TestCalculateDailyInterest(t *testing.T) {
 
 	// Run again on same day (should skip)
 	prevAccrued := loan.AccruedInterest
-	l.CalculateDailyInterest()
+	l.CalculateDailyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
 	if !loan.AccruedInterest.Equal(prevAccrued) {
 		t.Error("Interest should not be calculated twice on the same day")
 	}
 }
 
 func TestApplyMonthlyInterest(t *testing.T) {
-	store := NewMockStore()
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
 	l := NewLedger(store)
 
 	accrued := decimal.NewFromFloat(5.0)
-	loan, _ := l.CreateLoan("cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
 	loan.AccruedInterest = accrued
 	loan.StatementCycleDay = time.Now().Day() // Set to today
+	saveLoan(t, store, loan)
 
-	l.ApplyMonthlyInterest()
+	l.ApplyMonthlyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
 
 	expectedBalance := decimal.NewFromFloat(1005.0)
 	if !loan.Balance.Equal(expectedBalance) {
@@ -160,7 +111,8 @@ func TestApplyMonthlyInterest(t *testing.T) {
 
 	// Check if transaction was created
 	found := false
-	for _, tx := range store.transactions {
+	txs, _ := store.GetTransactionsForLoan(ctx, loan.ID)
+	for _, tx := range txs {
 		if tx.Type == models.TransactionTypeInterest && tx.Amount.Equal(accrued) {
 			found = true
 			break
@@ -171,25 +123,214 @@ func TestApplyMonthlyInterest(t *testing.T) {
 	}
 }
 
+func TestRunDailyInterestBackfill(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.CalculateDailyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
+	today := *loan.LastInterestCalculationDate
+	accruedToday := loan.AccruedInterest
+
+	// Backfill the missed day before
+	yesterday := today.AddDate(0, 0, -1)
+	run, err := l.RunDailyInterest(ctx, JobScope{LoanID: loan.ID, Date: yesterday})
+	if err != nil {
+		t.Fatalf("Failed to run daily interest: %v", err)
+	}
+	if run.Processed != 1 || run.Skipped != 0 || run.Failed != 0 {
+		t.Errorf("Expected 1 loan processed, got %+v", run)
+	}
+	loan = reloadLoan(t, l, loan.ID)
+	if !loan.AccruedInterest.Equal(accruedToday.Mul(decimal.NewFromInt(2))) {
+		t.Errorf("Expected accrued interest %s, got %s", accruedToday.Mul(decimal.NewFromInt(2)), loan.AccruedInterest)
+	}
+	if !loan.LastInterestCalculationDate.Equal(today) {
+		t.Errorf("Backfill moved the last accrual date to %s", loan.LastInterestCalculationDate)
+	}
+
+	// Repeating the backfill accrues nothing
+	run, err = l.RunDailyInterest(ctx, JobScope{LoanID: loan.ID, Date: yesterday})
+	if err != nil {
+		t.Fatalf("Failed to run daily interest: %v", err)
+	}
+	if run.Processed != 0 || run.Skipped != 1 {
+		t.Errorf("Expected the repeated backfill to be skipped, got %+v", run)
+	}
+	if accruals, _ := store.GetAccrualsForLoan(ctx, loan.ID, yesterday, today); len(accruals) != 2 {
+		t.Errorf("Expected 2 accruals, got %d", len(accruals))
+	}
+}
+
+func TestRunDailyInterestForOneLoan(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	other, _ := l.CreateLoan(ctx, "cust456", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	run, err := l.RunDailyInterest(ctx, JobScope{LoanID: loan.ID})
+	if err != nil {
+		t.Fatalf("Failed to run daily interest: %v", err)
+	}
+	if run.LoanID == nil || *run.LoanID != loan.ID || run.Processed != 1 {
+		t.Errorf("Expected a run over loan %s, got %+v", loan.ID, run)
+	}
+	if other = reloadLoan(t, l, other.ID); !other.AccruedInterest.IsZero() {
+		t.Errorf("Expected no interest on the other loan, got %s", other.AccruedInterest)
+	}
+
+	if _, err := l.RunDailyInterest(ctx, JobScope{LoanID: uuid.New()}); !errors.Is(err, models.ErrLoanNotFound) {
+		t.Errorf("Expected ErrLoanNotFound, got %v", err)
+	}
+}
+
+func TestRunDailyInterestBatchFailure(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.CreateLoan(ctx, "cust456", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	// Accruals are stored with their loans, so a failed batch stores neither
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"SaveAccrual"}})
+	run, err := l.RunDailyInterest(ctx, JobScope{})
+	if err != nil {
+		t.Fatalf("Failed to run daily interest: %v", err)
+	}
+	if run.Failed != 2 || run.Processed != 0 || len(run.Errors) != 2 {
+		t.Errorf("Expected both loans failed, got %+v", run)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.AccruedInterest.IsZero() || loan.LastInterestCalculationDate != nil {
+		t.Errorf("Expected the loan unchanged by the failed batch, accrued %s", loan.AccruedInterest)
+	}
+
+	faulty.SetConfig(store.FaultConfig{})
+	if run, err = l.RunDailyInterest(ctx, JobScope{}); err != nil || run.Processed != 2 {
+		t.Fatalf("Expected both loans processed on the retry, got %+v (%v)", run, err)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if accruals, _ := mock.GetAccrualsForLoan(ctx, loan.ID, today, today); len(accruals) != 1 {
+		t.Errorf("Expected 1 accrual, got %d", len(accruals))
+	}
+}
+
+// paymentDuringScanStore posts a payment on a loan once a daily interest run has read the
+// loans, before it stores them.
+type paymentDuringScanStore struct {
+	store.Storage
+	pay func()
+}
+
+func (s *paymentDuringScanStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	if err := s.Storage.ForEachActiveLoan(ctx, fn); err != nil {
+		return err
+	}
+	s.pay()
+	return nil
+}
+
+func TestRunDailyInterestKeepsConcurrentPayment(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	direct := NewLedger(mock)
+	loan, _ := direct.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.365), decimal.Zero)
+	l := NewLedger(&paymentDuringScanStore{Storage: mock, pay: func() {
+		if _, err := direct.RecordPayment(ctx, loan.ID, decimal.NewFromInt(100)); err != nil {
+			t.Fatalf("Failed to record payment: %v", err)
+		}
+	}})
+
+	run, err := l.RunDailyInterest(ctx, JobScope{})
+	if err != nil {
+		t.Fatalf("Failed to run daily interest: %v", err)
+	}
+	if run.Processed != 1 || run.Failed != 0 {
+		t.Errorf("Expected the loan processed once, got %+v", run)
+	}
+
+	// The accrual is worked out again on the balance the payment left
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(900)) || !loan.AccruedInterest.Equal(decimal.NewFromFloat(0.9)) {
+		t.Errorf("Expected balance 900 accruing 0.90, got %s accruing %s", loan.Balance, loan.AccruedInterest)
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if accruals, _ := mock.GetAccrualsForLoan(ctx, loan.ID, today, today); len(accruals) != 1 || !accruals[0].Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected one accrual on balance 900, got %+v", accruals)
+	}
+}
+
+func TestRunMonthlyInterestForDate(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	loan.AccruedInterest = decimal.NewFromFloat(5.0)
+	missed := time.Now().AddDate(0, 0, -3)
+	loan.StatementCycleDay = missed.Day()
+	saveLoan(t, store, loan)
+
+	// Today is not the loan's statement day
+	run, _ := l.RunMonthlyInterest(ctx, JobScope{})
+	if run.Processed != 0 || run.Skipped != 1 {
+		t.Errorf("Expected the loan to be skipped, got %+v", run)
+	}
+
+	run, err := l.RunMonthlyInterest(ctx, JobScope{Date: missed})
+	if err != nil {
+		t.Fatalf("Failed to run monthly interest: %v", err)
+	}
+	if run.Processed != 1 {
+		t.Errorf("Expected 1 loan processed, got %+v", run)
+	}
+	loan = reloadLoan(t, l, loan.ID)
+	if !loan.Balance.Equal(decimal.NewFromFloat(1005.0)) {
+		t.Errorf("Expected balance 1005, got %s", loan.Balance)
+	}
+
+	// The cycle's interest is applied once
+	loan.AccruedInterest = decimal.NewFromFloat(1.0)
+	saveLoan(t, store, loan)
+	run, _ = l.RunMonthlyInterest(ctx, JobScope{Date: missed})
+	loan = reloadLoan(t, l, loan.ID)
+	if run.Processed != 0 || !loan.Balance.Equal(decimal.NewFromFloat(1005.0)) {
+		t.Errorf("Expected the repeated run to apply nothing, got %+v and balance %s", run, loan.Balance)
+	}
+}
+
 func TestRecordPayment(t *testing.T) {
-	store := NewMockStore()
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
 	l := NewLedger(store)
 
-	loan, _ := l.CreateLoan("cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
 
 	payment := decimal.NewFromFloat(400.0)
-	_, err := l.RecordPayment(loan.ID, payment)
+	_, err := l.RecordPayment(ctx, loan.ID, payment)
 	if err != nil {
 		t.Fatalf("Failed to record payment: %v", err)
 	}
 
 	expectedBalance := decimal.NewFromFloat(600.0)
+	loan = reloadLoan(t, l, loan.ID)
 	if !loan.Balance.Equal(expectedBalance) {
 		t.Errorf("Expected balance %s, got %s", expectedBalance, loan.Balance)
 	}
 
 	// Pay off the loan
-	l.RecordPayment(loan.ID, expectedBalance)
+	l.RecordPayment(ctx, loan.ID, expectedBalance)
+	loan = reloadLoan(t, l, loan.ID)
 	if loan.Status != "closed" {
 		t.Errorf("Expected status 'closed', got %s", loan.Status)
 	}
@@ -197,3 +338,2538 @@ func TestRecordPayment(t *testing.T) {
 		t.Errorf("Expected balance 0, got %s", loan.Balance)
 	}
 }
+
+func TestRecordPaymentIsAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	// The loan update succeeds but the transaction insert after it fails
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"CreateTransaction"}})
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(400.0)); err == nil {
+		t.Fatal("Expected the payment to fail")
+	}
+	faulty.SetConfig(store.FaultConfig{})
+
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromFloat(1000.0)) || loan.LastPaymentDate != nil {
+		t.Errorf("Expected the loan unchanged by the failed payment, balance %s", loan.Balance)
+	}
+	if payments := transactionsOfType(mock, loan.ID, models.TransactionTypePayment); len(payments) != 0 {
+		t.Errorf("Expected no payment transaction, got %d", len(payments))
+	}
+
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(400.0)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromFloat(600.0)) {
+		t.Errorf("Expected balance 600, got %s", loan.Balance)
+	}
+}
+
+func TestRecordPaymentConcurrently(t *testing.T) {
+	ctx := context.Background()
+
+	sqlite, err := store.NewSQLiteStore(filepath.Join(t.TempDir(), "payments.db"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqlite.Close()
+	// Latency widens the window between reading the loan and writing it back
+	l := NewLedger(store.NewFaultyStore(sqlite, store.FaultConfig{Latency: time.Millisecond, LatencyJitter: time.Millisecond}))
+
+	loan, err := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(10000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+
+	const payments = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, payments)
+	for range payments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(10)); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Failed to record payment: %v", err)
+	}
+
+	// Every payment reduces the balance; none overwrites another
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(9800)) {
+		t.Errorf("Expected balance 9800 after %d payments of 10, got %s", payments, loan.Balance)
+	}
+	if recorded, _ := l.GetTransactions(ctx, loan.ID); len(recorded) != payments+1 {
+		t.Errorf("Expected the disbursement and %d payments, got %d transactions", payments, len(recorded))
+	}
+}
+
+func TestRecordPaymentAppliesIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	// A payment that fails leaves its key free to be released for a retry
+	if _, err := l.ReserveIdempotencyKey(ctx, "pay-1", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"CreateTransaction"}})
+	if _, err := l.RecordPayment(WithIdempotencyKey(ctx, "pay-1"), loan.ID, decimal.NewFromFloat(400.0)); err == nil {
+		t.Fatal("Expected the payment to fail")
+	}
+	faulty.SetConfig(store.FaultConfig{})
+	if record, _ := mock.GetIdempotencyRecord(ctx, "pay-1"); record.AppliedAt != nil {
+		t.Error("Expected the key not applied by a failed payment")
+	}
+	if err := l.ReleaseIdempotencyKey(ctx, "pay-1"); err != nil {
+		t.Fatalf("Failed to release key: %v", err)
+	}
+
+	// A payment that posts applies its key along with it, and the key is then kept
+	if _, err := l.ReserveIdempotencyKey(ctx, "pay-1", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.RecordPayment(WithIdempotencyKey(ctx, "pay-1"), loan.ID, decimal.NewFromFloat(400.0)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	if record, _ := mock.GetIdempotencyRecord(ctx, "pay-1"); record.AppliedAt == nil {
+		t.Error("Expected the key applied by the payment")
+	}
+	if err := l.ReleaseIdempotencyKey(ctx, "pay-1"); !errors.Is(err, models.ErrIdempotencyKeyApplied) {
+		t.Errorf("Expected an applied key not to be released, got %v", err)
+	}
+	if record, _ := mock.GetIdempotencyRecord(ctx, "pay-1"); record == nil {
+		t.Error("Expected the applied key kept")
+	}
+}
+
+func TestArchiveClosedLoans(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	closed, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(100.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.RecordPayment(ctx, closed.ID, decimal.NewFromFloat(100.0))
+	closed = reloadLoan(t, l, closed.ID)
+	closed.UpdatedAt = time.Now().AddDate(0, -13, 0) // Closed over a year ago
+	saveLoan(t, store, closed)
+
+	active, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(100.0), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	archived, err := l.ArchiveClosedLoans(ctx, 12)
+	if err != nil {
+		t.Fatalf("Failed to archive loans: %v", err)
+	}
+	if archived != 1 {
+		t.Fatalf("Expected 1 archived loan, got %d", archived)
+	}
+
+	if _, err := l.GetLoan(ctx, closed.ID); err == nil {
+		t.Error("Expected archived loan to be removed from the hot tables")
+	}
+	if _, err := l.GetLoan(ctx, active.ID); err != nil {
+		t.Errorf("Expected active loan to remain, got error: %v", err)
+	}
+
+	if _, err := l.GetArchivedLoan(ctx, closed.ID); err != nil {
+		t.Errorf("Expected archived loan to be retrievable: %v", err)
+	}
+	txs, _ := l.GetArchivedTransactions(ctx, closed.ID)
+	if len(txs) != 2 {
+		t.Errorf("Expected 2 archived transactions, got %d", len(txs))
+	}
+}
+
+// objectRecorder is an ArchiveUploader keeping the objects put to it, failing once fail is set.
+type objectRecorder struct {
+	objects map[string][]byte
+	fail    error
+}
+
+func (r *objectRecorder) PutObject(ctx context.Context, key string, body []byte, contentType string) error {
+	if r.fail != nil {
+		return r.fail
+	}
+	r.objects[key] = body
+	return nil
+}
+
+func TestExportArchive(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	closeLoan := func(closedAgo time.Time) *models.Loan {
+		loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(100.0), decimal.NewFromFloat(0.10), decimal.Zero)
+		l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0))
+		loan = reloadLoan(t, l, loan.ID)
+		loan.UpdatedAt = closedAgo
+		saveLoan(t, store, loan)
+		return loan
+	}
+	old := closeLoan(time.Now().AddDate(-8, 0, 0))
+	recent := closeLoan(time.Now().AddDate(-2, 0, 0))
+	l.ArchiveClosedLoans(ctx, 12)
+
+	if _, err := l.ExportArchive(ctx, &objectRecorder{}, 0, false); err == nil || !strings.HasPrefix(err.Error(), "invalid") {
+		t.Errorf("Expected an invalid age to be refused, got %v", err)
+	}
+
+	uploader := &objectRecorder{objects: map[string][]byte{}, fail: errors.New("unavailable")}
+	if _, err := l.ExportArchive(ctx, uploader, 7, false); err == nil {
+		t.Fatal("Expected the failed upload to fail the export")
+	}
+	uploader.fail = nil
+
+	export, err := l.ExportArchive(ctx, uploader, 7, false)
+	if err != nil {
+		t.Fatalf("Failed to export archive: %v", err)
+	}
+	if export.Loans != 1 || export.Transactions != 2 || len(export.Objects) != 1 || len(uploader.objects) != 1 {
+		t.Fatalf("Expected one object with the old loan and its 2 transactions, got %+v", export)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(uploader.objects[export.Objects[0]]))
+	if err != nil {
+		t.Fatalf("Expected a gzip object: %v", err)
+	}
+	var record archivedLoanRecord
+	if err := json.NewDecoder(zr).Decode(&record); err != nil || record.Loan.ID != old.ID || len(record.Transactions) != 2 {
+		t.Errorf("Expected the old loan's record, got %+v (%v)", record, err)
+	}
+
+	// An exported loan stays in the archive but is not exported again
+	if _, err := l.GetArchivedLoan(ctx, old.ID); err != nil {
+		t.Errorf("Expected the exported loan kept without pruning: %v", err)
+	}
+	if export, _ = l.ExportArchive(ctx, uploader, 7, false); export.Loans != 0 {
+		t.Errorf("Expected nothing left to export, got %d loans", export.Loans)
+	}
+
+	export, err = l.ExportArchive(ctx, uploader, 1, true)
+	if err != nil || export.Loans != 1 || !export.Pruned {
+		t.Fatalf("Expected the recent loan exported and pruned, got %+v (%v)", export, err)
+	}
+	if _, err := l.GetArchivedLoan(ctx, recent.ID); err == nil {
+		t.Error("Expected the pruned loan deleted from the archive")
+	}
+	if txs, _ := l.GetArchivedTransactions(ctx, recent.ID); len(txs) != 0 {
+		t.Errorf("Expected the pruned loan's transactions deleted, got %d", len(txs))
+	}
+}
+
+func TestUpdateDelinquency(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	// Last paid long enough ago that no payment was made by the next due date
+	lastPaid := time.Now().AddDate(0, 0, -100)
+	loan.LastPaymentDate = &lastPaid
+	saveLoan(t, store, loan)
+	expectedDPD := daysPastDue(loan, time.Now().UTC().Truncate(24*time.Hour))
+
+	l.UpdateDelinquency(ctx)
+	loan = reloadLoan(t, l, loan.ID)
+
+	if loan.DaysPastDue != expectedDPD || expectedDPD == 0 {
+		t.Fatalf("Expected %d days past due, got %d", expectedDPD, loan.DaysPastDue)
+	}
+	if loan.DelinquencyBucket != models.BucketForDaysPastDue(expectedDPD) {
+		t.Errorf("Expected bucket %s, got %s", models.BucketForDaysPastDue(expectedDPD), loan.DelinquencyBucket)
+	}
+
+	delinquent, err := l.GetDelinquentLoans(ctx, loan.DelinquencyBucket)
+	if err != nil {
+		t.Fatalf("Failed to get delinquent loans: %v", err)
+	}
+	if len(delinquent) != 1 {
+		t.Errorf("Expected 1 delinquent loan, got %d", len(delinquent))
+	}
+
+	// A payment brings the loan current
+	l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(50.0))
+	l.UpdateDelinquency(ctx)
+	loan = reloadLoan(t, l, loan.ID)
+	if loan.DaysPastDue != 0 || loan.DelinquencyBucket != models.DelinquencyCurrent {
+		t.Errorf("Expected loan to be current after payment, got %d days past due (%s)", loan.DaysPastDue, loan.DelinquencyBucket)
+	}
+}
+
+func TestBucketForDaysPastDue(t *testing.T) {
+	cases := map[int]models.DelinquencyBucket{
+		0:   models.DelinquencyCurrent,
+		1:   models.Delinquency1To29,
+		30:  models.Delinquency30,
+		61:  models.Delinquency60,
+		90:  models.Delinquency90,
+		200: models.Delinquency120,
+	}
+	for dpd, expected := range cases {
+		if got := models.BucketForDaysPastDue(dpd); got != expected {
+			t.Errorf("Expected bucket %s for %d days past due, got %s", expected, dpd, got)
+		}
+	}
+}
+
+func TestChargeOffLoan(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	loan.AccruedInterest = decimal.NewFromFloat(5.0)
+	saveLoan(t, store, loan)
+
+	if _, err := l.ChargeOffLoan(ctx, loan.ID); err != nil {
+		t.Fatalf("Failed to charge off loan: %v", err)
+	}
+	loan = reloadLoan(t, l, loan.ID)
+
+	if loan.Status != "charged_off" {
+		t.Errorf("Expected status 'charged_off', got %s", loan.Status)
+	}
+	expected := decimal.NewFromFloat(1005.0)
+	if !loan.ChargeOffAmount.Equal(expected) || !loan.Balance.Equal(expected) {
+		t.Errorf("Expected charge-off amount and retained balance %s, got %s and %s", expected, loan.ChargeOffAmount, loan.Balance)
+	}
+
+	// Charged-off loans no longer accrue interest
+	l.CalculateDailyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
+	if !loan.AccruedInterest.Equal(decimal.Zero) {
+		t.Errorf("Expected no accrual on charged-off loan, got %s", loan.AccruedInterest)
+	}
+
+	// Payments are recorded as recoveries
+	tx, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0))
+	if err != nil {
+		t.Fatalf("Failed to record recovery: %v", err)
+	}
+	if tx.Type != models.TransactionTypeRecovery {
+		t.Errorf("Expected recovery transaction, got %s", tx.Type)
+	}
+
+	if _, err := l.ChargeOffLoan(ctx, loan.ID); err == nil {
+		t.Error("Expected error charging off a loan twice")
+	}
+}
+
+func TestAutoChargeOff(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	late, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	late.DaysPastDue = 125
+	saveLoan(t, store, late)
+	early, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	early.DaysPastDue = 45
+	saveLoan(t, store, early)
+
+	// Disabled by default
+	l.AutoChargeOff(ctx)
+	if late = reloadLoan(t, l, late.ID); late.Status != "active" {
+		t.Fatalf("Expected no automatic charge-off when disabled, got status %s", late.Status)
+	}
+
+	l.SetAutoChargeOff(120)
+	l.AutoChargeOff(ctx)
+	late, early = reloadLoan(t, l, late.ID), reloadLoan(t, l, early.ID)
+	if late.Status != "charged_off" {
+		t.Errorf("Expected loan 125 days past due to be charged off, got %s", late.Status)
+	}
+	if early.Status != "active" {
+		t.Errorf("Expected loan 45 days past due to remain active, got %s", early.Status)
+	}
+}
+
+func TestCalculatePostChargeOffInterest(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	l.CreateProduct(ctx, &models.Product{Code: "recovery", Name: "Recovery Accrual", AccrueAfterChargeOff: true})
+	l.CreateProduct(ctx, &models.Product{Code: "standard", Name: "Standard"})
+
+	if _, err := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("missing")); err == nil {
+		t.Error("Expected error creating loan with unknown product")
+	}
+
+	accruing, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("recovery"))
+	standard, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("standard"))
+	l.ChargeOffLoan(ctx, accruing.ID)
+	l.ChargeOffLoan(ctx, standard.ID)
+
+	l.CalculatePostChargeOffInterest(ctx)
+	accruing, standard = reloadLoan(t, l, accruing.ID), reloadLoan(t, l, standard.ID)
+
+	expected := decimal.NewFromFloat(1000.0).Mul(decimal.NewFromFloat(0.10).Div(decimal.NewFromInt(365)))
+	if !accruing.PostChargeOffInterest.Equal(expected) {
+		t.Errorf("Expected recovery interest %s, got %s", expected, accruing.PostChargeOffInterest)
+	}
+	if !accruing.Balance.Equal(decimal.NewFromFloat(1000.0)) || !accruing.AccruedInterest.Equal(decimal.Zero) {
+		t.Errorf("Expected recovery interest to stay out of balance and accrued interest, got %s and %s", accruing.Balance, accruing.AccruedInterest)
+	}
+	if !standard.PostChargeOffInterest.Equal(decimal.Zero) {
+		t.Errorf("Expected no recovery interest for product without accrual, got %s", standard.PostChargeOffInterest)
+	}
+
+	// Runs at most once per day
+	l.CalculatePostChargeOffInterest(ctx)
+	accruing = reloadLoan(t, l, accruing.ID)
+	if !accruing.PostChargeOffInterest.Equal(expected) {
+		t.Error("Recovery interest should not be calculated twice on the same day")
+	}
+}
+
+func TestGetTimeline(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(100.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.AddNote(ctx, loan.ID, "agent1", "Customer called about payoff"); err != nil {
+		t.Fatalf("Failed to add note: %v", err)
+	}
+	l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0))
+
+	timeline, err := l.GetTimeline(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+
+	// Each webhook published for the loan is noted next to the change it reports
+	expected := []string{"disbursement", "notification", "note", "payment", "notification", "notification", "status_change"}
+	if len(timeline) != len(expected) {
+		t.Fatalf("Expected %d timeline entries, got %d", len(expected), len(timeline))
+	}
+	for i, entry := range timeline {
+		if entry.Type != expected[i] {
+			t.Errorf("Expected entry %d to be %s, got %s", i, expected[i], entry.Type)
+		}
+		if i > 0 && entry.Timestamp.Before(timeline[i-1].Timestamp) {
+			t.Errorf("Timeline entry %d is out of order", i)
+		}
+	}
+	for i, eventType := range map[int]models.WebhookEventType{1: models.WebhookLoanCreated, 4: models.WebhookPaymentRecorded, 5: models.WebhookLoanClosed} {
+		if !strings.Contains(timeline[i].Description, string(eventType)) {
+			t.Errorf("Expected entry %d to note the %s webhook, got %q", i, eventType, timeline[i].Description)
+		}
+	}
+}
+
+func TestRefinanceLoan(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	old, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.12), decimal.Zero, WithTerm(36))
+	old.AccruedInterest = decimal.NewFromFloat(10.0)
+	saveLoan(t, store, old)
+
+	refinanced, err := l.RefinanceLoan(ctx, old.ID, decimal.NewFromFloat(0.08), decimal.NewFromFloat(0.01), 60)
+	if err != nil {
+		t.Fatalf("Failed to refinance loan: %v", err)
+	}
+	old = reloadLoan(t, l, old.ID)
+
+	if old.Status != "closed" || !old.Balance.Equal(decimal.Zero) {
+		t.Errorf("Expected old loan closed with zero balance, got %s with %s", old.Status, old.Balance)
+	}
+
+	expectedPrincipal := decimal.NewFromFloat(1010.0)
+	if !refinanced.Principal.Equal(expectedPrincipal) {
+		t.Errorf("Expected new principal %s, got %s", expectedPrincipal, refinanced.Principal)
+	}
+	if !refinanced.InterestRate.Equal(decimal.NewFromFloat(0.09)) {
+		t.Errorf("Expected new rate 0.09, got %s", refinanced.InterestRate)
+	}
+	if refinanced.TermMonths != 60 {
+		t.Errorf("Expected term 60, got %d", refinanced.TermMonths)
+	}
+	if refinanced.RefinancedFrom == nil || *refinanced.RefinancedFrom != old.ID {
+		t.Errorf("Expected new loan to link to %s, got %v", old.ID, refinanced.RefinancedFrom)
+	}
+
+	if _, err := l.RefinanceLoan(ctx, old.ID, decimal.NewFromFloat(0.08), decimal.Zero, 60); err == nil {
+		t.Error("Expected error refinancing a closed loan")
+	}
+}
+
+func TestRefinanceLoanAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	old, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.12), decimal.Zero)
+	old.Status = models.LoanStatusDelinquent
+	saveLoan(t, mock, old)
+
+	// The old loan is closed but its status change cannot be recorded
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"CreateLoanEvent"}})
+	if _, err := l.RefinanceLoan(ctx, old.ID, decimal.NewFromFloat(0.08), decimal.Zero, 60); err == nil {
+		t.Fatal("Expected the refinance to fail")
+	}
+	faulty.SetConfig(store.FaultConfig{})
+
+	if old = reloadLoan(t, l, old.ID); old.Status != models.LoanStatusDelinquent || !old.Balance.Equal(decimal.NewFromFloat(1000.0)) {
+		t.Errorf("Expected the old loan untouched, got %s with %s", old.Status, old.Balance)
+	}
+	if loans, _ := mock.GetLoansByCustomerKey(ctx, "cust123"); len(loans) != 1 {
+		t.Errorf("Expected no refinanced loan, got %d loans", len(loans))
+	}
+	if txs := transactionsOfType(mock, old.ID, models.TransactionTypeRefinance); len(txs) != 0 {
+		t.Errorf("Expected no refinance transaction, got %d", len(txs))
+	}
+
+	if _, err := l.RefinanceLoan(ctx, old.ID, decimal.NewFromFloat(0.08), decimal.Zero, 60); err != nil {
+		t.Fatalf("Failed to refinance loan: %v", err)
+	}
+	events, _ := mock.GetLoanEventsForLoan(ctx, old.ID)
+	var described []string
+	for _, event := range events {
+		described = append(described, event.Description)
+	}
+	if !slices.Contains(described, "Status changed from delinquent to closed") {
+		t.Errorf("Expected the status change from delinquent, got %q", described)
+	}
+}
+
+func TestChangeRate(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	principal := decimal.NewFromFloat(1000.0)
+	loan, _ := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.10), decimal.Zero)
+
+	// A future-dated change does not affect today's accrual
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	if _, err := l.ChangeRate(ctx, loan.ID, decimal.NewFromFloat(0.20), decimal.Zero, tomorrow); err != nil {
+		t.Fatalf("Failed to schedule rate change: %v", err)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.InterestRate.Equal(decimal.NewFromFloat(0.10)) {
+		t.Errorf("Expected current rate to remain 0.10, got %s", loan.InterestRate)
+	}
+
+	// A change effective today is applied immediately and used by accrual
+	if _, err := l.ChangeRate(ctx, loan.ID, decimal.NewFromFloat(0.05), decimal.NewFromFloat(0.01), time.Now()); err != nil {
+		t.Fatalf("Failed to apply rate change: %v", err)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.InterestRate.Equal(decimal.NewFromFloat(0.06)) {
+		t.Errorf("Expected rate 0.06 after change, got %s", loan.InterestRate)
+	}
+
+	l.CalculateDailyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
+	expected := principal.Mul(decimal.NewFromFloat(0.06).Div(decimal.NewFromInt(365)))
+	if !loan.AccruedInterest.Equal(expected) {
+		t.Errorf("Expected accrual at rate in effect %s, got %s", expected, loan.AccruedInterest)
+	}
+
+	// Changes may not be backdated over accrued days
+	if _, err := l.ChangeRate(ctx, loan.ID, decimal.NewFromFloat(0.07), decimal.Zero, time.Now()); err == nil {
+		t.Error("Expected error for rate change effective on an already accrued day")
+	}
+
+	history, _ := l.GetRateHistory(ctx, loan.ID)
+	if len(history) != 2 {
+		t.Errorf("Expected 2 rate changes in history, got %d", len(history))
+	}
+}
+
+func TestChangeRateAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	loan, err := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+
+	// A change the loan cannot take leaves no rate change or event behind
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"UpdateLoanIfVersion"}})
+	if _, err := l.ChangeRate(ctx, loan.ID, decimal.NewFromFloat(0.05), decimal.Zero, time.Now()); err == nil {
+		t.Error("Expected the rate change to fail with the loan update")
+	}
+	faulty.SetConfig(store.FaultConfig{})
+	if history, _ := l.GetRateHistory(ctx, loan.ID); len(history) != 0 {
+		t.Errorf("Expected the rate change rolled back with the loan, got %d changes", len(history))
+	}
+	if events := eventsOfType(mock, loan.ID, models.LoanEventRateChange); len(events) != 0 {
+		t.Errorf("Expected no rate change event, got %d", len(events))
+	}
+
+	// Nor does an edit whose rate change cannot be recorded change the loan
+	edit := reloadLoan(t, l, loan.ID)
+	edit.BaseInterestRate = decimal.NewFromFloat(0.05)
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"CreateRateChange"}})
+	if err := l.UpdateLoan(ctx, edit); err == nil {
+		t.Error("Expected the edit to fail with its rate change")
+	}
+	faulty.SetConfig(store.FaultConfig{})
+	if loan = reloadLoan(t, l, loan.ID); !loan.InterestRate.Equal(decimal.NewFromFloat(0.10)) {
+		t.Errorf("Expected the loan to keep rate 0.10, got %s", loan.InterestRate)
+	}
+}
+
+func TestPaymentMethodLifecycle(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	if err := l.AddPaymentMethod(ctx, &models.PaymentMethod{CustomerKey: "cust123", Type: models.PaymentMethodCard, Token: "4111 1111 1111 1111"}); err == nil {
+		t.Error("Expected raw card number to be rejected")
+	}
+
+	method := &models.PaymentMethod{CustomerKey: "cust123", Type: models.PaymentMethodCard, Token: "tok_visa_abc123", Last4: "1111"}
+	if err := l.AddPaymentMethod(ctx, method); err != nil {
+		t.Fatalf("Failed to add payment method: %v", err)
+	}
+	if method.Status != models.PaymentMethodPending {
+		t.Errorf("Expected new payment method to be pending, got %s", method.Status)
+	}
+
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(10.0), WithPaymentMethod(method.ID)); err == nil {
+		t.Error("Expected unverified payment method to be rejected")
+	}
+
+	if _, err := l.VerifyPaymentMethod(ctx, method.ID); err != nil {
+		t.Fatalf("Failed to verify payment method: %v", err)
+	}
+	tx, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(10.0), WithPaymentMethod(method.ID))
+	if err != nil {
+		t.Fatalf("Failed to record payment with verified method: %v", err)
+	}
+	if tx.PaymentMethodID == nil || *tx.PaymentMethodID != method.ID {
+		t.Errorf("Expected payment to reference method %s", method.ID)
+	}
+
+	l.ExpirePaymentMethod(ctx, method.ID)
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(10.0), WithPaymentMethod(method.ID)); err == nil {
+		t.Error("Expected expired payment method to be rejected")
+	}
+}
+
+type stubIndexSource struct {
+	rate     decimal.Decimal
+	observed time.Time
+}
+
+func (s stubIndexSource) Name() string { return "stub" }
+
+func (s stubIndexSource) LatestRate(indexCode string) (decimal.Decimal, time.Time, error) {
+	return s.rate, s.observed, nil
+}
+
+func TestIndexedLoanRepricing(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	principal := decimal.NewFromFloat(1000.0)
+	margin := decimal.NewFromFloat(0.03)
+
+	if _, err := l.CreateLoan(ctx, "cust123", principal, decimal.Zero, margin, WithIndex("SOFR")); err == nil {
+		t.Fatal("Expected error creating a loan on an index with no published rate")
+	}
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	if _, _, err := l.PublishIndexRate(ctx, "SOFR", decimal.NewFromFloat(0.05), yesterday, IndexSourceManual); err != nil {
+		t.Fatalf("Failed to publish index rate: %v", err)
+	}
+
+	indexed, err := l.CreateLoan(ctx, "cust123", principal, decimal.Zero, margin, WithIndex("SOFR"))
+	if err != nil {
+		t.Fatalf("Failed to create indexed loan: %v", err)
+	}
+	if !indexed.InterestRate.Equal(decimal.NewFromFloat(0.08)) {
+		t.Errorf("Expected index plus margin 0.08, got %s", indexed.InterestRate)
+	}
+	fixed, _ := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.10), decimal.Zero)
+
+	src := stubIndexSource{rate: decimal.NewFromFloat(0.045), observed: time.Now()}
+	_, repriced, err := l.RefreshIndexRate(ctx, src, "SOFR")
+	if err != nil {
+		t.Fatalf("Failed to refresh index rate: %v", err)
+	}
+	if repriced != 1 {
+		t.Errorf("Expected 1 loan repriced, got %d", repriced)
+	}
+	indexed, fixed = reloadLoan(t, l, indexed.ID), reloadLoan(t, l, fixed.ID)
+	if !indexed.InterestRate.Equal(decimal.NewFromFloat(0.075)) {
+		t.Errorf("Expected repriced rate 0.075, got %s", indexed.InterestRate)
+	}
+	if !fixed.InterestRate.Equal(decimal.NewFromFloat(0.10)) {
+		t.Errorf("Expected fixed-rate loan to keep 0.10, got %s", fixed.InterestRate)
+	}
+
+	// Refreshing the same observation again is a no-op
+	if _, repriced, _ := l.RefreshIndexRate(ctx, src, "SOFR"); repriced != 0 {
+		t.Errorf("Expected no repricing for an unchanged observation, got %d", repriced)
+	}
+
+	history, _ := l.GetRateHistory(ctx, indexed.ID)
+	if len(history) != 1 {
+		t.Errorf("Expected 1 rate change in history, got %d", len(history))
+	}
+	rates, _ := l.GetIndexRates(ctx, "SOFR")
+	if len(rates) != 2 {
+		t.Errorf("Expected 2 index observations, got %d", len(rates))
+	}
+}
+
+func TestIndexedLoanRepricingAtomic(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1)
+	l.PublishIndexRate(ctx, "SOFR", decimal.NewFromFloat(0.05), yesterday, IndexSourceManual)
+	loan, err := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.Zero, decimal.NewFromFloat(0.03), WithIndex("SOFR"))
+	if err != nil {
+		t.Fatalf("Failed to create indexed loan: %v", err)
+	}
+
+	// The rate change is recorded but the loan cannot be repriced
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"UpdateLoanIfVersion"}})
+	if _, repriced, _ := l.PublishIndexRate(ctx, "SOFR", decimal.NewFromFloat(0.045), time.Now(), IndexSourceManual); repriced != 0 {
+		t.Errorf("Expected no loan repriced, got %d", repriced)
+	}
+	faulty.SetConfig(store.FaultConfig{})
+
+	if history, _ := l.GetRateHistory(ctx, loan.ID); len(history) != 0 {
+		t.Errorf("Expected the rate change rolled back with the loan, got %d changes", len(history))
+	}
+	if events := eventsOfType(mock, loan.ID, models.LoanEventRateChange); len(events) != 0 {
+		t.Errorf("Expected no rate change event, got %d", len(events))
+	}
+
+	// Nothing pending stops the next publication from repricing the loan
+	if _, repriced, _ := l.PublishIndexRate(ctx, "SOFR", decimal.NewFromFloat(0.045), time.Now(), IndexSourceManual); repriced != 1 {
+		t.Errorf("Expected the loan repriced, got %d", repriced)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.InterestRate.Equal(decimal.NewFromFloat(0.075)) {
+		t.Errorf("Expected repriced rate 0.075, got %s", loan.InterestRate)
+	}
+}
+
+func TestPromoRate(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	principal := decimal.NewFromFloat(1000.0)
+	today := time.Now().UTC()
+
+	promo, err := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.20), decimal.Zero,
+		WithPromo(decimal.Zero, today.AddDate(0, 0, -1), today.AddDate(0, 3, 0)))
+	if err != nil {
+		t.Fatalf("Failed to create promo loan: %v", err)
+	}
+	expired, _ := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.20), decimal.Zero,
+		WithPromo(decimal.Zero, today.AddDate(0, -6, 0), today.AddDate(0, 0, -1)))
+
+	l.CalculateDailyInterest(ctx)
+	promo, expired = reloadLoan(t, l, promo.ID), reloadLoan(t, l, expired.ID)
+
+	if !promo.AccruedInterest.IsZero() {
+		t.Errorf("Expected no accrual during 0%% promo, got %s", promo.AccruedInterest)
+	}
+	expected := DailyInterest(principal, decimal.NewFromFloat(0.20))
+	if !expired.AccruedInterest.Equal(expected) {
+		t.Errorf("Expected accrual at effective rate after promo %s, got %s", expected, expired.AccruedInterest)
+	}
+
+	if _, err := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.20), decimal.Zero,
+		WithPromo(decimal.Zero, today, today.AddDate(0, 0, -1))); err == nil {
+		t.Error("Expected error for promo ending before it starts")
+	}
+}
+
+func TestBalloonSchedule(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	principal := decimal.NewFromInt(100000)
+	loan, err := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.06), decimal.Zero, WithTerm(60), WithAmortization(360))
+	if err != nil {
+		t.Fatalf("Failed to create balloon loan: %v", err)
+	}
+
+	schedule, err := l.GetSchedule(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to build schedule: %v", err)
+	}
+	if !schedule.MonthlyPayment.Equal(decimal.RequireFromString("599.55")) {
+		t.Errorf("Expected monthly payment 599.55, got %s", schedule.MonthlyPayment)
+	}
+	if len(schedule.Entries) != 60 {
+		t.Fatalf("Expected 60 scheduled payments, got %d", len(schedule.Entries))
+	}
+
+	last := schedule.Entries[59]
+	if !last.Balloon || !last.Balance.IsZero() {
+		t.Errorf("Expected final entry to be a balloon retiring the balance, got %+v", last)
+	}
+	if !schedule.BalloonAmount.Equal(last.Payment) || schedule.BalloonAmount.LessThan(decimal.NewFromInt(90000)) {
+		t.Errorf("Unexpected balloon amount %s", schedule.BalloonAmount)
+	}
+	if !schedule.MaturityDate.Equal(last.DueDate) {
+		t.Errorf("Expected maturity on the balloon due date")
+	}
+
+	paid := decimal.Zero
+	for _, entry := range schedule.Entries {
+		paid = paid.Add(entry.Principal)
+	}
+	if !paid.Equal(principal) {
+		t.Errorf("Expected schedule to repay principal %s, repaid %s", principal, paid)
+	}
+
+	// Fully amortizing loans have no balloon
+	amortizing, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(1200), decimal.NewFromFloat(0.12), decimal.Zero, WithTerm(12))
+	schedule, _ = l.GetSchedule(ctx, amortizing.ID)
+	if !schedule.BalloonAmount.IsZero() || schedule.Entries[len(schedule.Entries)-1].Balloon {
+		t.Errorf("Expected no balloon on a fully amortizing loan, got %s", schedule.BalloonAmount)
+	}
+
+	if _, err := l.CreateLoan(ctx, "cust123", principal, decimal.NewFromFloat(0.06), decimal.Zero, WithTerm(60), WithAmortization(36)); err == nil {
+		t.Error("Expected error when amortization is shorter than the term")
+	}
+}
+
+func TestPortfolioHistory(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	delinquent, _ := l.CreateLoan(ctx, "cust2", decimal.NewFromInt(3000), decimal.NewFromFloat(0.10), decimal.Zero)
+	delinquent.DaysPastDue = 12
+	saveLoan(t, store, delinquent)
+
+	snapshot, err := l.TakePortfolioSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if !snapshot.OutstandingBalance.Equal(decimal.NewFromInt(4000)) || snapshot.ActiveLoans != 2 || snapshot.Originations != 2 {
+		t.Errorf("Unexpected snapshot totals: %+v", snapshot)
+	}
+	if !snapshot.DelinquencyRate.Equal(decimal.NewFromFloat(0.5)) {
+		t.Errorf("Expected delinquency rate 0.5, got %s", snapshot.DelinquencyRate)
+	}
+
+	// Earlier days in the same month roll up into one monthly point
+	today := snapshot.Date
+	earlier := today.AddDate(0, 0, -1)
+	store.SavePortfolioSnapshot(ctx, &models.PortfolioSnapshot{Date: earlier, OutstandingBalance: decimal.NewFromInt(500), Originations: 1, OriginationVolume: decimal.NewFromInt(500)})
+
+	daily, _ := l.GetPortfolioHistory(ctx, GranularityDaily, earlier, today)
+	if len(daily) != 2 || !daily[0].Date.Equal(earlier) {
+		t.Fatalf("Expected 2 daily points in order, got %d", len(daily))
+	}
+
+	monthly, err := l.GetPortfolioHistory(ctx, GranularityMonthly, earlier, today)
+	if err != nil {
+		t.Fatalf("Failed to get monthly history: %v", err)
+	}
+	last := monthly[len(monthly)-1]
+	if !last.OutstandingBalance.Equal(decimal.NewFromInt(4000)) {
+		t.Errorf("Expected monthly balance as of the latest snapshot, got %s", last.OutstandingBalance)
+	}
+	if earlier.Month() == today.Month() && last.Originations != 3 {
+		t.Errorf("Expected originations summed across the month, got %d", last.Originations)
+	}
+
+	if _, err := l.GetPortfolioHistory(ctx, "hourly", earlier, today); err == nil {
+		t.Error("Expected error for unsupported granularity")
+	}
+}
+
+func TestReportReader(t *testing.T) {
+	ctx := context.Background()
+
+	primary := store.NewMemoryStore()
+	l := NewLedger(primary)
+	loan, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+
+	// A replica that has not caught up yet has no loans
+	replica := store.NewMemoryStore()
+	l.SetReportReader(replica)
+	snapshot, err := l.TakePortfolioSnapshot(ctx)
+	if err != nil {
+		t.Fatalf("Failed to take snapshot: %v", err)
+	}
+	if snapshot.ActiveLoans != 0 {
+		t.Errorf("Expected the snapshot to read from the replica, got %d active loans", snapshot.ActiveLoans)
+	}
+	// Reads the ledger writes with still go to the primary
+	if _, err := l.GetLoan(ctx, loan.ID); err != nil {
+		t.Errorf("Expected the loan from the primary: %v", err)
+	}
+
+	l.SetReportReader(nil)
+	if snapshot, _ = l.TakePortfolioSnapshot(ctx); snapshot.ActiveLoans != 1 {
+		t.Errorf("Expected the snapshot to read from the primary without a replica, got %d active loans", snapshot.ActiveLoans)
+	}
+}
+
+func TestPrepaymentPenalty(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, err := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(1200), decimal.NewFromFloat(0.12), decimal.Zero,
+		WithTerm(12), WithPrepaymentPenalty(decimal.NewFromFloat(0.02), 6))
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	scheduled := MonthlyPayment(loan.Principal, loan.InterestRate, 12)
+
+	// A scheduled payment is not a prepayment
+	l.RecordPayment(ctx, loan.ID, scheduled)
+	if fees := transactionsOfType(store, loan.ID, models.TransactionTypeFee); len(fees) != 0 {
+		t.Fatalf("Expected no penalty on a scheduled payment, got %d fees", len(fees))
+	}
+
+	// Paying 500 above schedule prepays 500 of principal
+	before := reloadLoan(t, l, loan.ID).Balance
+	l.RecordPayment(ctx, loan.ID, scheduled.Add(decimal.NewFromInt(500)))
+	fees := transactionsOfType(store, loan.ID, models.TransactionTypeFee)
+	if len(fees) != 1 || !fees[0].Amount.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("Expected a 10.00 penalty fee, got %v", fees)
+	}
+	expected := before.Add(decimal.NewFromInt(10)).Sub(scheduled).Sub(decimal.NewFromInt(500))
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(expected) {
+		t.Errorf("Expected balance %s including the penalty, got %s", expected, loan.Balance)
+	}
+
+	// Outside the penalty period prepayment is free
+	l.RecordPayment(ctx, loan.ID, loan.Balance, WithPaymentDate(time.Now().AddDate(0, 7, 0)))
+	if fees := transactionsOfType(store, loan.ID, models.TransactionTypeFee); len(fees) != 1 {
+		t.Errorf("Expected no further penalty after the penalty period, got %d fees", len(fees))
+	}
+	if loan = reloadLoan(t, l, loan.ID); loan.Status != "closed" {
+		t.Errorf("Expected loan to be paid off, got %s", loan.Status)
+	}
+
+	// Paying off an open-ended loan early penalizes the whole balance
+	open, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(1000), decimal.NewFromFloat(0.12), decimal.Zero,
+		WithPrepaymentPenalty(decimal.NewFromFloat(0.01), 12))
+	l.RecordPayment(ctx, open.ID, decimal.NewFromInt(1000))
+	fees = transactionsOfType(store, open.ID, models.TransactionTypeFee)
+	if len(fees) != 1 || !fees[0].Amount.Equal(decimal.NewFromInt(10)) {
+		t.Fatalf("Expected a 10.00 payoff penalty, got %v", fees)
+	}
+	if open = reloadLoan(t, l, open.ID); open.Status != "active" || !open.Balance.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Expected the penalty to remain outstanding, got status %s balance %s", open.Status, open.Balance)
+	}
+}
+
+func TestPreviewPayment(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(1000), decimal.NewFromFloat(0.12), decimal.Zero,
+		WithPrepaymentPenalty(decimal.NewFromFloat(0.01), 12))
+	loan.FeesDue = decimal.NewFromInt(20)
+	saveLoan(t, store, loan)
+
+	preview, err := l.PreviewPayment(ctx, loan.ID, decimal.NewFromInt(1000), decimal.Zero)
+	if err != nil {
+		t.Fatalf("PreviewPayment failed: %v", err)
+	}
+	if !preview.FeesPaid.Equal(decimal.NewFromInt(20)) || !preview.PrepaymentPenalty.Equal(decimal.NewFromInt(10)) ||
+		!preview.PrincipalPaid.Equal(decimal.NewFromInt(970)) || !preview.Unapplied.IsZero() {
+		t.Errorf("Expected 20 to fees, a 10 penalty and 970 to principal, got %+v", preview)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.FeesDue.Equal(decimal.NewFromInt(20)) || !loan.Balance.Equal(decimal.NewFromInt(1000)) || len(transactionsOfType(store, loan.ID, models.TransactionTypeFee)) != 0 {
+		t.Fatalf("Expected the preview to change nothing")
+	}
+
+	// Recording the payment has the previewed outcome
+	l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(1000))
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(preview.Balance) || !loan.FeesDue.Equal(preview.FeesDue) || loan.Status != preview.Status {
+		t.Errorf("Expected balance %s, fees due %s and status %s as previewed, got %s, %s and %s",
+			preview.Balance, preview.FeesDue, preview.Status, loan.Balance, loan.FeesDue, loan.Status)
+	}
+
+	if _, err := l.PreviewPayment(ctx, loan.ID, decimal.NewFromInt(100), decimal.NewFromInt(10)); !errors.Is(err, models.ErrNoEscrowAccount) {
+		t.Errorf("Expected ErrNoEscrowAccount for an escrow amount, got %v", err)
+	}
+}
+
+// reloadLoan returns the loan as stored. The store hands out copies, so the loan a test
+// created does not see the ledger's later updates.
+func reloadLoan(t *testing.T, l *Ledger, id uuid.UUID) *models.Loan {
+	t.Helper()
+	loan, err := l.GetLoan(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to get loan %s: %v", id, err)
+	}
+	return loan
+}
+
+// disbursedAt backdates a loan as it is created, since the store keeps a loan's creation time
+// once it is stored.
+func disbursedAt(at time.Time) LoanOption {
+	return func(loan *models.Loan) {
+		loan.CreatedAt = at
+	}
+}
+
+// saveLoan stores changes a test made to a loan directly.
+func saveLoan(t *testing.T, store store.Storage, loan *models.Loan) {
+	t.Helper()
+	if err := store.UpdateLoan(context.Background(), loan); err != nil {
+		t.Fatalf("Failed to update loan %s: %v", loan.ID, err)
+	}
+}
+
+func eventsOfType(store store.Storage, loanID uuid.UUID, eventType models.LoanEventType) []*models.LoanEvent {
+	ctx := context.Background()
+
+	var matched []*models.LoanEvent
+	events, _ := store.GetLoanEventsForLoan(ctx, loanID)
+	for _, event := range events {
+		if event.Type == eventType {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
+
+func transactionsOfType(store store.Storage, loanID uuid.UUID, txType models.TransactionType) []*models.Transaction {
+	ctx := context.Background()
+
+	var matched []*models.Transaction
+	txs, _ := store.GetTransactionsForLoan(ctx, loanID)
+	for _, tx := range txs {
+		if tx.Type == txType {
+			matched = append(matched, tx)
+		}
+	}
+	return matched
+}
+
+func TestApplyMonthlyInterestResumesIntents(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	loan.AccruedInterest = decimal.NewFromFloat(5.0)
+	loan.StatementCycleDay = time.Now().Day()
+	saveLoan(t, mock, loan)
+
+	// Crash after the balance is updated but before the transaction is posted
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"CreateTransaction"}})
+	l.ApplyMonthlyInterest(ctx)
+
+	pending, _ := l.GetInterestIntents(ctx, "", models.IntentPending)
+	if len(pending) != 1 || pending[0].LoanID != loan.ID {
+		t.Fatalf("Expected one pending intent for the loan, got %d", len(pending))
+	}
+
+	faulty.SetConfig(store.FaultConfig{})
+	l.ApplyMonthlyInterest(ctx)
+	l.ApplyMonthlyInterest(ctx)
+
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromFloat(1005.0)) {
+		t.Errorf("Expected interest applied exactly once, balance %s", loan.Balance)
+	}
+	if interest := transactionsOfType(mock, loan.ID, models.TransactionTypeInterest); len(interest) != 1 {
+		t.Errorf("Expected exactly one interest transaction, got %d", len(interest))
+	}
+	intents, _ := l.GetInterestIntents(ctx, statementCycle(time.Now()), "")
+	if len(intents) != 1 || intents[0].Status != models.IntentCompleted {
+		t.Errorf("Expected the intent to be completed, got %+v", intents)
+	}
+}
+
+func TestPaymentLinks(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, _, err := l.CreatePaymentLink(ctx, loan.ID, decimal.NewFromInt(50), decimal.NewFromInt(200), time.Hour); err == nil {
+		t.Fatal("Expected error creating a payment link without a signing secret")
+	}
+
+	l.SetPaymentLinkSecret([]byte("test-secret"))
+	link, token, err := l.CreatePaymentLink(ctx, loan.ID, decimal.NewFromInt(50), decimal.NewFromInt(200), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create payment link: %v", err)
+	}
+	if strings.Contains(token, loan.ID.String()) {
+		t.Error("Expected token not to expose the loan ID")
+	}
+
+	// Tampered tokens and out-of-range amounts are rejected
+	if _, err := l.RedeemPaymentLink(ctx, token+"x", decimal.NewFromInt(100)); err == nil || err.Error() != "invalid payment link" {
+		t.Errorf("Expected invalid payment link for tampered token, got %v", err)
+	}
+	if _, err := l.RedeemPaymentLink(ctx, token, decimal.NewFromInt(500)); err == nil {
+		t.Error("Expected error for amount outside the link's range")
+	}
+
+	tx, err := l.RedeemPaymentLink(ctx, token, decimal.NewFromInt(100))
+	if err != nil {
+		t.Fatalf("Failed to redeem payment link: %v", err)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected balance 900 after link payment, got %s", loan.Balance)
+	}
+	if link, _ = store.GetPaymentLink(ctx, link.ID); link.Status != models.PaymentLinkRedeemed || link.TransactionID == nil || *link.TransactionID != tx.ID {
+		t.Errorf("Expected link to be redeemed by transaction %s, got %+v", tx.ID, link)
+	}
+	if _, err := l.RedeemPaymentLink(ctx, token, decimal.NewFromInt(100)); err == nil {
+		t.Error("Expected error redeeming a link twice")
+	}
+
+	// Links stop working once they expire, and rotating the secret invalidates them
+	expired, _, _ := l.CreatePaymentLink(ctx, loan.ID, decimal.NewFromInt(50), decimal.NewFromInt(200), time.Hour)
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Truncate(time.Second)
+	store.UpdatePaymentLink(ctx, expired)
+	if _, err := l.RedeemPaymentLink(ctx, l.paymentLinkToken(expired), decimal.NewFromInt(100)); err == nil || err.Error() != "payment link has expired" {
+		t.Errorf("Expected expired payment link, got %v", err)
+	}
+	_, rotatedToken, _ := l.CreatePaymentLink(ctx, loan.ID, decimal.NewFromInt(50), decimal.NewFromInt(200), time.Hour)
+	l.SetPaymentLinkSecret([]byte("rotated"))
+	if _, err := l.ValidatePaymentLink(ctx, rotatedToken); err == nil {
+		t.Error("Expected error after rotating the signing secret")
+	}
+}
+
+func TestRedeemPaymentLinkClaimsLink(t *testing.T) {
+	ctx := context.Background()
+
+	mock := store.NewMemoryStore()
+	faulty := store.NewFaultyStore(mock, store.FaultConfig{})
+	l := NewLedger(faulty)
+	l.SetPaymentLinkSecret([]byte("test-secret"))
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	link, token, _ := l.CreatePaymentLink(ctx, loan.ID, decimal.NewFromInt(50), decimal.NewFromInt(200), time.Hour)
+
+	// A payment does not post unless the link is claimed with it
+	faulty.SetConfig(store.FaultConfig{ErrorRate: 1, Methods: []string{"RedeemPaymentLink"}})
+	if _, err := l.RedeemPaymentLink(ctx, token, decimal.NewFromInt(100)); err == nil {
+		t.Fatal("Expected the redemption to fail")
+	}
+	faulty.SetConfig(store.FaultConfig{})
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected the loan unchanged by the failed redemption, balance %s", loan.Balance)
+	}
+	if link, _ = mock.GetPaymentLink(ctx, link.ID); link.Status != models.PaymentLinkActive {
+		t.Errorf("Expected the link to stay active, got %s", link.Status)
+	}
+
+	// Of several deliveries racing to redeem the link, one posts a payment
+	const deliveries = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, deliveries)
+	for range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := l.RedeemPaymentLink(ctx, token, decimal.NewFromInt(100))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	redeemed := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			redeemed++
+		case !errors.Is(err, models.ErrPaymentLinkRedeemed):
+			t.Errorf("Expected ErrPaymentLinkRedeemed for a losing delivery, got %v", err)
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("Expected exactly one delivery to redeem the link, %d did", redeemed)
+	}
+	if payments := transactionsOfType(mock, loan.ID, models.TransactionTypePayment); len(payments) != 1 {
+		t.Errorf("Expected exactly one payment, got %d", len(payments))
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected balance 900, got %s", loan.Balance)
+	}
+}
+
+func TestPayoffQuote(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	// 36.5% APR on 1000 accrues exactly 1.00 a day
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(1000), decimal.NewFromFloat(0.365), decimal.Zero,
+		WithPrepaymentPenalty(decimal.NewFromFloat(0.01), 12))
+
+	payoffDate := time.Now().UTC().AddDate(0, 0, 9)
+	quote, err := l.GetPayoffQuote(ctx, loan.ID, payoffDate)
+	if err != nil {
+		t.Fatalf("Failed to get payoff quote: %v", err)
+	}
+	if !quote.PerDiem.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Expected per diem 1.00, got %s", quote.PerDiem)
+	}
+	if !quote.AccruedInterest.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Expected 10 days of interest through the payoff date, got %s", quote.AccruedInterest)
+	}
+	if !quote.PrepaymentPenalty.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Expected prepayment penalty 10.00, got %s", quote.PrepaymentPenalty)
+	}
+	if !quote.Total.Equal(decimal.NewFromInt(1020)) {
+		t.Errorf("Expected total 1020, got %s", quote.Total)
+	}
+	if quote.BalloonAmount != nil {
+		t.Errorf("Expected no balloon on an open-ended loan")
+	}
+
+	if _, err := l.GetPayoffQuote(ctx, loan.ID, time.Now().AddDate(0, 0, -2)); err == nil {
+		t.Error("Expected error for a payoff date in the past")
+	}
+
+	balloon, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(100000), decimal.NewFromFloat(0.06), decimal.Zero, WithTerm(60), WithAmortization(360))
+	quote, _ = l.GetPayoffQuote(ctx, balloon.ID, time.Now())
+	schedule, _ := l.GetSchedule(ctx, balloon.ID)
+	if quote.BalloonAmount == nil || !quote.BalloonAmount.Equal(schedule.BalloonAmount) {
+		t.Errorf("Expected payoff quote to show the balloon amount %s", schedule.BalloonAmount)
+	}
+}
+
+func TestPayingPayoffQuoteClosesLoan(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	// Interest accrued over earlier days, and today's yet to accrue, are both part of the payoff
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromInt(10000), decimal.NewFromFloat(0.10), decimal.Zero)
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	loan.AccruedInterest = decimal.NewFromFloat(13.70)
+	loan.LastInterestCalculationDate = &yesterday
+	saveLoan(t, store, loan)
+
+	quote, err := l.GetPayoffQuote(ctx, loan.ID, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get payoff quote: %v", err)
+	}
+	if !quote.Total.Equal(decimal.NewFromFloat(10016.44)) {
+		t.Errorf("Expected total 10016.44, got %s", quote.Total)
+	}
+	if _, err := l.RecordPayment(ctx, loan.ID, quote.Total); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+
+	loan = reloadLoan(t, l, loan.ID)
+	if !loan.Balance.IsZero() || !loan.AccruedInterest.IsZero() || !loan.InterestDue.IsZero() || loan.Status != models.LoanStatusClosed {
+		t.Errorf("Expected the loan closed with nothing owed, got %s balance, %s accrued, %s due and status %s", loan.Balance, loan.AccruedInterest, loan.InterestDue, loan.Status)
+	}
+	interest := transactionsOfType(store, loan.ID, models.TransactionTypeInterest)
+	if len(interest) != 1 || !interest[0].Amount.Equal(decimal.NewFromFloat(16.44)) {
+		t.Errorf("Expected one interest transaction of 16.44, got %v", interest)
+	}
+}
+
+func TestBureauRecords(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	disbursed := time.Now().AddDate(0, -4, 0)
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithTerm(12), disbursedAt(disbursed))
+	l.CreateLoan(ctx, "cust2", decimal.NewFromInt(500), decimal.NewFromFloat(0.10), decimal.Zero, disbursedAt(disbursed))
+
+	period := time.Now().UTC().AddDate(0, -1, 0).Format("2006-01")
+	previous := time.Now().UTC().AddDate(0, -2, 0).Format("2006-01")
+	store.SaveBureauRecord(ctx, &models.BureauRecord{LoanID: loan.ID, Period: previous, AccountStatus: "71"})
+
+	records, err := l.BuildBureauRecords(ctx, period, "")
+	if err != nil {
+		t.Fatalf("Failed to build bureau records: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	record := records[0]
+	if record.LoanID != loan.ID {
+		record = records[1]
+	}
+	// Never paid since disbursement four months ago, so well past due
+	if record.DaysPastDue < 30 || record.AccountStatus == "11" {
+		t.Errorf("Expected a delinquent status, got %s with %d days past due", record.AccountStatus, record.DaysPastDue)
+	}
+	if record.PaymentHistory[:2] != "1B" || len(record.PaymentHistory) != 24 {
+		t.Errorf("Expected history to start with the prior 30-day month, got %q", record.PaymentHistory)
+	}
+
+	// Rebuilding the period replaces rather than duplicates its records
+	l.BuildBureauRecords(ctx, period, "")
+	stored, _ := store.GetBureauRecordsForLoan(ctx, loan.ID)
+	if len(stored) != 2 {
+		t.Errorf("Expected 2 stored periods, got %d", len(stored))
+	}
+
+	if byProduct, _ := l.BuildBureauRecords(ctx, period, "auto"); len(byProduct) != 0 {
+		t.Errorf("Expected no records for a product without loans, got %d", len(byProduct))
+	}
+	if _, err := l.BuildBureauRecords(ctx, "2024-13", ""); err == nil {
+		t.Error("Expected error for an invalid period")
+	}
+	if _, err := l.BuildBureauRecords(ctx, time.Now().UTC().AddDate(0, 2, 0).Format("2006-01"), ""); err == nil {
+		t.Error("Expected error for a future period")
+	}
+}
+
+func TestOddDaysInterest(t *testing.T) {
+	ctx := context.Background()
+
+	charge := &models.Product{Code: "charge", OddDaysInterest: models.OddDaysCharge}
+	waive := &models.Product{Code: "waive", OddDaysInterest: models.OddDaysWaive}
+
+	// 3650 at 10% accrues exactly 1.00 a day; disbursed June 10th and billed on the 1st
+	disbursed := time.Date(2024, 6, 10, 15, 0, 0, 0, time.UTC)
+	loan := &models.Loan{CreatedAt: disbursed, StatementCycleDay: 1, Balance: decimal.NewFromInt(3650), InterestRate: decimal.NewFromFloat(0.10)}
+	applyOddDays(loan, charge)
+	if loan.OddDays != 21 || !loan.OddDaysInterest.Equal(decimal.NewFromInt(21)) {
+		t.Errorf("Expected 21 odd days charged 21.00, got %d days and %s", loan.OddDays, loan.OddDaysInterest)
+	}
+	if !inOddDaysStub(loan, time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)) || inOddDaysStub(loan, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected the stub to end on the first statement date")
+	}
+
+	waived := &models.Loan{CreatedAt: disbursed, StatementCycleDay: 1, Balance: decimal.NewFromInt(3650), InterestRate: decimal.NewFromFloat(0.10)}
+	applyOddDays(waived, waive)
+	if waived.OddDays != 21 || !waived.OddDaysInterest.IsZero() {
+		t.Errorf("Expected 21 waived odd days, got %d days and %s", waived.OddDays, waived.OddDaysInterest)
+	}
+
+	fullCycle := &models.Loan{CreatedAt: disbursed, StatementCycleDay: 10, Balance: decimal.NewFromInt(3650), InterestRate: decimal.NewFromFloat(0.10)}
+	applyOddDays(fullCycle, charge)
+	if fullCycle.OddDaysPolicy != "" || fullCycle.OddDays != 0 {
+		t.Errorf("Expected no stub for a loan disbursed on its cycle day, got %d odd days", fullCycle.OddDays)
+	}
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+	l.CreateProduct(ctx, waive)
+	if err := l.CreateProduct(ctx, &models.Product{Code: "bad", OddDaysInterest: "defer"}); err == nil {
+		t.Error("Expected error for an unknown odd-days policy")
+	}
+
+	// No interest accrues during a waived stub
+	inStub, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("waive"))
+	inStub.OddDaysPolicy = models.OddDaysWaive
+	inStub.OddDays = 5
+	saveLoan(t, store, inStub)
+	l.CalculateDailyInterest(ctx)
+	if inStub = reloadLoan(t, l, inStub.ID); !inStub.AccruedInterest.IsZero() {
+		t.Errorf("Expected no accrual during a waived stub, got %s", inStub.AccruedInterest)
+	}
+
+	// The first accrual after a charged stub bills the disclosed odd-days interest
+	afterStub, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero, disbursedAt(time.Now().AddDate(0, 0, -30)))
+	afterStub.OddDaysPolicy = models.OddDaysCharge
+	afterStub.OddDays = 21
+	afterStub.OddDaysInterest = decimal.NewFromInt(21)
+	saveLoan(t, store, afterStub)
+	l.CalculateDailyInterest(ctx)
+	if afterStub = reloadLoan(t, l, afterStub.ID); !afterStub.AccruedInterest.Round(2).Equal(decimal.NewFromInt(22)) {
+		t.Errorf("Expected 21.00 odd-days plus 1.00 daily interest, got %s", afterStub.AccruedInterest)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	statement, err := l.issueStatement(ctx, afterStub, statementCycle(today), today)
+	if err != nil {
+		t.Fatalf("Failed to issue statement: %v", err)
+	}
+	if statement.OddDays != 21 || !statement.OddDaysInterest.Equal(decimal.NewFromInt(21)) || statement.OddDaysPolicy != models.OddDaysCharge {
+		t.Errorf("Expected the odd-days interest disclosed on the first statement, got %+v", statement)
+	}
+	again, _ := l.issueStatement(ctx, afterStub, statementCycle(today), today)
+	if again.ID != statement.ID {
+		t.Error("Expected a cycle's statement to be issued only once")
+	}
+}
+
+func TestMinimumPayment(t *testing.T) {
+	ctx := context.Background()
+
+	policy := models.MinimumPaymentPolicy{Floor: decimal.NewFromInt(25), Percent: decimal.NewFromFloat(0.02)}
+	cases := []struct {
+		balance, interest, want string
+	}{
+		{"5050.00", "50.00", "150.00"}, // 2% of 5000 plus the interest
+		{"500.00", "5.00", "25.00"},    // Floor
+		{"10.00", "0.10", "10.00"},     // Never more than the balance
+		{"0", "0", "0"},
+	}
+	for _, c := range cases {
+		statement := &models.Statement{Balance: decimal.RequireFromString(c.balance), InterestCharged: decimal.RequireFromString(c.interest)}
+		if got := MinimumDue(policy, statement); !got.Equal(decimal.RequireFromString(c.want)) {
+			t.Errorf("MinimumDue(balance %s, interest %s) = %s, want %s", c.balance, c.interest, got, c.want)
+		}
+	}
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+	if err := l.CreateProduct(ctx, &models.Product{Code: "bad", MinimumPaymentPercent: decimal.NewFromInt(2)}); err == nil {
+		t.Error("Expected error for a minimum payment percent above 1")
+	}
+	l.CreateProduct(ctx, &models.Product{Code: "card", MinimumPaymentFloor: decimal.NewFromInt(40)})
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("card"))
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	statement, err := l.issueStatement(ctx, loan, statementCycle(today), today)
+	if err != nil {
+		t.Fatalf("Failed to issue statement: %v", err)
+	}
+	if !statement.MinimumDue.Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Expected the product's 40.00 floor, got %s", statement.MinimumDue)
+	}
+
+	// Delinquency ages from the due date of the unpaid statement; paying the minimum cures it
+	older := &models.Statement{StatementDate: today.AddDate(0, -2, 0), DueDate: today.AddDate(0, -2, paymentGracePeriodDays), MinimumDue: decimal.NewFromInt(40)}
+	newer := &models.Statement{StatementDate: today.AddDate(0, -1, 0), DueDate: today.AddDate(0, -1, paymentGracePeriodDays), MinimumDue: decimal.NewFromInt(40)}
+	statements := []*models.Statement{older, newer}
+	payment := &models.Transaction{Type: models.TransactionTypePayment, Amount: decimal.NewFromInt(10), Timestamp: older.StatementDate.Add(time.Hour)}
+	transactions := []*models.Transaction{payment}
+	if dpd := statementDaysPastDue(statements, transactions, today); dpd != int(today.Sub(older.DueDate).Hours()/24) {
+		t.Errorf("Expected aging from the older statement's due date, got %d", dpd)
+	}
+	payment.Amount = decimal.NewFromInt(40)
+	if dpd := statementDaysPastDue(statements, transactions, today); dpd != int(today.Sub(newer.DueDate).Hours()/24) {
+		t.Errorf("Expected aging from the newer statement's due date, got %d", dpd)
+	}
+}
+
+func TestRenderStatementPDF(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(150.5), WithReference("check (1042)"))
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	loan, _ = l.GetLoan(ctx, loan.ID)
+	statement, err := l.issueStatement(ctx, loan, statementCycle(today), today)
+	if err != nil {
+		t.Fatalf("Failed to issue statement: %v", err)
+	}
+
+	view, err := l.statementView(ctx, loan.ID, statement.ID)
+	if err != nil {
+		t.Fatalf("Failed to gather statement: %v", err)
+	}
+	var text bytes.Buffer
+	if err := statementTemplate.Execute(&text, view); err != nil {
+		t.Fatalf("Failed to render statement: %v", err)
+	}
+	for _, want := range []string{
+		"Customer     cust1",
+		"  Payments                                       150.50",
+		"  New balance                                    849.50",
+		"  Interest rate (APR)                               10%",
+		"  Payment                           150.50  check (1042)",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Expected %q in statement:\n%s", want, text.String())
+		}
+	}
+	for _, line := range strings.Split(text.String(), "\n") {
+		if len(line) > pdf.Columns {
+			t.Errorf("Line wider than the page: %q", line)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := l.RenderStatementPDF(ctx, &out, loan.ID, statement.ID); err != nil {
+		t.Fatalf("Failed to render PDF: %v", err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), []byte("%PDF-")) {
+		t.Errorf("Expected a PDF, got %q", out.Bytes()[:min(out.Len(), 16)])
+	}
+	if err := l.RenderStatementPDF(ctx, &out, loan.ID, uuid.New()); !errors.Is(err, models.ErrStatementNotFound) {
+		t.Errorf("Expected ErrStatementNotFound, got %v", err)
+	}
+}
+
+func TestAutopay(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	method := &models.PaymentMethod{CustomerKey: "cust1", Type: models.PaymentMethodBankAccount, Token: "tok_ach_1"}
+	l.AddPaymentMethod(ctx, method)
+
+	enrollment := &models.AutopayEnrollment{LoanID: loan.ID, AmountType: models.AutopayMinimumDue, DayOfMonth: 5, PaymentMethodID: method.ID}
+	if err := l.EnrollAutopay(ctx, enrollment); err == nil {
+		t.Error("Expected an unverified payment method to be rejected")
+	}
+	l.VerifyPaymentMethod(ctx, method.ID)
+	if err := l.EnrollAutopay(ctx, &models.AutopayEnrollment{LoanID: loan.ID, AmountType: models.AutopayMinimumDue, DayOfMonth: 31, PaymentMethodID: method.ID}); err == nil {
+		t.Error("Expected error for a day of month after the 28th")
+	}
+	if err := l.EnrollAutopay(ctx, enrollment); err != nil {
+		t.Fatalf("Failed to enroll in autopay: %v", err)
+	}
+
+	cycle := statementCycle(time.Now())
+
+	// Nothing is due before the first statement
+	if tx, err := l.postAutopay(ctx, enrollment, cycle); err != nil || tx != nil {
+		t.Fatalf("Expected no autopay before a statement, got %v, %v", tx, err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	store.CreateStatement(ctx, &models.Statement{LoanID: loan.ID, Cycle: cycle, StatementDate: today, Balance: loan.Balance, MinimumDue: decimal.NewFromInt(40)})
+
+	tx, err := l.postAutopay(ctx, enrollment, cycle)
+	if err != nil {
+		t.Fatalf("Failed to post autopay: %v", err)
+	}
+	if tx == nil || !tx.Amount.Equal(decimal.NewFromInt(40)) || tx.Source != models.TransactionSourceAutopay || *tx.PaymentMethodID != method.ID {
+		t.Fatalf("Expected a 40.00 autopay payment from the enrolled method, got %+v", tx)
+	}
+
+	// The month's payment is only made once
+	if tx, _ := l.postAutopay(ctx, enrollment, cycle); tx != nil {
+		t.Error("Expected autopay to pay at most once per month")
+	}
+
+	if err := l.CancelAutopay(ctx, loan.ID); err != nil {
+		t.Fatalf("Failed to cancel autopay: %v", err)
+	}
+	if _, err := l.GetAutopayEnrollment(ctx, loan.ID); err == nil {
+		t.Error("Expected the enrollment to be removed")
+	}
+}
+
+func TestRecalculateLoan(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.CalculateDailyInterest(ctx)
+	l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(100))
+	loan = reloadLoan(t, l, loan.ID)
+
+	// A consistent loan recalculates to itself
+	result, err := l.RecalculateLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("Failed to recalculate loan: %v", err)
+	}
+	if len(result.Changes) != 0 {
+		t.Fatalf("Expected no changes for a consistent loan, got %+v", result.Changes)
+	}
+
+	// Corrupt the stored balances
+	expectedAccrued := loan.AccruedInterest
+	loan.Balance = decimal.NewFromInt(5000)
+	loan.AccruedInterest = decimal.Zero
+	saveLoan(t, store, loan)
+
+	result, _ = l.RecalculateLoan(ctx, loan.ID, false)
+	if len(result.Changes) != 2 || result.Changes[0].Field != "balance" || result.Changes[0].After != "900" {
+		t.Fatalf("Expected balance and accrued interest corrections, got %+v", result.Changes)
+	}
+	if !reloadLoan(t, l, loan.ID).Balance.Equal(decimal.NewFromInt(5000)) {
+		t.Error("Expected a dry run to leave the loan unchanged")
+	}
+
+	result, err = l.RecalculateLoan(ctx, loan.ID, true)
+	if err != nil || !result.Committed {
+		t.Fatalf("Expected the correction to be committed, got %v", err)
+	}
+	fixed, _ := store.GetLoan(ctx, loan.ID)
+	if !fixed.Balance.Equal(decimal.NewFromInt(900)) || !fixed.AccruedInterest.Equal(expectedAccrued) {
+		t.Errorf("Expected balance 900 and accrued %s, got %s and %s", expectedAccrued, fixed.Balance, fixed.AccruedInterest)
+	}
+	events, _ := store.GetLoanEventsForLoan(ctx, loan.ID)
+	if len(events) == 0 || events[len(events)-1].Type != models.LoanEventCorrection {
+		t.Error("Expected the correction on the loan's timeline")
+	}
+}
+
+func TestEscrow(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	plain, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.RecordPaymentWithEscrow(ctx, plain.ID, decimal.NewFromInt(100), decimal.NewFromInt(20)); err == nil {
+		t.Error("Expected error for an escrow deposit on a loan without escrow")
+	}
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithEscrow())
+	payment, err := l.RecordPaymentWithEscrow(ctx, loan.ID, decimal.NewFromInt(150), decimal.NewFromInt(50))
+	if err != nil {
+		t.Fatalf("Failed to record payment with escrow: %v", err)
+	}
+	if !payment.Amount.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected 100.00 applied to the loan, got %s", payment.Amount)
+	}
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if !loan.Balance.Equal(decimal.NewFromInt(900)) || !loan.EscrowBalance.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Expected balance 900 and escrow 50, got %s and %s", loan.Balance, loan.EscrowBalance)
+	}
+
+	if _, err := l.DisburseEscrow(ctx, loan.ID, decimal.NewFromInt(80), "County Tax Office"); err == nil {
+		t.Error("Expected error for a disbursement above the escrow balance")
+	}
+	if _, err := l.DisburseEscrow(ctx, loan.ID, decimal.NewFromInt(30), "County Tax Office"); err != nil {
+		t.Fatalf("Failed to disburse escrow: %v", err)
+	}
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if !loan.EscrowBalance.Equal(decimal.NewFromInt(20)) || !loan.Balance.Equal(decimal.NewFromInt(900)) {
+		t.Errorf("Expected escrow 20 with the balance untouched, got %s and %s", loan.EscrowBalance, loan.Balance)
+	}
+	if len(transactionsOfType(store, loan.ID, models.TransactionTypeEscrowCredit)) != 1 || len(transactionsOfType(store, loan.ID, models.TransactionTypeEscrowDebit)) != 1 {
+		t.Error("Expected one escrow credit and one escrow debit transaction")
+	}
+}
+
+func TestAssessFee(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.AssessFee(ctx, loan.ID, models.TransactionTypeInterest, decimal.NewFromInt(10), false); err == nil {
+		t.Error("Expected error for a non-fee transaction type")
+	}
+
+	fee, err := l.AssessFee(ctx, loan.ID, models.TransactionTypeOriginationFee, decimal.NewFromInt(50), true)
+	if err != nil {
+		t.Fatalf("Failed to assess origination fee: %v", err)
+	}
+	if !fee.Capitalized {
+		t.Error("Expected the origination fee to be marked capitalized")
+	}
+	if _, err := l.AssessFee(ctx, loan.ID, models.TransactionTypeServicingFee, decimal.NewFromInt(20), false); err != nil {
+		t.Fatalf("Failed to assess servicing fee: %v", err)
+	}
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if !loan.Balance.Equal(decimal.NewFromInt(1050)) || !loan.FeesDue.Equal(decimal.NewFromInt(20)) {
+		t.Errorf("Expected balance 1050 and fees due 20, got %s and %s", loan.Balance, loan.FeesDue)
+	}
+
+	// The billed fee is paid before the balance
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(100)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if !loan.FeesDue.IsZero() || !loan.Balance.Equal(decimal.NewFromInt(970)) {
+		t.Errorf("Expected fees due 0 and balance 970, got %s and %s", loan.FeesDue, loan.Balance)
+	}
+	if len(transactionsOfType(store, loan.ID, models.TransactionTypeOriginationFee)) != 1 || len(transactionsOfType(store, loan.ID, models.TransactionTypeServicingFee)) != 1 {
+		t.Error("Expected one origination fee and one servicing fee transaction")
+	}
+
+	result, err := l.RecalculateLoan(ctx, loan.ID, false)
+	if err != nil {
+		t.Fatalf("Failed to recalculate loan: %v", err)
+	}
+	if len(result.Changes) != 0 {
+		t.Errorf("Expected fees to replay without changes, got %+v", result.Changes)
+	}
+}
+
+func TestLineOfCreditDraws(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if _, err := l.CreateLoan(ctx, "cust1", decimal.Zero, decimal.NewFromFloat(0.10), decimal.Zero); err == nil {
+		t.Error("Expected error for an installment loan with no principal")
+	}
+
+	loan, err := l.CreateLoan(ctx, "cust1", decimal.Zero, decimal.NewFromFloat(0.365), decimal.Zero, WithLineOfCredit(decimal.NewFromInt(1000)))
+	if err != nil {
+		t.Fatalf("Failed to open line of credit: %v", err)
+	}
+	if len(transactionsOfType(store, loan.ID, models.TransactionTypeDisbursement)) != 0 {
+		t.Error("Expected no disbursement for an undrawn line of credit")
+	}
+
+	// Nothing drawn, nothing accrues
+	l.CalculateDailyInterest(ctx)
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if !loan.AccruedInterest.IsZero() {
+		t.Errorf("Expected no interest on an undrawn line, got %s", loan.AccruedInterest)
+	}
+
+	if _, err := l.Draw(ctx, loan.ID, decimal.NewFromInt(500)); err != nil {
+		t.Fatalf("Failed to draw: %v", err)
+	}
+	if _, err := l.Draw(ctx, loan.ID, decimal.NewFromInt(250)); err != nil {
+		t.Fatalf("Failed to draw: %v", err)
+	}
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if !loan.Balance.Equal(decimal.NewFromInt(750)) {
+		t.Errorf("Expected balance 750, got %s", loan.Balance)
+	}
+	if len(transactionsOfType(store, loan.ID, models.TransactionTypeDisbursement)) != 2 {
+		t.Error("Expected a disbursement transaction per draw")
+	}
+
+	// Paying the line down to zero leaves it open for further draws
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(750)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	loan, _ = store.GetLoan(ctx, loan.ID)
+	if loan.Status != "active" || !loan.Balance.IsZero() {
+		t.Errorf("Expected an active line with zero balance, got %s with %s", loan.Status, loan.Balance)
+	}
+
+	installment, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.Draw(ctx, installment.ID, decimal.NewFromInt(100)); err == nil {
+		t.Error("Expected error drawing on an installment loan")
+	}
+}
+
+func TestCreditLimit(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if _, err := l.CreateLoan(ctx, "cust1", decimal.Zero, decimal.NewFromFloat(0.10), decimal.Zero, WithLineOfCredit(decimal.Zero)); err == nil {
+		t.Error("Expected error for a line of credit without a credit limit")
+	}
+	if _, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(600), decimal.NewFromFloat(0.10), decimal.Zero, WithLineOfCredit(decimal.NewFromInt(500))); err == nil {
+		t.Error("Expected error for an initial draw above the credit limit")
+	}
+
+	loan, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(200), decimal.NewFromFloat(0.10), decimal.Zero, WithLineOfCredit(decimal.NewFromInt(500)))
+	if err != nil {
+		t.Fatalf("Failed to open line of credit: %v", err)
+	}
+	if loan.AvailableCredit == nil || !loan.AvailableCredit.Equal(decimal.NewFromInt(300)) {
+		t.Errorf("Expected available credit 300, got %v", loan.AvailableCredit)
+	}
+
+	if _, err := l.Draw(ctx, loan.ID, decimal.NewFromInt(301)); err == nil || err.Error() != "draw exceeds available credit" {
+		t.Errorf("Expected draw above available credit to be rejected, got %v", err)
+	}
+	if _, err := l.Draw(ctx, loan.ID, decimal.NewFromInt(300)); err != nil {
+		t.Fatalf("Failed to draw up to the limit: %v", err)
+	}
+	loan, _ = l.GetLoan(ctx, loan.ID)
+	if loan.AvailableCredit == nil || !loan.AvailableCredit.IsZero() {
+		t.Errorf("Expected no available credit at the limit, got %v", loan.AvailableCredit)
+	}
+
+	installment, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if installment.AvailableCredit != nil {
+		t.Error("Expected no available credit on an installment loan")
+	}
+}
+
+func TestCollateralLoanToValue(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(8000), decimal.NewFromFloat(0.10), decimal.Zero)
+	fetched, _ := l.GetLoan(ctx, loan.ID)
+	if fetched.LoanToValue != nil {
+		t.Errorf("Expected no LTV for an unsecured loan, got %s", fetched.LoanToValue)
+	}
+
+	if _, err := l.AddCollateral(ctx, loan.ID, "boat", "", decimal.NewFromInt(1000), time.Time{}); err == nil {
+		t.Error("Expected error for an unknown collateral type")
+	}
+	vehicle, err := l.AddCollateral(ctx, loan.ID, models.CollateralVehicle, "2019 Honda Civic", decimal.NewFromInt(10000), time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to add collateral: %v", err)
+	}
+	fetched, _ = l.GetLoan(ctx, loan.ID)
+	if fetched.LoanToValue == nil || !fetched.LoanToValue.Equal(decimal.NewFromFloat(0.8)) {
+		t.Errorf("Expected LTV 0.8, got %v", fetched.LoanToValue)
+	}
+
+	// A reappraisal changes the LTV
+	if _, err := l.UpdateCollateral(ctx, loan.ID, vehicle.ID, models.CollateralVehicle, "2019 Honda Civic", decimal.NewFromInt(16000), time.Time{}); err != nil {
+		t.Fatalf("Failed to update collateral: %v", err)
+	}
+	fetched, _ = l.GetLoan(ctx, loan.ID)
+	if fetched.LoanToValue == nil || !fetched.LoanToValue.Equal(decimal.NewFromFloat(0.5)) {
+		t.Errorf("Expected LTV 0.5, got %v", fetched.LoanToValue)
+	}
+
+	other, _ := l.CreateLoan(ctx, "cust2", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.GetCollateral(ctx, other.ID, vehicle.ID); err == nil {
+		t.Error("Expected collateral to be scoped to its loan")
+	}
+	if err := l.DeleteCollateral(ctx, loan.ID, vehicle.ID); err != nil {
+		t.Fatalf("Failed to delete collateral: %v", err)
+	}
+	fetched, _ = l.GetLoan(ctx, loan.ID)
+	if fetched.LoanToValue != nil {
+		t.Error("Expected no LTV once the collateral is released")
+	}
+}
+
+func TestLoanStatusTransitions(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	update := *loan
+	update.Status = "frozen"
+	if err := l.UpdateLoan(ctx, &update); err == nil {
+		t.Error("Expected error for an unknown status")
+	}
+	update.Status = models.LoanStatusPending
+	if err := l.UpdateLoan(ctx, &update); err == nil {
+		t.Error("Expected error moving an active loan back to pending")
+	}
+
+	// Aging past 30 days marks the loan delinquent; a payment makes it active again. The cycle
+	// is pinned and the last payment made the day before the statement two months back, so the
+	// payment fell due 34 to 67 days ago whatever today's date
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	statement := time.Date(today.Year(), today.Month()-2, 1, 0, 0, 0, 0, time.UTC)
+	past := statement.AddDate(0, 0, -1)
+	loan.StatementCycleDay = 1
+	loan.LastPaymentDate = &past
+	saveLoan(t, store, loan)
+	l.UpdateDelinquency(ctx)
+	expectedDPD := int(today.Sub(statement.AddDate(0, 0, paymentGracePeriodDays)).Hours() / 24)
+	if loan = reloadLoan(t, l, loan.ID); loan.Status != models.LoanStatusDelinquent || loan.DaysPastDue != expectedDPD {
+		t.Fatalf("Expected delinquent status %d days past due after aging, got %s (%d days past due)", expectedDPD, loan.Status, loan.DaysPastDue)
+	}
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(100)); err != nil {
+		t.Fatalf("Failed to record payment on delinquent loan: %v", err)
+	}
+	if loan = reloadLoan(t, l, loan.ID); loan.Status != models.LoanStatusActive {
+		t.Errorf("Expected active status after payment, got %s", loan.Status)
+	}
+
+	update = *loan
+	update.Status = models.LoanStatusClosed
+	if err := l.UpdateLoan(ctx, &update); err != nil {
+		t.Fatalf("Failed to close loan: %v", err)
+	}
+	reopen := update
+	reopen.Status = models.LoanStatusActive
+	if err := l.UpdateLoan(ctx, &reopen); err == nil {
+		t.Error("Expected error reopening a closed loan")
+	}
+}
+
+func TestForbearance(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	if _, err := l.PlaceInForbearance(ctx, loan.ID, today, today.AddDate(0, 0, -1), decimal.Zero, "hardship", "agent1"); err == nil {
+		t.Error("Expected error for a window ending before it starts")
+	}
+	if _, err := l.PlaceInForbearance(ctx, loan.ID, today.AddDate(0, 0, -10), today.AddDate(0, 0, 20), decimal.Zero, "hardship", "agent1"); err != nil {
+		t.Fatalf("Failed to place loan in forbearance: %v", err)
+	}
+	if _, err := l.PlaceInForbearance(ctx, loan.ID, today.AddDate(0, 0, 20), today.AddDate(0, 0, 30), decimal.Zero, "hardship", "agent1"); err == nil {
+		t.Error("Expected error for an overlapping window")
+	}
+
+	// No interest accrues at a zero forbearance rate
+	l.CalculateDailyInterest(ctx)
+	if loan = reloadLoan(t, l, loan.ID); !loan.AccruedInterest.IsZero() {
+		t.Errorf("Expected no accrual during forbearance, got %s", loan.AccruedInterest)
+	}
+
+	// Days in forbearance do not age the loan
+	past := today.AddDate(0, 0, -100)
+	loan.CreatedAt = past
+	loan.LastPaymentDate = &past
+	dpd, err := l.loanDaysPastDue(ctx, loan, today)
+	if err != nil {
+		t.Fatalf("Failed to age loan: %v", err)
+	}
+	if want := daysPastDue(loan, today) - 11; dpd != want {
+		t.Errorf("Expected %d days past due excluding forbearance, got %d", want, dpd)
+	}
+
+	events, _ := store.GetLoanEventsForLoan(ctx, loan.ID)
+	found := false
+	for _, event := range events {
+		if event.Type == models.LoanEventForbearance && event.Author == "agent1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected the forbearance to be recorded on the timeline")
+	}
+}
+
+func TestAccrualGracePeriod(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if err := l.CreateProduct(ctx, &models.Product{Code: "GRACE", Name: "Grace", AccrualGraceDays: -1}); err == nil {
+		t.Error("Expected error for a negative grace period")
+	}
+	if err := l.CreateProduct(ctx, &models.Product{Code: "GRACE", Name: "Grace", AccrualGraceDays: 30}); err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	loan, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("GRACE"))
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	disbursed := loan.CreatedAt.UTC().Truncate(24 * time.Hour)
+	if loan.AccrualStartDate == nil || !loan.AccrualStartDate.Equal(disbursed.AddDate(0, 0, 30)) {
+		t.Fatalf("Expected accrual to start 30 days after disbursement, got %v", loan.AccrualStartDate)
+	}
+
+	l.CalculateDailyInterest(ctx)
+	if loan = reloadLoan(t, l, loan.ID); !loan.AccruedInterest.IsZero() {
+		t.Errorf("Expected no accrual during the grace period, got %s", loan.AccruedInterest)
+	}
+
+	// A per-loan grace period overrides the product's; without one interest accrues at once
+	immediate, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero)
+	short, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero, WithProduct("GRACE"), WithAccrualGrace(5))
+	if short.AccrualStartDate == nil || !short.AccrualStartDate.Equal(disbursed.AddDate(0, 0, 5)) {
+		t.Errorf("Expected the loan's own 5-day grace period, got %v", short.AccrualStartDate)
+	}
+	l.CalculateDailyInterest(ctx)
+	if immediate = reloadLoan(t, l, immediate.ID); !immediate.AccruedInterest.Round(2).Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Expected 1.00 accrued without a grace period, got %s", immediate.AccruedInterest)
+	}
+}
+
+func TestSimpleInterestMode(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if _, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithInterestMode("daily")); err == nil {
+		t.Error("Expected error for an unknown interest mode")
+	}
+
+	loan, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithInterestMode(models.InterestSimple))
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	loan.AccruedInterest = decimal.NewFromInt(5)
+	loan.StatementCycleDay = time.Now().Day()
+	saveLoan(t, store, loan)
+
+	l.ApplyMonthlyInterest(ctx)
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(1000)) || !loan.InterestDue.Equal(decimal.NewFromInt(5)) {
+		t.Fatalf("Expected interest billed as due without capitalizing, got balance %s and interest due %s", loan.Balance, loan.InterestDue)
+	}
+	if interest := transactionsOfType(store, loan.ID, models.TransactionTypeInterest); len(interest) != 1 {
+		t.Errorf("Expected one interest transaction, got %d", len(interest))
+	}
+
+	// Payments cover the interest due before the balance
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(20)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	if loan = reloadLoan(t, l, loan.ID); !loan.InterestDue.IsZero() || !loan.Balance.Equal(decimal.NewFromInt(985)) {
+		t.Errorf("Expected interest due 0 and balance 985, got %s and %s", loan.InterestDue, loan.Balance)
+	}
+}
+
+func TestRoundingPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if err := l.SetRoundingPolicy(models.RoundingPolicy{Mode: "nearest", Places: 2}); err == nil {
+		t.Error("Expected error for an unknown rounding mode")
+	}
+	if err := l.SetRoundingPolicy(models.RoundingPolicy{Mode: models.RoundingHalfUp, Places: 4}); err == nil {
+		t.Error("Expected error for rounding to other than cents or mills")
+	}
+
+	// 1000 at 10% accrues 0.27397... a day
+	daily := DailyInterest(decimal.NewFromInt(1000), decimal.NewFromFloat(0.10))
+	policy := models.RoundingPolicy{Mode: models.RoundingHalfEven, Places: 2}
+	dropped, tracked := &models.Loan{}, &models.Loan{}
+	var droppedTotal, trackedTotal decimal.Decimal
+	for i := 0; i < 365; i++ {
+		droppedTotal = droppedTotal.Add(roundAccrual(policy, dropped, daily))
+		policy.TrackResidual = true
+		trackedTotal = trackedTotal.Add(roundAccrual(policy, tracked, daily))
+		policy.TrackResidual = false
+	}
+	if !droppedTotal.Equal(decimal.NewFromFloat(98.55)) {
+		t.Errorf("Expected 365 days rounded to 0.27 to total 98.55, got %s", droppedTotal)
+	}
+	if !trackedTotal.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Expected residual tracking to total a full year's 100.00, got %s", trackedTotal)
+	}
+	mills := models.RoundingPolicy{Mode: models.RoundingHalfUp, Places: 3}
+	if got := roundAccrual(mills, &models.Loan{}, daily); !got.Equal(decimal.NewFromFloat(0.274)) {
+		t.Errorf("Expected 0.274 rounded to mills, got %s", got)
+	}
+
+	// Capitalization rounds to cents and writes off the rest unless residuals are tracked
+	if err := l.SetRoundingPolicy(mills); err != nil {
+		t.Fatalf("Failed to set rounding policy: %v", err)
+	}
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	loan.AccruedInterest = decimal.NewFromFloat(5.006)
+	loan.StatementCycleDay = time.Now().Day()
+	saveLoan(t, store, loan)
+	l.ApplyMonthlyInterest(ctx)
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromFloat(1005.01)) || !loan.AccruedInterest.IsZero() {
+		t.Errorf("Expected balance 1005.01 with nothing left accrued, got %s and %s", loan.Balance, loan.AccruedInterest)
+	}
+
+	mills.TrackResidual = true
+	l.SetRoundingPolicy(mills)
+	carried, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero)
+	carried.AccruedInterest = decimal.NewFromFloat(5.006)
+	carried.StatementCycleDay = time.Now().Day()
+	saveLoan(t, store, carried)
+	l.ApplyMonthlyInterest(ctx)
+	if carried = reloadLoan(t, l, carried.ID); !carried.Balance.Equal(decimal.NewFromFloat(1005.01)) || !carried.AccruedInterest.Equal(decimal.NewFromFloat(-0.004)) {
+		t.Errorf("Expected balance 1005.01 with -0.004 carried, got %s and %s", carried.Balance, carried.AccruedInterest)
+	}
+}
+
+func TestNegativeAmortizationCap(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if _, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithNegativeAmortizationCap(decimal.NewFromFloat(0.9))); err == nil {
+		t.Error("Expected error for a cap below the original principal")
+	}
+
+	loan, err := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.Zero, WithNegativeAmortizationCap(decimal.NewFromFloat(1.10)))
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	loan.Balance = decimal.NewFromInt(1090)
+	loan.AccruedInterest = decimal.NewFromInt(25)
+	loan.StatementCycleDay = time.Now().Day()
+	saveLoan(t, store, loan)
+
+	l.ApplyMonthlyInterest(ctx)
+	if loan = reloadLoan(t, l, loan.ID); !loan.Balance.Equal(decimal.NewFromInt(1100)) || !loan.InterestDue.Equal(decimal.NewFromInt(15)) {
+		t.Errorf("Expected balance capped at 1100 with 15 billed, got %s and %s", loan.Balance, loan.InterestDue)
+	}
+	if !loan.NegativeAmortizationCapped {
+		t.Error("Expected the loan to be flagged once the cap was reached")
+	}
+
+	events, _ := store.GetLoanEventsForLoan(ctx, loan.ID)
+	found := false
+	for _, event := range events {
+		if event.Type == models.LoanEventNegativeAmortizationCap {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected a negative amortization cap event")
+	}
+}
+
+func TestAPRAndAPY(t *testing.T) {
+	ctx := context.Background()
+
+	apr := decimal.NewFromFloat(0.12)
+	apy := APYFromAPR(apr, models.InterestCompound)
+	if !apy.Round(6).Equal(decimal.NewFromFloat(0.126825)) {
+		t.Errorf("Expected 12%% compounded monthly to yield 0.126825, got %s", apy)
+	}
+	if back := APRFromAPY(apy, models.InterestCompound); !back.Round(6).Equal(apr) {
+		t.Errorf("Expected the APY to convert back to %s, got %s", apr, back)
+	}
+	if simple := APYFromAPR(apr, models.InterestSimple); !simple.Equal(apr) {
+		t.Errorf("Expected simple interest to yield the nominal rate, got %s", simple)
+	}
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+	created, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(1000), decimal.NewFromFloat(0.10), decimal.NewFromFloat(0.02))
+	loan, err := l.GetLoan(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Failed to get loan: %v", err)
+	}
+	if loan.APR == nil || !loan.APR.Equal(apr) || loan.APY == nil || !loan.APY.Equal(decimal.NewFromFloat(0.126825)) {
+		t.Errorf("Expected APR 0.12 and APY 0.126825 on the loan, got %v and %v", loan.APR, loan.APY)
+	}
+}
+
+func TestAccrualHistory(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(3650), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.CalculateDailyInterest(ctx)
+	loan = reloadLoan(t, l, loan.ID)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	accruals, err := l.GetAccruals(ctx, loan.ID, today.AddDate(0, 0, -7), today)
+	if err != nil {
+		t.Fatalf("Failed to get accruals: %v", err)
+	}
+	if len(accruals) != 1 {
+		t.Fatalf("Expected one accrual, got %d", len(accruals))
+	}
+	accrual := accruals[0]
+	if !accrual.Date.Equal(today) || !accrual.Balance.Equal(decimal.NewFromInt(3650)) || !accrual.Rate.Equal(decimal.NewFromFloat(0.10)) || !accrual.Amount.Equal(loan.AccruedInterest) {
+		t.Errorf("Unexpected accrual %+v", accrual)
+	}
+
+	if accruals, _ := l.GetAccruals(ctx, loan.ID, today.AddDate(0, 0, -7), today.AddDate(0, 0, -1)); len(accruals) != 0 {
+		t.Errorf("Expected no accruals before today, got %d", len(accruals))
+	}
+	if _, err := l.GetAccruals(ctx, loan.ID, today, today.AddDate(0, 0, -1)); err == nil {
+		t.Error("Expected error for a reversed date range")
+	}
+}
+
+// recordingWebhookSender records deliveries and fails while failing is set.
+type recordingWebhookSender struct {
+	failing bool
+	sent    []map[string]string
+	bodies  [][]byte
+}
+
+func (s *recordingWebhookSender) Send(url string, body []byte, headers map[string]string) error {
+	if s.failing {
+		return fmt.Errorf("connection refused")
+	}
+	s.sent = append(s.sent, headers)
+	s.bodies = append(s.bodies, body)
+	return nil
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if _, err := l.CreateWebhookSubscription(ctx, "ftp://example.com", []models.WebhookEventType{models.WebhookLoanClosed}); err == nil {
+		t.Error("Expected error for a non-HTTP URL")
+	}
+	if _, err := l.CreateWebhookSubscription(ctx, "https://example.com/hook", []models.WebhookEventType{"loan.exploded"}); err == nil {
+		t.Error("Expected error for an unknown event")
+	}
+	subscription, err := l.CreateWebhookSubscription(ctx, "https://example.com/hook", []models.WebhookEventType{models.WebhookPaymentRecorded, models.WebhookLoanClosed})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	if len(subscription.Secret) != 64 {
+		t.Errorf("Expected a 32-byte hex secret, got %q", subscription.Secret)
+	}
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(500), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(500)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	// Events wait in the outbox until the relay queues them, once
+	if deliveries, _ := store.GetWebhookDeliveriesForSubscription(ctx, subscription.ID, 10); len(deliveries) != 0 {
+		t.Fatalf("Expected nothing queued before the relay runs, got %d deliveries", len(deliveries))
+	}
+	if published, err := l.RelayOutbox(ctx); err != nil || published != 3 {
+		t.Fatalf("Expected loan.created, payment.recorded and loan.closed published, got %d (%v)", published, err)
+	}
+	if published, _ := l.RelayOutbox(ctx); published != 0 {
+		t.Errorf("Expected published events not to be relayed again, got %d", published)
+	}
+	if deliveries, _ := store.GetWebhookDeliveriesForSubscription(ctx, subscription.ID, 10); len(deliveries) != 2 {
+		t.Fatalf("Expected payment.recorded and loan.closed queued (not loan.created), got %d deliveries", len(deliveries))
+	}
+
+	sender := &recordingWebhookSender{failing: true}
+	if delivered, failed := l.DeliverWebhooks(ctx, sender); delivered != 0 || failed != 2 {
+		t.Errorf("Expected 2 failed deliveries, got %d delivered and %d failed", delivered, failed)
+	}
+	deliveries, _ := store.GetWebhookDeliveriesForSubscription(ctx, subscription.ID, 10)
+	retry := deliveries[len(deliveries)-1]
+	if retry.Status != models.WebhookDeliveryPending || retry.Attempts != 1 || !retry.NextAttemptAt.After(time.Now()) || retry.LastError != "connection refused" {
+		t.Errorf("Expected the delivery scheduled for retry, got %+v", retry)
+	}
+
+	// Make the retries due
+	for _, delivery := range deliveries {
+		delivery.NextAttemptAt = time.Now().Add(-time.Second)
+		store.UpdateWebhookDelivery(ctx, delivery)
+	}
+	sender.failing = false
+	if delivered, _ := l.DeliverWebhooks(ctx, sender); delivered != 2 {
+		t.Fatalf("Expected 2 deliveries, got %d", delivered)
+	}
+	if sender.sent[0][WebhookEventHeader] != string(models.WebhookPaymentRecorded) || sender.sent[1][WebhookEventHeader] != string(models.WebhookLoanClosed) {
+		t.Errorf("Expected payment.recorded then loan.closed, got %v", sender.sent)
+	}
+	mac := hmac.New(sha256.New, []byte(subscription.Secret))
+	mac.Write(sender.bodies[0])
+	if sender.sent[0][WebhookSignatureHeader] != hex.EncodeToString(mac.Sum(nil)) {
+		t.Error("Expected the body signed with the subscription secret")
+	}
+	var event models.WebhookEvent
+	json.Unmarshal(sender.bodies[1], &event)
+	if data, ok := event.Data.(map[string]any); !ok || data["status"] != string(models.LoanStatusClosed) {
+		t.Errorf("Expected the closed loan as the event data, got %+v", event.Data)
+	}
+	if delivered, failed := l.DeliverWebhooks(ctx, sender); delivered+failed != 0 {
+		t.Error("Expected delivered events not to be sent again")
+	}
+
+	subscriptions, _ := l.GetWebhookSubscriptions(ctx)
+	if len(subscriptions) != 1 || subscriptions[0].Secret != "" {
+		t.Errorf("Expected the subscription listed without its secret, got %+v", subscriptions)
+	}
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(500), decimal.NewFromFloat(0.10), decimal.Zero)
+
+	events, unsubscribe := l.SubscribeEvents()
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(500)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+
+	payment := <-events
+	if payment.Type != models.StreamTransactionCreated || payment.LoanID != loan.ID {
+		t.Fatalf("Expected the payment transaction first, got %+v", payment)
+	}
+	if tx, ok := payment.Data.(*models.Transaction); !ok || tx.Type != models.TransactionTypePayment {
+		t.Errorf("Expected a payment transaction as the data, got %+v", payment.Data)
+	}
+	closed := <-events
+	change, ok := closed.Data.(models.LoanStatusChange)
+	if closed.Type != models.StreamLoanStatusChanged || !ok || change.From != models.LoanStatusActive || change.To != models.LoanStatusClosed {
+		t.Errorf("Expected a change from active to closed, got %+v", closed)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Error("Expected the channel closed after unsubscribing")
+	}
+	l.CreateLoan(ctx, "cust1", decimal.NewFromInt(100), decimal.NewFromFloat(0.10), decimal.Zero) // Must not panic on the closed channel
+}
+
+func TestErrorValues(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	if _, err := l.RecordPayment(ctx, uuid.New(), decimal.NewFromInt(10)); !errors.Is(err, models.ErrLoanNotFound) {
+		t.Errorf("Expected ErrLoanNotFound, got %v", err)
+	}
+
+	line, _ := l.CreateLoan(ctx, "cust1", decimal.Zero, decimal.NewFromFloat(0.10), decimal.Zero, WithLineOfCredit(decimal.NewFromInt(100)))
+	if _, err := l.Draw(ctx, line.ID, decimal.NewFromInt(150)); !errors.Is(err, models.ErrInsufficientCredit) {
+		t.Errorf("Expected ErrInsufficientCredit, got %v", err)
+	}
+
+	loan, _ := l.CreateLoan(ctx, "cust1", decimal.NewFromInt(100), decimal.NewFromFloat(0.10), decimal.Zero)
+	l.RecordPayment(ctx, loan.ID, decimal.NewFromInt(100))
+	if _, err := l.ChargeOffLoan(ctx, loan.ID); !errors.Is(err, models.ErrLoanNotActive) {
+		t.Errorf("Expected ErrLoanNotActive for a closed loan, got %v", err)
+	}
+	if _, err := l.GetAutopayEnrollment(ctx, loan.ID); !errors.Is(err, models.ErrNotEnrolledInAutopay) {
+		t.Errorf("Expected ErrNotEnrolledInAutopay, got %v", err)
+	}
+	if _, err := l.DisburseEscrow(ctx, loan.ID, decimal.NewFromInt(10), "County"); !errors.Is(err, models.ErrNoEscrowAccount) {
+		t.Errorf("Expected ErrNoEscrowAccount, got %v", err)
+	}
+
+	stale, _ := l.GetLoan(ctx, loan.ID)
+	update := *stale
+	if err := l.UpdateLoanIfVersion(ctx, &update, stale.Version-1); !errors.Is(err, models.ErrLoanVersionMismatch) {
+		t.Errorf("Expected ErrLoanVersionMismatch, got %v", err)
+	}
+}
+
+func TestExportLoansCSV(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	// More than one page, so the export reads the store repeatedly
+	for i := 0; i < exportPageSize+1; i++ {
+		l.CreateLoan(ctx, fmt.Sprintf("cust%03d", i), decimal.NewFromFloat(1000.5), decimal.NewFromFloat(0.095), decimal.Zero)
+	}
+
+	var buf strings.Builder
+	if err := l.ExportLoansCSV(ctx, &buf, models.LoanSearch{}); err != nil {
+		t.Fatalf("Failed to export loans: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != exportPageSize+2 {
+		t.Fatalf("Expected a header and %d rows, got %d lines", exportPageSize+1, len(lines))
+	}
+	if !strings.HasPrefix(lines[0], "id,customer_key,status,") {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if !strings.Contains(lines[1], ",1000.50,1000.50,0.095,0.00,") {
+		t.Errorf("Expected fixed-cent amounts and the exact rate, got %q", lines[1])
+	}
+
+	buf.Reset()
+	if err := l.ExportLoansCSV(ctx, &buf, models.LoanSearch{Statuses: []models.LoanStatus{"open"}}); err == nil || buf.Len() != 0 {
+		t.Errorf("Expected an invalid search to fail before writing, got %v and %d bytes", err, buf.Len())
+	}
+}
+
+func TestVoidLoan(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	loan, _ := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0)); err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+	before, _ := l.GetTransactions(ctx, loan.ID)
+
+	voided, err := l.VoidLoan(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to void loan: %v", err)
+	}
+	if voided.Status != models.LoanStatusVoided || voided.DeletedAt == nil {
+		t.Errorf("Expected a voided loan with a deletion time, got status %s and deleted_at %v", voided.Status, voided.DeletedAt)
+	}
+
+	// The loan and its transactions are kept
+	if _, err := l.GetLoan(ctx, loan.ID); err != nil {
+		t.Errorf("Expected the voided loan to be kept, got %v", err)
+	}
+	after, _ := l.GetTransactions(ctx, loan.ID)
+	if len(after) != len(before) {
+		t.Errorf("Expected %d transactions to be kept, got %d", len(before), len(after))
+	}
+
+	// Voided loans drop out of listings and can no longer be changed
+	if loans, _ := l.GetAllLoans(ctx); len(loans) != 0 {
+		t.Errorf("Expected no loans listed, got %d", len(loans))
+	}
+	if loans, _ := l.GetCustomerLoans(ctx, "cust123"); len(loans) != 0 {
+		t.Errorf("Expected no customer loans listed, got %d", len(loans))
+	}
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0)); !errors.Is(err, models.ErrLoanNotActive) {
+		t.Errorf("Expected ErrLoanNotActive for a payment on a voided loan, got %v", err)
+	}
+	edit := *voided
+	edit.CustomerKey = "cust456"
+	if err := l.UpdateLoan(ctx, &edit); !errors.Is(err, models.ErrLoanNotActive) {
+		t.Errorf("Expected ErrLoanNotActive for editing a voided loan, got %v", err)
+	}
+
+	// Voiding again keeps the original deletion time
+	deletedAt := *voided.DeletedAt
+	again, err := l.VoidLoan(ctx, loan.ID)
+	if err != nil || !again.DeletedAt.Equal(deletedAt) {
+		t.Errorf("Expected voiding twice to change nothing, got %v and deleted_at %v", err, again.DeletedAt)
+	}
+}
+
+func TestCustomers(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+
+	customer := &models.Customer{CustomerKey: "cust123", Name: "Ada Borrower"}
+	if err := l.CreateCustomer(ctx, customer); err != nil {
+		t.Fatalf("Failed to create customer: %v", err)
+	}
+	if customer.Status != models.CustomerStatusActive || customer.ID == uuid.Nil {
+		t.Errorf("Expected an active customer with an ID, got %+v", customer)
+	}
+	if err := l.CreateCustomer(ctx, &models.Customer{CustomerKey: "cust123"}); !errors.Is(err, models.ErrCustomerExists) {
+		t.Errorf("Expected ErrCustomerExists, got %v", err)
+	}
+	if err := l.CreateCustomer(ctx, &models.Customer{CustomerKey: "cust456", Status: "closed"}); err == nil {
+		t.Error("Expected an invalid status to be rejected")
+	}
+
+	loan, err := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan for an active customer: %v", err)
+	}
+
+	// An update without a status keeps it; an inactive customer takes no new loans
+	update := &models.Customer{CustomerKey: "cust123", Name: "Ada B. Borrower"}
+	if err := l.UpdateCustomer(ctx, update); err != nil || update.Status != models.CustomerStatusActive || update.ID != customer.ID {
+		t.Errorf("Expected the status and ID to be kept, got %+v (%v)", update, err)
+	}
+	if err := l.UpdateCustomer(ctx, &models.Customer{CustomerKey: "cust123", Status: models.CustomerStatusInactive}); err != nil {
+		t.Fatalf("Failed to make customer inactive: %v", err)
+	}
+	if _, err := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(500.0), decimal.NewFromFloat(0.10), decimal.Zero); !errors.Is(err, models.ErrCustomerNotActive) {
+		t.Errorf("Expected ErrCustomerNotActive for a new loan, got %v", err)
+	}
+	if _, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0)); err != nil {
+		t.Errorf("Expected an inactive customer's loan to still take payments, got %v", err)
+	}
+
+	// Loans for a new customer key register the customer, who is then kept
+	other, err := l.CreateLoan(ctx, "cust789", decimal.NewFromFloat(500.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan for a new customer key: %v", err)
+	}
+	if _, err := l.GetCustomer(ctx, other.CustomerKey); err != nil {
+		t.Errorf("Expected the new customer key to be registered, got %v", err)
+	}
+	if err := l.DeleteCustomer(ctx, "cust789"); !errors.Is(err, models.ErrCustomerHasLoans) {
+		t.Errorf("Expected ErrCustomerHasLoans, got %v", err)
+	}
+
+	// Loans cannot be moved to an inactive customer
+	moved := *other
+	moved.CustomerKey = "cust123"
+	if err := l.UpdateLoan(ctx, &moved); !errors.Is(err, models.ErrCustomerNotActive) {
+		t.Errorf("Expected ErrCustomerNotActive when moving a loan, got %v", err)
+	}
+}
+
+func TestEventSourcing(t *testing.T) {
+	ctx := context.Background()
+
+	store := store.NewMemoryStore()
+	l := NewLedger(store)
+	l.SetEventSourcing(true)
+
+	loan, err := l.CreateLoan(ctx, "cust123", decimal.NewFromFloat(1000.0), decimal.NewFromFloat(0.10), decimal.Zero)
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	if run, err := l.RunDailyInterest(ctx, JobScope{}); err != nil || run.Processed != 1 {
+		t.Fatalf("Expected the loan to accrue, got %+v (%v)", run, err)
+	}
+	loan = reloadLoan(t, l, loan.ID)
+	missed := time.Now().AddDate(0, 0, -3)
+	loan.StatementCycleDay = missed.Day()
+	saveLoan(t, store, loan)
+	if run, err := l.RunMonthlyInterest(ctx, JobScope{Date: missed}); err != nil || run.Processed != 1 {
+		t.Fatalf("Expected the loan's interest applied, got %+v (%v)", run, err)
+	}
+	payment, err := l.RecordPayment(ctx, loan.ID, decimal.NewFromFloat(100.0))
+	if err != nil {
+		t.Fatalf("Failed to record payment: %v", err)
+	}
+
+	events, err := l.GetLedgerEvents(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to get ledger events: %v", err)
+	}
+	want := []models.LedgerEventType{models.LedgerLoanCreated, models.LedgerInterestAccrued, models.LedgerInterestApplied, models.LedgerPaymentRecorded}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(events))
+	}
+	for i, event := range events {
+		if event.Type != want[i] || event.Sequence != i+1 {
+			t.Errorf("Expected event %d to be %s, got %s at %d", i+1, want[i], event.Type, event.Sequence)
+		}
+	}
+	if last := events[3]; last.TransactionID == nil || *last.TransactionID != payment.ID || !last.Changes.Balance.Equal(decimal.NewFromFloat(-100.0)) {
+		t.Errorf("Expected the payment to lower the balance by 100, got %+v", last)
+	}
+
+	projection, err := l.ProjectLoan(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to project loan: %v", err)
+	}
+	loan = reloadLoan(t, l, loan.ID)
+	if len(projection.Differences) != 0 || projection.Events != 4 || !projection.Amounts.Balance.Equal(loan.Balance) {
+		t.Errorf("Expected the projection to match the loan's balance %s, got %+v", loan.Balance, projection)
+	}
+
+	// A change made around the ledger shows up as a difference
+	loan.Balance = loan.Balance.Add(decimal.NewFromInt(1))
+	saveLoan(t, store, loan)
+	if projection, _ = l.ProjectLoan(ctx, loan.ID); len(projection.Differences) != 1 || projection.Differences[0].Field != "balance" {
+		t.Errorf("Expected a balance difference, got %+v", projection.Differences)
+	}
+}
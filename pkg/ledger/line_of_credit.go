@@ -0,0 +1,110 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithLineOfCredit originates the loan as a revolving line of credit with the given credit
+// limit. The initial disbursement may be zero; further funds are drawn with Draw.
+func WithLineOfCredit(creditLimit decimal.Decimal) LoanOption {
+	return func(loan *models.Loan) {
+		loan.LoanType = models.LoanTypeLineOfCredit
+		loan.CreditLimit = creditLimit
+	}
+}
+
+// validatePrincipal requires installment loans to disburse a positive principal. Lines of
+// credit may open undrawn.
+func validatePrincipal(loan *models.Loan) error {
+	if loan.Principal.IsNegative() {
+		return models.Invalidf("principal must not be negative")
+	}
+	if loan.LoanType != models.LoanTypeLineOfCredit && !loan.Principal.IsPositive() {
+		return models.Invalidf("principal must be positive")
+	}
+	return nil
+}
+
+// validateCreditLimit requires a line of credit to have a positive credit limit covering its
+// initial draw. Installment loans have no credit limit.
+func validateCreditLimit(loan *models.Loan) error {
+	if loan.LoanType != models.LoanTypeLineOfCredit {
+		if !loan.CreditLimit.IsZero() {
+			return models.Invalidf("credit limit applies only to lines of credit")
+		}
+		return nil
+	}
+	if !loan.CreditLimit.IsPositive() {
+		return models.Invalidf("credit limit must be positive")
+	}
+	if loan.Principal.GreaterThan(loan.CreditLimit) {
+		return models.Invalidf("principal exceeds credit limit")
+	}
+	return nil
+}
+
+// availableCredit returns how much more may be drawn on a line of credit: the credit limit
+// less the balance and amounts due, and never below zero.
+func availableCredit(loan *models.Loan) decimal.Decimal {
+	available := loan.CreditLimit.Sub(loan.Balance).Sub(amountsDue(loan))
+	if available.IsNegative() {
+		return decimal.Zero
+	}
+	return available
+}
+
+// setAvailableCredit fills in the computed AvailableCredit of a line of credit.
+func setAvailableCredit(loan *models.Loan) {
+	if loan.LoanType != models.LoanTypeLineOfCredit {
+		return
+	}
+	available := availableCredit(loan)
+	loan.AvailableCredit = &available
+}
+
+// Draw advances funds on an active line of credit. The draw is added to the balance, where it
+// accrues interest from the day it is drawn, and is recorded as a disbursement.
+func (l *Ledger) Draw(ctx context.Context, loanID uuid.UUID, amount decimal.Decimal) (*models.Transaction, error) {
+	if !amount.IsPositive() {
+		return nil, models.Invalidf("draw amount must be positive")
+	}
+
+	var transaction *models.Transaction
+	err := l.changeLoan(ctx, loanID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		if loan.LoanType != models.LoanTypeLineOfCredit {
+			return models.ErrNotLineOfCredit
+		}
+		if !loan.Status.IsOpen() {
+			return models.ErrLoanNotActive
+		}
+		if amount.GreaterThan(availableCredit(loan)) {
+			return models.ErrInsufficientCredit
+		}
+
+		now := time.Now()
+		loan.Balance = loan.Balance.Add(amount)
+		loan.UpdatedAt = now
+
+		transaction = &models.Transaction{
+			ID:        uuid.New(),
+			LoanID:    loan.ID,
+			Amount:    amount,
+			Type:      models.TransactionTypeDisbursement,
+			Timestamp: now,
+		}
+		if err := tl.updateLoanWithTransactions(ctx, loan, transaction); err != nil {
+			return fmt.Errorf("failed to record draw: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
@@ -0,0 +1,90 @@
+package ledger
+
+import (
+	"context"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+const (
+	defaultLoanPageSize = 100  // Loans per page when the query sets no limit
+	maxLoanPageSize     = 1000 // Most loans a single page may return
+
+	maxTransactionPageSize = 1000 // Most transactions a single page may return
+)
+
+// ListLoans retrieves a page of the loans matching the query, with the total number matching
+// so clients can page through them all.
+func (l *Ledger) ListLoans(ctx context.Context, query models.LoanQuery) (*models.LoanPage, error) {
+	if query.Status != "" && !query.Status.Valid() {
+		return nil, models.Invalidf("invalid status filter: %q", query.Status)
+	}
+	if err := checkLoanPage(query.Sort, &query.Limit, query.Offset); err != nil {
+		return nil, err
+	}
+
+	loans, total, err := l.storage.ListLoans(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return loanPage(loans, total, query.Limit, query.Offset), nil
+}
+
+// SearchLoans retrieves a page of the loans matching the search, with the total number
+// matching so clients can page through them all.
+func (l *Ledger) SearchLoans(ctx context.Context, search models.LoanSearch) (*models.LoanPage, error) {
+	return l.searchLoans(ctx, l.storage, search)
+}
+
+// searchLoans is SearchLoans reading from r.
+func (l *Ledger) searchLoans(ctx context.Context, r store.Reader, search models.LoanSearch) (*models.LoanPage, error) {
+	for _, status := range search.Statuses {
+		if !status.Valid() {
+			return nil, models.Invalidf("invalid status filter: %q", status)
+		}
+	}
+	if search.MinBalance != nil && search.MaxBalance != nil && search.MinBalance.GreaterThan(*search.MaxBalance) {
+		return nil, models.Invalidf("invalid balance range: min_balance is greater than max_balance")
+	}
+	if search.MinRate != nil && search.MaxRate != nil && search.MinRate.GreaterThan(*search.MaxRate) {
+		return nil, models.Invalidf("invalid rate range: min_rate is greater than max_rate")
+	}
+	if search.CreatedFrom != nil && search.CreatedTo != nil && search.CreatedFrom.After(*search.CreatedTo) {
+		return nil, models.Invalidf("invalid created range: created_from is after created_to")
+	}
+	if err := checkLoanPage(search.Sort, &search.Limit, search.Offset); err != nil {
+		return nil, err
+	}
+
+	loans, total, err := r.SearchLoans(ctx, search)
+	if err != nil {
+		return nil, err
+	}
+	return loanPage(loans, total, search.Limit, search.Offset), nil
+}
+
+// checkLoanPage validates the sort and paging of a loan listing, defaulting an unset limit.
+func checkLoanPage(sort models.LoanSort, limit *int, offset int) error {
+	if !sort.Valid() {
+		return models.Invalidf("invalid sort: %q", sort)
+	}
+	if *limit == 0 {
+		*limit = defaultLoanPageSize
+	}
+	if *limit < 0 || *limit > maxLoanPageSize {
+		return models.Invalidf("invalid limit: must be between 1 and %d", maxLoanPageSize)
+	}
+	if offset < 0 {
+		return models.Invalidf("invalid offset: must not be negative")
+	}
+	return nil
+}
+
+func loanPage(loans []*models.Loan, total int, limit int, offset int) *models.LoanPage {
+	for _, loan := range loans {
+		setAvailableCredit(loan)
+		setDisclosureRates(loan)
+	}
+	return &models.LoanPage{Loans: loans, Total: total, Limit: limit, Offset: offset}
+}
@@ -0,0 +1,48 @@
+package ledger
+
+import (
+	"maps"
+	"regexp"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+const (
+	maxMetadataKeys        = 20  // Most metadata values one transaction may carry
+	maxMetadataValueLength = 500 // Longest metadata value, in bytes
+)
+
+// metadataKeyPattern matches the keys metadata may use. Keys are kept to a plain alphabet so
+// that they can be used in the JSON paths the stores filter on.
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// WithMetadata records free-form details of a payment, such as the channel it came through,
+// an external reference number or the operator who posted it. Values set by an earlier
+// WithMetadata are kept unless replaced.
+func WithMetadata(metadata map[string]string) PaymentOption {
+	return func(tx *models.Transaction) {
+		if len(metadata) == 0 {
+			return
+		}
+		if tx.Metadata == nil {
+			tx.Metadata = map[string]string{}
+		}
+		maps.Copy(tx.Metadata, metadata)
+	}
+}
+
+// validateMetadata checks a transaction's metadata, or a filter on it.
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxMetadataKeys {
+		return models.Invalidf("invalid metadata: at most %d keys are allowed", maxMetadataKeys)
+	}
+	for key, value := range metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return models.Invalidf("invalid metadata key %q: keys are 1 to 64 letters, digits, '_', '.' or '-'", key)
+		}
+		if len(value) > maxMetadataValueLength {
+			return models.Invalidf("invalid metadata value for %q: at most %d bytes are allowed", key, maxMetadataValueLength)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"context"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// defaultMinimumPayment applies to loans whose product does not set its own policy: the
+// greater of 25.00 or 1% of the balance plus the cycle's interest and fees.
+var defaultMinimumPayment = models.MinimumPaymentPolicy{
+	Floor:   decimal.NewFromInt(25),
+	Percent: decimal.NewFromFloat(0.01),
+}
+
+// validateMinimumPayment checks that a policy's floor is not negative and its percent is a
+// fraction of the balance.
+func validateMinimumPayment(policy models.MinimumPaymentPolicy) error {
+	if policy.Floor.IsNegative() {
+		return models.Invalidf("invalid minimum payment floor: must not be negative")
+	}
+	if policy.Percent.IsNegative() || policy.Percent.GreaterThan(one) {
+		return models.Invalidf("invalid minimum payment percent: must be between 0 and 1")
+	}
+	return nil
+}
+
+// SetMinimumPaymentPolicy sets the policy for loans whose product does not set its own.
+func (l *Ledger) SetMinimumPaymentPolicy(policy models.MinimumPaymentPolicy) error {
+	if err := validateMinimumPayment(policy); err != nil {
+		return err
+	}
+	l.minimumPayment = policy
+	return nil
+}
+
+// minimumPaymentPolicy returns the policy governing the loan: its product's, if the product
+// sets one, otherwise the ledger default.
+func (l *Ledger) minimumPaymentPolicy(ctx context.Context, loan *models.Loan) (models.MinimumPaymentPolicy, error) {
+	if loan.ProductCode != "" {
+		product, err := l.storage.GetProduct(ctx, loan.ProductCode)
+		if err != nil {
+			return models.MinimumPaymentPolicy{}, err
+		}
+		if !product.MinimumPaymentFloor.IsZero() || !product.MinimumPaymentPercent.IsZero() {
+			return models.MinimumPaymentPolicy{Floor: product.MinimumPaymentFloor, Percent: product.MinimumPaymentPercent}, nil
+		}
+	}
+	return l.minimumPayment, nil
+}
+
+// MinimumDue applies a policy to a statement. The percentage is taken of the balance before
+// the cycle's interest and fees, which are then due in full.
+func MinimumDue(policy models.MinimumPaymentPolicy, statement *models.Statement) decimal.Decimal {
+	if !statement.Balance.IsPositive() {
+		return decimal.Zero
+	}
+	charges := statement.InterestCharged.Add(statement.Fees)
+	due := statement.Balance.Sub(charges).Mul(policy.Percent).Add(charges)
+	due = decimal.Max(due, policy.Floor)
+	return decimal.Min(due, statement.Balance).Round(2)
+}
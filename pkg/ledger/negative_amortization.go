@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithNegativeAmortizationCap limits how far capitalized interest may grow the balance, as a
+// multiple of the original principal (1.10 caps the balance at 110% of principal).
+func WithNegativeAmortizationCap(multiple decimal.Decimal) LoanOption {
+	return func(loan *models.Loan) {
+		loan.NegativeAmortizationCap = multiple
+	}
+}
+
+// validateNegativeAmortizationCap requires a cap to allow at least the original principal.
+func validateNegativeAmortizationCap(loan *models.Loan) error {
+	if loan.NegativeAmortizationCap.IsNegative() {
+		return models.Invalidf("negative amortization cap must not be negative")
+	}
+	if loan.NegativeAmortizationCap.IsPositive() && loan.NegativeAmortizationCap.LessThan(one) {
+		return models.Invalidf("negative amortization cap must be at least 1")
+	}
+	return nil
+}
+
+// negativeAmortizationLimit is the most the loan's balance may reach by capitalizing interest,
+// or nil if it is uncapped. Lines of credit are capped relative to their credit limit.
+func negativeAmortizationLimit(loan *models.Loan) *decimal.Decimal {
+	if !loan.NegativeAmortizationCap.IsPositive() {
+		return nil
+	}
+	base := loan.Principal
+	if loan.LoanType == models.LoanTypeLineOfCredit {
+		base = loan.CreditLimit
+	}
+	limit := base.Mul(loan.NegativeAmortizationCap).Round(2)
+	return &limit
+}
+
+// applyInterest applies a cycle's interest to the loan. Simple interest is billed as interest
+// due. Compound interest is capitalized up to the negative amortization cap; the rest is billed
+// as interest due and the loan is flagged. It reports whether this application first reached
+// the cap.
+func applyInterest(loan *models.Loan, amount decimal.Decimal) bool {
+	if loan.InterestMode == models.InterestSimple {
+		loan.InterestDue = loan.InterestDue.Add(amount)
+		return false
+	}
+
+	capitalized := amount
+	if limit := negativeAmortizationLimit(loan); limit != nil {
+		capitalized = decimal.Max(decimal.Min(amount, limit.Sub(loan.Balance)), decimal.Zero)
+	}
+	loan.Balance = loan.Balance.Add(capitalized)
+	billed := amount.Sub(capitalized)
+	if !billed.IsPositive() {
+		return false
+	}
+	loan.InterestDue = loan.InterestDue.Add(billed)
+	reached := !loan.NegativeAmortizationCapped
+	loan.NegativeAmortizationCapped = true
+	return reached
+}
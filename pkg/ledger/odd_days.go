@@ -0,0 +1,54 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// firstStatementDate is the loan's first statement date. A loan disbursed on its statement
+// cycle day is first billed a full cycle later.
+func firstStatementDate(loan *models.Loan) time.Time {
+	return nextStatementDate(loan.CreatedAt, loan.StatementCycleDay)
+}
+
+// applyOddDays fixes how the stub period of a loan disbursed mid-cycle is billed, following
+// the product's policy. Under the charge policy the stub interest is computed once, at the
+// rates known at origination, so that the amount on the first statement does not depend on
+// which days the batch happened to run.
+func applyOddDays(loan *models.Loan, product *models.Product) {
+	if product == nil || product.OddDaysInterest == "" {
+		return
+	}
+	disbursed := loan.CreatedAt.UTC().Truncate(24 * time.Hour)
+	if disbursed.Day() == loan.StatementCycleDay {
+		// The first period is a full cycle
+		return
+	}
+	first := firstStatementDate(loan)
+
+	loan.OddDaysPolicy = product.OddDaysInterest
+	loan.OddDays = int(first.Sub(disbursed).Hours() / 24)
+	loan.OddDaysInterest = decimal.Zero
+	if loan.OddDaysPolicy == models.OddDaysCharge {
+		interest := decimal.Zero
+		for day := disbursed; day.Before(first); day = day.AddDate(0, 0, 1) {
+			if beforeAccrualStart(loan, day) {
+				continue
+			}
+			interest = interest.Add(DailyInterest(loan.Balance, accrualRate(loan, day)))
+		}
+		loan.OddDaysInterest = interest.Round(2)
+	}
+}
+
+// inOddDaysStub reports whether the day falls in a stub period whose interest is fixed or
+// waived rather than accrued daily.
+func inOddDaysStub(loan *models.Loan, day time.Time) bool {
+	if loan.OddDaysPolicy == "" || loan.OddDays == 0 {
+		return false
+	}
+	stubEnd := loan.CreatedAt.UTC().Truncate(24*time.Hour).AddDate(0, 0, loan.OddDays)
+	return day.Before(stubEnd)
+}
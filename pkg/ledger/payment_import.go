@@ -0,0 +1,154 @@
+package ledger
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// paymentImportColumns are the columns of a payment import file, in order. The reference
+// column may be omitted.
+var paymentImportColumns = []string{"loan_id", "amount", "date", "reference"}
+
+// WithReference records an external reference for a payment, such as a bank file's payment
+// reference.
+func WithReference(reference string) PaymentOption {
+	return func(tx *models.Transaction) {
+		tx.Reference = reference
+	}
+}
+
+// WithPaymentDate records the date a payment was received when it is posted later, as with
+// payments from a bank file. The date sets the payment's timestamp and the loan's last
+// payment date; interest already accrued is not recalculated.
+func WithPaymentDate(date time.Time) PaymentOption {
+	return func(tx *models.Transaction) {
+		tx.Timestamp = date
+	}
+}
+
+// ImportPayments applies a CSV file of payments with the columns loan_id, amount, date
+// (YYYY-MM-DD) and an optional reference. A header row naming the columns may come first.
+// Each row is recorded as a separate payment; a row that cannot be applied is reported as
+// failed and the import moves on to the next. A row whose reference matches a payment already
+// on the loan fails as a duplicate, so a file can safely be imported again. A file that cannot
+// be read as CSV is refused outright, but once rows have been processed a later read error
+// ends the import with the error reported as a failed row, so the report still accounts for
+// the payments already posted.
+func (l *Ledger) ImportPayments(ctx context.Context, r io.Reader) (*models.PaymentImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	report := &models.PaymentImportReport{Rows: []models.PaymentImportRow{}}
+	line := 0
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if len(report.Rows) == 0 {
+				return nil, models.Invalidf("invalid payment file: %v", err)
+			}
+			line++
+			if parseErr := (*csv.ParseError)(nil); errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			report.Rows = append(report.Rows, models.PaymentImportRow{Line: line, Error: fmt.Sprintf("invalid payment file: %v; rows after this one were not read", err)})
+			report.Failed++
+			break
+		}
+		line, _ = reader.FieldPos(0)
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), paymentImportColumns[0]) {
+			continue
+		}
+
+		row := models.PaymentImportRow{Line: line}
+		transaction, err := l.importPayment(ctx, record, &row)
+		if err != nil {
+			row.Error = err.Error()
+			report.Failed++
+		} else {
+			row.TransactionID = &transaction.ID
+			report.Applied++
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	return report, nil
+}
+
+// importPayment records the payment in one row of a payment import file, filling in the
+// row's fields as it parses them.
+func (l *Ledger) importPayment(ctx context.Context, record []string, row *models.PaymentImportRow) (*models.Transaction, error) {
+	fields := make([]string, len(paymentImportColumns))
+	for i := range fields {
+		if i < len(record) {
+			fields[i] = strings.TrimSpace(record[i])
+		}
+	}
+	row.LoanID, row.Amount, row.Date, row.Reference = fields[0], fields[1], fields[2], fields[3]
+	if len(record) < len(paymentImportColumns)-1 || len(record) > len(paymentImportColumns) {
+		return nil, fmt.Errorf("expected %d or %d columns, got %d", len(paymentImportColumns)-1, len(paymentImportColumns), len(record))
+	}
+
+	loanID, err := uuid.Parse(row.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+	amount, err := decimal.NewFromString(row.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount")
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	date, err := time.ParseInLocation("2006-01-02", row.Date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date, expected YYYY-MM-DD")
+	}
+
+	// A payment received today is dated now, so it falls after today's earlier activity
+	now := time.Now()
+	paidAt := date
+	if y, m, d := now.Date(); date.Equal(time.Date(y, m, d, 0, 0, 0, 0, time.Local)) {
+		paidAt = now
+	} else if date.After(now) {
+		return nil, fmt.Errorf("payment date must not be in the future")
+	}
+
+	opts := []PaymentOption{WithSource(models.TransactionSourceImport), WithPaymentDate(paidAt)}
+	if row.Reference != "" {
+		duplicate, err := l.hasPaymentReference(ctx, loanID, row.Reference)
+		if err != nil {
+			return nil, err
+		}
+		if duplicate {
+			return nil, fmt.Errorf("duplicate reference %q", row.Reference)
+		}
+		opts = append(opts, WithReference(row.Reference))
+	}
+	return l.RecordPayment(ctx, loanID, amount, opts...)
+}
+
+// hasPaymentReference reports whether the loan already has a payment with the given reference.
+func (l *Ledger) hasPaymentReference(ctx context.Context, loanID uuid.UUID, reference string) (bool, error) {
+	transactions, err := l.storage.GetTransactionsForLoan(ctx, loanID)
+	if err != nil {
+		return false, err
+	}
+	for _, tx := range transactions {
+		if tx.Reference == reference {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,159 @@
+package ledger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// maxPaymentLinkTTL bounds how long a payment link may stay valid.
+const maxPaymentLinkTTL = 30 * 24 * time.Hour
+
+// SetPaymentLinkSecret sets the key used to sign payment link tokens. Payment links cannot
+// be created or redeemed until a secret is set; changing it invalidates outstanding links.
+func (l *Ledger) SetPaymentLinkSecret(secret []byte) {
+	l.paymentLinkSecret = secret
+}
+
+// signPaymentLink computes the signature binding a link's ID to its expiry.
+func (l *Ledger) signPaymentLink(id uuid.UUID, expiresAt time.Time) []byte {
+	mac := hmac.New(sha256.New, l.paymentLinkSecret)
+	mac.Write([]byte(id.String() + "|" + strconv.FormatInt(expiresAt.Unix(), 10)))
+	return mac.Sum(nil)
+}
+
+// paymentLinkToken encodes a link as "<link id>.<signature>". The token identifies only the
+// link, never the loan.
+func (l *Ledger) paymentLinkToken(link *models.PaymentLink) string {
+	return base64.RawURLEncoding.EncodeToString(link.ID[:]) + "." + base64.RawURLEncoding.EncodeToString(l.signPaymentLink(link.ID, link.ExpiresAt))
+}
+
+// CreatePaymentLink issues a single-use link allowing a payment between minAmount and
+// maxAmount on the loan until it expires after ttl. It returns the link and its signed token.
+func (l *Ledger) CreatePaymentLink(ctx context.Context, loanID uuid.UUID, minAmount decimal.Decimal, maxAmount decimal.Decimal, ttl time.Duration) (*models.PaymentLink, string, error) {
+	if len(l.paymentLinkSecret) == 0 {
+		return nil, "", models.ErrPaymentLinksNotConfigured
+	}
+	if !minAmount.IsPositive() || maxAmount.LessThan(minAmount) {
+		return nil, "", models.Invalidf("payment link amount range is invalid")
+	}
+	if ttl <= 0 || ttl > maxPaymentLinkTTL {
+		return nil, "", models.Invalidf("payment link lifetime must be between 0 and %d days", int(maxPaymentLinkTTL.Hours()/24))
+	}
+
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !loan.Status.IsOpen() {
+		return nil, "", models.ErrLoanNotActive
+	}
+
+	now := time.Now()
+	link := &models.PaymentLink{
+		ID:        uuid.New(),
+		LoanID:    loan.ID,
+		MinAmount: minAmount,
+		MaxAmount: maxAmount,
+		Status:    models.PaymentLinkActive,
+		ExpiresAt: now.Add(ttl).Truncate(time.Second),
+		CreatedAt: now,
+	}
+	if err := l.storage.CreatePaymentLink(ctx, link); err != nil {
+		return nil, "", fmt.Errorf("failed to store payment link: %w", err)
+	}
+	return link, l.paymentLinkToken(link), nil
+}
+
+// ValidatePaymentLink verifies a token's signature and returns its link. It does not check
+// whether the link has expired or been used.
+func (l *Ledger) ValidatePaymentLink(ctx context.Context, token string) (*models.PaymentLink, error) {
+	if len(l.paymentLinkSecret) == 0 {
+		return nil, models.ErrPaymentLinksNotConfigured
+	}
+
+	idPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, models.ErrInvalidPaymentLink
+	}
+	idBytes, err := base64.RawURLEncoding.DecodeString(idPart)
+	if err != nil {
+		return nil, models.ErrInvalidPaymentLink
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return nil, models.ErrInvalidPaymentLink
+	}
+	id, err := uuid.FromBytes(idBytes)
+	if err != nil {
+		return nil, models.ErrInvalidPaymentLink
+	}
+
+	link, err := l.storage.GetPaymentLink(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrPaymentLinkNotFound) {
+			return nil, models.ErrInvalidPaymentLink
+		}
+		return nil, err
+	}
+	if !hmac.Equal(sig, l.signPaymentLink(link.ID, link.ExpiresAt)) {
+		return nil, models.ErrInvalidPaymentLink
+	}
+	return link, nil
+}
+
+// RedeemPaymentLink records a payment made through a payment link. The amount must fall
+// within the link's range, and the link must be unexpired and unused.
+func (l *Ledger) RedeemPaymentLink(ctx context.Context, token string, amount decimal.Decimal, opts ...PaymentOption) (*models.Transaction, error) {
+	link, err := l.ValidatePaymentLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if link.Status == models.PaymentLinkRedeemed {
+		return nil, models.ErrPaymentLinkRedeemed
+	}
+	if !time.Now().Before(link.ExpiresAt) {
+		return nil, models.ErrPaymentLinkExpired
+	}
+	if amount.LessThan(link.MinAmount) || amount.GreaterThan(link.MaxAmount) {
+		return nil, models.ErrPaymentLinkAmount
+	}
+
+	// The link is claimed in the payment's transaction, so that of two deliveries racing to
+	// redeem it only one posts a payment, and a payment never posts without the claim
+	opts = append([]PaymentOption{WithSource(models.TransactionSourcePaymentLink)}, opts...)
+	var transaction *models.Transaction
+	err = l.inTransaction(ctx, func(ctx context.Context, tl *Ledger) error {
+		var err error
+		transaction, err = tl.RecordPayment(ctx, link.LoanID, amount, opts...)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		link.Status = models.PaymentLinkRedeemed
+		link.TransactionID = &transaction.ID
+		link.RedeemedAt = &now
+		if err := tl.storage.RedeemPaymentLink(ctx, link); err != nil {
+			if errors.Is(err, models.ErrPaymentLinkRedeemed) {
+				return err
+			}
+			return fmt.Errorf("failed to mark payment link redeemed: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
@@ -0,0 +1,124 @@
+package ledger
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// PaymentOption customizes a payment before it is recorded by RecordPayment.
+type PaymentOption func(*models.Transaction)
+
+// WithPaymentMethod records which payment method funded the payment.
+func WithPaymentMethod(id uuid.UUID) PaymentOption {
+	return func(tx *models.Transaction) {
+		tx.PaymentMethodID = &id
+	}
+}
+
+// looksLikeAccountNumber reports whether a token appears to be a raw card or account number
+// rather than a processor token. Such values must never be stored.
+func looksLikeAccountNumber(token string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(token)
+	if len(digits) < 8 {
+		return false
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// AddPaymentMethod registers a tokenized payment method for a customer. New methods start
+// out pending verification.
+func (l *Ledger) AddPaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	if method.CustomerKey == "" {
+		return models.Invalidf("customer key is required")
+	}
+	if method.Type != models.PaymentMethodCard && method.Type != models.PaymentMethodBankAccount {
+		return models.Invalidf("invalid payment method type")
+	}
+	if method.Token == "" {
+		return models.Invalidf("payment method token is required")
+	}
+	if looksLikeAccountNumber(method.Token) {
+		return models.Invalidf("payment method token must be a processor token, not a raw account number")
+	}
+	if len(method.Last4) > 4 {
+		return models.Invalidf("last4 must be at most 4 characters")
+	}
+
+	method.ID = uuid.New()
+	method.Status = models.PaymentMethodPending
+	method.CreatedAt = time.Now()
+	method.UpdatedAt = method.CreatedAt
+	return l.storage.CreatePaymentMethod(ctx, method)
+}
+
+// GetPaymentMethod retrieves a payment method by its ID.
+func (l *Ledger) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	return l.storage.GetPaymentMethod(ctx, id)
+}
+
+// GetPaymentMethodsForCustomer retrieves all payment methods registered to a customer.
+func (l *Ledger) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	return l.storage.GetPaymentMethodsForCustomer(ctx, customerKey)
+}
+
+// VerifyPaymentMethod marks a pending payment method as verified, e.g. after a successful
+// micro-deposit or card authorization check.
+func (l *Ledger) VerifyPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	method, err := l.storage.GetPaymentMethod(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if method.Status != models.PaymentMethodPending {
+		return nil, models.ErrPaymentMethodNotPending
+	}
+	return method, l.setPaymentMethodStatus(ctx, method, models.PaymentMethodVerified)
+}
+
+// ExpirePaymentMethod marks a payment method as expired so it can no longer fund payments.
+func (l *Ledger) ExpirePaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	method, err := l.storage.GetPaymentMethod(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if method.Status == models.PaymentMethodExpired {
+		return method, nil
+	}
+	return method, l.setPaymentMethodStatus(ctx, method, models.PaymentMethodExpired)
+}
+
+func (l *Ledger) setPaymentMethodStatus(ctx context.Context, method *models.PaymentMethod, status models.PaymentMethodStatus) error {
+	method.Status = status
+	method.UpdatedAt = time.Now()
+	return l.storage.UpdatePaymentMethod(ctx, method)
+}
+
+// usablePaymentMethod checks that a payment method can fund a payment on the given loan.
+func (l *Ledger) usablePaymentMethod(ctx context.Context, id uuid.UUID, loan *models.Loan) error {
+	method, err := l.storage.GetPaymentMethod(ctx, id)
+	if err != nil {
+		return err
+	}
+	if method.CustomerKey != loan.CustomerKey {
+		return models.ErrPaymentMethodNotCustomers
+	}
+	if method.ExpiresAt != nil && method.ExpiresAt.Before(time.Now()) {
+		return models.ErrPaymentMethodExpired
+	}
+	switch method.Status {
+	case models.PaymentMethodVerified:
+		return nil
+	case models.PaymentMethodExpired:
+		return models.ErrPaymentMethodExpired
+	default:
+		return models.ErrPaymentMethodNotVerified
+	}
+}
@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// PreviewPayment works out how RecordPaymentWithEscrow would apply a payment to the loan right
+// now, with the same checks, without recording anything.
+func (l *Ledger) PreviewPayment(ctx context.Context, loanID uuid.UUID, amount decimal.Decimal, escrowAmount decimal.Decimal, opts ...PaymentOption) (*models.PaymentPreview, error) {
+	stored, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if escrowAmount.IsPositive() {
+		if _, err := l.escrowLoan(ctx, loanID); err != nil {
+			return nil, err
+		}
+		if !escrowAmount.LessThan(amount) {
+			return nil, models.ErrEscrowExceedsPayment
+		}
+		amount = amount.Sub(escrowAmount)
+	} else {
+		escrowAmount = decimal.Zero
+	}
+
+	transaction := &models.Transaction{LoanID: loanID, Amount: amount}
+	for _, opt := range opts {
+		opt(transaction)
+	}
+	transactionType, paidAt, err := l.preparePayment(ctx, stored, transaction, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the payment to a copy so the stored loan is untouched
+	loan := *stored
+	if transactionType == models.TransactionTypePayment {
+		if _, err := l.billPayoffInterest(ctx, &loan, amount, paidAt); err != nil {
+			return nil, err
+		}
+	}
+	allocation := applyPayment(&loan, amount, transactionType, paidAt)
+	return &models.PaymentPreview{
+		LoanID:            loanID,
+		Amount:            amount,
+		EscrowAmount:      escrowAmount,
+		Type:              transactionType,
+		FeesPaid:          allocation.fees,
+		InterestPaid:      allocation.interest,
+		PrepaymentPenalty: allocation.penalty,
+		PrincipalPaid:     allocation.principal,
+		Unapplied:         allocation.unapplied,
+		Balance:           loan.Balance,
+		FeesDue:           loan.FeesDue,
+		InterestDue:       loan.InterestDue,
+		EscrowBalance:     loan.EscrowBalance.Add(escrowAmount),
+		Status:            loan.Status,
+	}, nil
+}
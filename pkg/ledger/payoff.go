@@ -0,0 +1,117 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// GetPayoffQuote computes the amount that pays the loan off in full on the given date:
+// the balance, interest accrued through that date at the rates in effect each day, and any
+// prepayment penalty. For balloon loans the quote also shows the balloon due at maturity.
+func (l *Ledger) GetPayoffQuote(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.PayoffQuote, error) {
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	if !loan.Status.IsOpen() {
+		return nil, models.ErrLoanNotActive
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	date = date.UTC().Truncate(24 * time.Hour)
+	if date.Before(today) {
+		return nil, models.Invalidf("payoff date must not be in the past")
+	}
+
+	forbearances, err := l.storage.GetForbearancesForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	accrued := l.interestThrough(loan, forbearances, date).Add(loan.InterestDue)
+
+	penalty := prepaymentPenalty(loan, loan.Balance, date)
+	quote := &models.PayoffQuote{
+		LoanID:            loan.ID,
+		GoodThrough:       date,
+		Principal:         loan.Balance,
+		AccruedInterest:   accrued,
+		PerDiem:           DailyInterest(loan.Balance, accrualRate(loan, date.AddDate(0, 0, 1))).Round(2),
+		PrepaymentPenalty: penalty,
+		Fees:              penalty.Add(loan.FeesDue),
+	}
+	quote.Total = quote.Principal.Add(quote.AccruedInterest).Add(quote.Fees)
+
+	if loan.TermMonths > 0 && amortizationMonths(loan) > loan.TermMonths {
+		schedule, err := buildSchedule(loan)
+		if err != nil {
+			return nil, err
+		}
+		quote.BalloonAmount = &schedule.BalloonAmount
+		quote.MaturityDate = &schedule.MaturityDate
+	}
+
+	return quote, nil
+}
+
+// interestThrough returns the loan's accrued interest together with the interest it will
+// accrue from the first day not yet accrued through date, rounding each day as the daily
+// batch will, to the cent.
+func (l *Ledger) interestThrough(loan *models.Loan, forbearances []*models.Forbearance, date time.Time) decimal.Decimal {
+	accrued := loan.AccruedInterest
+	projected := *loan
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	if loan.LastInterestCalculationDate != nil {
+		day = loan.LastInterestCalculationDate.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	}
+	for ; !day.After(date); day = day.AddDate(0, 0, 1) {
+		if loan.OddDaysPolicy == models.OddDaysWaive && inOddDaysStub(loan, day) || beforeAccrualStart(loan, day) {
+			continue
+		}
+		rate := accrualRate(loan, day)
+		if forbearance := forbearanceOn(forbearances, day); forbearance != nil {
+			rate = forbearance.Rate
+		}
+		accrued = accrued.Add(roundAccrual(l.rounding, &projected, DailyInterest(loan.Balance, rate)))
+	}
+	return accrued.Round(2)
+}
+
+// billPayoffInterest bills the interest accrued through the day of a payment that pays the
+// loan off, as its payoff quote does, so the payment settles it before principal. It returns
+// the interest transaction posting the amount billed, or nil if the payment does not pay the
+// loan off or there is no interest to bill.
+func (l *Ledger) billPayoffInterest(ctx context.Context, loan *models.Loan, amount decimal.Decimal, paidAt time.Time) (*models.Transaction, error) {
+	forbearances, err := l.storage.GetForbearancesForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+	day := paidAt.UTC().Truncate(24 * time.Hour)
+	interest := l.interestThrough(loan, forbearances, day)
+
+	payoff := loan.Balance.Add(amountsDue(loan)).Add(interest).Add(prepaymentPenalty(loan, loan.Balance, paidAt))
+	if amount.LessThan(payoff) {
+		return nil, nil
+	}
+
+	loan.InterestDue = loan.InterestDue.Add(interest)
+	loan.AccruedInterest = decimal.Zero
+	loan.InterestResidual = decimal.Zero
+	if loan.LastInterestCalculationDate == nil || day.After(*loan.LastInterestCalculationDate) {
+		loan.LastInterestCalculationDate = &day
+	}
+	if !interest.IsPositive() {
+		return nil, nil
+	}
+	return &models.Transaction{
+		ID:        uuid.New(),
+		LoanID:    loan.ID,
+		Amount:    interest,
+		Type:      models.TransactionTypeInterest,
+		Timestamp: paidAt,
+	}, nil
+}
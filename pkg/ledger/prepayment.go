@@ -0,0 +1,59 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithPrepaymentPenalty charges rate times the prepaid principal on payments made within the
+// first months after origination.
+func WithPrepaymentPenalty(rate decimal.Decimal, months int) LoanOption {
+	return func(loan *models.Loan) {
+		loan.PrepaymentPenaltyRate = rate
+		loan.PrepaymentPenaltyMonths = months
+	}
+}
+
+// validatePrepaymentPenalty checks that a loan's prepayment penalty rule is well formed.
+func validatePrepaymentPenalty(loan *models.Loan) error {
+	if loan.PrepaymentPenaltyRate.IsNegative() || loan.PrepaymentPenaltyRate.GreaterThan(one) {
+		return models.Invalidf("prepayment penalty rate must be between 0 and 1")
+	}
+	if loan.PrepaymentPenaltyMonths < 0 {
+		return models.Invalidf("prepayment penalty months must not be negative")
+	}
+	return nil
+}
+
+// prepaidPrincipal returns the part of a payment that repays principal ahead of schedule:
+// anything above the scheduled monthly payment for term loans, or the whole balance when
+// an open-ended loan is paid off.
+func prepaidPrincipal(loan *models.Loan, amount decimal.Decimal) decimal.Decimal {
+	applied := decimal.Min(amount, loan.Balance)
+	if loan.TermMonths <= 0 {
+		if amount.GreaterThanOrEqual(loan.Balance) {
+			return applied
+		}
+		return decimal.Zero
+	}
+
+	scheduled := MonthlyPayment(loan.Principal, loan.InterestRate, amortizationMonths(loan))
+	if !applied.GreaterThan(scheduled) {
+		return decimal.Zero
+	}
+	return applied.Sub(scheduled)
+}
+
+// prepaymentPenalty returns the penalty owed on a payment made at the given time, or zero if
+// the loan has no penalty or the penalty period has passed.
+func prepaymentPenalty(loan *models.Loan, amount decimal.Decimal, at time.Time) decimal.Decimal {
+	if !loan.PrepaymentPenaltyRate.IsPositive() || loan.PrepaymentPenaltyMonths <= 0 {
+		return decimal.Zero
+	}
+	if !at.Before(loan.CreatedAt.AddDate(0, loan.PrepaymentPenaltyMonths, 0)) {
+		return decimal.Zero
+	}
+	return prepaidPrincipal(loan, amount).Mul(loan.PrepaymentPenaltyRate).Round(2)
+}
@@ -0,0 +1,65 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// CreateProduct registers a new loan product.
+func (l *Ledger) CreateProduct(ctx context.Context, product *models.Product) error {
+	if product.Code == "" {
+		return models.Invalidf("product code is required")
+	}
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	product.CreatedAt = time.Now()
+	product.UpdatedAt = product.CreatedAt
+	return l.storage.CreateProduct(ctx, product)
+}
+
+// validateProduct checks a product's servicing terms.
+func validateProduct(product *models.Product) error {
+	if !product.OddDaysInterest.Valid() {
+		return models.Invalidf("invalid odd-days interest policy %q", product.OddDaysInterest)
+	}
+	if product.AccrualGraceDays < 0 {
+		return models.Invalidf("invalid accrual grace period: must not be negative")
+	}
+	return validateMinimumPayment(models.MinimumPaymentPolicy{Floor: product.MinimumPaymentFloor, Percent: product.MinimumPaymentPercent})
+}
+
+// GetProduct retrieves a product by its code.
+func (l *Ledger) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	return l.storage.GetProduct(ctx, code)
+}
+
+// GetAllProducts retrieves all products.
+func (l *Ledger) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	return l.storage.GetAllProducts(ctx)
+}
+
+// UpdateProduct updates an existing product's terms.
+func (l *Ledger) UpdateProduct(ctx context.Context, product *models.Product) error {
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	product.UpdatedAt = time.Now()
+	return l.storage.UpdateProduct(ctx, product)
+}
+
+// productsByCode loads every product keyed by code, for batch jobs that need to consult
+// product terms for many loans.
+func (l *Ledger) productsByCode(ctx context.Context) (map[string]*models.Product, error) {
+	products, err := l.storage.GetAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]*models.Product, len(products))
+	for _, p := range products {
+		byCode[p.Code] = p
+	}
+	return byCode, nil
+}
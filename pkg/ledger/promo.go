@@ -0,0 +1,55 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithPromo gives the loan an introductory rate that is used for accrual from start through
+// end (inclusive), after which the loan reverts to its effective rate.
+func WithPromo(rate decimal.Decimal, start time.Time, end time.Time) LoanOption {
+	return func(loan *models.Loan) {
+		start = start.UTC().Truncate(24 * time.Hour)
+		end = end.UTC().Truncate(24 * time.Hour)
+		loan.PromoRate = rate
+		loan.PromoStartDate = &start
+		loan.PromoEndDate = &end
+	}
+}
+
+// validatePromo checks that a loan's promo window, if any, is well formed.
+func validatePromo(loan *models.Loan) error {
+	if loan.PromoStartDate == nil && loan.PromoEndDate == nil {
+		return nil
+	}
+	if loan.PromoStartDate == nil || loan.PromoEndDate == nil {
+		return models.Invalidf("promo requires both a start and end date")
+	}
+	if loan.PromoEndDate.Before(*loan.PromoStartDate) {
+		return models.Invalidf("promo end date must not be before start date")
+	}
+	if loan.PromoRate.IsNegative() {
+		return models.Invalidf("promo rate must not be negative")
+	}
+	return nil
+}
+
+// promoActive reports whether the loan's promo window covers the given day.
+func promoActive(loan *models.Loan, day time.Time) bool {
+	if loan.PromoStartDate == nil || loan.PromoEndDate == nil {
+		return false
+	}
+	day = day.UTC().Truncate(24 * time.Hour)
+	return !day.Before(loan.PromoStartDate.UTC().Truncate(24*time.Hour)) && !day.After(loan.PromoEndDate.UTC().Truncate(24*time.Hour))
+}
+
+// accrualRate returns the APR used to accrue interest on the given day: the promo rate
+// during the promo window, otherwise the loan's effective rate.
+func accrualRate(loan *models.Loan, day time.Time) decimal.Decimal {
+	if promoActive(loan, day) {
+		return loan.PromoRate
+	}
+	return loan.InterestRate
+}
@@ -0,0 +1,124 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// maxInterestRate bounds a loan's annual rates (1 is 100% APR).
+var maxInterestRate = one
+
+// validateRates requires the loan's base, effective and promo rates to be between 0 and
+// maxInterestRate, and its variance to be no further from zero than that.
+func validateRates(loan *models.Loan) error {
+	rates := []struct {
+		name string
+		rate decimal.Decimal
+	}{
+		{"base interest rate", loan.BaseInterestRate},
+		{"interest rate", loan.InterestRate},
+		{"promo rate", loan.PromoRate},
+	}
+	for _, r := range rates {
+		if r.rate.IsNegative() || r.rate.GreaterThan(maxInterestRate) {
+			return models.Invalidf("%s must be between 0 and %s", r.name, maxInterestRate)
+		}
+	}
+	if loan.InterestRateVariance.Abs().GreaterThan(maxInterestRate) {
+		return models.Invalidf("interest rate variance must be between -%s and %s", maxInterestRate, maxInterestRate)
+	}
+	return nil
+}
+
+// recordRateChange appends a change to the loan's rate history and notes it on the timeline.
+func (l *Ledger) recordRateChange(ctx context.Context, loan *models.Loan, baseRate decimal.Decimal, variance decimal.Decimal, effective time.Time) (*models.RateChange, error) {
+	change := &models.RateChange{
+		ID:                   uuid.New(),
+		LoanID:               loan.ID,
+		BaseInterestRate:     baseRate,
+		InterestRateVariance: variance,
+		InterestRate:         baseRate.Add(variance),
+		EffectiveDate:        effective,
+		CreatedAt:            time.Now(),
+	}
+	if err := l.storage.CreateRateChange(ctx, change); err != nil {
+		return nil, fmt.Errorf("failed to store rate change: %w", err)
+	}
+
+	description := fmt.Sprintf("Interest rate changed from %s to %s effective %s", loan.InterestRate, change.InterestRate, effective.Format("2006-01-02"))
+	if _, err := l.recordEvent(ctx, loan.ID, models.LoanEventRateChange, systemAuthor, description); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// ChangeRate schedules a change to a loan's pricing that takes effect on the given date.
+// Changes effective today or earlier are applied to the loan immediately; future-dated
+// changes are picked up by the daily accrual once they come into effect. The effective
+// date may not precede interest that has already been accrued.
+func (l *Ledger) ChangeRate(ctx context.Context, loanID uuid.UUID, baseRate decimal.Decimal, variance decimal.Decimal, effective time.Time) (*models.RateChange, error) {
+	effective = effective.UTC().Truncate(24 * time.Hour)
+	var change *models.RateChange
+	err := l.changeLoan(ctx, loanID, func(ctx context.Context, tl *Ledger, loan *models.Loan) error {
+		if !loan.Status.IsOpen() {
+			return models.ErrLoanNotActive
+		}
+		if loan.LastInterestCalculationDate != nil && !effective.After(loan.LastInterestCalculationDate.UTC().Truncate(24*time.Hour)) {
+			return models.ErrRateChangeBeforeAccrual
+		}
+
+		var err error
+		if change, err = tl.recordRateChange(ctx, loan, baseRate, variance, effective); err != nil {
+			return err
+		}
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		if effective.After(today) {
+			return nil
+		}
+		applyRateChange(loan, change)
+		loan.UpdatedAt = time.Now()
+		if err := tl.storage.UpdateLoanIfVersion(ctx, loan, loan.Version); err != nil {
+			return fmt.Errorf("failed to apply rate change: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// GetRateHistory retrieves a loan's rate changes ordered by effective date.
+func (l *Ledger) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetRateHistory(ctx, loanID)
+}
+
+// applyRateChange copies a rate change's pricing onto the loan.
+func applyRateChange(loan *models.Loan, change *models.RateChange) {
+	loan.BaseInterestRate = change.BaseInterestRate
+	loan.InterestRateVariance = change.InterestRateVariance
+	loan.InterestRate = change.InterestRate
+}
+
+// rateInEffect brings the loan's pricing up to date with its rate history for the given
+// accrual day and reports whether the loan was changed.
+func (l *Ledger) rateInEffect(ctx context.Context, loan *models.Loan, day time.Time) (bool, error) {
+	change, err := l.storage.GetRateInEffect(ctx, loan.ID, day)
+	if err != nil {
+		return false, err
+	}
+	if change == nil || change.InterestRate.Equal(loan.InterestRate) && change.BaseInterestRate.Equal(loan.BaseInterestRate) {
+		return false, nil
+	}
+	applyRateChange(loan, change)
+	return true, nil
+}
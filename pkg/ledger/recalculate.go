@@ -0,0 +1,298 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"github.com/shopspring/decimal"
+)
+
+// RecalculateLoan recomputes a loan from scratch: it replays the loan's transactions and
+// redoes daily accrual through the last accrual date at the rates in its rate history, then
+// compares the result with the stored loan. With commit set, any differences are written to
+// the loan and noted on its timeline.
+//
+// Accrual on each day uses the balance at the start of the day, as the daily batch does when
+// it runs before the day's payments.
+func (l *Ledger) RecalculateLoan(ctx context.Context, loanID uuid.UUID, commit bool) (*models.LoanRecalculation, error) {
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := l.storage.GetTransactionsForLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := l.storage.GetRateHistory(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	forbearances, err := l.storage.GetForbearancesForLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.LoanRecalculation{LoanID: loanID, Before: loan}
+
+	originalRate, err := l.originalRate(ctx, loan, history)
+	if err != nil {
+		return nil, err
+	}
+	if originalRate == nil {
+		result.Warnings = append(result.Warnings, "rate before the first rate change is unknown; the first change's rate was assumed")
+		originalRate = history[0]
+	}
+
+	after := *loan
+	after.Balance = decimal.Zero
+	after.AccruedInterest = decimal.Zero
+	after.ChargeOffAmount = decimal.Zero
+	after.EscrowBalance = decimal.Zero
+	after.FeesDue = decimal.Zero
+	after.InterestDue = decimal.Zero
+	after.InterestResidual = decimal.Zero
+	after.NegativeAmortizationCapped = false
+	after.Status = models.LoanStatusActive
+	after.LastPaymentDate = nil
+	applyRateChange(&after, originalRate)
+
+	replayed := replayLoan(&after, transactions, history, forbearances, originalRate, l.rounding)
+	if replayed != len(transactions) {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("%d transactions could not be replayed", len(transactions)-replayed))
+	}
+	// Delinquency comes from aging rather than transactions, so it survives the replay
+	if after.Status == models.LoanStatusActive && loan.Status == models.LoanStatusDelinquent {
+		after.Status = models.LoanStatusDelinquent
+	}
+	result.After = &after
+	result.Changes = loanChanges(loan, &after)
+
+	if commit && len(result.Changes) > 0 {
+		after.UpdatedAt = time.Now()
+		err := l.updateLoanWithEvent(ctx, &after, models.LedgerLoanAdjusted, decimal.Zero, nil, func(ctx context.Context, s store.Storage) error {
+			if err := s.UpdateLoan(ctx, &after); err != nil {
+				return err
+			}
+			return publishEvents(ctx, s, loanClosedEvents(loan.Status, &after)...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store recalculated loan: %w", err)
+		}
+		fields := make([]string, len(result.Changes))
+		for i, change := range result.Changes {
+			fields[i] = fmt.Sprintf("%s %s -> %s", change.Field, change.Before, change.After)
+		}
+		if _, err := l.recordEvent(ctx, loan.ID, models.LoanEventCorrection, systemAuthor, "Loan recalculated: "+strings.Join(fields, ", ")); err != nil {
+			return nil, err
+		}
+		if err := l.recordStatusChange(ctx, loan.ID, loan.Status, after.Status); err != nil {
+			return nil, err
+		}
+		result.Committed = true
+	}
+	return result, nil
+}
+
+// originalRate returns the loan's pricing before its first rate change. Rate history only
+// records changes, so the original rate is taken from the first rate change's timeline event.
+// It returns nil if that cannot be determined.
+func (l *Ledger) originalRate(ctx context.Context, loan *models.Loan, history []*models.RateChange) (*models.RateChange, error) {
+	if len(history) == 0 {
+		return &models.RateChange{
+			BaseInterestRate:     loan.BaseInterestRate,
+			InterestRateVariance: loan.InterestRateVariance,
+			InterestRate:         loan.InterestRate,
+		}, nil
+	}
+
+	events, err := l.storage.GetLoanEventsForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range events {
+		if event.Type != models.LoanEventRateChange {
+			continue
+		}
+		var from, to, effective string
+		if _, err := fmt.Sscanf(event.Description, "Interest rate changed from %s to %s effective %s", &from, &to, &effective); err != nil {
+			return nil, nil
+		}
+		rate, err := decimal.NewFromString(from)
+		if err != nil {
+			return nil, nil
+		}
+		// The split between base rate and variance is not recorded; assume the loan's current
+		// split if the rate matches, otherwise keep it all in the base rate
+		if rate.Equal(loan.InterestRate) {
+			return &models.RateChange{BaseInterestRate: loan.BaseInterestRate, InterestRateVariance: loan.InterestRateVariance, InterestRate: rate}, nil
+		}
+		return &models.RateChange{BaseInterestRate: rate, InterestRateVariance: decimal.Zero, InterestRate: rate}, nil
+	}
+	return nil, nil
+}
+
+// replayLoan rebuilds the loan's balances from its transactions, accruing daily interest
+// from disbursement through the loan's last accrual date under the current rounding policy.
+// It returns how many transactions were applied.
+func replayLoan(loan *models.Loan, transactions []*models.Transaction, history []*models.RateChange, forbearances []*models.Forbearance, originalRate *models.RateChange, rounding models.RoundingPolicy) int {
+	rateOn := func(day time.Time) *models.RateChange {
+		rate := originalRate
+		for _, change := range history {
+			if !change.EffectiveDate.After(day) {
+				rate = change
+			}
+		}
+		return rate
+	}
+
+	day := loan.CreatedAt.UTC().Truncate(24 * time.Hour)
+	last := day
+	if loan.LastInterestCalculationDate != nil {
+		last = loan.LastInterestCalculationDate.UTC().Truncate(24 * time.Hour)
+	}
+	if len(transactions) > 0 {
+		if txDay := transactions[len(transactions)-1].Timestamp.UTC().Truncate(24 * time.Hour); txDay.After(last) {
+			last = txDay
+		}
+	}
+	accrueThrough := day.AddDate(0, 0, -1)
+	if loan.LastInterestCalculationDate != nil {
+		accrueThrough = loan.LastInterestCalculationDate.UTC().Truncate(24 * time.Hour)
+	}
+
+	applied := 0
+	accrued := false
+	next := 0
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		dayEnd := day.AddDate(0, 0, 1)
+
+		// Disbursements fund the loan before the day's accrual
+		for i := next; i < len(transactions) && transactions[i].Timestamp.Before(dayEnd); i++ {
+			if transactions[i].Type == models.TransactionTypeDisbursement {
+				loan.Balance = loan.Balance.Add(transactions[i].Amount)
+			}
+		}
+
+		if loan.Status.IsOpen() && !day.After(accrueThrough) && !inOddDaysStub(loan, day) && !beforeAccrualStart(loan, day) {
+			applyRateChange(loan, rateOn(day))
+			rate := accrualRate(loan, day)
+			if forbearance := forbearanceOn(forbearances, day); forbearance != nil {
+				rate = forbearance.Rate
+			}
+			interest := DailyInterest(loan.Balance, rate)
+			if loan.OddDaysPolicy == models.OddDaysCharge && !accrued {
+				interest = interest.Add(loan.OddDaysInterest)
+			}
+			loan.AccruedInterest = loan.AccruedInterest.Add(roundAccrual(rounding, loan, interest))
+			accrued = true
+		}
+
+		for ; next < len(transactions) && transactions[next].Timestamp.Before(dayEnd); next++ {
+			if applyReplayedTransaction(loan, transactions[next], rounding) {
+				applied++
+			}
+		}
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	applyRateChange(loan, rateOn(today))
+	return applied
+}
+
+// applyReplayedTransaction applies one transaction's effect on the loan, mirroring the ledger
+// operation that recorded it. It reports whether the transaction type is known.
+func applyReplayedTransaction(loan *models.Loan, tx *models.Transaction, rounding models.RoundingPolicy) bool {
+	switch tx.Type {
+	case models.TransactionTypeDisbursement:
+		// Applied at the start of its day
+	case models.TransactionTypeInterest:
+		applyInterest(loan, tx.Amount)
+		settleAccruedInterest(rounding, loan, tx.Amount)
+	case models.TransactionTypeFee:
+		loan.Balance = loan.Balance.Add(tx.Amount)
+	case models.TransactionTypeOriginationFee, models.TransactionTypeServicingFee:
+		if tx.Capitalized {
+			loan.Balance = loan.Balance.Add(tx.Amount)
+		} else {
+			loan.FeesDue = loan.FeesDue.Add(tx.Amount)
+		}
+	case models.TransactionTypePayment, models.TransactionTypeRecovery:
+		loan.Balance = loan.Balance.Sub(applyToAmountsDue(loan, tx.Amount))
+		if tx.Type == models.TransactionTypePayment {
+			timestamp := tx.Timestamp
+			loan.LastPaymentDate = &timestamp
+		}
+		if loan.Balance.LessThanOrEqual(decimal.Zero) {
+			if loan.LoanType != models.LoanTypeLineOfCredit {
+				loan.Status = models.LoanStatusClosed
+			}
+			loan.Balance = decimal.Zero
+		}
+	case models.TransactionTypeChargeOff:
+		loan.Balance = loan.Balance.Add(loan.AccruedInterest).Add(amountsDue(loan))
+		loan.AccruedInterest = decimal.Zero
+		loan.FeesDue = decimal.Zero
+		loan.InterestDue = decimal.Zero
+		loan.ChargeOffAmount = loan.Balance
+		loan.Status = models.LoanStatusChargedOff
+	case models.TransactionTypeEscrowCredit:
+		loan.EscrowBalance = loan.EscrowBalance.Add(tx.Amount)
+	case models.TransactionTypeEscrowDebit:
+		loan.EscrowBalance = loan.EscrowBalance.Sub(tx.Amount)
+	case models.TransactionTypeRefinance:
+		loan.Balance = decimal.Zero
+		loan.AccruedInterest = decimal.Zero
+		loan.Status = models.LoanStatusClosed
+	default:
+		return false
+	}
+	return true
+}
+
+// loanChanges lists the fields the recalculation changes.
+func loanChanges(before *models.Loan, after *models.Loan) []models.FieldChange {
+	changes := []models.FieldChange{}
+	decimals := []struct {
+		field         string
+		before, after decimal.Decimal
+	}{
+		{"balance", before.Balance, after.Balance},
+		{"accrued_interest", before.AccruedInterest, after.AccruedInterest},
+		{"interest_residual", before.InterestResidual, after.InterestResidual},
+		{"charge_off_amount", before.ChargeOffAmount, after.ChargeOffAmount},
+		{"escrow_balance", before.EscrowBalance, after.EscrowBalance},
+		{"fees_due", before.FeesDue, after.FeesDue},
+		{"interest_due", before.InterestDue, after.InterestDue},
+		{"base_interest_rate", before.BaseInterestRate, after.BaseInterestRate},
+		{"interest_rate_variance", before.InterestRateVariance, after.InterestRateVariance},
+		{"interest_rate", before.InterestRate, after.InterestRate},
+	}
+	for _, d := range decimals {
+		if !d.before.Equal(d.after) {
+			changes = append(changes, models.FieldChange{Field: d.field, Before: d.before.String(), After: d.after.String()})
+		}
+	}
+	if before.Status != after.Status {
+		changes = append(changes, models.FieldChange{Field: "status", Before: string(before.Status), After: string(after.Status)})
+	}
+	if before.NegativeAmortizationCapped != after.NegativeAmortizationCapped {
+		changes = append(changes, models.FieldChange{Field: "negative_amortization_capped", Before: strconv.FormatBool(before.NegativeAmortizationCapped), After: strconv.FormatBool(after.NegativeAmortizationCapped)})
+	}
+	// Payment dates drive delinquency by day, so they are compared by day
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02")
+	}
+	if formatTime(before.LastPaymentDate) != formatTime(after.LastPaymentDate) {
+		changes = append(changes, models.FieldChange{Field: "last_payment_date", Before: formatTime(before.LastPaymentDate), After: formatTime(after.LastPaymentDate)})
+	}
+	return changes
+}
@@ -0,0 +1,87 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// WithTerm sets the contractual term of the loan in months.
+func WithTerm(months int) LoanOption {
+	return func(loan *models.Loan) {
+		loan.TermMonths = months
+	}
+}
+
+// withRefinancedFrom links a new loan to the loan it refinances.
+func withRefinancedFrom(id uuid.UUID) LoanOption {
+	return func(loan *models.Loan) {
+		loan.RefinancedFrom = &id
+	}
+}
+
+// RefinanceLoan closes an active loan and originates a replacement for the same customer and
+// product with a new rate and term. The old loan's balance plus accrued interest and amounts due
+// becomes the principal of the new loan, which records the old loan's ID in RefinancedFrom.
+// The new loan, the old loan's closing and the refinance transaction are committed together.
+func (l *Ledger) RefinanceLoan(ctx context.Context, id uuid.UUID, baseRate decimal.Decimal, variance decimal.Decimal, termMonths int) (*models.Loan, error) {
+	var refinanced *models.Loan
+	err := l.inTransaction(ctx, func(ctx context.Context, tl *Ledger) error {
+		old, err := tl.storage.GetLoan(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if !old.Status.IsOpen() {
+			return models.ErrLoanNotActive
+		}
+
+		payoff := old.Balance.Add(old.AccruedInterest).Add(amountsDue(old))
+		if !payoff.GreaterThan(decimal.Zero) {
+			return models.ErrNoBalanceToRefinance
+		}
+
+		opts := []LoanOption{WithTerm(termMonths), withRefinancedFrom(old.ID)}
+		if old.ProductCode != "" {
+			opts = append(opts, WithProduct(old.ProductCode))
+		}
+		refinanced, err = tl.CreateLoan(ctx, old.CustomerKey, payoff, baseRate, variance, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to create refinanced loan: %w", err)
+		}
+
+		now := time.Now()
+		previousStatus := old.Status
+		old.Balance = decimal.Zero
+		old.AccruedInterest = decimal.Zero
+		old.FeesDue = decimal.Zero
+		old.InterestDue = decimal.Zero
+		old.Status = models.LoanStatusClosed
+		old.UpdatedAt = now
+
+		transaction := &models.Transaction{
+			ID:        uuid.New(),
+			LoanID:    old.ID,
+			Amount:    payoff,
+			Type:      models.TransactionTypeRefinance,
+			Timestamp: now,
+		}
+		closed := newWebhookEvent(models.WebhookLoanClosed, old)
+		if err := tl.updateLoanPublishing(ctx, old, []*models.WebhookEvent{closed}, transaction); err != nil {
+			return fmt.Errorf("failed to close refinanced loan: %w", err)
+		}
+		if err := tl.recordStatusChange(ctx, old.ID, previousStatus, old.Status); err != nil {
+			return err
+		}
+		_, err = tl.recordEvent(ctx, old.ID, models.LoanEventNote, systemAuthor, fmt.Sprintf("Refinanced into loan %s", refinanced.ID))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refinanced, nil
+}
@@ -0,0 +1,122 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+	"github.com/shopspring/decimal"
+)
+
+// Granularity is the period each point of a portfolio history covers.
+type Granularity string
+
+const (
+	GranularityDaily   Granularity = "daily"
+	GranularityWeekly  Granularity = "weekly"
+	GranularityMonthly Granularity = "monthly"
+)
+
+// SetReportReader routes the ledger's reporting queries to r, typically a read replica, so
+// they do not contend with payment writes on the primary: the portfolio snapshot and history,
+// the credit bureau file and the loan export. Their results then lag the primary by as much
+// as the replica does. Everything else, including the reads that a write depends on, stays
+// on the primary. nil sends reporting queries back to the primary.
+func (l *Ledger) SetReportReader(r store.Reader) {
+	l.reports = r
+}
+
+// reportReader returns the store reporting queries read from.
+func (l *Ledger) reportReader() store.Reader {
+	if l.reports != nil {
+		return l.reports
+	}
+	return l.storage
+}
+
+// TakePortfolioSnapshot records today's portfolio totals, replacing any snapshot already
+// taken today so that the batch can run more than once a day.
+func (l *Ledger) TakePortfolioSnapshot(ctx context.Context) (*models.PortfolioSnapshot, error) {
+	loans, err := l.reportReader().GetAllLoans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loans for portfolio snapshot: %w", err)
+	}
+	loans = withoutVoided(loans)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	snapshot := &models.PortfolioSnapshot{
+		Date:               today,
+		OutstandingBalance: decimal.Zero,
+		AccruedInterest:    decimal.Zero,
+		DelinquencyRate:    decimal.Zero,
+		OriginationVolume:  decimal.Zero,
+		CreatedAt:          time.Now(),
+	}
+	for _, loan := range loans {
+		if loan.CreatedAt.UTC().Truncate(24 * time.Hour).Equal(today) {
+			snapshot.Originations++
+			snapshot.OriginationVolume = snapshot.OriginationVolume.Add(loan.Principal)
+		}
+		if !loan.Status.IsOpen() {
+			continue
+		}
+		snapshot.ActiveLoans++
+		snapshot.OutstandingBalance = snapshot.OutstandingBalance.Add(loan.Balance)
+		snapshot.AccruedInterest = snapshot.AccruedInterest.Add(loan.AccruedInterest)
+		if loan.DaysPastDue > 0 {
+			snapshot.DelinquentLoans++
+		}
+	}
+	if snapshot.ActiveLoans > 0 {
+		snapshot.DelinquencyRate = decimal.NewFromInt(int64(snapshot.DelinquentLoans)).Div(decimal.NewFromInt(int64(snapshot.ActiveLoans))).Round(4)
+	}
+
+	if err := l.storage.SavePortfolioSnapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// GetPortfolioHistory returns the portfolio time series between from and to at the given
+// granularity. Weekly and monthly points report balances and delinquency as of the last
+// snapshot in the period and sum originations across it.
+func (l *Ledger) GetPortfolioHistory(ctx context.Context, granularity Granularity, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	var periodStart func(time.Time) time.Time
+	switch granularity {
+	case GranularityDaily:
+		return l.reportReader().GetPortfolioSnapshots(ctx, from, to)
+	case GranularityWeekly:
+		periodStart = func(d time.Time) time.Time {
+			// Weeks start on Monday
+			return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+		}
+	case GranularityMonthly:
+		periodStart = func(d time.Time) time.Time {
+			return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, time.UTC)
+		}
+	default:
+		return nil, models.Invalidf("invalid granularity %q, expected daily, weekly or monthly", granularity)
+	}
+
+	daily, err := l.reportReader().GetPortfolioSnapshots(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []*models.PortfolioSnapshot
+	var current *models.PortfolioSnapshot
+	for _, day := range daily {
+		start := periodStart(day.Date.UTC())
+		if current == nil || !current.Date.Equal(start) {
+			current = &models.PortfolioSnapshot{Date: start, OriginationVolume: decimal.Zero}
+			history = append(history, current)
+		}
+		originations, volume := current.Originations+day.Originations, current.OriginationVolume.Add(day.OriginationVolume)
+		*current = *day
+		current.Date = start
+		current.Originations, current.OriginationVolume = originations, volume
+	}
+	return history, nil
+}
@@ -0,0 +1,75 @@
+package ledger
+
+import (
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// validateRoundingPolicy checks that a policy names a known mode and rounds accruals to
+// cents or mills.
+func validateRoundingPolicy(policy models.RoundingPolicy) error {
+	switch policy.Mode {
+	case models.RoundingNone:
+		return nil
+	case models.RoundingHalfUp, models.RoundingHalfEven:
+	default:
+		return models.Invalidf("invalid rounding mode: %q", policy.Mode)
+	}
+	if policy.Places != 2 && policy.Places != 3 {
+		return models.Invalidf("invalid rounding places: must be 2 (cents) or 3 (mills)")
+	}
+	return nil
+}
+
+// SetRoundingPolicy sets how interest is rounded at accrual and capitalization. The zero
+// policy keeps full precision.
+func (l *Ledger) SetRoundingPolicy(policy models.RoundingPolicy) error {
+	if err := validateRoundingPolicy(policy); err != nil {
+		return err
+	}
+	l.rounding = policy
+	return nil
+}
+
+// roundMoney rounds an amount to the given places in the policy's mode.
+func roundMoney(policy models.RoundingPolicy, amount decimal.Decimal, places int32) decimal.Decimal {
+	switch policy.Mode {
+	case models.RoundingHalfUp:
+		return amount.Round(places)
+	case models.RoundingHalfEven:
+		return amount.RoundBank(places)
+	}
+	return amount
+}
+
+// roundAccrual rounds a day's interest to the policy's precision. With residual tracking the
+// fraction rounded off the previous accrual is added back first, and the new one is kept on
+// the loan, so rounding never gains or loses more than half a unit over the life of the loan.
+func roundAccrual(policy models.RoundingPolicy, loan *models.Loan, interest decimal.Decimal) decimal.Decimal {
+	if policy.Mode == models.RoundingNone {
+		return interest
+	}
+	if policy.TrackResidual {
+		interest = interest.Add(loan.InterestResidual)
+	}
+	rounded := roundMoney(policy, interest, policy.Places)
+	if policy.TrackResidual {
+		loan.InterestResidual = interest.Sub(rounded)
+	}
+	return rounded
+}
+
+// capitalizedInterest is the amount of a cycle's accrued interest to apply, rounded to cents.
+func capitalizedInterest(policy models.RoundingPolicy, accrued decimal.Decimal) decimal.Decimal {
+	return roundMoney(policy, accrued, 2)
+}
+
+// settleAccruedInterest removes applied interest from the loan's accrued interest. Whatever
+// fraction of a cent rounding left behind is carried to the next cycle when residuals are
+// tracked and written off otherwise.
+func settleAccruedInterest(policy models.RoundingPolicy, loan *models.Loan, applied decimal.Decimal) {
+	loan.AccruedInterest = loan.AccruedInterest.Sub(applied)
+	if policy.Mode != models.RoundingNone && !policy.TrackResidual {
+		loan.AccruedInterest = loan.AccruedInterest.Truncate(2)
+	}
+}
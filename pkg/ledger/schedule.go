@@ -0,0 +1,125 @@
+package ledger
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	monthsInYear = decimal.NewFromInt(12)
+	one          = decimal.NewFromInt(1)
+)
+
+// WithAmortization amortizes the loan's payments over more months than its term, leaving a
+// balloon payment of the unamortized balance due at maturity.
+func WithAmortization(months int) LoanOption {
+	return func(loan *models.Loan) {
+		loan.AmortizationMonths = months
+	}
+}
+
+// amortizationMonths returns the number of months the loan's payments are amortized over.
+func amortizationMonths(loan *models.Loan) int {
+	if loan.AmortizationMonths > 0 {
+		return loan.AmortizationMonths
+	}
+	return loan.TermMonths
+}
+
+// validateAmortization checks that a balloon loan's amortization covers at least its term.
+func validateAmortization(loan *models.Loan) error {
+	if loan.AmortizationMonths == 0 {
+		return nil
+	}
+	if loan.AmortizationMonths < 0 {
+		return models.Invalidf("amortization must not be negative")
+	}
+	if loan.TermMonths == 0 {
+		return models.Invalidf("amortization requires a term")
+	}
+	if loan.AmortizationMonths < loan.TermMonths {
+		return models.Invalidf("amortization must not be shorter than the term")
+	}
+	return nil
+}
+
+// MonthlyPayment returns the level payment, rounded to cents, that fully amortizes principal
+// over the given number of months at an annual rate: P * r / (1 - (1 + r)^-n).
+func MonthlyPayment(principal decimal.Decimal, annualRate decimal.Decimal, months int) decimal.Decimal {
+	if months <= 0 {
+		return decimal.Zero
+	}
+	n := decimal.NewFromInt(int64(months))
+	r := annualRate.Div(monthsInYear)
+	if r.IsZero() {
+		return principal.Div(n).Round(2)
+	}
+	factor := one.Add(r).Pow(n)
+	return principal.Mul(r).Mul(factor).Div(factor.Sub(one)).Round(2)
+}
+
+// GetSchedule builds the contractual amortization schedule of a term loan from its
+// principal and current rate. Payments fall due on the loan's due dates; the last entry
+// retires whatever principal remains, which is the balloon for loans amortized over a
+// longer period than their term.
+func (l *Ledger) GetSchedule(ctx context.Context, loanID uuid.UUID) (*models.AmortizationSchedule, error) {
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	return buildSchedule(loan)
+}
+
+func buildSchedule(loan *models.Loan) (*models.AmortizationSchedule, error) {
+	if loan.TermMonths <= 0 {
+		return nil, models.ErrLoanHasNoTerm
+	}
+
+	amortization := amortizationMonths(loan)
+	payment := MonthlyPayment(loan.Principal, loan.InterestRate, amortization)
+	monthlyRate := loan.InterestRate.Div(monthsInYear)
+	firstStatement := nextStatementDate(loan.CreatedAt, loan.StatementCycleDay)
+
+	schedule := &models.AmortizationSchedule{
+		LoanID:             loan.ID,
+		TermMonths:         loan.TermMonths,
+		AmortizationMonths: amortization,
+		MonthlyPayment:     payment,
+		BalloonAmount:      decimal.Zero,
+	}
+
+	balance := loan.Principal
+	for n := 1; n <= loan.TermMonths; n++ {
+		entry := &models.ScheduleEntry{
+			Number:   n,
+			DueDate:  firstStatement.AddDate(0, n-1, paymentGracePeriodDays),
+			Interest: balance.Mul(monthlyRate).Round(2),
+			Payment:  payment,
+		}
+		entry.Principal = payment.Sub(entry.Interest)
+
+		// The final payment, or one that would overpay, retires the remaining principal
+		if n == loan.TermMonths || entry.Principal.GreaterThan(balance) {
+			entry.Principal = balance
+			entry.Payment = balance.Add(entry.Interest)
+		}
+		balance = balance.Sub(entry.Principal)
+		entry.Balance = balance
+		schedule.Entries = append(schedule.Entries, entry)
+
+		if balance.IsZero() {
+			break
+		}
+	}
+
+	last := schedule.Entries[len(schedule.Entries)-1]
+	schedule.MaturityDate = last.DueDate
+	if amortization > loan.TermMonths {
+		last.Balloon = true
+		schedule.BalloonAmount = last.Payment
+	}
+	return schedule, nil
+}
@@ -0,0 +1,21 @@
+package ledger
+
+import (
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// WithInterestMode selects whether the loan's monthly interest is capitalized into the
+// balance or billed separately as interest due.
+func WithInterestMode(mode models.InterestMode) LoanOption {
+	return func(loan *models.Loan) {
+		loan.InterestMode = mode
+	}
+}
+
+// validateInterestMode rejects unknown interest modes.
+func validateInterestMode(loan *models.Loan) error {
+	if !loan.InterestMode.Valid() {
+		return models.Invalidf("interest mode must be %s or %s, got %q", models.InterestCompound, models.InterestSimple, loan.InterestMode)
+	}
+	return nil
+}
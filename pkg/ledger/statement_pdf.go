@@ -0,0 +1,141 @@
+package ledger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/pdf"
+	"github.com/shopspring/decimal"
+)
+
+// statementTemplate lays out a statement as monospaced text for pdf.WriteText. Lines must fit
+// in pdf.Columns characters.
+var statementTemplate = template.Must(template.New("statement").Funcs(template.FuncMap{
+	"date":     func(t time.Time) string { return t.UTC().Format("Jan 2, 2006") },
+	"money":    func(d decimal.Decimal) string { return d.StringFixed(2) },
+	"describe": statementDescription,
+	"percent": func(d decimal.Decimal) string {
+		return d.Mul(decimal.NewFromInt(100)).String() + "%"
+	},
+	"trunc": func(n int, s string) string {
+		if r := []rune(s); len(r) > n {
+			return string(r[:n])
+		}
+		return s
+	},
+}).Parse(`LOAN STATEMENT
+
+Loan         {{.Loan.ID}}
+Customer     {{.Loan.CustomerKey}}
+Period       {{date .Statement.PeriodStart}} to {{date .Statement.StatementDate}}
+Statement    {{.Statement.Cycle}}, issued {{date .Statement.StatementDate}}
+
+ACCOUNT SUMMARY
+{{if .Previous}}{{printf "  %-36s %16s" "Previous balance" (money .Previous.Balance)}}
+{{end}}{{printf "  %-36s %16s" "Payments" (money .Statement.Payments)}}
+{{printf "  %-36s %16s" "Interest charged" (money .Statement.InterestCharged)}}
+{{printf "  %-36s %16s" "Fees charged" (money .Statement.Fees)}}
+{{printf "  %-36s %16s" "New balance" (money .Statement.Balance)}}
+
+PAYMENT DUE
+{{printf "  %-36s %16s" "Minimum payment due" (money .Statement.MinimumDue)}}
+{{printf "  %-36s %16s" "Payment due date" (date .Statement.DueDate)}}
+{{printf "  %-36s %16s" "Interest rate (APR)" (percent .Loan.InterestRate)}}
+{{- if eq .Statement.OddDaysPolicy "charge"}}
+
+  Interest charged includes {{money .Statement.OddDaysInterest}} for the {{.Statement.OddDays}} days
+  between disbursement and the start of your first full cycle.
+{{- else if eq .Statement.OddDaysPolicy "waive"}}
+
+  Interest for the {{.Statement.OddDays}} days between disbursement and the start of your
+  first full cycle was waived.
+{{- end}}
+
+TRANSACTIONS
+{{printf "  %-12s  %-24s  %14s  %s" "Date" "Description" "Amount" "Reference"}}
+{{range .Transactions}}{{printf "  %-12s  %-24s  %14s  %s" (date .Timestamp) (describe .Type) (money .Amount) (trunc 32 .Reference)}}
+{{else}}  No activity this period.
+{{end}}`))
+
+// statementView is the data statementTemplate renders.
+type statementView struct {
+	Loan         *models.Loan
+	Statement    *models.Statement
+	Previous     *models.Statement // Nil on the first statement
+	Transactions []*models.Transaction
+}
+
+// statementDescription names a transaction type on a statement.
+func statementDescription(t models.TransactionType) string {
+	if entry, ok := borrowerEntries[t]; ok {
+		return entry.payee
+	}
+	name := strings.ReplaceAll(string(t), "_", " ")
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// RenderStatementPDF writes one of a loan's statements to w as a PDF suitable for mailing or
+// download: the cycle's summary, the minimum due and every transaction posted in the cycle.
+func (l *Ledger) RenderStatementPDF(ctx context.Context, w io.Writer, loanID uuid.UUID, statementID uuid.UUID) error {
+	view, err := l.statementView(ctx, loanID, statementID)
+	if err != nil {
+		return err
+	}
+
+	var text bytes.Buffer
+	if err := statementTemplate.Execute(&text, view); err != nil {
+		return fmt.Errorf("failed to render statement: %w", err)
+	}
+	return pdf.WriteText(w, "Statement "+view.Statement.Cycle+" for loan "+loanID.String(), text.String())
+}
+
+// statementView gathers what a statement shows.
+func (l *Ledger) statementView(ctx context.Context, loanID uuid.UUID, statementID uuid.UUID) (*statementView, error) {
+	loan, err := l.storage.GetLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	statements, err := l.storage.GetStatementsForLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &statementView{Loan: loan}
+	for i, statement := range statements {
+		if statement.ID == statementID {
+			view.Statement = statement
+			if i > 0 {
+				view.Previous = statements[i-1]
+			}
+			break
+		}
+	}
+	if view.Statement == nil {
+		return nil, models.ErrStatementNotFound
+	}
+
+	// The cycle covers what posted after the previous statement was issued, as when the
+	// statement's totals were computed.
+	since := loan.CreatedAt
+	if view.Previous != nil {
+		since = view.Previous.CreatedAt
+	}
+	transactions, err := l.storage.GetTransactionsForLoan(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	for _, tx := range transactions {
+		if tx.Timestamp.Before(since) || tx.Timestamp.After(view.Statement.CreatedAt) {
+			continue
+		}
+		view.Transactions = append(view.Transactions, tx)
+	}
+	return view, nil
+}
@@ -0,0 +1,121 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// GenerateStatements issues a statement for every active loan whose statement cycle day is
+// today. It runs after ApplyMonthlyInterest in the daily batch so that each statement shows
+// the interest applied for its cycle. A cycle's statement is only ever issued once.
+func (l *Ledger) GenerateStatements(ctx context.Context) {
+	now := time.Now()
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	err := l.storage.ForEachActiveLoan(ctx, func(loan *models.Loan) error {
+		if loan.StatementCycleDay != now.Day() || today.Before(firstStatementDate(loan)) {
+			return nil
+		}
+		if _, err := l.issueStatement(ctx, loan, statementCycle(now), today); err != nil {
+			fmt.Printf("Error issuing statement for loan %s: %v\n", loan.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error getting active loans for statement generation: %v\n", err)
+	}
+}
+
+// issueStatement summarizes the loan's transactions since its previous statement (or since
+// disbursement). The first statement discloses the odd-days interest of the stub period.
+func (l *Ledger) issueStatement(ctx context.Context, loan *models.Loan, cycle string, statementDate time.Time) (*models.Statement, error) {
+	existing, err := l.storage.GetStatement(ctx, loan.ID, cycle)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	previous, err := l.storage.GetStatementsForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+	since := loan.CreatedAt
+	if len(previous) > 0 {
+		since = previous[len(previous)-1].CreatedAt
+	}
+
+	transactions, err := l.storage.GetTransactionsForLoan(ctx, loan.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.Statement{
+		ID:              uuid.New(),
+		LoanID:          loan.ID,
+		Cycle:           cycle,
+		PeriodStart:     since.UTC().Truncate(24 * time.Hour),
+		StatementDate:   statementDate,
+		Balance:         loan.Balance.Add(amountsDue(loan)),
+		InterestCharged: decimal.Zero,
+		Payments:        decimal.Zero,
+		Fees:            decimal.Zero,
+		OddDaysInterest: decimal.Zero,
+		MinimumDue:      decimal.Zero,
+		DueDate:         statementDate.AddDate(0, 0, paymentGracePeriodDays),
+		CreatedAt:       time.Now(),
+	}
+	for _, tx := range transactions {
+		if tx.Timestamp.Before(since) {
+			continue
+		}
+		switch tx.Type {
+		case models.TransactionTypeInterest:
+			statement.InterestCharged = statement.InterestCharged.Add(tx.Amount)
+		case models.TransactionTypePayment:
+			statement.Payments = statement.Payments.Add(tx.Amount)
+		case models.TransactionTypeFee, models.TransactionTypeOriginationFee, models.TransactionTypeServicingFee:
+			statement.Fees = statement.Fees.Add(tx.Amount)
+		}
+	}
+	policy, err := l.minimumPaymentPolicy(ctx, loan)
+	if err != nil {
+		return nil, err
+	}
+	statement.MinimumDue = MinimumDue(policy, statement)
+	if len(previous) == 0 {
+		statement.OddDays = loan.OddDays
+		statement.OddDaysInterest = loan.OddDaysInterest
+		statement.OddDaysPolicy = loan.OddDaysPolicy
+	}
+
+	if err := l.storage.CreateStatement(ctx, statement); err != nil {
+		return nil, err
+	}
+
+	description := fmt.Sprintf("Statement for %s issued: balance %s, minimum %s due %s", cycle, statement.Balance.StringFixed(2), statement.MinimumDue.StringFixed(2), statement.DueDate.Format("2006-01-02"))
+	switch statement.OddDaysPolicy {
+	case models.OddDaysCharge:
+		description += fmt.Sprintf(" (includes %s interest for %d odd days)", statement.OddDaysInterest.StringFixed(2), statement.OddDays)
+	case models.OddDaysWaive:
+		description += fmt.Sprintf(" (interest waived for %d odd days)", statement.OddDays)
+	}
+	if _, err := l.recordEvent(ctx, loan.ID, models.LoanEventStatement, systemAuthor, description); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// GetStatements retrieves a loan's statements, oldest first.
+func (l *Ledger) GetStatements(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.storage.GetStatementsForLoan(ctx, loanID)
+}
@@ -0,0 +1,176 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+// loanChangeRetries is how many times changeLoan works a change out again on a loan that
+// another update changed between its read and its write.
+const loanChangeRetries = 3
+
+// streamBuffer is how many events a live stream subscriber may fall behind before further
+// events are dropped for it.
+const streamBuffer = 64
+
+// eventStream fans ledger changes out to live subscribers. Events are not stored, so a
+// subscriber sees only what happens while it is connected.
+type eventStream struct {
+	mu          sync.Mutex
+	subscribers map[chan *models.StreamEvent]struct{}
+}
+
+// SubscribeEvents returns a channel receiving every new transaction, loan status change and
+// daily accrual from now on, and a function that ends the subscription and closes the channel. A subscriber
+// that stops reading misses events rather than blocking the ledger.
+func (l *Ledger) SubscribeEvents() (<-chan *models.StreamEvent, func()) {
+	ch := make(chan *models.StreamEvent, streamBuffer)
+
+	l.stream.mu.Lock()
+	if l.stream.subscribers == nil {
+		l.stream.subscribers = make(map[chan *models.StreamEvent]struct{})
+	}
+	l.stream.subscribers[ch] = struct{}{}
+	l.stream.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			l.stream.mu.Lock()
+			delete(l.stream.subscribers, ch)
+			l.stream.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// broadcast sends an event to every live subscriber without waiting on any of them. Inside
+// inTransaction the event is held back until the transaction commits.
+func (l *Ledger) broadcast(eventType models.StreamEventType, loanID uuid.UUID, data any) {
+	if l.pending != nil {
+		*l.pending = append(*l.pending, func() { l.stream.send(eventType, loanID, data) })
+		return
+	}
+	l.stream.send(eventType, loanID, data)
+}
+
+func (s *eventStream) send(eventType models.StreamEventType, loanID uuid.UUID, data any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	event := &models.StreamEvent{ID: uuid.New(), Type: eventType, LoanID: loanID, CreatedAt: time.Now(), Data: data}
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default: // Subscriber is behind; drop the event for it
+		}
+	}
+}
+
+// inTransaction runs fn with a ledger whose every call goes through one store transaction, so
+// that several ledger operations commit or roll back together. Events for live subscribers
+// are sent once it commits, and dropped if it does not. Called inside a transaction, fn joins
+// it.
+func (l *Ledger) inTransaction(ctx context.Context, fn func(ctx context.Context, tl *Ledger) error) error {
+	if l.pending != nil {
+		return fn(ctx, l)
+	}
+	var pending []func()
+	err := l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+		pending = nil // The store may run the transaction again after a conflict
+		tl := *l
+		tl.storage = tx
+		tl.pending = &pending
+		return fn(ctx, &tl)
+	})
+	if err != nil {
+		return err
+	}
+	for _, send := range pending {
+		send()
+	}
+	return nil
+}
+
+// changeLoan reads the loan and runs change on it in one transaction. change writes the loan
+// with a versioned update, as updateLoanPublishing does, so a loan that another update changed
+// in the meantime is not overwritten: the change is worked out again on the loan as it now
+// is. Called inside a transaction, it joins it and leaves retrying to the caller.
+func (l *Ledger) changeLoan(ctx context.Context, id uuid.UUID, change func(ctx context.Context, tl *Ledger, loan *models.Loan) error) error {
+	var err error
+	for attempt := 0; attempt <= loanChangeRetries; attempt++ {
+		err = l.inTransaction(ctx, func(ctx context.Context, tl *Ledger) error {
+			loan, err := tl.storage.GetLoan(ctx, id)
+			if err != nil {
+				return err
+			}
+			return change(ctx, tl, loan)
+		})
+		if l.pending != nil || !errors.Is(err, models.ErrLoanVersionMismatch) {
+			return err
+		}
+	}
+	return err
+}
+
+// createTransaction stores a transaction and pushes it to live subscribers.
+func (l *Ledger) createTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := l.storage.CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	l.broadcast(models.StreamTransactionCreated, transaction.LoanID, transaction)
+	return nil
+}
+
+// updateLoanWithTransactions stores a loan's update and the transactions it produced as one
+// unit of work, so the books never hold the one without the other, and pushes the
+// transactions to live subscribers once they are committed.
+func (l *Ledger) updateLoanWithTransactions(ctx context.Context, loan *models.Loan, transactions ...*models.Transaction) error {
+	return l.updateLoanPublishing(ctx, loan, nil, transactions...)
+}
+
+// updateLoanPublishing is updateLoanWithTransactions that also writes the webhook events the
+// change raises to the outbox in the same unit of work. The loan is written only if it is still
+// at the version it was read at, failing with models.ErrLoanVersionMismatch otherwise.
+func (l *Ledger) updateLoanPublishing(ctx context.Context, loan *models.Loan, events []*models.WebhookEvent, transactions ...*models.Transaction) error {
+	err := l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+		var before *models.Loan
+		if l.eventSourcing {
+			var err error
+			if before, err = tx.GetLoan(ctx, loan.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.UpdateLoanIfVersion(ctx, loan, loan.Version); err != nil {
+			return fmt.Errorf("failed to update loan: %w", err)
+		}
+		for _, transaction := range transactions {
+			if err := tx.CreateTransaction(ctx, transaction); err != nil {
+				return fmt.Errorf("failed to store %s transaction: %w", transaction.Type, err)
+			}
+		}
+		if l.eventSourcing {
+			if err := appendLedgerEvent(ctx, tx, transactionsEvent(loanAmounts(before), loan, transactions)); err != nil {
+				return err
+			}
+		}
+		return publishEvents(ctx, tx, events...)
+	})
+	if err != nil {
+		return err
+	}
+	for _, transaction := range transactions {
+		l.broadcast(models.StreamTransactionCreated, transaction.LoanID, transaction)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+// systemAuthor attributes events recorded by the ledger itself rather than a servicing agent.
+const systemAuthor = "system"
+
+// recordEvent stores a non-monetary event against a loan.
+func (l *Ledger) recordEvent(ctx context.Context, loanID uuid.UUID, eventType models.LoanEventType, author string, description string) (*models.LoanEvent, error) {
+	return storeLoanEvent(ctx, l.storage, loanID, eventType, author, description)
+}
+
+// storeLoanEvent stores a non-monetary event against a loan through s.
+func storeLoanEvent(ctx context.Context, s store.Storage, loanID uuid.UUID, eventType models.LoanEventType, author string, description string) (*models.LoanEvent, error) {
+	event := &models.LoanEvent{
+		ID:          uuid.New(),
+		LoanID:      loanID,
+		Type:        eventType,
+		Description: description,
+		Author:      author,
+		Timestamp:   time.Now(),
+	}
+	if err := s.CreateLoanEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to store %s event: %w", eventType, err)
+	}
+	return event, nil
+}
+
+// recordStatusChange stores a status change event if the status actually changed.
+func (l *Ledger) recordStatusChange(ctx context.Context, loanID uuid.UUID, from, to models.LoanStatus) error {
+	if from == to {
+		return nil
+	}
+	if _, err := l.recordEvent(ctx, loanID, models.LoanEventStatusChange, systemAuthor, fmt.Sprintf("Status changed from %s to %s", from, to)); err != nil {
+		return err
+	}
+	l.broadcast(models.StreamLoanStatusChanged, loanID, models.LoanStatusChange{LoanID: loanID, From: from, To: to})
+	return nil
+}
+
+// AddNote attaches a servicing note to a loan.
+func (l *Ledger) AddNote(ctx context.Context, loanID uuid.UUID, author string, text string) (*models.LoanEvent, error) {
+	if text == "" {
+		return nil, models.Invalidf("note text is required")
+	}
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		return nil, err
+	}
+	return l.recordEvent(ctx, loanID, models.LoanEventNote, author, text)
+}
+
+// GetTimeline merges a loan's transactions and events into a single chronologically ordered
+// feed. Archived loans are served from cold storage.
+func (l *Ledger) GetTimeline(ctx context.Context, loanID uuid.UUID) ([]*models.TimelineEntry, error) {
+	getTransactions := l.storage.GetTransactionsForLoan
+	getEvents := l.storage.GetLoanEventsForLoan
+	if _, err := l.storage.GetLoan(ctx, loanID); err != nil {
+		if _, archiveErr := l.storage.GetArchivedLoan(ctx, loanID); archiveErr != nil {
+			return nil, err
+		}
+		getTransactions = l.storage.GetArchivedTransactionsForLoan
+		getEvents = l.storage.GetArchivedLoanEventsForLoan
+	}
+
+	transactions, err := getTransactions(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+	events, err := getEvents(ctx, loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeline := make([]*models.TimelineEntry, 0, len(transactions)+len(events))
+	for _, tx := range transactions {
+		amount := tx.Amount
+		timeline = append(timeline, &models.TimelineEntry{
+			Timestamp:   tx.Timestamp,
+			Category:    "transaction",
+			Type:        string(tx.Type),
+			Description: fmt.Sprintf("%s of %s", tx.Type, tx.Amount.StringFixed(2)),
+			Amount:      &amount,
+			ReferenceID: tx.ID,
+		})
+	}
+	for _, event := range events {
+		timeline = append(timeline, &models.TimelineEntry{
+			Timestamp:   event.Timestamp,
+			Category:    "event",
+			Type:        string(event.Type),
+			Description: event.Description,
+			ReferenceID: event.ID,
+		})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Timestamp.Before(timeline[j].Timestamp)
+	})
+	return timeline, nil
+}
@@ -0,0 +1,273 @@
+package ledger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/store"
+)
+
+// WebhookSender delivers a webhook body to a subscriber URL. It returns an error unless the
+// subscriber acknowledged the delivery.
+type WebhookSender interface {
+	Send(url string, body []byte, headers map[string]string) error
+}
+
+// Webhook headers sent with each delivery.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookSignatureHeader = "X-Webhook-Signature" // Hex HMAC-SHA256 of the body under the subscription's secret
+)
+
+const (
+	// maxWebhookAttempts is how many times a delivery is tried before it is marked failed.
+	maxWebhookAttempts = 8
+	// webhookRetryBase is the wait before the first retry; each further retry waits twice as long.
+	webhookRetryBase = 30 * time.Second
+	// webhookBatchSize caps the deliveries sent by one run of the delivery worker.
+	webhookBatchSize = 100
+	// outboxBatchSize caps the events published by one run of the outbox relay.
+	outboxBatchSize = 100
+)
+
+// CreateWebhookSubscription registers a URL for the given events and generates the secret
+// that signs its deliveries. The secret is returned only here.
+func (l *Ledger) CreateWebhookSubscription(ctx context.Context, rawURL string, events []models.WebhookEventType) (*models.WebhookSubscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, models.Invalidf("invalid webhook URL")
+	}
+	if len(events) == 0 {
+		return nil, models.Invalidf("invalid webhook events: at least one event is required")
+	}
+	for _, event := range events {
+		if !event.Valid() {
+			return nil, models.Invalidf("invalid webhook event %q", event)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	subscription := &models.WebhookSubscription{
+		ID:        uuid.New(),
+		URL:       rawURL,
+		Events:    events,
+		Secret:    hex.EncodeToString(secret),
+		CreatedAt: time.Now(),
+	}
+	if err := l.storage.CreateWebhookSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// GetWebhookSubscriptions lists the webhook subscriptions, without their secrets.
+func (l *Ledger) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	subscriptions, err := l.storage.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, subscription := range subscriptions {
+		subscription.Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription removes a subscription and drops its undelivered events.
+func (l *Ledger) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	return l.storage.DeleteWebhookSubscription(ctx, id)
+}
+
+// GetWebhookDeliveries lists a subscription's most recent deliveries, newest first.
+func (l *Ledger) GetWebhookDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	if _, err := l.storage.GetWebhookSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	deliveries, err := l.storage.GetWebhookDeliveriesForSubscription(ctx, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// newWebhookEvent returns an event of the given type reporting data.
+func newWebhookEvent(eventType models.WebhookEventType, data any) *models.WebhookEvent {
+	return &models.WebhookEvent{ID: uuid.New(), Type: eventType, CreatedAt: time.Now(), Data: data}
+}
+
+// publishEvents writes events to the outbox through s, which should be the transaction that
+// stores the change they report: the events are then kept exactly when the change is, and
+// RelayOutbox queues them for their subscribers. Each is noted on its loan's timeline.
+func publishEvents(ctx context.Context, s store.Storage, events ...*models.WebhookEvent) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+		}
+		outboxEvent := &models.OutboxEvent{ID: event.ID, Type: event.Type, Payload: payload, CreatedAt: event.CreatedAt}
+		if err := s.CreateOutboxEvent(ctx, outboxEvent); err != nil {
+			return fmt.Errorf("failed to write %s event to the outbox: %w", event.Type, err)
+		}
+		if loanID, ok := webhookEventLoanID(event); ok {
+			description := fmt.Sprintf("Published %s webhook event %s", event.Type, event.ID)
+			if _, err := storeLoanEvent(ctx, s, loanID, models.LoanEventNotification, systemAuthor, description); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// webhookEventLoanID returns the ID of the loan a webhook event reports on.
+func webhookEventLoanID(event *models.WebhookEvent) (uuid.UUID, bool) {
+	switch data := event.Data.(type) {
+	case *models.Loan:
+		return data.ID, true
+	case *models.Transaction:
+		return data.LoanID, true
+	}
+	return uuid.Nil, false
+}
+
+// loanClosedEvents returns a loan.closed event if a change from the previous status closed
+// the loan.
+func loanClosedEvents(previous models.LoanStatus, loan *models.Loan) []*models.WebhookEvent {
+	if previous == models.LoanStatusClosed || loan.Status != models.LoanStatusClosed {
+		return nil
+	}
+	return []*models.WebhookEvent{newWebhookEvent(models.WebhookLoanClosed, loan)}
+}
+
+func subscribed(subscription *models.WebhookSubscription, eventType models.WebhookEventType) bool {
+	for _, event := range subscription.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// RelayOutbox publishes the events waiting in the outbox, oldest first, by queuing each for
+// the subscribers to its type. An event's deliveries are queued in the same transaction that
+// marks it published, so it is queued exactly once even if the relay stops part-way or two
+// relays run at once. The relay stops at the first event it cannot publish, leaving it and
+// those after it for the next run so subscribers still receive events in order. It returns
+// the number of events published.
+func (l *Ledger) RelayOutbox(ctx context.Context) (int, error) {
+	pending, err := l.storage.GetPendingOutboxEvents(ctx, outboxBatchSize)
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+	subscriptions, err := l.storage.GetWebhookSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range pending {
+		err := l.storage.InTransaction(ctx, func(ctx context.Context, tx store.Storage) error {
+			now := time.Now()
+			if err := tx.MarkOutboxEventPublished(ctx, event.ID, now); err != nil {
+				return err
+			}
+			for _, subscription := range subscriptions {
+				if !subscribed(subscription, event.Type) {
+					continue
+				}
+				delivery := &models.WebhookDelivery{
+					ID:             uuid.New(),
+					SubscriptionID: subscription.ID,
+					EventID:        event.ID,
+					EventType:      event.Type,
+					Payload:        event.Payload,
+					Status:         models.WebhookDeliveryPending,
+					NextAttemptAt:  now,
+					CreatedAt:      now,
+				}
+				if err := tx.CreateWebhookDelivery(ctx, delivery); err != nil {
+					return fmt.Errorf("failed to queue for webhook subscription %s: %w", subscription.ID, err)
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, models.ErrOutboxEventPublished) {
+			continue // Another relay got there first
+		}
+		if err != nil {
+			return published, fmt.Errorf("failed to publish %s event %s: %w", event.Type, event.ID, err)
+		}
+		published++
+	}
+	return published, nil
+}
+
+// DeliverWebhooks sends the deliveries that are due, signing each body with its
+// subscription's secret. A failed delivery is retried with exponential backoff and marked
+// failed after maxWebhookAttempts. It returns the number delivered and the number that failed
+// this run.
+func (l *Ledger) DeliverWebhooks(ctx context.Context, sender WebhookSender) (int, int) {
+	due, err := l.storage.GetDueWebhookDeliveries(ctx, time.Now(), webhookBatchSize)
+	if err != nil {
+		fmt.Printf("Error getting due webhook deliveries: %v\n", err)
+		return 0, 0
+	}
+
+	delivered, failed := 0, 0
+	subscriptions := map[uuid.UUID]*models.WebhookSubscription{}
+	for _, delivery := range due {
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			if subscription, err = l.storage.GetWebhookSubscription(ctx, delivery.SubscriptionID); err != nil {
+				fmt.Printf("Error getting webhook subscription %s: %v\n", delivery.SubscriptionID, err)
+				continue
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		mac := hmac.New(sha256.New, []byte(subscription.Secret))
+		mac.Write(delivery.Payload)
+		headers := map[string]string{
+			"Content-Type":         "application/json",
+			WebhookEventHeader:     string(delivery.EventType),
+			WebhookDeliveryHeader:  delivery.ID.String(),
+			WebhookSignatureHeader: hex.EncodeToString(mac.Sum(nil)),
+		}
+
+		now := time.Now()
+		delivery.Attempts++
+		if err := sender.Send(subscription.URL, delivery.Payload, headers); err != nil {
+			delivery.LastError = err.Error()
+			if delivery.Attempts >= maxWebhookAttempts {
+				delivery.Status = models.WebhookDeliveryFailed
+			} else {
+				delivery.NextAttemptAt = now.Add(webhookRetryBase << (delivery.Attempts - 1))
+			}
+			failed++
+		} else {
+			delivery.Status = models.WebhookDeliveryDelivered
+			delivery.LastError = ""
+			delivery.DeliveredAt = &now
+			delivered++
+		}
+		if err := l.storage.UpdateWebhookDelivery(ctx, delivery); err != nil {
+			fmt.Printf("Error updating webhook delivery %s: %v\n", delivery.ID, err)
+		}
+	}
+	return delivered, failed
+}
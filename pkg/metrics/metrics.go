@@ -0,0 +1,323 @@
+// Package metrics keeps counters, histograms and gauges and exposes them in the Prometheus
+// text exposition format (https://prometheus.io/docs/instrumenting/exposition_formats/).
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types, as written on a family's # TYPE line.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefaultBuckets are histogram upper bounds suited to request latencies in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Label is one name="value" pair on a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one line of a metric family. Suffix is appended to the family name, e.g. "_bucket".
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named metric with its help text, type and current samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector reports metric families when the registry is scraped. Implement it to expose
+// values kept elsewhere, such as loan counts read from the store.
+type Collector interface {
+	Collect() []Family
+}
+
+// Registry holds the collectors exposed by one /metrics endpoint.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds collectors to the registry.
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Gather collects every registered family, sorted by name. Families reported under the same
+// name by several collectors are merged.
+func (r *Registry) Gather() []Family {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	byName := make(map[string]*Family)
+	var names []string
+	for _, c := range collectors {
+		for _, f := range c.Collect() {
+			if existing, ok := byName[f.Name]; ok {
+				existing.Samples = append(existing.Samples, f.Samples...)
+				continue
+			}
+			family := f
+			byName[f.Name] = &family
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+
+	families := make([]Family, 0, len(names))
+	for _, name := range names {
+		families = append(families, *byName[name])
+	}
+	return families
+}
+
+// WriteText writes the gathered families in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	for _, f := range r.Gather() {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.Name, escapeHelp(f.Help), f.Name, f.Type); err != nil {
+			return err
+		}
+		for _, s := range f.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s%s %s\n", f.Name, s.Suffix, formatLabels(s.Labels), formatValue(s.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry's metrics to Prometheus scrapers.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = l.Name + `="` + escapeLabelValue(l.Value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+
+// labelKey joins label values into a map key; the separator cannot occur in valid UTF-8.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func pairLabels(names []string, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i, name := range names {
+		labels[i] = Label{Name: name, Value: values[i]}
+	}
+	return labels
+}
+
+// CounterVec is a set of counters partitioned by label values.
+type CounterVec struct {
+	name, help string
+	labelNames []string
+
+	mu     sync.Mutex
+	values map[string]float64
+	labels map[string][]string
+}
+
+// NewCounterVec creates a counter family with the given label names.
+func NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+		labels:     make(map[string][]string),
+	}
+}
+
+// Add increases the counter for the label values, which must match the label names in number.
+// Negative deltas are ignored, since counters only go up.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 || len(labelValues) != len(c.labelNames) {
+		return
+	}
+	key := labelKey(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.labels[key]; !ok {
+		c.labels[key] = append([]string(nil), labelValues...)
+	}
+	c.values[key] += delta
+}
+
+// Inc adds one to the counter for the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Collect implements Collector.
+func (c *CounterVec) Collect() []Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	family := Family{Name: c.name, Help: c.help, Type: TypeCounter}
+	for _, key := range sortedKeys(c.values) {
+		family.Samples = append(family.Samples, Sample{Labels: pairLabels(c.labelNames, c.labels[key]), Value: c.values[key]})
+	}
+	return []Family{family}
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	name, help string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	counts      []uint64 // Per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec creates a histogram family with the given bucket upper bounds (DefaultBuckets
+// when nil) and label names.
+func NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{
+		name:       name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*histogram),
+	}
+}
+
+// Observe records a value in the histogram for the label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labelNames) {
+		return
+	}
+	key := labelKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+	series.count++
+	series.sum += value
+}
+
+// Collect implements Collector.
+func (h *HistogramVec) Collect() []Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	family := Family{Name: h.name, Help: h.help, Type: TypeHistogram}
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		labels := pairLabels(h.labelNames, series.labelValues)
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += series.counts[i]
+			family.Samples = append(family.Samples, Sample{Suffix: "_bucket", Labels: append(labels[:len(labels):len(labels)], Label{"le", formatValue(upper)}), Value: float64(cumulative)})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_bucket", Labels: append(labels[:len(labels):len(labels)], Label{"le", "+Inf"}), Value: float64(series.count)},
+			Sample{Suffix: "_sum", Labels: labels, Value: series.sum},
+			Sample{Suffix: "_count", Labels: labels, Value: float64(series.count)},
+		)
+	}
+	return []Family{family}
+}
+
+// GaugeFunc reports the value of a function each time it is scraped.
+type GaugeFunc struct {
+	name, help string
+	value      func() float64
+}
+
+// NewGaugeFunc creates a gauge whose value is read from fn on every scrape.
+func NewGaugeFunc(name string, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{name: name, help: help, value: fn}
+}
+
+// Collect implements Collector.
+func (g *GaugeFunc) Collect() []Family {
+	return []Family{{Name: g.name, Help: g.help, Type: TypeGauge, Samples: []Sample{{Value: g.value()}}}}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	requests := NewCounterVec("requests_total", "Requests served.", "method", "status")
+	requests.Inc("GET", "200")
+	requests.Inc("GET", "200")
+	requests.Add(3, "POST", "201")
+	requests.Add(-1, "POST", "201") // Ignored
+	requests.Inc("GET")             // Wrong label count, ignored
+
+	latency := NewHistogramVec("latency_seconds", "Request latency.", []float64{1, 0.1}, "route")
+	latency.Observe(0.05, "/loans")
+	latency.Observe(0.5, "/loans")
+	latency.Observe(5, "/loans")
+
+	registry := NewRegistry()
+	registry.Register(requests, latency, NewGaugeFunc("open_loans", "Loans \"open\"\nright now.", func() float64 { return 7 }))
+
+	var b strings.Builder
+	if err := registry.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/loans",le="0.1"} 1
+latency_seconds_bucket{route="/loans",le="1"} 2
+latency_seconds_bucket{route="/loans",le="+Inf"} 3
+latency_seconds_sum{route="/loans"} 5.55
+latency_seconds_count{route="/loans"} 3
+# HELP open_loans Loans "open"\nright now.
+# TYPE open_loans gauge
+open_loans 7
+# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 2
+requests_total{method="POST",status="201"} 3
+`
+	if b.String() != expected {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", b.String(), expected)
+	}
+}
+
+func TestRegistryHandler(t *testing.T) {
+	counter := NewCounterVec("escaped_total", "Escaping.", "path")
+	counter.Inc("a\"b\\c\nd")
+
+	registry := NewRegistry()
+	registry.Register(counter)
+
+	rr := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text content type, got %q", ct)
+	}
+	if !strings.Contains(rr.Body.String(), `escaped_total{path="a\"b\\c\nd"} 1`) {
+		t.Errorf("Expected escaped label value, got:\n%s", rr.Body.String())
+	}
+}
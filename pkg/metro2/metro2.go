@@ -0,0 +1,296 @@
+// Package metro2 writes loan account status records as fixed-width lines in the style of the
+// Metro 2 base segment used to furnish data to credit bureaus. The layout is configurable:
+// a Format lists which fields appear, in what order and at what width.
+package metro2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// Field names accepted in a Format.
+const (
+	FieldRecordLength      = "record_length" // Length of the record, as the Metro 2 record descriptor word
+	FieldAccountNumber     = "account_number"
+	FieldCustomerKey       = "customer_key"
+	FieldProductCode       = "product_code"
+	FieldDateOpened        = "date_opened"
+	FieldOriginalAmount    = "original_amount"
+	FieldTermMonths        = "term_months"
+	FieldCurrentBalance    = "current_balance"
+	FieldAccountStatus     = "account_status"
+	FieldDaysPastDue       = "days_past_due"
+	FieldPaymentHistory    = "payment_history"
+	FieldDateOfAccountInfo = "date_of_account_information"
+	FieldReportingPeriod   = "reporting_period"
+	FieldFiller            = "filler" // Blank padding, e.g. for fields reserved by the bureau
+)
+
+// Account status codes reported for a loan.
+const (
+	StatusCurrent     = "11" // Current, or less than 30 days past due
+	StatusPaid        = "13" // Paid or closed with a zero balance
+	Status30          = "71" // 30-59 days past due
+	Status60          = "78" // 60-89 days past due
+	Status90          = "80" // 90-119 days past due
+	Status120         = "82" // 120-149 days past due
+	Status150         = "83" // 150-179 days past due
+	Status180         = "84" // 180 or more days past due
+	StatusChargedOff  = "97" // Unpaid balance reported as a loss
+	historyNoData     = 'B'  // Payment history code for a month with no record
+	historyChargedOff = 'L'
+)
+
+// PaymentHistoryMonths is how many months of history a record carries.
+const PaymentHistoryMonths = 24
+
+const dateLayout = "01022006"
+
+// numericFields are zero-filled and right-justified; every other field is space-filled and
+// left-justified, as in Metro 2.
+var numericFields = map[string]bool{
+	FieldRecordLength:      true,
+	FieldDateOpened:        true,
+	FieldOriginalAmount:    true,
+	FieldTermMonths:        true,
+	FieldCurrentBalance:    true,
+	FieldDaysPastDue:       true,
+	FieldDateOfAccountInfo: true,
+}
+
+var knownFields = map[string]bool{
+	FieldRecordLength:      true,
+	FieldAccountNumber:     true,
+	FieldCustomerKey:       true,
+	FieldProductCode:       true,
+	FieldDateOpened:        true,
+	FieldOriginalAmount:    true,
+	FieldTermMonths:        true,
+	FieldCurrentBalance:    true,
+	FieldAccountStatus:     true,
+	FieldDaysPastDue:       true,
+	FieldPaymentHistory:    true,
+	FieldDateOfAccountInfo: true,
+	FieldReportingPeriod:   true,
+	FieldFiller:            true,
+}
+
+// AccountStatus maps a loan status and its days past due to a Metro 2 account status code.
+func AccountStatus(loanStatus string, daysPastDue int) string {
+	switch loanStatus {
+	case "charged_off":
+		return StatusChargedOff
+	case "closed":
+		return StatusPaid
+	}
+	switch {
+	case daysPastDue >= 180:
+		return Status180
+	case daysPastDue >= 150:
+		return Status150
+	case daysPastDue >= 120:
+		return Status120
+	case daysPastDue >= 90:
+		return Status90
+	case daysPastDue >= 60:
+		return Status60
+	case daysPastDue >= 30:
+		return Status30
+	}
+	return StatusCurrent
+}
+
+// PaymentHistory builds the payment history profile from the account status codes of the
+// months preceding the reporting period, most recent first. An empty string marks a month
+// without a record. The profile is always PaymentHistoryMonths long.
+func PaymentHistory(previous []string) string {
+	profile := make([]byte, PaymentHistoryMonths)
+	for i := range profile {
+		profile[i] = historyNoData
+		if i < len(previous) {
+			profile[i] = historyCode(previous[i])
+		}
+	}
+	return string(profile)
+}
+
+func historyCode(accountStatus string) byte {
+	switch accountStatus {
+	case StatusCurrent, StatusPaid:
+		return '0'
+	case Status30:
+		return '1'
+	case Status60:
+		return '2'
+	case Status90:
+		return '3'
+	case Status120:
+		return '4'
+	case Status150:
+		return '5'
+	case Status180:
+		return '6'
+	case StatusChargedOff:
+		return historyChargedOff
+	}
+	return historyNoData
+}
+
+// Field is one fixed-width column of a record.
+type Field struct {
+	Name  string `json:"name"`
+	Width int    `json:"width"`
+}
+
+// Format is the ordered layout of a record.
+type Format struct {
+	Fields []Field `json:"fields"`
+}
+
+// DefaultFormat is a subset of the Metro 2 base segment.
+var DefaultFormat = Format{Fields: []Field{
+	{Name: FieldRecordLength, Width: 4},
+	{Name: FieldAccountNumber, Width: 32},
+	{Name: FieldCustomerKey, Width: 20},
+	{Name: FieldProductCode, Width: 10},
+	{Name: FieldDateOpened, Width: 8},
+	{Name: FieldOriginalAmount, Width: 9},
+	{Name: FieldTermMonths, Width: 3},
+	{Name: FieldCurrentBalance, Width: 9},
+	{Name: FieldAccountStatus, Width: 2},
+	{Name: FieldDaysPastDue, Width: 3},
+	{Name: FieldPaymentHistory, Width: PaymentHistoryMonths},
+	{Name: FieldDateOfAccountInfo, Width: 8},
+}}
+
+// LoadFormat reads a JSON layout such as {"fields": [{"name": "account_number", "width": 30}]}.
+func LoadFormat(r io.Reader) (Format, error) {
+	var format Format
+	if err := json.NewDecoder(r).Decode(&format); err != nil {
+		return Format{}, fmt.Errorf("failed to decode format: %w", err)
+	}
+	if err := format.Validate(); err != nil {
+		return Format{}, err
+	}
+	return format, nil
+}
+
+// Validate checks that the format has at least one field and that every field is known and
+// has a positive width.
+func (f Format) Validate() error {
+	if len(f.Fields) == 0 {
+		return fmt.Errorf("format has no fields")
+	}
+	for _, field := range f.Fields {
+		if !knownFields[field.Name] {
+			return fmt.Errorf("unknown field %q", field.Name)
+		}
+		if field.Width <= 0 {
+			return fmt.Errorf("field %q must have a positive width", field.Name)
+		}
+	}
+	return nil
+}
+
+// RecordLength is the length of each record, excluding the line terminator.
+func (f Format) RecordLength() int {
+	length := 0
+	for _, field := range f.Fields {
+		length += field.Width
+	}
+	return length
+}
+
+// Write writes one line per record in the given format.
+func Write(w io.Writer, format Format, records []*models.BureauRecord) error {
+	if err := format.Validate(); err != nil {
+		return err
+	}
+	for _, record := range records {
+		line, err := format.Line(record)
+		if err != nil {
+			return fmt.Errorf("loan %s: %w", record.LoanID, err)
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Line renders a single record. Alphanumeric values longer than their field are truncated;
+// numeric values that do not fit are an error, since truncating them would misreport the account.
+func (f Format) Line(record *models.BureauRecord) (string, error) {
+	var b strings.Builder
+	for _, field := range f.Fields {
+		value := f.value(field.Name, record)
+		if numericFields[field.Name] {
+			if len(value) > field.Width {
+				return "", fmt.Errorf("value %s for field %q exceeds width %d", value, field.Name, field.Width)
+			}
+			b.WriteString(strings.Repeat("0", field.Width-len(value)))
+			b.WriteString(value)
+			continue
+		}
+		if len(value) > field.Width {
+			value = value[:field.Width]
+		}
+		b.WriteString(value)
+		b.WriteString(strings.Repeat(" ", field.Width-len(value)))
+	}
+	return b.String(), nil
+}
+
+func (f Format) value(name string, record *models.BureauRecord) string {
+	switch name {
+	case FieldRecordLength:
+		return strconv.Itoa(f.RecordLength())
+	case FieldAccountNumber:
+		return strings.ToUpper(strings.ReplaceAll(record.LoanID.String(), "-", ""))
+	case FieldCustomerKey:
+		return record.CustomerKey
+	case FieldProductCode:
+		return record.ProductCode
+	case FieldDateOpened:
+		return formatDate(record.DateOpened)
+	case FieldOriginalAmount:
+		return wholeDollars(record.OriginalAmount.IntPart())
+	case FieldTermMonths:
+		return strconv.Itoa(record.TermMonths)
+	case FieldCurrentBalance:
+		return wholeDollars(record.CurrentBalance.IntPart())
+	case FieldAccountStatus:
+		return record.AccountStatus
+	case FieldDaysPastDue:
+		return strconv.Itoa(record.DaysPastDue)
+	case FieldPaymentHistory:
+		return record.PaymentHistory
+	case FieldDateOfAccountInfo:
+		return formatDate(record.AsOf)
+	case FieldReportingPeriod:
+		return record.Period
+	}
+	return ""
+}
+
+// wholeDollars reports amounts in whole dollars, dropping cents as Metro 2 does. Negative
+// amounts (credit balances) are reported as zero.
+func wholeDollars(amount int64) string {
+	if amount < 0 {
+		amount = 0
+	}
+	return strconv.FormatInt(amount, 10)
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(dateLayout)
+}
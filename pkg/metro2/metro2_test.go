@@ -0,0 +1,83 @@
+package metro2
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestWrite(t *testing.T) {
+	record := &models.BureauRecord{
+		LoanID:         uuid.MustParse("0f8fad5b-d9cb-469f-a165-70867728950e"),
+		CustomerKey:    "cust_123",
+		DateOpened:     time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+		OriginalAmount: decimal.RequireFromString("5000.00"),
+		CurrentBalance: decimal.RequireFromString("1234.99"),
+		AccountStatus:  Status30,
+		DaysPastDue:    42,
+		PaymentHistory: PaymentHistory([]string{StatusCurrent, "", StatusChargedOff}),
+		AsOf:           time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, DefaultFormat, []*models.BureauRecord{record}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line := strings.TrimSuffix(buf.String(), "\n")
+	if len(line) != DefaultFormat.RecordLength() {
+		t.Fatalf("Expected record length %d, got %d", DefaultFormat.RecordLength(), len(line))
+	}
+	if !strings.HasPrefix(line, "01320F8FAD5BD9CB469FA16570867728950Ecust_123") {
+		t.Errorf("Unexpected record prefix: %q", line)
+	}
+	for _, want := range []string{"03152024000005000", "000001234", "71042", "0BLBBBBBBBBBBBBBBBBBBBBB06302024"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %q in record %q", want, line)
+		}
+	}
+
+	narrow := Format{Fields: []Field{{Name: FieldCurrentBalance, Width: 3}}}
+	if err := Write(&buf, narrow, []*models.BureauRecord{record}); err == nil {
+		t.Error("Expected error for an amount wider than its field")
+	}
+}
+
+func TestLoadFormat(t *testing.T) {
+	format, err := LoadFormat(strings.NewReader(`{"fields": [{"name": "account_number", "width": 30}, {"name": "filler", "width": 5}]}`))
+	if err != nil {
+		t.Fatalf("LoadFormat failed: %v", err)
+	}
+	if format.RecordLength() != 35 {
+		t.Errorf("Expected record length 35, got %d", format.RecordLength())
+	}
+
+	if _, err := LoadFormat(strings.NewReader(`{"fields": [{"name": "ssn", "width": 9}]}`)); err == nil {
+		t.Error("Expected error for an unknown field")
+	}
+}
+
+func TestAccountStatus(t *testing.T) {
+	cases := []struct {
+		status string
+		dpd    int
+		want   string
+	}{
+		{"active", 0, StatusCurrent},
+		{"active", 29, StatusCurrent},
+		{"active", 30, Status30},
+		{"active", 95, Status90},
+		{"active", 200, Status180},
+		{"charged_off", 130, StatusChargedOff},
+		{"closed", 0, StatusPaid},
+	}
+	for _, c := range cases {
+		if got := AccountStatus(c.status, c.dpd); got != c.want {
+			t.Errorf("AccountStatus(%q, %d) = %s, want %s", c.status, c.dpd, got, c.want)
+		}
+	}
+}
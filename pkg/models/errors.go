@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the ledger and storage layers. Callers compare with errors.Is, since an
+// error may be wrapped with more context on its way up.
+var (
+	ErrLoanNotFound                = errors.New("loan not found")
+	ErrLoanNotActive               = errors.New("loan is not active")
+	ErrLoanVersionMismatch         = errors.New("loan version mismatch")
+	ErrInsufficientCredit          = errors.New("draw exceeds available credit")
+	ErrNoEscrowAccount             = errors.New("loan has no escrow account")
+	ErrNotEnrolledInAutopay        = errors.New("loan is not enrolled in autopay")
+	ErrProductNotFound             = errors.New("product not found")
+	ErrCollateralNotFound          = errors.New("collateral not found")
+	ErrPaymentMethodNotFound       = errors.New("payment method not found")
+	ErrPaymentLinkNotFound         = errors.New("payment link not found")
+	ErrPaymentLinkRedeemed         = errors.New("payment link has already been redeemed")
+	ErrInterestIntentNotFound      = errors.New("interest intent not found")
+	ErrIdempotencyRecordNotFound   = errors.New("idempotency record not found")
+	ErrIdempotencyKeyExists        = errors.New("idempotency key already exists")
+	ErrIdempotencyKeyApplied       = errors.New("idempotency key's request has taken effect")
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound     = errors.New("webhook delivery not found")
+	ErrOutboxEventPublished        = errors.New("outbox event not found or already published")
+	ErrStatementNotFound           = errors.New("statement not found")
+	ErrCustomerNotFound            = errors.New("customer not found")
+	ErrCustomerExists              = errors.New("customer already exists")
+	ErrCustomerNotActive           = errors.New("customer is not active")
+	ErrCustomerHasLoans            = errors.New("customer has loans")
+)
+
+// Errors for requests the ledger refuses because of the state of the loan or of the records
+// they involve, rather than because their input is malformed.
+var (
+	ErrInvalidStatusTransition   = errors.New("invalid status transition")
+	ErrNotLineOfCredit           = errors.New("loan is not a line of credit")
+	ErrLoanHasNoTerm             = errors.New("loan has no term")
+	ErrNoBalanceToRefinance      = errors.New("loan has no balance to refinance")
+	ErrRateChangeBeforeAccrual   = errors.New("effective date must be after the last accrual date")
+	ErrForbearanceOverlap        = errors.New("forbearance overlaps an existing window")
+	ErrInsufficientEscrow        = errors.New("insufficient escrow balance")
+	ErrEscrowExceedsPayment      = errors.New("escrow amount must be less than the payment amount")
+	ErrPaymentMethodNotVerified  = errors.New("payment method is not verified")
+	ErrPaymentMethodExpired      = errors.New("payment method has expired")
+	ErrPaymentMethodNotCustomers = errors.New("payment method does not belong to the loan's customer")
+	ErrPaymentMethodNotPending   = errors.New("payment method is not pending verification")
+	ErrPaymentLinksNotConfigured = errors.New("payment links are not configured")
+	ErrInvalidPaymentLink        = errors.New("invalid payment link")
+	ErrPaymentLinkExpired        = errors.New("payment link has expired")
+	ErrPaymentLinkAmount         = errors.New("amount is outside the payment link's range")
+	ErrIdempotencyKeyInUse       = errors.New("idempotency key is in use")
+	ErrIdempotencyKeyMismatch    = errors.New("idempotency key was used for a different request")
+)
+
+// ValidationError is a request refused because its input is invalid, such as an amount out of
+// range or an unknown option. Its message is written for the client.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Invalidf returns a ValidationError with the formatted message.
+func Invalidf(format string, args ...any) error {
+	return &ValidationError{Message: fmt.Sprintf(format, args...)}
+}
@@ -2,24 +2,562 @@ package models
 
 import (
 	"time"
+
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
 type Loan struct {
-	ID                        uuid.UUID       `json:"id"`
-	CustomerKey               string          `json:"customer_key"` // Link to external customer system
-	Principal                 decimal.Decimal `json:"principal"`
-	Balance                   decimal.Decimal `json:"balance"`
-	BaseInterestRate          decimal.Decimal `json:"base_interest_rate"`     // Standard rate for the product
-	InterestRateVariance      decimal.Decimal `json:"interest_rate_variance"` // Adjustment (positive or negative)
-	InterestRate              decimal.Decimal `json:"interest_rate"`          // Resulting effective APR
-	Status                    string          `json:"status"`                 // e.g., "active", "closed"
-	CreatedAt                 time.Time       `json:"created_at"`
-	UpdatedAt                 time.Time       `json:"updated_at"`
-	LastInterestCalculationDate *time.Time      `json:"last_interest_calculation_date,omitempty"` // To prevent duplicate daily calculations
-	StatementCycleDay         int             `json:"statement_cycle_day"`                       // Day of the month (1-28) for statement generation and interest application
-	AccruedInterest           decimal.Decimal `json:"accrued_interest"`                          // Interest accrued since last statement
+	ID                          uuid.UUID         `json:"id"`
+	CustomerKey                 string            `json:"customer_key"` // Link to external customer system
+	Principal                   decimal.Decimal   `json:"principal"`
+	Balance                     decimal.Decimal   `json:"balance"`
+	BaseInterestRate            decimal.Decimal   `json:"base_interest_rate"`     // Standard rate for the product
+	InterestRateVariance        decimal.Decimal   `json:"interest_rate_variance"` // Adjustment (positive or negative)
+	InterestRate                decimal.Decimal   `json:"interest_rate"`          // Resulting effective APR
+	Status                      LoanStatus        `json:"status"`
+	CreatedAt                   time.Time         `json:"created_at"`
+	UpdatedAt                   time.Time         `json:"updated_at"`
+	LastInterestCalculationDate *time.Time        `json:"last_interest_calculation_date,omitempty"` // To prevent duplicate daily calculations
+	StatementCycleDay           int               `json:"statement_cycle_day"`                      // Day of the month (1-28) for statement generation and interest application
+	AccruedInterest             decimal.Decimal   `json:"accrued_interest"`                         // Interest accrued since last statement
+	DaysPastDue                 int               `json:"days_past_due"`                            // Days since the oldest unpaid due date
+	DelinquencyBucket           DelinquencyBucket `json:"delinquency_bucket"`                       // Aging bucket derived from DaysPastDue
+	LastPaymentDate             *time.Time        `json:"last_payment_date,omitempty"`              // Most recent payment, used to determine the next due date
+	ChargedOffAt                *time.Time        `json:"charged_off_at,omitempty"`                 // When the loan was charged off, if ever
+	ChargeOffAmount             decimal.Decimal   `json:"charge_off_amount"`                        // Receivable written off, retained for recovery tracking
+	ProductCode                 string            `json:"product_code,omitempty"`                   // Product whose terms govern the loan
+	PostChargeOffInterest       decimal.Decimal   `json:"post_charge_off_interest"`                 // Recovery-only interest accrued after charge-off, never billed to the customer
+	TermMonths                  int               `json:"term_months,omitempty"`                    // Contractual term; 0 for open-ended loans
+	RefinancedFrom              *uuid.UUID        `json:"refinanced_from,omitempty"`                // Loan that this loan refinanced, if any
+	IndexCode                   string            `json:"index_code,omitempty"`                     // Benchmark index the base rate floats with; empty for fixed-rate loans
+	PromoRate                   decimal.Decimal   `json:"promo_rate"`                               // Introductory APR used for accrual during the promo window
+	PromoStartDate              *time.Time        `json:"promo_start_date,omitempty"`               // First day of the promo window
+	PromoEndDate                *time.Time        `json:"promo_end_date,omitempty"`                 // Last day of the promo window; the effective rate applies afterwards
+	AmortizationMonths          int               `json:"amortization_months,omitempty"`            // Term payments are amortized over; longer than TermMonths for balloon loans, 0 to use TermMonths
+	PrepaymentPenaltyRate       decimal.Decimal   `json:"prepayment_penalty_rate"`                  // Fraction of prepaid principal charged as a penalty
+	PrepaymentPenaltyMonths     int               `json:"prepayment_penalty_months,omitempty"`      // Months after origination during which prepayment is penalized
+	InterestAppliedCycle        string            `json:"interest_applied_cycle,omitempty"`         // Statement cycle (YYYY-MM) whose accrued interest was last applied to the balance
+	OddDaysPolicy               OddDaysPolicy     `json:"odd_days_policy,omitempty"`                // How interest for the stub before the first full cycle is handled; empty accrues it daily
+	OddDays                     int               `json:"odd_days,omitempty"`                       // Days from disbursement to the first statement date
+	OddDaysInterest             decimal.Decimal   `json:"odd_days_interest"`                        // Fixed interest charged for the stub at origination; zero when waived
+	EscrowEnabled               bool              `json:"escrow_enabled,omitempty"`                 // Whether the loan has an escrow account for taxes and insurance
+	EscrowBalance               decimal.Decimal   `json:"escrow_balance"`                           // Funds held in escrow, separate from the balance owed
+	FeesDue                     decimal.Decimal   `json:"fees_due"`                                 // Billed fees not capitalized into the balance; payments cover them first
+	LoanType                    LoanType          `json:"loan_type,omitempty"`                      // Empty for installment loans
+	CreditLimit                 decimal.Decimal   `json:"credit_limit"`                             // Most a line of credit may have outstanding; zero for installment loans
+	AvailableCredit             *decimal.Decimal  `json:"available_credit,omitempty"`               // Undrawn credit on a line of credit, computed when the loan is retrieved
+	LoanToValue                 *decimal.Decimal  `json:"ltv,omitempty"`                            // Balance over total collateral valuation, computed when the loan is retrieved
+	AccrualStartDate            *time.Time        `json:"accrual_start_date,omitempty"`             // First day interest accrues; nil accrues from disbursement
+	InterestMode                InterestMode      `json:"interest_mode,omitempty"`                  // Empty compounds monthly
+	InterestDue                 decimal.Decimal   `json:"interest_due"`                             // Billed simple interest not capitalized; payments cover it after fees due
+	InterestResidual            decimal.Decimal   `json:"interest_residual"`                        // Fraction of a unit rounded off daily accrual, carried into the next day
+	NegativeAmortizationCap     decimal.Decimal   `json:"negative_amortization_cap"`                // Most the balance may grow to by capitalizing interest, as a multiple of the original principal; zero is uncapped
+	NegativeAmortizationCapped  bool              `json:"negative_amortization_capped"`             // Set once interest was billed as due because the cap was reached
+	DeletedAt                   *time.Time        `json:"deleted_at,omitempty"`                     // When the loan was voided
+	APR                         *decimal.Decimal  `json:"apr,omitempty"`                            // Nominal annual rate accruing today, computed when the loan is retrieved
+	APY                         *decimal.Decimal  `json:"apy,omitempty"`                            // Effective annual yield of the APR under the loan's interest mode, computed when the loan is retrieved
+	Version                     int               `json:"version"`                                  // Incremented on every update; the loan's ETag
+}
+
+// LoanQuery selects a page of loans matching optional filters. Zero-valued filters match
+// every loan, except that voided loans only match a status filter of voided.
+type LoanQuery struct {
+	Status       LoanStatus      `json:"status,omitempty"`
+	CustomerKey  string          `json:"customer_key,omitempty"`
+	MinBalance   decimal.Decimal `json:"min_balance"`
+	CreatedAfter *time.Time      `json:"created_after,omitempty"` // Inclusive
+	Sort         LoanSort        `json:"sort,omitempty"`
+	Limit        int             `json:"limit"`
+	Offset       int             `json:"offset"`
+}
+
+// LoanCursor is a position in the loans ordered oldest first, for keyset pagination: a page
+// read after it starts with the loan after the one created at CreatedAt with ID.
+type LoanCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        uuid.UUID `json:"id"`
+}
+
+// LoanSearch selects a page of loans by ranges and sets of values. Unset bounds and empty
+// sets match every loan, except that voided loans only match a status set that includes
+// voided; ranges are inclusive at both ends.
+type LoanSearch struct {
+	Statuses          []LoanStatus     `json:"statuses,omitempty"`            // Any of these statuses
+	CustomerKeyPrefix string           `json:"customer_key_prefix,omitempty"` // Customer keys starting with this
+	MinBalance        *decimal.Decimal `json:"min_balance,omitempty"`
+	MaxBalance        *decimal.Decimal `json:"max_balance,omitempty"`
+	MinRate           *decimal.Decimal `json:"min_rate,omitempty"` // Effective interest rate
+	MaxRate           *decimal.Decimal `json:"max_rate,omitempty"`
+	CreatedFrom       *time.Time       `json:"created_from,omitempty"`
+	CreatedTo         *time.Time       `json:"created_to,omitempty"`
+	Sort              LoanSort         `json:"sort,omitempty"`
+	Limit             int              `json:"limit"`
+	Offset            int              `json:"offset"`
+}
+
+// LoanSort orders a loan listing by a field, ascending, or descending with a leading "-".
+// The empty sort orders by creation.
+type LoanSort string
+
+const (
+	LoanSortCreatedAt     LoanSort = "created_at"
+	LoanSortCreatedAtDesc LoanSort = "-created_at"
+	LoanSortBalance       LoanSort = "balance"
+	LoanSortBalanceDesc   LoanSort = "-balance"
+)
+
+// Valid reports whether the sort is a known value.
+func (s LoanSort) Valid() bool {
+	switch s {
+	case "", LoanSortCreatedAt, LoanSortCreatedAtDesc, LoanSortBalance, LoanSortBalanceDesc:
+		return true
+	}
+	return false
+}
+
+// LoanPage is one page of a loan listing.
+type LoanPage struct {
+	Loans  []*Loan `json:"loans"`
+	Total  int     `json:"total"` // Loans matching the query across all pages
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// Customer is a borrower. Loans refer to their customer by CustomerKey, the customer's key
+// in the system that owns their identity.
+type Customer struct {
+	ID          uuid.UUID      `json:"id"`
+	CustomerKey string         `json:"customer_key"`
+	Name        string         `json:"name"`
+	Email       string         `json:"email,omitempty"`
+	Phone       string         `json:"phone,omitempty"`
+	Address     string         `json:"address,omitempty"`
+	Status      CustomerStatus `json:"status"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// CustomerStatus is whether a customer may take out new loans.
+type CustomerStatus string
+
+const (
+	CustomerStatusActive   CustomerStatus = "active"
+	CustomerStatusInactive CustomerStatus = "inactive" // Existing loans are serviced, but no new ones are opened
+)
+
+// Valid reports whether the status is a known value.
+func (s CustomerStatus) Valid() bool {
+	return s == CustomerStatusActive || s == CustomerStatusInactive
+}
+
+// CustomerSummary totals a customer's loans. Balances and interest cover open loans only.
+type CustomerSummary struct {
+	CustomerKey        string                  `json:"customer_key"`
+	LoanCount          int                     `json:"loan_count"`
+	OpenLoanCount      int                     `json:"open_loan_count"`
+	OutstandingBalance decimal.Decimal         `json:"outstanding_balance"`
+	AccruedInterest    decimal.Decimal         `json:"accrued_interest"`
+	NextStatements     []CustomerNextStatement `json:"next_statements"` // One per open loan, soonest first
+}
+
+// CustomerNextStatement is the next statement date of one of a customer's open loans.
+type CustomerNextStatement struct {
+	LoanID            uuid.UUID `json:"loan_id"`
+	StatementCycleDay int       `json:"statement_cycle_day"`
+	StatementDate     time.Time `json:"statement_date"`
+}
+
+// IdempotencyRecord remembers the response to a request sent with an Idempotency-Key, so a
+// retry of the request receives the original response instead of being processed again.
+type IdempotencyRecord struct {
+	Key         string     `json:"key"`
+	RequestHash string     `json:"request_hash"` // SHA-256 of the method, path and body, to detect key reuse
+	StatusCode  int        `json:"status_code"`  // Zero while the original request is still being processed
+	ContentType string     `json:"content_type"`
+	Body        []byte     `json:"body"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"` // When the request took effect, committed with its changes
+}
+
+// LoanStatus is a loan's position in its lifecycle. Loans move between statuses only along
+// the transitions permitted by CanTransitionTo.
+type LoanStatus string
+
+const (
+	LoanStatusPending    LoanStatus = "pending"     // Approved but not yet funded
+	LoanStatusActive     LoanStatus = "active"      // Funded and in good standing
+	LoanStatusDelinquent LoanStatus = "delinquent"  // Funded and 30 or more days past due
+	LoanStatusClosed     LoanStatus = "closed"      // Paid off, refinanced or cancelled; terminal
+	LoanStatusChargedOff LoanStatus = "charged_off" // Written off; payments are recoveries
+	LoanStatusVoided     LoanStatus = "voided"      // Entered in error; kept for the record but hidden from listings. Terminal, and reached only by voiding the loan
+)
+
+// loanStatusTransitions lists the statuses each status may move to.
+var loanStatusTransitions = map[LoanStatus][]LoanStatus{
+	LoanStatusPending:    {LoanStatusActive, LoanStatusClosed},
+	LoanStatusActive:     {LoanStatusDelinquent, LoanStatusClosed, LoanStatusChargedOff},
+	LoanStatusDelinquent: {LoanStatusActive, LoanStatusClosed, LoanStatusChargedOff},
+	LoanStatusChargedOff: {LoanStatusClosed},
+}
+
+// Valid reports whether the status is a known value.
+func (s LoanStatus) Valid() bool {
+	switch s {
+	case LoanStatusPending, LoanStatusActive, LoanStatusDelinquent, LoanStatusClosed, LoanStatusChargedOff, LoanStatusVoided:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether a loan in this status is being serviced: it accrues interest,
+// accepts payments and ages for delinquency.
+func (s LoanStatus) IsOpen() bool {
+	return s == LoanStatusActive || s == LoanStatusDelinquent
+}
+
+// CanTransitionTo reports whether a loan may move from this status to next. Staying in the
+// same status is always permitted.
+func (s LoanStatus) CanTransitionTo(next LoanStatus) bool {
+	if s == next {
+		return true
+	}
+	for _, allowed := range loanStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// InterestMode selects what happens to a cycle's accrued interest on the statement date.
+type InterestMode string
+
+const (
+	InterestCompound InterestMode = "compound" // Capitalized into the balance, where it accrues interest
+	InterestSimple   InterestMode = "simple"   // Billed as interest due and never capitalized
+)
+
+// Valid reports whether the mode is a known value. The empty mode compounds.
+func (m InterestMode) Valid() bool {
+	switch m {
+	case "", InterestCompound, InterestSimple:
+		return true
+	}
+	return false
+}
+
+// LoanType distinguishes revolving lines of credit from installment loans. The empty type is
+// an installment loan funded in full at origination.
+type LoanType string
+
+const (
+	LoanTypeLineOfCredit LoanType = "line_of_credit" // Funded by draws; stays open when paid down to zero
+)
+
+// CollateralType classifies an asset securing a loan.
+type CollateralType string
+
+const (
+	CollateralVehicle    CollateralType = "vehicle"
+	CollateralRealEstate CollateralType = "real_estate"
+	CollateralDeposit    CollateralType = "deposit"
+	CollateralEquipment  CollateralType = "equipment"
+	CollateralOther      CollateralType = "other"
+)
+
+// Valid reports whether the collateral type is a known value.
+func (t CollateralType) Valid() bool {
+	switch t {
+	case CollateralVehicle, CollateralRealEstate, CollateralDeposit, CollateralEquipment, CollateralOther:
+		return true
+	}
+	return false
+}
+
+// Collateral is an asset pledged to secure a loan, with its most recent valuation.
+type Collateral struct {
+	ID            uuid.UUID       `json:"id"`
+	LoanID        uuid.UUID       `json:"loan_id"`
+	Type          CollateralType  `json:"type"`
+	Description   string          `json:"description"`
+	Valuation     decimal.Decimal `json:"valuation"`
+	ValuationDate time.Time       `json:"valuation_date"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+// Forbearance is a window during which a loan accrues interest at a reduced rate, or not at
+// all, and its delinquency aging is paused.
+type Forbearance struct {
+	ID        uuid.UUID       `json:"id"`
+	LoanID    uuid.UUID       `json:"loan_id"`
+	StartDate time.Time       `json:"start_date"`
+	EndDate   time.Time       `json:"end_date"` // Last day of the window, inclusive
+	Rate      decimal.Decimal `json:"rate"`     // APR accrued during the window; zero suspends accrual
+	Reason    string          `json:"reason"`
+	CreatedBy string          `json:"created_by,omitempty"` // Servicing agent who granted the forbearance
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Accrual records one day's interest accrual on a loan and the inputs it was computed from.
+type Accrual struct {
+	LoanID    uuid.UUID       `json:"loan_id"`
+	Date      time.Time       `json:"date"`
+	Balance   decimal.Decimal `json:"balance"` // Balance interest accrued on
+	Rate      decimal.Decimal `json:"rate"`    // APR in effect, after any promo or forbearance
+	Amount    decimal.Decimal `json:"amount"`  // Interest accrued, including any odd-days interest billed that day
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Product defines servicing terms shared by every loan originated under it.
+type Product struct {
+	Code                  string          `json:"code"`
+	Name                  string          `json:"name"`
+	AccrueAfterChargeOff  bool            `json:"accrue_after_charge_off"` // Keep accruing recovery interest on charged-off loans
+	CreatedAt             time.Time       `json:"created_at"`
+	UpdatedAt             time.Time       `json:"updated_at"`
+	OddDaysInterest       OddDaysPolicy   `json:"odd_days_interest,omitempty"`  // Charge or waive interest for the stub period of loans disbursed mid-cycle
+	MinimumPaymentFloor   decimal.Decimal `json:"minimum_payment_floor"`        // Smallest minimum due; with MinimumPaymentPercent, zero uses the ledger default policy
+	MinimumPaymentPercent decimal.Decimal `json:"minimum_payment_percent"`      // Fraction of the balance due each cycle, on top of the cycle's interest and fees
+	AccrualGraceDays      int             `json:"accrual_grace_days,omitempty"` // Days after disbursement before interest starts accruing
+}
+
+// OddDaysPolicy determines how interest is handled for the stub period between disbursement
+// and the first statement date of a loan disbursed mid-cycle.
+type OddDaysPolicy string
+
+const (
+	OddDaysCharge OddDaysPolicy = "charge" // Fixed at origination and billed on the first statement
+	OddDaysWaive  OddDaysPolicy = "waive"  // No interest accrues until the first statement date
+)
+
+// Valid reports whether the policy is a known value. The empty policy accrues stub interest
+// daily like any other period.
+func (p OddDaysPolicy) Valid() bool {
+	switch p {
+	case "", OddDaysCharge, OddDaysWaive:
+		return true
+	}
+	return false
+}
+
+// RoundingMode selects how interest amounts are rounded.
+type RoundingMode string
+
+const (
+	RoundingNone     RoundingMode = ""          // Full precision
+	RoundingHalfUp   RoundingMode = "half_up"   // Halves round away from zero
+	RoundingHalfEven RoundingMode = "half_even" // Banker's rounding: halves round to the even digit
+)
+
+// RoundingPolicy governs how interest is rounded as it accrues each day and when a cycle's
+// accrued interest is capitalized, which always rounds to cents.
+type RoundingPolicy struct {
+	Mode          RoundingMode `json:"mode"`
+	Places        int32        `json:"places"`         // Accrual precision: 2 for cents, 3 for mills
+	TrackResidual bool         `json:"track_residual"` // Carry rounded-off fractions forward instead of dropping them
+}
+
+// MinimumPaymentPolicy determines a statement's minimum due: the greater of Floor and
+// Percent of the balance plus the cycle's interest and fees, but never more than the balance.
+type MinimumPaymentPolicy struct {
+	Floor   decimal.Decimal `json:"floor"`
+	Percent decimal.Decimal `json:"percent"`
+}
+
+// Statement summarizes a loan's activity over one statement cycle.
+type Statement struct {
+	ID              uuid.UUID       `json:"id"`
+	LoanID          uuid.UUID       `json:"loan_id"`
+	Cycle           string          `json:"cycle"` // YYYY-MM
+	PeriodStart     time.Time       `json:"period_start"`
+	StatementDate   time.Time       `json:"statement_date"`
+	Balance         decimal.Decimal `json:"balance"` // Including amounts due, after the cycle's interest was applied
+	InterestCharged decimal.Decimal `json:"interest_charged"`
+	Payments        decimal.Decimal `json:"payments"`
+	Fees            decimal.Decimal `json:"fees"`
+	OddDays         int             `json:"odd_days,omitempty"`        // Stub days disclosed on the first statement
+	OddDaysInterest decimal.Decimal `json:"odd_days_interest"`         // Stub interest included in InterestCharged
+	OddDaysPolicy   OddDaysPolicy   `json:"odd_days_policy,omitempty"` // Set on the first statement only
+	MinimumDue      decimal.Decimal `json:"minimum_due"`               // Smallest payment by DueDate that keeps the loan current
+	DueDate         time.Time       `json:"due_date"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// RateChange is an effective-dated change to a loan's pricing. The history of rate changes
+// determines which rate applies on each accrual day.
+type RateChange struct {
+	ID                   uuid.UUID       `json:"id"`
+	LoanID               uuid.UUID       `json:"loan_id"`
+	BaseInterestRate     decimal.Decimal `json:"base_interest_rate"`
+	InterestRateVariance decimal.Decimal `json:"interest_rate_variance"`
+	InterestRate         decimal.Decimal `json:"interest_rate"`  // Resulting effective APR
+	EffectiveDate        time.Time       `json:"effective_date"` // First accrual day (UTC midnight) the rate applies to
+	CreatedAt            time.Time       `json:"created_at"`
+}
+
+// IndexRate is a published observation of a benchmark rate such as SOFR or the prime rate.
+// Loans tied to an index are repriced whenever a new observation is published.
+type IndexRate struct {
+	ID              uuid.UUID       `json:"id"`
+	IndexCode       string          `json:"index_code"`       // Series identifier, e.g. "SOFR" or "DPRIME"
+	Rate            decimal.Decimal `json:"rate"`             // Annual rate as a fraction (0.0531 for 5.31%)
+	ObservationDate time.Time       `json:"observation_date"` // Day the rate was observed by the publisher
+	Source          string          `json:"source"`           // "fred" or "manual"
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+// ScheduleEntry is one scheduled payment in a loan's amortization schedule.
+type ScheduleEntry struct {
+	Number    int             `json:"number"`
+	DueDate   time.Time       `json:"due_date"`
+	Payment   decimal.Decimal `json:"payment"`
+	Principal decimal.Decimal `json:"principal"`
+	Interest  decimal.Decimal `json:"interest"`
+	Balance   decimal.Decimal `json:"balance"`           // Principal remaining after the payment
+	Balloon   bool            `json:"balloon,omitempty"` // Final payment retiring the unamortized balance
+}
+
+// AmortizationSchedule is the contractual payment schedule of a term loan.
+type AmortizationSchedule struct {
+	LoanID             uuid.UUID        `json:"loan_id"`
+	TermMonths         int              `json:"term_months"`
+	AmortizationMonths int              `json:"amortization_months"`
+	MonthlyPayment     decimal.Decimal  `json:"monthly_payment"`
+	MaturityDate       time.Time        `json:"maturity_date"`
+	BalloonAmount      decimal.Decimal  `json:"balloon_amount"` // Final payment due at maturity; zero for fully amortizing loans
+	Entries            []*ScheduleEntry `json:"entries"`
+}
+
+// PortfolioSnapshot captures portfolio-wide totals at the end of a day, recorded by the daily
+// batch so trend reports don't have to recompute the past.
+type PortfolioSnapshot struct {
+	Date               time.Time       `json:"date"`                // UTC day the snapshot describes
+	OutstandingBalance decimal.Decimal `json:"outstanding_balance"` // Balance of active loans
+	AccruedInterest    decimal.Decimal `json:"accrued_interest"`    // Interest accrued on active loans, not yet applied
+	ActiveLoans        int             `json:"active_loans"`
+	DelinquentLoans    int             `json:"delinquent_loans"`
+	DelinquencyRate    decimal.Decimal `json:"delinquency_rate"` // DelinquentLoans / ActiveLoans
+	Originations       int             `json:"originations"`     // Loans originated during the period
+	OriginationVolume  decimal.Decimal `json:"origination_volume"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+// IntentStatus tracks an interest intent through the monthly application job.
+type IntentStatus string
+
+const (
+	IntentPending   IntentStatus = "pending"
+	IntentCompleted IntentStatus = "completed"
+)
+
+// InterestIntent is a write-ahead record of the interest the monthly application job is about
+// to post to a loan for a statement cycle. Intents left pending by a crash are resumed on the
+// next run, and completed intents show which loans each run touched.
+type InterestIntent struct {
+	ID            uuid.UUID       `json:"id"`
+	LoanID        uuid.UUID       `json:"loan_id"`
+	Cycle         string          `json:"cycle"` // Statement cycle, YYYY-MM
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID uuid.UUID       `json:"transaction_id"` // ID reserved for the interest transaction
+	Status        IntentStatus    `json:"status"`
+	CreatedAt     time.Time       `json:"created_at"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty"`
+}
+
+// PaymentLinkStatus tracks whether a payment link can still be used.
+type PaymentLinkStatus string
+
+const (
+	PaymentLinkActive   PaymentLinkStatus = "active"
+	PaymentLinkRedeemed PaymentLinkStatus = "redeemed"
+)
+
+// PaymentLink is a single-use, expiring authorization for a borrower to pay a loan an amount
+// within a range. It is handed out as a signed token that doesn't reveal the loan ID.
+type PaymentLink struct {
+	ID            uuid.UUID         `json:"id"`
+	LoanID        uuid.UUID         `json:"loan_id"`
+	MinAmount     decimal.Decimal   `json:"min_amount"`
+	MaxAmount     decimal.Decimal   `json:"max_amount"`
+	Status        PaymentLinkStatus `json:"status"`
+	ExpiresAt     time.Time         `json:"expires_at"`
+	TransactionID *uuid.UUID        `json:"transaction_id,omitempty"` // Payment made with the link
+	CreatedAt     time.Time         `json:"created_at"`
+	RedeemedAt    *time.Time        `json:"redeemed_at,omitempty"`
+}
+
+// PayoffQuote is the amount needed to pay a loan off in full on a given date.
+type PayoffQuote struct {
+	LoanID            uuid.UUID        `json:"loan_id"`
+	GoodThrough       time.Time        `json:"good_through"`       // Payoff date the quote is valid for
+	Principal         decimal.Decimal  `json:"principal"`          // Outstanding balance
+	AccruedInterest   decimal.Decimal  `json:"accrued_interest"`   // Interest accrued through GoodThrough
+	PerDiem           decimal.Decimal  `json:"per_diem"`           // Interest added for each day past GoodThrough
+	PrepaymentPenalty decimal.Decimal  `json:"prepayment_penalty"` // Penalty charged on paying off on GoodThrough
+	Fees              decimal.Decimal  `json:"fees"`               // Total fees included in the payoff
+	Total             decimal.Decimal  `json:"total"`
+	BalloonAmount     *decimal.Decimal `json:"balloon_amount,omitempty"` // Scheduled balloon at maturity, for balloon loans
+	MaturityDate      *time.Time       `json:"maturity_date,omitempty"`
+}
+
+// BureauRecord is a loan's account status for one reporting period, as furnished to credit
+// bureaus. Records are kept so that later periods can report the payment history.
+type BureauRecord struct {
+	LoanID         uuid.UUID       `json:"loan_id"`
+	Period         string          `json:"period"` // Reporting month, YYYY-MM
+	CustomerKey    string          `json:"customer_key"`
+	ProductCode    string          `json:"product_code,omitempty"`
+	DateOpened     time.Time       `json:"date_opened"`
+	OriginalAmount decimal.Decimal `json:"original_amount"`
+	CurrentBalance decimal.Decimal `json:"current_balance"`
+	TermMonths     int             `json:"term_months,omitempty"`
+	AccountStatus  string          `json:"account_status"`  // Metro 2 account status code, e.g. "11" current, "97" charged off
+	DaysPastDue    int             `json:"days_past_due"`   // As of the end of the period
+	PaymentHistory string          `json:"payment_history"` // Up to 24 monthly codes, most recent first
+	AsOf           time.Time       `json:"as_of"`           // Date of account information
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// DelinquencyBucket groups past-due loans into the aging ranges used by collections.
+type DelinquencyBucket string
+
+const (
+	DelinquencyCurrent DelinquencyBucket = "current"
+	Delinquency1To29   DelinquencyBucket = "1-29"
+	Delinquency30      DelinquencyBucket = "30-59"
+	Delinquency60      DelinquencyBucket = "60-89"
+	Delinquency90      DelinquencyBucket = "90-119"
+	Delinquency120     DelinquencyBucket = "120+"
+)
+
+// Valid reports whether b is one of the defined aging buckets.
+func (b DelinquencyBucket) Valid() bool {
+	switch b {
+	case DelinquencyCurrent, Delinquency1To29, Delinquency30, Delinquency60, Delinquency90, Delinquency120:
+		return true
+	}
+	return false
+}
+
+// BucketForDaysPastDue returns the aging bucket for the given number of days past due.
+func BucketForDaysPastDue(daysPastDue int) DelinquencyBucket {
+	switch {
+	case daysPastDue >= 120:
+		return Delinquency120
+	case daysPastDue >= 90:
+		return Delinquency90
+	case daysPastDue >= 60:
+		return Delinquency60
+	case daysPastDue >= 30:
+		return Delinquency30
+	case daysPastDue > 0:
+		return Delinquency1To29
+	default:
+		return DelinquencyCurrent
+	}
 }
 
 type TransactionType string
@@ -28,12 +566,406 @@ const (
 	TransactionTypeDisbursement TransactionType = "disbursement"
 	TransactionTypePayment      TransactionType = "payment"
 	TransactionTypeInterest     TransactionType = "interest"
+	TransactionTypeChargeOff    TransactionType = "charge_off"
+	TransactionTypeRecovery     TransactionType = "recovery"
+	TransactionTypeRefinance    TransactionType = "refinance_payoff"
+	TransactionTypeFee          TransactionType = "fee"           // Prepayment penalty
+	TransactionTypeEscrowCredit TransactionType = "escrow_credit" // Payment portion deposited into escrow
+	TransactionTypeEscrowDebit  TransactionType = "escrow_debit"  // Escrow disbursement for taxes or insurance
+
+	TransactionTypeOriginationFee TransactionType = "origination_fee" // Charged when the loan is opened
+	TransactionTypeServicingFee   TransactionType = "servicing_fee"   // Charged for servicing the account
 )
 
+// Valid reports whether the transaction type is a known value.
+func (t TransactionType) Valid() bool {
+	switch t {
+	case TransactionTypeDisbursement, TransactionTypePayment, TransactionTypeInterest, TransactionTypeChargeOff,
+		TransactionTypeRecovery, TransactionTypeRefinance, TransactionTypeFee, TransactionTypeEscrowCredit,
+		TransactionTypeEscrowDebit, TransactionTypeOriginationFee, TransactionTypeServicingFee:
+		return true
+	}
+	return false
+}
+
 type Transaction struct {
+	ID              uuid.UUID         `json:"id"`
+	LoanID          uuid.UUID         `json:"loan_id"`
+	Amount          decimal.Decimal   `json:"amount"`
+	Type            TransactionType   `json:"type"`
+	Timestamp       time.Time         `json:"timestamp"`
+	PaymentMethodID *uuid.UUID        `json:"payment_method_id,omitempty"` // Funding source for payments, if known
+	Source          string            `json:"source,omitempty"`            // Channel that originated a payment; empty for payments posted through the API
+	Capitalized     bool              `json:"capitalized,omitempty"`       // Whether a fee was added to the balance rather than billed separately
+	Reference       string            `json:"reference,omitempty"`         // External reference for the payment, e.g. from a bank file
+	Metadata        map[string]string `json:"metadata,omitempty"`          // Free-form details, such as the payment channel or the operator who posted it
+}
+
+// PaymentPreview is how a payment would be applied to a loan and what the loan would look like
+// afterwards, computed without recording anything.
+type PaymentPreview struct {
+	LoanID            uuid.UUID       `json:"loan_id"`
+	Amount            decimal.Decimal `json:"amount"`             // Applied to the loan, net of the escrow deposit
+	EscrowAmount      decimal.Decimal `json:"escrow_amount"`      // Deposited into escrow
+	Type              TransactionType `json:"type"`               // payment, or recovery on a charged-off loan
+	FeesPaid          decimal.Decimal `json:"fees_paid"`          // Billed fees due
+	InterestPaid      decimal.Decimal `json:"interest_paid"`      // Billed interest due
+	PrepaymentPenalty decimal.Decimal `json:"prepayment_penalty"` // Charged for prepaying principal and paid before it; any part left unpaid is added to the balance
+	PrincipalPaid     decimal.Decimal `json:"principal_paid"`
+	Unapplied         decimal.Decimal `json:"unapplied"` // Beyond what is owed; a payment is never applied past a zero balance
+	Balance           decimal.Decimal `json:"balance"`
+	FeesDue           decimal.Decimal `json:"fees_due"`
+	InterestDue       decimal.Decimal `json:"interest_due"`
+	EscrowBalance     decimal.Decimal `json:"escrow_balance"`
+	Status            LoanStatus      `json:"status"`
+}
+
+// TransactionQuery selects a page of a loan's transactions matching optional filters, oldest
+// first. Unset bounds and an empty type set match every transaction; ranges are inclusive.
+// A zero limit returns every match.
+type TransactionQuery struct {
+	Types     []TransactionType `json:"types,omitempty"` // Any of these types
+	From      *time.Time        `json:"from,omitempty"`
+	To        *time.Time        `json:"to,omitempty"`
+	MinAmount *decimal.Decimal  `json:"min_amount,omitempty"`
+	MaxAmount *decimal.Decimal  `json:"max_amount,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"` // Transactions whose metadata has every one of these values
+	Limit     int               `json:"limit"`
+	Offset    int               `json:"offset"`
+}
+
+// Payment sources other than direct posting through the API.
+const (
+	TransactionSourceAutopay     = "autopay"
+	TransactionSourcePaymentLink = "payment_link"
+	TransactionSourceImport      = "import"
+)
+
+// PaymentImportReport is the outcome of importing a file of payments. Each row is applied or
+// fails on its own, so one bad row does not hold back the rest of the file.
+type PaymentImportReport struct {
+	Applied int                `json:"applied"`
+	Failed  int                `json:"failed"`
+	Rows    []PaymentImportRow `json:"rows"`
+}
+
+// PaymentImportRow is the outcome of one row of a payment import.
+type PaymentImportRow struct {
+	Line          int        `json:"line"` // Line of the row in the file
+	LoanID        string     `json:"loan_id"`
+	Amount        string     `json:"amount"`
+	Date          string     `json:"date"`
+	Reference     string     `json:"reference,omitempty"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty"` // Set when the payment was applied
+	Error         string     `json:"error,omitempty"`          // Set when the row failed
+}
+
+// AutopayAmountType selects how much an autopay enrollment pays each month.
+type AutopayAmountType string
+
+const (
+	AutopayFixedAmount      AutopayAmountType = "amount"            // The enrollment's Amount
+	AutopayMinimumDue       AutopayAmountType = "minimum_due"       // The unpaid minimum due on the latest statement
+	AutopayStatementBalance AutopayAmountType = "statement_balance" // The unpaid balance on the latest statement
+)
+
+// AutopayEnrollment schedules a monthly payment for a loan from a verified payment method.
+type AutopayEnrollment struct {
+	LoanID          uuid.UUID         `json:"loan_id"`
+	AmountType      AutopayAmountType `json:"amount_type"`
+	Amount          decimal.Decimal   `json:"amount"`       // Used with AutopayFixedAmount
+	DayOfMonth      int               `json:"day_of_month"` // 1-28
+	PaymentMethodID uuid.UUID         `json:"payment_method_id"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// LoanEventType identifies a non-monetary event in a loan's history.
+type LoanEventType string
+
+const (
+	LoanEventStatusChange            LoanEventType = "status_change"
+	LoanEventRateChange              LoanEventType = "rate_change"
+	LoanEventStatement               LoanEventType = "statement"
+	LoanEventNote                    LoanEventType = "note"
+	LoanEventNotification            LoanEventType = "notification"
+	LoanEventCorrection              LoanEventType = "correction"
+	LoanEventForbearance             LoanEventType = "forbearance"
+	LoanEventNegativeAmortizationCap LoanEventType = "negative_amortization_cap"
+)
+
+// LoanEvent records a non-monetary change or annotation on a loan, such as a status change
+// or a servicing note. Monetary changes are recorded as Transactions.
+type LoanEvent struct {
+	ID          uuid.UUID     `json:"id"`
+	LoanID      uuid.UUID     `json:"loan_id"`
+	Type        LoanEventType `json:"type"`
+	Description string        `json:"description"`
+	Author      string        `json:"author,omitempty"` // Servicing agent or system component that recorded the event
+	Timestamp   time.Time     `json:"timestamp"`
+}
+
+// FieldChange is a difference between a loan's stored and recomputed value of one field.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// LoanRecalculation is the result of recomputing a loan from its transactions and rate
+// history.
+type LoanRecalculation struct {
+	LoanID    uuid.UUID     `json:"loan_id"`
+	Before    *Loan         `json:"before"`
+	After     *Loan         `json:"after"`
+	Changes   []FieldChange `json:"changes"`
+	Committed bool          `json:"committed"`
+	Warnings  []string      `json:"warnings,omitempty"`
+}
+
+// LedgerEventType names an entry in a loan's event log.
+type LedgerEventType string
+
+const (
+	LedgerLoanCreated     LedgerEventType = "loan_created"     // Amount is the principal disbursed
+	LedgerPaymentRecorded LedgerEventType = "payment_recorded" // Amount is the payment or recovery
+	LedgerInterestAccrued LedgerEventType = "interest_accrued" // Amount is the day's interest
+	LedgerInterestApplied LedgerEventType = "interest_applied" // Amount is the cycle's interest capitalized or billed
+	LedgerLoanAdjusted    LedgerEventType = "loan_adjusted"    // Any other change to the amounts, such as a fee, draw, charge-off or correction
+)
+
+// LedgerEvent is an entry in a loan's append-only event log, kept when the ledger runs in
+// event-sourced mode. It records how the event changed each of the loan's amounts and what
+// they came to, so the amounts can be rebuilt by adding up the changes, and an audit can
+// see exactly what produced every figure.
+type LedgerEvent struct {
+	ID            uuid.UUID       `json:"id"`
+	LoanID        uuid.UUID       `json:"loan_id"`
+	Sequence      int             `json:"sequence"` // Position in the loan's log, from 1
+	Type          LedgerEventType `json:"type"`
+	Amount        decimal.Decimal `json:"amount"`
+	TransactionID *uuid.UUID      `json:"transaction_id,omitempty"` // Transaction posted with the event, if any
+	Changes       LoanAmounts     `json:"changes"`                  // Change the event made to each amount
+	Amounts       LoanAmounts     `json:"amounts"`                  // Amounts after the event
+	OccurredAt    time.Time       `json:"occurred_at"`
+}
+
+// LoanAmounts are the figures of a loan its event log accounts for.
+type LoanAmounts struct {
+	Balance         decimal.Decimal `json:"balance"`
+	AccruedInterest decimal.Decimal `json:"accrued_interest"`
+	InterestDue     decimal.Decimal `json:"interest_due"`
+	FeesDue         decimal.Decimal `json:"fees_due"`
+	EscrowBalance   decimal.Decimal `json:"escrow_balance"`
+}
+
+// LoanProjection is a loan's amounts rebuilt from its event log, with any differences from
+// the amounts stored on the loan.
+type LoanProjection struct {
+	LoanID      uuid.UUID     `json:"loan_id"`
+	Events      int           `json:"events"`
+	Amounts     LoanAmounts   `json:"amounts"`
+	Differences []FieldChange `json:"differences"` // Stored values as Before, rebuilt as After
+}
+
+// TimelineEntry is one item in a loan's merged, chronologically ordered history.
+type TimelineEntry struct {
+	Timestamp   time.Time        `json:"timestamp"`
+	Category    string           `json:"category"` // "transaction" or "event"
+	Type        string           `json:"type"`
+	Description string           `json:"description"`
+	Amount      *decimal.Decimal `json:"amount,omitempty"`
+	ReferenceID uuid.UUID        `json:"reference_id"` // ID of the underlying transaction or event
+}
+
+// PaymentMethodType identifies the kind of funding source behind a payment method.
+type PaymentMethodType string
+
+const (
+	PaymentMethodCard        PaymentMethodType = "card"
+	PaymentMethodBankAccount PaymentMethodType = "bank_account"
+)
+
+// PaymentMethodStatus tracks a payment method through its lifecycle.
+type PaymentMethodStatus string
+
+const (
+	PaymentMethodPending  PaymentMethodStatus = "pending_verification"
+	PaymentMethodVerified PaymentMethodStatus = "verified"
+	PaymentMethodExpired  PaymentMethodStatus = "expired"
+)
+
+// PaymentMethod is a tokenized reference to a borrower's card or bank account held by the
+// payment processor. The ledger never stores raw card or account numbers.
+type PaymentMethod struct {
+	ID          uuid.UUID           `json:"id"`
+	CustomerKey string              `json:"customer_key"`
+	Type        PaymentMethodType   `json:"type"`
+	Token       string              `json:"token"`           // Processor token referencing the instrument
+	Last4       string              `json:"last4,omitempty"` // Display hint only
+	Label       string              `json:"label,omitempty"` // e.g., card brand or bank name
+	Status      PaymentMethodStatus `json:"status"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// WebhookEventType names a ledger event that webhook subscribers can receive.
+type WebhookEventType string
+
+const (
+	WebhookLoanCreated     WebhookEventType = "loan.created"     // Data is the loan
+	WebhookPaymentRecorded WebhookEventType = "payment.recorded" // Data is the payment or recovery transaction
+	WebhookInterestApplied WebhookEventType = "interest.applied" // Data is the monthly interest transaction
+	WebhookLoanClosed      WebhookEventType = "loan.closed"      // Data is the loan
+)
+
+// Valid reports whether the event type is one subscribers can receive.
+func (t WebhookEventType) Valid() bool {
+	switch t {
+	case WebhookLoanCreated, WebhookPaymentRecorded, WebhookInterestApplied, WebhookLoanClosed:
+		return true
+	}
+	return false
+}
+
+// WebhookSubscription registers a URL to receive ledger events as signed JSON POSTs.
+type WebhookSubscription struct {
+	ID        uuid.UUID          `json:"id"`
+	URL       string             `json:"url"`
+	Events    []WebhookEventType `json:"events"`
+	Secret    string             `json:"secret,omitempty"` // HMAC key for the X-Webhook-Signature header; only returned when the subscription is created
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// WebhookEvent is the JSON body POSTed to subscribers.
+type WebhookEvent struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      WebhookEventType `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      any              `json:"data"`
+}
+
+// WebhookDeliveryStatus tracks a delivery through its retries.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed" // Gave up after the maximum number of attempts
+)
+
+// WebhookDelivery is one event queued for one subscriber. Deliveries are queued by the outbox
+// relay and sent by the delivery worker, so an unreachable subscriber is retried rather than
+// missing the event.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `json:"id"`
+	SubscriptionID uuid.UUID             `json:"subscription_id"`
+	EventID        uuid.UUID             `json:"event_id"`
+	EventType      WebhookEventType      `json:"event_type"`
+	Payload        []byte                `json:"-"` // Encoded WebhookEvent
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	LastError      string                `json:"last_error,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// OutboxEvent is a webhook event waiting in the transactional outbox. It is written in the
+// same database transaction as the change that raised it, so it exists exactly when the
+// change does, and the outbox relay later queues it for its subscribers and marks it
+// published.
+type OutboxEvent struct {
+	ID          uuid.UUID        `json:"id"` // ID of the WebhookEvent, which consumers can deduplicate on
+	Type        WebhookEventType `json:"type"`
+	Payload     []byte           `json:"-"` // Encoded WebhookEvent
+	CreatedAt   time.Time        `json:"created_at"`
+	PublishedAt *time.Time       `json:"published_at,omitempty"`
+}
+
+// StreamEventType identifies an event pushed to live event stream clients.
+type StreamEventType string
+
+const (
+	StreamTransactionCreated StreamEventType = "transaction.created"
+	StreamLoanStatusChanged  StreamEventType = "loan.status_changed"
+	StreamAccrualRecorded    StreamEventType = "accrual.recorded"
+)
+
+// LoanStatusChange is the data of a loan.status_changed stream event.
+type LoanStatusChange struct {
+	LoanID uuid.UUID  `json:"loan_id"`
+	From   LoanStatus `json:"from"`
+	To     LoanStatus `json:"to"`
+}
+
+// StreamEvent is a ledger change pushed to live event stream clients as it happens. Data is
+// a *Transaction, a LoanStatusChange or an *Accrual.
+type StreamEvent struct {
 	ID        uuid.UUID       `json:"id"`
+	Type      StreamEventType `json:"type"`
 	LoanID    uuid.UUID       `json:"loan_id"`
-	Amount    decimal.Decimal `json:"amount"`
-	Type      TransactionType `json:"type"`
-	Timestamp time.Time       `json:"timestamp"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      any             `json:"data"`
+}
+
+// JobName identifies a batch job.
+type JobName string
+
+const (
+	JobRefreshIndexRates     JobName = "refresh_index_rates"
+	JobDailyInterest         JobName = "daily_interest"
+	JobPostChargeOffInterest JobName = "post_charge_off_interest"
+	JobMonthlyInterest       JobName = "monthly_interest"
+	JobStatements            JobName = "statements"
+	JobAutopay               JobName = "autopay"
+	JobDelinquency           JobName = "delinquency"
+	JobAutoChargeOff         JobName = "auto_charge_off"
+	JobPortfolioSnapshot     JobName = "portfolio_snapshot"
+	JobBureauExport          JobName = "bureau_export"
+	JobArchive               JobName = "archive"
+	JobArchiveExport         JobName = "archive_export"
+)
+
+// Valid reports whether the name is one of the batch jobs.
+func (j JobName) Valid() bool {
+	switch j {
+	case JobRefreshIndexRates, JobDailyInterest, JobPostChargeOffInterest, JobMonthlyInterest, JobStatements,
+		JobAutopay, JobDelinquency, JobAutoChargeOff, JobPortfolioSnapshot, JobBureauExport, JobArchive, JobArchiveExport:
+		return true
+	}
+	return false
+}
+
+// JobTrigger records what started a job run.
+type JobTrigger string
+
+const (
+	JobTriggerSchedule JobTrigger = "schedule" // The background batch
+	JobTriggerManual   JobTrigger = "manual"   // An admin endpoint
+)
+
+// JobRun is the outcome of one run of a batch job. Jobs that don't track loans leave the
+// counts at zero.
+type JobRun struct {
+	ID         uuid.UUID  `json:"id"`
+	Job        JobName    `json:"job"`
+	Trigger    JobTrigger `json:"trigger"`
+	Date       time.Time  `json:"date"`              // Accrual or statement date the run was for
+	LoanID     *uuid.UUID `json:"loan_id,omitempty"` // Set when the run was scoped to one loan
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt time.Time  `json:"finished_at"`
+	Processed  int        `json:"processed"` // Loans the job changed
+	Skipped    int        `json:"skipped"`   // Loans with nothing to do, e.g. already accrued for the date
+	Failed     int        `json:"failed"`
+	Errors     []string   `json:"errors,omitempty"` // One per failed loan, or the error that stopped the run
+}
+
+// ArchiveExport is the outcome of exporting archived loans to object storage.
+type ArchiveExport struct {
+	Objects      []string `json:"objects"` // Keys of the objects written, one per batch of loans
+	Loans        int      `json:"loans"`
+	Transactions int      `json:"transactions"`
+	Pruned       bool     `json:"pruned"` // Whether the exported loans were deleted from the archive
 }
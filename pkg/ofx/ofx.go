@@ -0,0 +1,166 @@
+// Package ofx writes account statements in the Open Financial Exchange 2.2 XML format that
+// personal finance and accounting software imports. Only the parts of the specification a
+// downloaded statement needs are covered: the sign-on response and one bank statement.
+package ofx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Account types for Statement.AccountType.
+const (
+	AccountChecking   = "CHECKING"
+	AccountSavings    = "SAVINGS"
+	AccountCreditLine = "CREDITLINE" // A loan or line of credit
+)
+
+// Transaction types for Transaction.Type.
+const (
+	TypeCredit  = "CREDIT"
+	TypeDebit   = "DEBIT"
+	TypeInt     = "INT" // Interest earned or paid
+	TypeFee     = "FEE"
+	TypePayment = "PAYMENT"
+)
+
+// maxNameLength is the longest NAME the specification allows.
+const maxNameLength = 32
+
+// header is the OFX 2 processing instruction that follows the XML declaration.
+const header = `<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>`
+
+// Statement is one account's activity over a period.
+type Statement struct {
+	BankID       string // Identifies the institution; importers only need it to be stable
+	AccountID    string
+	AccountType  string
+	Currency     string // ISO 4217, e.g. USD
+	Start        time.Time
+	End          time.Time
+	Balance      decimal.Decimal // Ledger balance, signed from the account holder's side
+	BalanceAsOf  time.Time
+	Generated    time.Time // Server time in the sign-on response
+	Transactions []Transaction
+}
+
+// Transaction is one statement line.
+type Transaction struct {
+	ID     string // Unique and stable across downloads, so re-imports don't duplicate it
+	Type   string
+	Posted time.Time
+	Amount decimal.Decimal // Positive for money into the account, negative for money out
+	Name   string          // Payee or description; truncated to 32 characters
+	Memo   string
+}
+
+// Write writes the statement as an OFX 2.2 document.
+func Write(w io.Writer, s Statement) error {
+	status := ofxStatus{Code: 0, Severity: "INFO"}
+	doc := ofxDocument{
+		SignOn: ofxSignOn{Status: status, ServerDate: formatTime(s.Generated), Language: "ENG"},
+		Bank: ofxBank{
+			Status: status,
+			TrnUID: "0",
+			Statement: ofxStatement{
+				Currency: s.Currency,
+				Account:  ofxAccount{BankID: s.BankID, AccountID: s.AccountID, AccountType: s.AccountType},
+				List: ofxTransactionList{
+					Start: formatTime(s.Start),
+					End:   formatTime(s.End),
+				},
+				Balance: ofxBalance{Amount: s.Balance.StringFixed(2), AsOf: formatTime(s.BalanceAsOf)},
+			},
+		},
+	}
+	for _, t := range s.Transactions {
+		name := []rune(t.Name)
+		if len(name) > maxNameLength {
+			name = name[:maxNameLength]
+		}
+		doc.Bank.Statement.List.Transactions = append(doc.Bank.Statement.List.Transactions, ofxTransaction{
+			Type:   t.Type,
+			Posted: formatTime(t.Posted),
+			Amount: t.Amount.StringFixed(2),
+			ID:     t.ID,
+			Name:   string(name),
+			Memo:   t.Memo,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header+header+"\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode OFX: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// formatTime formats a time as an OFX datetime in GMT.
+func formatTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+type ofxDocument struct {
+	XMLName xml.Name  `xml:"OFX"`
+	SignOn  ofxSignOn `xml:"SIGNONMSGSRSV1>SONRS"`
+	Bank    ofxBank   `xml:"BANKMSGSRSV1>STMTTRNRS"`
+}
+
+type ofxStatus struct {
+	Code     int    `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxSignOn struct {
+	Status     ofxStatus `xml:"STATUS"`
+	ServerDate string    `xml:"DTSERVER"`
+	Language   string    `xml:"LANGUAGE"`
+}
+
+type ofxBank struct {
+	TrnUID    string       `xml:"TRNUID"`
+	Status    ofxStatus    `xml:"STATUS"`
+	Statement ofxStatement `xml:"STMTRS"`
+}
+
+type ofxStatement struct {
+	Currency string             `xml:"CURDEF"`
+	Account  ofxAccount         `xml:"BANKACCTFROM"`
+	List     ofxTransactionList `xml:"BANKTRANLIST"`
+	Balance  ofxBalance         `xml:"LEDGERBAL"`
+}
+
+type ofxAccount struct {
+	BankID      string `xml:"BANKID"`
+	AccountID   string `xml:"ACCTID"`
+	AccountType string `xml:"ACCTTYPE"`
+}
+
+type ofxTransactionList struct {
+	Start        string           `xml:"DTSTART"`
+	End          string           `xml:"DTEND"`
+	Transactions []ofxTransaction `xml:"STMTTRN"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	ID     string `xml:"FITID"`
+	Name   string `xml:"NAME,omitempty"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
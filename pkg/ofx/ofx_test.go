@@ -0,0 +1,68 @@
+package ofx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestWrite(t *testing.T) {
+	posted := time.Date(2024, 3, 15, 9, 30, 0, 0, time.UTC)
+	statement := Statement{
+		BankID:      "FREDLOAN",
+		AccountID:   "loan-1",
+		AccountType: AccountCreditLine,
+		Currency:    "USD",
+		Start:       time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:         time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		Balance:     decimal.RequireFromString("-900.5"),
+		BalanceAsOf: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC),
+		Generated:   time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC),
+		Transactions: []Transaction{
+			{ID: "tx-1", Type: TypePayment, Posted: posted, Amount: decimal.NewFromInt(100), Name: "Payment", Memo: "ref <1> & co"},
+			{ID: "tx-2", Type: TypeInt, Posted: posted, Amount: decimal.RequireFromString("-0.5"), Name: strings.Repeat("x", 40)},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, statement); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, xml.Header+header) {
+		t.Errorf("Expected XML declaration and OFX header, got %q", out[:min(len(out), 120)])
+	}
+	for _, want := range []string{
+		"<DTSERVER>20240401120000.000[0:GMT]</DTSERVER>",
+		"<ACCTTYPE>CREDITLINE</ACCTTYPE>",
+		"<DTSTART>20240301000000.000[0:GMT]</DTSTART>",
+		"<TRNTYPE>PAYMENT</TRNTYPE>",
+		"<DTPOSTED>20240315093000.000[0:GMT]</DTPOSTED>",
+		"<TRNAMT>100.00</TRNAMT>",
+		"<FITID>tx-1</FITID>",
+		"<MEMO>ref &lt;1&gt; &amp; co</MEMO>",
+		"<TRNAMT>-0.50</TRNAMT>",
+		"<NAME>" + strings.Repeat("x", maxNameLength) + "</NAME>",
+		"<BALAMT>-900.50</BALAMT>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in output:\n%s", want, out)
+		}
+	}
+
+	// The document must be well-formed XML.
+	decoder := xml.NewDecoder(strings.NewReader(out))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Output is not well-formed XML: %v", err)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+// Package oidc validates bearer tokens (signed JWTs) issued by an OpenID Connect provider,
+// using the signing keys the provider publishes through discovery
+// (https://openid.net/specs/openid-connect-discovery-1_0.html).
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRolesClaim is the claim roles are read from unless the verifier names another.
+	DefaultRolesClaim = "roles"
+	// keyCacheTTL is how long fetched signing keys are trusted before they are fetched again.
+	keyCacheTTL = time.Hour
+	// keyRefetchInterval limits how often an unknown key ID triggers a refetch, so tokens
+	// with made-up key IDs cannot hammer the provider.
+	keyRefetchInterval = time.Minute
+	// defaultLeeway tolerates clock skew between the provider and this server.
+	defaultLeeway = time.Minute
+)
+
+// Claims are the parts of a validated token the API uses.
+type Claims struct {
+	Subject   string
+	Roles     []string
+	ExpiresAt time.Time
+}
+
+// Verifier checks token signatures against the issuer's published keys and validates the
+// issuer, audience and lifetime claims.
+type Verifier struct {
+	Issuer     string
+	Audience   string // Required "aud" value; empty accepts any audience
+	RolesClaim string // Claim holding the caller's roles; a dotted path reaches into nested objects
+	HTTPClient *http.Client
+	Leeway     time.Duration
+
+	now func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// NewVerifier creates a verifier for tokens from the given issuer.
+func NewVerifier(issuer string, audience string) *Verifier {
+	return &Verifier{
+		Issuer:     strings.TrimSuffix(issuer, "/"),
+		Audience:   audience,
+		RolesClaim: DefaultRolesClaim,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		Leeway:     defaultLeeway,
+		now:        time.Now,
+	}
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Verify validates a compact-serialized JWT and returns its claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid token: malformed")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+	hash, ok := algorithmHashes[h.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("invalid token: unsupported algorithm %q", h.Algorithm)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	key, err := v.key(h.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(h.Algorithm, key, hash, hasher.Sum(nil), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %w", err)
+	}
+	return v.validateClaims(claims)
+}
+
+var algorithmHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+}
+
+func verifySignature(algorithm string, key crypto.PublicKey, hash crypto.Hash, digest []byte, signature []byte) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "RS") {
+			return fmt.Errorf("invalid token: %s does not match an RSA key", algorithm)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return fmt.Errorf("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(algorithm, "ES") {
+			return fmt.Errorf("invalid token: %s does not match an EC key", algorithm)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("invalid token: unsupported key type")
+	}
+	return nil
+}
+
+func (v *Verifier) validateClaims(claims map[string]any) (*Claims, error) {
+	now := v.now()
+
+	if issuer, _ := claims["iss"].(string); strings.TrimSuffix(issuer, "/") != v.Issuer {
+		return nil, fmt.Errorf("invalid token: issuer %q is not trusted", issuer)
+	}
+	if v.Audience != "" && !hasAudience(claims["aud"], v.Audience) {
+		return nil, fmt.Errorf("invalid token: not issued for audience %q", v.Audience)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("invalid token: missing expiry")
+	}
+	expiresAt := time.Unix(int64(exp), 0)
+	if now.After(expiresAt.Add(v.Leeway)) {
+		return nil, fmt.Errorf("invalid token: expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("invalid token: not yet valid")
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Claims{Subject: subject, Roles: rolesAt(claims, v.RolesClaim), ExpiresAt: expiresAt}, nil
+}
+
+// hasAudience reports whether the "aud" claim, a string or an array of strings, contains want.
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == want
+	case []any:
+		for _, entry := range a {
+			if entry == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rolesAt reads the roles at a dotted claim path (e.g. "realm_access.roles"). The claim may be
+// an array of strings or a single space- or comma-separated string.
+func rolesAt(claims map[string]any, path string) []string {
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[name]
+	}
+
+	var roles []string
+	switch r := value.(type) {
+	case []any:
+		for _, entry := range r {
+			if role, ok := entry.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	case string:
+		roles = strings.FieldsFunc(r, func(c rune) bool { return c == ' ' || c == ',' })
+	}
+	return roles
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// key returns the signing key with the given ID, fetching the issuer's keys when the cache
+// is stale or does not know the ID.
+func (v *Verifier) key(id string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	key, known := lookupKey(v.keys, id)
+	sinceFetch := now.Sub(v.fetchedAt)
+	if sinceFetch < keyCacheTTL && (known || sinceFetch < keyRefetchInterval) {
+		if !known {
+			return nil, fmt.Errorf("invalid token: unknown signing key %q", id)
+		}
+		return key, nil
+	}
+
+	keys, err := v.fetchKeys()
+	if err != nil {
+		if known {
+			return key, nil // Keep using the cached key while the provider is unreachable
+		}
+		return nil, err
+	}
+	v.keys = keys
+	v.fetchedAt = now
+
+	if key, known = lookupKey(keys, id); !known {
+		return nil, fmt.Errorf("invalid token: unknown signing key %q", id)
+	}
+	return key, nil
+}
+
+// lookupKey finds a key by ID. A token without a key ID matches when the issuer has one key.
+func lookupKey(keys map[string]crypto.PublicKey, id string) (crypto.PublicKey, bool) {
+	if id == "" && len(keys) == 1 {
+		for _, only := range keys {
+			return only, true
+		}
+	}
+	key, ok := keys[id]
+	return key, ok
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// fetchKeys reads the issuer's discovery document and then its JSON Web Key Set. Keys that
+// are not signing keys or have unsupported types are skipped.
+func (v *Verifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	var discovery discoveryDocument
+	if err := v.getJSON(v.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover issuer %s: %w", v.Issuer, err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.Issuer {
+		return nil, fmt.Errorf("issuer %s reports a different issuer %q", v.Issuer, discovery.Issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("issuer %s publishes no jwks_uri", v.Issuer)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys for %s: %w", v.Issuer, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.KeyID] = key
+	}
+	return keys, nil
+}
+
+func (v *Verifier) getJSON(url string, out any) error {
+	resp, err := v.HTTPClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
@@ -0,0 +1,140 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testIssuer serves discovery and a key set holding one RSA and one EC key.
+type testIssuer struct {
+	server     *httptest.Server
+	rsaKey     *rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	keyFetches int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.server.URL, "jwks_uri": issuer.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		issuer.keyFetches++
+		encode := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encode(rsaKey.N.Bytes()), "e": "AQAB"},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X.FillBytes(make([]byte, 32))), "y": encode(ecKey.Y.FillBytes(make([]byte, 32)))},
+		}})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, algorithm string, kid string, claims map[string]any) string {
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(map[string]string{"alg": algorithm, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+
+	var signature []byte
+	if algorithm == "ES256" {
+		r, s, err := ecdsa.Sign(rand.Reader, i.ecKey, digest.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsaKey, crypto.SHA256, digest.Sum(nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL+"/", "fredloan")
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{"iss": issuer.server.URL, "aud": []string{"other", "fredloan"}, "sub": "user-1", "exp": exp, "roles": []string{"servicer"}}
+		for name, value := range overrides {
+			c[name] = value
+		}
+		return c
+	}
+
+	got, err := verifier.Verify(issuer.sign(t, "RS256", "rsa-1", claims(nil)))
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got.Subject != "user-1" || !reflect.DeepEqual(got.Roles, []string{"servicer"}) || got.ExpiresAt.Unix() != int64(exp) {
+		t.Errorf("Unexpected claims %+v", got)
+	}
+	if _, err := verifier.Verify(issuer.sign(t, "ES256", "ec-1", claims(nil))); err != nil {
+		t.Errorf("Expected an ES256 token to verify, got %v", err)
+	}
+	if issuer.keyFetches != 1 {
+		t.Errorf("Expected the key set fetched once, got %d", issuer.keyFetches)
+	}
+
+	tampered := issuer.sign(t, "RS256", "rsa-1", claims(nil))
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+	rejected := map[string]string{
+		"tampered signature": tampered,
+		"expired":            issuer.sign(t, "RS256", "rsa-1", claims(map[string]any{"exp": float64(time.Now().Add(-2 * time.Minute).Unix())})),
+		"not yet valid":      issuer.sign(t, "RS256", "rsa-1", claims(map[string]any{"nbf": float64(time.Now().Add(time.Hour).Unix())})),
+		"wrong audience":     issuer.sign(t, "RS256", "rsa-1", claims(map[string]any{"aud": "billing"})),
+		"wrong issuer":       issuer.sign(t, "RS256", "rsa-1", claims(map[string]any{"iss": "https://evil.example.com"})),
+		"missing expiry":     issuer.sign(t, "RS256", "rsa-1", claims(map[string]any{"exp": nil})),
+		"unknown key":        issuer.sign(t, "RS256", "rsa-2", claims(nil)),
+		"algorithm mismatch": issuer.sign(t, "RS256", "ec-1", claims(nil)),
+		"malformed":          "not.a-token",
+	}
+	for name, token := range rejected {
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("Expected %s token to be rejected", name)
+		}
+	}
+	if issuer.keyFetches != 1 {
+		t.Errorf("Expected an unknown key ID not to refetch within a minute, got %d fetches", issuer.keyFetches)
+	}
+}
+
+func TestVerify_NestedRolesClaim(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := NewVerifier(issuer.server.URL, "")
+	verifier.RolesClaim = "realm_access.roles"
+
+	token := issuer.sign(t, "RS256", "rsa-1", map[string]any{
+		"iss":          issuer.server.URL,
+		"exp":          float64(time.Now().Add(time.Hour).Unix()),
+		"realm_access": map[string]any{"roles": []string{"admin", "offline_access"}},
+	})
+	got, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !reflect.DeepEqual(got.Roles, []string{"admin", "offline_access"}) {
+		t.Errorf("Expected roles from realm_access.roles, got %v", got.Roles)
+	}
+}
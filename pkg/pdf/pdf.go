@@ -0,0 +1,159 @@
+// Package pdf writes plain text as a PDF document: US Letter pages set in Courier, the
+// built-in monospaced font every reader has, so text laid out in columns stays aligned.
+// Nothing beyond that is supported; the output is meant for printing and download.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page geometry in points (1/72 inch).
+const (
+	pageWidth  = 612
+	pageHeight = 792
+	margin     = 54
+	fontSize   = 9
+	leading    = 11
+)
+
+// Columns is how many characters fit on a line; longer lines wrap.
+const Columns = (pageWidth - 2*margin) * 10 / (fontSize * 6) // Courier glyphs are 0.6 em wide
+
+// LinesPerPage is how many lines fit on a page.
+const LinesPerPage = (pageHeight - 2*margin) / leading
+
+// WriteText writes text as a PDF document with the given title. Each line of text is a line on
+// the page, wrapped at Columns characters; a form feed starts a new page. Characters outside
+// Latin-1 are printed as "?".
+func WriteText(w io.Writer, title string, text string) error {
+	pages := paginate(text)
+
+	// Objects 1 to 4 are fixed; each page then takes two, the page and its content stream.
+	var objects [][]byte
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		[]byte("<< /Type /Catalog /Pages 2 0 R >>"),
+		[]byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))),
+		[]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>"),
+		[]byte("<< /Title "+literal(title)+" /Producer (fredLoan) >>"),
+	)
+	for i, lines := range pages {
+		content, err := pageContent(lines)
+		if err != nil {
+			return err
+		}
+		objects = append(objects,
+			[]byte(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, 6+2*i)),
+			content,
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n") // The binary comment marks the file as binary for transfer tools
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(object)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// paginate splits text into pages of at most LinesPerPage lines, wrapping long lines. There is
+// always at least one page.
+func paginate(text string) [][]string {
+	var pages [][]string
+	for _, block := range strings.Split(strings.TrimRight(text, "\n"), "\f") {
+		var page []string
+		for _, line := range strings.Split(strings.Trim(block, "\n"), "\n") {
+			for _, part := range wrap(strings.TrimRight(line, " \r")) {
+				if len(page) == LinesPerPage {
+					pages = append(pages, page)
+					page = nil
+				}
+				page = append(page, part)
+			}
+		}
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// wrap breaks a line into pieces of at most Columns characters.
+func wrap(line string) []string {
+	runes := []rune(line)
+	if len(runes) <= Columns {
+		return []string{line}
+	}
+	var parts []string
+	for len(runes) > Columns {
+		parts = append(parts, string(runes[:Columns]))
+		runes = runes[Columns:]
+	}
+	return append(parts, string(runes))
+}
+
+// pageContent returns the compressed content stream object that draws the lines.
+func pageContent(lines []string) ([]byte, error) {
+	var ops bytes.Buffer
+	fmt.Fprintf(&ops, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", fontSize, leading, margin, pageHeight-margin-fontSize)
+	for _, line := range lines {
+		ops.WriteString(literal(line))
+		ops.WriteString(" Tj T*\n")
+	}
+	ops.WriteString("ET\n")
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(ops.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var object bytes.Buffer
+	fmt.Fprintf(&object, "<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	compressed.WriteTo(&object)
+	object.WriteString("\nendstream")
+	return object.Bytes(), nil
+}
+
+// literal encodes s as a PDF string literal in WinAnsiEncoding, which matches Latin-1 for the
+// characters it shares with it.
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
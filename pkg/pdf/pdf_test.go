@@ -0,0 +1,78 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	lines := []string{"Balance (due) \\ 100.00", "Café €5", strings.Repeat("x", Columns+5)}
+	for i := 0; i < LinesPerPage; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	text := strings.Join(lines, "\n") + "\fsecond block"
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, "Statement (2024-03)", text); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("Missing PDF header or trailer")
+	}
+	// The wrapped line pushes two lines onto the second page and the form feed starts a third
+	if !strings.Contains(out, "/Count 3 >>") {
+		t.Errorf("Expected 3 pages")
+	}
+	if !strings.Contains(out, "/Title (Statement \\(2024-03\\))") {
+		t.Errorf("Expected an escaped title")
+	}
+
+	// Every cross-reference entry must point at its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(out)
+	if startxref == nil {
+		t.Fatal("Missing startxref")
+	}
+	xref, _ := strconv.Atoi(startxref[1])
+	if !strings.HasPrefix(out[xref:], "xref\n") {
+		t.Fatalf("startxref %d does not point at the cross-reference table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(out[xref:], -1)
+	if len(entries) != 4+2*3 {
+		t.Fatalf("Expected 10 objects, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(out[offset:], want) {
+			t.Errorf("Object %d is not at offset %d", i+1, offset)
+		}
+	}
+
+	// The first page draws the escaped and re-encoded text
+	streams := regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindAllStringSubmatchIndex(out, -1)
+	if len(streams) != 3 {
+		t.Fatalf("Expected 3 content streams, got %d", len(streams))
+	}
+	length, _ := strconv.Atoi(out[streams[0][2]:streams[0][3]])
+	zr, err := zlib.NewReader(strings.NewReader(out[streams[0][1] : streams[0][1]+length]))
+	if err != nil {
+		t.Fatalf("Failed to open content stream: %v", err)
+	}
+	content, _ := io.ReadAll(zr)
+	for _, want := range []string{
+		`(Balance \(due\) \\ 100.00) Tj T*`,
+		`(Caf\351 ?5) Tj T*`,
+		"(" + strings.Repeat("x", Columns) + ") Tj T*\n(xxxxx) Tj T*",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected %q in the first page:\n%s", want, content)
+		}
+	}
+}
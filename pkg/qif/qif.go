@@ -0,0 +1,56 @@
+// Package qif writes transactions in the Quicken Interchange Format, the plain-text format
+// older personal finance software and many accounting packages import.
+package qif
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Account types for the !Type header.
+const (
+	TypeBank           = "Bank"
+	TypeCreditCard     = "CCard"
+	TypeOtherAsset     = "Oth A"
+	TypeOtherLiability = "Oth L" // A loan
+)
+
+const dateLayout = "01/02/2006"
+
+// lineBreaks replaces the line breaks in a value, since every QIF field is a single line.
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// Transaction is one register entry.
+type Transaction struct {
+	Date   time.Time
+	Amount decimal.Decimal // Positive for money into the account, negative for money out
+	Number string          // Check or reference number
+	Payee  string
+	Memo   string
+}
+
+// Write writes the transactions as a QIF register of the given account type. Dates are
+// written month first with a four-digit year, which current importers read unambiguously.
+func Write(w io.Writer, accountType string, transactions []Transaction) error {
+	out := bufio.NewWriter(w)
+	out.WriteString("!Type:" + accountType + "\n")
+	for _, t := range transactions {
+		out.WriteString("D" + t.Date.Format(dateLayout) + "\n")
+		out.WriteString("T" + t.Amount.StringFixed(2) + "\n")
+		if t.Number != "" {
+			out.WriteString("N" + lineBreaks.Replace(t.Number) + "\n")
+		}
+		if t.Payee != "" {
+			out.WriteString("P" + lineBreaks.Replace(t.Payee) + "\n")
+		}
+		if t.Memo != "" {
+			out.WriteString("M" + lineBreaks.Replace(t.Memo) + "\n")
+		}
+		out.WriteString("^\n")
+	}
+	return out.Flush()
+}
@@ -0,0 +1,38 @@
+package qif
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestWrite(t *testing.T) {
+	transactions := []Transaction{
+		{
+			Date:   time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC),
+			Amount: decimal.NewFromInt(-1000),
+			Payee:  "Loan disbursement",
+		},
+		{
+			Date:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			Amount: decimal.RequireFromString("125.5"),
+			Number: "chk-42",
+			Payee:  "Payment",
+			Memo:   "first\nsecond",
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, TypeOtherLiability, transactions); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := "!Type:Oth L\n" +
+		"D03/05/2024\nT-1000.00\nPLoan disbursement\n^\n" +
+		"D04/01/2024\nT125.50\nNchk-42\nPPayment\nMfirst second\n^\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,799 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"go.etcd.io/bbolt"
+)
+
+var _ Storage = (*BoltStore)(nil)
+
+// boltFormatVersion is the layout of the buckets in a Bolt file. A file written in a newer
+// layout than this build's is refused rather than misread.
+const boltFormatVersion = 1
+
+var (
+	boltMetaBucket = []byte("meta")
+	boltVersionKey = []byte("format_version")
+)
+
+// BoltStore keeps the ledger in a single file with bbolt, an embedded key-value store written
+// in pure Go, for deployments where the cgo SQLite needs is a problem. It serves every call
+// from a MemoryStore loaded from the file when it is opened, and writes each change through
+// to the file in one bbolt transaction before the change is visible, so a crash loses
+// nothing that was acknowledged.
+//
+// Each write works on a copy of the store's contents, as MemoryStore.InTransaction does, and
+// saves only the records it replaced. The whole ledger is held in memory and every write costs
+// time in proportion to its size, which suits small and medium ledgers; larger ones belong on
+// PostgreSQL or MySQL. Only one process can have the file open at a time.
+type BoltStore struct {
+	mem *MemoryStore
+	db  *bbolt.DB
+}
+
+// NewBoltStore opens the Bolt file at path, creating it if it does not exist, and loads the
+// ledger in it.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+	mem := NewMemoryStore()
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if err := checkBoltFormat(tx); err != nil {
+			return err
+		}
+		for _, c := range boltCollections {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(c.name))
+			if err != nil {
+				return err
+			}
+			if err := c.load(bucket, &mem.memoryData); err != nil {
+				return fmt.Errorf("failed to load %s: %w", c.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{mem: mem, db: db}, nil
+}
+
+// checkBoltFormat records the format version in a new file and refuses a file in a newer one.
+func checkBoltFormat(tx *bbolt.Tx) error {
+	meta, err := tx.CreateBucketIfNotExists(boltMetaBucket)
+	if err != nil {
+		return err
+	}
+	stored := meta.Get(boltVersionKey)
+	if stored == nil {
+		return meta.Put(boltVersionKey, boltIndex(boltFormatVersion))
+	}
+	if version := binary.BigEndian.Uint64(stored); version > boltFormatVersion {
+		return fmt.Errorf("bolt database is in format version %d, newer than this build's %d", version, boltFormatVersion)
+	}
+	return nil
+}
+
+// Close closes the file.
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
+
+// update runs fn on a copy of the store's contents and, if it succeeds, saves the records fn
+// added, replaced or removed to the file in one bbolt transaction before the copy replaces
+// the contents. Every other call waits until it is done. A change that cannot be saved is
+// dropped, leaving the store as it was.
+func (b *BoltStore) update(ctx context.Context, fn func(tx *MemoryStore) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mem.mu.Lock()
+	defer b.mem.mu.Unlock()
+
+	tx := &MemoryStore{memoryData: b.mem.memoryData.snapshot()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	err := b.db.Update(func(btx *bbolt.Tx) error {
+		for _, c := range boltCollections {
+			if err := c.save(btx.Bucket([]byte(c.name)), &b.mem.memoryData, &tx.memoryData); err != nil {
+				return fmt.Errorf("failed to save %s: %w", c.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.mem.memoryData = tx.memoryData
+	return nil
+}
+
+// InTransaction runs fn on a copy of the store's contents, which are saved and replace them if
+// fn succeeds.
+func (b *BoltStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return fn(ctx, tx)
+	})
+}
+
+// ForEachActiveLoan calls fn with each active or delinquent loan in turn.
+func (b *BoltStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	return b.mem.ForEachActiveLoan(ctx, fn)
+}
+
+// boltCollection is one of a MemoryStore's maps or slices, kept in a bucket of its own.
+type boltCollection struct {
+	name string
+	// load adds the records in the bucket to the collection in d.
+	load func(bucket *bbolt.Bucket, d *memoryData) error
+	// save writes the differences between the collection in before and in after to the
+	// bucket. Records are never changed in place, so a record that differs is a new pointer.
+	save func(bucket *bbolt.Bucket, before *memoryData, after *memoryData) error
+}
+
+// boltCollections lists every collection of memoryData. A field added there needs an entry
+// here, or its records are lost when the store is reopened.
+var boltCollections = []boltCollection{
+	boltMap("loans", func(d *memoryData) *map[uuid.UUID]*models.Loan { return &d.loans }, uuidKey),
+	boltMap("customers", func(d *memoryData) *map[string]*models.Customer { return &d.customers }, stringKey),
+	boltSlice("transactions", func(d *memoryData) *[]*models.Transaction { return &d.transactions }),
+	boltSlice("events", func(d *memoryData) *[]*models.LoanEvent { return &d.events }),
+	boltSlice("rate_changes", func(d *memoryData) *[]*models.RateChange { return &d.rateChanges }),
+	boltMap("archived_loans", func(d *memoryData) *map[uuid.UUID]*models.Loan { return &d.archivedLoans }, uuidKey),
+	boltSlice("archived_transactions", func(d *memoryData) *[]*models.Transaction { return &d.archivedTransactions }),
+	boltSlice("archived_events", func(d *memoryData) *[]*models.LoanEvent { return &d.archivedEvents }),
+	boltSlice("archived_rate_changes", func(d *memoryData) *[]*models.RateChange { return &d.archivedRateChanges }),
+	boltMap("archived_records", func(d *memoryData) *map[uuid.UUID]*archivedRecords { return &d.archivedRecords }, uuidKey),
+	boltMap("archive_exports", func(d *memoryData) *map[uuid.UUID]*time.Time { return &d.archiveExports }, uuidKey),
+	boltSlice("index_rates", func(d *memoryData) *[]*models.IndexRate { return &d.indexRates }),
+	boltMap("portfolio_snapshots", func(d *memoryData) *map[time.Time]*models.PortfolioSnapshot { return &d.snapshots }, timeMapKey),
+	boltMap("interest_intents", func(d *memoryData) *map[uuid.UUID]*models.InterestIntent { return &d.intents }, uuidKey),
+	boltMap("idempotency_keys", func(d *memoryData) *map[string]*models.IdempotencyRecord { return &d.idempotency }, stringKey),
+	boltMap("statements", func(d *memoryData) *map[uuid.UUID]*models.Statement { return &d.statements }, uuidKey),
+	boltMap("autopay_enrollments", func(d *memoryData) *map[uuid.UUID]*models.AutopayEnrollment { return &d.autopay }, uuidKey),
+	boltMap("collateral", func(d *memoryData) *map[uuid.UUID]*models.Collateral { return &d.collateral }, uuidKey),
+	boltSlice("forbearances", func(d *memoryData) *[]*models.Forbearance { return &d.forbearances }),
+	boltMap("accruals", func(d *memoryData) *map[accrualKey]*models.Accrual { return &d.accruals }, accrualMapKey),
+	boltSlice("ledger_events", func(d *memoryData) *[]*models.LedgerEvent { return &d.ledgerEvents }),
+	boltMap("bureau_records", func(d *memoryData) *map[bureauRecordKey]*models.BureauRecord { return &d.bureauRecords }, bureauRecordMapKey),
+	boltMap("payment_methods", func(d *memoryData) *map[uuid.UUID]*models.PaymentMethod { return &d.paymentMethods }, uuidKey),
+	boltMap("payment_links", func(d *memoryData) *map[uuid.UUID]*models.PaymentLink { return &d.paymentLinks }, uuidKey),
+	boltMap("webhook_subscriptions", func(d *memoryData) *map[uuid.UUID]*models.WebhookSubscription { return &d.webhooks }, uuidKey),
+	boltSlice("webhook_deliveries", func(d *memoryData) *[]*models.WebhookDelivery { return &d.webhookDeliveries }),
+	boltSlice("outbox_events", func(d *memoryData) *[]*models.OutboxEvent { return &d.outbox }),
+	boltMap("products", func(d *memoryData) *map[string]*models.Product { return &d.products }, stringKey),
+	boltSlice("job_runs", func(d *memoryData) *[]*models.JobRun { return &d.jobRuns }),
+}
+
+// boltKey converts the keys of a map to and from bucket keys.
+type boltKey[K comparable] struct {
+	encode func(K) []byte
+	decode func([]byte) (K, error)
+}
+
+var (
+	uuidKey = boltKey[uuid.UUID]{
+		encode: func(id uuid.UUID) []byte { return id[:] },
+		decode: uuid.FromBytes,
+	}
+	stringKey = boltKey[string]{
+		encode: func(s string) []byte { return []byte(s) },
+		decode: func(k []byte) (string, error) { return string(k), nil },
+	}
+	timeMapKey = boltKey[time.Time]{
+		encode: encodeTimeKey,
+		decode: decodeTimeKey,
+	}
+	accrualMapKey = boltKey[accrualKey]{
+		encode: func(k accrualKey) []byte {
+			return append(k.loanID[:len(k.loanID):len(k.loanID)], encodeTimeKey(k.date)...)
+		},
+		decode: func(k []byte) (accrualKey, error) {
+			if len(k) < 16 {
+				return accrualKey{}, errors.New("accrual key too short")
+			}
+			date, err := decodeTimeKey(k[16:])
+			return accrualKey{loanID: uuid.UUID(k[:16]), date: date}, err
+		},
+	}
+	bureauRecordMapKey = boltKey[bureauRecordKey]{
+		encode: func(k bureauRecordKey) []byte { return append(k.loanID[:len(k.loanID):len(k.loanID)], k.period...) },
+		decode: func(k []byte) (bureauRecordKey, error) {
+			if len(k) < 16 {
+				return bureauRecordKey{}, errors.New("bureau record key too short")
+			}
+			return bureauRecordKey{loanID: uuid.UUID(k[:16]), period: string(k[16:])}, nil
+		},
+	}
+)
+
+func encodeTimeKey(t time.Time) []byte {
+	encoded, _ := t.MarshalBinary() // Fails only for offsets no zone has
+	return encoded
+}
+
+func decodeTimeKey(k []byte) (time.Time, error) {
+	var t time.Time
+	err := t.UnmarshalBinary(k)
+	return timeKey(t), err
+}
+
+// boltMap keeps a map of records in a bucket under their map keys.
+func boltMap[K comparable, V any](name string, field func(*memoryData) *map[K]*V, key boltKey[K]) boltCollection {
+	return boltCollection{
+		name: name,
+		load: func(bucket *bbolt.Bucket, d *memoryData) error {
+			records := *field(d)
+			return bucket.ForEach(func(k, v []byte) error {
+				id, err := key.decode(k)
+				if err != nil {
+					return err
+				}
+				record := new(V)
+				if err := decodeRecord(v, record); err != nil {
+					return err
+				}
+				records[id] = record
+				return nil
+			})
+		},
+		save: func(bucket *bbolt.Bucket, before *memoryData, after *memoryData) error {
+			old, current := *field(before), *field(after)
+			for id, record := range current {
+				if old[id] != record {
+					if err := putRecord(bucket, key.encode(id), record); err != nil {
+						return err
+					}
+				}
+			}
+			for id := range old {
+				if _, ok := current[id]; !ok {
+					if err := bucket.Delete(key.encode(id)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// boltSlice keeps a slice of records in a bucket under their positions, so that it loads in
+// the same order.
+func boltSlice[V any](name string, field func(*memoryData) *[]*V) boltCollection {
+	return boltCollection{
+		name: name,
+		load: func(bucket *bbolt.Bucket, d *memoryData) error {
+			records := field(d)
+			return bucket.ForEach(func(_, v []byte) error {
+				record := new(V)
+				if err := decodeRecord(v, record); err != nil {
+					return err
+				}
+				*records = append(*records, record)
+				return nil
+			})
+		},
+		save: func(bucket *bbolt.Bucket, before *memoryData, after *memoryData) error {
+			old, current := *field(before), *field(after)
+			for i := 0; i < max(len(old), len(current)); i++ {
+				switch {
+				case i >= len(current):
+					if err := bucket.Delete(boltIndex(uint64(i))); err != nil {
+						return err
+					}
+				case i >= len(old) || old[i] != current[i]:
+					if err := putRecord(bucket, boltIndex(uint64(i)), current[i]); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// boltIndex encodes a number as a bucket key that sorts in numeric order.
+func boltIndex(i uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, i)
+}
+
+// putRecord stores a record gob-encoded, which unlike its JSON keeps every exported field.
+func putRecord[V any](bucket *bbolt.Bucket, key []byte, record *V) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(record); err != nil {
+		return err
+	}
+	return bucket.Put(key, buf.Bytes())
+}
+
+func decodeRecord[V any](data []byte, record *V) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(record)
+}
+
+// Storage methods below read from memory or, for writes, go through update.
+
+func (b *BoltStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateLoan(ctx, loan)
+	})
+}
+
+func (b *BoltStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	return b.mem.GetLoan(ctx, id)
+}
+
+func (b *BoltStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateLoan(ctx, loan)
+	})
+}
+
+func (b *BoltStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateLoanIfVersion(ctx, loan, version)
+	})
+}
+
+func (b *BoltStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	var result []uuid.UUID
+	err := b.update(ctx, func(tx *MemoryStore) error {
+		var err error
+		result, err = tx.UpdateLoans(ctx, loans)
+		return err
+	})
+	return result, err
+}
+
+func (b *BoltStore) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	return b.mem.GetAllLoans(ctx)
+}
+
+func (b *BoltStore) ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error) {
+	return b.mem.ListLoans(ctx, query)
+}
+
+func (b *BoltStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error) {
+	return b.mem.ListLoansAfter(ctx, query, cursor, limit)
+}
+
+func (b *BoltStore) SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error) {
+	return b.mem.SearchLoans(ctx, search)
+}
+
+func (b *BoltStore) GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error) {
+	return b.mem.GetAllActiveLoans(ctx)
+}
+
+func (b *BoltStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error) {
+	return b.mem.GetLoansByStatus(ctx, status)
+}
+
+func (b *BoltStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	return b.mem.GetLoansByCustomerKey(ctx, customerKey)
+}
+
+func (b *BoltStore) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	return b.mem.GetCustomerSummary(ctx, customerKey)
+}
+
+func (b *BoltStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error) {
+	return b.mem.GetDelinquentLoans(ctx, minDaysPastDue)
+}
+
+func (b *BoltStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateTransaction(ctx, transaction)
+	})
+}
+
+func (b *BoltStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	return b.mem.GetTransactionsForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	return b.mem.QueryTransactions(ctx, loanID, query)
+}
+
+func (b *BoltStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateLoanEvent(ctx, event)
+	})
+}
+
+func (b *BoltStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	return b.mem.GetLoanEventsForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateRateChange(ctx, change)
+	})
+}
+
+func (b *BoltStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	return b.mem.GetRateHistory(ctx, loanID)
+}
+
+func (b *BoltStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error) {
+	return b.mem.GetRateInEffect(ctx, loanID, date)
+}
+
+func (b *BoltStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateIndexRate(ctx, rate)
+	})
+}
+
+func (b *BoltStore) GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error) {
+	return b.mem.GetLatestIndexRate(ctx, indexCode)
+}
+
+func (b *BoltStore) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	return b.mem.GetIndexRates(ctx, indexCode)
+}
+
+func (b *BoltStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.SavePortfolioSnapshot(ctx, snapshot)
+	})
+}
+
+func (b *BoltStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	return b.mem.GetPortfolioSnapshots(ctx, from, to)
+}
+
+func (b *BoltStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateInterestIntent(ctx, intent)
+	})
+}
+
+func (b *BoltStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateInterestIntent(ctx, intent)
+	})
+}
+
+func (b *BoltStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error) {
+	return b.mem.GetInterestIntent(ctx, loanID, cycle)
+}
+
+func (b *BoltStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	return b.mem.GetInterestIntentsByStatus(ctx, status)
+}
+
+func (b *BoltStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error) {
+	return b.mem.GetInterestIntentsForCycle(ctx, cycle)
+}
+
+func (b *BoltStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateIdempotencyRecord(ctx, record)
+	})
+}
+
+func (b *BoltStore) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	return b.mem.GetIdempotencyRecord(ctx, key)
+}
+
+func (b *BoltStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateIdempotencyRecord(ctx, record)
+	})
+}
+
+func (b *BoltStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.DeleteIdempotencyRecord(ctx, key)
+	})
+}
+
+func (b *BoltStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateStatement(ctx, statement)
+	})
+}
+
+func (b *BoltStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error) {
+	return b.mem.GetStatement(ctx, loanID, cycle)
+}
+
+func (b *BoltStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	return b.mem.GetStatementsForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.SaveAutopayEnrollment(ctx, enrollment)
+	})
+}
+
+func (b *BoltStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	return b.mem.GetAutopayEnrollment(ctx, loanID)
+}
+
+func (b *BoltStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.DeleteAutopayEnrollment(ctx, loanID)
+	})
+}
+
+func (b *BoltStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error) {
+	return b.mem.GetAutopayEnrollmentsForDay(ctx, day)
+}
+
+func (b *BoltStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateCollateral(ctx, collateral)
+	})
+}
+
+func (b *BoltStore) GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error) {
+	return b.mem.GetCollateral(ctx, id)
+}
+
+func (b *BoltStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateCollateral(ctx, collateral)
+	})
+}
+
+func (b *BoltStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.DeleteCollateral(ctx, id)
+	})
+}
+
+func (b *BoltStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	return b.mem.GetCollateralForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateForbearance(ctx, forbearance)
+	})
+}
+
+func (b *BoltStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	return b.mem.GetForbearancesForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.SaveAccrual(ctx, accrual)
+	})
+}
+
+func (b *BoltStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	return b.mem.GetAccrualsForLoan(ctx, loanID, from, to)
+}
+
+func (b *BoltStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.AppendLedgerEvent(ctx, event)
+	})
+}
+
+func (b *BoltStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	return b.mem.GetLedgerEvents(ctx, loanID)
+}
+
+func (b *BoltStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.SaveBureauRecord(ctx, record)
+	})
+}
+
+func (b *BoltStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error) {
+	return b.mem.GetBureauRecordsForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	var result int
+	err := b.update(ctx, func(tx *MemoryStore) error {
+		var err error
+		result, err = tx.ArchiveClosedLoans(ctx, closedBefore)
+		return err
+	})
+	return result, err
+}
+
+func (b *BoltStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.MarkArchivedLoansExported(ctx, ids, exportedAt)
+	})
+}
+
+func (b *BoltStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.DeleteArchivedLoans(ctx, ids)
+	})
+}
+
+func (b *BoltStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	return b.mem.GetArchivedLoan(ctx, id)
+}
+
+func (b *BoltStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	return b.mem.GetArchivedTransactionsForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	return b.mem.GetArchivedLoanEventsForLoan(ctx, loanID)
+}
+
+func (b *BoltStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	return b.mem.GetArchivedRateHistory(ctx, loanID)
+}
+
+func (b *BoltStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error) {
+	return b.mem.GetArchivedLoansToExport(ctx, closedBefore, limit)
+}
+
+func (b *BoltStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreatePaymentMethod(ctx, method)
+	})
+}
+
+func (b *BoltStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	return b.mem.GetPaymentMethod(ctx, id)
+}
+
+func (b *BoltStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdatePaymentMethod(ctx, method)
+	})
+}
+
+func (b *BoltStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	return b.mem.GetPaymentMethodsForCustomer(ctx, customerKey)
+}
+
+func (b *BoltStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreatePaymentLink(ctx, link)
+	})
+}
+
+func (b *BoltStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error) {
+	return b.mem.GetPaymentLink(ctx, id)
+}
+
+func (b *BoltStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdatePaymentLink(ctx, link)
+	})
+}
+
+func (b *BoltStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.RedeemPaymentLink(ctx, link)
+	})
+}
+
+func (b *BoltStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateWebhookSubscription(ctx, subscription)
+	})
+}
+
+func (b *BoltStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	return b.mem.GetWebhookSubscription(ctx, id)
+}
+
+func (b *BoltStore) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	return b.mem.GetWebhookSubscriptions(ctx)
+}
+
+func (b *BoltStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.DeleteWebhookSubscription(ctx, id)
+	})
+}
+
+func (b *BoltStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateWebhookDelivery(ctx, delivery)
+	})
+}
+
+func (b *BoltStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateWebhookDelivery(ctx, delivery)
+	})
+}
+
+func (b *BoltStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return b.mem.GetDueWebhookDeliveries(ctx, at, limit)
+}
+
+func (b *BoltStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	return b.mem.GetWebhookDeliveriesForSubscription(ctx, subscriptionID, limit)
+}
+
+func (b *BoltStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateOutboxEvent(ctx, event)
+	})
+}
+
+func (b *BoltStore) GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	return b.mem.GetPendingOutboxEvents(ctx, limit)
+}
+
+func (b *BoltStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.MarkOutboxEventPublished(ctx, id, at)
+	})
+}
+
+func (b *BoltStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateProduct(ctx, product)
+	})
+}
+
+func (b *BoltStore) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	return b.mem.GetProduct(ctx, code)
+}
+
+func (b *BoltStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateProduct(ctx, product)
+	})
+}
+
+func (b *BoltStore) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	return b.mem.GetAllProducts(ctx)
+}
+
+func (b *BoltStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateCustomer(ctx, customer)
+	})
+}
+
+func (b *BoltStore) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	return b.mem.GetCustomer(ctx, customerKey)
+}
+
+func (b *BoltStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.UpdateCustomer(ctx, customer)
+	})
+}
+
+func (b *BoltStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.DeleteCustomer(ctx, customerKey)
+	})
+}
+
+func (b *BoltStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	return b.mem.GetAllCustomers(ctx)
+}
+
+func (b *BoltStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	return b.update(ctx, func(tx *MemoryStore) error {
+		return tx.CreateJobRun(ctx, run)
+	})
+}
+
+func (b *BoltStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	return b.mem.GetJobRuns(ctx, job, limit)
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ledger.bolt")
+
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	old := now.AddDate(-2, 0, 0)
+	newLoan := func(status models.LoanStatus, updated time.Time) *models.Loan {
+		loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_bolt", Principal: decimal.NewFromInt(1000), Balance: decimal.NewFromInt(1000), Status: status, CreatedAt: updated, UpdatedAt: updated}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+		return loan
+	}
+	loan := newLoan(models.LoanStatusActive, now)
+	closed := newLoan(models.LoanStatusClosed, old)
+
+	amounts := []int64{300, 100, 200}
+	for i, amount := range amounts {
+		transaction := &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.NewFromInt(amount), Type: models.TransactionTypePayment, Timestamp: now.Add(time.Duration(i) * time.Minute), Metadata: map[string]string{"order": "A-1"}}
+		if err := s.CreateTransaction(ctx, transaction); err != nil {
+			t.Fatalf("Failed to create transaction: %v", err)
+		}
+	}
+	s.CreateTransaction(ctx, &models.Transaction{ID: uuid.New(), LoanID: closed.ID, Amount: decimal.NewFromInt(1000), Type: models.TransactionTypeDisbursement, Timestamp: old})
+	s.CreateStatement(ctx, &models.Statement{ID: uuid.New(), LoanID: closed.ID, Cycle: "2024-01", StatementDate: old, CreatedAt: old})
+
+	failed := errors.New("failed")
+	err = s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		changed := *loan
+		changed.Balance = decimal.NewFromInt(1)
+		if err := tx.UpdateLoan(ctx, &changed); err != nil {
+			return err
+		}
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Expected fn's error, got %v", err)
+	}
+
+	archived, err := s.ArchiveClosedLoans(ctx, now.AddDate(-1, 0, 0))
+	if err != nil || archived != 1 {
+		t.Fatalf("Expected 1 archived loan, got %d (%v)", archived, err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	// Everything written, and nothing from the failed unit, is there after reopening
+	s, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer s.Close()
+
+	fetched, err := s.GetLoan(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to get loan: %v", err)
+	}
+	if !fetched.Balance.Equal(decimal.NewFromInt(1000)) || !fetched.CreatedAt.Equal(now) {
+		t.Errorf("Expected the loan as created, got balance %s created %s", fetched.Balance, fetched.CreatedAt)
+	}
+	transactions, _ := s.GetTransactionsForLoan(ctx, loan.ID)
+	if len(transactions) != len(amounts) {
+		t.Fatalf("Expected %d transactions, got %d", len(amounts), len(transactions))
+	}
+	matched, total, _ := s.QueryTransactions(ctx, loan.ID, models.TransactionQuery{Metadata: map[string]string{"order": "A-1"}})
+	if total != len(amounts) || len(matched) != len(amounts) {
+		t.Errorf("Expected every transaction to keep its metadata, got %d", total)
+	}
+
+	if _, err := s.GetLoan(ctx, closed.ID); err == nil {
+		t.Error("Expected the archived loan to be gone from the loans")
+	}
+	if _, err := s.GetArchivedLoan(ctx, closed.ID); err != nil {
+		t.Errorf("Expected the archived loan to be kept: %v", err)
+	}
+	if txs, _ := s.GetArchivedTransactionsForLoan(ctx, closed.ID); len(txs) != 1 {
+		t.Errorf("Expected 1 archived transaction, got %d", len(txs))
+	}
+	if statements, _ := s.GetStatementsForLoan(ctx, closed.ID); len(statements) != 0 {
+		t.Errorf("Expected the archived loan's statement to be gone, got %d", len(statements))
+	}
+}
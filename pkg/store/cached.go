@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ Storage = (*CachedStore)(nil)
+
+// cachePrefix starts every key a CachedStore writes, so that evicting everything leaves
+// other data in the same Redis database alone.
+const cachePrefix = "fredloan:"
+
+// CachedStore decorates a Storage with a Redis cache of loans and their transaction
+// histories, for read-heavy customer-facing traffic. GetLoan and GetTransactionsForLoan are
+// served from the cache where they can be; the writes that change a loan or its
+// transactions evict its entries, and archiving evicts every entry. Entries also expire
+// after the TTL, which bounds how stale a read racing a write can leave one. Redis being
+// unavailable only costs the cache: reads fall back to the underlying store. Every other
+// method passes straight through.
+type CachedStore struct {
+	Storage
+	client *redis.Client
+	ttl    time.Duration
+	// pending is set on the store handed to an InTransaction function. Its reads go around
+	// the cache, since the transaction may see data no one else can yet, and its evictions
+	// wait for the transaction to end.
+	pending *cacheEvictions
+}
+
+// cacheEvictions are the keys a transaction's writes evict when it ends.
+type cacheEvictions struct {
+	keys []string
+	all  bool
+}
+
+// NewCachedStore wraps s with a cache held in Redis through client for up to ttl. The store
+// owns the client and closes it with s.
+func NewCachedStore(s Storage, client *redis.Client, ttl time.Duration) *CachedStore {
+	return &CachedStore{Storage: s, client: client, ttl: ttl}
+}
+
+func loanCacheKey(id uuid.UUID) string {
+	return cachePrefix + "loan:" + id.String()
+}
+
+func transactionsCacheKey(loanID uuid.UUID) string {
+	return cachePrefix + "transactions:" + loanID.String()
+}
+
+// cached returns the value cached under key, or loads it and caches it.
+func cached[T any](ctx context.Context, c *CachedStore, key string, load func() (T, error)) (T, error) {
+	if c.pending != nil {
+		return load()
+	}
+	data, err := c.client.Get(ctx, key).Bytes()
+	if err == nil {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			return value, nil
+		}
+	} else if !errors.Is(err, redis.Nil) {
+		log.Printf("Cache read of %s failed: %v", key, err)
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		if err := c.client.Set(ctx, key, data, c.ttl).Err(); err != nil {
+			log.Printf("Cache write of %s failed: %v", key, err)
+		}
+	}
+	return value, nil
+}
+
+// evict removes keys from the cache, or from within a transaction, once it ends. It runs
+// even if the caller's context is cancelled, as the write it follows may have gone through.
+func (c *CachedStore) evict(ctx context.Context, keys ...string) {
+	if c.pending != nil {
+		c.pending.keys = append(c.pending.keys, keys...)
+		return
+	}
+	if err := c.client.Del(context.WithoutCancel(ctx), keys...).Err(); err != nil {
+		log.Printf("Cache eviction of %v failed; they may be stale until they expire: %v", keys, err)
+	}
+}
+
+// evictAll removes every entry from the cache, or from within a transaction, once it ends.
+func (c *CachedStore) evictAll(ctx context.Context) {
+	if c.pending != nil {
+		c.pending.all = true
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	iter := c.client.Scan(ctx, 0, cachePrefix+"*", 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	err := iter.Err()
+	for len(keys) > 0 && err == nil {
+		batch := keys[:min(len(keys), 1000)]
+		keys = keys[len(batch):]
+		err = c.client.Del(ctx, batch...).Err()
+	}
+	if err != nil {
+		log.Printf("Cache eviction failed; entries may be stale until they expire: %v", err)
+	}
+}
+
+// Close closes the underlying store and the Redis client.
+func (c *CachedStore) Close() error {
+	return errors.Join(c.Storage.Close(), c.client.Close())
+}
+
+// InTransaction hands fn a store that reads around the cache and holds back its evictions
+// until the transaction ends, so no one caches what the transaction has yet to commit.
+func (c *CachedStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	pending := c.pending
+	if pending == nil {
+		pending = &cacheEvictions{}
+	}
+	err := c.Storage.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		return fn(ctx, &CachedStore{Storage: tx, client: c.client, ttl: c.ttl, pending: pending})
+	})
+	if c.pending == nil {
+		// Evicting after a rollback costs only a reload
+		if pending.all {
+			c.evictAll(ctx)
+		} else if len(pending.keys) > 0 {
+			c.evict(ctx, pending.keys...)
+		}
+	}
+	return err
+}
+
+func (c *CachedStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	return cached(ctx, c, loanCacheKey(id), func() (*models.Loan, error) {
+		return c.Storage.GetLoan(ctx, id)
+	})
+}
+
+func (c *CachedStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	return cached(ctx, c, transactionsCacheKey(loanID), func() ([]*models.Transaction, error) {
+		return c.Storage.GetTransactionsForLoan(ctx, loanID)
+	})
+}
+
+// The loan updates evict the loan even when they fail, as a version mismatch means the
+// cached loan is out of date.
+
+func (c *CachedStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	defer c.evict(ctx, loanCacheKey(loan.ID))
+	return c.Storage.UpdateLoan(ctx, loan)
+}
+
+func (c *CachedStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	defer c.evict(ctx, loanCacheKey(loan.ID))
+	return c.Storage.UpdateLoanIfVersion(ctx, loan, version)
+}
+
+func (c *CachedStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	if len(loans) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(loans))
+	for i, loan := range loans {
+		keys[i] = loanCacheKey(loan.ID)
+	}
+	defer c.evict(ctx, keys...)
+	return c.Storage.UpdateLoans(ctx, loans)
+}
+
+func (c *CachedStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := c.Storage.CreateTransaction(ctx, transaction); err != nil {
+		return err
+	}
+	c.evict(ctx, transactionsCacheKey(transaction.LoanID))
+	return nil
+}
+
+func (c *CachedStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	archived, err := c.Storage.ArchiveClosedLoans(ctx, closedBefore)
+	if archived > 0 {
+		c.evictAll(ctx)
+	}
+	return archived, err
+}
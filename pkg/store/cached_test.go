@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/redis/go-redis/v9"
+	"github.com/shopspring/decimal"
+)
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	inner := NewMemoryStore()
+	faulty := NewFaultyStore(inner, FaultConfig{})
+	s := NewCachedStore(faulty, redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1, DialerRetries: 1}), time.Minute)
+	defer s.Close()
+
+	now := time.Now()
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_cache", Balance: decimal.NewFromInt(1000), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now}
+	s.CreateLoan(ctx, loan)
+	s.CreateTransaction(ctx, &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.NewFromInt(1000), Type: models.TransactionTypeDisbursement, Timestamp: now})
+
+	// Once cached, reads are served without the underlying store
+	if _, err := s.GetLoan(ctx, loan.ID); err != nil {
+		t.Fatalf("Failed to get loan: %v", err)
+	}
+	s.GetTransactionsForLoan(ctx, loan.ID)
+	faulty.SetConfig(FaultConfig{ErrorRate: 1, Methods: []string{"GetLoan", "GetTransactionsForLoan"}})
+	cachedLoan, err := s.GetLoan(ctx, loan.ID)
+	if err != nil || !cachedLoan.Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Fatalf("Expected the cached loan, got %v (%v)", cachedLoan, err)
+	}
+	if transactions, err := s.GetTransactionsForLoan(ctx, loan.ID); err != nil || len(transactions) != 1 {
+		t.Fatalf("Expected the cached transactions, got %d (%v)", len(transactions), err)
+	}
+	faulty.SetConfig(FaultConfig{})
+
+	// Writes evict what they change
+	loan.Balance = decimal.NewFromInt(600)
+	s.UpdateLoan(ctx, loan)
+	s.CreateTransaction(ctx, &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.NewFromInt(400), Type: models.TransactionTypePayment, Timestamp: now})
+	if fetched, _ := s.GetLoan(ctx, loan.ID); !fetched.Balance.Equal(decimal.NewFromInt(600)) {
+		t.Errorf("Expected the updated loan, got balance %s", fetched.Balance)
+	}
+	if transactions, _ := s.GetTransactionsForLoan(ctx, loan.ID); len(transactions) != 2 {
+		t.Errorf("Expected 2 transactions, got %d", len(transactions))
+	}
+
+	// A transaction's writes evict once it commits, and its reads see its own writes
+	err = s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		loan.Balance = decimal.NewFromInt(500)
+		if err := tx.UpdateLoan(ctx, loan); err != nil {
+			return err
+		}
+		if fetched, _ := tx.GetLoan(ctx, loan.ID); !fetched.Balance.Equal(decimal.NewFromInt(500)) {
+			t.Errorf("Expected the transaction to read its own write, got balance %s", fetched.Balance)
+		}
+		if !server.Exists(loanCacheKey(loan.ID)) {
+			t.Error("Expected the loan to stay cached until the transaction commits")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to run unit of work: %v", err)
+	}
+	if fetched, _ := s.GetLoan(ctx, loan.ID); !fetched.Balance.Equal(decimal.NewFromInt(500)) {
+		t.Errorf("Expected the committed loan, got balance %s", fetched.Balance)
+	}
+
+	// Archiving clears the cache, as the archived loans are no longer there to read
+	loan.Status = models.LoanStatusClosed
+	loan.UpdatedAt = now.AddDate(-1, 0, 0)
+	s.UpdateLoan(ctx, loan)
+	s.GetLoan(ctx, loan.ID)
+	if archived, err := s.ArchiveClosedLoans(ctx, now); err != nil || archived != 1 {
+		t.Fatalf("Expected 1 loan archived, got %d (%v)", archived, err)
+	}
+	if _, err := s.GetLoan(ctx, loan.ID); !errors.Is(err, models.ErrLoanNotFound) {
+		t.Errorf("Expected the archived loan gone, got %v", err)
+	}
+
+	// Without Redis, reads go to the underlying store
+	server.Close()
+	other := &models.Loan{ID: uuid.New(), CustomerKey: "cust_cache", Balance: decimal.NewFromInt(200), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now}
+	s.CreateLoan(ctx, other)
+	if fetched, err := s.GetLoan(ctx, other.ID); err != nil || !fetched.Balance.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("Expected the loan from the store, got %v (%v)", fetched, err)
+	}
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// dialect rewrites a query written for SQLite into the SQL of another database. A nil dialect
+// is SQLite itself and leaves queries as they are.
+type dialect func(query string) string
+
+func (d dialect) rewrite(query string) string {
+	if d == nil {
+		return query
+	}
+	return d(query)
+}
+
+// sqlDB is a database handle that passes every query through its dialect. One bound to a
+// transaction by InTransaction runs every query in that transaction instead, and the
+// transactions begun on it join that one rather than starting their own.
+type sqlDB struct {
+	*sql.DB
+	dialect dialect
+	tx      *sql.Tx
+}
+
+func (db *sqlDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if db.tx != nil {
+		return db.tx.ExecContext(ctx, db.dialect.rewrite(query), args...)
+	}
+	return db.DB.ExecContext(ctx, db.dialect.rewrite(query), args...)
+}
+
+func (db *sqlDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if db.tx != nil {
+		return db.tx.QueryContext(ctx, db.dialect.rewrite(query), args...)
+	}
+	return db.DB.QueryContext(ctx, db.dialect.rewrite(query), args...)
+}
+
+func (db *sqlDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if db.tx != nil {
+		return db.tx.QueryRowContext(ctx, db.dialect.rewrite(query), args...)
+	}
+	return db.DB.QueryRowContext(ctx, db.dialect.rewrite(query), args...)
+}
+
+// BeginTx starts a transaction whose queries go through the same dialect, or joins the one
+// the handle is bound to.
+func (db *sqlDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sqlTx, error) {
+	if db.tx != nil {
+		return &sqlTx{Tx: db.tx, dialect: db.dialect, joined: true}, nil
+	}
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect}, nil
+}
+
+// sqlTx is a transaction that passes every query through its dialect. A joined transaction
+// belongs to an enclosing InTransaction, which alone commits or rolls it back.
+type sqlTx struct {
+	*sql.Tx
+	dialect dialect
+	joined  bool
+}
+
+func (tx *sqlTx) Commit() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Commit()
+}
+
+func (tx *sqlTx) Rollback() error {
+	if tx.joined {
+		return nil
+	}
+	return tx.Tx.Rollback()
+}
+
+func (tx *sqlTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.dialect.rewrite(query), args...)
+}
+
+func (tx *sqlTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.dialect.rewrite(query), args...)
+}
+
+func (tx *sqlTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.dialect.rewrite(query), args...)
+}
+
+var (
+	juliandayPattern = regexp.MustCompile(`julianday\(([^()]*)\)`)
+	castRealPattern  = regexp.MustCompile(`CAST\(([^()]*) AS REAL\)`)
+	jsonPathPattern  = regexp.MustCompile(`json_extract\((\w+), \?\)`)
+)
+
+// postgresDialect rewrites the store's SQLite queries for PostgreSQL. Timestamps are
+// TIMESTAMPTZ and amounts NUMERIC there, so they compare and sort without julianday or a
+// cast to REAL; JSON values are read with a JSON path query; an unbounded LIMIT is ALL
+// rather than -1; and ? placeholders are numbered.
+func postgresDialect(query string) string {
+	query = juliandayPattern.ReplaceAllString(query, "$1")
+	query = castRealPattern.ReplaceAllString(query, "$1")
+	query = jsonPathPattern.ReplaceAllString(query, "(jsonb_path_query_first($1, ?::jsonpath) #>> '{}')")
+	query = strings.ReplaceAll(query, "LIMIT -1", "LIMIT ALL")
+	return numberPlaceholders(query)
+}
+
+// numberPlaceholders replaces each ? outside quotes with $1, $2, ... in order.
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0 // A doubled quote reopens on the next character
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+var (
+	onConflictNothingPattern = regexp.MustCompile(`\s*ON CONFLICT\s*\([^)]*\) DO NOTHING`)
+	onConflictUpdatePattern  = regexp.MustCompile(`ON CONFLICT\s*\([^)]*\) DO UPDATE SET`)
+	excludedPattern          = regexp.MustCompile(`excluded\.(\w+)`)
+	keyColumnPattern         = regexp.MustCompile(`\bkey\b`)
+)
+
+// mysqlDialect rewrites the store's SQLite queries for MySQL and MariaDB. Timestamps are
+// DATETIME and amounts DECIMAL there, so they compare and sort without julianday or a cast
+// to REAL; JSON values are unquoted to compare as text; an unbounded LIMIT is the largest
+// row count; upserts are ON DUPLICATE KEY UPDATE, and inserts that skip conflicts INSERT
+// IGNORE; and the key column, a reserved word, is quoted.
+func mysqlDialect(query string) string {
+	query = juliandayPattern.ReplaceAllString(query, "$1")
+	query = castRealPattern.ReplaceAllString(query, "$1")
+	query = jsonPathPattern.ReplaceAllString(query, "JSON_UNQUOTE(JSON_EXTRACT($1, ?))")
+	query = strings.ReplaceAll(query, "LIMIT -1", "LIMIT 18446744073709551615")
+	if onConflictNothingPattern.MatchString(query) {
+		query = onConflictNothingPattern.ReplaceAllString(query, "")
+		query = strings.Replace(query, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	}
+	query = onConflictUpdatePattern.ReplaceAllString(query, "ON DUPLICATE KEY UPDATE")
+	query = excludedPattern.ReplaceAllString(query, "VALUES($1)")
+	return keyColumnPattern.ReplaceAllString(query, "`key`")
+}
@@ -0,0 +1,1039 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// ErrInjectedFault is returned by FaultyStore when it injects a failure and no other
+// error has been configured.
+var ErrInjectedFault = errors.New("injected storage fault")
+
+var _ Storage = (*FaultyStore)(nil)
+
+// FaultConfig controls the failures injected by a FaultyStore.
+type FaultConfig struct {
+	// ErrorRate is the probability (0-1) that a call fails before reaching the underlying store.
+	ErrorRate float64
+	// PartialFailureRate is the probability (0-1) that a call reaches the underlying store and
+	// takes effect, but the caller still sees an error, as with a timeout after commit.
+	PartialFailureRate float64
+	// Latency is added to every call, plus a random delay of up to LatencyJitter.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+	// FailAfter lets the first N calls succeed before faults are injected (0 injects from the
+	// first call). Useful for failing a batch job part-way through.
+	FailAfter int
+	// Methods restricts injection to the named Storage methods; empty means all methods.
+	Methods []string
+	// Err is the error injected; ErrInjectedFault if nil.
+	Err error
+	// Seed seeds the random source so that a run can be reproduced; 0 uses the current time.
+	Seed int64
+}
+
+// FaultyStore decorates a Storage with configurable errors, latency and partial failures
+// for resilience testing. Close is never faulted.
+type FaultyStore struct {
+	inner Storage
+	*faults
+}
+
+// faults is a FaultyStore's configuration and call count, which the store it hands to an
+// InTransaction function shares.
+type faults struct {
+	mu      sync.Mutex
+	cfg     FaultConfig
+	methods map[string]bool
+	rand    *rand.Rand
+	calls   int
+}
+
+// NewFaultyStore wraps s with the given fault configuration.
+func NewFaultyStore(s Storage, cfg FaultConfig) *FaultyStore {
+	f := &FaultyStore{inner: s, faults: &faults{}}
+	f.SetConfig(cfg)
+	return f
+}
+
+// SetConfig replaces the fault configuration and resets the call count.
+func (f *FaultyStore) SetConfig(cfg FaultConfig) {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	methods := make(map[string]bool, len(cfg.Methods))
+	for _, m := range cfg.Methods {
+		methods[m] = true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cfg = cfg
+	f.methods = methods
+	f.rand = rand.New(rand.NewSource(seed))
+	f.calls = 0
+}
+
+// Calls returns the number of faultable calls made since the configuration was last set.
+func (f *FaultyStore) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// faultErr returns the configured error to inject.
+func (f *FaultyStore) faultErr() error {
+	if f.cfg.Err != nil {
+		return f.cfg.Err
+	}
+	return ErrInjectedFault
+}
+
+// before applies latency, cut short if ctx is done first, and decides whether the call fails
+// outright.
+func (f *FaultyStore) before(ctx context.Context, method string) error {
+	f.mu.Lock()
+	f.calls++
+	delay := f.cfg.Latency
+	if f.cfg.LatencyJitter > 0 {
+		delay += time.Duration(f.rand.Int63n(int64(f.cfg.LatencyJitter)))
+	}
+	var err error
+	if f.armed(method) && f.rand.Float64() < f.cfg.ErrorRate {
+		err = f.faultErr()
+	}
+	f.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err() // The caller gave up waiting, as it would on a slow database
+		}
+	}
+	return err
+}
+
+// after decides whether a call that reached the underlying store reports a partial failure.
+func (f *FaultyStore) after(method string, err error) error {
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.armed(method) && f.rand.Float64() < f.cfg.PartialFailureRate {
+		return f.faultErr()
+	}
+	return nil
+}
+
+// armed reports whether faults may be injected into the method on the current call.
+// Callers must hold f.mu.
+func (f *FaultyStore) armed(method string) bool {
+	if f.calls <= f.cfg.FailAfter {
+		return false
+	}
+	return len(f.methods) == 0 || f.methods[method]
+}
+
+// Close closes the underlying store.
+func (f *FaultyStore) Close() error {
+	return f.inner.Close()
+}
+
+// InTransaction can fail like any call, and fn's calls through the store it is given are
+// faulted under the same configuration. A partial failure reports an error for a committed
+// transaction.
+func (f *FaultyStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	if err := f.before(ctx, "InTransaction"); err != nil {
+		return err
+	}
+	return f.after("InTransaction", f.inner.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		return fn(ctx, &FaultyStore{inner: tx, faults: f.faults})
+	}))
+}
+
+// ForEachActiveLoan can fail like any call; fn's own errors are returned as they are.
+func (f *FaultyStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	if err := f.before(ctx, "ForEachActiveLoan"); err != nil {
+		return err
+	}
+	return f.after("ForEachActiveLoan", f.inner.ForEachActiveLoan(ctx, fn))
+}
+
+// Storage methods below are wrapped with fault injection.
+
+func (f *FaultyStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	if err := f.before(ctx, "CreateLoan"); err != nil {
+		return err
+	}
+	return f.after("CreateLoan", f.inner.CreateLoan(ctx, loan))
+}
+
+func (f *FaultyStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	if err := f.before(ctx, "GetLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetLoan(ctx, id)
+	if err = f.after("GetLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	if err := f.before(ctx, "UpdateLoan"); err != nil {
+		return err
+	}
+	return f.after("UpdateLoan", f.inner.UpdateLoan(ctx, loan))
+}
+
+func (f *FaultyStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	if err := f.before(ctx, "UpdateLoanIfVersion"); err != nil {
+		return err
+	}
+	return f.after("UpdateLoanIfVersion", f.inner.UpdateLoanIfVersion(ctx, loan, version))
+}
+
+func (f *FaultyStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	if err := f.before(ctx, "UpdateLoans"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.UpdateLoans(ctx, loans)
+	if err = f.after("UpdateLoans", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	if err := f.before(ctx, "GetAllLoans"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAllLoans(ctx)
+	if err = f.after("GetAllLoans", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error) {
+	if err := f.before(ctx, "ListLoans"); err != nil {
+		return nil, 0, err
+	}
+	result, count, err := f.inner.ListLoans(ctx, query)
+	if err = f.after("ListLoans", err); err != nil {
+		return nil, 0, err
+	}
+	return result, count, nil
+}
+
+func (f *FaultyStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error) {
+	if err := f.before(ctx, "ListLoansAfter"); err != nil {
+		return nil, nil, err
+	}
+	result, next, err := f.inner.ListLoansAfter(ctx, query, cursor, limit)
+	if err = f.after("ListLoansAfter", err); err != nil {
+		return nil, nil, err
+	}
+	return result, next, nil
+}
+
+func (f *FaultyStore) SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error) {
+	if err := f.before(ctx, "SearchLoans"); err != nil {
+		return nil, 0, err
+	}
+	result, count, err := f.inner.SearchLoans(ctx, search)
+	if err = f.after("SearchLoans", err); err != nil {
+		return nil, 0, err
+	}
+	return result, count, nil
+}
+
+func (f *FaultyStore) GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error) {
+	if err := f.before(ctx, "GetAllActiveLoans"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAllActiveLoans(ctx)
+	if err = f.after("GetAllActiveLoans", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error) {
+	if err := f.before(ctx, "GetLoansByStatus"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetLoansByStatus(ctx, status)
+	if err = f.after("GetLoansByStatus", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	if err := f.before(ctx, "GetLoansByCustomerKey"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetLoansByCustomerKey(ctx, customerKey)
+	if err = f.after("GetLoansByCustomerKey", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	if err := f.before(ctx, "GetCustomerSummary"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetCustomerSummary(ctx, customerKey)
+	if err = f.after("GetCustomerSummary", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error) {
+	if err := f.before(ctx, "GetDelinquentLoans"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetDelinquentLoans(ctx, minDaysPastDue)
+	if err = f.after("GetDelinquentLoans", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	if err := f.before(ctx, "CreateTransaction"); err != nil {
+		return err
+	}
+	return f.after("CreateTransaction", f.inner.CreateTransaction(ctx, transaction))
+}
+
+func (f *FaultyStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	if err := f.before(ctx, "GetTransactionsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetTransactionsForLoan(ctx, loanID)
+	if err = f.after("GetTransactionsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	if err := f.before(ctx, "QueryTransactions"); err != nil {
+		return nil, 0, err
+	}
+	result, count, err := f.inner.QueryTransactions(ctx, loanID, query)
+	if err = f.after("QueryTransactions", err); err != nil {
+		return nil, 0, err
+	}
+	return result, count, nil
+}
+
+func (f *FaultyStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	if err := f.before(ctx, "CreateLoanEvent"); err != nil {
+		return err
+	}
+	return f.after("CreateLoanEvent", f.inner.CreateLoanEvent(ctx, event))
+}
+
+func (f *FaultyStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	if err := f.before(ctx, "GetLoanEventsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetLoanEventsForLoan(ctx, loanID)
+	if err = f.after("GetLoanEventsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	if err := f.before(ctx, "CreateRateChange"); err != nil {
+		return err
+	}
+	return f.after("CreateRateChange", f.inner.CreateRateChange(ctx, change))
+}
+
+func (f *FaultyStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	if err := f.before(ctx, "GetRateHistory"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetRateHistory(ctx, loanID)
+	if err = f.after("GetRateHistory", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error) {
+	if err := f.before(ctx, "GetRateInEffect"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetRateInEffect(ctx, loanID, date)
+	if err = f.after("GetRateInEffect", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	if err := f.before(ctx, "CreateIndexRate"); err != nil {
+		return err
+	}
+	return f.after("CreateIndexRate", f.inner.CreateIndexRate(ctx, rate))
+}
+
+func (f *FaultyStore) GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error) {
+	if err := f.before(ctx, "GetLatestIndexRate"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetLatestIndexRate(ctx, indexCode)
+	if err = f.after("GetLatestIndexRate", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	if err := f.before(ctx, "GetIndexRates"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetIndexRates(ctx, indexCode)
+	if err = f.after("GetIndexRates", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	if err := f.before(ctx, "SavePortfolioSnapshot"); err != nil {
+		return err
+	}
+	return f.after("SavePortfolioSnapshot", f.inner.SavePortfolioSnapshot(ctx, snapshot))
+}
+
+func (f *FaultyStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	if err := f.before(ctx, "GetPortfolioSnapshots"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetPortfolioSnapshots(ctx, from, to)
+	if err = f.after("GetPortfolioSnapshots", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	if err := f.before(ctx, "CreateInterestIntent"); err != nil {
+		return err
+	}
+	return f.after("CreateInterestIntent", f.inner.CreateInterestIntent(ctx, intent))
+}
+
+func (f *FaultyStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	if err := f.before(ctx, "UpdateInterestIntent"); err != nil {
+		return err
+	}
+	return f.after("UpdateInterestIntent", f.inner.UpdateInterestIntent(ctx, intent))
+}
+
+func (f *FaultyStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error) {
+	if err := f.before(ctx, "GetInterestIntent"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetInterestIntent(ctx, loanID, cycle)
+	if err = f.after("GetInterestIntent", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	if err := f.before(ctx, "GetInterestIntentsByStatus"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetInterestIntentsByStatus(ctx, status)
+	if err = f.after("GetInterestIntentsByStatus", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error) {
+	if err := f.before(ctx, "GetInterestIntentsForCycle"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetInterestIntentsForCycle(ctx, cycle)
+	if err = f.after("GetInterestIntentsForCycle", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	if err := f.before(ctx, "CreateIdempotencyRecord"); err != nil {
+		return err
+	}
+	return f.after("CreateIdempotencyRecord", f.inner.CreateIdempotencyRecord(ctx, record))
+}
+
+func (f *FaultyStore) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	if err := f.before(ctx, "GetIdempotencyRecord"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetIdempotencyRecord(ctx, key)
+	if err = f.after("GetIdempotencyRecord", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	if err := f.before(ctx, "UpdateIdempotencyRecord"); err != nil {
+		return err
+	}
+	return f.after("UpdateIdempotencyRecord", f.inner.UpdateIdempotencyRecord(ctx, record))
+}
+
+func (f *FaultyStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	if err := f.before(ctx, "DeleteIdempotencyRecord"); err != nil {
+		return err
+	}
+	return f.after("DeleteIdempotencyRecord", f.inner.DeleteIdempotencyRecord(ctx, key))
+}
+
+func (f *FaultyStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	if err := f.before(ctx, "CreateStatement"); err != nil {
+		return err
+	}
+	return f.after("CreateStatement", f.inner.CreateStatement(ctx, statement))
+}
+
+func (f *FaultyStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error) {
+	if err := f.before(ctx, "GetStatement"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetStatement(ctx, loanID, cycle)
+	if err = f.after("GetStatement", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	if err := f.before(ctx, "GetStatementsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetStatementsForLoan(ctx, loanID)
+	if err = f.after("GetStatementsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	if err := f.before(ctx, "SaveAutopayEnrollment"); err != nil {
+		return err
+	}
+	return f.after("SaveAutopayEnrollment", f.inner.SaveAutopayEnrollment(ctx, enrollment))
+}
+
+func (f *FaultyStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	if err := f.before(ctx, "GetAutopayEnrollment"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAutopayEnrollment(ctx, loanID)
+	if err = f.after("GetAutopayEnrollment", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	if err := f.before(ctx, "DeleteAutopayEnrollment"); err != nil {
+		return err
+	}
+	return f.after("DeleteAutopayEnrollment", f.inner.DeleteAutopayEnrollment(ctx, loanID))
+}
+
+func (f *FaultyStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error) {
+	if err := f.before(ctx, "GetAutopayEnrollmentsForDay"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAutopayEnrollmentsForDay(ctx, day)
+	if err = f.after("GetAutopayEnrollmentsForDay", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	if err := f.before(ctx, "CreateCollateral"); err != nil {
+		return err
+	}
+	return f.after("CreateCollateral", f.inner.CreateCollateral(ctx, collateral))
+}
+
+func (f *FaultyStore) GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error) {
+	if err := f.before(ctx, "GetCollateral"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetCollateral(ctx, id)
+	if err = f.after("GetCollateral", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	if err := f.before(ctx, "UpdateCollateral"); err != nil {
+		return err
+	}
+	return f.after("UpdateCollateral", f.inner.UpdateCollateral(ctx, collateral))
+}
+
+func (f *FaultyStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	if err := f.before(ctx, "DeleteCollateral"); err != nil {
+		return err
+	}
+	return f.after("DeleteCollateral", f.inner.DeleteCollateral(ctx, id))
+}
+
+func (f *FaultyStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	if err := f.before(ctx, "GetCollateralForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetCollateralForLoan(ctx, loanID)
+	if err = f.after("GetCollateralForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	if err := f.before(ctx, "CreateForbearance"); err != nil {
+		return err
+	}
+	return f.after("CreateForbearance", f.inner.CreateForbearance(ctx, forbearance))
+}
+
+func (f *FaultyStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	if err := f.before(ctx, "GetForbearancesForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetForbearancesForLoan(ctx, loanID)
+	if err = f.after("GetForbearancesForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	if err := f.before(ctx, "SaveAccrual"); err != nil {
+		return err
+	}
+	return f.after("SaveAccrual", f.inner.SaveAccrual(ctx, accrual))
+}
+
+func (f *FaultyStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	if err := f.before(ctx, "GetAccrualsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAccrualsForLoan(ctx, loanID, from, to)
+	if err = f.after("GetAccrualsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	if err := f.before(ctx, "AppendLedgerEvent"); err != nil {
+		return err
+	}
+	return f.after("AppendLedgerEvent", f.inner.AppendLedgerEvent(ctx, event))
+}
+
+func (f *FaultyStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	if err := f.before(ctx, "GetLedgerEvents"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetLedgerEvents(ctx, loanID)
+	if err = f.after("GetLedgerEvents", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	if err := f.before(ctx, "SaveBureauRecord"); err != nil {
+		return err
+	}
+	return f.after("SaveBureauRecord", f.inner.SaveBureauRecord(ctx, record))
+}
+
+func (f *FaultyStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error) {
+	if err := f.before(ctx, "GetBureauRecordsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetBureauRecordsForLoan(ctx, loanID)
+	if err = f.after("GetBureauRecordsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	if err := f.before(ctx, "ArchiveClosedLoans"); err != nil {
+		return 0, err
+	}
+	result, err := f.inner.ArchiveClosedLoans(ctx, closedBefore)
+	if err = f.after("ArchiveClosedLoans", err); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	if err := f.before(ctx, "MarkArchivedLoansExported"); err != nil {
+		return err
+	}
+	return f.after("MarkArchivedLoansExported", f.inner.MarkArchivedLoansExported(ctx, ids, exportedAt))
+}
+
+func (f *FaultyStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	if err := f.before(ctx, "DeleteArchivedLoans"); err != nil {
+		return err
+	}
+	return f.after("DeleteArchivedLoans", f.inner.DeleteArchivedLoans(ctx, ids))
+}
+
+func (f *FaultyStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	if err := f.before(ctx, "GetArchivedLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetArchivedLoan(ctx, id)
+	if err = f.after("GetArchivedLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	if err := f.before(ctx, "GetArchivedTransactionsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetArchivedTransactionsForLoan(ctx, loanID)
+	if err = f.after("GetArchivedTransactionsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	if err := f.before(ctx, "GetArchivedLoanEventsForLoan"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetArchivedLoanEventsForLoan(ctx, loanID)
+	if err = f.after("GetArchivedLoanEventsForLoan", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	if err := f.before(ctx, "GetArchivedRateHistory"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetArchivedRateHistory(ctx, loanID)
+	if err = f.after("GetArchivedRateHistory", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error) {
+	if err := f.before(ctx, "GetArchivedLoansToExport"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetArchivedLoansToExport(ctx, closedBefore, limit)
+	if err = f.after("GetArchivedLoansToExport", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	if err := f.before(ctx, "CreatePaymentMethod"); err != nil {
+		return err
+	}
+	return f.after("CreatePaymentMethod", f.inner.CreatePaymentMethod(ctx, method))
+}
+
+func (f *FaultyStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	if err := f.before(ctx, "GetPaymentMethod"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetPaymentMethod(ctx, id)
+	if err = f.after("GetPaymentMethod", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	if err := f.before(ctx, "UpdatePaymentMethod"); err != nil {
+		return err
+	}
+	return f.after("UpdatePaymentMethod", f.inner.UpdatePaymentMethod(ctx, method))
+}
+
+func (f *FaultyStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	if err := f.before(ctx, "GetPaymentMethodsForCustomer"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetPaymentMethodsForCustomer(ctx, customerKey)
+	if err = f.after("GetPaymentMethodsForCustomer", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	if err := f.before(ctx, "CreatePaymentLink"); err != nil {
+		return err
+	}
+	return f.after("CreatePaymentLink", f.inner.CreatePaymentLink(ctx, link))
+}
+
+func (f *FaultyStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error) {
+	if err := f.before(ctx, "GetPaymentLink"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetPaymentLink(ctx, id)
+	if err = f.after("GetPaymentLink", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	if err := f.before(ctx, "UpdatePaymentLink"); err != nil {
+		return err
+	}
+	return f.after("UpdatePaymentLink", f.inner.UpdatePaymentLink(ctx, link))
+}
+
+func (f *FaultyStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	if err := f.before(ctx, "RedeemPaymentLink"); err != nil {
+		return err
+	}
+	return f.after("RedeemPaymentLink", f.inner.RedeemPaymentLink(ctx, link))
+}
+
+func (f *FaultyStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	if err := f.before(ctx, "CreateWebhookSubscription"); err != nil {
+		return err
+	}
+	return f.after("CreateWebhookSubscription", f.inner.CreateWebhookSubscription(ctx, subscription))
+}
+
+func (f *FaultyStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	if err := f.before(ctx, "GetWebhookSubscription"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetWebhookSubscription(ctx, id)
+	if err = f.after("GetWebhookSubscription", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	if err := f.before(ctx, "GetWebhookSubscriptions"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetWebhookSubscriptions(ctx)
+	if err = f.after("GetWebhookSubscriptions", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	if err := f.before(ctx, "DeleteWebhookSubscription"); err != nil {
+		return err
+	}
+	return f.after("DeleteWebhookSubscription", f.inner.DeleteWebhookSubscription(ctx, id))
+}
+
+func (f *FaultyStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := f.before(ctx, "CreateWebhookDelivery"); err != nil {
+		return err
+	}
+	return f.after("CreateWebhookDelivery", f.inner.CreateWebhookDelivery(ctx, delivery))
+}
+
+func (f *FaultyStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	if err := f.before(ctx, "UpdateWebhookDelivery"); err != nil {
+		return err
+	}
+	return f.after("UpdateWebhookDelivery", f.inner.UpdateWebhookDelivery(ctx, delivery))
+}
+
+func (f *FaultyStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	if err := f.before(ctx, "GetDueWebhookDeliveries"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetDueWebhookDeliveries(ctx, at, limit)
+	if err = f.after("GetDueWebhookDeliveries", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	if err := f.before(ctx, "GetWebhookDeliveriesForSubscription"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetWebhookDeliveriesForSubscription(ctx, subscriptionID, limit)
+	if err = f.after("GetWebhookDeliveriesForSubscription", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	if err := f.before(ctx, "CreateOutboxEvent"); err != nil {
+		return err
+	}
+	return f.after("CreateOutboxEvent", f.inner.CreateOutboxEvent(ctx, event))
+}
+
+func (f *FaultyStore) GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	if err := f.before(ctx, "GetPendingOutboxEvents"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetPendingOutboxEvents(ctx, limit)
+	if err = f.after("GetPendingOutboxEvents", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	if err := f.before(ctx, "MarkOutboxEventPublished"); err != nil {
+		return err
+	}
+	return f.after("MarkOutboxEventPublished", f.inner.MarkOutboxEventPublished(ctx, id, at))
+}
+
+func (f *FaultyStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	if err := f.before(ctx, "CreateProduct"); err != nil {
+		return err
+	}
+	return f.after("CreateProduct", f.inner.CreateProduct(ctx, product))
+}
+
+func (f *FaultyStore) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	if err := f.before(ctx, "GetProduct"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetProduct(ctx, code)
+	if err = f.after("GetProduct", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	if err := f.before(ctx, "UpdateProduct"); err != nil {
+		return err
+	}
+	return f.after("UpdateProduct", f.inner.UpdateProduct(ctx, product))
+}
+
+func (f *FaultyStore) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	if err := f.before(ctx, "GetAllProducts"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAllProducts(ctx)
+	if err = f.after("GetAllProducts", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	if err := f.before(ctx, "CreateCustomer"); err != nil {
+		return err
+	}
+	return f.after("CreateCustomer", f.inner.CreateCustomer(ctx, customer))
+}
+
+func (f *FaultyStore) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	if err := f.before(ctx, "GetCustomer"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetCustomer(ctx, customerKey)
+	if err = f.after("GetCustomer", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	if err := f.before(ctx, "UpdateCustomer"); err != nil {
+		return err
+	}
+	return f.after("UpdateCustomer", f.inner.UpdateCustomer(ctx, customer))
+}
+
+func (f *FaultyStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	if err := f.before(ctx, "DeleteCustomer"); err != nil {
+		return err
+	}
+	return f.after("DeleteCustomer", f.inner.DeleteCustomer(ctx, customerKey))
+}
+
+func (f *FaultyStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	if err := f.before(ctx, "GetAllCustomers"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetAllCustomers(ctx)
+	if err = f.after("GetAllCustomers", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (f *FaultyStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	if err := f.before(ctx, "CreateJobRun"); err != nil {
+		return err
+	}
+	return f.after("CreateJobRun", f.inner.CreateJobRun(ctx, run))
+}
+
+func (f *FaultyStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	if err := f.before(ctx, "GetJobRuns"); err != nil {
+		return nil, err
+	}
+	result, err := f.inner.GetJobRuns(ctx, job, limit)
+	if err = f.after("GetJobRuns", err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func newFaultTestLoan() *models.Loan {
+	return &models.Loan{
+		ID:                uuid.New(),
+		CustomerKey:       "cust_fault",
+		Principal:         decimal.NewFromInt(100),
+		Balance:           decimal.NewFromInt(100),
+		InterestRate:      decimal.NewFromFloat(0.1),
+		Status:            "active",
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		StatementCycleDay: 1,
+	}
+}
+
+func TestFaultyStore(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_faulty_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	f := NewFaultyStore(s, FaultConfig{ErrorRate: 1, Methods: []string{"CreateLoan"}, FailAfter: 1, Seed: 1})
+	defer f.Close()
+
+	// The first call is allowed through
+	first := newFaultTestLoan()
+	if err := f.CreateLoan(ctx, first); err != nil {
+		t.Fatalf("Expected first call to succeed, got %v", err)
+	}
+
+	// Subsequent calls to the faulted method fail without reaching the store
+	second := newFaultTestLoan()
+	if err := f.CreateLoan(ctx, second); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("Expected injected fault, got %v", err)
+	}
+	if _, err := s.GetLoan(ctx, second.ID); err == nil {
+		t.Error("Expected failed call not to reach the store")
+	}
+
+	// Other methods are unaffected
+	if _, err := f.GetLoan(ctx, first.ID); err != nil {
+		t.Errorf("Expected GetLoan to be unaffected, got %v", err)
+	}
+
+	// A partial failure takes effect but still reports an error
+	custom := errors.New("timeout after commit")
+	f.SetConfig(FaultConfig{PartialFailureRate: 1, Methods: []string{"CreateLoan"}, Err: custom})
+	third := newFaultTestLoan()
+	if err := f.CreateLoan(ctx, third); err != custom {
+		t.Fatalf("Expected partial failure error, got %v", err)
+	}
+	if _, err := s.GetLoan(ctx, third.ID); err != nil {
+		t.Errorf("Expected partially failed write to be stored, got %v", err)
+	}
+
+	f.SetConfig(FaultConfig{Latency: 20 * time.Millisecond})
+	start := time.Now()
+	f.GetAllLoans(ctx)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected injected latency, call took %s", elapsed)
+	}
+	if f.Calls() != 1 {
+		t.Errorf("Expected 1 call since reconfiguring, got %d", f.Calls())
+	}
+
+	// A caller that stops waiting is not held up by the injected latency
+	f.SetConfig(FaultConfig{Latency: time.Minute})
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := f.GetAllLoans(cancelled); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to cut the latency short, got %v", err)
+	}
+}
@@ -0,0 +1,757 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/metrics"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+var _ Storage = (*InstrumentedStore)(nil)
+
+// storeCallBuckets are histogram upper bounds suited to store calls in seconds, which are
+// mostly well under the request latencies metrics.DefaultBuckets are made for.
+var storeCallBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// storeMetrics are the collectors an InstrumentedStore and the stores it hands to
+// transactions record into.
+type storeMetrics struct {
+	duration *metrics.HistogramVec
+	errors   *metrics.CounterVec
+	slow     time.Duration // Calls taking at least this long are logged; zero logs none
+}
+
+// InstrumentedStore decorates a Storage with a latency histogram and an error counter per
+// method, and logs calls slower than a threshold. Errors are counted by kind, so that a SQLite
+// database turning writers away as busy, the sign that write contention is the bottleneck,
+// stands out from other failures.
+type InstrumentedStore struct {
+	inner   Storage
+	metrics *storeMetrics
+}
+
+// NewInstrumentedStore wraps s so its calls are measured. Calls taking slowThreshold or longer
+// are logged as warnings; a zero threshold logs none. The metrics are exposed by registering
+// Collectors.
+func NewInstrumentedStore(s Storage, slowThreshold time.Duration) *InstrumentedStore {
+	return &InstrumentedStore{inner: s, metrics: &storeMetrics{
+		duration: metrics.NewHistogramVec("store_call_duration_seconds", "Time taken by store calls, by method.", storeCallBuckets, "method"),
+		errors:   metrics.NewCounterVec("store_call_errors_total", "Store calls that returned an error, by method and kind: busy, canceled or error.", "method", "kind"),
+		slow:     slowThreshold,
+	}}
+}
+
+// Collectors returns the store's metrics, for registering on the server's registry.
+func (s *InstrumentedStore) Collectors() []metrics.Collector {
+	return []metrics.Collector{s.metrics.duration, s.metrics.errors}
+}
+
+func (s *InstrumentedStore) observe(method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	s.metrics.duration.Observe(elapsed.Seconds(), method)
+	if err != nil {
+		s.metrics.errors.Inc(method, errorKind(err))
+	}
+	if s.metrics.slow > 0 && elapsed >= s.metrics.slow {
+		slog.Warn("Slow store call", "method", method, "duration", elapsed, "err", err)
+	}
+}
+
+// errorKind classifies a store error for the error counter.
+func errorKind(err error) string {
+	switch {
+	case sqliteBusy(err):
+		return "busy"
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		return "canceled"
+	}
+	return "error"
+}
+
+// Close closes the underlying store.
+func (s *InstrumentedStore) Close() error {
+	return s.inner.Close()
+}
+
+// InTransaction measures the whole transaction, and fn's calls through the store it is given
+// are measured as well.
+func (s *InstrumentedStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	start := time.Now()
+	err := s.inner.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		return fn(ctx, &InstrumentedStore{inner: tx, metrics: s.metrics})
+	})
+	s.observe("InTransaction", start, err)
+	return err
+}
+
+// ForEachActiveLoan measures the whole iteration, including the time fn takes.
+func (s *InstrumentedStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	start := time.Now()
+	err := s.inner.ForEachActiveLoan(ctx, fn)
+	s.observe("ForEachActiveLoan", start, err)
+	return err
+}
+
+// Storage methods below are measured.
+
+func (s *InstrumentedStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	start := time.Now()
+	err := s.inner.CreateLoan(ctx, loan)
+	s.observe("CreateLoan", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetLoan(ctx, id)
+	s.observe("GetLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	start := time.Now()
+	err := s.inner.UpdateLoan(ctx, loan)
+	s.observe("UpdateLoan", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	start := time.Now()
+	err := s.inner.UpdateLoanIfVersion(ctx, loan, version)
+	s.observe("UpdateLoanIfVersion", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	start := time.Now()
+	result, err := s.inner.UpdateLoans(ctx, loans)
+	s.observe("UpdateLoans", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetAllLoans(ctx)
+	s.observe("GetAllLoans", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error) {
+	start := time.Now()
+	result, count, err := s.inner.ListLoans(ctx, query)
+	s.observe("ListLoans", start, err)
+	return result, count, err
+}
+
+func (s *InstrumentedStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error) {
+	start := time.Now()
+	result, next, err := s.inner.ListLoansAfter(ctx, query, cursor, limit)
+	s.observe("ListLoansAfter", start, err)
+	return result, next, err
+}
+
+func (s *InstrumentedStore) SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error) {
+	start := time.Now()
+	result, count, err := s.inner.SearchLoans(ctx, search)
+	s.observe("SearchLoans", start, err)
+	return result, count, err
+}
+
+func (s *InstrumentedStore) GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetAllActiveLoans(ctx)
+	s.observe("GetAllActiveLoans", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetLoansByStatus(ctx, status)
+	s.observe("GetLoansByStatus", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetLoansByCustomerKey(ctx, customerKey)
+	s.observe("GetLoansByCustomerKey", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	start := time.Now()
+	result, err := s.inner.GetCustomerSummary(ctx, customerKey)
+	s.observe("GetCustomerSummary", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetDelinquentLoans(ctx, minDaysPastDue)
+	s.observe("GetDelinquentLoans", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	start := time.Now()
+	err := s.inner.CreateTransaction(ctx, transaction)
+	s.observe("CreateTransaction", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	start := time.Now()
+	result, err := s.inner.GetTransactionsForLoan(ctx, loanID)
+	s.observe("GetTransactionsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	start := time.Now()
+	result, count, err := s.inner.QueryTransactions(ctx, loanID, query)
+	s.observe("QueryTransactions", start, err)
+	return result, count, err
+}
+
+func (s *InstrumentedStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	start := time.Now()
+	err := s.inner.CreateLoanEvent(ctx, event)
+	s.observe("CreateLoanEvent", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	start := time.Now()
+	result, err := s.inner.GetLoanEventsForLoan(ctx, loanID)
+	s.observe("GetLoanEventsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	start := time.Now()
+	err := s.inner.CreateRateChange(ctx, change)
+	s.observe("CreateRateChange", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	start := time.Now()
+	result, err := s.inner.GetRateHistory(ctx, loanID)
+	s.observe("GetRateHistory", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error) {
+	start := time.Now()
+	result, err := s.inner.GetRateInEffect(ctx, loanID, date)
+	s.observe("GetRateInEffect", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	start := time.Now()
+	err := s.inner.CreateIndexRate(ctx, rate)
+	s.observe("CreateIndexRate", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error) {
+	start := time.Now()
+	result, err := s.inner.GetLatestIndexRate(ctx, indexCode)
+	s.observe("GetLatestIndexRate", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	start := time.Now()
+	result, err := s.inner.GetIndexRates(ctx, indexCode)
+	s.observe("GetIndexRates", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	start := time.Now()
+	err := s.inner.SavePortfolioSnapshot(ctx, snapshot)
+	s.observe("SavePortfolioSnapshot", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	start := time.Now()
+	result, err := s.inner.GetPortfolioSnapshots(ctx, from, to)
+	s.observe("GetPortfolioSnapshots", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	start := time.Now()
+	err := s.inner.CreateInterestIntent(ctx, intent)
+	s.observe("CreateInterestIntent", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	start := time.Now()
+	err := s.inner.UpdateInterestIntent(ctx, intent)
+	s.observe("UpdateInterestIntent", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error) {
+	start := time.Now()
+	result, err := s.inner.GetInterestIntent(ctx, loanID, cycle)
+	s.observe("GetInterestIntent", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	start := time.Now()
+	result, err := s.inner.GetInterestIntentsByStatus(ctx, status)
+	s.observe("GetInterestIntentsByStatus", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error) {
+	start := time.Now()
+	result, err := s.inner.GetInterestIntentsForCycle(ctx, cycle)
+	s.observe("GetInterestIntentsForCycle", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	start := time.Now()
+	err := s.inner.CreateIdempotencyRecord(ctx, record)
+	s.observe("CreateIdempotencyRecord", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	start := time.Now()
+	result, err := s.inner.GetIdempotencyRecord(ctx, key)
+	s.observe("GetIdempotencyRecord", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	start := time.Now()
+	err := s.inner.UpdateIdempotencyRecord(ctx, record)
+	s.observe("UpdateIdempotencyRecord", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	start := time.Now()
+	err := s.inner.DeleteIdempotencyRecord(ctx, key)
+	s.observe("DeleteIdempotencyRecord", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	start := time.Now()
+	err := s.inner.CreateStatement(ctx, statement)
+	s.observe("CreateStatement", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error) {
+	start := time.Now()
+	result, err := s.inner.GetStatement(ctx, loanID, cycle)
+	s.observe("GetStatement", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	start := time.Now()
+	result, err := s.inner.GetStatementsForLoan(ctx, loanID)
+	s.observe("GetStatementsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	start := time.Now()
+	err := s.inner.SaveAutopayEnrollment(ctx, enrollment)
+	s.observe("SaveAutopayEnrollment", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	start := time.Now()
+	result, err := s.inner.GetAutopayEnrollment(ctx, loanID)
+	s.observe("GetAutopayEnrollment", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	start := time.Now()
+	err := s.inner.DeleteAutopayEnrollment(ctx, loanID)
+	s.observe("DeleteAutopayEnrollment", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error) {
+	start := time.Now()
+	result, err := s.inner.GetAutopayEnrollmentsForDay(ctx, day)
+	s.observe("GetAutopayEnrollmentsForDay", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	start := time.Now()
+	err := s.inner.CreateCollateral(ctx, collateral)
+	s.observe("CreateCollateral", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error) {
+	start := time.Now()
+	result, err := s.inner.GetCollateral(ctx, id)
+	s.observe("GetCollateral", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	start := time.Now()
+	err := s.inner.UpdateCollateral(ctx, collateral)
+	s.observe("UpdateCollateral", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	start := time.Now()
+	err := s.inner.DeleteCollateral(ctx, id)
+	s.observe("DeleteCollateral", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	start := time.Now()
+	result, err := s.inner.GetCollateralForLoan(ctx, loanID)
+	s.observe("GetCollateralForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	start := time.Now()
+	err := s.inner.CreateForbearance(ctx, forbearance)
+	s.observe("CreateForbearance", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	start := time.Now()
+	result, err := s.inner.GetForbearancesForLoan(ctx, loanID)
+	s.observe("GetForbearancesForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	start := time.Now()
+	err := s.inner.SaveAccrual(ctx, accrual)
+	s.observe("SaveAccrual", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	start := time.Now()
+	result, err := s.inner.GetAccrualsForLoan(ctx, loanID, from, to)
+	s.observe("GetAccrualsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	start := time.Now()
+	err := s.inner.AppendLedgerEvent(ctx, event)
+	s.observe("AppendLedgerEvent", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	start := time.Now()
+	result, err := s.inner.GetLedgerEvents(ctx, loanID)
+	s.observe("GetLedgerEvents", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	start := time.Now()
+	err := s.inner.SaveBureauRecord(ctx, record)
+	s.observe("SaveBureauRecord", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error) {
+	start := time.Now()
+	result, err := s.inner.GetBureauRecordsForLoan(ctx, loanID)
+	s.observe("GetBureauRecordsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	start := time.Now()
+	result, err := s.inner.ArchiveClosedLoans(ctx, closedBefore)
+	s.observe("ArchiveClosedLoans", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	start := time.Now()
+	err := s.inner.MarkArchivedLoansExported(ctx, ids, exportedAt)
+	s.observe("MarkArchivedLoansExported", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	start := time.Now()
+	err := s.inner.DeleteArchivedLoans(ctx, ids)
+	s.observe("DeleteArchivedLoans", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetArchivedLoan(ctx, id)
+	s.observe("GetArchivedLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	start := time.Now()
+	result, err := s.inner.GetArchivedTransactionsForLoan(ctx, loanID)
+	s.observe("GetArchivedTransactionsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	start := time.Now()
+	result, err := s.inner.GetArchivedLoanEventsForLoan(ctx, loanID)
+	s.observe("GetArchivedLoanEventsForLoan", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	start := time.Now()
+	result, err := s.inner.GetArchivedRateHistory(ctx, loanID)
+	s.observe("GetArchivedRateHistory", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error) {
+	start := time.Now()
+	result, err := s.inner.GetArchivedLoansToExport(ctx, closedBefore, limit)
+	s.observe("GetArchivedLoansToExport", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	start := time.Now()
+	err := s.inner.CreatePaymentMethod(ctx, method)
+	s.observe("CreatePaymentMethod", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	start := time.Now()
+	result, err := s.inner.GetPaymentMethod(ctx, id)
+	s.observe("GetPaymentMethod", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	start := time.Now()
+	err := s.inner.UpdatePaymentMethod(ctx, method)
+	s.observe("UpdatePaymentMethod", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	start := time.Now()
+	result, err := s.inner.GetPaymentMethodsForCustomer(ctx, customerKey)
+	s.observe("GetPaymentMethodsForCustomer", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	start := time.Now()
+	err := s.inner.CreatePaymentLink(ctx, link)
+	s.observe("CreatePaymentLink", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error) {
+	start := time.Now()
+	result, err := s.inner.GetPaymentLink(ctx, id)
+	s.observe("GetPaymentLink", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	start := time.Now()
+	err := s.inner.UpdatePaymentLink(ctx, link)
+	s.observe("UpdatePaymentLink", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	start := time.Now()
+	err := s.inner.RedeemPaymentLink(ctx, link)
+	s.observe("RedeemPaymentLink", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	start := time.Now()
+	err := s.inner.CreateWebhookSubscription(ctx, subscription)
+	s.observe("CreateWebhookSubscription", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	start := time.Now()
+	result, err := s.inner.GetWebhookSubscription(ctx, id)
+	s.observe("GetWebhookSubscription", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	start := time.Now()
+	result, err := s.inner.GetWebhookSubscriptions(ctx)
+	s.observe("GetWebhookSubscriptions", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	start := time.Now()
+	err := s.inner.DeleteWebhookSubscription(ctx, id)
+	s.observe("DeleteWebhookSubscription", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	start := time.Now()
+	err := s.inner.CreateWebhookDelivery(ctx, delivery)
+	s.observe("CreateWebhookDelivery", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	start := time.Now()
+	err := s.inner.UpdateWebhookDelivery(ctx, delivery)
+	s.observe("UpdateWebhookDelivery", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := s.inner.GetDueWebhookDeliveries(ctx, at, limit)
+	s.observe("GetDueWebhookDeliveries", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	start := time.Now()
+	result, err := s.inner.GetWebhookDeliveriesForSubscription(ctx, subscriptionID, limit)
+	s.observe("GetWebhookDeliveriesForSubscription", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	start := time.Now()
+	err := s.inner.CreateOutboxEvent(ctx, event)
+	s.observe("CreateOutboxEvent", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	start := time.Now()
+	result, err := s.inner.GetPendingOutboxEvents(ctx, limit)
+	s.observe("GetPendingOutboxEvents", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	start := time.Now()
+	err := s.inner.MarkOutboxEventPublished(ctx, id, at)
+	s.observe("MarkOutboxEventPublished", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	start := time.Now()
+	err := s.inner.CreateProduct(ctx, product)
+	s.observe("CreateProduct", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	start := time.Now()
+	result, err := s.inner.GetProduct(ctx, code)
+	s.observe("GetProduct", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	start := time.Now()
+	err := s.inner.UpdateProduct(ctx, product)
+	s.observe("UpdateProduct", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	start := time.Now()
+	result, err := s.inner.GetAllProducts(ctx)
+	s.observe("GetAllProducts", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	start := time.Now()
+	err := s.inner.CreateCustomer(ctx, customer)
+	s.observe("CreateCustomer", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	start := time.Now()
+	result, err := s.inner.GetCustomer(ctx, customerKey)
+	s.observe("GetCustomer", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	start := time.Now()
+	err := s.inner.UpdateCustomer(ctx, customer)
+	s.observe("UpdateCustomer", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	start := time.Now()
+	err := s.inner.DeleteCustomer(ctx, customerKey)
+	s.observe("DeleteCustomer", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	start := time.Now()
+	result, err := s.inner.GetAllCustomers(ctx)
+	s.observe("GetAllCustomers", start, err)
+	return result, err
+}
+
+func (s *InstrumentedStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	start := time.Now()
+	err := s.inner.CreateJobRun(ctx, run)
+	s.observe("CreateJobRun", start, err)
+	return err
+}
+
+func (s *InstrumentedStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	start := time.Now()
+	result, err := s.inner.GetJobRuns(ctx, job, limit)
+	s.observe("GetJobRuns", start, err)
+	return result, err
+}
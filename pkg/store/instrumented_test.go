@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"github.com/mcclellann/fredLoan/pkg/metrics"
+)
+
+func TestInstrumentedStore(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_instrumented_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	instrumented := NewInstrumentedStore(s, 0)
+	defer instrumented.Close()
+
+	loan := newFaultTestLoan()
+	err = instrumented.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		return tx.CreateLoan(ctx, loan)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instrumented.GetLoan(ctx, loan.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := instrumented.GetLoan(ctx, uuid.New()); err == nil {
+		t.Fatal("Expected an error for an unknown loan")
+	}
+
+	registry := metrics.NewRegistry()
+	registry.Register(instrumented.Collectors()...)
+	samples := map[string]float64{}
+	for _, family := range registry.Gather() {
+		for _, sample := range family.Samples {
+			key := family.Name + sample.Suffix
+			for _, label := range sample.Labels {
+				if label.Name != "le" {
+					key += " " + label.Value
+				}
+			}
+			if sample.Suffix != "_bucket" {
+				samples[key] = sample.Value
+			}
+		}
+	}
+	expected := map[string]float64{
+		"store_call_duration_seconds_count GetLoan":       2,
+		"store_call_duration_seconds_count CreateLoan":    1, // Called inside the transaction
+		"store_call_duration_seconds_count InTransaction": 1,
+		"store_call_errors_total GetLoan error":           1,
+	}
+	for key, value := range expected {
+		if samples[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, samples[key])
+		}
+	}
+	if _, ok := samples["store_call_errors_total CreateLoan error"]; ok {
+		t.Error("Expected no errors counted for a call that succeeded")
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{fmt.Errorf("failed to update loan: %w", sqlite3.Error{Code: sqlite3.ErrBusy}), "busy"},
+		{sqlite3.Error{Code: sqlite3.ErrLocked}, "busy"},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, "error"},
+		{fmt.Errorf("failed to get loan: %w", context.DeadlineExceeded), "canceled"},
+		{fmt.Errorf("loan not found"), "error"},
+	}
+	for _, tt := range tests {
+		if got := errorKind(tt.err); got != tt.expected {
+			t.Errorf("errorKind(%v): expected %s, got %s", tt.err, tt.expected, got)
+		}
+	}
+}
@@ -1,21 +1,359 @@
 package store
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/mcclellann/fredLoan/pkg/models"
 )
 
+// Reader is the read side of Storage: the queries, which change nothing. A read replica can
+// serve it.
+type Reader interface {
+	GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error)
+	GetAllLoans(ctx context.Context) ([]*models.Loan, error)
+	// ListLoans retrieves a page of the loans matching the query, in the query's sort order,
+	// along with the number of matching loans across all pages. Voided loans are left out
+	// unless the query asks for them by status.
+	ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error)
+	// ListLoansAfter retrieves up to limit loans matching the query's filters, oldest first,
+	// starting after the cursor, or with the oldest loan when the cursor is nil. The query's
+	// sort, limit and offset are ignored. It returns the cursor for the next page, which is
+	// nil after the last page. Unlike ListLoans it does not count the matching loans.
+	ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error)
+	// SearchLoans retrieves a page of the loans matching the search, in its sort order, along
+	// with the number of matching loans across all pages. Voided loans are left out unless the
+	// search asks for them by status.
+	SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error)
+	GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error)
+	// ForEachActiveLoan calls fn with each active or delinquent loan in ID order, reading
+	// them a page at a time so a large portfolio is never held in memory at once. fn may call
+	// the store. Iteration stops at fn's first error, which it returns.
+	ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error
+	GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error)
+	// GetLoansByCustomerKey retrieves a customer's loans, oldest first.
+	GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error)
+	// GetCustomerSummary totals a customer's loans and lists the statement cycle day of each
+	// open loan. Statement dates are left for the caller to compute.
+	GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error)
+	GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error)
+
+	GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error)
+	// QueryTransactions retrieves a page of a loan's transactions matching the query, oldest
+	// first, along with the number of matching transactions across all pages.
+	QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error)
+
+	GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error)
+
+	GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error)
+	// GetRateInEffect returns the latest rate change effective on or before the given date,
+	// or nil if the loan has no rate change in effect by then.
+	GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error)
+
+	// GetLatestIndexRate returns the most recent observation for an index, or nil if none
+	// has been published.
+	GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error)
+	GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error)
+
+	// GetPortfolioSnapshots retrieves snapshots dated from through to (inclusive) in date order.
+	GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error)
+
+	// GetInterestIntent returns the loan's intent for a statement cycle, or nil if none was recorded.
+	GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error)
+	GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error)
+	GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error)
+
+	// GetIdempotencyRecord returns the record for a key, or nil if the key is unused.
+	GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error)
+
+	// GetStatement retrieves a loan's statement for a cycle, or nil if none was issued.
+	GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error)
+	// GetStatementsForLoan retrieves a loan's statements, oldest first.
+	GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error)
+
+	// GetAutopayEnrollment retrieves a loan's autopay enrollment, or nil if it is not enrolled.
+	GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error)
+	// GetAutopayEnrollmentsForDay retrieves the enrollments scheduled for a day of the month.
+	GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error)
+
+	GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error)
+	// GetCollateralForLoan retrieves the collateral securing a loan, oldest first.
+	GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error)
+
+	// GetForbearancesForLoan retrieves a loan's forbearance windows in start date order.
+	GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error)
+
+	// GetAccrualsForLoan retrieves a loan's accruals dated from through to (inclusive) in date order.
+	GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error)
+
+	// GetLedgerEvents retrieves a loan's event log in sequence order.
+	GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error)
+
+	// GetBureauRecordsForLoan retrieves a loan's bureau records, most recent period first.
+	GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error)
+
+	GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error)
+	GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error)
+	GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error)
+	GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error)
+	// GetArchivedLoansToExport retrieves up to limit archived loans last updated before the
+	// cutoff that have not been exported, least recently updated first.
+	GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error)
+
+	GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error)
+	GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error)
+
+	GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error)
+
+	GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error)
+	GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error)
+	// GetDueWebhookDeliveries retrieves up to limit pending deliveries whose next attempt is
+	// due at the given time, oldest first.
+	GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error)
+	// GetWebhookDeliveriesForSubscription retrieves a subscription's deliveries, newest first.
+	GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error)
+
+	// GetPendingOutboxEvents retrieves up to limit unpublished events, oldest first.
+	GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error)
+
+	GetProduct(ctx context.Context, code string) (*models.Product, error)
+	GetAllProducts(ctx context.Context) ([]*models.Product, error)
+
+	GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error)
+	// GetAllCustomers retrieves every customer ordered by customer key.
+	GetAllCustomers(ctx context.Context) ([]*models.Customer, error)
+
+	// GetJobRuns retrieves up to limit runs of a job, or of every job when job is empty, most
+	// recent first.
+	GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error)
+}
+
+// Writer is the write side of Storage: the operations that change the stored data.
+type Writer interface {
+	// CreateLoan stores a new loan. It and the loan updates register the loan's customer key
+	// as an active customer if no customer has it yet.
+	CreateLoan(ctx context.Context, loan *models.Loan) error
+	UpdateLoan(ctx context.Context, loan *models.Loan) error
+	// UpdateLoanIfVersion updates a loan only if its stored version equals version, for
+	// optimistic concurrency. It returns models.ErrLoanVersionMismatch when the loan has changed.
+	UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error
+	// UpdateLoans updates many loans as UpdateLoan does, together: if any loan is missing it
+	// fails with models.ErrLoanNotFound and none are updated. A loan whose stored version no
+	// longer equals its Version is left as it is, and the IDs of such loans are returned.
+	UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error)
+
+	CreateTransaction(ctx context.Context, transaction *models.Transaction) error
+
+	CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error
+
+	CreateRateChange(ctx context.Context, change *models.RateChange) error
+
+	CreateIndexRate(ctx context.Context, rate *models.IndexRate) error
+
+	// SavePortfolioSnapshot stores the snapshot for its date, replacing any earlier snapshot
+	// taken the same day.
+	SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error
+
+	CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error
+	UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error
+
+	// CreateIdempotencyRecord claims a key, failing with models.ErrIdempotencyKeyExists if
+	// it was claimed before.
+	CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error
+	UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+
+	// CreateStatement stores a statement; a loan has at most one statement per cycle.
+	CreateStatement(ctx context.Context, statement *models.Statement) error
+
+	// SaveAutopayEnrollment creates or replaces a loan's autopay enrollment.
+	SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error
+	// DeleteAutopayEnrollment removes a loan's autopay enrollment.
+	DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error
+
+	CreateCollateral(ctx context.Context, collateral *models.Collateral) error
+	UpdateCollateral(ctx context.Context, collateral *models.Collateral) error
+	DeleteCollateral(ctx context.Context, id uuid.UUID) error
+
+	CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error
+
+	// SaveAccrual stores a loan's accrual for its date, replacing any earlier accrual for the
+	// same loan and date.
+	SaveAccrual(ctx context.Context, accrual *models.Accrual) error
+
+	// AppendLedgerEvent adds an event to the end of its loan's event log, setting its
+	// sequence number. The log is append-only: events are never changed or removed, even
+	// when their loan is archived.
+	AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error
+
+	// SaveBureauRecord stores a loan's record for a reporting period, replacing any earlier
+	// record for the same loan and period.
+	SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error
+
+	// ArchiveClosedLoans moves closed loans last updated before the cutoff, along with
+	// their transactions, into cold storage and returns the number of loans moved.
+	ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error)
+	// MarkArchivedLoansExported records that archived loans were exported to object storage,
+	// so that GetArchivedLoansToExport passes over them.
+	MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error
+	// DeleteArchivedLoans removes archived loans along with their archived transactions,
+	// events and rate history.
+	DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error
+
+	CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error
+	UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error
+
+	CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error
+	UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error
+	// RedeemPaymentLink records an active link's redemption, failing with
+	// models.ErrPaymentLinkRedeemed if the link is no longer active.
+	RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error
+
+	CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	// DeleteWebhookSubscription removes a subscription along with its deliveries.
+	DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error
+	CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+
+	// CreateOutboxEvent writes an event to the outbox. It belongs in the transaction that
+	// stores the change the event reports.
+	CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error
+	// MarkOutboxEventPublished records that an event was published at the given time. It
+	// returns ErrOutboxEventPublished if the event is not pending, so two relays never both
+	// publish it.
+	MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error
+
+	CreateProduct(ctx context.Context, product *models.Product) error
+	UpdateProduct(ctx context.Context, product *models.Product) error
+
+	// CreateCustomer stores a new customer, failing with models.ErrCustomerExists if another
+	// customer has the same customer key.
+	CreateCustomer(ctx context.Context, customer *models.Customer) error
+	UpdateCustomer(ctx context.Context, customer *models.Customer) error
+	// DeleteCustomer deletes a customer, failing with models.ErrCustomerHasLoans while any
+	// loan, archived or not, refers to them.
+	DeleteCustomer(ctx context.Context, customerKey string) error
+
+	// CreateJobRun records a finished run of a batch job.
+	CreateJobRun(ctx context.Context, run *models.JobRun) error
+}
+
 // Storage defines the interface for database operations related to loans and transactions.
 type Storage interface {
-	CreateLoan(loan *models.Loan) error
-	GetLoan(id uuid.UUID) (*models.Loan, error)
-	UpdateLoan(loan *models.Loan) error
-	DeleteLoan(id uuid.UUID) error
-	GetAllLoans() ([]*models.Loan, error)
-	GetAllActiveLoans() ([]*models.Loan, error)
+	Reader
+	Writer
 
-	CreateTransaction(transaction *models.Transaction) error
-	GetTransactionsForLoan(loanID uuid.UUID) ([]*models.Transaction, error)
+	// InTransaction runs fn with a store whose calls make up one unit of work: their changes
+	// are kept together if fn returns nil and discarded together if it returns an error. fn
+	// must make its calls through the store it is given; calls on this store while it runs
+	// are not part of the unit, and may wait for it to finish.
+	InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error
 
 	Close() error
 }
+
+// Database drivers accepted by Open.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverMemory   = "memory"
+	DriverBolt     = "bolt"
+)
+
+// Open opens the store for a database driver and data source, migrating its schema to the
+// latest version.
+func Open(driver string, dataSourceName string) (Storage, error) {
+	var s Storage
+	var err error
+	switch driver {
+	case DriverSQLite, "":
+		s, err = NewSQLiteStore(dataSourceName)
+	case DriverPostgres:
+		s, err = NewPostgresStore(dataSourceName)
+	case DriverMySQL:
+		s, err = NewMySQLStore(dataSourceName)
+	case DriverMemory:
+		s = NewMemoryStore() // Nothing to connect to; the data source is ignored
+	case DriverBolt:
+		s, err = NewBoltStore(dataSourceName)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q: expected %s, %s, %s, %s or %s", driver, DriverSQLite, DriverPostgres, DriverMySQL, DriverMemory, DriverBolt)
+	}
+	if err != nil {
+		return nil, err // Not the typed nil store
+	}
+	return s, nil
+}
+
+// OpenMigrator connects to a database as Open does but leaves its schema as it is, for
+// inspecting and moving between schema versions. The memory and bolt drivers have no schema.
+func OpenMigrator(driver string, dataSourceName string) (Migrator, error) {
+	var m Migrator
+	var err error
+	switch driver {
+	case DriverSQLite, "":
+		m, err = openSQLite(dataSourceName)
+	case DriverPostgres:
+		m, err = openPostgres(dataSourceName)
+	case DriverMySQL:
+		m, err = openMySQL(dataSourceName)
+	default:
+		return nil, fmt.Errorf("database driver %q has no schema migrations", driver)
+	}
+	if err != nil {
+		return nil, err // Not the typed nil store
+	}
+	return m, nil
+}
+
+// ReplicaReader is a Reader connected to a read replica.
+type ReplicaReader interface {
+	Reader
+	Close() error
+}
+
+// OpenReplica connects to a read replica of a PostgreSQL or MySQL database, to serve reads
+// that can lag the primary. Its schema is left as it is, since it follows the primary's.
+func OpenReplica(driver string, dataSourceName string) (ReplicaReader, error) {
+	var r ReplicaReader
+	var err error
+	switch driver {
+	case DriverPostgres:
+		r, err = openPostgres(dataSourceName)
+	case DriverMySQL:
+		r, err = openMySQL(dataSourceName)
+	default:
+		return nil, fmt.Errorf("database driver %q has no read replicas", driver)
+	}
+	if err != nil {
+		return nil, err // Not the typed nil store
+	}
+	return r, nil
+}
+
+// RedactDataSource returns a driver's data source with its password or encryption key, if
+// any, masked, for printing.
+func RedactDataSource(driver string, dataSourceName string) string {
+	switch driver {
+	case DriverSQLite, "":
+		if dsn, params := splitSQLiteParams(dataSourceName); params.Has(sqliteKeyParam) {
+			params.Set(sqliteKeyParam, "xxxxx")
+			return dsn + "?" + params.Encode()
+		}
+	case DriverPostgres:
+		if u, err := url.Parse(dataSourceName); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	case DriverMySQL:
+		if cfg, err := mysql.ParseDSN(dataSourceName); err == nil && cfg.Passwd != "" {
+			cfg.Passwd = "xxxxx"
+			return cfg.FormatDSN()
+		}
+	}
+	return dataSourceName
+}
@@ -0,0 +1,898 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+var _ Storage = (*MemoryStore)(nil)
+
+// MemoryStore keeps the ledger in memory, for demos, tests and ephemeral environments, and as
+// a reference for what each Storage method does without SQL. Everything is lost when the
+// process exits.
+//
+// Records are copied on the way in and out, so callers never share the store's own; one
+// lock serializes writers; and every list comes back in the order the SQL stores give, with
+// the ID breaking ties where their ORDER BY leaves rows unordered.
+type MemoryStore struct {
+	mu sync.RWMutex
+	memoryData
+}
+
+// memoryData is what a MemoryStore holds. Stored records are never changed in place, only
+// replaced, so a copy of the maps and slices is a snapshot of the store.
+type memoryData struct {
+	loans                map[uuid.UUID]*models.Loan
+	customers            map[string]*models.Customer
+	transactions         []*models.Transaction
+	events               []*models.LoanEvent
+	rateChanges          []*models.RateChange
+	archivedLoans        map[uuid.UUID]*models.Loan
+	archivedTransactions []*models.Transaction
+	archivedEvents       []*models.LoanEvent
+	archivedRateChanges  []*models.RateChange
+	archivedRecords      map[uuid.UUID]*archivedRecords
+	archiveExports       map[uuid.UUID]*time.Time // When each exported archived loan was exported
+	indexRates           []*models.IndexRate
+	snapshots            map[time.Time]*models.PortfolioSnapshot
+	intents              map[uuid.UUID]*models.InterestIntent
+	idempotency          map[string]*models.IdempotencyRecord
+	statements           map[uuid.UUID]*models.Statement
+	autopay              map[uuid.UUID]*models.AutopayEnrollment
+	collateral           map[uuid.UUID]*models.Collateral
+	forbearances         []*models.Forbearance
+	accruals             map[accrualKey]*models.Accrual
+	ledgerEvents         []*models.LedgerEvent
+	bureauRecords        map[bureauRecordKey]*models.BureauRecord
+	paymentMethods       map[uuid.UUID]*models.PaymentMethod
+	paymentLinks         map[uuid.UUID]*models.PaymentLink
+	webhooks             map[uuid.UUID]*models.WebhookSubscription
+	webhookDeliveries    []*models.WebhookDelivery
+	outbox               []*models.OutboxEvent
+	products             map[string]*models.Product
+	jobRuns              []*models.JobRun
+}
+
+// accrualKey identifies an accrual, as the accruals table's primary key does.
+type accrualKey struct {
+	loanID uuid.UUID
+	date   time.Time
+}
+
+// bureauRecordKey identifies a bureau record, as the bureau_records table's primary key does.
+type bureauRecordKey struct {
+	loanID uuid.UUID
+	period string
+}
+
+// archivedRecords holds an archived loan's other records, which nothing reads from the
+// archive but which leave the hot collections with the loan, as they do in the SQL stores.
+type archivedRecords struct {
+	Statements      []*models.Statement
+	Accruals        []*models.Accrual
+	Collateral      []*models.Collateral
+	Forbearances    []*models.Forbearance
+	Autopay         *models.AutopayEnrollment
+	PaymentLinks    []*models.PaymentLink
+	BureauRecords   []*models.BureauRecord
+	InterestIntents []*models.InterestIntent
+	LedgerEvents    []*models.LedgerEvent
+}
+
+// add adds one of the loan's records.
+func (r *archivedRecords) add(record any) {
+	switch record := record.(type) {
+	case *models.Statement:
+		r.Statements = append(r.Statements, record)
+	case *models.Accrual:
+		r.Accruals = append(r.Accruals, record)
+	case *models.Collateral:
+		r.Collateral = append(r.Collateral, record)
+	case *models.Forbearance:
+		r.Forbearances = append(r.Forbearances, record)
+	case *models.AutopayEnrollment:
+		r.Autopay = record
+	case *models.PaymentLink:
+		r.PaymentLinks = append(r.PaymentLinks, record)
+	case *models.BureauRecord:
+		r.BureauRecords = append(r.BureauRecords, record)
+	case *models.InterestIntent:
+		r.InterestIntents = append(r.InterestIntents, record)
+	case *models.LedgerEvent:
+		r.LedgerEvents = append(r.LedgerEvents, record)
+	}
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{memoryData: memoryData{
+		loans:           make(map[uuid.UUID]*models.Loan),
+		customers:       make(map[string]*models.Customer),
+		archivedLoans:   make(map[uuid.UUID]*models.Loan),
+		archivedRecords: make(map[uuid.UUID]*archivedRecords),
+		archiveExports:  make(map[uuid.UUID]*time.Time),
+		snapshots:       make(map[time.Time]*models.PortfolioSnapshot),
+		intents:         make(map[uuid.UUID]*models.InterestIntent),
+		idempotency:     make(map[string]*models.IdempotencyRecord),
+		statements:      make(map[uuid.UUID]*models.Statement),
+		autopay:         make(map[uuid.UUID]*models.AutopayEnrollment),
+		collateral:      make(map[uuid.UUID]*models.Collateral),
+		accruals:        make(map[accrualKey]*models.Accrual),
+		bureauRecords:   make(map[bureauRecordKey]*models.BureauRecord),
+		paymentMethods:  make(map[uuid.UUID]*models.PaymentMethod),
+		paymentLinks:    make(map[uuid.UUID]*models.PaymentLink),
+		webhooks:        make(map[uuid.UUID]*models.WebhookSubscription),
+		products:        make(map[string]*models.Product),
+	}}
+}
+
+// Close does nothing; the store's contents go with the process.
+func (m *MemoryStore) Close() error {
+	return nil
+}
+
+// InTransaction runs fn on a store holding a copy of this one's contents, which replace them
+// if fn succeeds. Every other call waits until it is done, as writers do on SQLite.
+func (m *MemoryStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := &MemoryStore{memoryData: m.memoryData.snapshot()}
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	m.memoryData = tx.memoryData
+	return nil
+}
+
+// snapshot copies the maps and slices; the records in them are shared, never being changed.
+func (d *memoryData) snapshot() memoryData {
+	return memoryData{
+		loans:                maps.Clone(d.loans),
+		customers:            maps.Clone(d.customers),
+		transactions:         slices.Clone(d.transactions),
+		events:               slices.Clone(d.events),
+		rateChanges:          slices.Clone(d.rateChanges),
+		archivedLoans:        maps.Clone(d.archivedLoans),
+		archivedTransactions: slices.Clone(d.archivedTransactions),
+		archivedEvents:       slices.Clone(d.archivedEvents),
+		archivedRateChanges:  slices.Clone(d.archivedRateChanges),
+		archivedRecords:      maps.Clone(d.archivedRecords),
+		archiveExports:       maps.Clone(d.archiveExports),
+		indexRates:           slices.Clone(d.indexRates),
+		snapshots:            maps.Clone(d.snapshots),
+		intents:              maps.Clone(d.intents),
+		idempotency:          maps.Clone(d.idempotency),
+		statements:           maps.Clone(d.statements),
+		autopay:              maps.Clone(d.autopay),
+		collateral:           maps.Clone(d.collateral),
+		forbearances:         slices.Clone(d.forbearances),
+		accruals:             maps.Clone(d.accruals),
+		ledgerEvents:         slices.Clone(d.ledgerEvents),
+		bureauRecords:        maps.Clone(d.bureauRecords),
+		paymentMethods:       maps.Clone(d.paymentMethods),
+		paymentLinks:         maps.Clone(d.paymentLinks),
+		webhooks:             maps.Clone(d.webhooks),
+		webhookDeliveries:    slices.Clone(d.webhookDeliveries),
+		outbox:               slices.Clone(d.outbox),
+		products:             maps.Clone(d.products),
+		jobRuns:              slices.Clone(d.jobRuns),
+	}
+}
+
+// clone returns a copy of a record. Records whose fields point at or share anything have a
+// copy function of their own built on it.
+func clone[T any](record *T) *T {
+	if record == nil {
+		return nil
+	}
+	copied := *record
+	return &copied
+}
+
+// cloneAll copies each record with copyRecord into a new, non-nil slice.
+func cloneAll[T any](records []*T, copyRecord func(*T) *T) []*T {
+	copies := make([]*T, 0, len(records))
+	for _, record := range records {
+		copies = append(copies, copyRecord(record))
+	}
+	return copies
+}
+
+// mapValues returns the values of a map in no particular order, for sorting.
+func mapValues[K comparable, V any](m map[K]V) []V {
+	values := make([]V, 0, len(m))
+	for _, v := range m {
+		values = append(values, v)
+	}
+	return values
+}
+
+// copyLoan copies a loan, leaving out the fields computed when a loan is retrieved, which
+// the databases do not store either.
+func copyLoan(loan *models.Loan) *models.Loan {
+	copied := clone(loan)
+	copied.LastInterestCalculationDate = clone(loan.LastInterestCalculationDate)
+	copied.LastPaymentDate = clone(loan.LastPaymentDate)
+	copied.ChargedOffAt = clone(loan.ChargedOffAt)
+	copied.RefinancedFrom = clone(loan.RefinancedFrom)
+	copied.PromoStartDate = clone(loan.PromoStartDate)
+	copied.PromoEndDate = clone(loan.PromoEndDate)
+	copied.AccrualStartDate = clone(loan.AccrualStartDate)
+	copied.DeletedAt = clone(loan.DeletedAt)
+	copied.AvailableCredit = nil
+	copied.LoanToValue = nil
+	copied.APR = nil
+	copied.APY = nil
+	return copied
+}
+
+func copyTransaction(transaction *models.Transaction) *models.Transaction {
+	copied := clone(transaction)
+	copied.PaymentMethodID = clone(transaction.PaymentMethodID)
+	copied.Metadata = maps.Clone(transaction.Metadata)
+	return copied
+}
+
+// sortLoans orders loans oldest first, by ID within the same creation time.
+func sortLoans(loans []*models.Loan) {
+	sort.Slice(loans, func(i, j int) bool {
+		if !loans[i].CreatedAt.Equal(loans[j].CreatedAt) {
+			return loans[i].CreatedAt.Before(loans[j].CreatedAt)
+		}
+		return loans[i].ID.String() < loans[j].ID.String()
+	})
+}
+
+// filterLoans returns copies of the loans that match, oldest first.
+func (m *MemoryStore) filterLoans(match func(*models.Loan) bool) []*models.Loan {
+	loans := []*models.Loan{}
+	for _, loan := range m.loans {
+		if match(loan) {
+			loans = append(loans, copyLoan(loan))
+		}
+	}
+	sortLoans(loans)
+	return loans
+}
+
+// registerCustomer adds an active customer with the given key unless one exists, as the SQL
+// stores do when a loan is saved.
+func (m *MemoryStore) registerCustomer(customerKey string, at time.Time) {
+	if _, ok := m.customers[customerKey]; !ok {
+		m.customers[customerKey] = &models.Customer{ID: uuid.New(), CustomerKey: customerKey, Status: models.CustomerStatusActive, CreatedAt: at, UpdatedAt: at}
+	}
+}
+
+// CreateLoan stores a new loan, registering its customer key as a customer if it is new.
+func (m *MemoryStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.loans[loan.ID]; ok {
+		return fmt.Errorf("failed to create loan: loan %s already exists", loan.ID)
+	}
+	m.registerCustomer(loan.CustomerKey, loan.CreatedAt)
+	m.loans[loan.ID] = copyLoan(loan)
+	return nil
+}
+
+// GetLoan retrieves a loan by its ID.
+func (m *MemoryStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loan, ok := m.loans[id]
+	if !ok {
+		return nil, models.ErrLoanNotFound
+	}
+	return copyLoan(loan), nil
+}
+
+// UpdateLoan stores a loan's fields and increments its version, setting loan.Version to the
+// new value.
+func (m *MemoryStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	return m.updateLoan(loan, 0)
+}
+
+// UpdateLoanIfVersion updates a loan only if its stored version is still version, returning
+// models.ErrLoanVersionMismatch if another update got there first.
+func (m *MemoryStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	if version <= 0 {
+		return models.ErrLoanVersionMismatch
+	}
+	return m.updateLoan(loan, version)
+}
+
+// updateLoan updates a loan, requiring the stored version to equal version unless it is zero.
+// The creation time is kept, as the SQL stores' UPDATE does not set it.
+func (m *MemoryStore) updateLoan(loan *models.Loan, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.loans[loan.ID]
+	if !ok {
+		return models.ErrLoanNotFound
+	}
+	if version > 0 && existing.Version != version {
+		return models.ErrLoanVersionMismatch
+	}
+	m.storeLoan(loan, existing)
+	return nil
+}
+
+// UpdateLoans stores many loans at once, setting each loan's version to its new value. If
+// any loan is missing none are stored.
+func (m *MemoryStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, loan := range loans {
+		if _, ok := m.loans[loan.ID]; !ok {
+			return nil, models.ErrLoanNotFound
+		}
+	}
+	read := make(map[uuid.UUID]int, len(loans))
+	for _, loan := range loans {
+		if _, ok := read[loan.ID]; !ok {
+			read[loan.ID] = m.loans[loan.ID].Version
+		}
+	}
+	var stale []uuid.UUID
+	for _, loan := range loans {
+		if loan.Version != read[loan.ID] {
+			if !slices.Contains(stale, loan.ID) {
+				stale = append(stale, loan.ID)
+			}
+			continue
+		}
+		m.storeLoan(loan, m.loans[loan.ID])
+	}
+	return stale, nil
+}
+
+// storeLoan replaces the existing loan with a copy of loan at the next version. The caller
+// holds the lock.
+func (m *MemoryStore) storeLoan(loan *models.Loan, existing *models.Loan) {
+	m.registerCustomer(loan.CustomerKey, loan.UpdatedAt)
+	updated := copyLoan(loan)
+	updated.CreatedAt = existing.CreatedAt
+	updated.Version = existing.Version + 1
+	m.loans[loan.ID] = updated
+	loan.Version = updated.Version
+}
+
+// GetAllLoans retrieves all loans.
+func (m *MemoryStore) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.filterLoans(func(*models.Loan) bool { return true }), nil
+}
+
+// ListLoans retrieves a page of the loans matching the query, in the query's sort order,
+// along with the number of matching loans across all pages. Voided loans are left out unless
+// the query asks for them by status.
+func (m *MemoryStore) ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loans := m.filterLoans(matchLoanQuery(query))
+	return pageLoans(loans, query.Sort, query.Limit, query.Offset)
+}
+
+// ListLoansAfter retrieves up to limit loans matching the query's filters, oldest first,
+// starting after the cursor.
+func (m *MemoryStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid limit %d: must be positive", limit)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	match := matchLoanQuery(query)
+	loans := m.filterLoans(func(loan *models.Loan) bool {
+		return match(loan) && (cursor == nil || loan.CreatedAt.After(cursor.CreatedAt) ||
+			loan.CreatedAt.Equal(cursor.CreatedAt) && loan.ID.String() > cursor.ID.String())
+	})
+	loans, next := loanPageAfter(loans[:min(len(loans), limit+1)], limit)
+	return loans, next, nil
+}
+
+// matchLoanQuery reports whether a loan passes a loan query's filters.
+func matchLoanQuery(query models.LoanQuery) func(*models.Loan) bool {
+	return func(loan *models.Loan) bool {
+		return (query.Status == "" && loan.Status != models.LoanStatusVoided || loan.Status == query.Status) &&
+			(query.CustomerKey == "" || loan.CustomerKey == query.CustomerKey) &&
+			(query.MinBalance.IsZero() || !loan.Balance.LessThan(query.MinBalance)) &&
+			(query.CreatedAfter == nil || !loan.CreatedAt.Before(*query.CreatedAfter))
+	}
+}
+
+// SearchLoans retrieves a page of the loans matching the search, in its sort order, along
+// with the number of matching loans across all pages.
+func (m *MemoryStore) SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error) {
+	inRange := func(value decimal.Decimal, lo, hi *decimal.Decimal) bool {
+		return (lo == nil || !value.LessThan(*lo)) && (hi == nil || !value.GreaterThan(*hi))
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loans := m.filterLoans(func(loan *models.Loan) bool {
+		return (len(search.Statuses) == 0 && loan.Status != models.LoanStatusVoided || slices.Contains(search.Statuses, loan.Status)) &&
+			strings.HasPrefix(loan.CustomerKey, search.CustomerKeyPrefix) &&
+			inRange(loan.Balance, search.MinBalance, search.MaxBalance) &&
+			inRange(loan.InterestRate, search.MinRate, search.MaxRate) &&
+			(search.CreatedFrom == nil || !loan.CreatedAt.Before(*search.CreatedFrom)) &&
+			(search.CreatedTo == nil || !loan.CreatedAt.After(*search.CreatedTo))
+	})
+	return pageLoans(loans, search.Sort, search.Limit, search.Offset)
+}
+
+// pageLoans sorts loans, which are oldest first already, as loanSortOrders does and returns
+// one page along with the total.
+func pageLoans(loans []*models.Loan, order models.LoanSort, limit int, offset int) ([]*models.Loan, int, error) {
+	switch order {
+	case "", models.LoanSortCreatedAt:
+	case models.LoanSortCreatedAtDesc:
+		slices.Reverse(loans)
+	case models.LoanSortBalance, models.LoanSortBalanceDesc:
+		sort.SliceStable(loans, func(i, j int) bool {
+			if !loans[i].Balance.Equal(loans[j].Balance) {
+				return loans[i].Balance.LessThan(loans[j].Balance)
+			}
+			return loans[i].ID.String() < loans[j].ID.String()
+		})
+		if order == models.LoanSortBalanceDesc {
+			slices.Reverse(loans)
+		}
+	default:
+		return nil, 0, fmt.Errorf("unknown loan sort %q", order)
+	}
+	total := len(loans)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	return loans[start:end], total, nil
+}
+
+// GetAllActiveLoans retrieves all open loans, whether active or delinquent.
+func (m *MemoryStore) GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.filterLoans(func(loan *models.Loan) bool { return loan.Status.IsOpen() }), nil
+}
+
+// ForEachActiveLoan calls fn with each active or delinquent loan in ID order. The loans are
+// copied out before fn sees any, so fn is free to use the store.
+func (m *MemoryStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	loans, _ := m.GetAllActiveLoans(ctx)
+	slices.SortFunc(loans, func(a, b *models.Loan) int { return strings.Compare(a.ID.String(), b.ID.String()) })
+	for _, loan := range loans {
+		if err := fn(loan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLoansByStatus retrieves all loans with the given status.
+func (m *MemoryStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.filterLoans(func(loan *models.Loan) bool { return loan.Status == status }), nil
+}
+
+// GetLoansByCustomerKey retrieves a customer's loans, oldest first.
+func (m *MemoryStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.filterLoans(func(loan *models.Loan) bool { return loan.CustomerKey == customerKey }), nil
+}
+
+// GetCustomerSummary totals a customer's loans and lists the statement cycle day of each
+// open loan. Statement dates are left for the caller to compute.
+func (m *MemoryStore) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loans := m.filterLoans(func(loan *models.Loan) bool {
+		return loan.CustomerKey == customerKey && loan.Status != models.LoanStatusVoided
+	})
+
+	summary := &models.CustomerSummary{CustomerKey: customerKey, LoanCount: len(loans), NextStatements: []models.CustomerNextStatement{}}
+	for _, loan := range loans {
+		if !loan.Status.IsOpen() {
+			continue
+		}
+		summary.OpenLoanCount++
+		summary.OutstandingBalance = summary.OutstandingBalance.Add(loan.Balance)
+		summary.AccruedInterest = summary.AccruedInterest.Add(loan.AccruedInterest)
+		summary.NextStatements = append(summary.NextStatements, models.CustomerNextStatement{LoanID: loan.ID, StatementCycleDay: loan.StatementCycleDay})
+	}
+	summary.OutstandingBalance = summary.OutstandingBalance.Round(2)
+	summary.AccruedInterest = summary.AccruedInterest.Round(2)
+	sort.Slice(summary.NextStatements, func(i, j int) bool {
+		a, b := summary.NextStatements[i], summary.NextStatements[j]
+		if a.StatementCycleDay != b.StatementCycleDay {
+			return a.StatementCycleDay < b.StatementCycleDay
+		}
+		return a.LoanID.String() < b.LoanID.String()
+	})
+	return summary, nil
+}
+
+// GetDelinquentLoans retrieves open loans that are at least minDaysPastDue days past due,
+// most delinquent first.
+func (m *MemoryStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loans := m.filterLoans(func(loan *models.Loan) bool {
+		return loan.Status.IsOpen() && loan.DaysPastDue > 0 && loan.DaysPastDue >= minDaysPastDue
+	})
+	sort.SliceStable(loans, func(i, j int) bool { return loans[i].DaysPastDue > loans[j].DaysPastDue })
+	return loans, nil
+}
+
+// CreateTransaction records a transaction.
+func (m *MemoryStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.transactions = append(m.transactions, copyTransaction(transaction))
+	return nil
+}
+
+// transactionsForLoan returns copies of a loan's transactions among transactions, oldest
+// first and in the order they were recorded within the same timestamp.
+func transactionsForLoan(transactions []*models.Transaction, loanID uuid.UUID) []*models.Transaction {
+	matched := []*models.Transaction{}
+	for _, transaction := range transactions {
+		if transaction.LoanID == loanID {
+			matched = append(matched, copyTransaction(transaction))
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+	return matched
+}
+
+// GetTransactionsForLoan retrieves a loan's transactions, oldest first.
+func (m *MemoryStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return transactionsForLoan(m.transactions, loanID), nil
+}
+
+// QueryTransactions retrieves a page of a loan's transactions matching the query, oldest
+// first, along with the number of matching transactions across all pages.
+func (m *MemoryStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	transactions := slices.DeleteFunc(transactionsForLoan(m.transactions, loanID), func(tx *models.Transaction) bool {
+		return !((len(query.Types) == 0 || slices.Contains(query.Types, tx.Type)) &&
+			(query.From == nil || !tx.Timestamp.Before(*query.From)) &&
+			(query.To == nil || !tx.Timestamp.After(*query.To)) &&
+			(query.MinAmount == nil || !tx.Amount.LessThan(*query.MinAmount)) &&
+			(query.MaxAmount == nil || !tx.Amount.GreaterThan(*query.MaxAmount)) &&
+			hasMetadata(tx, query.Metadata))
+	})
+	sort.Slice(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	total := len(transactions)
+	start := min(query.Offset, total)
+	end := total
+	if query.Limit > 0 {
+		end = min(start+query.Limit, total)
+	}
+	return transactions[start:end], total, nil
+}
+
+// hasMetadata reports whether the transaction's metadata has every one of the values.
+func hasMetadata(tx *models.Transaction, values map[string]string) bool {
+	for key, value := range values {
+		if v, ok := tx.Metadata[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateLoanEvent records an event in a loan's history.
+func (m *MemoryStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, clone(event))
+	return nil
+}
+
+// eventsForLoan returns copies of a loan's events among events, oldest first.
+func eventsForLoan(events []*models.LoanEvent, loanID uuid.UUID) []*models.LoanEvent {
+	matched := []*models.LoanEvent{}
+	for _, event := range events {
+		if event.LoanID == loanID {
+			matched = append(matched, clone(event))
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Timestamp.Before(matched[j].Timestamp) })
+	return matched
+}
+
+// GetLoanEventsForLoan retrieves a loan's events, oldest first.
+func (m *MemoryStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return eventsForLoan(m.events, loanID), nil
+}
+
+// CreateRateChange records a change to a loan's interest rate.
+func (m *MemoryStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rateChanges = append(m.rateChanges, clone(change))
+	return nil
+}
+
+// GetRateHistory retrieves a loan's rate changes in the order they took effect.
+func (m *MemoryStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return rateHistoryForLoan(m.rateChanges, loanID), nil
+}
+
+// rateHistoryForLoan returns copies of a loan's rate changes in the order they took effect.
+func rateHistoryForLoan(rateChanges []*models.RateChange, loanID uuid.UUID) []*models.RateChange {
+	changes := []*models.RateChange{}
+	for _, change := range rateChanges {
+		if change.LoanID == loanID {
+			changes = append(changes, clone(change))
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if !a.EffectiveDate.Equal(b.EffectiveDate) {
+			return a.EffectiveDate.Before(b.EffectiveDate)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return changes
+}
+
+// GetRateInEffect returns the latest rate change effective on or before the given date, or
+// nil if the loan has no rate change in effect by then.
+func (m *MemoryStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error) {
+	history, _ := m.GetRateHistory(ctx, loanID)
+	for i := len(history) - 1; i >= 0; i-- {
+		if !history[i].EffectiveDate.After(date) {
+			return history[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// ArchiveClosedLoans moves closed loans whose last update predates closedBefore, together
+// with all their records, into the archive. It returns the number of loans archived.
+func (m *MemoryStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	archived := map[uuid.UUID]bool{}
+	for id, loan := range m.loans {
+		if loan.Status == models.LoanStatusClosed && loan.UpdatedAt.Before(closedBefore) {
+			m.archivedLoans[id] = loan
+			delete(m.loans, id)
+			archived[id] = true
+		}
+	}
+	if len(archived) == 0 {
+		return 0, nil
+	}
+	m.transactions, m.archivedTransactions = archive(m.transactions, m.archivedTransactions, archived, func(tx *models.Transaction) uuid.UUID { return tx.LoanID })
+	m.events, m.archivedEvents = archive(m.events, m.archivedEvents, archived, func(event *models.LoanEvent) uuid.UUID { return event.LoanID })
+	m.rateChanges, m.archivedRateChanges = archive(m.rateChanges, m.archivedRateChanges, archived, func(change *models.RateChange) uuid.UUID { return change.LoanID })
+
+	records := map[uuid.UUID]*archivedRecords{}
+	for id := range archived {
+		records[id] = &archivedRecords{}
+	}
+	archiveRecords(m.statements, records, func(statement *models.Statement) uuid.UUID { return statement.LoanID })
+	archiveRecords(m.accruals, records, func(accrual *models.Accrual) uuid.UUID { return accrual.LoanID })
+	archiveRecords(m.collateral, records, func(item *models.Collateral) uuid.UUID { return item.LoanID })
+	archiveRecords(m.autopay, records, func(enrollment *models.AutopayEnrollment) uuid.UUID { return enrollment.LoanID })
+	archiveRecords(m.paymentLinks, records, func(link *models.PaymentLink) uuid.UUID { return link.LoanID })
+	archiveRecords(m.bureauRecords, records, func(record *models.BureauRecord) uuid.UUID { return record.LoanID })
+	archiveRecords(m.intents, records, func(intent *models.InterestIntent) uuid.UUID { return intent.LoanID })
+	m.forbearances = archiveRecordList(m.forbearances, records, func(forbearance *models.Forbearance) uuid.UUID { return forbearance.LoanID })
+	m.ledgerEvents = archiveRecordList(m.ledgerEvents, records, func(event *models.LedgerEvent) uuid.UUID { return event.LoanID })
+	maps.Copy(m.archivedRecords, records)
+	return len(archived), nil
+}
+
+// archive moves the records of archived loans from hot to cold, returning both.
+func archive[T any](hot []*T, cold []*T, archived map[uuid.UUID]bool, loanID func(*T) uuid.UUID) ([]*T, []*T) {
+	kept := hot[:0]
+	for _, record := range hot {
+		if archived[loanID(record)] {
+			cold = append(cold, record)
+		} else {
+			kept = append(kept, record)
+		}
+	}
+	clear(hot[len(kept):])
+	return kept, cold
+}
+
+// archiveRecords moves the records of the loans in archived from a hot map to their loans'
+// archived records.
+func archiveRecords[K comparable, V any](hot map[K]*V, archived map[uuid.UUID]*archivedRecords, loanID func(*V) uuid.UUID) {
+	for key, record := range hot {
+		if r := archived[loanID(record)]; r != nil {
+			r.add(record)
+			delete(hot, key)
+		}
+	}
+}
+
+// archiveRecordList moves the records of the loans in archived from a hot slice to their
+// loans' archived records, returning what is left of the slice.
+func archiveRecordList[V any](hot []*V, archived map[uuid.UUID]*archivedRecords, loanID func(*V) uuid.UUID) []*V {
+	return slices.DeleteFunc(hot, func(record *V) bool {
+		r := archived[loanID(record)]
+		if r != nil {
+			r.add(record)
+		}
+		return r != nil
+	})
+}
+
+// GetArchivedLoan retrieves an archived loan by its ID.
+func (m *MemoryStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loan, ok := m.archivedLoans[id]
+	if !ok {
+		return nil, models.ErrLoanNotFound
+	}
+	return copyLoan(loan), nil
+}
+
+// GetArchivedTransactionsForLoan retrieves an archived loan's transactions, oldest first.
+func (m *MemoryStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return transactionsForLoan(m.archivedTransactions, loanID), nil
+}
+
+// GetArchivedLoanEventsForLoan retrieves an archived loan's events, oldest first.
+func (m *MemoryStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return eventsForLoan(m.archivedEvents, loanID), nil
+}
+
+// GetArchivedRateHistory retrieves an archived loan's rate changes in the order they took
+// effect.
+func (m *MemoryStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return rateHistoryForLoan(m.archivedRateChanges, loanID), nil
+}
+
+// GetArchivedLoansToExport retrieves up to limit archived loans last updated before
+// closedBefore that have not been exported, least recently updated first.
+func (m *MemoryStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	loans := []*models.Loan{}
+	for id, loan := range m.archivedLoans {
+		if m.archiveExports[id] == nil && loan.UpdatedAt.Before(closedBefore) {
+			loans = append(loans, loan)
+		}
+	}
+	sort.Slice(loans, func(i, j int) bool {
+		a, b := loans[i], loans[j]
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	if len(loans) > limit {
+		loans = loans[:limit]
+	}
+	return cloneAll(loans, copyLoan), nil
+}
+
+// MarkArchivedLoansExported records that the archived loans were exported at exportedAt.
+func (m *MemoryStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := m.archivedLoans[id]; ok {
+			m.archiveExports[id] = &exportedAt
+		}
+	}
+	return nil
+}
+
+// DeleteArchivedLoans removes archived loans with all their records.
+func (m *MemoryStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := map[uuid.UUID]bool{}
+	for _, id := range ids {
+		delete(m.archivedLoans, id)
+		delete(m.archivedRecords, id)
+		delete(m.archiveExports, id)
+		deleted[id] = true
+	}
+	m.archivedTransactions = slices.DeleteFunc(m.archivedTransactions, func(tx *models.Transaction) bool { return deleted[tx.LoanID] })
+	m.archivedEvents = slices.DeleteFunc(m.archivedEvents, func(event *models.LoanEvent) bool { return deleted[event.LoanID] })
+	m.archivedRateChanges = slices.DeleteFunc(m.archivedRateChanges, func(change *models.RateChange) bool { return deleted[change.LoanID] })
+	return nil
+}
+
+// CreateCustomer stores a new customer, failing with models.ErrCustomerExists if the
+// customer key is taken.
+func (m *MemoryStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.customers[customer.CustomerKey]; ok {
+		return models.ErrCustomerExists
+	}
+	m.customers[customer.CustomerKey] = clone(customer)
+	return nil
+}
+
+// GetCustomer retrieves a customer by their customer key.
+func (m *MemoryStore) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	customer, ok := m.customers[customerKey]
+	if !ok {
+		return nil, models.ErrCustomerNotFound
+	}
+	return clone(customer), nil
+}
+
+// UpdateCustomer updates an existing customer's details and status.
+func (m *MemoryStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.customers[customer.CustomerKey]
+	if !ok {
+		return models.ErrCustomerNotFound
+	}
+	updated := clone(customer)
+	updated.ID = existing.ID
+	updated.CreatedAt = existing.CreatedAt
+	m.customers[customer.CustomerKey] = updated
+	return nil
+}
+
+// DeleteCustomer deletes a customer that no loan, archived or not, refers to.
+func (m *MemoryStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.customers[customerKey]; !ok {
+		return models.ErrCustomerNotFound
+	}
+	for _, loans := range []map[uuid.UUID]*models.Loan{m.loans, m.archivedLoans} {
+		for _, loan := range loans {
+			if loan.CustomerKey == customerKey {
+				return models.ErrCustomerHasLoans
+			}
+		}
+	}
+	delete(m.customers, customerKey)
+	return nil
+}
+
+// GetAllCustomers retrieves all customers ordered by customer key.
+func (m *MemoryStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	customers := cloneAll(mapValues(m.customers), clone[models.Customer])
+	sort.Slice(customers, func(i, j int) bool { return customers[i].CustomerKey < customers[j].CustomerKey })
+	return customers, nil
+}
@@ -0,0 +1,842 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// timeKey normalizes a timestamp for use as a map key, so that the same instant in another
+// location, or with a monotonic reading, finds the same record as the databases' columns do.
+func timeKey(t time.Time) time.Time {
+	return t.UTC().Round(0)
+}
+
+func copyIdempotencyRecord(record *models.IdempotencyRecord) *models.IdempotencyRecord {
+	copied := clone(record)
+	copied.Body = slices.Clone(record.Body)
+	copied.CompletedAt = clone(record.CompletedAt)
+	copied.AppliedAt = clone(record.AppliedAt)
+	return copied
+}
+
+func copyInterestIntent(intent *models.InterestIntent) *models.InterestIntent {
+	copied := clone(intent)
+	copied.CompletedAt = clone(intent.CompletedAt)
+	return copied
+}
+
+func copyPaymentMethod(method *models.PaymentMethod) *models.PaymentMethod {
+	copied := clone(method)
+	copied.ExpiresAt = clone(method.ExpiresAt)
+	return copied
+}
+
+func copyPaymentLink(link *models.PaymentLink) *models.PaymentLink {
+	copied := clone(link)
+	copied.TransactionID = clone(link.TransactionID)
+	copied.RedeemedAt = clone(link.RedeemedAt)
+	return copied
+}
+
+func copyWebhookSubscription(subscription *models.WebhookSubscription) *models.WebhookSubscription {
+	copied := clone(subscription)
+	copied.Events = slices.Clone(subscription.Events)
+	return copied
+}
+
+func copyWebhookDelivery(delivery *models.WebhookDelivery) *models.WebhookDelivery {
+	copied := clone(delivery)
+	copied.Payload = slices.Clone(delivery.Payload)
+	copied.DeliveredAt = clone(delivery.DeliveredAt)
+	return copied
+}
+
+func copyOutboxEvent(event *models.OutboxEvent) *models.OutboxEvent {
+	copied := clone(event)
+	copied.Payload = slices.Clone(event.Payload)
+	copied.PublishedAt = clone(event.PublishedAt)
+	return copied
+}
+
+func copyLedgerEvent(event *models.LedgerEvent) *models.LedgerEvent {
+	copied := clone(event)
+	copied.TransactionID = clone(event.TransactionID)
+	return copied
+}
+
+func copyJobRun(run *models.JobRun) *models.JobRun {
+	copied := clone(run)
+	copied.LoanID = clone(run.LoanID)
+	copied.Errors = slices.Clone(run.Errors)
+	return copied
+}
+
+// CreateIndexRate records an observation of a benchmark index.
+func (m *MemoryStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.indexRates = append(m.indexRates, clone(rate))
+	return nil
+}
+
+// GetLatestIndexRate returns the most recent observation for an index, or nil if none has
+// been published.
+func (m *MemoryStore) GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error) {
+	rates, _ := m.GetIndexRates(ctx, indexCode)
+	if len(rates) == 0 {
+		return nil, nil
+	}
+	return rates[len(rates)-1], nil
+}
+
+// GetIndexRates retrieves an index's observations in date order.
+func (m *MemoryStore) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rates := []*models.IndexRate{}
+	for _, rate := range m.indexRates {
+		if rate.IndexCode == indexCode {
+			rates = append(rates, clone(rate))
+		}
+	}
+	sort.SliceStable(rates, func(i, j int) bool {
+		a, b := rates[i], rates[j]
+		if !a.ObservationDate.Equal(b.ObservationDate) {
+			return a.ObservationDate.Before(b.ObservationDate)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	return rates, nil
+}
+
+// SavePortfolioSnapshot stores the snapshot for its date, replacing any earlier snapshot
+// taken the same day.
+func (m *MemoryStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[timeKey(snapshot.Date)] = clone(snapshot)
+	return nil
+}
+
+// GetPortfolioSnapshots retrieves snapshots dated from through to (inclusive) in date order.
+func (m *MemoryStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshots := []*models.PortfolioSnapshot{}
+	for _, snapshot := range m.snapshots {
+		if !snapshot.Date.Before(from) && !snapshot.Date.After(to) {
+			snapshots = append(snapshots, clone(snapshot))
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Date.Before(snapshots[j].Date) })
+	return snapshots, nil
+}
+
+// CreateInterestIntent records an intent to post interest to a loan for a statement cycle. A
+// loan has at most one intent per cycle.
+func (m *MemoryStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.intents {
+		if existing.ID == intent.ID || existing.LoanID == intent.LoanID && existing.Cycle == intent.Cycle {
+			return fmt.Errorf("failed to create interest intent: loan %s already has an intent for cycle %s", intent.LoanID, intent.Cycle)
+		}
+	}
+	m.intents[intent.ID] = copyInterestIntent(intent)
+	return nil
+}
+
+// UpdateInterestIntent updates an intent's status and completion time.
+func (m *MemoryStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.intents[intent.ID]
+	if !ok {
+		return models.ErrInterestIntentNotFound
+	}
+	updated := copyInterestIntent(existing)
+	updated.Status = intent.Status
+	updated.CompletedAt = clone(intent.CompletedAt)
+	m.intents[intent.ID] = updated
+	return nil
+}
+
+// GetInterestIntent returns the loan's intent for a statement cycle, or nil if none was
+// recorded.
+func (m *MemoryStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error) {
+	intents := m.filterInterestIntents(func(intent *models.InterestIntent) bool {
+		return intent.LoanID == loanID && intent.Cycle == cycle
+	})
+	if len(intents) == 0 {
+		return nil, nil
+	}
+	return intents[0], nil
+}
+
+// GetInterestIntentsByStatus retrieves the intents with a status, oldest first.
+func (m *MemoryStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	return m.filterInterestIntents(func(intent *models.InterestIntent) bool { return intent.Status == status }), nil
+}
+
+// GetInterestIntentsForCycle retrieves every loan's intent for a statement cycle, oldest first.
+func (m *MemoryStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error) {
+	return m.filterInterestIntents(func(intent *models.InterestIntent) bool { return intent.Cycle == cycle }), nil
+}
+
+// filterInterestIntents returns copies of the intents that match, oldest first.
+func (m *MemoryStore) filterInterestIntents(match func(*models.InterestIntent) bool) []*models.InterestIntent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	intents := []*models.InterestIntent{}
+	for _, intent := range m.intents {
+		if match(intent) {
+			intents = append(intents, copyInterestIntent(intent))
+		}
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		a, b := intents[i], intents[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return intents
+}
+
+// CreateIdempotencyRecord claims a key, failing with models.ErrIdempotencyKeyExists if it
+// was claimed before.
+func (m *MemoryStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.idempotency[record.Key]; ok {
+		return models.ErrIdempotencyKeyExists
+	}
+	m.idempotency[record.Key] = copyIdempotencyRecord(record)
+	return nil
+}
+
+// GetIdempotencyRecord returns the record for a key, or nil if the key is unused.
+func (m *MemoryStore) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	record, ok := m.idempotency[key]
+	if !ok {
+		return nil, nil
+	}
+	return copyIdempotencyRecord(record), nil
+}
+
+// UpdateIdempotencyRecord stores the response recorded for a key, and when its request took
+// effect.
+func (m *MemoryStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.idempotency[record.Key]
+	if !ok {
+		return models.ErrIdempotencyRecordNotFound
+	}
+	updated := copyIdempotencyRecord(existing)
+	updated.StatusCode = record.StatusCode
+	updated.ContentType = record.ContentType
+	updated.Body = slices.Clone(record.Body)
+	updated.CompletedAt = clone(record.CompletedAt)
+	updated.AppliedAt = clone(record.AppliedAt)
+	m.idempotency[record.Key] = updated
+	return nil
+}
+
+// DeleteIdempotencyRecord releases a key so it can be used again.
+func (m *MemoryStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.idempotency, key)
+	return nil
+}
+
+// CreateStatement stores a statement; a loan has at most one statement per cycle.
+func (m *MemoryStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.statements {
+		if existing.ID == statement.ID || existing.LoanID == statement.LoanID && existing.Cycle == statement.Cycle {
+			return fmt.Errorf("failed to create statement: loan %s already has a statement for cycle %s", statement.LoanID, statement.Cycle)
+		}
+	}
+	m.statements[statement.ID] = clone(statement)
+	return nil
+}
+
+// GetStatement retrieves a loan's statement for a cycle, or nil if none was issued.
+func (m *MemoryStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, statement := range m.statements {
+		if statement.LoanID == loanID && statement.Cycle == cycle {
+			return clone(statement), nil
+		}
+	}
+	return nil, nil
+}
+
+// GetStatementsForLoan retrieves a loan's statements, oldest first.
+func (m *MemoryStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	statements := []*models.Statement{}
+	for _, statement := range m.statements {
+		if statement.LoanID == loanID {
+			statements = append(statements, clone(statement))
+		}
+	}
+	sort.Slice(statements, func(i, j int) bool {
+		a, b := statements[i], statements[j]
+		if !a.StatementDate.Equal(b.StatementDate) {
+			return a.StatementDate.Before(b.StatementDate)
+		}
+		return a.Cycle < b.Cycle
+	})
+	return statements, nil
+}
+
+// SaveAutopayEnrollment creates or replaces a loan's autopay enrollment.
+func (m *MemoryStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.autopay[enrollment.LoanID] = clone(enrollment)
+	return nil
+}
+
+// GetAutopayEnrollment retrieves a loan's autopay enrollment, or nil if it is not enrolled.
+func (m *MemoryStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return clone(m.autopay[loanID]), nil
+}
+
+// DeleteAutopayEnrollment removes a loan's autopay enrollment.
+func (m *MemoryStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.autopay[loanID]; !ok {
+		return models.ErrNotEnrolledInAutopay
+	}
+	delete(m.autopay, loanID)
+	return nil
+}
+
+// GetAutopayEnrollmentsForDay retrieves the enrollments scheduled for a day of the month, in
+// loan ID order.
+func (m *MemoryStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	enrollments := []*models.AutopayEnrollment{}
+	for _, enrollment := range m.autopay {
+		if enrollment.DayOfMonth == day {
+			enrollments = append(enrollments, clone(enrollment))
+		}
+	}
+	sort.Slice(enrollments, func(i, j int) bool { return enrollments[i].LoanID.String() < enrollments[j].LoanID.String() })
+	return enrollments, nil
+}
+
+// CreateCollateral records an asset securing a loan.
+func (m *MemoryStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.collateral[collateral.ID]; ok {
+		return fmt.Errorf("failed to create collateral: collateral %s already exists", collateral.ID)
+	}
+	m.collateral[collateral.ID] = clone(collateral)
+	return nil
+}
+
+// GetCollateral retrieves an item of collateral by its ID.
+func (m *MemoryStore) GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collateral, ok := m.collateral[id]
+	if !ok {
+		return nil, models.ErrCollateralNotFound
+	}
+	return clone(collateral), nil
+}
+
+// UpdateCollateral updates an item of collateral.
+func (m *MemoryStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.collateral[collateral.ID]
+	if !ok {
+		return models.ErrCollateralNotFound
+	}
+	updated := clone(collateral)
+	updated.LoanID = existing.LoanID
+	updated.CreatedAt = existing.CreatedAt
+	m.collateral[collateral.ID] = updated
+	return nil
+}
+
+// DeleteCollateral removes an item of collateral.
+func (m *MemoryStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.collateral[id]; !ok {
+		return models.ErrCollateralNotFound
+	}
+	delete(m.collateral, id)
+	return nil
+}
+
+// GetCollateralForLoan retrieves the collateral securing a loan, oldest first.
+func (m *MemoryStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	collateral := []*models.Collateral{}
+	for _, item := range m.collateral {
+		if item.LoanID == loanID {
+			collateral = append(collateral, clone(item))
+		}
+	}
+	sort.Slice(collateral, func(i, j int) bool {
+		a, b := collateral[i], collateral[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return collateral, nil
+}
+
+// CreateForbearance records a forbearance window on a loan.
+func (m *MemoryStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.forbearances = append(m.forbearances, clone(forbearance))
+	return nil
+}
+
+// GetForbearancesForLoan retrieves a loan's forbearance windows in start date order.
+func (m *MemoryStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	forbearances := []*models.Forbearance{}
+	for _, forbearance := range m.forbearances {
+		if forbearance.LoanID == loanID {
+			forbearances = append(forbearances, clone(forbearance))
+		}
+	}
+	sort.SliceStable(forbearances, func(i, j int) bool { return forbearances[i].StartDate.Before(forbearances[j].StartDate) })
+	return forbearances, nil
+}
+
+// SaveAccrual stores a loan's accrual for its date, replacing any earlier accrual for the
+// same loan and date.
+func (m *MemoryStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accruals[accrualKey{loanID: accrual.LoanID, date: timeKey(accrual.Date)}] = clone(accrual)
+	return nil
+}
+
+// GetAccrualsForLoan retrieves a loan's accruals dated from through to (inclusive) in date
+// order.
+func (m *MemoryStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	accruals := []*models.Accrual{}
+	for _, accrual := range m.accruals {
+		if accrual.LoanID == loanID && !accrual.Date.Before(from) && !accrual.Date.After(to) {
+			accruals = append(accruals, clone(accrual))
+		}
+	}
+	sort.Slice(accruals, func(i, j int) bool { return accruals[i].Date.Before(accruals[j].Date) })
+	return accruals, nil
+}
+
+// AppendLedgerEvent adds an event to the end of its loan's event log, setting its sequence
+// number.
+func (m *MemoryStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sequence := 1
+	for _, existing := range m.ledgerEvents {
+		if existing.ID == event.ID {
+			return fmt.Errorf("ledger event %s already exists", event.ID)
+		}
+		if existing.LoanID == event.LoanID {
+			sequence = existing.Sequence + 1
+		}
+	}
+	event.Sequence = sequence
+	m.ledgerEvents = append(m.ledgerEvents, copyLedgerEvent(event))
+	return nil
+}
+
+// GetLedgerEvents retrieves a loan's event log in sequence order.
+func (m *MemoryStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events := []*models.LedgerEvent{}
+	for _, event := range m.ledgerEvents {
+		if event.LoanID == loanID {
+			events = append(events, copyLedgerEvent(event)) // Appended in sequence order
+		}
+	}
+	return events, nil
+}
+
+// SaveBureauRecord stores a loan's record for a reporting period, replacing any earlier
+// record for the same loan and period.
+func (m *MemoryStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bureauRecords[bureauRecordKey{loanID: record.LoanID, period: record.Period}] = clone(record)
+	return nil
+}
+
+// GetBureauRecordsForLoan retrieves a loan's bureau records, most recent period first.
+func (m *MemoryStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	records := []*models.BureauRecord{}
+	for _, record := range m.bureauRecords {
+		if record.LoanID == loanID {
+			records = append(records, clone(record))
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Period > records[j].Period })
+	return records, nil
+}
+
+// CreatePaymentMethod stores a customer's payment method.
+func (m *MemoryStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.paymentMethods[method.ID]; ok {
+		return fmt.Errorf("failed to create payment method: payment method %s already exists", method.ID)
+	}
+	m.paymentMethods[method.ID] = copyPaymentMethod(method)
+	return nil
+}
+
+// GetPaymentMethod retrieves a payment method by its ID.
+func (m *MemoryStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	method, ok := m.paymentMethods[id]
+	if !ok {
+		return nil, models.ErrPaymentMethodNotFound
+	}
+	return copyPaymentMethod(method), nil
+}
+
+// UpdatePaymentMethod updates a payment method.
+func (m *MemoryStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.paymentMethods[method.ID]
+	if !ok {
+		return models.ErrPaymentMethodNotFound
+	}
+	updated := copyPaymentMethod(method)
+	updated.CustomerKey = existing.CustomerKey
+	updated.CreatedAt = existing.CreatedAt
+	m.paymentMethods[method.ID] = updated
+	return nil
+}
+
+// GetPaymentMethodsForCustomer retrieves a customer's payment methods, oldest first.
+func (m *MemoryStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	methods := []*models.PaymentMethod{}
+	for _, method := range m.paymentMethods {
+		if method.CustomerKey == customerKey {
+			methods = append(methods, copyPaymentMethod(method))
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		a, b := methods[i], methods[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return methods, nil
+}
+
+// CreatePaymentLink stores a payment link.
+func (m *MemoryStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.paymentLinks[link.ID]; ok {
+		return fmt.Errorf("failed to create payment link: payment link %s already exists", link.ID)
+	}
+	m.paymentLinks[link.ID] = copyPaymentLink(link)
+	return nil
+}
+
+// GetPaymentLink retrieves a payment link by its ID.
+func (m *MemoryStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	link, ok := m.paymentLinks[id]
+	if !ok {
+		return nil, models.ErrPaymentLinkNotFound
+	}
+	return copyPaymentLink(link), nil
+}
+
+// UpdatePaymentLink updates a payment link.
+func (m *MemoryStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.paymentLinks[link.ID]; !ok {
+		return models.ErrPaymentLinkNotFound
+	}
+	m.paymentLinks[link.ID] = copyPaymentLink(link)
+	return nil
+}
+
+// RedeemPaymentLink records a payment link's redemption if the link is still active.
+func (m *MemoryStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.paymentLinks[link.ID]
+	if !ok {
+		return models.ErrPaymentLinkNotFound
+	}
+	if existing.Status != models.PaymentLinkActive {
+		return models.ErrPaymentLinkRedeemed
+	}
+	m.paymentLinks[link.ID] = copyPaymentLink(link)
+	return nil
+}
+
+// CreateWebhookSubscription stores a webhook subscription.
+func (m *MemoryStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooks[subscription.ID]; ok {
+		return fmt.Errorf("failed to create webhook subscription: subscription %s already exists", subscription.ID)
+	}
+	m.webhooks[subscription.ID] = copyWebhookSubscription(subscription)
+	return nil
+}
+
+// GetWebhookSubscription retrieves a webhook subscription by its ID.
+func (m *MemoryStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscription, ok := m.webhooks[id]
+	if !ok {
+		return nil, models.ErrWebhookSubscriptionNotFound
+	}
+	return copyWebhookSubscription(subscription), nil
+}
+
+// GetWebhookSubscriptions retrieves every webhook subscription, oldest first.
+func (m *MemoryStore) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	subscriptions := cloneAll(mapValues(m.webhooks), copyWebhookSubscription)
+	sort.Slice(subscriptions, func(i, j int) bool {
+		a, b := subscriptions[i], subscriptions[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	return subscriptions, nil
+}
+
+// DeleteWebhookSubscription removes a webhook subscription and its deliveries.
+func (m *MemoryStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooks[id]; !ok {
+		return models.ErrWebhookSubscriptionNotFound
+	}
+	delete(m.webhooks, id)
+	m.webhookDeliveries = slices.DeleteFunc(m.webhookDeliveries, func(delivery *models.WebhookDelivery) bool {
+		return delivery.SubscriptionID == id
+	})
+	return nil
+}
+
+// CreateWebhookDelivery queues an event for a subscriber.
+func (m *MemoryStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhookDeliveries = append(m.webhookDeliveries, copyWebhookDelivery(delivery))
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt.
+func (m *MemoryStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, existing := range m.webhookDeliveries {
+		if existing.ID == delivery.ID {
+			updated := copyWebhookDelivery(existing)
+			updated.Status = delivery.Status
+			updated.Attempts = delivery.Attempts
+			updated.NextAttemptAt = delivery.NextAttemptAt
+			updated.LastError = delivery.LastError
+			updated.DeliveredAt = clone(delivery.DeliveredAt)
+			m.webhookDeliveries[i] = updated
+			return nil
+		}
+	}
+	return models.ErrWebhookDeliveryNotFound
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the given time,
+// oldest first.
+func (m *MemoryStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return m.filterWebhookDeliveries(func(delivery *models.WebhookDelivery) bool {
+		return delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(at)
+	}, false, limit), nil
+}
+
+// GetWebhookDeliveriesForSubscription retrieves up to limit of a subscription's deliveries,
+// newest first.
+func (m *MemoryStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	return m.filterWebhookDeliveries(func(delivery *models.WebhookDelivery) bool {
+		return delivery.SubscriptionID == subscriptionID
+	}, true, limit), nil
+}
+
+// filterWebhookDeliveries returns copies of up to limit of the deliveries that match, oldest
+// or newest first.
+func (m *MemoryStore) filterWebhookDeliveries(match func(*models.WebhookDelivery) bool, newestFirst bool, limit int) []*models.WebhookDelivery {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	deliveries := []*models.WebhookDelivery{}
+	for _, delivery := range m.webhookDeliveries {
+		if match(delivery) {
+			deliveries = append(deliveries, copyWebhookDelivery(delivery))
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt) })
+	if newestFirst {
+		slices.Reverse(deliveries)
+	}
+	return deliveries[:min(limit, len(deliveries))]
+}
+
+// CreateOutboxEvent writes an event to the outbox.
+func (m *MemoryStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.outbox {
+		if existing.ID == event.ID {
+			return fmt.Errorf("failed to create outbox event: event %s already exists", event.ID)
+		}
+	}
+	m.outbox = append(m.outbox, copyOutboxEvent(event))
+	return nil
+}
+
+// GetPendingOutboxEvents retrieves up to limit unpublished events, oldest first.
+func (m *MemoryStore) GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events := []*models.OutboxEvent{}
+	for _, event := range m.outbox {
+		if event.PublishedAt == nil && len(events) < limit {
+			events = append(events, copyOutboxEvent(event)) // Written oldest first
+		}
+	}
+	return events, nil
+}
+
+// MarkOutboxEventPublished records that a pending event was published.
+func (m *MemoryStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, event := range m.outbox {
+		if event.ID == id && event.PublishedAt == nil {
+			published := copyOutboxEvent(event)
+			published.PublishedAt = &at
+			m.outbox[i] = published
+			return nil
+		}
+	}
+	return models.ErrOutboxEventPublished
+}
+
+// CreateProduct stores a new product.
+func (m *MemoryStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.products[product.Code]; ok {
+		return fmt.Errorf("failed to create product: product %s already exists", product.Code)
+	}
+	m.products[product.Code] = clone(product)
+	return nil
+}
+
+// GetProduct retrieves a product by its code.
+func (m *MemoryStore) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	product, ok := m.products[code]
+	if !ok {
+		return nil, models.ErrProductNotFound
+	}
+	return clone(product), nil
+}
+
+// UpdateProduct updates an existing product.
+func (m *MemoryStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.products[product.Code]
+	if !ok {
+		return models.ErrProductNotFound
+	}
+	updated := clone(product)
+	updated.CreatedAt = existing.CreatedAt
+	m.products[product.Code] = updated
+	return nil
+}
+
+// GetAllProducts retrieves all products ordered by code.
+func (m *MemoryStore) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	products := cloneAll(mapValues(m.products), clone[models.Product])
+	sort.Slice(products, func(i, j int) bool { return products[i].Code < products[j].Code })
+	return products, nil
+}
+
+// CreateJobRun records a finished run of a batch job.
+func (m *MemoryStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jobRuns = append(m.jobRuns, copyJobRun(run))
+	return nil
+}
+
+// GetJobRuns retrieves up to limit runs of a job, or of every job when job is empty, most
+// recent first.
+func (m *MemoryStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	runs := []*models.JobRun{}
+	for _, run := range m.jobRuns {
+		if job == "" || run.Job == job {
+			runs = append(runs, copyJobRun(run))
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	slices.Reverse(runs)
+	return runs[:min(limit, len(runs))], nil
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestMemoryStore_Copies(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	now := time.Now()
+	paid := now
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_mem", Balance: decimal.NewFromInt(1000), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now, LastPaymentDate: &paid, Version: 1}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	if err := s.CreateLoan(ctx, loan); err == nil {
+		t.Error("Expected error creating a loan twice")
+	}
+
+	// Changing the caller's loan, or one handed out, does not change the stored loan
+	loan.Balance = decimal.NewFromInt(5)
+	*loan.LastPaymentDate = now.AddDate(0, 0, -1)
+	fetched, _ := s.GetLoan(ctx, loan.ID)
+	fetched.Status = models.LoanStatusClosed
+	fetched, _ = s.GetLoan(ctx, loan.ID)
+	if !fetched.Balance.Equal(decimal.NewFromInt(1000)) || fetched.Status != models.LoanStatusActive || !fetched.LastPaymentDate.Equal(now) {
+		t.Errorf("Expected the stored loan unchanged, got %+v", fetched)
+	}
+
+	fetched.Balance = decimal.NewFromInt(900)
+	if err := s.UpdateLoanIfVersion(ctx, fetched, 1); err != nil || fetched.Version != 2 {
+		t.Fatalf("Expected the update to move the loan to version 2, got %d (%v)", fetched.Version, err)
+	}
+	if err := s.UpdateLoanIfVersion(ctx, fetched, 1); err != models.ErrLoanVersionMismatch {
+		t.Errorf("Expected ErrLoanVersionMismatch for a stale version, got %v", err)
+	}
+	if _, err := s.GetCustomer(ctx, "cust_mem"); err != nil {
+		t.Errorf("Expected the loan to register its customer, got %v", err)
+	}
+
+	delivery := &models.WebhookDelivery{ID: uuid.New(), Status: models.WebhookDeliveryPending, Payload: []byte(`{"a":1}`), NextAttemptAt: now, CreatedAt: now}
+	s.CreateWebhookDelivery(ctx, delivery)
+	delivery.Payload[0] = 'x'
+	due, _ := s.GetDueWebhookDeliveries(ctx, now, 10)
+	if len(due) != 1 || string(due[0].Payload) != `{"a":1}` {
+		t.Errorf("Expected the stored payload unchanged, got %q", due[0].Payload)
+	}
+}
+
+func TestMemoryStore_Ordering(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	loanID := uuid.New()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, offset := range []int{2, 0, 1} {
+		tx := &models.Transaction{ID: uuid.New(), LoanID: loanID, Amount: decimal.NewFromInt(int64(offset)), Type: models.TransactionTypePayment, Timestamp: start.Add(time.Duration(offset) * time.Hour)}
+		if err := s.CreateTransaction(ctx, tx); err != nil {
+			t.Fatalf("Failed to create transaction: %v", err)
+		}
+	}
+	transactions, _ := s.GetTransactionsForLoan(ctx, loanID)
+	for i, tx := range transactions {
+		if !tx.Amount.Equal(decimal.NewFromInt(int64(i))) {
+			t.Errorf("Expected transactions in time order, got %s at %d", tx.Amount, i)
+		}
+	}
+
+	// Days are the same in any location, so a snapshot saved again replaces the first
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	s.SavePortfolioSnapshot(ctx, &models.PortfolioSnapshot{Date: day, ActiveLoans: 1})
+	s.SavePortfolioSnapshot(ctx, &models.PortfolioSnapshot{Date: day.In(time.FixedZone("EST", -5*3600)), ActiveLoans: 2})
+	s.SavePortfolioSnapshot(ctx, &models.PortfolioSnapshot{Date: day.AddDate(0, 0, -1), ActiveLoans: 3})
+	snapshots, _ := s.GetPortfolioSnapshots(ctx, day.AddDate(0, 0, -7), day)
+	if len(snapshots) != 2 || snapshots[0].ActiveLoans != 3 || snapshots[1].ActiveLoans != 2 {
+		t.Errorf("Expected 2 snapshots in date order, got %+v", snapshots)
+	}
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(DriverMemory, "")
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	loanID := uuid.New()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.CreateTransaction(ctx, &models.Transaction{ID: uuid.New(), LoanID: loanID, Amount: decimal.NewFromInt(1), Type: models.TransactionTypePayment, Timestamp: time.Now()})
+			s.GetTransactionsForLoan(ctx, loanID)
+		}()
+	}
+	wg.Wait()
+	if transactions, _ := s.GetTransactionsForLoan(ctx, loanID); len(transactions) != 20 {
+		t.Errorf("Expected 20 transactions, got %d", len(transactions))
+	}
+}
+
+func TestMemoryStore_InTransaction(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	now := time.Now()
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_mem", Balance: decimal.NewFromInt(1000), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now}
+	s.CreateLoan(ctx, loan)
+
+	failed := errors.New("failed")
+	err := s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		loan.Balance = decimal.NewFromInt(600)
+		if err := tx.UpdateLoan(ctx, loan); err != nil {
+			return err
+		}
+		tx.CreateTransaction(ctx, &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.NewFromInt(400), Type: models.TransactionTypePayment, Timestamp: now})
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("Expected fn's error, got %v", err)
+	}
+	fetched, _ := s.GetLoan(ctx, loan.ID)
+	transactions, _ := s.GetTransactionsForLoan(ctx, loan.ID)
+	if !fetched.Balance.Equal(decimal.NewFromInt(1000)) || len(transactions) != 0 {
+		t.Errorf("Expected nothing kept from the failed unit, got balance %s and %d transactions", fetched.Balance, len(transactions))
+	}
+
+	err = s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		if err := tx.UpdateLoan(ctx, loan); err != nil {
+			return err
+		}
+		return tx.CreateTransaction(ctx, &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.NewFromInt(400), Type: models.TransactionTypePayment, Timestamp: now})
+	})
+	if err != nil {
+		t.Fatalf("Failed to run unit of work: %v", err)
+	}
+	fetched, _ = s.GetLoan(ctx, loan.ID)
+	transactions, _ = s.GetTransactionsForLoan(ctx, loan.ID)
+	if !fetched.Balance.Equal(decimal.NewFromInt(600)) || len(transactions) != 1 {
+		t.Errorf("Expected both changes kept, got balance %s and %d transactions", fetched.Balance, len(transactions))
+	}
+}
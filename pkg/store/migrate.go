@@ -0,0 +1,434 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles holds each database's migrations, as pairs of files named
+// NNNN_name.up.sql and NNNN_name.down.sql under migrations/<driver>. A migration is never
+// edited once released; a schema change is a new, higher-numbered pair.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// MigrationStatus is one of the schema migrations a store knows of, with when it was applied
+// to the database, if it has been.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// Migrator is implemented by the stores whose schema is built by numbered migrations. The
+// applied migrations are recorded in the database's schema_migrations table.
+type Migrator interface {
+	// Migrate applies the migrations not yet applied and returns how many it ran.
+	Migrate(ctx context.Context) (int, error)
+	// MigrateTo applies or reverts migrations until the schema is at version, where 0 is an
+	// empty database, and returns how many it ran.
+	MigrateTo(ctx context.Context, version int) (int, error)
+	// MigrationStatus lists the known and applied migrations in version order.
+	MigrationStatus(ctx context.Context) ([]MigrationStatus, error)
+	Close() error
+}
+
+var (
+	_ Migrator = (*SQLiteStore)(nil)
+	_ Migrator = (*PostgresStore)(nil)
+	_ Migrator = (*MySQLStore)(nil)
+)
+
+// migration is one numbered schema change.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// migrations describes how one database's schema is migrated.
+type migrations struct {
+	// dir is the directory of the database's files in migrationFiles.
+	dir string
+	// createTable creates the schema_migrations table if it does not exist.
+	createTable string
+	// tableExists counts the tables named by its one argument. It goes through the dialect.
+	tableExists string
+	// transactional is whether the database can roll back DDL, so that a migration and its
+	// record commit together. Elsewhere a failed migration must be repaired by hand.
+	transactional bool
+	// lock, if set, keeps other processes from migrating until unlock runs on the same
+	// connection.
+	lock   func(ctx context.Context, conn *sql.Conn) error
+	unlock string
+	// adopt, if set, brings a database built before migrations were numbered up to the
+	// first migration, which is then recorded as applied without being run.
+	adopt func(ctx context.Context, conn *sql.Conn) error
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// load reads the database's migrations in version order.
+func (m *migrations) load() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, m.dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := migrationFiles.ReadFile(path.Join(m.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &migration{version: version, name: match[2]}
+			byVersion[version] = mig
+		}
+		if match[3] == "up" {
+			mig.up = string(content)
+		} else {
+			mig.down = string(content)
+		}
+	}
+
+	list := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.up == "" || mig.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", mig.version, mig.name)
+		}
+		list = append(list, *mig)
+	}
+	slices.SortFunc(list, func(a, b migration) int { return a.version - b.version })
+	return list, nil
+}
+
+// splitStatements splits a migration file into its statements, each of which ends with a
+// semicolon at the end of a line. Comment lines are dropped.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// execer is satisfied by both *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// execScript runs a migration file's statements. They are written for their database
+// already, so they bypass the dialect.
+func execScript(ctx context.Context, db execer, script string) error {
+	for _, statement := range splitStatements(script) {
+		if _, err := db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate applies the migrations not yet applied and returns how many it ran. Migrations
+// applied by a newer build are left alone.
+func (s *sqlStore) Migrate(ctx context.Context) (int, error) {
+	list, err := s.migrations.load()
+	if err != nil || len(list) == 0 {
+		return 0, err
+	}
+	return s.migrateTo(ctx, list, list[len(list)-1].version, false)
+}
+
+// MigrateTo applies the migrations up to version that have not been applied, in order, then
+// reverts those above it, newest first.
+func (s *sqlStore) MigrateTo(ctx context.Context, version int) (int, error) {
+	list, err := s.migrations.load()
+	if err != nil {
+		return 0, err
+	}
+	return s.migrateTo(ctx, list, version, true)
+}
+
+func (s *sqlStore) migrateTo(ctx context.Context, list []migration, version int, revert bool) (int, error) {
+	if version < 0 || (version > 0 && !slices.ContainsFunc(list, func(m migration) bool { return m.version == version })) {
+		return 0, fmt.Errorf("unknown schema version %d", version)
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if s.migrations.lock != nil {
+		if err := s.migrations.lock(ctx, conn); err != nil {
+			return 0, fmt.Errorf("failed to lock schema: %w", err)
+		}
+		defer conn.ExecContext(context.Background(), s.migrations.unlock)
+	}
+
+	applied, err := s.appliedMigrations(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	for v := range applied {
+		if revert && v > version && !slices.ContainsFunc(list, func(m migration) bool { return m.version == v }) {
+			return 0, fmt.Errorf("schema version %d was applied by a newer build and cannot be reverted by this one", v)
+		}
+	}
+
+	ran := 0
+	for _, m := range list {
+		if _, ok := applied[m.version]; ok || m.version > version {
+			continue
+		}
+		if err := s.runMigration(ctx, conn, m, true); err != nil {
+			return ran, fmt.Errorf("migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+		ran++
+	}
+	if !revert {
+		return ran, nil
+	}
+	for i := len(list) - 1; i >= 0; i-- {
+		m := list[i]
+		if _, ok := applied[m.version]; !ok || m.version <= version {
+			continue
+		}
+		if err := s.runMigration(ctx, conn, m, false); err != nil {
+			return ran, fmt.Errorf("reverting migration %04d_%s failed: %w", m.version, m.name, err)
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// appliedMigrations creates the schema_migrations table if needed and returns when each
+// recorded migration was applied. A database that has loans but no recorded migrations was
+// built before migrations were numbered; it is adopted as being at the first migration.
+func (s *sqlStore) appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	if _, err := conn.ExecContext(ctx, s.migrations.createTable); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	applied, err := s.readAppliedMigrations(ctx, conn)
+	if err != nil || len(applied) > 0 {
+		return applied, err
+	}
+
+	var tables int
+	if err := conn.QueryRowContext(ctx, s.db.dialect.rewrite(s.migrations.tableExists), "loans").Scan(&tables); err != nil {
+		return nil, err
+	}
+	if tables == 0 {
+		return applied, nil
+	}
+	if s.migrations.adopt != nil {
+		if err := s.migrations.adopt(ctx, conn); err != nil {
+			return nil, fmt.Errorf("failed to adopt existing schema: %w", err)
+		}
+	}
+	list, err := s.migrations.load()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordMigration(ctx, conn, list[0], true); err != nil {
+		return nil, err
+	}
+	log.Printf("Adopted the existing schema as migration %04d_%s.", list[0].version, list[0].name)
+	return s.readAppliedMigrations(ctx, conn)
+}
+
+func (s *sqlStore) readAppliedMigrations(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// runMigration runs a migration's up or down file and records the change, together if the
+// database's DDL is transactional.
+func (s *sqlStore) runMigration(ctx context.Context, conn *sql.Conn, m migration, up bool) error {
+	script := m.down
+	if up {
+		script = m.up
+	}
+	if !s.migrations.transactional {
+		if err := execScript(ctx, conn, script); err != nil {
+			return err
+		}
+		return s.recordMigration(ctx, conn, m, up)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := execScript(ctx, tx, script); err != nil {
+		return err
+	}
+	if err := s.recordMigration(ctx, tx, m, up); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordMigration adds an applied migration to schema_migrations, or removes a reverted one.
+func (s *sqlStore) recordMigration(ctx context.Context, db execer, m migration, up bool) error {
+	var err error
+	if up {
+		_, err = db.ExecContext(ctx, s.db.dialect.rewrite(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+			m.version, m.name, time.Now().UTC())
+	} else {
+		_, err = db.ExecContext(ctx, s.db.dialect.rewrite(`DELETE FROM schema_migrations WHERE version = ?`), m.version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %04d_%s: %w", m.version, m.name, err)
+	}
+	return nil
+}
+
+// MigrationStatus lists the migrations this build knows, and any applied by a newer one. A
+// database never migrated has none applied.
+func (s *sqlStore) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	list, err := s.migrations.load()
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]MigrationStatus{}
+	for _, m := range list {
+		byVersion[m.version] = MigrationStatus{Version: m.version, Name: m.name}
+	}
+	var tables int
+	if err := s.db.QueryRowContext(ctx, s.migrations.tableExists, "schema_migrations").Scan(&tables); err != nil {
+		return nil, err
+	}
+	if tables == 0 {
+		return sortedStatuses(byVersion), nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status MigrationStatus
+		var at time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &at); err != nil {
+			return nil, err
+		}
+		status.AppliedAt = &at
+		byVersion[status.Version] = status
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortedStatuses(byVersion), nil
+}
+
+func sortedStatuses(byVersion map[int]MigrationStatus) []MigrationStatus {
+	statuses := make([]MigrationStatus, 0, len(byVersion))
+	for _, status := range byVersion {
+		statuses = append(statuses, status)
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int { return a.Version - b.Version })
+	return statuses
+}
+
+// ErrSchemaTooNew is returned when opening a database whose schema a newer build has
+// migrated past the latest migration this build knows.
+var ErrSchemaTooNew = errors.New("database schema is newer than this build")
+
+// migrate brings a newly opened store's schema up to date and registers the customers of its
+// loans, logging the schema version it leaves the database at. A database at a schema
+// version newer than this build's is refused instead: the build would read and write tables
+// it does not know the shape of, as when it is rolled back, or starts before the rest of a
+// rolling upgrade, after a newer build has migrated the database.
+func (s *sqlStore) migrate(ctx context.Context) error {
+	if err := checkSchemaVersion(ctx, s); err != nil {
+		return err
+	}
+	ran, err := s.Migrate(ctx)
+	if err != nil {
+		return err
+	}
+	version, err := schemaVersion(ctx, s)
+	if err != nil {
+		return err
+	}
+	log.Printf("Database connection established; schema at version %d (%d migrations applied).", version, ran)
+	return s.registerLoanCustomers(ctx)
+}
+
+// checkSchemaVersion returns ErrSchemaTooNew if a migration newer than any this build knows
+// has been applied to the database.
+func checkSchemaVersion(ctx context.Context, s *sqlStore) error {
+	list, err := s.migrations.load()
+	if err != nil || len(list) == 0 {
+		return err
+	}
+	version, err := schemaVersion(ctx, s)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if latest := list[len(list)-1].version; version > latest {
+		return fmt.Errorf("%w: the database is at schema version %d but this build knows up to %d; run a build that knows version %d", ErrSchemaTooNew, version, latest, version)
+	}
+	return nil
+}
+
+// schemaVersion returns the highest migration applied to a database, or 0 for none.
+func schemaVersion(ctx context.Context, s *sqlStore) (int, error) {
+	statuses, err := s.MigrationStatus(ctx)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			version = max(version, status.Version)
+		}
+	}
+	return version, nil
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestMigrations_Files(t *testing.T) {
+	var versions [][]int
+	for _, m := range []*migrations{sqliteMigrations, postgresMigrations, mysqlMigrations} {
+		list, err := m.load()
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", m.dir, err)
+		}
+		var numbers []int
+		for i, mig := range list {
+			if mig.version != i+1 {
+				t.Errorf("Expected %s to be numbered from 1 without gaps, got %d at %d", m.dir, mig.version, i)
+			}
+			if len(splitStatements(mig.up)) == 0 || len(splitStatements(mig.down)) == 0 {
+				t.Errorf("Expected statements in both files of %s migration %d", m.dir, mig.version)
+			}
+			numbers = append(numbers, mig.version)
+		}
+		versions = append(versions, numbers)
+	}
+	// Every database has the same migrations, so a version means the same schema on each
+	if !slices.Equal(versions[0], versions[1]) || !slices.Equal(versions[0], versions[2]) {
+		t.Errorf("Expected the same migrations for every database, got %v", versions)
+	}
+
+	statements := splitStatements("-- A comment\nCREATE TABLE a (\n\tx TEXT DEFAULT ';'\n);\n\nDROP TABLE b;\n")
+	if len(statements) != 2 || statements[1] != "DROP TABLE b" {
+		t.Errorf("Expected 2 statements, got %q", statements)
+	}
+}
+
+func TestMigrations_UpAndDown(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_migrations.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	statuses, err := s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get migration status: %v", err)
+	}
+	if len(statuses) == 0 || statuses[0].Version != 1 || statuses[0].Name != "initial_schema" {
+		t.Fatalf("Expected the initial schema first, got %+v", statuses)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("Expected migration %d applied by opening the store", status.Version)
+		}
+	}
+	if ran, err := s.Migrate(ctx); err != nil || ran != 0 {
+		t.Errorf("Expected nothing to migrate, got %d (%v)", ran, err)
+	}
+
+	if ran, err := s.MigrateTo(ctx, 0); err != nil || ran != len(statuses) {
+		t.Fatalf("Expected %d migrations reverted, got %d (%v)", len(statuses), ran, err)
+	}
+	if _, err := s.GetAllLoans(ctx); err == nil {
+		t.Error("Expected the loans table to be gone at version 0")
+	}
+	statuses, _ = s.MigrationStatus(ctx)
+	for _, status := range statuses {
+		if status.AppliedAt != nil {
+			t.Errorf("Expected migration %d reverted, applied at %v", status.Version, status.AppliedAt)
+		}
+	}
+
+	if ran, err := s.Migrate(ctx); err != nil || ran != len(statuses) {
+		t.Fatalf("Expected %d migrations applied, got %d (%v)", len(statuses), ran, err)
+	}
+	now := time.Now()
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_migrated", Principal: decimal.NewFromInt(100), Balance: decimal.NewFromInt(100), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Errorf("Failed to create loan after migrating back up: %v", err)
+	}
+
+	if _, err := s.MigrateTo(ctx, 9999); err == nil {
+		t.Error("Expected an unknown version to be rejected")
+	}
+}
+
+func TestMigrations_RefuseNewerSchema(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_newer_schema.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	// As a newer build's migration would leave it
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'from_the_future', ?)`, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = NewSQLiteStore(dbFile)
+	if err == nil {
+		s.Close()
+		t.Fatal("Expected a database at a newer schema version to be refused")
+	}
+	if !errors.Is(err, ErrSchemaTooNew) || !strings.Contains(err.Error(), "schema version 9999") {
+		t.Errorf("Expected the newer schema version to be named, got %v", err)
+	}
+
+	// This build's tools can still list its migrations
+	m, err := OpenMigrator(DriverSQLite, dbFile)
+	if err != nil {
+		t.Fatalf("Failed to open migrator: %v", err)
+	}
+	defer m.Close()
+	statuses, err := m.MigrationStatus(ctx)
+	if err != nil || statuses[len(statuses)-1].Version != 9999 {
+		t.Errorf("Expected the newer migration listed, got %+v (%v)", statuses, err)
+	}
+}
+
+func TestMigrations_AdoptLegacySchema(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_legacy.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	// A database from before most columns, the customers and the archive existed
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	for _, statement := range []string{
+		`CREATE TABLE loans (id TEXT PRIMARY KEY, customer_key TEXT NOT NULL, principal TEXT NOT NULL, balance TEXT NOT NULL, interest_rate TEXT NOT NULL, status TEXT NOT NULL, created_at DATETIME NOT NULL, updated_at DATETIME NOT NULL)`,
+		`CREATE TABLE transactions (id TEXT PRIMARY KEY, loan_id TEXT NOT NULL, amount TEXT NOT NULL, type TEXT NOT NULL, timestamp DATETIME NOT NULL)`,
+		`INSERT INTO loans VALUES ('` + uuid.NewString() + `', 'cust_legacy', '500', '400', '0.05', 'active', '2020-01-01 00:00:00+00:00', '2020-01-01 00:00:00+00:00')`,
+	} {
+		if _, err := db.Exec(statement); err != nil {
+			t.Fatalf("Failed to build legacy database: %v", err)
+		}
+	}
+	db.Close()
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer s.Close()
+
+	statuses, err := s.MigrationStatus(ctx)
+	if err != nil || statuses[0].AppliedAt == nil {
+		t.Fatalf("Expected the initial schema recorded as applied, got %+v (%v)", statuses, err)
+	}
+	loans, err := s.GetLoansByCustomerKey(ctx, "cust_legacy")
+	if err != nil || len(loans) != 1 {
+		t.Fatalf("Expected the legacy loan, got %d (%v)", len(loans), err)
+	}
+	if loans[0].Version != 1 || loans[0].DelinquencyBucket != "current" || loans[0].StatementCycleDay != 1 {
+		t.Errorf("Expected the added columns' defaults, got %+v", loans[0])
+	}
+	if _, err := s.GetCustomer(ctx, "cust_legacy"); err != nil {
+		t.Errorf("Expected the loan's customer registered, got %v", err)
+	}
+
+	tx := &models.Transaction{ID: uuid.New(), LoanID: loans[0].ID, Amount: decimal.NewFromInt(100), Type: models.TransactionTypePayment, Timestamp: time.Now(), Reference: "legacy"}
+	if err := s.CreateTransaction(ctx, tx); err != nil {
+		t.Errorf("Failed to create transaction with a reference: %v", err)
+	}
+}
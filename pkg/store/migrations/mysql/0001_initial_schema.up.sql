@@ -0,0 +1,294 @@
+-- The schema as it stood before migrations were numbered. The archive tables are copies of
+-- their hot counterparts' columns.
+CREATE TABLE IF NOT EXISTS customers (
+	id CHAR(36) PRIMARY KEY,
+	customer_key VARCHAR(255) NOT NULL UNIQUE,
+	name VARCHAR(255) NOT NULL DEFAULT '',
+	email VARCHAR(255) NOT NULL DEFAULT '',
+	phone VARCHAR(255) NOT NULL DEFAULT '',
+	address VARCHAR(255) NOT NULL DEFAULT '',
+	status VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS loans (
+	id CHAR(36) PRIMARY KEY,
+	customer_key VARCHAR(255) NOT NULL,
+	principal DECIMAL(38,18) NOT NULL,
+	balance DECIMAL(38,18) NOT NULL,
+	interest_rate DECIMAL(38,18) NOT NULL,
+	base_interest_rate DECIMAL(38,18) NOT NULL DEFAULT 0,
+	interest_rate_variance DECIMAL(38,18) NOT NULL DEFAULT 0,
+	status VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	last_interest_calculation_date DATETIME(6),
+	statement_cycle_day INTEGER NOT NULL DEFAULT 1,
+	accrued_interest DECIMAL(38,18) NOT NULL DEFAULT 0,
+	days_past_due INTEGER NOT NULL DEFAULT 0,
+	delinquency_bucket VARCHAR(255) NOT NULL DEFAULT 'current',
+	last_payment_date DATETIME(6),
+	charged_off_at DATETIME(6),
+	charge_off_amount DECIMAL(38,18) NOT NULL DEFAULT 0,
+	product_code VARCHAR(255) NOT NULL DEFAULT '',
+	post_charge_off_interest DECIMAL(38,18) NOT NULL DEFAULT 0,
+	term_months INTEGER NOT NULL DEFAULT 0,
+	refinanced_from CHAR(36),
+	index_code VARCHAR(255) NOT NULL DEFAULT '',
+	promo_rate DECIMAL(38,18) NOT NULL DEFAULT 0,
+	promo_start_date DATETIME(6),
+	promo_end_date DATETIME(6),
+	amortization_months INTEGER NOT NULL DEFAULT 0,
+	prepayment_penalty_rate DECIMAL(38,18) NOT NULL DEFAULT 0,
+	prepayment_penalty_months INTEGER NOT NULL DEFAULT 0,
+	interest_applied_cycle VARCHAR(255) NOT NULL DEFAULT '',
+	odd_days_policy VARCHAR(255) NOT NULL DEFAULT '',
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest DECIMAL(38,18) NOT NULL DEFAULT 0,
+	escrow_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	escrow_balance DECIMAL(38,18) NOT NULL DEFAULT 0,
+	fees_due DECIMAL(38,18) NOT NULL DEFAULT 0,
+	loan_type VARCHAR(255) NOT NULL DEFAULT '',
+	credit_limit DECIMAL(38,18) NOT NULL DEFAULT 0,
+	accrual_start_date DATETIME(6),
+	interest_mode VARCHAR(255) NOT NULL DEFAULT '',
+	interest_due DECIMAL(38,18) NOT NULL DEFAULT 0,
+	interest_residual DECIMAL(38,18) NOT NULL DEFAULT 0,
+	negative_amortization_cap DECIMAL(38,18) NOT NULL DEFAULT 0,
+	negative_amortization_capped BOOLEAN NOT NULL DEFAULT FALSE,
+	deleted_at DATETIME(6),
+	version INTEGER NOT NULL DEFAULT 1,
+	INDEX idx_loans_customer_key (customer_key),
+	INDEX idx_loans_status (status),
+	INDEX idx_loans_balance (balance),
+	INDEX idx_loans_interest_rate (interest_rate),
+	INDEX idx_loans_created_at (created_at),
+	FOREIGN KEY (customer_key) REFERENCES customers(customer_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS transactions (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	amount DECIMAL(38,18) NOT NULL,
+	type VARCHAR(255) NOT NULL,
+	timestamp DATETIME(6) NOT NULL,
+	payment_method_id CHAR(36),
+	source VARCHAR(255) NOT NULL DEFAULT '',
+	capitalized BOOLEAN NOT NULL DEFAULT FALSE,
+	reference VARCHAR(255) NOT NULL DEFAULT '',
+	INDEX idx_transactions_loan_id (loan_id, timestamp),
+	FOREIGN KEY (loan_id) REFERENCES loans(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS loan_events (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	type VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL,
+	author VARCHAR(255) NOT NULL DEFAULT '',
+	timestamp DATETIME(6) NOT NULL,
+	INDEX idx_loan_events_loan_id (loan_id),
+	FOREIGN KEY (loan_id) REFERENCES loans(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS rate_history (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	base_interest_rate DECIMAL(38,18) NOT NULL,
+	interest_rate_variance DECIMAL(38,18) NOT NULL,
+	interest_rate DECIMAL(38,18) NOT NULL,
+	effective_date DATETIME(6) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	INDEX idx_rate_history_loan_id (loan_id, effective_date),
+	FOREIGN KEY (loan_id) REFERENCES loans(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+-- LIKE copies the columns and indexes but not the foreign keys, which archived rows,
+-- whose loans have gone from loans, could not satisfy
+CREATE TABLE IF NOT EXISTS archived_loans LIKE loans;
+ALTER TABLE archived_loans ADD COLUMN archived_at DATETIME(6) NOT NULL;
+CREATE TABLE IF NOT EXISTS archived_transactions LIKE transactions;
+CREATE TABLE IF NOT EXISTS archived_loan_events LIKE loan_events;
+CREATE TABLE IF NOT EXISTS archived_rate_history LIKE rate_history;
+CREATE TABLE IF NOT EXISTS payment_methods (
+	id CHAR(36) PRIMARY KEY,
+	customer_key VARCHAR(255) NOT NULL,
+	type VARCHAR(255) NOT NULL,
+	token TEXT NOT NULL,
+	last4 VARCHAR(255) NOT NULL DEFAULT '',
+	label VARCHAR(255) NOT NULL DEFAULT '',
+	status VARCHAR(255) NOT NULL,
+	expires_at DATETIME(6),
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	INDEX idx_payment_methods_customer_key (customer_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS index_rates (
+	id CHAR(36) PRIMARY KEY,
+	index_code VARCHAR(255) NOT NULL,
+	rate DECIMAL(38,18) NOT NULL,
+	observation_date DATETIME(6) NOT NULL,
+	source VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	INDEX idx_index_rates_code (index_code, observation_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+	date DATETIME(6) PRIMARY KEY,
+	outstanding_balance DECIMAL(38,18) NOT NULL,
+	accrued_interest DECIMAL(38,18) NOT NULL,
+	active_loans INTEGER NOT NULL,
+	delinquent_loans INTEGER NOT NULL,
+	delinquency_rate DECIMAL(38,18) NOT NULL,
+	originations INTEGER NOT NULL,
+	origination_volume DECIMAL(38,18) NOT NULL,
+	created_at DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS interest_intents (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	cycle VARCHAR(255) NOT NULL,
+	amount DECIMAL(38,18) NOT NULL,
+	transaction_id CHAR(36) NOT NULL,
+	status VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	completed_at DATETIME(6),
+	UNIQUE (loan_id, cycle),
+	INDEX idx_interest_intents_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	`key` VARCHAR(255) PRIMARY KEY,
+	request_hash VARCHAR(255) NOT NULL,
+	status_code INTEGER NOT NULL,
+	content_type VARCHAR(255) NOT NULL,
+	body LONGBLOB,
+	created_at DATETIME(6) NOT NULL,
+	completed_at DATETIME(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS payment_links (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	min_amount DECIMAL(38,18) NOT NULL,
+	max_amount DECIMAL(38,18) NOT NULL,
+	status VARCHAR(255) NOT NULL,
+	expires_at DATETIME(6) NOT NULL,
+	transaction_id CHAR(36),
+	created_at DATETIME(6) NOT NULL,
+	redeemed_at DATETIME(6)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id CHAR(36) PRIMARY KEY,
+	url TEXT NOT NULL,
+	events TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id CHAR(36) PRIMARY KEY,
+	subscription_id CHAR(36) NOT NULL,
+	event_id CHAR(36) NOT NULL,
+	event_type VARCHAR(255) NOT NULL,
+	payload LONGBLOB NOT NULL,
+	status VARCHAR(255) NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at DATETIME(6) NOT NULL,
+	last_error TEXT NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	delivered_at DATETIME(6),
+	INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+	INDEX idx_webhook_deliveries_subscription (subscription_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS bureau_records (
+	loan_id CHAR(36) NOT NULL,
+	period VARCHAR(255) NOT NULL,
+	customer_key VARCHAR(255) NOT NULL,
+	product_code VARCHAR(255) NOT NULL DEFAULT '',
+	date_opened DATETIME(6) NOT NULL,
+	original_amount DECIMAL(38,18) NOT NULL,
+	current_balance DECIMAL(38,18) NOT NULL,
+	term_months INTEGER NOT NULL DEFAULT 0,
+	account_status VARCHAR(255) NOT NULL,
+	days_past_due INTEGER NOT NULL,
+	payment_history TEXT NOT NULL,
+	as_of DATETIME(6) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	PRIMARY KEY (loan_id, period)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS statements (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	cycle VARCHAR(255) NOT NULL,
+	period_start DATETIME(6) NOT NULL,
+	statement_date DATETIME(6) NOT NULL,
+	balance DECIMAL(38,18) NOT NULL,
+	interest_charged DECIMAL(38,18) NOT NULL,
+	payments DECIMAL(38,18) NOT NULL,
+	fees DECIMAL(38,18) NOT NULL,
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest DECIMAL(38,18) NOT NULL DEFAULT 0,
+	odd_days_policy VARCHAR(255) NOT NULL DEFAULT '',
+	minimum_due DECIMAL(38,18) NOT NULL DEFAULT 0,
+	due_date DATETIME(6) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	UNIQUE (loan_id, cycle)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS collateral (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	type VARCHAR(255) NOT NULL,
+	description TEXT NOT NULL,
+	valuation DECIMAL(38,18) NOT NULL,
+	valuation_date DATETIME(6) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL,
+	INDEX idx_collateral_loan_id (loan_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS forbearances (
+	id CHAR(36) PRIMARY KEY,
+	loan_id CHAR(36) NOT NULL,
+	start_date DATETIME(6) NOT NULL,
+	end_date DATETIME(6) NOT NULL,
+	rate DECIMAL(38,18) NOT NULL,
+	reason TEXT NOT NULL,
+	created_by VARCHAR(255) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	INDEX idx_forbearances_loan_id (loan_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS accruals (
+	loan_id CHAR(36) NOT NULL,
+	date DATETIME(6) NOT NULL,
+	balance DECIMAL(38,18) NOT NULL,
+	rate DECIMAL(38,18) NOT NULL,
+	amount DECIMAL(38,18) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	PRIMARY KEY (loan_id, date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS autopay_enrollments (
+	loan_id CHAR(36) PRIMARY KEY,
+	amount_type VARCHAR(255) NOT NULL,
+	amount DECIMAL(38,18) NOT NULL,
+	day_of_month INTEGER NOT NULL,
+	payment_method_id CHAR(36) NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS products (
+	code VARCHAR(255) PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	accrue_after_charge_off BOOLEAN NOT NULL DEFAULT FALSE,
+	odd_days_interest VARCHAR(255) NOT NULL DEFAULT '',
+	minimum_payment_floor DECIMAL(38,18) NOT NULL DEFAULT 0,
+	minimum_payment_percent DECIMAL(38,18) NOT NULL DEFAULT 0,
+	accrual_grace_days INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME(6) NOT NULL,
+	updated_at DATETIME(6) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
+CREATE TABLE IF NOT EXISTS job_runs (
+	id CHAR(36) PRIMARY KEY,
+	job VARCHAR(255) NOT NULL,
+	triggered_by VARCHAR(255) NOT NULL,
+	date DATETIME(6) NOT NULL,
+	loan_id CHAR(36),
+	started_at DATETIME(6) NOT NULL,
+	finished_at DATETIME(6) NOT NULL,
+	processed INTEGER NOT NULL,
+	skipped INTEGER NOT NULL,
+	failed INTEGER NOT NULL,
+	errors TEXT NOT NULL,
+	INDEX idx_job_runs_job (job, started_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1 @@
+DROP INDEX idx_loans_created_at_id ON loans;
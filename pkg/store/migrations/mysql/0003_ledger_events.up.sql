@@ -0,0 +1,22 @@
+-- The append-only event log of event-sourced mode. It has no foreign key to loans, as
+-- archiving moves loans out of the table while their logs stay
+CREATE TABLE IF NOT EXISTS ledger_events (
+	id CHAR(36) NOT NULL UNIQUE,
+	loan_id CHAR(36) NOT NULL,
+	sequence_number INT NOT NULL,
+	type VARCHAR(255) NOT NULL,
+	amount DECIMAL(38,18) NOT NULL,
+	transaction_id CHAR(36),
+	balance_change DECIMAL(38,18) NOT NULL,
+	accrued_interest_change DECIMAL(38,18) NOT NULL,
+	interest_due_change DECIMAL(38,18) NOT NULL,
+	fees_due_change DECIMAL(38,18) NOT NULL,
+	escrow_balance_change DECIMAL(38,18) NOT NULL,
+	balance DECIMAL(38,18) NOT NULL,
+	accrued_interest DECIMAL(38,18) NOT NULL,
+	interest_due DECIMAL(38,18) NOT NULL,
+	fees_due DECIMAL(38,18) NOT NULL,
+	escrow_balance DECIMAL(38,18) NOT NULL,
+	occurred_at DATETIME(6) NOT NULL,
+	PRIMARY KEY (loan_id, sequence_number)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
@@ -0,0 +1,10 @@
+-- The transactional outbox: webhook events written with the changes that raised them, until
+-- the relay publishes them
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id CHAR(36) PRIMARY KEY,
+	event_type VARCHAR(255) NOT NULL,
+	payload LONGBLOB NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	published_at DATETIME(6),
+	INDEX idx_outbox_events_pending (published_at, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin;
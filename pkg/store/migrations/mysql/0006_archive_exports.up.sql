@@ -0,0 +1,3 @@
+-- When an archived loan was exported to object storage, with its transactions, events and rate
+-- history; NULL until it has been
+ALTER TABLE archived_loans ADD COLUMN exported_at DATETIME(6) NULL;
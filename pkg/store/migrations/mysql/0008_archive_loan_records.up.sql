@@ -0,0 +1,12 @@
+-- Archive counterparts of the remaining per-loan tables, so that archiving moves all of a
+-- loan's records out of the hot tables, its event log included. None of these has a foreign
+-- key for LIKE to leave behind
+CREATE TABLE IF NOT EXISTS archived_statements LIKE statements;
+CREATE TABLE IF NOT EXISTS archived_accruals LIKE accruals;
+CREATE TABLE IF NOT EXISTS archived_collateral LIKE collateral;
+CREATE TABLE IF NOT EXISTS archived_forbearances LIKE forbearances;
+CREATE TABLE IF NOT EXISTS archived_autopay_enrollments LIKE autopay_enrollments;
+CREATE TABLE IF NOT EXISTS archived_payment_links LIKE payment_links;
+CREATE TABLE IF NOT EXISTS archived_bureau_records LIKE bureau_records;
+CREATE TABLE IF NOT EXISTS archived_interest_intents LIKE interest_intents;
+CREATE TABLE IF NOT EXISTS archived_ledger_events LIKE ledger_events;
@@ -0,0 +1,25 @@
+DROP TABLE IF EXISTS job_runs;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS autopay_enrollments;
+DROP TABLE IF EXISTS accruals;
+DROP TABLE IF EXISTS forbearances;
+DROP TABLE IF EXISTS collateral;
+DROP TABLE IF EXISTS statements;
+DROP TABLE IF EXISTS bureau_records;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+DROP TABLE IF EXISTS payment_links;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS interest_intents;
+DROP TABLE IF EXISTS portfolio_snapshots;
+DROP TABLE IF EXISTS index_rates;
+DROP TABLE IF EXISTS payment_methods;
+DROP TABLE IF EXISTS archived_rate_history;
+DROP TABLE IF EXISTS rate_history;
+DROP TABLE IF EXISTS archived_loan_events;
+DROP TABLE IF EXISTS loan_events;
+DROP TABLE IF EXISTS archived_transactions;
+DROP TABLE IF EXISTS archived_loans;
+DROP TABLE IF EXISTS transactions;
+DROP TABLE IF EXISTS loans;
+DROP TABLE IF EXISTS customers;
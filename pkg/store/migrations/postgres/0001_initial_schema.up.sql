@@ -0,0 +1,293 @@
+-- The schema as it stood before migrations were numbered. The archive tables are copies of
+-- their hot counterparts' columns.
+CREATE TABLE IF NOT EXISTS customers (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	address TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS loans (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL REFERENCES customers(customer_key),
+	principal NUMERIC NOT NULL,
+	balance NUMERIC NOT NULL,
+	interest_rate NUMERIC NOT NULL,
+	base_interest_rate NUMERIC NOT NULL DEFAULT 0,
+	interest_rate_variance NUMERIC NOT NULL DEFAULT 0,
+	status TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	last_interest_calculation_date TIMESTAMPTZ,
+	statement_cycle_day INTEGER NOT NULL DEFAULT 1,
+	accrued_interest NUMERIC NOT NULL DEFAULT 0,
+	days_past_due INTEGER NOT NULL DEFAULT 0,
+	delinquency_bucket TEXT NOT NULL DEFAULT 'current',
+	last_payment_date TIMESTAMPTZ,
+	charged_off_at TIMESTAMPTZ,
+	charge_off_amount NUMERIC NOT NULL DEFAULT 0,
+	product_code TEXT NOT NULL DEFAULT '',
+	post_charge_off_interest NUMERIC NOT NULL DEFAULT 0,
+	term_months INTEGER NOT NULL DEFAULT 0,
+	refinanced_from TEXT,
+	index_code TEXT NOT NULL DEFAULT '',
+	promo_rate NUMERIC NOT NULL DEFAULT 0,
+	promo_start_date TIMESTAMPTZ,
+	promo_end_date TIMESTAMPTZ,
+	amortization_months INTEGER NOT NULL DEFAULT 0,
+	prepayment_penalty_rate NUMERIC NOT NULL DEFAULT 0,
+	prepayment_penalty_months INTEGER NOT NULL DEFAULT 0,
+	interest_applied_cycle TEXT NOT NULL DEFAULT '',
+	odd_days_policy TEXT NOT NULL DEFAULT '',
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest NUMERIC NOT NULL DEFAULT 0,
+	escrow_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+	escrow_balance NUMERIC NOT NULL DEFAULT 0,
+	fees_due NUMERIC NOT NULL DEFAULT 0,
+	loan_type TEXT NOT NULL DEFAULT '',
+	credit_limit NUMERIC NOT NULL DEFAULT 0,
+	accrual_start_date TIMESTAMPTZ,
+	interest_mode TEXT NOT NULL DEFAULT '',
+	interest_due NUMERIC NOT NULL DEFAULT 0,
+	interest_residual NUMERIC NOT NULL DEFAULT 0,
+	negative_amortization_cap NUMERIC NOT NULL DEFAULT 0,
+	negative_amortization_capped BOOLEAN NOT NULL DEFAULT FALSE,
+	deleted_at TIMESTAMPTZ,
+	version INTEGER NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_loans_customer_key ON loans(customer_key);
+CREATE INDEX IF NOT EXISTS idx_loans_status ON loans(status);
+CREATE INDEX IF NOT EXISTS idx_loans_balance ON loans(balance);
+CREATE INDEX IF NOT EXISTS idx_loans_interest_rate ON loans(interest_rate);
+CREATE INDEX IF NOT EXISTS idx_loans_created_at ON loans(created_at);
+CREATE TABLE IF NOT EXISTS transactions (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL REFERENCES loans(id),
+	amount NUMERIC NOT NULL,
+	type TEXT NOT NULL,
+	timestamp TIMESTAMPTZ NOT NULL,
+	payment_method_id TEXT,
+	source TEXT NOT NULL DEFAULT '',
+	capitalized BOOLEAN NOT NULL DEFAULT FALSE,
+	reference TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_transactions_loan_id ON transactions(loan_id, timestamp);
+CREATE TABLE IF NOT EXISTS archived_loans (LIKE loans INCLUDING DEFAULTS);
+ALTER TABLE archived_loans ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_loans_id ON archived_loans(id);
+CREATE TABLE IF NOT EXISTS archived_transactions (LIKE transactions INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_transactions_id ON archived_transactions(id);
+CREATE INDEX IF NOT EXISTS idx_archived_transactions_loan_id ON archived_transactions(loan_id, timestamp);
+CREATE TABLE IF NOT EXISTS loan_events (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL REFERENCES loans(id),
+	type TEXT NOT NULL,
+	description TEXT NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	timestamp TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_loan_events_loan_id ON loan_events(loan_id);
+CREATE TABLE IF NOT EXISTS archived_loan_events (LIKE loan_events INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_loan_events_id ON archived_loan_events(id);
+CREATE TABLE IF NOT EXISTS rate_history (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL REFERENCES loans(id),
+	base_interest_rate NUMERIC NOT NULL,
+	interest_rate_variance NUMERIC NOT NULL,
+	interest_rate NUMERIC NOT NULL,
+	effective_date TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rate_history_loan_id ON rate_history(loan_id, effective_date);
+CREATE TABLE IF NOT EXISTS archived_rate_history (LIKE rate_history INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_rate_history_id ON archived_rate_history(id);
+CREATE TABLE IF NOT EXISTS payment_methods (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL,
+	type TEXT NOT NULL,
+	token TEXT NOT NULL,
+	last4 TEXT NOT NULL DEFAULT '',
+	label TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	expires_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_payment_methods_customer_key ON payment_methods(customer_key);
+CREATE TABLE IF NOT EXISTS index_rates (
+	id TEXT PRIMARY KEY,
+	index_code TEXT NOT NULL,
+	rate NUMERIC NOT NULL,
+	observation_date TIMESTAMPTZ NOT NULL,
+	source TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_index_rates_code ON index_rates(index_code, observation_date);
+CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+	date TIMESTAMPTZ PRIMARY KEY,
+	outstanding_balance NUMERIC NOT NULL,
+	accrued_interest NUMERIC NOT NULL,
+	active_loans INTEGER NOT NULL,
+	delinquent_loans INTEGER NOT NULL,
+	delinquency_rate NUMERIC NOT NULL,
+	originations INTEGER NOT NULL,
+	origination_volume NUMERIC NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS interest_intents (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	cycle TEXT NOT NULL,
+	amount NUMERIC NOT NULL,
+	transaction_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	completed_at TIMESTAMPTZ,
+	UNIQUE (loan_id, cycle)
+);
+CREATE INDEX IF NOT EXISTS idx_interest_intents_status ON interest_intents(status);
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	request_hash TEXT NOT NULL,
+	status_code INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	body BYTEA,
+	created_at TIMESTAMPTZ NOT NULL,
+	completed_at TIMESTAMPTZ
+);
+CREATE TABLE IF NOT EXISTS payment_links (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	min_amount NUMERIC NOT NULL,
+	max_amount NUMERIC NOT NULL,
+	status TEXT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	transaction_id TEXT,
+	created_at TIMESTAMPTZ NOT NULL,
+	redeemed_at TIMESTAMPTZ
+);
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id TEXT PRIMARY KEY,
+	url TEXT NOT NULL,
+	events TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	subscription_id TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	payload BYTEA NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	delivered_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id);
+CREATE TABLE IF NOT EXISTS bureau_records (
+	loan_id TEXT NOT NULL,
+	period TEXT NOT NULL,
+	customer_key TEXT NOT NULL,
+	product_code TEXT NOT NULL DEFAULT '',
+	date_opened TIMESTAMPTZ NOT NULL,
+	original_amount NUMERIC NOT NULL,
+	current_balance NUMERIC NOT NULL,
+	term_months INTEGER NOT NULL DEFAULT 0,
+	account_status TEXT NOT NULL,
+	days_past_due INTEGER NOT NULL,
+	payment_history TEXT NOT NULL,
+	as_of TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (loan_id, period)
+);
+CREATE TABLE IF NOT EXISTS statements (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	cycle TEXT NOT NULL,
+	period_start TIMESTAMPTZ NOT NULL,
+	statement_date TIMESTAMPTZ NOT NULL,
+	balance NUMERIC NOT NULL,
+	interest_charged NUMERIC NOT NULL,
+	payments NUMERIC NOT NULL,
+	fees NUMERIC NOT NULL,
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest NUMERIC NOT NULL DEFAULT 0,
+	odd_days_policy TEXT NOT NULL DEFAULT '',
+	minimum_due NUMERIC NOT NULL DEFAULT 0,
+	due_date TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	UNIQUE (loan_id, cycle)
+);
+CREATE TABLE IF NOT EXISTS collateral (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL,
+	valuation NUMERIC NOT NULL,
+	valuation_date TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_collateral_loan_id ON collateral(loan_id);
+CREATE TABLE IF NOT EXISTS forbearances (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	start_date TIMESTAMPTZ NOT NULL,
+	end_date TIMESTAMPTZ NOT NULL,
+	rate NUMERIC NOT NULL,
+	reason TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_forbearances_loan_id ON forbearances(loan_id);
+CREATE TABLE IF NOT EXISTS accruals (
+	loan_id TEXT NOT NULL,
+	date TIMESTAMPTZ NOT NULL,
+	balance NUMERIC NOT NULL,
+	rate NUMERIC NOT NULL,
+	amount NUMERIC NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (loan_id, date)
+);
+CREATE TABLE IF NOT EXISTS autopay_enrollments (
+	loan_id TEXT PRIMARY KEY,
+	amount_type TEXT NOT NULL,
+	amount NUMERIC NOT NULL,
+	day_of_month INTEGER NOT NULL,
+	payment_method_id TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS products (
+	code TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	accrue_after_charge_off BOOLEAN NOT NULL DEFAULT FALSE,
+	odd_days_interest TEXT NOT NULL DEFAULT '',
+	minimum_payment_floor NUMERIC NOT NULL DEFAULT 0,
+	minimum_payment_percent NUMERIC NOT NULL DEFAULT 0,
+	accrual_grace_days INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE TABLE IF NOT EXISTS job_runs (
+	id TEXT PRIMARY KEY,
+	job TEXT NOT NULL,
+	triggered_by TEXT NOT NULL,
+	date TIMESTAMPTZ NOT NULL,
+	loan_id TEXT,
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ NOT NULL,
+	processed INTEGER NOT NULL,
+	skipped INTEGER NOT NULL,
+	failed INTEGER NOT NULL,
+	errors TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job, started_at);
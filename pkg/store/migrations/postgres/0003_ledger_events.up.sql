@@ -0,0 +1,22 @@
+-- The append-only event log of event-sourced mode. It has no foreign key to loans, as
+-- archiving moves loans out of the table while their logs stay
+CREATE TABLE IF NOT EXISTS ledger_events (
+	id TEXT NOT NULL UNIQUE,
+	loan_id TEXT NOT NULL,
+	sequence_number INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	amount NUMERIC NOT NULL,
+	transaction_id TEXT,
+	balance_change NUMERIC NOT NULL,
+	accrued_interest_change NUMERIC NOT NULL,
+	interest_due_change NUMERIC NOT NULL,
+	fees_due_change NUMERIC NOT NULL,
+	escrow_balance_change NUMERIC NOT NULL,
+	balance NUMERIC NOT NULL,
+	accrued_interest NUMERIC NOT NULL,
+	interest_due NUMERIC NOT NULL,
+	fees_due NUMERIC NOT NULL,
+	escrow_balance NUMERIC NOT NULL,
+	occurred_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (loan_id, sequence_number)
+);
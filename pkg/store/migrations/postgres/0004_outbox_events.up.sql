@@ -0,0 +1,10 @@
+-- The transactional outbox: webhook events written with the changes that raised them, until
+-- the relay publishes them
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id TEXT PRIMARY KEY,
+	event_type TEXT NOT NULL,
+	payload BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	published_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events(published_at, created_at);
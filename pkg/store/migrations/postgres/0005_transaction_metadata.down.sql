@@ -0,0 +1,2 @@
+ALTER TABLE archived_transactions DROP COLUMN IF EXISTS metadata;
+ALTER TABLE transactions DROP COLUMN IF EXISTS metadata;
@@ -0,0 +1 @@
+ALTER TABLE archived_loans DROP COLUMN IF EXISTS exported_at;
@@ -0,0 +1,3 @@
+-- When the request holding an idempotency key took effect, recorded in the same transaction as
+-- its changes; a key whose request took effect is never released for a retry
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS applied_at TIMESTAMPTZ;
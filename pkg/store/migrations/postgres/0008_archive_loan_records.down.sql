@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS archived_ledger_events;
+DROP TABLE IF EXISTS archived_interest_intents;
+DROP TABLE IF EXISTS archived_bureau_records;
+DROP TABLE IF EXISTS archived_payment_links;
+DROP TABLE IF EXISTS archived_autopay_enrollments;
+DROP TABLE IF EXISTS archived_forbearances;
+DROP TABLE IF EXISTS archived_collateral;
+DROP TABLE IF EXISTS archived_accruals;
+DROP TABLE IF EXISTS archived_statements;
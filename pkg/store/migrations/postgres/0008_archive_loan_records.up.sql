@@ -0,0 +1,20 @@
+-- Archive counterparts of the remaining per-loan tables, so that archiving moves all of a
+-- loan's records out of the hot tables, its event log included
+CREATE TABLE IF NOT EXISTS archived_statements (LIKE statements INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_statements_id ON archived_statements(id);
+CREATE TABLE IF NOT EXISTS archived_accruals (LIKE accruals INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_accruals_loan_id ON archived_accruals(loan_id, date);
+CREATE TABLE IF NOT EXISTS archived_collateral (LIKE collateral INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_collateral_id ON archived_collateral(id);
+CREATE TABLE IF NOT EXISTS archived_forbearances (LIKE forbearances INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_forbearances_id ON archived_forbearances(id);
+CREATE TABLE IF NOT EXISTS archived_autopay_enrollments (LIKE autopay_enrollments INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_autopay_enrollments_loan_id ON archived_autopay_enrollments(loan_id);
+CREATE TABLE IF NOT EXISTS archived_payment_links (LIKE payment_links INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_payment_links_id ON archived_payment_links(id);
+CREATE TABLE IF NOT EXISTS archived_bureau_records (LIKE bureau_records INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_bureau_records_loan_id ON archived_bureau_records(loan_id, period);
+CREATE TABLE IF NOT EXISTS archived_interest_intents (LIKE interest_intents INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_interest_intents_id ON archived_interest_intents(id);
+CREATE TABLE IF NOT EXISTS archived_ledger_events (LIKE ledger_events INCLUDING DEFAULTS);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_archived_ledger_events_loan_id ON archived_ledger_events(loan_id, sequence_number);
@@ -0,0 +1,366 @@
+-- The schema as it stood before migrations were numbered. Amounts are TEXT so that no
+-- precision is lost.
+CREATE TABLE IF NOT EXISTS customers (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL DEFAULT '',
+	email TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	address TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS loans (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL REFERENCES customers(customer_key),
+	principal TEXT NOT NULL,
+	balance TEXT NOT NULL,
+	interest_rate TEXT NOT NULL,
+	base_interest_rate TEXT NOT NULL DEFAULT '0',
+	interest_rate_variance TEXT NOT NULL DEFAULT '0',
+	status TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	last_interest_calculation_date DATETIME,
+	statement_cycle_day INTEGER NOT NULL DEFAULT 1,
+	accrued_interest TEXT NOT NULL DEFAULT '0',
+	days_past_due INTEGER NOT NULL DEFAULT 0,
+	delinquency_bucket TEXT NOT NULL DEFAULT 'current',
+	last_payment_date DATETIME,
+	charged_off_at DATETIME,
+	charge_off_amount TEXT NOT NULL DEFAULT '0',
+	product_code TEXT NOT NULL DEFAULT '',
+	post_charge_off_interest TEXT NOT NULL DEFAULT '0',
+	term_months INTEGER NOT NULL DEFAULT 0,
+	refinanced_from TEXT,
+	index_code TEXT NOT NULL DEFAULT '',
+	promo_rate TEXT NOT NULL DEFAULT '0',
+	promo_start_date DATETIME,
+	promo_end_date DATETIME,
+	amortization_months INTEGER NOT NULL DEFAULT 0,
+	prepayment_penalty_rate TEXT NOT NULL DEFAULT '0',
+	prepayment_penalty_months INTEGER NOT NULL DEFAULT 0,
+	interest_applied_cycle TEXT NOT NULL DEFAULT '',
+	odd_days_policy TEXT NOT NULL DEFAULT '',
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest TEXT NOT NULL DEFAULT '0',
+	escrow_enabled INTEGER NOT NULL DEFAULT 0,
+	escrow_balance TEXT NOT NULL DEFAULT '0',
+	fees_due TEXT NOT NULL DEFAULT '0',
+	loan_type TEXT NOT NULL DEFAULT '',
+	credit_limit TEXT NOT NULL DEFAULT '0',
+	accrual_start_date DATETIME,
+	interest_mode TEXT NOT NULL DEFAULT '',
+	interest_due TEXT NOT NULL DEFAULT '0',
+	interest_residual TEXT NOT NULL DEFAULT '0',
+	negative_amortization_cap TEXT NOT NULL DEFAULT '0',
+	negative_amortization_capped INTEGER NOT NULL DEFAULT 0,
+	deleted_at DATETIME,
+	version INTEGER NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_loans_customer_key ON loans(customer_key);
+CREATE INDEX IF NOT EXISTS idx_loans_status ON loans(status);
+CREATE INDEX IF NOT EXISTS idx_loans_balance ON loans(CAST(balance AS REAL));
+CREATE INDEX IF NOT EXISTS idx_loans_interest_rate ON loans(CAST(interest_rate AS REAL));
+CREATE INDEX IF NOT EXISTS idx_loans_created_at ON loans(julianday(created_at));
+CREATE TABLE IF NOT EXISTS transactions (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	type TEXT NOT NULL,
+	timestamp DATETIME NOT NULL,
+	payment_method_id TEXT,
+	source TEXT NOT NULL DEFAULT '',
+	capitalized INTEGER NOT NULL DEFAULT 0,
+	reference TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY(loan_id) REFERENCES loans(id)
+);
+CREATE INDEX IF NOT EXISTS idx_transactions_loan_id ON transactions(loan_id, timestamp);
+CREATE TABLE IF NOT EXISTS archived_loans (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL,
+	principal TEXT NOT NULL,
+	balance TEXT NOT NULL,
+	interest_rate TEXT NOT NULL,
+	base_interest_rate TEXT NOT NULL DEFAULT '0',
+	interest_rate_variance TEXT NOT NULL DEFAULT '0',
+	status TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	last_interest_calculation_date DATETIME,
+	statement_cycle_day INTEGER NOT NULL DEFAULT 1,
+	accrued_interest TEXT NOT NULL DEFAULT '0',
+	days_past_due INTEGER NOT NULL DEFAULT 0,
+	delinquency_bucket TEXT NOT NULL DEFAULT 'current',
+	last_payment_date DATETIME,
+	charged_off_at DATETIME,
+	charge_off_amount TEXT NOT NULL DEFAULT '0',
+	product_code TEXT NOT NULL DEFAULT '',
+	post_charge_off_interest TEXT NOT NULL DEFAULT '0',
+	term_months INTEGER NOT NULL DEFAULT 0,
+	refinanced_from TEXT,
+	index_code TEXT NOT NULL DEFAULT '',
+	promo_rate TEXT NOT NULL DEFAULT '0',
+	promo_start_date DATETIME,
+	promo_end_date DATETIME,
+	amortization_months INTEGER NOT NULL DEFAULT 0,
+	prepayment_penalty_rate TEXT NOT NULL DEFAULT '0',
+	prepayment_penalty_months INTEGER NOT NULL DEFAULT 0,
+	interest_applied_cycle TEXT NOT NULL DEFAULT '',
+	odd_days_policy TEXT NOT NULL DEFAULT '',
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest TEXT NOT NULL DEFAULT '0',
+	escrow_enabled INTEGER NOT NULL DEFAULT 0,
+	escrow_balance TEXT NOT NULL DEFAULT '0',
+	fees_due TEXT NOT NULL DEFAULT '0',
+	loan_type TEXT NOT NULL DEFAULT '',
+	credit_limit TEXT NOT NULL DEFAULT '0',
+	accrual_start_date DATETIME,
+	interest_mode TEXT NOT NULL DEFAULT '',
+	interest_due TEXT NOT NULL DEFAULT '0',
+	interest_residual TEXT NOT NULL DEFAULT '0',
+	negative_amortization_cap TEXT NOT NULL DEFAULT '0',
+	negative_amortization_capped INTEGER NOT NULL DEFAULT 0,
+	deleted_at DATETIME,
+	version INTEGER NOT NULL DEFAULT 1,
+	archived_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS archived_transactions (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	type TEXT NOT NULL,
+	timestamp DATETIME NOT NULL,
+	payment_method_id TEXT,
+	source TEXT NOT NULL DEFAULT '',
+	capitalized INTEGER NOT NULL DEFAULT 0,
+	reference TEXT NOT NULL DEFAULT '',
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS loan_events (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	timestamp DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES loans(id)
+);
+CREATE INDEX IF NOT EXISTS idx_loan_events_loan_id ON loan_events(loan_id);
+CREATE TABLE IF NOT EXISTS archived_loan_events (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	timestamp DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS rate_history (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	base_interest_rate TEXT NOT NULL,
+	interest_rate_variance TEXT NOT NULL,
+	interest_rate TEXT NOT NULL,
+	effective_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES loans(id)
+);
+CREATE INDEX IF NOT EXISTS idx_rate_history_loan_id ON rate_history(loan_id, effective_date);
+CREATE TABLE IF NOT EXISTS archived_rate_history (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	base_interest_rate TEXT NOT NULL,
+	interest_rate_variance TEXT NOT NULL,
+	interest_rate TEXT NOT NULL,
+	effective_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS payment_methods (
+	id TEXT PRIMARY KEY,
+	customer_key TEXT NOT NULL,
+	type TEXT NOT NULL,
+	token TEXT NOT NULL,
+	last4 TEXT NOT NULL DEFAULT '',
+	label TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	expires_at DATETIME,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_payment_methods_customer_key ON payment_methods(customer_key);
+CREATE TABLE IF NOT EXISTS index_rates (
+	id TEXT PRIMARY KEY,
+	index_code TEXT NOT NULL,
+	rate TEXT NOT NULL,
+	observation_date DATETIME NOT NULL,
+	source TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_index_rates_code ON index_rates(index_code, observation_date);
+CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+	date DATETIME PRIMARY KEY,
+	outstanding_balance TEXT NOT NULL,
+	accrued_interest TEXT NOT NULL,
+	active_loans INTEGER NOT NULL,
+	delinquent_loans INTEGER NOT NULL,
+	delinquency_rate TEXT NOT NULL,
+	originations INTEGER NOT NULL,
+	origination_volume TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS interest_intents (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	cycle TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	transaction_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	completed_at DATETIME,
+	UNIQUE(loan_id, cycle)
+);
+CREATE INDEX IF NOT EXISTS idx_interest_intents_status ON interest_intents(status);
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	request_hash TEXT NOT NULL,
+	status_code INTEGER NOT NULL,
+	content_type TEXT NOT NULL,
+	body BLOB,
+	created_at DATETIME NOT NULL,
+	completed_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS payment_links (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	min_amount TEXT NOT NULL,
+	max_amount TEXT NOT NULL,
+	status TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	transaction_id TEXT,
+	created_at DATETIME NOT NULL,
+	redeemed_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+	id TEXT PRIMARY KEY,
+	url TEXT NOT NULL,
+	events TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	subscription_id TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at DATETIME NOT NULL,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at DATETIME NOT NULL,
+	delivered_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id);
+CREATE TABLE IF NOT EXISTS bureau_records (
+	loan_id TEXT NOT NULL,
+	period TEXT NOT NULL,
+	customer_key TEXT NOT NULL,
+	product_code TEXT NOT NULL DEFAULT '',
+	date_opened DATETIME NOT NULL,
+	original_amount TEXT NOT NULL,
+	current_balance TEXT NOT NULL,
+	term_months INTEGER NOT NULL DEFAULT 0,
+	account_status TEXT NOT NULL,
+	days_past_due INTEGER NOT NULL,
+	payment_history TEXT NOT NULL,
+	as_of DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (loan_id, period)
+);
+CREATE TABLE IF NOT EXISTS statements (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	cycle TEXT NOT NULL,
+	period_start DATETIME NOT NULL,
+	statement_date DATETIME NOT NULL,
+	balance TEXT NOT NULL,
+	interest_charged TEXT NOT NULL,
+	payments TEXT NOT NULL,
+	fees TEXT NOT NULL,
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest TEXT NOT NULL DEFAULT '0',
+	odd_days_policy TEXT NOT NULL DEFAULT '',
+	minimum_due TEXT NOT NULL DEFAULT '0',
+	due_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	UNIQUE (loan_id, cycle)
+);
+CREATE TABLE IF NOT EXISTS collateral (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL,
+	valuation TEXT NOT NULL,
+	valuation_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_collateral_loan_id ON collateral(loan_id);
+CREATE TABLE IF NOT EXISTS forbearances (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	start_date DATETIME NOT NULL,
+	end_date DATETIME NOT NULL,
+	rate TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_forbearances_loan_id ON forbearances(loan_id);
+CREATE TABLE IF NOT EXISTS accruals (
+	loan_id TEXT NOT NULL,
+	date DATETIME NOT NULL,
+	balance TEXT NOT NULL,
+	rate TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (loan_id, date)
+);
+CREATE TABLE IF NOT EXISTS autopay_enrollments (
+	loan_id TEXT PRIMARY KEY,
+	amount_type TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	day_of_month INTEGER NOT NULL,
+	payment_method_id TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS products (
+	code TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	accrue_after_charge_off INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest TEXT NOT NULL DEFAULT '',
+	minimum_payment_floor TEXT NOT NULL DEFAULT '0',
+	minimum_payment_percent TEXT NOT NULL DEFAULT '0',
+	accrual_grace_days INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS job_runs (
+	id TEXT PRIMARY KEY,
+	job TEXT NOT NULL,
+	triggered_by TEXT NOT NULL,
+	date DATETIME NOT NULL,
+	loan_id TEXT,
+	started_at DATETIME NOT NULL,
+	finished_at DATETIME NOT NULL,
+	processed INTEGER NOT NULL,
+	skipped INTEGER NOT NULL,
+	failed INTEGER NOT NULL,
+	errors TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job, started_at);
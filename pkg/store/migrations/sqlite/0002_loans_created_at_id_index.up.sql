@@ -0,0 +1,2 @@
+-- Keyset pagination of loans orders and seeks on creation time, then ID
+CREATE INDEX IF NOT EXISTS idx_loans_created_at_id ON loans(julianday(created_at), id);
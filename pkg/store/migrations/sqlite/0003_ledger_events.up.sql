@@ -0,0 +1,22 @@
+-- The append-only event log of event-sourced mode. It has no foreign key to loans, as
+-- archiving moves loans out of the table while their logs stay
+CREATE TABLE IF NOT EXISTS ledger_events (
+	id TEXT NOT NULL UNIQUE,
+	loan_id TEXT NOT NULL,
+	sequence_number INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	transaction_id TEXT,
+	balance_change TEXT NOT NULL,
+	accrued_interest_change TEXT NOT NULL,
+	interest_due_change TEXT NOT NULL,
+	fees_due_change TEXT NOT NULL,
+	escrow_balance_change TEXT NOT NULL,
+	balance TEXT NOT NULL,
+	accrued_interest TEXT NOT NULL,
+	interest_due TEXT NOT NULL,
+	fees_due TEXT NOT NULL,
+	escrow_balance TEXT NOT NULL,
+	occurred_at DATETIME NOT NULL,
+	PRIMARY KEY (loan_id, sequence_number)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE archived_transactions DROP COLUMN metadata;
+ALTER TABLE transactions DROP COLUMN metadata;
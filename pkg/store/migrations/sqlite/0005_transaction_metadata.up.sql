@@ -0,0 +1,4 @@
+-- Free-form details of a transaction, such as its payment channel or the operator who posted
+-- it, as a JSON object; NULL when it has none
+ALTER TABLE transactions ADD COLUMN metadata TEXT;
+ALTER TABLE archived_transactions ADD COLUMN metadata TEXT;
@@ -0,0 +1,123 @@
+-- Archive counterparts of the remaining per-loan tables, so that archiving moves all of a
+-- loan's records out of the hot tables, its event log included
+CREATE TABLE IF NOT EXISTS archived_statements (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	cycle TEXT NOT NULL,
+	period_start DATETIME NOT NULL,
+	statement_date DATETIME NOT NULL,
+	balance TEXT NOT NULL,
+	interest_charged TEXT NOT NULL,
+	payments TEXT NOT NULL,
+	fees TEXT NOT NULL,
+	odd_days INTEGER NOT NULL DEFAULT 0,
+	odd_days_interest TEXT NOT NULL DEFAULT '0',
+	odd_days_policy TEXT NOT NULL DEFAULT '',
+	minimum_due TEXT NOT NULL DEFAULT '0',
+	due_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_accruals (
+	loan_id TEXT NOT NULL,
+	date DATETIME NOT NULL,
+	balance TEXT NOT NULL,
+	rate TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (loan_id, date),
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_collateral (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL,
+	valuation TEXT NOT NULL,
+	valuation_date DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_forbearances (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	start_date DATETIME NOT NULL,
+	end_date DATETIME NOT NULL,
+	rate TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_autopay_enrollments (
+	loan_id TEXT PRIMARY KEY,
+	amount_type TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	day_of_month INTEGER NOT NULL,
+	payment_method_id TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_payment_links (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	min_amount TEXT NOT NULL,
+	max_amount TEXT NOT NULL,
+	status TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	transaction_id TEXT,
+	created_at DATETIME NOT NULL,
+	redeemed_at DATETIME,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_bureau_records (
+	loan_id TEXT NOT NULL,
+	period TEXT NOT NULL,
+	customer_key TEXT NOT NULL,
+	product_code TEXT NOT NULL DEFAULT '',
+	date_opened DATETIME NOT NULL,
+	original_amount TEXT NOT NULL,
+	current_balance TEXT NOT NULL,
+	term_months INTEGER NOT NULL DEFAULT 0,
+	account_status TEXT NOT NULL,
+	days_past_due INTEGER NOT NULL,
+	payment_history TEXT NOT NULL,
+	as_of DATETIME NOT NULL,
+	created_at DATETIME NOT NULL,
+	PRIMARY KEY (loan_id, period),
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_interest_intents (
+	id TEXT PRIMARY KEY,
+	loan_id TEXT NOT NULL,
+	cycle TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	transaction_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	created_at DATETIME NOT NULL,
+	completed_at DATETIME,
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
+CREATE TABLE IF NOT EXISTS archived_ledger_events (
+	id TEXT NOT NULL UNIQUE,
+	loan_id TEXT NOT NULL,
+	sequence_number INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	amount TEXT NOT NULL,
+	transaction_id TEXT,
+	balance_change TEXT NOT NULL,
+	accrued_interest_change TEXT NOT NULL,
+	interest_due_change TEXT NOT NULL,
+	fees_due_change TEXT NOT NULL,
+	escrow_balance_change TEXT NOT NULL,
+	balance TEXT NOT NULL,
+	accrued_interest TEXT NOT NULL,
+	interest_due TEXT NOT NULL,
+	fees_due TEXT NOT NULL,
+	escrow_balance TEXT NOT NULL,
+	occurred_at DATETIME NOT NULL,
+	PRIMARY KEY (loan_id, sequence_number),
+	FOREIGN KEY(loan_id) REFERENCES archived_loans(id)
+);
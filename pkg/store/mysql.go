@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQLStore manages the database connection and operations for MySQL and MariaDB. It shares
+// its queries with SQLiteStore, rewritten by mysqlDialect, but keeps amounts in DECIMAL and
+// timestamps in DATETIME columns, IDs as the 36-character text of their UUIDs, and takes
+// writers concurrently.
+type MySQLStore struct {
+	*sqlStore
+}
+
+// NewMySQLStore connects to MySQL or MariaDB with a go-sql-driver DSN, such as
+// user:password@tcp(host:3306)/fredloan, and migrates the database to the latest schema.
+// Timestamps are read and written in UTC whatever the DSN's loc, and an update that leaves a
+// row as it was still counts the row as affected, as it does on the other databases.
+func NewMySQLStore(dataSourceName string) (*MySQLStore, error) {
+	s, err := openMySQL(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.migrate(context.Background()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("could not initialize schema: %w", err)
+	}
+	return s, nil
+}
+
+// openMySQL connects to a MySQL or MariaDB database without touching its schema.
+func openMySQL(dataSourceName string) (*MySQLStore, error) {
+	cfg, err := mysql.ParseDSN(dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse data source: %w", err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.ClientFoundRows = true
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+	db := sql.OpenDB(connector)
+	if err := db.PingContext(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not connect to database: %w", err)
+	}
+
+	return &MySQLStore{&sqlStore{db: &sqlDB{DB: db, dialect: mysqlDialect}, migrations: mysqlMigrations}}, nil
+}
+
+// mysqlMigrations builds the schema from migrations/mysql. MySQL commits each DDL statement as
+// it runs, so a migration's statements are not run in a transaction, and one that fails
+// part-way must be finished or undone by hand. Tables are created with ENGINE=InnoDB DEFAULT
+// CHARSET=utf8mb4 COLLATE=utf8mb4_bin: the binary collation compares text by its bytes, as
+// SQLite does, so customer keys and prefix searches stay case-sensitive. The archive tables
+// are copies of their hot counterparts' columns; a migration that adds a column to a table
+// must add it to both.
+var mysqlMigrations = &migrations{
+	dir: "migrations/mysql",
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at DATETIME(6) NOT NULL
+	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_bin`,
+	tableExists: `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = ?`,
+	// Concurrent servers starting on an empty database would race to create the same tables.
+	// The lock belongs to the session, so it is taken and released on one connection.
+	lock: func(ctx context.Context, conn *sql.Conn) error {
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK('fredloan_schema', 60)`).Scan(&locked); err != nil {
+			return err
+		}
+		if locked.Int64 != 1 {
+			return errors.New("timed out")
+		}
+		return nil
+	},
+	unlock: `SELECT RELEASE_LOCK('fredloan_schema')`,
+}
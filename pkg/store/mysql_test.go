@@ -0,0 +1,160 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestMySQLDialect(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{`SELECT id FROM loans WHERE id = ?`, `SELECT id FROM loans WHERE id = ?`},
+		{
+			`SELECT id FROM loans WHERE julianday(created_at) >= julianday(?) AND CAST(balance AS REAL) >= ? ORDER BY julianday(created_at) ASC`,
+			`SELECT id FROM loans WHERE created_at >= ? AND balance >= ? ORDER BY created_at ASC`,
+		},
+		{`SELECT id FROM transactions WHERE loan_id = ? LIMIT -1 OFFSET ?`, `SELECT id FROM transactions WHERE loan_id = ? LIMIT 18446744073709551615 OFFSET ?`},
+		{`SELECT id FROM transactions WHERE json_extract(metadata, ?) = ?`, `SELECT id FROM transactions WHERE JSON_UNQUOTE(JSON_EXTRACT(metadata, ?)) = ?`},
+		{
+			`INSERT INTO idempotency_keys (key, request_hash) VALUES (?, ?) ON CONFLICT (key) DO NOTHING`,
+			"INSERT IGNORE INTO idempotency_keys (`key`, request_hash) VALUES (?, ?)",
+		},
+		{`INSERT INTO customers (id, customer_key) VALUES (?, ?) ON CONFLICT(customer_key) DO NOTHING`, `INSERT IGNORE INTO customers (id, customer_key) VALUES (?, ?)`},
+		{upsert("accruals", accrualColumns, "loan_id", "date"), `INSERT INTO accruals (loan_id, date, balance, rate, amount, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE balance = VALUES(balance), rate = VALUES(rate), amount = VALUES(amount), created_at = VALUES(created_at)`},
+	}
+	for _, tt := range tests {
+		if got := mysqlDialect(tt.query); got != tt.expected {
+			t.Errorf("mysqlDialect(%q):\nexpected %q\ngot      %q", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestRedactDataSource(t *testing.T) {
+	tests := []struct {
+		driver   string
+		dsn      string
+		expected string
+	}{
+		{DriverSQLite, "fredloan.db", "fredloan.db"},
+		{DriverSQLite, "fredloan.db?_busy_timeout=5000&_key=secret", "fredloan.db?_busy_timeout=5000&_key=xxxxx"},
+		{DriverPostgres, "postgres://fredloan:secret@db:5432/fredloan", "postgres://fredloan:xxxxx@db:5432/fredloan"},
+		{DriverMySQL, "fredloan:secret@tcp(db:3306)/fredloan", "fredloan:xxxxx@tcp(db:3306)/fredloan"},
+		{DriverMySQL, "fredloan@tcp(db:3306)/fredloan", "fredloan@tcp(db:3306)/fredloan"},
+	}
+	for _, tt := range tests {
+		if got := RedactDataSource(tt.driver, tt.dsn); got != tt.expected {
+			t.Errorf("RedactDataSource(%q, %q): expected %q, got %q", tt.driver, tt.dsn, tt.expected, got)
+		}
+	}
+}
+
+// TestMySQLStore runs against the database in FREDLOAN_TEST_MYSQL_DSN, such as
+// root@tcp(localhost:3306)/fredloan_test, and is skipped without one.
+func TestMySQLStore(t *testing.T) {
+	dsn := os.Getenv("FREDLOAN_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("FREDLOAN_TEST_MYSQL_DSN not set")
+	}
+	ctx := context.Background()
+
+	s, err := NewMySQLStore(dsn)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	customerKey := "cust_my_" + uuid.NewString()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	loan := &models.Loan{
+		ID:                uuid.New(),
+		CustomerKey:       customerKey,
+		Principal:         decimal.RequireFromString("2500.50"),
+		Balance:           decimal.RequireFromString("2500.50"),
+		BaseInterestRate:  decimal.RequireFromString("0.0725"),
+		InterestRate:      decimal.RequireFromString("0.0725"),
+		Status:            models.LoanStatusActive,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		StatementCycleDay: 15,
+		EscrowEnabled:     true,
+		Version:           1,
+	}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+
+	fetched, err := s.GetLoan(ctx, loan.ID)
+	if err != nil {
+		t.Fatalf("Failed to get loan: %v", err)
+	}
+	if !fetched.Balance.Equal(loan.Balance) || !fetched.InterestRate.Equal(loan.InterestRate) || !fetched.EscrowEnabled || !fetched.CreatedAt.Equal(now) {
+		t.Errorf("Expected the stored loan back, got %+v", fetched)
+	}
+
+	fetched.Balance = decimal.RequireFromString("2400.25")
+	if err := s.UpdateLoanIfVersion(ctx, fetched, 1); err != nil || fetched.Version != 2 {
+		t.Fatalf("Expected the update to move the loan to version 2, got %d (%v)", fetched.Version, err)
+	}
+	if err := s.UpdateLoanIfVersion(ctx, fetched, 1); err != models.ErrLoanVersionMismatch {
+		t.Errorf("Expected ErrLoanVersionMismatch for a stale version, got %v", err)
+	}
+
+	minBalance := decimal.NewFromInt(2000)
+	loans, total, err := s.SearchLoans(ctx, models.LoanSearch{CustomerKeyPrefix: customerKey, MinBalance: &minBalance, Sort: models.LoanSortBalanceDesc, Limit: 10})
+	if err != nil || total != 1 || len(loans) != 1 || loans[0].ID != loan.ID {
+		t.Errorf("Expected the search to find the loan, got %d of %d (%v)", len(loans), total, err)
+	}
+
+	for i, amount := range []string{"100.10", "-25.00"} {
+		tx := &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.RequireFromString(amount), Type: models.TransactionTypePayment, Timestamp: now.Add(time.Duration(i) * time.Minute)}
+		if i == 0 {
+			tx.Metadata = map[string]string{"channel": "branch"}
+		}
+		if err := s.CreateTransaction(ctx, tx); err != nil {
+			t.Fatalf("Failed to create transaction: %v", err)
+		}
+	}
+	minAmount := decimal.Zero
+	transactions, total, err := s.QueryTransactions(ctx, loan.ID, models.TransactionQuery{MinAmount: &minAmount})
+	if err != nil || total != 1 || len(transactions) != 1 || !transactions[0].Amount.Equal(decimal.RequireFromString("100.10")) {
+		t.Errorf("Expected the one positive transaction, got %d of %d (%v)", len(transactions), total, err)
+	}
+	transactions, total, err = s.QueryTransactions(ctx, loan.ID, models.TransactionQuery{Metadata: map[string]string{"channel": "branch"}})
+	if err != nil || total != 1 || len(transactions) != 1 || transactions[0].Metadata["channel"] != "branch" {
+		t.Errorf("Expected the transaction from the branch, got %d of %d (%v)", len(transactions), total, err)
+	}
+
+	summary, err := s.GetCustomerSummary(ctx, customerKey)
+	if err != nil || summary.OpenLoanCount != 1 || !summary.OutstandingBalance.Equal(decimal.RequireFromString("2400.25")) {
+		t.Errorf("Expected a summary of the open loan, got %+v (%v)", summary, err)
+	}
+
+	// Saving an accrual again for the same day replaces it
+	day := now.Truncate(24 * time.Hour)
+	for _, amount := range []string{"0.50", "0.48"} {
+		if err := s.SaveAccrual(ctx, &models.Accrual{LoanID: loan.ID, Date: day, Balance: fetched.Balance, Rate: fetched.InterestRate, Amount: decimal.RequireFromString(amount), CreatedAt: now}); err != nil {
+			t.Fatalf("Failed to save accrual: %v", err)
+		}
+	}
+	accruals, err := s.GetAccrualsForLoan(ctx, loan.ID, day, day)
+	if err != nil || len(accruals) != 1 || !accruals[0].Amount.Equal(decimal.RequireFromString("0.48")) {
+		t.Errorf("Expected the replaced accrual, got %+v (%v)", accruals, err)
+	}
+
+	record := &models.IdempotencyRecord{Key: "my_" + uuid.NewString(), RequestHash: "hash", CreatedAt: now}
+	if err := s.CreateIdempotencyRecord(ctx, record); err != nil {
+		t.Fatalf("Failed to create idempotency record: %v", err)
+	}
+	if err := s.CreateIdempotencyRecord(ctx, record); err != models.ErrIdempotencyKeyExists {
+		t.Errorf("Expected ErrIdempotencyKeyExists for a claimed key, got %v", err)
+	}
+}
@@ -0,0 +1,748 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+var _ Storage = (*RetryingStore)(nil)
+
+// Backoff between retries of a busy call: the first waits up to retryBaseDelay, each after it
+// up to twice as long as the one before, and none longer than retryMaxDelay.
+const (
+	retryBaseDelay = 20 * time.Millisecond
+	retryMaxDelay  = time.Second
+)
+
+// RetryingStore decorates a Storage so that calls SQLite turns away as busy, because another
+// connection holds the write lock, are retried with backoff rather than failing. The busy
+// timeout makes a connection wait for the lock before giving up, but SQLite fails at once
+// when waiting could not help, as when a transaction's snapshot is older than a write
+// committed since; a retry starts over from a fresh one.
+//
+// A transaction is retried as a whole, fn and all, so fn must not act outside the store it is
+// given. Calls fn makes through that store are not retried on their own. ForEachActiveLoan is
+// never retried, as fn may already have acted on some loans when it fails.
+type RetryingStore struct {
+	inner    Storage
+	attempts int
+}
+
+// NewRetryingStore wraps s so that busy calls are tried up to retries more times. Errors other
+// than busy ones are returned at once.
+func NewRetryingStore(s Storage, retries int) *RetryingStore {
+	return &RetryingStore{inner: s, attempts: retries + 1}
+}
+
+// retry calls fn until it succeeds, fails with an error other than busy, runs out of
+// attempts or ctx is done, waiting a random part of a doubling delay between attempts.
+func (s *RetryingStore) retry(ctx context.Context, method string, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !sqliteBusy(err) || attempt >= s.attempts {
+			return err
+		}
+		wait := rand.N(delay) + 1
+		slog.Debug("Retrying busy store call", "method", method, "attempt", attempt, "wait", wait)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// Close closes the underlying store.
+func (s *RetryingStore) Close() error {
+	return s.inner.Close()
+}
+
+// InTransaction retries the whole transaction when it is busy, handing fn the underlying
+// store's transaction each time.
+func (s *RetryingStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	return s.retry(ctx, "InTransaction", func() error {
+		return s.inner.InTransaction(ctx, fn)
+	})
+}
+
+// ForEachActiveLoan is passed through without retries.
+func (s *RetryingStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	return s.inner.ForEachActiveLoan(ctx, fn)
+}
+
+// Storage methods below are retried.
+
+func (s *RetryingStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	return s.retry(ctx, "CreateLoan", func() error {
+		return s.inner.CreateLoan(ctx, loan)
+	})
+}
+
+func (s *RetryingStore) GetLoan(ctx context.Context, id uuid.UUID) (result *models.Loan, err error) {
+	err = s.retry(ctx, "GetLoan", func() error {
+		result, err = s.inner.GetLoan(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	return s.retry(ctx, "UpdateLoan", func() error {
+		return s.inner.UpdateLoan(ctx, loan)
+	})
+}
+
+func (s *RetryingStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	return s.retry(ctx, "UpdateLoanIfVersion", func() error {
+		return s.inner.UpdateLoanIfVersion(ctx, loan, version)
+	})
+}
+
+func (s *RetryingStore) UpdateLoans(ctx context.Context, loans []*models.Loan) (result []uuid.UUID, err error) {
+	err = s.retry(ctx, "UpdateLoans", func() error {
+		result, err = s.inner.UpdateLoans(ctx, loans)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetAllLoans(ctx context.Context) (result []*models.Loan, err error) {
+	err = s.retry(ctx, "GetAllLoans", func() error {
+		result, err = s.inner.GetAllLoans(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) ListLoans(ctx context.Context, query models.LoanQuery) (result []*models.Loan, count int, err error) {
+	err = s.retry(ctx, "ListLoans", func() error {
+		result, count, err = s.inner.ListLoans(ctx, query)
+		return err
+	})
+	return result, count, err
+}
+
+func (s *RetryingStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) (result []*models.Loan, next *models.LoanCursor, err error) {
+	err = s.retry(ctx, "ListLoansAfter", func() error {
+		result, next, err = s.inner.ListLoansAfter(ctx, query, cursor, limit)
+		return err
+	})
+	return result, next, err
+}
+
+func (s *RetryingStore) SearchLoans(ctx context.Context, search models.LoanSearch) (result []*models.Loan, count int, err error) {
+	err = s.retry(ctx, "SearchLoans", func() error {
+		result, count, err = s.inner.SearchLoans(ctx, search)
+		return err
+	})
+	return result, count, err
+}
+
+func (s *RetryingStore) GetAllActiveLoans(ctx context.Context) (result []*models.Loan, err error) {
+	err = s.retry(ctx, "GetAllActiveLoans", func() error {
+		result, err = s.inner.GetAllActiveLoans(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) (result []*models.Loan, err error) {
+	err = s.retry(ctx, "GetLoansByStatus", func() error {
+		result, err = s.inner.GetLoansByStatus(ctx, status)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) (result []*models.Loan, err error) {
+	err = s.retry(ctx, "GetLoansByCustomerKey", func() error {
+		result, err = s.inner.GetLoansByCustomerKey(ctx, customerKey)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetCustomerSummary(ctx context.Context, customerKey string) (result *models.CustomerSummary, err error) {
+	err = s.retry(ctx, "GetCustomerSummary", func() error {
+		result, err = s.inner.GetCustomerSummary(ctx, customerKey)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) (result []*models.Loan, err error) {
+	err = s.retry(ctx, "GetDelinquentLoans", func() error {
+		result, err = s.inner.GetDelinquentLoans(ctx, minDaysPastDue)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return s.retry(ctx, "CreateTransaction", func() error {
+		return s.inner.CreateTransaction(ctx, transaction)
+	})
+}
+
+func (s *RetryingStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.Transaction, err error) {
+	err = s.retry(ctx, "GetTransactionsForLoan", func() error {
+		result, err = s.inner.GetTransactionsForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) (result []*models.Transaction, count int, err error) {
+	err = s.retry(ctx, "QueryTransactions", func() error {
+		result, count, err = s.inner.QueryTransactions(ctx, loanID, query)
+		return err
+	})
+	return result, count, err
+}
+
+func (s *RetryingStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	return s.retry(ctx, "CreateLoanEvent", func() error {
+		return s.inner.CreateLoanEvent(ctx, event)
+	})
+}
+
+func (s *RetryingStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.LoanEvent, err error) {
+	err = s.retry(ctx, "GetLoanEventsForLoan", func() error {
+		result, err = s.inner.GetLoanEventsForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	return s.retry(ctx, "CreateRateChange", func() error {
+		return s.inner.CreateRateChange(ctx, change)
+	})
+}
+
+func (s *RetryingStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) (result []*models.RateChange, err error) {
+	err = s.retry(ctx, "GetRateHistory", func() error {
+		result, err = s.inner.GetRateHistory(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (result *models.RateChange, err error) {
+	err = s.retry(ctx, "GetRateInEffect", func() error {
+		result, err = s.inner.GetRateInEffect(ctx, loanID, date)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	return s.retry(ctx, "CreateIndexRate", func() error {
+		return s.inner.CreateIndexRate(ctx, rate)
+	})
+}
+
+func (s *RetryingStore) GetLatestIndexRate(ctx context.Context, indexCode string) (result *models.IndexRate, err error) {
+	err = s.retry(ctx, "GetLatestIndexRate", func() error {
+		result, err = s.inner.GetLatestIndexRate(ctx, indexCode)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetIndexRates(ctx context.Context, indexCode string) (result []*models.IndexRate, err error) {
+	err = s.retry(ctx, "GetIndexRates", func() error {
+		result, err = s.inner.GetIndexRates(ctx, indexCode)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	return s.retry(ctx, "SavePortfolioSnapshot", func() error {
+		return s.inner.SavePortfolioSnapshot(ctx, snapshot)
+	})
+}
+
+func (s *RetryingStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) (result []*models.PortfolioSnapshot, err error) {
+	err = s.retry(ctx, "GetPortfolioSnapshots", func() error {
+		result, err = s.inner.GetPortfolioSnapshots(ctx, from, to)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	return s.retry(ctx, "CreateInterestIntent", func() error {
+		return s.inner.CreateInterestIntent(ctx, intent)
+	})
+}
+
+func (s *RetryingStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	return s.retry(ctx, "UpdateInterestIntent", func() error {
+		return s.inner.UpdateInterestIntent(ctx, intent)
+	})
+}
+
+func (s *RetryingStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (result *models.InterestIntent, err error) {
+	err = s.retry(ctx, "GetInterestIntent", func() error {
+		result, err = s.inner.GetInterestIntent(ctx, loanID, cycle)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) (result []*models.InterestIntent, err error) {
+	err = s.retry(ctx, "GetInterestIntentsByStatus", func() error {
+		result, err = s.inner.GetInterestIntentsByStatus(ctx, status)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) (result []*models.InterestIntent, err error) {
+	err = s.retry(ctx, "GetInterestIntentsForCycle", func() error {
+		result, err = s.inner.GetInterestIntentsForCycle(ctx, cycle)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	return s.retry(ctx, "CreateIdempotencyRecord", func() error {
+		return s.inner.CreateIdempotencyRecord(ctx, record)
+	})
+}
+
+func (s *RetryingStore) GetIdempotencyRecord(ctx context.Context, key string) (result *models.IdempotencyRecord, err error) {
+	err = s.retry(ctx, "GetIdempotencyRecord", func() error {
+		result, err = s.inner.GetIdempotencyRecord(ctx, key)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	return s.retry(ctx, "UpdateIdempotencyRecord", func() error {
+		return s.inner.UpdateIdempotencyRecord(ctx, record)
+	})
+}
+
+func (s *RetryingStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	return s.retry(ctx, "DeleteIdempotencyRecord", func() error {
+		return s.inner.DeleteIdempotencyRecord(ctx, key)
+	})
+}
+
+func (s *RetryingStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	return s.retry(ctx, "CreateStatement", func() error {
+		return s.inner.CreateStatement(ctx, statement)
+	})
+}
+
+func (s *RetryingStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (result *models.Statement, err error) {
+	err = s.retry(ctx, "GetStatement", func() error {
+		result, err = s.inner.GetStatement(ctx, loanID, cycle)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.Statement, err error) {
+	err = s.retry(ctx, "GetStatementsForLoan", func() error {
+		result, err = s.inner.GetStatementsForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	return s.retry(ctx, "SaveAutopayEnrollment", func() error {
+		return s.inner.SaveAutopayEnrollment(ctx, enrollment)
+	})
+}
+
+func (s *RetryingStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (result *models.AutopayEnrollment, err error) {
+	err = s.retry(ctx, "GetAutopayEnrollment", func() error {
+		result, err = s.inner.GetAutopayEnrollment(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	return s.retry(ctx, "DeleteAutopayEnrollment", func() error {
+		return s.inner.DeleteAutopayEnrollment(ctx, loanID)
+	})
+}
+
+func (s *RetryingStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) (result []*models.AutopayEnrollment, err error) {
+	err = s.retry(ctx, "GetAutopayEnrollmentsForDay", func() error {
+		result, err = s.inner.GetAutopayEnrollmentsForDay(ctx, day)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	return s.retry(ctx, "CreateCollateral", func() error {
+		return s.inner.CreateCollateral(ctx, collateral)
+	})
+}
+
+func (s *RetryingStore) GetCollateral(ctx context.Context, id uuid.UUID) (result *models.Collateral, err error) {
+	err = s.retry(ctx, "GetCollateral", func() error {
+		result, err = s.inner.GetCollateral(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	return s.retry(ctx, "UpdateCollateral", func() error {
+		return s.inner.UpdateCollateral(ctx, collateral)
+	})
+}
+
+func (s *RetryingStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	return s.retry(ctx, "DeleteCollateral", func() error {
+		return s.inner.DeleteCollateral(ctx, id)
+	})
+}
+
+func (s *RetryingStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.Collateral, err error) {
+	err = s.retry(ctx, "GetCollateralForLoan", func() error {
+		result, err = s.inner.GetCollateralForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	return s.retry(ctx, "CreateForbearance", func() error {
+		return s.inner.CreateForbearance(ctx, forbearance)
+	})
+}
+
+func (s *RetryingStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.Forbearance, err error) {
+	err = s.retry(ctx, "GetForbearancesForLoan", func() error {
+		result, err = s.inner.GetForbearancesForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	return s.retry(ctx, "SaveAccrual", func() error {
+		return s.inner.SaveAccrual(ctx, accrual)
+	})
+}
+
+func (s *RetryingStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) (result []*models.Accrual, err error) {
+	err = s.retry(ctx, "GetAccrualsForLoan", func() error {
+		result, err = s.inner.GetAccrualsForLoan(ctx, loanID, from, to)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	return s.retry(ctx, "AppendLedgerEvent", func() error {
+		return s.inner.AppendLedgerEvent(ctx, event)
+	})
+}
+
+func (s *RetryingStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) (result []*models.LedgerEvent, err error) {
+	err = s.retry(ctx, "GetLedgerEvents", func() error {
+		result, err = s.inner.GetLedgerEvents(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	return s.retry(ctx, "SaveBureauRecord", func() error {
+		return s.inner.SaveBureauRecord(ctx, record)
+	})
+}
+
+func (s *RetryingStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.BureauRecord, err error) {
+	err = s.retry(ctx, "GetBureauRecordsForLoan", func() error {
+		result, err = s.inner.GetBureauRecordsForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (result int, err error) {
+	err = s.retry(ctx, "ArchiveClosedLoans", func() error {
+		result, err = s.inner.ArchiveClosedLoans(ctx, closedBefore)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	return s.retry(ctx, "MarkArchivedLoansExported", func() error {
+		return s.inner.MarkArchivedLoansExported(ctx, ids, exportedAt)
+	})
+}
+
+func (s *RetryingStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	return s.retry(ctx, "DeleteArchivedLoans", func() error {
+		return s.inner.DeleteArchivedLoans(ctx, ids)
+	})
+}
+
+func (s *RetryingStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (result *models.Loan, err error) {
+	err = s.retry(ctx, "GetArchivedLoan", func() error {
+		result, err = s.inner.GetArchivedLoan(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.Transaction, err error) {
+	err = s.retry(ctx, "GetArchivedTransactionsForLoan", func() error {
+		result, err = s.inner.GetArchivedTransactionsForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) (result []*models.LoanEvent, err error) {
+	err = s.retry(ctx, "GetArchivedLoanEventsForLoan", func() error {
+		result, err = s.inner.GetArchivedLoanEventsForLoan(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) (result []*models.RateChange, err error) {
+	err = s.retry(ctx, "GetArchivedRateHistory", func() error {
+		result, err = s.inner.GetArchivedRateHistory(ctx, loanID)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) (result []*models.Loan, err error) {
+	err = s.retry(ctx, "GetArchivedLoansToExport", func() error {
+		result, err = s.inner.GetArchivedLoansToExport(ctx, closedBefore, limit)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	return s.retry(ctx, "CreatePaymentMethod", func() error {
+		return s.inner.CreatePaymentMethod(ctx, method)
+	})
+}
+
+func (s *RetryingStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (result *models.PaymentMethod, err error) {
+	err = s.retry(ctx, "GetPaymentMethod", func() error {
+		result, err = s.inner.GetPaymentMethod(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	return s.retry(ctx, "UpdatePaymentMethod", func() error {
+		return s.inner.UpdatePaymentMethod(ctx, method)
+	})
+}
+
+func (s *RetryingStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) (result []*models.PaymentMethod, err error) {
+	err = s.retry(ctx, "GetPaymentMethodsForCustomer", func() error {
+		result, err = s.inner.GetPaymentMethodsForCustomer(ctx, customerKey)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return s.retry(ctx, "CreatePaymentLink", func() error {
+		return s.inner.CreatePaymentLink(ctx, link)
+	})
+}
+
+func (s *RetryingStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (result *models.PaymentLink, err error) {
+	err = s.retry(ctx, "GetPaymentLink", func() error {
+		result, err = s.inner.GetPaymentLink(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return s.retry(ctx, "UpdatePaymentLink", func() error {
+		return s.inner.UpdatePaymentLink(ctx, link)
+	})
+}
+
+func (s *RetryingStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	return s.retry(ctx, "RedeemPaymentLink", func() error {
+		return s.inner.RedeemPaymentLink(ctx, link)
+	})
+}
+
+func (s *RetryingStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	return s.retry(ctx, "CreateWebhookSubscription", func() error {
+		return s.inner.CreateWebhookSubscription(ctx, subscription)
+	})
+}
+
+func (s *RetryingStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (result *models.WebhookSubscription, err error) {
+	err = s.retry(ctx, "GetWebhookSubscription", func() error {
+		result, err = s.inner.GetWebhookSubscription(ctx, id)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetWebhookSubscriptions(ctx context.Context) (result []*models.WebhookSubscription, err error) {
+	err = s.retry(ctx, "GetWebhookSubscriptions", func() error {
+		result, err = s.inner.GetWebhookSubscriptions(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	return s.retry(ctx, "DeleteWebhookSubscription", func() error {
+		return s.inner.DeleteWebhookSubscription(ctx, id)
+	})
+}
+
+func (s *RetryingStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return s.retry(ctx, "CreateWebhookDelivery", func() error {
+		return s.inner.CreateWebhookDelivery(ctx, delivery)
+	})
+}
+
+func (s *RetryingStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return s.retry(ctx, "UpdateWebhookDelivery", func() error {
+		return s.inner.UpdateWebhookDelivery(ctx, delivery)
+	})
+}
+
+func (s *RetryingStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) (result []*models.WebhookDelivery, err error) {
+	err = s.retry(ctx, "GetDueWebhookDeliveries", func() error {
+		result, err = s.inner.GetDueWebhookDeliveries(ctx, at, limit)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) (result []*models.WebhookDelivery, err error) {
+	err = s.retry(ctx, "GetWebhookDeliveriesForSubscription", func() error {
+		result, err = s.inner.GetWebhookDeliveriesForSubscription(ctx, subscriptionID, limit)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	return s.retry(ctx, "CreateOutboxEvent", func() error {
+		return s.inner.CreateOutboxEvent(ctx, event)
+	})
+}
+
+func (s *RetryingStore) GetPendingOutboxEvents(ctx context.Context, limit int) (result []*models.OutboxEvent, err error) {
+	err = s.retry(ctx, "GetPendingOutboxEvents", func() error {
+		result, err = s.inner.GetPendingOutboxEvents(ctx, limit)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return s.retry(ctx, "MarkOutboxEventPublished", func() error {
+		return s.inner.MarkOutboxEventPublished(ctx, id, at)
+	})
+}
+
+func (s *RetryingStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	return s.retry(ctx, "CreateProduct", func() error {
+		return s.inner.CreateProduct(ctx, product)
+	})
+}
+
+func (s *RetryingStore) GetProduct(ctx context.Context, code string) (result *models.Product, err error) {
+	err = s.retry(ctx, "GetProduct", func() error {
+		result, err = s.inner.GetProduct(ctx, code)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	return s.retry(ctx, "UpdateProduct", func() error {
+		return s.inner.UpdateProduct(ctx, product)
+	})
+}
+
+func (s *RetryingStore) GetAllProducts(ctx context.Context) (result []*models.Product, err error) {
+	err = s.retry(ctx, "GetAllProducts", func() error {
+		result, err = s.inner.GetAllProducts(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	return s.retry(ctx, "CreateCustomer", func() error {
+		return s.inner.CreateCustomer(ctx, customer)
+	})
+}
+
+func (s *RetryingStore) GetCustomer(ctx context.Context, customerKey string) (result *models.Customer, err error) {
+	err = s.retry(ctx, "GetCustomer", func() error {
+		result, err = s.inner.GetCustomer(ctx, customerKey)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	return s.retry(ctx, "UpdateCustomer", func() error {
+		return s.inner.UpdateCustomer(ctx, customer)
+	})
+}
+
+func (s *RetryingStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	return s.retry(ctx, "DeleteCustomer", func() error {
+		return s.inner.DeleteCustomer(ctx, customerKey)
+	})
+}
+
+func (s *RetryingStore) GetAllCustomers(ctx context.Context) (result []*models.Customer, err error) {
+	err = s.retry(ctx, "GetAllCustomers", func() error {
+		result, err = s.inner.GetAllCustomers(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (s *RetryingStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	return s.retry(ctx, "CreateJobRun", func() error {
+		return s.inner.CreateJobRun(ctx, run)
+	})
+}
+
+func (s *RetryingStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) (result []*models.JobRun, err error) {
+	err = s.retry(ctx, "GetJobRuns", func() error {
+		result, err = s.inner.GetJobRuns(ctx, job, limit)
+		return err
+	})
+	return result, err
+}
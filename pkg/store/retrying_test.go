@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+// busyStore fails its first busy calls to GetLoan and InTransaction as SQLite does when
+// another connection holds the write lock.
+type busyStore struct {
+	*MemoryStore
+	busy  int
+	calls int
+}
+
+func (s *busyStore) fail() error {
+	s.calls++
+	if s.calls <= s.busy {
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	}
+	return nil
+}
+
+func (s *busyStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.MemoryStore.GetLoan(ctx, id)
+}
+
+func (s *busyStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	return s.MemoryStore.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		return s.fail() // As at commit, after fn has run
+	})
+}
+
+func TestRetryingStore(t *testing.T) {
+	ctx := context.Background()
+	inner := &busyStore{MemoryStore: NewMemoryStore(), busy: 2}
+	s := NewRetryingStore(inner, 3)
+
+	loan := newFaultTestLoan()
+	runs := 0
+	err := s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		runs++
+		return tx.CreateLoan(ctx, loan)
+	})
+	if err != nil {
+		t.Fatalf("Expected the transaction to succeed once no longer busy, got %v", err)
+	}
+	if runs != 3 {
+		t.Errorf("Expected the transaction to run 3 times, ran %d", runs)
+	}
+
+	inner.calls, inner.busy = 0, 4
+	if _, err := s.GetLoan(ctx, loan.ID); !sqliteBusy(err) {
+		t.Errorf("Expected busy once retries run out, got %v", err)
+	}
+	if inner.calls != 4 {
+		t.Errorf("Expected 4 attempts, got %d", inner.calls)
+	}
+
+	inner.calls, inner.busy = 0, 1
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.GetLoan(canceled, loan.ID); !sqliteBusy(err) || inner.calls != 1 {
+		t.Errorf("Expected a canceled call not to be retried, got %v after %d attempts", err, inner.calls)
+	}
+
+	inner.calls, inner.busy = 0, 0
+	if _, err := s.GetLoan(ctx, uuid.New()); err == nil || inner.calls != 1 {
+		t.Errorf("Expected other errors not to be retried, got %v after %d attempts", err, inner.calls)
+	}
+}
+
+func TestRetryingStore_SQLiteConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_retrying.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	dsn, _ := WithSQLiteTuning(DriverSQLite, dbFile, SQLiteTuning{BusyTimeout: 50 * time.Millisecond})
+	sqlite, err := NewSQLiteStore(dsn)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	s := NewRetryingStore(sqlite, 10)
+	defer s.Close()
+
+	loan := newFaultTestLoan()
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each writer reads the loan and writes it back, which fails as busy for all but one of
+	// any writers that read at once were their transactions not retried
+	const writers, writes = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*writes)
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range writes {
+				errs <- s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+					current, err := tx.GetLoan(ctx, loan.ID)
+					if err != nil {
+						return err
+					}
+					current.Balance = current.Balance.Add(decimal.NewFromInt(1))
+					return tx.UpdateLoan(ctx, current)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected every write to succeed, got %v", err)
+		}
+	}
+
+	stored, err := s.GetLoan(ctx, loan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := loan.Balance.Add(decimal.NewFromInt(writers * writes)); !stored.Balance.Equal(expected) {
+		t.Errorf("Expected balance %s after every write, got %s", expected, stored.Balance)
+	}
+}
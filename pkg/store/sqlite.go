@@ -1,140 +1,233 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
-	"log"
+	"maps"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
 
-	_ "github.com/mattn/go-sqlite3"
+var (
+	_ Storage = (*SQLiteStore)(nil)
+	_ Storage = (*PostgresStore)(nil)
+	_ Storage = (*MySQLStore)(nil)
 )
 
+// sqlStore implements Storage on a database/sql connection. Its queries are written for
+// SQLite; the connection's dialect adapts them to the database it is open on, and its
+// migrations build the database's schema.
+type sqlStore struct {
+	db         *sqlDB
+	migrations *migrations
+}
+
 // SQLiteStore manages the database connection and operations for SQLite.
 type SQLiteStore struct {
-	db *sql.DB
+	*sqlStore
+	key string // SQLCipher key the database is encrypted with; empty when it is not
 }
 
-// NewSQLiteStore creates a new SQLiteStore and initializes the database.
+// NewSQLiteStore creates a new SQLiteStore and migrates the database to the latest schema.
 func NewSQLiteStore(dataSourceName string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite3", dataSourceName)
+	s, err := openSQLite(dataSourceName)
 	if err != nil {
-		return nil, fmt.Errorf("could not open database: %w", err)
+		return nil, err
+	}
+	if err := s.migrate(context.Background()); err != nil {
+		s.Close()
+		return nil, fmt.Errorf("could not initialize schema: %w", err)
+	}
+	return s, nil
+}
+
+// openSQLite connects to a SQLite database without touching its schema. A data source with
+// an encryption key (see WithEncryptionKey) opens the database with SQLCipher.
+func openSQLite(dataSourceName string) (*SQLiteStore, error) {
+	ctx := context.Background()
+
+	dataSourceName, key := splitSQLiteKey(dataSourceName)
+	// Transactions take the write lock as they begin, where the busy timeout waits for it,
+	// rather than at their first write, where SQLite fails at once if another connection has
+	// written since the transaction first read
+	if dsn, params := splitSQLiteParams(dataSourceName); !params.Has("_txlock") {
+		params.Set("_txlock", "immediate")
+		dataSourceName = dsn + "?" + params.Encode()
+	}
+	db := openSQLiteDB(dataSourceName, key)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not connect to database: %w", err)
 	}
 
 	// Manually enable foreign keys and WAL mode
-	_, err = db.Exec("PRAGMA foreign_keys = ON;")
+	_, err := db.ExecContext(ctx, "PRAGMA foreign_keys = ON;")
 	if err != nil {
 		return nil, fmt.Errorf("failed to enable foreign keys: %w", err)
 	}
-	_, err = db.Exec("PRAGMA journal_mode = WAL;")
+	_, err = db.ExecContext(ctx, "PRAGMA journal_mode = WAL;")
 	if err != nil {
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
-
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("could not connect to database: %w", err)
-	}
-
-	s := &SQLiteStore{db: db}
-	if err := s.initSchema(); err != nil {
-		return nil, fmt.Errorf("could not initialize schema: %w", err)
-	}
-	log.Println("Database connection established and schema initialized.")
-	return s, nil
+	return &SQLiteStore{sqlStore: &sqlStore{db: &sqlDB{DB: db}, migrations: sqliteMigrations}, key: key}, nil
 }
 
-// initSchema creates the database tables if they don't already exist and adds new columns if necessary.
-// We use TEXT for decimal fields in SQLite to ensure no precision is lost.
-func (s *SQLiteStore) initSchema() error {
-	const schema = `
-	CREATE TABLE IF NOT EXISTS loans (
-		id TEXT PRIMARY KEY,
-		customer_key TEXT NOT NULL,
-		principal TEXT NOT NULL,
-		balance TEXT NOT NULL,
-		interest_rate TEXT NOT NULL,
-		base_interest_rate TEXT NOT NULL DEFAULT '0',
-		interest_rate_variance TEXT NOT NULL DEFAULT '0',
-		status TEXT NOT NULL,
-		created_at DATETIME NOT NULL,
-		updated_at DATETIME NOT NULL,
-		last_interest_calculation_date DATETIME,
-		statement_cycle_day INTEGER NOT NULL DEFAULT 1,
-		accrued_interest TEXT NOT NULL DEFAULT '0'
-	);
-	CREATE TABLE IF NOT EXISTS transactions (
-		id TEXT PRIMARY KEY,
-		loan_id TEXT NOT NULL,
-		amount TEXT NOT NULL,
-		type TEXT NOT NULL,
-		timestamp DATETIME NOT NULL,
-		FOREIGN KEY(loan_id) REFERENCES loans(id)
-	);
-	`
-	_, err := s.db.Exec(schema)
-	if err != nil {
-		return err
-	}
+// sqliteMigrations builds the schema from migrations/sqlite. SQLite rolls DDL back with the
+// rest of a transaction, and a database file has one writer at a time, so no lock is taken.
+var sqliteMigrations = &migrations{
+	dir: "migrations/sqlite",
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`,
+	tableExists:   `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+	transactional: true,
+	adopt:         adoptSQLiteSchema,
+}
 
-	// Migration for columns that might have been REAL before
-	// In a real scenario, we'd need more complex migrations if data existed.
-	// For now, we'll just ensure the columns are present.
-	columns := []string{
+// sqliteLegacyColumns are the columns that databases built before migrations were numbered
+// gained with ALTER TABLE as they were added, and so may lack. The archive tables mirror their
+// hot counterparts.
+var sqliteLegacyColumns = []struct {
+	tables  []string
+	columns []string
+}{
+	{[]string{"loans", "archived_loans"}, []string{
 		"last_interest_calculation_date DATETIME",
 		"statement_cycle_day INTEGER NOT NULL DEFAULT 1",
 		"accrued_interest TEXT NOT NULL DEFAULT '0'",
 		"base_interest_rate TEXT NOT NULL DEFAULT '0'",
 		"interest_rate_variance TEXT NOT NULL DEFAULT '0'",
-	}
+		"days_past_due INTEGER NOT NULL DEFAULT 0",
+		"delinquency_bucket TEXT NOT NULL DEFAULT 'current'",
+		"last_payment_date DATETIME",
+		"charged_off_at DATETIME",
+		"charge_off_amount TEXT NOT NULL DEFAULT '0'",
+		"product_code TEXT NOT NULL DEFAULT ''",
+		"post_charge_off_interest TEXT NOT NULL DEFAULT '0'",
+		"term_months INTEGER NOT NULL DEFAULT 0",
+		"refinanced_from TEXT",
+		"index_code TEXT NOT NULL DEFAULT ''",
+		"promo_rate TEXT NOT NULL DEFAULT '0'",
+		"promo_start_date DATETIME",
+		"promo_end_date DATETIME",
+		"amortization_months INTEGER NOT NULL DEFAULT 0",
+		"prepayment_penalty_rate TEXT NOT NULL DEFAULT '0'",
+		"prepayment_penalty_months INTEGER NOT NULL DEFAULT 0",
+		"interest_applied_cycle TEXT NOT NULL DEFAULT ''",
+		"odd_days_policy TEXT NOT NULL DEFAULT ''",
+		"odd_days INTEGER NOT NULL DEFAULT 0",
+		"odd_days_interest TEXT NOT NULL DEFAULT '0'",
+		"escrow_enabled INTEGER NOT NULL DEFAULT 0",
+		"escrow_balance TEXT NOT NULL DEFAULT '0'",
+		"fees_due TEXT NOT NULL DEFAULT '0'",
+		"loan_type TEXT NOT NULL DEFAULT ''",
+		"credit_limit TEXT NOT NULL DEFAULT '0'",
+		"accrual_start_date DATETIME",
+		"interest_mode TEXT NOT NULL DEFAULT ''",
+		"interest_due TEXT NOT NULL DEFAULT '0'",
+		"interest_residual TEXT NOT NULL DEFAULT '0'",
+		"negative_amortization_cap TEXT NOT NULL DEFAULT '0'",
+		"negative_amortization_capped INTEGER NOT NULL DEFAULT 0",
+		"version INTEGER NOT NULL DEFAULT 1",
+		"deleted_at DATETIME",
+	}},
+	{[]string{"transactions", "archived_transactions"}, []string{
+		"payment_method_id TEXT",
+		"source TEXT NOT NULL DEFAULT ''",
+		"capitalized INTEGER NOT NULL DEFAULT 0",
+		"reference TEXT NOT NULL DEFAULT ''",
+	}},
+	{[]string{"products"}, []string{
+		"odd_days_interest TEXT NOT NULL DEFAULT ''",
+		"minimum_payment_floor TEXT NOT NULL DEFAULT '0'",
+		"minimum_payment_percent TEXT NOT NULL DEFAULT '0'",
+		"accrual_grace_days INTEGER NOT NULL DEFAULT 0",
+	}},
+	{[]string{"statements"}, []string{
+		"minimum_due TEXT NOT NULL DEFAULT '0'",
+	}},
+}
 
-	for _, col := range columns {
-		_, err = s.db.Exec(fmt.Sprintf("ALTER TABLE loans ADD COLUMN %s", col))
-		if err != nil && !isDuplicateColumnError(err) {
-			return fmt.Errorf("failed to add column %s: %w", col, err)
+// adoptSQLiteSchema adds the legacy columns a database lacks to the tables it has, then runs
+// the initial migration, whose statements all skip what exists, for the tables it lacks.
+func adoptSQLiteSchema(ctx context.Context, conn *sql.Conn) error {
+	for _, legacy := range sqliteLegacyColumns {
+		for _, table := range legacy.tables {
+			existing, err := sqliteColumns(ctx, conn, table)
+			if err != nil {
+				return err
+			}
+			if len(existing) == 0 {
+				continue // The initial migration creates the table whole
+			}
+			for _, column := range legacy.columns {
+				if existing[strings.Fields(column)[0]] {
+					continue
+				}
+				if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column)); err != nil {
+					return fmt.Errorf("failed to add column %s to %s: %w", column, table, err)
+				}
+			}
 		}
 	}
 
-	return nil
-}
-
-// isDuplicateColumnError checks if the error indicates a duplicate column.
-func isDuplicateColumnError(err error) bool {
-	if err == nil {
-		return false
+	initial, err := migrationFiles.ReadFile("migrations/sqlite/0001_initial_schema.up.sql")
+	if err != nil {
+		return err
 	}
-	return err.Error() == "duplicate column name" || (len(err.Error()) > 21 && err.Error()[:21] == "duplicate column name")
+	return execScript(ctx, conn, string(initial))
 }
 
-// CreateLoan inserts a new loan into the database.
-func (s *SQLiteStore) CreateLoan(loan *models.Loan) error {
-	_, err := s.db.Exec(
-		`INSERT INTO loans (id, customer_key, principal, balance, interest_rate, base_interest_rate, interest_rate_variance, status, created_at, updated_at, last_interest_calculation_date, statement_cycle_day, accrued_interest)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		loan.ID.String(), loan.CustomerKey, loan.Principal, loan.Balance, loan.InterestRate, loan.BaseInterestRate, loan.InterestRateVariance, loan.Status, loan.CreatedAt, loan.UpdatedAt, loan.LastInterestCalculationDate, loan.StatementCycleDay, loan.AccruedInterest,
-	)
+// sqliteColumns returns the names of a table's columns, none if the table does not exist.
+func sqliteColumns(ctx context.Context, conn *sql.Conn, table string) (map[string]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT name FROM pragma_table_info(?)`, table)
 	if err != nil {
-		return fmt.Errorf("failed to create loan: %w", err)
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
 }
 
-// GetLoan retrieves a loan by its ID.
-func (s *SQLiteStore) GetLoan(id uuid.UUID) (*models.Loan, error) {
+// loanColumns lists the loan columns in the order expected by scanLoan and loanValues.
+const loanColumns = `id, customer_key, principal, balance, interest_rate, base_interest_rate, interest_rate_variance, status, created_at, updated_at, last_interest_calculation_date, statement_cycle_day, accrued_interest, days_past_due, delinquency_bucket, last_payment_date, charged_off_at, charge_off_amount, product_code, post_charge_off_interest, term_months, refinanced_from, index_code, promo_rate, promo_start_date, promo_end_date, amortization_months, prepayment_penalty_rate, prepayment_penalty_months, interest_applied_cycle, odd_days_policy, odd_days, odd_days_interest, escrow_enabled, escrow_balance, fees_due, loan_type, credit_limit, accrual_start_date, interest_mode, interest_due, interest_residual, negative_amortization_cap, negative_amortization_capped, deleted_at, version`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// loanValues returns the loan's fields in loanColumns order.
+func loanValues(loan *models.Loan) []any {
+	return []any{loan.ID.String(), loan.CustomerKey, loan.Principal, loan.Balance, loan.InterestRate, loan.BaseInterestRate, loan.InterestRateVariance, loan.Status, loan.CreatedAt, loan.UpdatedAt, loan.LastInterestCalculationDate, loan.StatementCycleDay, loan.AccruedInterest, loan.DaysPastDue, loan.DelinquencyBucket, loan.LastPaymentDate, loan.ChargedOffAt, loan.ChargeOffAmount, loan.ProductCode, loan.PostChargeOffInterest, loan.TermMonths, loan.RefinancedFrom, loan.IndexCode, loan.PromoRate, loan.PromoStartDate, loan.PromoEndDate, loan.AmortizationMonths, loan.PrepaymentPenaltyRate, loan.PrepaymentPenaltyMonths, loan.InterestAppliedCycle, loan.OddDaysPolicy, loan.OddDays, loan.OddDaysInterest, loan.EscrowEnabled, loan.EscrowBalance, loan.FeesDue, loan.LoanType, loan.CreditLimit, loan.AccrualStartDate, loan.InterestMode, loan.InterestDue, loan.InterestResidual, loan.NegativeAmortizationCap, loan.NegativeAmortizationCapped, loan.DeletedAt, loan.Version}
+}
+
+// scanLoan reads a single loan selected with loanColumns.
+func scanLoan(row rowScanner) (*models.Loan, error) {
 	var loan models.Loan
 	var created, updated time.Time
 	var loanIDStr string
-	var lastInterestCalcDate sql.NullTime
-
-	row := s.db.QueryRow(`SELECT id, customer_key, principal, balance, interest_rate, base_interest_rate, interest_rate_variance, status, created_at, updated_at, last_interest_calculation_date, statement_cycle_day, accrued_interest FROM loans WHERE id = ?`, id.String())
-	err := row.Scan(&loanIDStr, &loan.CustomerKey, &loan.Principal, &loan.Balance, &loan.InterestRate, &loan.BaseInterestRate, &loan.InterestRateVariance, &loan.Status, &created, &updated, &lastInterestCalcDate, &loan.StatementCycleDay, &loan.AccruedInterest)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("loan not found")
-		}
-		return nil, fmt.Errorf("failed to get loan: %w", err)
+	var lastInterestCalcDate, lastPaymentDate sql.NullTime
+	if err := row.Scan(&loanIDStr, &loan.CustomerKey, &loan.Principal, &loan.Balance, &loan.InterestRate, &loan.BaseInterestRate, &loan.InterestRateVariance, &loan.Status, &created, &updated, &lastInterestCalcDate, &loan.StatementCycleDay, &loan.AccruedInterest, &loan.DaysPastDue, &loan.DelinquencyBucket, &lastPaymentDate, &loan.ChargedOffAt, &loan.ChargeOffAmount, &loan.ProductCode, &loan.PostChargeOffInterest, &loan.TermMonths, &loan.RefinancedFrom, &loan.IndexCode, &loan.PromoRate, &loan.PromoStartDate, &loan.PromoEndDate, &loan.AmortizationMonths, &loan.PrepaymentPenaltyRate, &loan.PrepaymentPenaltyMonths, &loan.InterestAppliedCycle, &loan.OddDaysPolicy, &loan.OddDays, &loan.OddDaysInterest, &loan.EscrowEnabled, &loan.EscrowBalance, &loan.FeesDue, &loan.LoanType, &loan.CreditLimit, &loan.AccrualStartDate, &loan.InterestMode, &loan.InterestDue, &loan.InterestResidual, &loan.NegativeAmortizationCap, &loan.NegativeAmortizationCapped, &loan.DeletedAt, &loan.Version); err != nil {
+		return nil, err
 	}
 	loan.ID = uuid.MustParse(loanIDStr)
 	loan.CreatedAt = created
@@ -142,60 +235,246 @@ func (s *SQLiteStore) GetLoan(id uuid.UUID) (*models.Loan, error) {
 	if lastInterestCalcDate.Valid {
 		loan.LastInterestCalculationDate = &lastInterestCalcDate.Time
 	}
+	if lastPaymentDate.Valid {
+		loan.LastPaymentDate = &lastPaymentDate.Time
+	}
 	return &loan, nil
 }
 
-// UpdateLoan updates an existing loan in the database.
-func (s *SQLiteStore) UpdateLoan(loan *models.Loan) error {
-	result, err := s.db.Exec(
-		`UPDATE loans SET customer_key = ?, principal = ?, balance = ?, interest_rate = ?, base_interest_rate = ?, interest_rate_variance = ?, status = ?, updated_at = ?, last_interest_calculation_date = ?, statement_cycle_day = ?, accrued_interest = ? WHERE id = ?`,
-		loan.CustomerKey, loan.Principal, loan.Balance, loan.InterestRate, loan.BaseInterestRate, loan.InterestRateVariance, loan.Status, loan.UpdatedAt, loan.LastInterestCalculationDate, loan.StatementCycleDay, loan.AccruedInterest, loan.ID.String(),
+// placeholders returns a comma-separated list of n bind parameters.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// upsert returns an INSERT of columns into table that, when a row with the same key columns
+// is already stored, replaces its other columns instead.
+func upsert(table string, columns string, key ...string) string {
+	names := strings.Split(columns, ", ")
+	var set []string
+	for _, name := range names {
+		if !slices.Contains(key, name) {
+			set = append(set, name+" = excluded."+name)
+		}
+	}
+	return `INSERT INTO ` + table + ` (` + columns + `) VALUES (` + placeholders(len(names)) + `)
+		ON CONFLICT (` + strings.Join(key, ", ") + `) DO UPDATE SET ` + strings.Join(set, ", ")
+}
+
+// CreateLoan inserts a new loan into the database, registering its customer key as a customer
+// if it is new.
+func (s *sqlStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := registerCustomer(ctx, tx, loan.CustomerKey, loan.CreatedAt); err != nil {
+		return err
+	}
+	values := loanValues(loan)
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO loans (`+loanColumns+`) VALUES (`+placeholders(len(values))+`)`,
+		values...,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to update loan: %w", err)
+		return fmt.Errorf("failed to create loan: %w", err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	return tx.Commit()
+}
+
+// GetLoan retrieves a loan by its ID.
+func (s *sqlStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE id = ?`, id.String())
+	loan, err := scanLoan(row)
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		if err == sql.ErrNoRows {
+			return nil, models.ErrLoanNotFound
+		}
+		return nil, fmt.Errorf("failed to get loan: %w", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("loan not found")
+	return loan, nil
+}
+
+// UpdateLoan stores a loan's fields and increments its version, setting loan.Version to the
+// new value.
+func (s *sqlStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	return s.updateLoan(ctx, loan, 0)
+}
+
+// UpdateLoanIfVersion updates a loan only if its stored version is still version, returning
+// models.ErrLoanVersionMismatch if another update got there first.
+func (s *sqlStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	if version <= 0 {
+		return models.ErrLoanVersionMismatch
 	}
-	return nil
+	return s.updateLoan(ctx, loan, version)
 }
 
-// DeleteLoan removes a loan and its transactions from the database within a transaction.
-func (s *SQLiteStore) DeleteLoan(id uuid.UUID) error {
-	tx, err := s.db.Begin()
+// updateLoan updates a loan, requiring the stored version to equal version unless it is zero.
+// Like CreateLoan, it registers the loan's customer key as a customer if it is new.
+func (s *sqlStore) updateLoan(ctx context.Context, loan *models.Loan, version int) error {
+	query := `UPDATE loans SET customer_key = ?, principal = ?, balance = ?, interest_rate = ?, base_interest_rate = ?, interest_rate_variance = ?, status = ?, updated_at = ?, last_interest_calculation_date = ?, statement_cycle_day = ?, accrued_interest = ?, days_past_due = ?, delinquency_bucket = ?, last_payment_date = ?, charged_off_at = ?, charge_off_amount = ?, product_code = ?, post_charge_off_interest = ?, term_months = ?, refinanced_from = ?, index_code = ?, promo_rate = ?, promo_start_date = ?, promo_end_date = ?, amortization_months = ?, prepayment_penalty_rate = ?, prepayment_penalty_months = ?, interest_applied_cycle = ?, odd_days_policy = ?, odd_days = ?, odd_days_interest = ?, escrow_enabled = ?, escrow_balance = ?, fees_due = ?, loan_type = ?, credit_limit = ?, accrual_start_date = ?, interest_mode = ?, interest_due = ?, interest_residual = ?, negative_amortization_cap = ?, negative_amortization_capped = ?, deleted_at = ?, version = version + 1 WHERE id = ?`
+	args := []any{
+		loan.CustomerKey, loan.Principal, loan.Balance, loan.InterestRate, loan.BaseInterestRate, loan.InterestRateVariance, loan.Status, loan.UpdatedAt, loan.LastInterestCalculationDate, loan.StatementCycleDay, loan.AccruedInterest, loan.DaysPastDue, loan.DelinquencyBucket, loan.LastPaymentDate, loan.ChargedOffAt, loan.ChargeOffAmount, loan.ProductCode, loan.PostChargeOffInterest, loan.TermMonths, loan.RefinancedFrom, loan.IndexCode, loan.PromoRate, loan.PromoStartDate, loan.PromoEndDate, loan.AmortizationMonths, loan.PrepaymentPenaltyRate, loan.PrepaymentPenaltyMonths, loan.InterestAppliedCycle, loan.OddDaysPolicy, loan.OddDays, loan.OddDaysInterest, loan.EscrowEnabled, loan.EscrowBalance, loan.FeesDue, loan.LoanType, loan.CreditLimit, loan.AccrualStartDate, loan.InterestMode, loan.InterestDue, loan.InterestResidual, loan.NegativeAmortizationCap, loan.NegativeAmortizationCapped, loan.DeletedAt, loan.ID.String(),
+	}
+	if version > 0 {
+		query += ` AND version = ?`
+		args = append(args, version)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	_, err = tx.Exec(`DELETE FROM transactions WHERE loan_id = ?`, id.String())
+	// The loan may have moved to a customer key that is new
+	if err := registerCustomer(ctx, tx, loan.CustomerKey, loan.UpdatedAt); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to delete associated transactions: %w", err)
+		return fmt.Errorf("failed to update loan: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		if version > 0 {
+			var exists int
+			if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM loans WHERE id = ?`, loan.ID.String()).Scan(&exists); err == nil && exists > 0 {
+				return models.ErrLoanVersionMismatch
+			}
+		}
+		return models.ErrLoanNotFound
+	}
+	// Read back rather than UPDATE ... RETURNING, which MySQL lacks
+	if err := tx.QueryRowContext(ctx, `SELECT version FROM loans WHERE id = ?`, loan.ID.String()).Scan(&loan.Version); err != nil {
+		return fmt.Errorf("failed to read loan version: %w", err)
+	}
+	return tx.Commit()
+}
+
+// loanBatchSize is how many loans UpdateLoans writes per statement, keeping the bind
+// parameters well under every database's limit.
+const loanBatchSize = 500
+
+// loanBatchUpdate sets every column of a loan UpdateLoans writes but its creation time, and
+// increments its version as UpdateLoan does. Each assignment is guarded by the version, so a
+// loan that changed since it was read is left as it is.
+var loanBatchUpdate = func() string {
+	const unchanged = "loans.version = excluded.version"
+	var set []string
+	for _, name := range strings.Split(loanColumns, ", ") {
+		if name != "id" && name != "created_at" && name != "version" {
+			set = append(set, name+" = CASE WHEN "+unchanged+" THEN excluded."+name+" ELSE loans."+name+" END")
+		}
 	}
+	return strings.Join(append(set, "version = CASE WHEN "+unchanged+" THEN loans.version + 1 ELSE loans.version END"), ", ")
+}()
 
-	result, err := tx.Exec(`DELETE FROM loans WHERE id = ?`, id.String())
+// UpdateLoans stores many loans in one transaction, a batch of them per statement, and sets
+// each stored loan's version to its new value. A loan whose version changed since it was read
+// is skipped and its ID returned. If any loan is missing none are stored.
+func (s *sqlStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	if len(loans) == 0 {
+		return nil, nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("failed to delete loan: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var stale []uuid.UUID
+	for batch := range slices.Chunk(loans, loanBatchSize) {
+		skipped, err := updateLoanBatch(ctx, tx, batch)
+		if err != nil {
+			return nil, err
+		}
+		stale = append(stale, skipped...)
 	}
+	return stale, tx.Commit()
+}
 
-	rowsAffected, err := result.RowsAffected()
+// updateLoanBatch updates up to loanBatchSize loans with a multi-row upsert, after checking
+// they all exist so that the upsert never inserts one, and returns the IDs of the loans it
+// skipped because their versions had changed.
+func updateLoanBatch(ctx context.Context, tx *sqlTx, batch []*models.Loan) ([]uuid.UUID, error) {
+	latest := make(map[uuid.UUID]*models.Loan, len(batch))
+	var ids []any
+	for _, loan := range batch {
+		if _, ok := latest[loan.ID]; !ok {
+			ids = append(ids, loan.ID.String())
+		}
+		latest[loan.ID] = loan // A loan given twice is stored as last given
+	}
+
+	// Lock the loans before reading their versions, so none can change before the upsert and
+	// every loan it skips is known from the versions read here
+	if _, err := tx.ExecContext(ctx, `UPDATE loans SET version = version WHERE id IN (`+placeholders(len(ids))+`)`, ids...); err != nil {
+		return nil, fmt.Errorf("failed to lock loans: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT id, customer_key, version FROM loans WHERE id IN (`+placeholders(len(ids))+`)`, ids...)
 	if err != nil {
-		return fmt.Errorf("failed to check rows affected: %w", err)
+		return nil, fmt.Errorf("failed to look up loans: %w", err)
+	}
+	customerKeys := make(map[string]string, len(ids))
+	versions := make(map[string]int, len(ids))
+	for rows.Next() {
+		var id, customerKey string
+		var version int
+		if err := rows.Scan(&id, &customerKey, &version); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan loan: %w", err)
+		}
+		customerKeys[id] = customerKey
+		versions[id] = version
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up loans: %w", err)
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("loan not found")
+	if len(customerKeys) != len(ids) {
+		return nil, models.ErrLoanNotFound
 	}
 
-	return tx.Commit()
+	var stale []uuid.UUID
+	var values []any
+	for _, id := range ids {
+		loan := latest[uuid.MustParse(id.(string))]
+		if versions[id.(string)] != loan.Version {
+			stale = append(stale, loan.ID)
+			continue
+		}
+		// Only a loan moved to another customer key can bring a new customer
+		if customerKeys[id.(string)] != loan.CustomerKey {
+			if err := registerCustomer(ctx, tx, loan.CustomerKey, loan.UpdatedAt); err != nil {
+				return nil, err
+			}
+		}
+		values = append(values, loanValues(loan)...)
+	}
+	if len(stale) == len(ids) {
+		return stale, nil
+	}
+	stored := len(ids) - len(stale)
+	row := "(" + placeholders(len(values)/stored) + ")"
+	query := `INSERT INTO loans (` + loanColumns + `) VALUES ` + strings.TrimSuffix(strings.Repeat(row+", ", stored), ", ") + `
+		ON CONFLICT (id) DO UPDATE SET ` + loanBatchUpdate
+	if _, err := tx.ExecContext(ctx, query, values...); err != nil {
+		return nil, fmt.Errorf("failed to update loans: %w", err)
+	}
+
+	for _, loan := range batch {
+		if !slices.Contains(stale, loan.ID) {
+			loan.Version = versions[loan.ID.String()] + 1
+		}
+	}
+	return stale, nil
 }
 
 // GetAllLoans retrieves all loans.
-func (s *SQLiteStore) GetAllLoans() ([]*models.Loan, error) {
-	rows, err := s.db.Query(`SELECT id, customer_key, principal, balance, interest_rate, base_interest_rate, interest_rate_variance, status, created_at, updated_at, last_interest_calculation_date, statement_cycle_day, accrued_interest FROM loans`)
+func (s *sqlStore) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all loans: %w", err)
 	}
@@ -204,9 +483,162 @@ func (s *SQLiteStore) GetAllLoans() ([]*models.Loan, error) {
 	return s.scanLoans(rows)
 }
 
-// GetAllActiveLoans retrieves all active loans.
-func (s *SQLiteStore) GetAllActiveLoans() ([]*models.Loan, error) {
-	rows, err := s.db.Query(`SELECT id, customer_key, principal, balance, interest_rate, base_interest_rate, interest_rate_variance, status, created_at, updated_at, last_interest_calculation_date, statement_cycle_day, accrued_interest FROM loans WHERE status = 'active'`)
+// loanSortOrders maps each loan sort to its ORDER BY clause. Amounts are stored as text, so
+// they are cast to sort numerically; timestamps go through julianday to compare across
+// time zone offsets.
+var loanSortOrders = map[models.LoanSort]string{
+	"":                           "julianday(created_at) ASC, id ASC",
+	models.LoanSortCreatedAt:     "julianday(created_at) ASC, id ASC",
+	models.LoanSortCreatedAtDesc: "julianday(created_at) DESC, id DESC",
+	models.LoanSortBalance:       "CAST(balance AS REAL) ASC, id ASC",
+	models.LoanSortBalanceDesc:   "CAST(balance AS REAL) DESC, id DESC",
+}
+
+// ListLoans retrieves a page of the loans matching the query, in the query's sort order,
+// along with the number of matching loans across all pages. Voided loans are left out unless
+// the query asks for them by status.
+func (s *sqlStore) ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error) {
+	conditions, args := loanQueryConditions(query)
+	return s.pageLoans(ctx, conditions, args, query.Sort, query.Limit, query.Offset)
+}
+
+// ListLoansAfter retrieves up to limit loans matching the query's filters, oldest first,
+// starting after the cursor. The page seeks past the cursor on the (created_at, id) index
+// rather than skipping rows with OFFSET, so late pages cost as little as early ones.
+func (s *sqlStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid limit %d: must be positive", limit)
+	}
+	conditions, args := loanQueryConditions(query)
+	if cursor != nil {
+		conditions = append(conditions, "(julianday(created_at) > julianday(?) OR julianday(created_at) = julianday(?) AND id > ?)")
+		args = append(args, cursor.CreatedAt.UTC(), cursor.CreatedAt.UTC(), cursor.ID.String())
+	}
+
+	// One loan more than the page tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE `+strings.Join(conditions, " AND ")+` ORDER BY `+loanSortOrders[models.LoanSortCreatedAt]+` LIMIT ?`, append(args, limit+1)...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list loans: %w", err)
+	}
+	defer rows.Close()
+
+	loans, err := s.scanLoans(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	loans, next := loanPageAfter(loans, limit)
+	return loans, next, nil
+}
+
+// loanPageAfter trims loans read one past a page of limit to the page, returning the cursor
+// after its last loan if more follow.
+func loanPageAfter(loans []*models.Loan, limit int) ([]*models.Loan, *models.LoanCursor) {
+	if len(loans) <= limit {
+		return loans, nil
+	}
+	last := loans[limit-1]
+	return loans[:limit], &models.LoanCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+}
+
+// loanQueryConditions returns the WHERE conditions and their arguments for a loan query's
+// filters.
+func loanQueryConditions(query models.LoanQuery) ([]string, []any) {
+	conditions := []string{"status != 'voided'"}
+	args := []any{}
+	if query.Status != "" {
+		conditions = []string{"status = ?"}
+		args = append(args, query.Status)
+	}
+	if query.CustomerKey != "" {
+		conditions = append(conditions, "customer_key = ?")
+		args = append(args, query.CustomerKey)
+	}
+	if !query.MinBalance.IsZero() {
+		conditions = append(conditions, "CAST(balance AS REAL) >= ?")
+		args = append(args, query.MinBalance.InexactFloat64())
+	}
+	if query.CreatedAfter != nil {
+		conditions = append(conditions, "julianday(created_at) >= julianday(?)")
+		args = append(args, query.CreatedAfter.UTC())
+	}
+	return conditions, args
+}
+
+// SearchLoans retrieves a page of the loans matching the search, in its sort order, along
+// with the number of matching loans across all pages. Each condition matches the expression
+// of an index on loans, so ranges are index scans.
+func (s *sqlStore) SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error) {
+	conditions := []string{"status != 'voided'"}
+	args := []any{}
+	if len(search.Statuses) > 0 {
+		conditions = []string{"status IN (?" + strings.Repeat(", ?", len(search.Statuses)-1) + ")"}
+		for _, status := range search.Statuses {
+			args = append(args, status)
+		}
+	}
+	if search.CustomerKeyPrefix != "" {
+		// A range rather than LIKE, which cannot use the index under SQLite's
+		// case-insensitive default
+		conditions = append(conditions, "customer_key >= ? AND customer_key < ?")
+		args = append(args, search.CustomerKeyPrefix, search.CustomerKeyPrefix+string(utf8.MaxRune))
+	}
+	ranges := []struct {
+		expr     string
+		min, max *decimal.Decimal
+	}{
+		{"CAST(balance AS REAL)", search.MinBalance, search.MaxBalance},
+		{"CAST(interest_rate AS REAL)", search.MinRate, search.MaxRate},
+	}
+	for _, r := range ranges {
+		if r.min != nil {
+			conditions = append(conditions, r.expr+" >= ?")
+			args = append(args, r.min.InexactFloat64())
+		}
+		if r.max != nil {
+			conditions = append(conditions, r.expr+" <= ?")
+			args = append(args, r.max.InexactFloat64())
+		}
+	}
+	if search.CreatedFrom != nil {
+		conditions = append(conditions, "julianday(created_at) >= julianday(?)")
+		args = append(args, search.CreatedFrom.UTC())
+	}
+	if search.CreatedTo != nil {
+		conditions = append(conditions, "julianday(created_at) <= julianday(?)")
+		args = append(args, search.CreatedTo.UTC())
+	}
+	return s.pageLoans(ctx, conditions, args, search.Sort, search.Limit, search.Offset)
+}
+
+// pageLoans counts the loans matching every condition and retrieves one page of them.
+func (s *sqlStore) pageLoans(ctx context.Context, conditions []string, args []any, sort models.LoanSort, limit int, offset int) ([]*models.Loan, int, error) {
+	order, ok := loanSortOrders[sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unknown loan sort %q", sort)
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM loans WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count loans: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE `+where+` ORDER BY `+order+` LIMIT ? OFFSET ?`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list loans: %w", err)
+	}
+	defer rows.Close()
+
+	loans, err := s.scanLoans(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return loans, total, nil
+}
+
+// GetAllActiveLoans retrieves all open loans, whether active or delinquent.
+func (s *sqlStore) GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE status IN ('active', 'delinquent')`)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all active loans: %w", err)
 	}
@@ -215,23 +647,78 @@ func (s *SQLiteStore) GetAllActiveLoans() ([]*models.Loan, error) {
 	return s.scanLoans(rows)
 }
 
-func (s *SQLiteStore) scanLoans(rows *sql.Rows) ([]*models.Loan, error) {
+// activeLoanPageSize is how many loans ForEachActiveLoan reads at a time.
+const activeLoanPageSize = 500
+
+// ForEachActiveLoan calls fn with each active or delinquent loan in ID order. Each page is
+// read in full and its rows closed before fn sees any of it, so fn is free to use the
+// database, and the next page starts after the last ID seen.
+func (s *sqlStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	after := ""
+	for {
+		rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE status IN ('active', 'delinquent') AND id > ? ORDER BY id LIMIT ?`, after, activeLoanPageSize)
+		if err != nil {
+			return fmt.Errorf("failed to get active loans: %w", err)
+		}
+		page, err := s.scanLoans(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		for _, loan := range page {
+			if err := fn(loan); err != nil {
+				return err
+			}
+		}
+		if len(page) < activeLoanPageSize {
+			return nil
+		}
+		after = page[len(page)-1].ID.String()
+	}
+}
+
+// GetLoansByCustomerKey retrieves a customer's loans, oldest first.
+func (s *sqlStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE customer_key = ? ORDER BY julianday(created_at) ASC, id ASC`, customerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get loans for customer %s: %w", customerKey, err)
+	}
+	defer rows.Close()
+
+	return s.scanLoans(rows)
+}
+
+// GetLoansByStatus retrieves all loans with the given status.
+func (s *sqlStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE status = ?`, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s loans: %w", status, err)
+	}
+	defer rows.Close()
+
+	return s.scanLoans(rows)
+}
+
+// GetDelinquentLoans retrieves open loans that are at least minDaysPastDue days past due,
+// most delinquent first.
+func (s *sqlStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE status IN ('active', 'delinquent') AND days_past_due >= ? AND days_past_due > 0 ORDER BY days_past_due DESC`, minDaysPastDue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delinquent loans: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanLoans(rows)
+}
+
+func (s *sqlStore) scanLoans(rows *sql.Rows) ([]*models.Loan, error) {
 	var loans []*models.Loan
 	for rows.Next() {
-		var loan models.Loan
-		var created, updated time.Time
-		var loanIDStr string
-		var lastInterestCalcDate sql.NullTime
-		if err := rows.Scan(&loanIDStr, &loan.CustomerKey, &loan.Principal, &loan.Balance, &loan.InterestRate, &loan.BaseInterestRate, &loan.InterestRateVariance, &loan.Status, &created, &updated, &lastInterestCalcDate, &loan.StatementCycleDay, &loan.AccruedInterest); err != nil {
+		loan, err := scanLoan(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan loan row: %w", err)
 		}
-		loan.ID = uuid.MustParse(loanIDStr)
-		loan.CreatedAt = created
-		loan.UpdatedAt = updated
-		if lastInterestCalcDate.Valid {
-			loan.LastInterestCalculationDate = &lastInterestCalcDate.Time
-		}
-		loans = append(loans, &loan)
+		loans = append(loans, loan)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error during rows iteration: %w", err)
@@ -239,12 +726,33 @@ func (s *SQLiteStore) scanLoans(rows *sql.Rows) ([]*models.Loan, error) {
 	return loans, nil
 }
 
+// transactionColumns lists the transaction columns in the order expected by scanTransaction
+// and transactionValues.
+const transactionColumns = `id, loan_id, amount, type, timestamp, payment_method_id, source, capitalized, reference, metadata`
+
+// transactionValues returns the transaction's fields in transactionColumns order.
+func transactionValues(transaction *models.Transaction) []any {
+	return []any{transaction.ID.String(), transaction.LoanID.String(), transaction.Amount, transaction.Type, transaction.Timestamp, transaction.PaymentMethodID, transaction.Source, transaction.Capitalized, transaction.Reference, jsonMetadata{&transaction.Metadata}}
+}
+
+// scanTransaction reads a single transaction selected with transactionColumns.
+func scanTransaction(row rowScanner) (*models.Transaction, error) {
+	var transaction models.Transaction
+	var txIDStr, loanIDStr string
+	if err := row.Scan(&txIDStr, &loanIDStr, &transaction.Amount, &transaction.Type, &transaction.Timestamp, &transaction.PaymentMethodID, &transaction.Source, &transaction.Capitalized, &transaction.Reference, jsonMetadata{&transaction.Metadata}); err != nil {
+		return nil, err
+	}
+	transaction.ID = uuid.MustParse(txIDStr)
+	transaction.LoanID = uuid.MustParse(loanIDStr)
+	return &transaction, nil
+}
+
 // CreateTransaction inserts a new transaction into the database.
-func (s *SQLiteStore) CreateTransaction(transaction *models.Transaction) error {
-	_, err := s.db.Exec(
-		`INSERT INTO transactions (id, loan_id, amount, type, timestamp)
-		VALUES (?, ?, ?, ?, ?)`,
-		transaction.ID.String(), transaction.LoanID.String(), transaction.Amount, transaction.Type, transaction.Timestamp,
+func (s *sqlStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	values := transactionValues(transaction)
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO transactions (`+transactionColumns+`) VALUES (`+placeholders(len(values))+`)`,
+		values...,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create transaction: %w", err)
@@ -253,33 +761,144 @@ func (s *SQLiteStore) CreateTransaction(transaction *models.Transaction) error {
 }
 
 // GetTransactionsForLoan retrieves all transactions for a given loan ID.
-func (s *SQLiteStore) GetTransactionsForLoan(loanID uuid.UUID) ([]*models.Transaction, error) {
-	rows, err := s.db.Query(`SELECT id, loan_id, amount, type, timestamp FROM transactions WHERE loan_id = ? ORDER BY timestamp ASC`, loanID.String())
+func (s *sqlStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE loan_id = ? ORDER BY timestamp ASC`, loanID.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to get transactions for loan %s: %w", loanID, err)
 	}
 	defer rows.Close()
 
+	return s.scanTransactions(rows)
+}
+
+// QueryTransactions retrieves a page of a loan's transactions matching the query, oldest
+// first, along with the number of matching transactions across all pages.
+func (s *sqlStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	conditions := []string{"loan_id = ?"}
+	args := []any{loanID.String()}
+	if len(query.Types) > 0 {
+		conditions = append(conditions, "type IN (?"+strings.Repeat(", ?", len(query.Types)-1)+")")
+		for _, txType := range query.Types {
+			args = append(args, txType)
+		}
+	}
+	if query.From != nil {
+		conditions = append(conditions, "julianday(timestamp) >= julianday(?)")
+		args = append(args, query.From.UTC())
+	}
+	if query.To != nil {
+		conditions = append(conditions, "julianday(timestamp) <= julianday(?)")
+		args = append(args, query.To.UTC())
+	}
+	if query.MinAmount != nil {
+		conditions = append(conditions, "CAST(amount AS REAL) >= ?")
+		args = append(args, query.MinAmount.InexactFloat64())
+	}
+	if query.MaxAmount != nil {
+		conditions = append(conditions, "CAST(amount AS REAL) <= ?")
+		args = append(args, query.MaxAmount.InexactFloat64())
+	}
+	for _, key := range slices.Sorted(maps.Keys(query.Metadata)) {
+		conditions = append(conditions, "json_extract(metadata, ?) = ?")
+		args = append(args, metadataPath(key), query.Metadata[key])
+	}
+	where := strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM transactions WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count transactions for loan %s: %w", loanID, err)
+	}
+
+	limit := `LIMIT -1` // No limit
+	if query.Limit > 0 {
+		limit = `LIMIT ?`
+		args = append(args, query.Limit)
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+transactionColumns+` FROM transactions WHERE `+where+` ORDER BY timestamp ASC, id ASC `+limit+` OFFSET ?`, append(args, query.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query transactions for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	transactions, err := s.scanTransactions(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return transactions, total, nil
+}
+
+// jsonMetadata reads and writes a transaction's metadata as a JSON object, stored as NULL
+// when the transaction has none.
+type jsonMetadata struct {
+	metadata *map[string]string
+}
+
+func (j jsonMetadata) Value() (driver.Value, error) {
+	if len(*j.metadata) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(*j.metadata)
+	return string(encoded), err
+}
+
+func (j jsonMetadata) Scan(src any) error {
+	*j.metadata = nil
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), j.metadata)
+	case []byte:
+		return json.Unmarshal(v, j.metadata)
+	default:
+		return fmt.Errorf("unsupported metadata type %T", src)
+	}
+}
+
+// metadataPath returns the JSON path of a metadata key, in the syntax SQLite, PostgreSQL and
+// MySQL share.
+func metadataPath(key string) string {
+	return `$."` + key + `"`
+}
+
+func (s *sqlStore) scanTransactions(rows *sql.Rows) ([]*models.Transaction, error) {
 	var transactions []*models.Transaction
 	for rows.Next() {
-		var transaction models.Transaction
-		var txIDStr, loanIDStr string
-		var timestamp time.Time
-		if err := rows.Scan(&txIDStr, &loanIDStr, &transaction.Amount, &transaction.Type, &timestamp); err != nil {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan transaction row: %w", err)
 		}
-		transaction.ID = uuid.MustParse(txIDStr)
-		transaction.LoanID = uuid.MustParse(loanIDStr)
-		transaction.Timestamp = timestamp
-		transactions = append(transactions, &transaction)
+		transactions = append(transactions, transaction)
 	}
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error during rows iteration for loan transactions: %w", err)
 	}
 	return transactions, nil
 }
 
 // Close closes the database connection.
-func (s *SQLiteStore) Close() error {
+func (s *sqlStore) Close() error {
 	return s.db.Close()
 }
+
+// InTransaction runs fn on a store bound to one database transaction, committed if fn returns
+// nil. Called on a store that is already bound, fn joins the enclosing transaction.
+func (s *sqlStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	if s.db.tx != nil {
+		return fn(ctx, s)
+	}
+	tx, err := s.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	bound := &sqlStore{db: &sqlDB{DB: s.db.DB, dialect: s.db.dialect, tx: tx}, migrations: s.migrations}
+	if err := fn(ctx, bound); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// accrualColumns lists the accruals columns in the order expected by scanAccrual.
+const accrualColumns = `loan_id, date, balance, rate, amount, created_at`
+
+func scanAccrual(row rowScanner) (*models.Accrual, error) {
+	var accrual models.Accrual
+	var loanIDStr string
+	if err := row.Scan(&loanIDStr, &accrual.Date, &accrual.Balance, &accrual.Rate, &accrual.Amount, &accrual.CreatedAt); err != nil {
+		return nil, err
+	}
+	accrual.LoanID = uuid.MustParse(loanIDStr)
+	return &accrual, nil
+}
+
+// SaveAccrual stores a loan's accrual for its date, replacing any earlier accrual for the
+// same loan and date.
+func (s *sqlStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	_, err := s.db.ExecContext(ctx,
+		upsert("accruals", accrualColumns, "loan_id", "date"),
+		accrual.LoanID.String(), accrual.Date.UTC(), accrual.Balance, accrual.Rate, accrual.Amount, accrual.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save accrual: %w", err)
+	}
+	return nil
+}
+
+// GetAccrualsForLoan retrieves a loan's accruals dated from through to (inclusive) in date order.
+func (s *sqlStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+accrualColumns+` FROM accruals WHERE loan_id = ? AND date >= ? AND date <= ? ORDER BY date ASC`, loanID.String(), from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accruals for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	accruals := []*models.Accrual{}
+	for rows.Next() {
+		accrual, err := scanAccrual(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan accrual row: %w", err)
+		}
+		accruals = append(accruals, accrual)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for accruals: %w", err)
+	}
+	return accruals, nil
+}
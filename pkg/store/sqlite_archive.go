@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// loanChildTables lists the tables holding per-loan records that must follow a loan when it
+// is archived or deleted. Each has an archived_ counterpart with the same columns.
+var loanChildTables = []struct {
+	table   string
+	columns string
+}{
+	{"transactions", transactionColumns},
+	{"loan_events", loanEventColumns},
+	{"rate_history", rateChangeColumns},
+	{"statements", statementColumns},
+	{"accruals", accrualColumns},
+	{"collateral", collateralColumns},
+	{"forbearances", forbearanceColumns},
+	{"autopay_enrollments", autopayColumns},
+	{"payment_links", paymentLinkColumns},
+	{"bureau_records", bureauRecordColumns},
+	{"interest_intents", interestIntentColumns},
+	{"ledger_events", ledgerEventColumns},
+}
+
+// ArchiveClosedLoans moves closed loans whose last update predates closedBefore, together
+// with every record in loanChildTables, from the hot tables into the archive tables within a
+// single database transaction. It returns the number of loans archived.
+func (s *sqlStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	const candidates = `SELECT id FROM loans WHERE status = 'closed' AND updated_at < ?`
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO archived_loans (`+loanColumns+`, archived_at)
+		SELECT `+loanColumns+`, CURRENT_TIMESTAMP FROM loans WHERE id IN (`+candidates+`)`,
+		closedBefore,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy loans to archive: %w", err)
+	}
+	archived, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if archived == 0 {
+		return 0, nil
+	}
+
+	for _, child := range loanChildTables {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO archived_`+child.table+` (`+child.columns+`)
+			SELECT `+child.columns+` FROM `+child.table+` WHERE loan_id IN (`+candidates+`)`,
+			closedBefore,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to copy %s to archive: %w", child.table, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+child.table+` WHERE loan_id IN (`+candidates+`)`, closedBefore); err != nil {
+			return 0, fmt.Errorf("failed to delete archived %s: %w", child.table, err)
+		}
+	}
+
+	// The same condition as candidates: MySQL cannot delete from a table it selects from
+	if _, err := tx.ExecContext(ctx, `DELETE FROM loans WHERE status = 'closed' AND updated_at < ?`, closedBefore); err != nil {
+		return 0, fmt.Errorf("failed to delete archived loans: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit archive: %w", err)
+	}
+	return int(archived), nil
+}
+
+// GetArchivedLoan retrieves a loan from the archive tables by its ID.
+func (s *sqlStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM archived_loans WHERE id = ?`, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived loan: %w", err)
+	}
+	defer rows.Close()
+
+	loans, err := s.scanLoans(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(loans) == 0 {
+		return nil, models.ErrLoanNotFound
+	}
+	return loans[0], nil
+}
+
+// GetArchivedTransactionsForLoan retrieves all archived transactions for a given loan ID.
+func (s *sqlStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+transactionColumns+` FROM archived_transactions WHERE loan_id = ? ORDER BY timestamp ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived transactions for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	return s.scanTransactions(rows)
+}
+
+// GetArchivedLoanEventsForLoan retrieves all archived events for a given loan ID.
+func (s *sqlStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanEventColumns+` FROM archived_loan_events WHERE loan_id = ? ORDER BY timestamp ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived events for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	return scanLoanEvents(rows)
+}
+
+// GetArchivedRateHistory retrieves an archived loan's rate changes ordered by effective date.
+func (s *sqlStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	return s.rateHistory(ctx, "archived_rate_history", loanID)
+}
+
+// GetArchivedLoansToExport retrieves up to limit archived loans last updated before
+// closedBefore that have not been exported, least recently updated first.
+func (s *sqlStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+loanColumns+` FROM archived_loans WHERE exported_at IS NULL AND updated_at < ? ORDER BY updated_at ASC, id ASC LIMIT ?`,
+		closedBefore, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived loans to export: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanLoans(rows)
+}
+
+// MarkArchivedLoansExported records that the archived loans were exported at exportedAt.
+func (s *sqlStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []any{exportedAt}
+	for _, id := range ids {
+		args = append(args, id.String())
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE archived_loans SET exported_at = ? WHERE id IN (`+placeholders(len(ids))+`)`, args...); err != nil {
+		return fmt.Errorf("failed to mark archived loans exported: %w", err)
+	}
+	return nil
+}
+
+// DeleteArchivedLoans removes archived loans with their records from the archive tables
+// within a single database transaction.
+func (s *sqlStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id.String()
+	}
+	in := `(` + placeholders(len(ids)) + `)`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, child := range loanChildTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM archived_`+child.table+` WHERE loan_id IN `+in, args...); err != nil {
+			return fmt.Errorf("failed to delete archived %s: %w", child.table, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM archived_loans WHERE id IN `+in, args...); err != nil {
+		return fmt.Errorf("failed to delete archived loans: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit archive deletion: %w", err)
+	}
+	return nil
+}
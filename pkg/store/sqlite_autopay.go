@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// autopayColumns lists the autopay_enrollments columns in the order expected by scanAutopayEnrollment.
+const autopayColumns = `loan_id, amount_type, amount, day_of_month, payment_method_id, created_at, updated_at`
+
+func scanAutopayEnrollment(row rowScanner) (*models.AutopayEnrollment, error) {
+	var enrollment models.AutopayEnrollment
+	var loanIDStr, methodIDStr string
+	err := row.Scan(&loanIDStr, &enrollment.AmountType, &enrollment.Amount, &enrollment.DayOfMonth, &methodIDStr, &enrollment.CreatedAt, &enrollment.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	enrollment.LoanID = uuid.MustParse(loanIDStr)
+	enrollment.PaymentMethodID = uuid.MustParse(methodIDStr)
+	return &enrollment, nil
+}
+
+// SaveAutopayEnrollment creates or replaces a loan's autopay enrollment.
+func (s *sqlStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	_, err := s.db.ExecContext(ctx,
+		upsert("autopay_enrollments", autopayColumns, "loan_id"),
+		enrollment.LoanID.String(), enrollment.AmountType, enrollment.Amount, enrollment.DayOfMonth, enrollment.PaymentMethodID.String(), enrollment.CreatedAt, enrollment.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save autopay enrollment: %w", err)
+	}
+	return nil
+}
+
+// GetAutopayEnrollment retrieves a loan's autopay enrollment, or nil if it is not enrolled.
+func (s *sqlStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	enrollment, err := scanAutopayEnrollment(s.db.QueryRowContext(ctx, `SELECT `+autopayColumns+` FROM autopay_enrollments WHERE loan_id = ?`, loanID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get autopay enrollment: %w", err)
+	}
+	return enrollment, nil
+}
+
+// DeleteAutopayEnrollment removes a loan's autopay enrollment.
+func (s *sqlStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM autopay_enrollments WHERE loan_id = ?`, loanID.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete autopay enrollment: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrNotEnrolledInAutopay
+	}
+	return nil
+}
+
+// GetAutopayEnrollmentsForDay retrieves the enrollments scheduled for a day of the month.
+func (s *sqlStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+autopayColumns+` FROM autopay_enrollments WHERE day_of_month = ?`, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get autopay enrollments: %w", err)
+	}
+	defer rows.Close()
+
+	var enrollments []*models.AutopayEnrollment
+	for rows.Next() {
+		enrollment, err := scanAutopayEnrollment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan autopay enrollment row: %w", err)
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for autopay enrollments: %w", err)
+	}
+	return enrollments, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// Backuper is a database that can be copied while it is in use, and have its contents
+// replaced by such a copy.
+type Backuper interface {
+	// Backup writes a consistent snapshot of the database to a new file at path.
+	Backup(ctx context.Context, path string) error
+	// Restore replaces the database's contents with the backup at path and migrates it to the
+	// latest schema, returning the schema version it is left at. It refuses a backup that is
+	// damaged, is not of a ledger, or is at a schema version newer than this build knows.
+	Restore(ctx context.Context, path string) (int, error)
+	Close() error
+}
+
+var _ Backuper = (*SQLiteStore)(nil)
+
+// OpenBackuper connects to a database for backing it up or restoring it, leaving its schema
+// as it is. Only SQLite databases are backed up this way; the server databases have tools
+// of their own, such as pg_dump and mysqldump.
+func OpenBackuper(driver string, dataSourceName string) (Backuper, error) {
+	if driver != DriverSQLite && driver != "" {
+		return nil, fmt.Errorf("database driver %q has no online backup; use the database's own tools", driver)
+	}
+	return openSQLite(dataSourceName)
+}
+
+// Backup copies the database to a new file at path with SQLite's online backup API. The
+// copy is taken in one step under a read transaction, which in WAL mode leaves writers free
+// to carry on, so the snapshot is consistent without stopping the server.
+func (s *SQLiteStore) Backup(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+	// An encrypted database is backed up encrypted with the same key
+	dest := openSQLiteDB(path, s.key)
+	err := copySQLite(ctx, dest, s.db.DB)
+	if err == nil {
+		// The copy takes the database's WAL mode with it; a backup is better as one file
+		_, err = dest.ExecContext(ctx, `PRAGMA journal_mode=DELETE`)
+	}
+	if closeErr := dest.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// Restore checks the backup at path, copies it over the database with the online backup API
+// and migrates the result. Connections the server holds see the restored data once it is
+// done, but the server should be stopped first all the same: anything it writes while the
+// restore runs is lost.
+func (s *SQLiteStore) Restore(ctx context.Context, path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("could not read backup: %w", err)
+	}
+	src := openSQLiteDB("file:"+(&url.URL{Path: path}).EscapedPath()+"?mode=ro", s.key)
+	defer src.Close()
+
+	if err := checkSQLiteBackup(ctx, src); err != nil {
+		return 0, err
+	}
+	if err := copySQLite(ctx, s.db.DB, src); err != nil {
+		return 0, fmt.Errorf("failed to restore database: %w", err)
+	}
+	if _, err := s.Migrate(ctx); err != nil {
+		return 0, fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	if err := s.registerLoanCustomers(ctx); err != nil {
+		return 0, err
+	}
+	return schemaVersion(ctx, s.sqlStore)
+}
+
+// checkSQLiteBackup makes sure a backup is a sound ledger database at a schema version this
+// build can migrate from.
+func checkSQLiteBackup(ctx context.Context, db *sql.DB) error {
+	var integrity string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&integrity); err != nil {
+		return fmt.Errorf("backup is not a SQLite database: %w", err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("backup failed its integrity check: %s", integrity)
+	}
+
+	backup := &sqlStore{db: &sqlDB{DB: db}, migrations: sqliteMigrations}
+	var tables int
+	if err := db.QueryRowContext(ctx, sqliteMigrations.tableExists, "loans").Scan(&tables); err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if tables == 0 {
+		return errors.New("backup is not a ledger database: it has no loans table")
+	}
+	version, err := schemaVersion(ctx, backup)
+	if err != nil {
+		return fmt.Errorf("failed to read backup's schema version: %w", err)
+	}
+	list, err := sqliteMigrations.load()
+	if err != nil {
+		return err
+	}
+	if latest := list[len(list)-1].version; version > latest {
+		return fmt.Errorf("backup is at schema version %d, newer than this build's %d", version, latest)
+	}
+	return nil
+}
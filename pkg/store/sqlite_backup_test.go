@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestSQLiteStore_BackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile, backupFile := "test_store_backup.db", "test_store_backup.bak.db"
+	for _, f := range []string{dbFile, backupFile} {
+		os.Remove(f)
+		defer os.Remove(f)
+	}
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_backup", Principal: decimal.NewFromInt(1000), Balance: decimal.NewFromInt(1000), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now, Version: 1}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	if err := s.Backup(ctx, backupFile); err != nil {
+		t.Fatalf("Failed to back up: %v", err)
+	}
+	if err := s.Backup(ctx, backupFile); err == nil {
+		t.Error("Expected a backup not to overwrite an existing file")
+	}
+
+	// Changes made after the backup are undone by restoring it
+	loan.Balance = decimal.NewFromInt(400)
+	s.UpdateLoan(ctx, loan)
+	later := &models.Loan{ID: uuid.New(), CustomerKey: "cust_backup", Balance: decimal.NewFromInt(50), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now, Version: 1}
+	s.CreateLoan(ctx, later)
+
+	version, err := s.Restore(ctx, backupFile)
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	list, _ := sqliteMigrations.load()
+	if latest := list[len(list)-1].version; version != latest {
+		t.Errorf("Expected the restored schema at version %d, got %d", latest, version)
+	}
+	fetched, err := s.GetLoan(ctx, loan.ID)
+	if err != nil || !fetched.Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected the backed up balance of 1000, got %v (%v)", fetched, err)
+	}
+	if _, err := s.GetLoan(ctx, later.ID); err != models.ErrLoanNotFound {
+		t.Errorf("Expected the later loan gone, got %v", err)
+	}
+
+	// A backup from a newer build, or of something other than a ledger, is refused
+	backup, err := sql.Open("sqlite3", backupFile)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	backup.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (9999, 'future', ?)`, now)
+	backup.Close()
+	if _, err := s.Restore(ctx, backupFile); err == nil {
+		t.Error("Expected a backup at a newer schema version to be refused")
+	}
+	os.Remove(backupFile)
+	other, _ := sql.Open("sqlite3", backupFile)
+	other.ExecContext(ctx, `CREATE TABLE notes (body TEXT)`)
+	other.Close()
+	if _, err := s.Restore(ctx, backupFile); err == nil {
+		t.Error("Expected a database without loans to be refused")
+	}
+	if _, err := s.Restore(ctx, "test_store_backup.missing.db"); err == nil {
+		t.Error("Expected a missing backup to be refused")
+	}
+	if fetched, err := s.GetLoan(ctx, loan.ID); err != nil || !fetched.Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected refused restores to leave the database alone, got %v (%v)", fetched, err)
+	}
+}
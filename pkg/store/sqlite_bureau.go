@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// bureauRecordColumns lists the bureau_records columns in the order expected by scanBureauRecord.
+const bureauRecordColumns = `loan_id, period, customer_key, product_code, date_opened, original_amount, current_balance, term_months, account_status, days_past_due, payment_history, as_of, created_at`
+
+func scanBureauRecord(row rowScanner) (*models.BureauRecord, error) {
+	var record models.BureauRecord
+	var loanIDStr string
+	err := row.Scan(&loanIDStr, &record.Period, &record.CustomerKey, &record.ProductCode, &record.DateOpened, &record.OriginalAmount, &record.CurrentBalance,
+		&record.TermMonths, &record.AccountStatus, &record.DaysPastDue, &record.PaymentHistory, &record.AsOf, &record.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	record.LoanID = uuid.MustParse(loanIDStr)
+	return &record, nil
+}
+
+// SaveBureauRecord stores a loan's record for a reporting period, replacing any earlier
+// record for the same loan and period.
+func (s *sqlStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	_, err := s.db.ExecContext(ctx,
+		upsert("bureau_records", bureauRecordColumns, "loan_id", "period"),
+		record.LoanID.String(), record.Period, record.CustomerKey, record.ProductCode, record.DateOpened, record.OriginalAmount, record.CurrentBalance,
+		record.TermMonths, record.AccountStatus, record.DaysPastDue, record.PaymentHistory, record.AsOf, record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save bureau record: %w", err)
+	}
+	return nil
+}
+
+// GetBureauRecordsForLoan retrieves a loan's bureau records, most recent period first.
+func (s *sqlStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+bureauRecordColumns+` FROM bureau_records WHERE loan_id = ? ORDER BY period DESC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bureau records for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	var records []*models.BureauRecord
+	for rows.Next() {
+		record, err := scanBureauRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bureau record row: %w", err)
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for bureau records: %w", err)
+	}
+	return records, nil
+}
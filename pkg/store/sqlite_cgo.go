@@ -0,0 +1,79 @@
+//go:build cgo
+
+package store
+
+// The parts of the SQLite store that need go-sqlite3's cgo API. Builds without cgo get the
+// stand-ins in sqlite_nocgo.go, and can use every database but SQLite.
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// keySQLite gives a new connection the key of its encrypted database, and checks that the
+// key opens it.
+func keySQLite(conn *sqlite3.SQLiteConn, key string) error {
+	if _, err := conn.Exec(`PRAGMA key = '`+strings.ReplaceAll(key, "'", "''")+`'`, nil); err != nil {
+		return fmt.Errorf("failed to set encryption key: %w", err)
+	}
+	// SQLite ignores pragmas it does not know, so without this the database would be
+	// written in plaintext
+	rows, err := conn.Query(`PRAGMA cipher_version`, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check for SQLCipher: %w", err)
+	}
+	err = rows.Next(make([]driver.Value, len(rows.Columns())))
+	rows.Close()
+	if errors.Is(err, io.EOF) {
+		return errors.New("an encryption key is set but this build's SQLite is not SQLCipher")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for SQLCipher: %w", err)
+	}
+	if _, err := conn.Exec(`SELECT count(*) FROM sqlite_master`, nil); err != nil {
+		return fmt.Errorf("the encryption key does not open the database: %w", err)
+	}
+	return nil
+}
+
+// copySQLite copies the main database of src over that of dest with SQLite's backup API.
+func copySQLite(ctx context.Context, dest *sql.DB, src *sql.DB) error {
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(destDriver any) error {
+		return srcConn.Raw(func(srcDriver any) error {
+			backup, err := destDriver.(*sqlite3.SQLiteConn).Backup("main", srcDriver.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
+// sqliteBusy reports whether err is SQLite turning a call away because another connection
+// holds a lock it needs.
+func sqliteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteKeyParam is the SQLite data source parameter holding the key of a database encrypted
+// with SQLCipher. It is taken out of the data source before go-sqlite3 parses it, and given
+// to each connection with PRAGMA key.
+const sqliteKeyParam = "_key"
+
+// WithEncryptionKey returns a SQLite data source that opens the database encrypted at rest
+// with key, a passphrase SQLCipher derives the database key from. An empty key leaves the
+// data source as it is. Encryption needs a build linked against SQLCipher rather than the
+// SQLite go-sqlite3 bundles (the libsqlite3 build tag); other builds refuse to open the
+// database instead of leaving it in plaintext.
+func WithEncryptionKey(driver string, dataSourceName string, key string) (string, error) {
+	if key == "" {
+		return dataSourceName, nil
+	}
+	if driver != DriverSQLite && driver != "" {
+		return "", fmt.Errorf("database driver %q cannot be encrypted by the ledger; use the database's own encryption at rest", driver)
+	}
+	dsn, params := splitSQLiteParams(dataSourceName)
+	params.Set(sqliteKeyParam, key)
+	return dsn + "?" + params.Encode(), nil
+}
+
+// splitSQLiteParams splits a SQLite data source into its file and parameters the way
+// go-sqlite3 does, at the first question mark.
+func splitSQLiteParams(dataSourceName string) (string, url.Values) {
+	dsn, query, ok := strings.Cut(dataSourceName, "?")
+	if !ok {
+		return dataSourceName, url.Values{}
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return dataSourceName, url.Values{} // Left for go-sqlite3 to report
+	}
+	return dsn, params
+}
+
+// splitSQLiteKey returns a SQLite data source without its encryption key, and the key.
+func splitSQLiteKey(dataSourceName string) (string, string) {
+	dsn, params := splitSQLiteParams(dataSourceName)
+	key := params.Get(sqliteKeyParam)
+	if key == "" {
+		return dataSourceName, ""
+	}
+	params.Del(sqliteKeyParam)
+	if len(params) > 0 {
+		dsn += "?" + params.Encode()
+	}
+	return dsn, key
+}
+
+// openSQLiteDB opens a pool of connections to a SQLite database, each keyed with key if it
+// is set.
+func openSQLiteDB(dataSourceName string, key string) *sql.DB {
+	d := &sqlite3.SQLiteDriver{}
+	if key != "" {
+		d.ConnectHook = func(conn *sqlite3.SQLiteConn) error {
+			return keySQLite(conn, key)
+		}
+	}
+	return sql.OpenDB(sqliteConnector{dsn: dataSourceName, driver: d})
+}
+
+// sqliteConnector connects to a SQLite database through a driver of its own, so that
+// databases with different keys can be open at once.
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithEncryptionKey(t *testing.T) {
+	dsn, err := WithEncryptionKey(DriverSQLite, "file:fredloan.db?_busy_timeout=5000", "it's secret&")
+	if err != nil {
+		t.Fatalf("Failed to add key: %v", err)
+	}
+	if plain, key := splitSQLiteKey(dsn); plain != "file:fredloan.db?_busy_timeout=5000" || key != "it's secret&" {
+		t.Errorf("Expected the data source and key back, got %q and %q", plain, key)
+	}
+	if plain, key := splitSQLiteKey("fredloan.db"); plain != "fredloan.db" || key != "" {
+		t.Errorf("Expected a data source without a key unchanged, got %q and %q", plain, key)
+	}
+	if unchanged, _ := WithEncryptionKey(DriverPostgres, "postgres://db/fredloan", ""); unchanged != "postgres://db/fredloan" {
+		t.Errorf("Expected no key to leave the data source alone, got %q", unchanged)
+	}
+	if _, err := WithEncryptionKey(DriverPostgres, "postgres://db/fredloan", "secret"); err == nil {
+		t.Error("Expected a key for PostgreSQL to be refused")
+	}
+}
+
+func TestSQLiteStore_EncryptionNeedsSQLCipher(t *testing.T) {
+	dbFile := "test_store_cipher.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	dsn, _ := WithEncryptionKey(DriverSQLite, dbFile, "secret")
+	s, err := NewSQLiteStore(dsn)
+	if err == nil {
+		// Built against SQLCipher: the key must be needed to read the database back
+		s.Close()
+		if plain, err := NewSQLiteStore(dbFile); err == nil {
+			plain.Close()
+			t.Error("Expected the encrypted database not to open without its key")
+		}
+		return
+	}
+	if !strings.Contains(err.Error(), "not SQLCipher") {
+		t.Errorf("Expected a key to be refused without SQLCipher, got %v", err)
+	}
+}
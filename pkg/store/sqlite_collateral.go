@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// collateralColumns lists the collateral columns in the order expected by scanCollateral.
+const collateralColumns = `id, loan_id, type, description, valuation, valuation_date, created_at, updated_at`
+
+func scanCollateral(row rowScanner) (*models.Collateral, error) {
+	var collateral models.Collateral
+	var idStr, loanIDStr string
+	err := row.Scan(&idStr, &loanIDStr, &collateral.Type, &collateral.Description, &collateral.Valuation, &collateral.ValuationDate, &collateral.CreatedAt, &collateral.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	collateral.ID = uuid.MustParse(idStr)
+	collateral.LoanID = uuid.MustParse(loanIDStr)
+	return &collateral, nil
+}
+
+// CreateCollateral inserts a new collateral record into the database.
+func (s *sqlStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO collateral (`+collateralColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		collateral.ID.String(), collateral.LoanID.String(), collateral.Type, collateral.Description, collateral.Valuation, collateral.ValuationDate, collateral.CreatedAt, collateral.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create collateral: %w", err)
+	}
+	return nil
+}
+
+// GetCollateral retrieves a collateral record by its ID.
+func (s *sqlStore) GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error) {
+	collateral, err := scanCollateral(s.db.QueryRowContext(ctx, `SELECT `+collateralColumns+` FROM collateral WHERE id = ?`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrCollateralNotFound
+		}
+		return nil, fmt.Errorf("failed to get collateral: %w", err)
+	}
+	return collateral, nil
+}
+
+// UpdateCollateral updates a collateral record's description and valuation.
+func (s *sqlStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE collateral SET type = ?, description = ?, valuation = ?, valuation_date = ?, updated_at = ? WHERE id = ?`,
+		collateral.Type, collateral.Description, collateral.Valuation, collateral.ValuationDate, collateral.UpdatedAt, collateral.ID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update collateral: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrCollateralNotFound
+	}
+	return nil
+}
+
+// DeleteCollateral removes a collateral record.
+func (s *sqlStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM collateral WHERE id = ?`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete collateral: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrCollateralNotFound
+	}
+	return nil
+}
+
+// GetCollateralForLoan retrieves the collateral securing a loan, oldest first.
+func (s *sqlStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+collateralColumns+` FROM collateral WHERE loan_id = ? ORDER BY created_at ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collateral for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	collateral := []*models.Collateral{}
+	for rows.Next() {
+		item, err := scanCollateral(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collateral row: %w", err)
+		}
+		collateral = append(collateral, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for collateral: %w", err)
+	}
+	return collateral, nil
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/shopspring/decimal"
+)
+
+const customerColumns = `id, customer_key, name, email, phone, address, status, created_at, updated_at`
+
+func scanCustomer(row rowScanner) (*models.Customer, error) {
+	var customer models.Customer
+	var idStr string
+	if err := row.Scan(&idStr, &customer.CustomerKey, &customer.Name, &customer.Email, &customer.Phone, &customer.Address, &customer.Status, &customer.CreatedAt, &customer.UpdatedAt); err != nil {
+		return nil, err
+	}
+	customer.ID = uuid.MustParse(idStr)
+	return &customer, nil
+}
+
+// CreateCustomer inserts a new customer into the database, failing with
+// models.ErrCustomerExists if the customer key is taken.
+func (s *sqlStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO customers (`+customerColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(customer_key) DO NOTHING`,
+		customer.ID.String(), customer.CustomerKey, customer.Name, customer.Email, customer.Phone, customer.Address, customer.Status, customer.CreatedAt, customer.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create customer: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrCustomerExists
+	}
+	return nil
+}
+
+// GetCustomer retrieves a customer by their customer key.
+func (s *sqlStore) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	customer, err := scanCustomer(s.db.QueryRowContext(ctx, `SELECT `+customerColumns+` FROM customers WHERE customer_key = ?`, customerKey))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrCustomerNotFound
+		}
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	return customer, nil
+}
+
+// UpdateCustomer updates an existing customer's details and status.
+func (s *sqlStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE customers SET name = ?, email = ?, phone = ?, address = ?, status = ?, updated_at = ? WHERE customer_key = ?`,
+		customer.Name, customer.Email, customer.Phone, customer.Address, customer.Status, customer.UpdatedAt, customer.CustomerKey,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update customer: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrCustomerNotFound
+	}
+	return nil
+}
+
+// DeleteCustomer deletes a customer that no loan, archived or not, refers to.
+func (s *sqlStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM customers WHERE customer_key = ?
+		AND NOT EXISTS (SELECT 1 FROM loans WHERE customer_key = ?)
+		AND NOT EXISTS (SELECT 1 FROM archived_loans WHERE customer_key = ?)`,
+		customerKey, customerKey, customerKey)
+	if err != nil {
+		return fmt.Errorf("failed to delete customer: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		// Either there is no such customer or their loans kept them
+		if _, err := s.GetCustomer(ctx, customerKey); err != nil {
+			return err
+		}
+		return models.ErrCustomerHasLoans
+	}
+	return nil
+}
+
+// GetAllCustomers retrieves all customers ordered by customer key.
+func (s *sqlStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+customerColumns+` FROM customers ORDER BY customer_key`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all customers: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []*models.Customer
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return customers, nil
+}
+
+// registerCustomer adds an active customer with the given key unless one exists, so that a
+// loan may refer to it.
+func registerCustomer(ctx context.Context, tx *sqlTx, customerKey string, at time.Time) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO customers (id, customer_key, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?) ON CONFLICT(customer_key) DO NOTHING`,
+		uuid.New().String(), customerKey, models.CustomerStatusActive, at, at,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register customer %s: %w", customerKey, err)
+	}
+	return nil
+}
+
+// registerLoanCustomers registers a customer for every customer key that loans in a database
+// from before customers were stored refer to.
+func (s *sqlStore) registerLoanCustomers(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT customer_key FROM loans WHERE customer_key NOT IN (SELECT customer_key FROM customers)
+		UNION SELECT customer_key FROM archived_loans WHERE customer_key NOT IN (SELECT customer_key FROM customers)`)
+	if err != nil {
+		return fmt.Errorf("failed to find unregistered customers: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan unregistered customer: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error during rows iteration for unregistered customers: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	for _, key := range keys {
+		if err := registerCustomer(ctx, tx, key, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCustomerSummary totals a customer's loans and lists the statement cycle day of each
+// open loan. Statement dates are left for the caller to compute.
+func (s *sqlStore) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	summary := &models.CustomerSummary{CustomerKey: customerKey, NextStatements: []models.CustomerNextStatement{}}
+
+	// Amounts are stored as text, so they are cast for the aggregate and rounded back to cents
+	var balance, accrued float64
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*),
+		COALESCE(SUM(CASE WHEN status IN ('active', 'delinquent') THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN status IN ('active', 'delinquent') THEN CAST(balance AS REAL) END), 0),
+		COALESCE(SUM(CASE WHEN status IN ('active', 'delinquent') THEN CAST(accrued_interest AS REAL) END), 0)
+		FROM loans WHERE customer_key = ? AND status != 'voided'`, customerKey).Scan(&summary.LoanCount, &summary.OpenLoanCount, &balance, &accrued)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize loans for customer %s: %w", customerKey, err)
+	}
+	summary.OutstandingBalance = decimal.NewFromFloat(balance).Round(2)
+	summary.AccruedInterest = decimal.NewFromFloat(accrued).Round(2)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, statement_cycle_day FROM loans WHERE customer_key = ? AND status IN ('active', 'delinquent') ORDER BY statement_cycle_day ASC, id ASC`, customerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statement cycles for customer %s: %w", customerKey, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var next models.CustomerNextStatement
+		var loanIDStr string
+		if err := rows.Scan(&loanIDStr, &next.StatementCycleDay); err != nil {
+			return nil, fmt.Errorf("failed to scan statement cycle row: %w", err)
+		}
+		next.LoanID = uuid.MustParse(loanIDStr)
+		summary.NextStatements = append(summary.NextStatements, next)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for statement cycles: %w", err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// loanEventColumns lists the loan event columns in the order expected by scanLoanEvents.
+const loanEventColumns = `id, loan_id, type, description, author, timestamp`
+
+// CreateLoanEvent inserts a new loan event into the database.
+func (s *sqlStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO loan_events (`+loanEventColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		event.ID.String(), event.LoanID.String(), event.Type, event.Description, event.Author, event.Timestamp,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create loan event: %w", err)
+	}
+	return nil
+}
+
+// GetLoanEventsForLoan retrieves all events for a given loan ID in chronological order.
+func (s *sqlStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+loanEventColumns+` FROM loan_events WHERE loan_id = ? ORDER BY timestamp ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get events for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	return scanLoanEvents(rows)
+}
+
+func scanLoanEvents(rows *sql.Rows) ([]*models.LoanEvent, error) {
+	var events []*models.LoanEvent
+	for rows.Next() {
+		var event models.LoanEvent
+		var eventIDStr, loanIDStr string
+		if err := rows.Scan(&eventIDStr, &loanIDStr, &event.Type, &event.Description, &event.Author, &event.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan loan event row: %w", err)
+		}
+		event.ID = uuid.MustParse(eventIDStr)
+		event.LoanID = uuid.MustParse(loanIDStr)
+		events = append(events, &event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for loan events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// forbearanceColumns lists the forbearances columns in the order expected by scanForbearance.
+const forbearanceColumns = `id, loan_id, start_date, end_date, rate, reason, created_by, created_at`
+
+func scanForbearance(row rowScanner) (*models.Forbearance, error) {
+	var forbearance models.Forbearance
+	var idStr, loanIDStr string
+	err := row.Scan(&idStr, &loanIDStr, &forbearance.StartDate, &forbearance.EndDate, &forbearance.Rate, &forbearance.Reason, &forbearance.CreatedBy, &forbearance.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	forbearance.ID = uuid.MustParse(idStr)
+	forbearance.LoanID = uuid.MustParse(loanIDStr)
+	return &forbearance, nil
+}
+
+// CreateForbearance inserts a new forbearance window into the database.
+func (s *sqlStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO forbearances (`+forbearanceColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		forbearance.ID.String(), forbearance.LoanID.String(), forbearance.StartDate, forbearance.EndDate, forbearance.Rate, forbearance.Reason, forbearance.CreatedBy, forbearance.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create forbearance: %w", err)
+	}
+	return nil
+}
+
+// GetForbearancesForLoan retrieves a loan's forbearance windows in start date order.
+func (s *sqlStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+forbearanceColumns+` FROM forbearances WHERE loan_id = ? ORDER BY start_date ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get forbearances for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	forbearances := []*models.Forbearance{}
+	for rows.Next() {
+		forbearance, err := scanForbearance(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan forbearance row: %w", err)
+		}
+		forbearances = append(forbearances, forbearance)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for forbearances: %w", err)
+	}
+	return forbearances, nil
+}
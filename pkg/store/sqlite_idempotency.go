@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// idempotencyColumns lists the idempotency_keys columns in the order expected by scanIdempotencyRecord.
+const idempotencyColumns = `key, request_hash, status_code, content_type, body, created_at, completed_at, applied_at`
+
+func scanIdempotencyRecord(row rowScanner) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	err := row.Scan(&record.Key, &record.RequestHash, &record.StatusCode, &record.ContentType, &record.Body, &record.CreatedAt, &record.CompletedAt, &record.AppliedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// CreateIdempotencyRecord claims a key, failing with models.ErrIdempotencyKeyExists if it
+// was claimed before.
+func (s *sqlStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	result, err := s.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (`+idempotencyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (key) DO NOTHING`,
+		record.Key, record.RequestHash, record.StatusCode, record.ContentType, record.Body, record.CreatedAt, record.CompletedAt, record.AppliedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create idempotency record: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+// GetIdempotencyRecord returns the record for a key, or nil if the key is unused.
+func (s *sqlStore) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+idempotencyColumns+` FROM idempotency_keys WHERE key = ?`, key)
+	record, err := scanIdempotencyRecord(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+	return record, nil
+}
+
+// UpdateIdempotencyRecord stores the response recorded for a key, and when its request took
+// effect.
+func (s *sqlStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE idempotency_keys SET status_code = ?, content_type = ?, body = ?, completed_at = ?, applied_at = ? WHERE key = ?`,
+		record.StatusCode, record.ContentType, record.Body, record.CompletedAt, record.AppliedAt, record.Key)
+	if err != nil {
+		return fmt.Errorf("failed to update idempotency record: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrIdempotencyRecordNotFound
+	}
+	return nil
+}
+
+// DeleteIdempotencyRecord releases a key so it can be used again.
+func (s *sqlStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = ?`, key); err != nil {
+		return fmt.Errorf("failed to delete idempotency record: %w", err)
+	}
+	return nil
+}
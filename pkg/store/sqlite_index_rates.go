@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// indexRateColumns lists the index_rates columns in the order expected by scanIndexRate.
+const indexRateColumns = `id, index_code, rate, observation_date, source, created_at`
+
+func scanIndexRate(row rowScanner) (*models.IndexRate, error) {
+	var rate models.IndexRate
+	var idStr string
+	if err := row.Scan(&idStr, &rate.IndexCode, &rate.Rate, &rate.ObservationDate, &rate.Source, &rate.CreatedAt); err != nil {
+		return nil, err
+	}
+	rate.ID = uuid.MustParse(idStr)
+	return &rate, nil
+}
+
+// CreateIndexRate records a published observation of a benchmark rate.
+func (s *sqlStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO index_rates (`+indexRateColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		rate.ID.String(), rate.IndexCode, rate.Rate, rate.ObservationDate.UTC(), rate.Source, rate.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create index rate: %w", err)
+	}
+	return nil
+}
+
+// GetLatestIndexRate returns the most recent observation for an index, or nil if none has
+// been published.
+func (s *sqlStore) GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+indexRateColumns+` FROM index_rates WHERE index_code = ? ORDER BY observation_date DESC, created_at DESC LIMIT 1`, indexCode)
+	rate, err := scanIndexRate(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest rate for index %s: %w", indexCode, err)
+	}
+	return rate, nil
+}
+
+// GetIndexRates retrieves every observation for an index ordered by observation date.
+func (s *sqlStore) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+indexRateColumns+` FROM index_rates WHERE index_code = ? ORDER BY observation_date ASC, created_at ASC`, indexCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rates for index %s: %w", indexCode, err)
+	}
+	defer rows.Close()
+
+	var rates []*models.IndexRate
+	for rows.Next() {
+		rate, err := scanIndexRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan index rate row: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for index rates: %w", err)
+	}
+	return rates, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// interestIntentColumns lists the interest_intents columns in the order expected by scanInterestIntent.
+const interestIntentColumns = `id, loan_id, cycle, amount, transaction_id, status, created_at, completed_at`
+
+func scanInterestIntent(row rowScanner) (*models.InterestIntent, error) {
+	var intent models.InterestIntent
+	var idStr, loanIDStr, txIDStr string
+	err := row.Scan(&idStr, &loanIDStr, &intent.Cycle, &intent.Amount, &txIDStr, &intent.Status, &intent.CreatedAt, &intent.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	intent.ID = uuid.MustParse(idStr)
+	intent.LoanID = uuid.MustParse(loanIDStr)
+	intent.TransactionID = uuid.MustParse(txIDStr)
+	return &intent, nil
+}
+
+func (s *sqlStore) queryInterestIntents(ctx context.Context, query string, args ...interface{}) ([]*models.InterestIntent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+interestIntentColumns+` FROM interest_intents `+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interest intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []*models.InterestIntent
+	for rows.Next() {
+		intent, err := scanInterestIntent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan interest intent row: %w", err)
+		}
+		intents = append(intents, intent)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for interest intents: %w", err)
+	}
+	return intents, nil
+}
+
+// CreateInterestIntent records an intent to post interest to a loan for a statement cycle.
+func (s *sqlStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO interest_intents (`+interestIntentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		intent.ID.String(), intent.LoanID.String(), intent.Cycle, intent.Amount, intent.TransactionID.String(), intent.Status, intent.CreatedAt, intent.CompletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create interest intent: %w", err)
+	}
+	return nil
+}
+
+// UpdateInterestIntent updates an intent's status and completion time.
+func (s *sqlStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE interest_intents SET status = ?, completed_at = ? WHERE id = ?`, intent.Status, intent.CompletedAt, intent.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to update interest intent: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.ErrInterestIntentNotFound
+	}
+	return nil
+}
+
+// GetInterestIntent returns the loan's intent for a statement cycle, or nil if none was recorded.
+func (s *sqlStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+interestIntentColumns+` FROM interest_intents WHERE loan_id = ? AND cycle = ?`, loanID.String(), cycle)
+	intent, err := scanInterestIntent(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get interest intent for loan %s: %w", loanID, err)
+	}
+	return intent, nil
+}
+
+// GetInterestIntentsByStatus retrieves intents with the given status in creation order.
+func (s *sqlStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	return s.queryInterestIntents(ctx, `WHERE status = ? ORDER BY created_at ASC`, status)
+}
+
+// GetInterestIntentsForCycle retrieves every intent recorded for a statement cycle.
+func (s *sqlStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error) {
+	return s.queryInterestIntents(ctx, `WHERE cycle = ? ORDER BY created_at ASC`, cycle)
+}
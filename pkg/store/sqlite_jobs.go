@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// jobRunColumns lists the job_runs columns in the order expected by scanJobRun.
+const jobRunColumns = `id, job, triggered_by, date, loan_id, started_at, finished_at, processed, skipped, failed, errors`
+
+func scanJobRun(row rowScanner) (*models.JobRun, error) {
+	var run models.JobRun
+	var idStr, errs string
+	if err := row.Scan(&idStr, &run.Job, &run.Trigger, &run.Date, &run.LoanID, &run.StartedAt, &run.FinishedAt, &run.Processed, &run.Skipped, &run.Failed, &errs); err != nil {
+		return nil, err
+	}
+	run.ID = uuid.MustParse(idStr)
+	if err := json.Unmarshal([]byte(errs), &run.Errors); err != nil {
+		return nil, fmt.Errorf("failed to decode job run errors: %w", err)
+	}
+	return &run, nil
+}
+
+// CreateJobRun records a finished run of a batch job.
+func (s *sqlStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	errs, err := json.Marshal(run.Errors)
+	if err != nil {
+		return fmt.Errorf("failed to encode job run errors: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO job_runs (`+jobRunColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID.String(), run.Job, run.Trigger, run.Date, run.LoanID, run.StartedAt, run.FinishedAt, run.Processed, run.Skipped, run.Failed, string(errs),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create job run: %w", err)
+	}
+	return nil
+}
+
+// GetJobRuns retrieves up to limit runs of a job, or of every job when job is empty, most
+// recent first.
+func (s *sqlStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+jobRunColumns+` FROM job_runs WHERE ? = '' OR job = ? ORDER BY julianday(started_at) DESC LIMIT ?`,
+		job, job, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.JobRun
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job run row: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for job runs: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// ledgerEventColumns lists the ledger_events columns in the order expected by
+// scanLedgerEvent.
+const ledgerEventColumns = `id, loan_id, sequence_number, type, amount, transaction_id, ` +
+	`balance_change, accrued_interest_change, interest_due_change, fees_due_change, escrow_balance_change, ` +
+	`balance, accrued_interest, interest_due, fees_due, escrow_balance, occurred_at`
+
+func scanLedgerEvent(row rowScanner) (*models.LedgerEvent, error) {
+	var event models.LedgerEvent
+	var eventIDStr, loanIDStr string
+	changes, amounts := &event.Changes, &event.Amounts
+	if err := row.Scan(&eventIDStr, &loanIDStr, &event.Sequence, &event.Type, &event.Amount, &event.TransactionID,
+		&changes.Balance, &changes.AccruedInterest, &changes.InterestDue, &changes.FeesDue, &changes.EscrowBalance,
+		&amounts.Balance, &amounts.AccruedInterest, &amounts.InterestDue, &amounts.FeesDue, &amounts.EscrowBalance, &event.OccurredAt); err != nil {
+		return nil, err
+	}
+	event.ID = uuid.MustParse(eventIDStr)
+	event.LoanID = uuid.MustParse(loanIDStr)
+	return &event, nil
+}
+
+// AppendLedgerEvent adds an event to the end of its loan's event log, numbering it after the
+// loan's last event. Two appends racing for the same number conflict on the primary key, so
+// one fails rather than both taking it.
+func (s *sqlStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	var sequence int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(sequence_number), 0) + 1 FROM ledger_events WHERE loan_id = ?`, event.LoanID.String()).Scan(&sequence); err != nil {
+		return fmt.Errorf("failed to number ledger event: %w", err)
+	}
+	changes, amounts := event.Changes, event.Amounts
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO ledger_events (`+ledgerEventColumns+`) VALUES (`+placeholders(17)+`)`,
+		event.ID.String(), event.LoanID.String(), sequence, event.Type, event.Amount, event.TransactionID,
+		changes.Balance, changes.AccruedInterest, changes.InterestDue, changes.FeesDue, changes.EscrowBalance,
+		amounts.Balance, amounts.AccruedInterest, amounts.InterestDue, amounts.FeesDue, amounts.EscrowBalance, event.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append ledger event: %w", err)
+	}
+	event.Sequence = sequence
+	return nil
+}
+
+// GetLedgerEvents retrieves a loan's event log in sequence order.
+func (s *sqlStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+ledgerEventColumns+` FROM ledger_events WHERE loan_id = ? ORDER BY sequence_number ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger events for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	events := []*models.LedgerEvent{}
+	for rows.Next() {
+		event, err := scanLedgerEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ledger event row: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for ledger events: %w", err)
+	}
+	return events, nil
+}
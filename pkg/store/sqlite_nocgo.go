@@ -0,0 +1,28 @@
+//go:build !cgo
+
+package store
+
+// Stand-ins for the parts of the SQLite store in sqlite_cgo.go. Without cgo, go-sqlite3 is a
+// stub that fails to open any database, so these are never reached with a connection.
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var errSQLiteNoCgo = errors.New("SQLite needs a build with cgo; use the bolt driver for builds without it")
+
+func keySQLite(conn *sqlite3.SQLiteConn, key string) error {
+	return errSQLiteNoCgo
+}
+
+func copySQLite(ctx context.Context, dest *sql.DB, src *sql.DB) error {
+	return errSQLiteNoCgo
+}
+
+func sqliteBusy(err error) bool {
+	return false
+}
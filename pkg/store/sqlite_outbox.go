@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// CreateOutboxEvent writes an event to the outbox.
+func (s *sqlStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO outbox_events (id, event_type, payload, created_at, published_at) VALUES (?, ?, ?, ?, ?)`,
+		event.ID.String(), event.Type, event.Payload, event.CreatedAt, event.PublishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox event: %w", err)
+	}
+	return nil
+}
+
+// GetPendingOutboxEvents retrieves up to limit unpublished events, oldest first.
+func (s *sqlStore) GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, event_type, payload, created_at, published_at FROM outbox_events
+		WHERE published_at IS NULL ORDER BY julianday(created_at) ASC, id ASC LIMIT ?`,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.OutboxEvent
+	for rows.Next() {
+		var event models.OutboxEvent
+		var idStr string
+		if err := rows.Scan(&idStr, &event.Type, &event.Payload, &event.CreatedAt, &event.PublishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		event.ID = uuid.MustParse(idStr)
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+// MarkOutboxEventPublished records that a pending event was published.
+func (s *sqlStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	result, err := s.db.ExecContext(ctx, `UPDATE outbox_events SET published_at = ? WHERE id = ? AND published_at IS NULL`, at, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrOutboxEventPublished
+	}
+	return nil
+}
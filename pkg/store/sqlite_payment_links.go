@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// paymentLinkColumns lists the payment link columns in the order expected by scanPaymentLink.
+const paymentLinkColumns = `id, loan_id, min_amount, max_amount, status, expires_at, transaction_id, created_at, redeemed_at`
+
+func scanPaymentLink(row rowScanner) (*models.PaymentLink, error) {
+	var link models.PaymentLink
+	var idStr, loanIDStr string
+	if err := row.Scan(&idStr, &loanIDStr, &link.MinAmount, &link.MaxAmount, &link.Status, &link.ExpiresAt, &link.TransactionID, &link.CreatedAt, &link.RedeemedAt); err != nil {
+		return nil, err
+	}
+	link.ID = uuid.MustParse(idStr)
+	link.LoanID = uuid.MustParse(loanIDStr)
+	return &link, nil
+}
+
+// CreatePaymentLink inserts a new payment link into the database.
+func (s *sqlStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO payment_links (`+paymentLinkColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		link.ID.String(), link.LoanID.String(), link.MinAmount, link.MaxAmount, link.Status, link.ExpiresAt, link.TransactionID, link.CreatedAt, link.RedeemedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create payment link: %w", err)
+	}
+	return nil
+}
+
+// GetPaymentLink retrieves a payment link by its ID.
+func (s *sqlStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error) {
+	link, err := scanPaymentLink(s.db.QueryRowContext(ctx, `SELECT `+paymentLinkColumns+` FROM payment_links WHERE id = ?`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrPaymentLinkNotFound
+		}
+		return nil, fmt.Errorf("failed to get payment link: %w", err)
+	}
+	return link, nil
+}
+
+// UpdatePaymentLink records a payment link's redemption.
+func (s *sqlStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE payment_links SET status = ?, transaction_id = ?, redeemed_at = ? WHERE id = ?`,
+		link.Status, link.TransactionID, link.RedeemedAt, link.ID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update payment link: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrPaymentLinkNotFound
+	}
+	return nil
+}
+
+// RedeemPaymentLink records a payment link's redemption only while the link is active, so
+// that of two redemptions racing for a link just one succeeds.
+func (s *sqlStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE payment_links SET status = ?, transaction_id = ?, redeemed_at = ? WHERE id = ? AND status = ?`,
+		link.Status, link.TransactionID, link.RedeemedAt, link.ID.String(), models.PaymentLinkActive,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeem payment link: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		if _, err := s.GetPaymentLink(ctx, link.ID); err != nil {
+			return err
+		}
+		return models.ErrPaymentLinkRedeemed
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// paymentMethodColumns lists the payment method columns in the order expected by scanPaymentMethod.
+const paymentMethodColumns = `id, customer_key, type, token, last4, label, status, expires_at, created_at, updated_at`
+
+func scanPaymentMethod(row rowScanner) (*models.PaymentMethod, error) {
+	var method models.PaymentMethod
+	var idStr string
+	if err := row.Scan(&idStr, &method.CustomerKey, &method.Type, &method.Token, &method.Last4, &method.Label, &method.Status, &method.ExpiresAt, &method.CreatedAt, &method.UpdatedAt); err != nil {
+		return nil, err
+	}
+	method.ID = uuid.MustParse(idStr)
+	return &method, nil
+}
+
+// CreatePaymentMethod inserts a new payment method into the database.
+func (s *sqlStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO payment_methods (`+paymentMethodColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		method.ID.String(), method.CustomerKey, method.Type, method.Token, method.Last4, method.Label, method.Status, method.ExpiresAt, method.CreatedAt, method.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create payment method: %w", err)
+	}
+	return nil
+}
+
+// GetPaymentMethod retrieves a payment method by its ID.
+func (s *sqlStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	method, err := scanPaymentMethod(s.db.QueryRowContext(ctx, `SELECT `+paymentMethodColumns+` FROM payment_methods WHERE id = ?`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrPaymentMethodNotFound
+		}
+		return nil, fmt.Errorf("failed to get payment method: %w", err)
+	}
+	return method, nil
+}
+
+// UpdatePaymentMethod updates an existing payment method's status and display details.
+func (s *sqlStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE payment_methods SET last4 = ?, label = ?, status = ?, expires_at = ?, updated_at = ? WHERE id = ?`,
+		method.Last4, method.Label, method.Status, method.ExpiresAt, method.UpdatedAt, method.ID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update payment method: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrPaymentMethodNotFound
+	}
+	return nil
+}
+
+// GetPaymentMethodsForCustomer retrieves all payment methods registered to a customer.
+func (s *sqlStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+paymentMethodColumns+` FROM payment_methods WHERE customer_key = ? ORDER BY created_at ASC`, customerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment methods for customer %s: %w", customerKey, err)
+	}
+	defer rows.Close()
+
+	var methods []*models.PaymentMethod
+	for rows.Next() {
+		method, err := scanPaymentMethod(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment method row: %w", err)
+		}
+		methods = append(methods, method)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for payment methods: %w", err)
+	}
+	return methods, nil
+}
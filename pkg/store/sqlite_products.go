@@ -0,0 +1,84 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+const productColumns = `code, name, accrue_after_charge_off, odd_days_interest, minimum_payment_floor, minimum_payment_percent, accrual_grace_days, created_at, updated_at`
+
+func scanProduct(row rowScanner) (*models.Product, error) {
+	var product models.Product
+	if err := row.Scan(&product.Code, &product.Name, &product.AccrueAfterChargeOff, &product.OddDaysInterest, &product.MinimumPaymentFloor, &product.MinimumPaymentPercent, &product.AccrualGraceDays, &product.CreatedAt, &product.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
+
+// CreateProduct inserts a new product into the database.
+func (s *sqlStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO products (`+productColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		product.Code, product.Name, product.AccrueAfterChargeOff, product.OddDaysInterest, product.MinimumPaymentFloor, product.MinimumPaymentPercent, product.AccrualGraceDays, product.CreatedAt, product.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+	return nil
+}
+
+// GetProduct retrieves a product by its code.
+func (s *sqlStore) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	product, err := scanProduct(s.db.QueryRowContext(ctx, `SELECT `+productColumns+` FROM products WHERE code = ?`, code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrProductNotFound
+		}
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	return product, nil
+}
+
+// UpdateProduct updates an existing product in the database.
+func (s *sqlStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE products SET name = ?, accrue_after_charge_off = ?, odd_days_interest = ?, minimum_payment_floor = ?, minimum_payment_percent = ?, accrual_grace_days = ?, updated_at = ? WHERE code = ?`,
+		product.Name, product.AccrueAfterChargeOff, product.OddDaysInterest, product.MinimumPaymentFloor, product.MinimumPaymentPercent, product.AccrualGraceDays, product.UpdatedAt, product.Code,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrProductNotFound
+	}
+	return nil
+}
+
+// GetAllProducts retrieves all products ordered by code.
+func (s *sqlStore) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+productColumns+` FROM products ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all products: %w", err)
+	}
+	defer rows.Close()
+
+	var products []*models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan product row: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration: %w", err)
+	}
+	return products, nil
+}
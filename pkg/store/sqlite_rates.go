@@ -0,0 +1,79 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// rateChangeColumns lists the rate history columns in the order expected by scanRateChange.
+const rateChangeColumns = `id, loan_id, base_interest_rate, interest_rate_variance, interest_rate, effective_date, created_at`
+
+func scanRateChange(row rowScanner) (*models.RateChange, error) {
+	var change models.RateChange
+	var changeIDStr, loanIDStr string
+	if err := row.Scan(&changeIDStr, &loanIDStr, &change.BaseInterestRate, &change.InterestRateVariance, &change.InterestRate, &change.EffectiveDate, &change.CreatedAt); err != nil {
+		return nil, err
+	}
+	change.ID = uuid.MustParse(changeIDStr)
+	change.LoanID = uuid.MustParse(loanIDStr)
+	return &change, nil
+}
+
+// CreateRateChange inserts a new rate change into the loan's rate history.
+func (s *sqlStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO rate_history (`+rateChangeColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		change.ID.String(), change.LoanID.String(), change.BaseInterestRate, change.InterestRateVariance, change.InterestRate, change.EffectiveDate.UTC(), change.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create rate change: %w", err)
+	}
+	return nil
+}
+
+// GetRateHistory retrieves all rate changes for a loan ordered by effective date.
+func (s *sqlStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	return s.rateHistory(ctx, "rate_history", loanID)
+}
+
+// rateHistory retrieves a loan's rate changes from table, rate_history or its archive,
+// ordered by effective date.
+func (s *sqlStore) rateHistory(ctx context.Context, table string, loanID uuid.UUID) ([]*models.RateChange, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+rateChangeColumns+` FROM `+table+` WHERE loan_id = ? ORDER BY effective_date ASC, created_at ASC`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rate history for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	var changes []*models.RateChange
+	for rows.Next() {
+		change, err := scanRateChange(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rate change row: %w", err)
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for rate history: %w", err)
+	}
+	return changes, nil
+}
+
+// GetRateInEffect returns the most recent rate change effective on or before date, or nil
+// if none applies yet.
+func (s *sqlStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+rateChangeColumns+` FROM rate_history WHERE loan_id = ? AND effective_date <= ? ORDER BY effective_date DESC, created_at DESC LIMIT 1`, loanID.String(), date.UTC())
+	change, err := scanRateChange(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get rate in effect for loan %s: %w", loanID, err)
+	}
+	return change, nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// snapshotColumns lists the portfolio_snapshots columns in the order expected by scanSnapshot.
+const snapshotColumns = `date, outstanding_balance, accrued_interest, active_loans, delinquent_loans, delinquency_rate, originations, origination_volume, created_at`
+
+func scanSnapshot(row rowScanner) (*models.PortfolioSnapshot, error) {
+	var snapshot models.PortfolioSnapshot
+	err := row.Scan(&snapshot.Date, &snapshot.OutstandingBalance, &snapshot.AccruedInterest, &snapshot.ActiveLoans, &snapshot.DelinquentLoans,
+		&snapshot.DelinquencyRate, &snapshot.Originations, &snapshot.OriginationVolume, &snapshot.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// SavePortfolioSnapshot stores the snapshot for its date, replacing any earlier snapshot
+// taken the same day.
+func (s *sqlStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	_, err := s.db.ExecContext(ctx,
+		upsert("portfolio_snapshots", snapshotColumns, "date"),
+		snapshot.Date.UTC(), snapshot.OutstandingBalance, snapshot.AccruedInterest, snapshot.ActiveLoans, snapshot.DelinquentLoans,
+		snapshot.DelinquencyRate, snapshot.Originations, snapshot.OriginationVolume, snapshot.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save portfolio snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetPortfolioSnapshots retrieves snapshots dated from through to (inclusive) in date order.
+func (s *sqlStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+snapshotColumns+` FROM portfolio_snapshots WHERE date >= ? AND date <= ? ORDER BY date ASC`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get portfolio snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*models.PortfolioSnapshot
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan portfolio snapshot row: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for portfolio snapshots: %w", err)
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// statementColumns lists the statements columns in the order expected by scanStatement.
+const statementColumns = `id, loan_id, cycle, period_start, statement_date, balance, interest_charged, payments, fees, odd_days, odd_days_interest, odd_days_policy, minimum_due, due_date, created_at`
+
+func scanStatement(row rowScanner) (*models.Statement, error) {
+	var statement models.Statement
+	var idStr, loanIDStr string
+	err := row.Scan(&idStr, &loanIDStr, &statement.Cycle, &statement.PeriodStart, &statement.StatementDate, &statement.Balance, &statement.InterestCharged,
+		&statement.Payments, &statement.Fees, &statement.OddDays, &statement.OddDaysInterest, &statement.OddDaysPolicy, &statement.MinimumDue, &statement.DueDate, &statement.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	statement.ID = uuid.MustParse(idStr)
+	statement.LoanID = uuid.MustParse(loanIDStr)
+	return &statement, nil
+}
+
+// CreateStatement stores a statement; a loan has at most one statement per cycle.
+func (s *sqlStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO statements (`+statementColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		statement.ID.String(), statement.LoanID.String(), statement.Cycle, statement.PeriodStart, statement.StatementDate, statement.Balance, statement.InterestCharged,
+		statement.Payments, statement.Fees, statement.OddDays, statement.OddDaysInterest, statement.OddDaysPolicy, statement.MinimumDue, statement.DueDate, statement.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create statement: %w", err)
+	}
+	return nil
+}
+
+// GetStatement retrieves a loan's statement for a cycle, or nil if none was issued.
+func (s *sqlStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error) {
+	statement, err := scanStatement(s.db.QueryRowContext(ctx, `SELECT `+statementColumns+` FROM statements WHERE loan_id = ? AND cycle = ?`, loanID.String(), cycle))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get statement: %w", err)
+	}
+	return statement, nil
+}
+
+// GetStatementsForLoan retrieves a loan's statements, oldest first.
+func (s *sqlStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+statementColumns+` FROM statements WHERE loan_id = ? ORDER BY statement_date`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get statements for loan %s: %w", loanID, err)
+	}
+	defer rows.Close()
+
+	var statements []*models.Statement
+	for rows.Next() {
+		statement, err := scanStatement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan statement row: %w", err)
+		}
+		statements = append(statements, statement)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during rows iteration for statements: %w", err)
+	}
+	return statements, nil
+}
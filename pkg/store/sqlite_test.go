@@ -1,7 +1,11 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,6 +15,8 @@ import (
 )
 
 func TestSQLiteStore_CreateAndGetLoan(t *testing.T) {
+	ctx := context.Background()
+
 	dbFile := "test_store_dec.db"
 	os.Remove(dbFile)
 	defer os.Remove(dbFile)
@@ -36,12 +42,12 @@ func TestSQLiteStore_CreateAndGetLoan(t *testing.T) {
 		AccruedInterest:      decimal.Zero,
 	}
 
-	err = s.CreateLoan(loan)
+	err = s.CreateLoan(ctx, loan)
 	if err != nil {
 		t.Fatalf("Failed to create loan: %v", err)
 	}
 
-	fetched, err := s.GetLoan(loan.ID)
+	fetched, err := s.GetLoan(ctx, loan.ID)
 	if err != nil {
 		t.Fatalf("Failed to get loan: %v", err)
 	}
@@ -60,6 +66,8 @@ func TestSQLiteStore_CreateAndGetLoan(t *testing.T) {
 }
 
 func TestSQLiteStore_Transactions(t *testing.T) {
+	ctx := context.Background()
+
 	dbFile := "test_tx_dec.db"
 	os.Remove(dbFile)
 	defer os.Remove(dbFile)
@@ -72,7 +80,7 @@ func TestSQLiteStore_Transactions(t *testing.T) {
 
 	loanID := uuid.New()
 	// Must create loan first due to foreign key
-	s.CreateLoan(&models.Loan{
+	s.CreateLoan(ctx, &models.Loan{
 		ID:                   loanID,
 		CustomerKey:          "test",
 		Principal:            decimal.NewFromInt(100),
@@ -96,12 +104,12 @@ func TestSQLiteStore_Transactions(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	err = s.CreateTransaction(tx)
+	err = s.CreateTransaction(ctx, tx)
 	if err != nil {
 		t.Fatalf("Failed to create transaction: %v", err)
 	}
 
-	txs, err := s.GetTransactionsForLoan(loanID)
+	txs, err := s.GetTransactionsForLoan(ctx, loanID)
 	if err != nil {
 		t.Fatalf("Failed to get transactions: %v", err)
 	}
@@ -113,4 +121,1376 @@ func TestSQLiteStore_Transactions(t *testing.T) {
 	if !txs[0].Amount.Equal(amount) {
 		t.Errorf("Expected amount %s, got %s", amount, txs[0].Amount)
 	}
+	if txs[0].Metadata != nil {
+		t.Errorf("Expected no metadata, got %v", txs[0].Metadata)
+	}
+
+	// Metadata is kept as given and filtered on one key or several
+	tagged := &models.Transaction{
+		ID:        uuid.New(),
+		LoanID:    loanID,
+		Amount:    amount,
+		Type:      models.TransactionTypePayment,
+		Timestamp: time.Now(),
+		Metadata:  map[string]string{"channel": "branch", "operator.id": "op-7"},
+	}
+	if err := s.CreateTransaction(ctx, tagged); err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	filters := []struct {
+		metadata map[string]string
+		expected int
+	}{
+		{map[string]string{"channel": "branch"}, 1},
+		{map[string]string{"channel": "branch", "operator.id": "op-7"}, 1},
+		{map[string]string{"channel": "branch", "operator.id": "op-8"}, 0},
+		{map[string]string{"channel": "mobile"}, 0},
+	}
+	for _, f := range filters {
+		txs, total, err := s.QueryTransactions(ctx, loanID, models.TransactionQuery{Metadata: f.metadata})
+		if err != nil || total != f.expected || len(txs) != f.expected {
+			t.Errorf("Expected %d transactions with metadata %v, got %d of %d (%v)", f.expected, f.metadata, len(txs), total, err)
+		}
+		if len(txs) == 1 && txs[0].Metadata["operator.id"] != "op-7" {
+			t.Errorf("Expected the transaction's metadata, got %v", txs[0].Metadata)
+		}
+	}
+}
+
+func TestSQLiteStore_ArchiveClosedLoans(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_archive_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	old := time.Now().AddDate(-2, 0, 0)
+	newLoan := func(status models.LoanStatus, updated time.Time) *models.Loan {
+		loan := &models.Loan{
+			ID:                   uuid.New(),
+			CustomerKey:          "test",
+			Principal:            decimal.NewFromInt(100),
+			Balance:              decimal.Zero,
+			BaseInterestRate:     decimal.NewFromFloat(0.1),
+			InterestRateVariance: decimal.Zero,
+			InterestRate:         decimal.NewFromFloat(0.1),
+			Status:               status,
+			CreatedAt:            updated,
+			UpdatedAt:            updated,
+			StatementCycleDay:    1,
+			AccruedInterest:      decimal.Zero,
+		}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+		s.CreateTransaction(ctx, &models.Transaction{
+			ID:        uuid.New(),
+			LoanID:    loan.ID,
+			Amount:    decimal.NewFromInt(100),
+			Type:      models.TransactionTypeDisbursement,
+			Timestamp: updated,
+		})
+		return loan
+	}
+
+	oldClosed := newLoan("closed", old)
+	recentClosed := newLoan("closed", time.Now())
+	oldActive := newLoan("active", old)
+
+	archived, err := s.ArchiveClosedLoans(ctx, time.Now().AddDate(-1, 0, 0))
+	if err != nil {
+		t.Fatalf("Failed to archive loans: %v", err)
+	}
+	if archived != 1 {
+		t.Fatalf("Expected 1 archived loan, got %d", archived)
+	}
+
+	if _, err := s.GetLoan(ctx, oldClosed.ID); err == nil {
+		t.Error("Expected archived loan to be removed from loans table")
+	}
+	for _, id := range []uuid.UUID{recentClosed.ID, oldActive.ID} {
+		if _, err := s.GetLoan(ctx, id); err != nil {
+			t.Errorf("Expected loan %s to remain in loans table: %v", id, err)
+		}
+	}
+
+	fetched, err := s.GetArchivedLoan(ctx, oldClosed.ID)
+	if err != nil {
+		t.Fatalf("Failed to get archived loan: %v", err)
+	}
+	if fetched.Status != "closed" {
+		t.Errorf("Expected archived status 'closed', got %s", fetched.Status)
+	}
+
+	txs, err := s.GetArchivedTransactionsForLoan(ctx, oldClosed.ID)
+	if err != nil {
+		t.Fatalf("Failed to get archived transactions: %v", err)
+	}
+	if len(txs) != 1 {
+		t.Errorf("Expected 1 archived transaction, got %d", len(txs))
+	}
+	hot, _ := s.GetTransactionsForLoan(ctx, oldClosed.ID)
+	if len(hot) != 0 {
+		t.Errorf("Expected no hot transactions for archived loan, got %d", len(hot))
+	}
+
+	toExport, err := s.GetArchivedLoansToExport(ctx, time.Now(), 10)
+	if err != nil || len(toExport) != 1 || toExport[0].ID != oldClosed.ID {
+		t.Fatalf("Expected the archived loan to export, got %d (%v)", len(toExport), err)
+	}
+	if err := s.MarkArchivedLoansExported(ctx, []uuid.UUID{oldClosed.ID}, time.Now()); err != nil {
+		t.Fatalf("Failed to mark loan exported: %v", err)
+	}
+	if toExport, _ = s.GetArchivedLoansToExport(ctx, time.Now(), 10); len(toExport) != 0 {
+		t.Errorf("Expected no loans left to export, got %d", len(toExport))
+	}
+	if err := s.DeleteArchivedLoans(ctx, []uuid.UUID{oldClosed.ID}); err != nil {
+		t.Fatalf("Failed to delete archived loan: %v", err)
+	}
+	if _, err := s.GetArchivedLoan(ctx, oldClosed.ID); err == nil {
+		t.Error("Expected the deleted loan gone from the archive")
+	}
+	if txs, _ := s.GetArchivedTransactionsForLoan(ctx, oldClosed.ID); len(txs) != 0 {
+		t.Errorf("Expected the deleted loan's transactions gone, got %d", len(txs))
+	}
+}
+
+func TestSQLiteStore_ArchiveLoanRecords(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_archive_records.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	old := time.Now().AddDate(-2, 0, 0)
+	loan := &models.Loan{
+		ID:                uuid.New(),
+		CustomerKey:       "test",
+		Principal:         decimal.NewFromInt(100),
+		Status:            models.LoanStatusClosed,
+		CreatedAt:         old,
+		UpdatedAt:         old,
+		StatementCycleDay: 1,
+	}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	if err := s.CreateStatement(ctx, &models.Statement{ID: uuid.New(), LoanID: loan.ID, Cycle: "2024-01", PeriodStart: old, StatementDate: old, DueDate: old, CreatedAt: old}); err != nil {
+		t.Fatalf("Failed to create statement: %v", err)
+	}
+	for i := range 3 {
+		date := old.AddDate(0, 0, i)
+		if err := s.SaveAccrual(ctx, &models.Accrual{LoanID: loan.ID, Date: date, Amount: decimal.NewFromFloat(0.03), CreatedAt: date}); err != nil {
+			t.Fatalf("Failed to save accrual: %v", err)
+		}
+	}
+
+	if archived, err := s.ArchiveClosedLoans(ctx, time.Now().AddDate(-1, 0, 0)); err != nil || archived != 1 {
+		t.Fatalf("Expected 1 archived loan, got %d (%v)", archived, err)
+	}
+	count := func(table string) int {
+		var n int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table+` WHERE loan_id = ?`, loan.ID.String()).Scan(&n); err != nil {
+			t.Fatalf("Failed to count %s: %v", table, err)
+		}
+		return n
+	}
+	for _, child := range loanChildTables {
+		if n := count(child.table); n != 0 {
+			t.Errorf("Expected no %s left for the archived loan, got %d", child.table, n)
+		}
+	}
+	if statements, accruals := count("archived_statements"), count("archived_accruals"); statements != 1 || accruals != 3 {
+		t.Errorf("Expected the statement and 3 accruals archived, got %d and %d", statements, accruals)
+	}
+
+	if err := s.DeleteArchivedLoans(ctx, []uuid.UUID{loan.ID}); err != nil {
+		t.Fatalf("Failed to delete archived loan: %v", err)
+	}
+	if statements, accruals := count("archived_statements"), count("archived_accruals"); statements != 0 || accruals != 0 {
+		t.Errorf("Expected the archived statement and accruals deleted, got %d and %d", statements, accruals)
+	}
+}
+
+func TestSQLiteStore_Products(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_products_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	product := &models.Product{
+		Code:                 "personal",
+		Name:                 "Personal Loan",
+		AccrueAfterChargeOff: true,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+	if err := s.CreateProduct(ctx, product); err != nil {
+		t.Fatalf("Failed to create product: %v", err)
+	}
+
+	fetched, err := s.GetProduct(ctx, "personal")
+	if err != nil {
+		t.Fatalf("Failed to get product: %v", err)
+	}
+	if !fetched.AccrueAfterChargeOff {
+		t.Error("Expected AccrueAfterChargeOff to be true")
+	}
+
+	fetched.AccrueAfterChargeOff = false
+	if err := s.UpdateProduct(ctx, fetched); err != nil {
+		t.Fatalf("Failed to update product: %v", err)
+	}
+	fetched, _ = s.GetProduct(ctx, "personal")
+	if fetched.AccrueAfterChargeOff {
+		t.Error("Expected AccrueAfterChargeOff to be false after update")
+	}
+
+	if _, err := s.GetProduct(ctx, "missing"); err == nil || err.Error() != "product not found" {
+		t.Errorf("Expected 'product not found', got %v", err)
+	}
+}
+
+func TestSQLiteStore_Customers(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_customers.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	customer := &models.Customer{
+		ID:          uuid.New(),
+		CustomerKey: "cust_b",
+		Name:        "Ada Borrower",
+		Email:       "ada@example.com",
+		Status:      models.CustomerStatusActive,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.CreateCustomer(ctx, customer); err != nil {
+		t.Fatalf("Failed to create customer: %v", err)
+	}
+	duplicate := *customer
+	duplicate.ID = uuid.New()
+	if err := s.CreateCustomer(ctx, &duplicate); err != models.ErrCustomerExists {
+		t.Errorf("Expected ErrCustomerExists for a taken key, got %v", err)
+	}
+
+	customer.Status = models.CustomerStatusInactive
+	customer.Phone = "555-0100"
+	if err := s.UpdateCustomer(ctx, customer); err != nil {
+		t.Fatalf("Failed to update customer: %v", err)
+	}
+	fetched, err := s.GetCustomer(ctx, "cust_b")
+	if err != nil {
+		t.Fatalf("Failed to get customer: %v", err)
+	}
+	if fetched.ID != customer.ID || fetched.Status != models.CustomerStatusInactive || fetched.Phone != "555-0100" || fetched.Email != "ada@example.com" {
+		t.Errorf("Expected the updated customer, got %+v", fetched)
+	}
+	if _, err := s.GetCustomer(ctx, "missing"); err != models.ErrCustomerNotFound {
+		t.Errorf("Expected ErrCustomerNotFound, got %v", err)
+	}
+
+	// A loan for a new customer key registers the customer, who is then kept by the loan
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_a", Status: models.LoanStatusActive, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	registered, err := s.GetCustomer(ctx, "cust_a")
+	if err != nil || registered.Status != models.CustomerStatusActive {
+		t.Fatalf("Expected the loan's customer to be registered as active, got %v, %v", registered, err)
+	}
+	if err := s.DeleteCustomer(ctx, "cust_a"); err != models.ErrCustomerHasLoans {
+		t.Errorf("Expected ErrCustomerHasLoans, got %v", err)
+	}
+
+	customers, err := s.GetAllCustomers(ctx)
+	if err != nil || len(customers) != 2 || customers[0].CustomerKey != "cust_a" || customers[1].CustomerKey != "cust_b" {
+		t.Errorf("Expected both customers ordered by key, got %d (%v)", len(customers), err)
+	}
+
+	if err := s.DeleteCustomer(ctx, "cust_b"); err != nil {
+		t.Fatalf("Failed to delete a customer without loans: %v", err)
+	}
+	if err := s.DeleteCustomer(ctx, "cust_b"); err != models.ErrCustomerNotFound {
+		t.Errorf("Expected ErrCustomerNotFound after deleting, got %v", err)
+	}
+
+	// Opening a database whose loans predate the customers table registers their customers
+	conn, err := s.db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get a connection: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "PRAGMA foreign_keys = OFF; DELETE FROM customers;"); err != nil {
+		t.Fatalf("Failed to clear customers: %v", err)
+	}
+	conn.Close()
+	s.Close()
+
+	s, err = NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.GetCustomer(ctx, "cust_a"); err != nil {
+		t.Errorf("Expected cust_a to be registered on reopening, got %v", err)
+	}
+}
+
+func TestSQLiteStore_RateHistory(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_rates_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	loanID := uuid.New()
+	s.CreateLoan(ctx, &models.Loan{
+		ID:                   loanID,
+		CustomerKey:          "test",
+		Principal:            decimal.NewFromInt(100),
+		Balance:              decimal.NewFromInt(100),
+		BaseInterestRate:     decimal.NewFromFloat(0.1),
+		InterestRateVariance: decimal.Zero,
+		InterestRate:         decimal.NewFromFloat(0.1),
+		Status:               "active",
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+		StatementCycleDay:    1,
+		AccruedInterest:      decimal.Zero,
+	})
+
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+	for _, c := range []struct {
+		effective time.Time
+		rate      float64
+	}{
+		{day(2025, 1, 1), 0.05},
+		{day(2025, 6, 1), 0.07},
+	} {
+		err := s.CreateRateChange(ctx, &models.RateChange{
+			ID:                   uuid.New(),
+			LoanID:               loanID,
+			BaseInterestRate:     decimal.NewFromFloat(c.rate),
+			InterestRateVariance: decimal.Zero,
+			InterestRate:         decimal.NewFromFloat(c.rate),
+			EffectiveDate:        c.effective,
+			CreatedAt:            time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create rate change: %v", err)
+		}
+	}
+
+	if change, _ := s.GetRateInEffect(ctx, loanID, day(2024, 12, 31)); change != nil {
+		t.Errorf("Expected no rate in effect before first change, got %s", change.InterestRate)
+	}
+	change, err := s.GetRateInEffect(ctx, loanID, day(2025, 5, 31))
+	if err != nil || change == nil || !change.InterestRate.Equal(decimal.NewFromFloat(0.05)) {
+		t.Errorf("Expected rate 0.05 in effect on 2025-05-31, got %v (err %v)", change, err)
+	}
+	change, _ = s.GetRateInEffect(ctx, loanID, day(2025, 6, 1))
+	if change == nil || !change.InterestRate.Equal(decimal.NewFromFloat(0.07)) {
+		t.Errorf("Expected rate 0.07 in effect on 2025-06-01, got %v", change)
+	}
+
+	history, _ := s.GetRateHistory(ctx, loanID)
+	if len(history) != 2 {
+		t.Errorf("Expected 2 rate changes, got %d", len(history))
+	}
+}
+
+func TestSQLiteStore_PaymentMethods(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_payment_methods_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	expires := time.Now().AddDate(2, 0, 0)
+	method := &models.PaymentMethod{
+		ID:          uuid.New(),
+		CustomerKey: "cust_pm",
+		Type:        models.PaymentMethodCard,
+		Token:       "tok_abc",
+		Last4:       "4242",
+		Status:      models.PaymentMethodPending,
+		ExpiresAt:   &expires,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.CreatePaymentMethod(ctx, method); err != nil {
+		t.Fatalf("Failed to create payment method: %v", err)
+	}
+
+	method.Status = models.PaymentMethodVerified
+	if err := s.UpdatePaymentMethod(ctx, method); err != nil {
+		t.Fatalf("Failed to update payment method: %v", err)
+	}
+
+	methods, err := s.GetPaymentMethodsForCustomer(ctx, "cust_pm")
+	if err != nil {
+		t.Fatalf("Failed to list payment methods: %v", err)
+	}
+	if len(methods) != 1 || methods[0].Status != models.PaymentMethodVerified || methods[0].ExpiresAt == nil {
+		t.Fatalf("Unexpected payment methods: %+v", methods)
+	}
+
+	loanID := uuid.New()
+	s.CreateLoan(ctx, &models.Loan{
+		ID:                loanID,
+		CustomerKey:       "cust_pm",
+		Principal:         decimal.NewFromInt(100),
+		Balance:           decimal.NewFromInt(100),
+		InterestRate:      decimal.NewFromFloat(0.1),
+		Status:            "active",
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		StatementCycleDay: 1,
+	})
+	s.CreateTransaction(ctx, &models.Transaction{
+		ID:              uuid.New(),
+		LoanID:          loanID,
+		Amount:          decimal.NewFromInt(10),
+		Type:            models.TransactionTypePayment,
+		Timestamp:       time.Now(),
+		PaymentMethodID: &method.ID,
+	})
+	txs, _ := s.GetTransactionsForLoan(ctx, loanID)
+	if len(txs) != 1 || txs[0].PaymentMethodID == nil || *txs[0].PaymentMethodID != method.ID {
+		t.Errorf("Expected transaction to reference payment method %s", method.ID)
+	}
+}
+
+func TestSQLiteStore_RedeemPaymentLink(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_payment_links_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	loan := newFaultTestLoan()
+	s.CreateLoan(ctx, loan)
+	link := &models.PaymentLink{
+		ID:        uuid.New(),
+		LoanID:    loan.ID,
+		MinAmount: decimal.NewFromInt(10),
+		MaxAmount: decimal.NewFromInt(100),
+		Status:    models.PaymentLinkActive,
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: time.Now(),
+	}
+	if err := s.CreatePaymentLink(ctx, link); err != nil {
+		t.Fatalf("Failed to create payment link: %v", err)
+	}
+
+	now := time.Now()
+	first, second := uuid.New(), uuid.New()
+	link.Status, link.TransactionID, link.RedeemedAt = models.PaymentLinkRedeemed, &first, &now
+	if err := s.RedeemPaymentLink(ctx, link); err != nil {
+		t.Fatalf("Failed to redeem payment link: %v", err)
+	}
+	link.TransactionID = &second
+	if err := s.RedeemPaymentLink(ctx, link); !errors.Is(err, models.ErrPaymentLinkRedeemed) {
+		t.Errorf("Expected ErrPaymentLinkRedeemed redeeming the link again, got %v", err)
+	}
+	if stored, _ := s.GetPaymentLink(ctx, link.ID); stored.TransactionID == nil || *stored.TransactionID != first {
+		t.Errorf("Expected the link to keep its first redemption, got %+v", stored)
+	}
+
+	link.ID = uuid.New()
+	if err := s.RedeemPaymentLink(ctx, link); !errors.Is(err, models.ErrPaymentLinkNotFound) {
+		t.Errorf("Expected ErrPaymentLinkNotFound for an unknown link, got %v", err)
+	}
+}
+
+func TestSQLiteStore_PortfolioSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_snapshots_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for i := 0; i < 3; i++ {
+		s.SavePortfolioSnapshot(ctx, &models.PortfolioSnapshot{
+			Date:               today.AddDate(0, 0, -i),
+			OutstandingBalance: decimal.NewFromInt(int64(100 * (i + 1))),
+			ActiveLoans:        i + 1,
+			CreatedAt:          time.Now(),
+		})
+	}
+	// Saving again for the same day replaces the earlier snapshot
+	s.SavePortfolioSnapshot(ctx, &models.PortfolioSnapshot{Date: today, OutstandingBalance: decimal.NewFromInt(50), ActiveLoans: 1, CreatedAt: time.Now()})
+
+	snapshots, err := s.GetPortfolioSnapshots(ctx, today.AddDate(0, 0, -1), today)
+	if err != nil {
+		t.Fatalf("Failed to get snapshots: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected 2 snapshots in range, got %d", len(snapshots))
+	}
+	if !snapshots[1].Date.Equal(today) || !snapshots[1].OutstandingBalance.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Expected today's replaced snapshot last, got %+v", snapshots[1])
+	}
+}
+
+func TestSQLiteStore_LedgerEvents(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_ledger_events_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	loanID, transactionID := uuid.New(), uuid.New()
+	created := &models.LedgerEvent{
+		ID:            uuid.New(),
+		LoanID:        loanID,
+		Type:          models.LedgerLoanCreated,
+		Amount:        decimal.NewFromInt(1000),
+		TransactionID: &transactionID,
+		Changes:       models.LoanAmounts{Balance: decimal.NewFromInt(1000)},
+		Amounts:       models.LoanAmounts{Balance: decimal.NewFromInt(1000)},
+		OccurredAt:    time.Now().UTC(),
+	}
+	accrued := &models.LedgerEvent{
+		ID:         uuid.New(),
+		LoanID:     loanID,
+		Type:       models.LedgerInterestAccrued,
+		Amount:     decimal.RequireFromString("0.27"),
+		Changes:    models.LoanAmounts{AccruedInterest: decimal.RequireFromString("0.27")},
+		Amounts:    models.LoanAmounts{Balance: decimal.NewFromInt(1000), AccruedInterest: decimal.RequireFromString("0.27")},
+		OccurredAt: time.Now().UTC(),
+	}
+	for _, event := range []*models.LedgerEvent{created, accrued} {
+		if err := s.AppendLedgerEvent(ctx, event); err != nil {
+			t.Fatalf("Failed to append %s event: %v", event.Type, err)
+		}
+	}
+	if created.Sequence != 1 || accrued.Sequence != 2 {
+		t.Errorf("Expected sequence numbers 1 and 2, got %d and %d", created.Sequence, accrued.Sequence)
+	}
+	// Another loan's log is numbered on its own
+	other := &models.LedgerEvent{ID: uuid.New(), LoanID: uuid.New(), Type: models.LedgerLoanCreated, OccurredAt: time.Now().UTC()}
+	if err := s.AppendLedgerEvent(ctx, other); err != nil || other.Sequence != 1 {
+		t.Errorf("Expected the other loan's first event numbered 1, got %d (%v)", other.Sequence, err)
+	}
+	if err := s.AppendLedgerEvent(ctx, created); err == nil {
+		t.Error("Expected appending an event twice to fail")
+	}
+
+	events, err := s.GetLedgerEvents(ctx, loanID)
+	if err != nil {
+		t.Fatalf("Failed to get ledger events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].ID != created.ID || events[0].TransactionID == nil || *events[0].TransactionID != transactionID {
+		t.Errorf("Expected the created event with its transaction first, got %+v", events[0])
+	}
+	if events[1].TransactionID != nil || !events[1].Amounts.AccruedInterest.Equal(decimal.RequireFromString("0.27")) || !events[1].Amounts.Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected the accrual's amounts to round-trip, got %+v", events[1])
+	}
+}
+
+func TestSQLiteStore_Outbox(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_outbox_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now().UTC()
+	first := &models.OutboxEvent{ID: uuid.New(), Type: models.WebhookLoanCreated, Payload: []byte(`{"a":1}`), CreatedAt: now.Add(-time.Minute)}
+	second := &models.OutboxEvent{ID: uuid.New(), Type: models.WebhookPaymentRecorded, Payload: []byte(`{"b":2}`), CreatedAt: now}
+	// An event rolled back with its change never reaches the outbox
+	s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		tx.CreateOutboxEvent(ctx, &models.OutboxEvent{ID: uuid.New(), Type: models.WebhookLoanClosed, Payload: []byte(`{}`), CreatedAt: now})
+		return errors.New("rolled back")
+	})
+	for _, event := range []*models.OutboxEvent{second, first} {
+		if err := s.CreateOutboxEvent(ctx, event); err != nil {
+			t.Fatalf("Failed to create outbox event: %v", err)
+		}
+	}
+
+	pending, err := s.GetPendingOutboxEvents(ctx, 10)
+	if err != nil {
+		t.Fatalf("Failed to get pending outbox events: %v", err)
+	}
+	if len(pending) != 2 || pending[0].ID != first.ID || string(pending[0].Payload) != `{"a":1}` || pending[1].ID != second.ID {
+		t.Fatalf("Expected the two events oldest first, got %+v", pending)
+	}
+
+	if err := s.MarkOutboxEventPublished(ctx, first.ID, now); err != nil {
+		t.Fatalf("Failed to mark outbox event published: %v", err)
+	}
+	if err := s.MarkOutboxEventPublished(ctx, first.ID, now); !errors.Is(err, models.ErrOutboxEventPublished) {
+		t.Errorf("Expected ErrOutboxEventPublished marking it again, got %v", err)
+	}
+	if pending, _ = s.GetPendingOutboxEvents(ctx, 10); len(pending) != 1 || pending[0].ID != second.ID {
+		t.Errorf("Expected only the second event pending, got %+v", pending)
+	}
+}
+
+func TestSQLiteStore_JobRuns(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_job_runs_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	loanID := uuid.New()
+	start := time.Now().Add(-time.Hour)
+	for i, job := range []models.JobName{models.JobDailyInterest, models.JobAutopay, models.JobDailyInterest} {
+		run := &models.JobRun{ID: uuid.New(), Job: job, Trigger: models.JobTriggerSchedule, Date: today, StartedAt: start.Add(time.Duration(i) * time.Minute), FinishedAt: start.Add(time.Duration(i)*time.Minute + time.Second), Processed: i}
+		if i == 2 {
+			run.Trigger = models.JobTriggerManual
+			run.LoanID = &loanID
+			run.Failed = 1
+			run.Errors = []string{"loan failed"}
+		}
+		if err := s.CreateJobRun(ctx, run); err != nil {
+			t.Fatalf("Failed to create job run: %v", err)
+		}
+	}
+
+	runs, err := s.GetJobRuns(ctx, models.JobDailyInterest, 10)
+	if err != nil {
+		t.Fatalf("Failed to get job runs: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("Expected 2 daily interest runs, got %d", len(runs))
+	}
+	latest := runs[0]
+	if latest.Trigger != models.JobTriggerManual || latest.LoanID == nil || *latest.LoanID != loanID || latest.Processed != 2 || len(latest.Errors) != 1 {
+		t.Errorf("Expected the manual run first, got %+v", latest)
+	}
+	if runs[1].LoanID != nil || runs[1].Errors != nil {
+		t.Errorf("Expected an unscoped run without errors, got %+v", runs[1])
+	}
+
+	if runs, _ := s.GetJobRuns(ctx, "", 2); len(runs) != 2 || runs[1].Job != models.JobAutopay {
+		t.Errorf("Expected the 2 latest runs of any job, got %d", len(runs))
+	}
+}
+
+func TestSQLiteStore_ListLoans(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_list.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	created := time.Now().Add(-time.Hour)
+	ids := make([]uuid.UUID, 5)
+	for i := range ids {
+		ids[i] = uuid.New()
+		loan := &models.Loan{
+			ID:          ids[i],
+			CustomerKey: "cust_test",
+			Principal:   decimal.NewFromInt(1000),
+			Balance:     decimal.NewFromInt(1000),
+			Status:      models.LoanStatusActive,
+			CreatedAt:   created.Add(time.Duration(i) * time.Minute),
+			UpdatedAt:   created,
+		}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+	}
+
+	loans, total, err := s.ListLoans(ctx, models.LoanQuery{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("Failed to list loans: %v", err)
+	}
+	if total != 5 {
+		t.Errorf("Expected total 5, got %d", total)
+	}
+	if len(loans) != 2 || loans[0].ID != ids[2] || loans[1].ID != ids[3] {
+		t.Errorf("Expected the third and fourth loans, got %d loans", len(loans))
+	}
+
+	loans, _, _ = s.ListLoans(ctx, models.LoanQuery{Limit: 10, Offset: 4})
+	if len(loans) != 1 || loans[0].ID != ids[4] {
+		t.Errorf("Expected only the last loan on the final page, got %d loans", len(loans))
+	}
+
+	// Filters and sorts run in SQL, on amounts stored as text
+	other := &models.Loan{
+		ID:          uuid.New(),
+		CustomerKey: "cust_other",
+		Principal:   decimal.NewFromInt(950),
+		Balance:     decimal.NewFromInt(950),
+		Status:      models.LoanStatusDelinquent,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.CreateLoan(ctx, other); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	loans, total, _ = s.ListLoans(ctx, models.LoanQuery{Status: models.LoanStatusDelinquent, Limit: 10})
+	if total != 1 || loans[0].ID != other.ID {
+		t.Errorf("Expected only the delinquent loan, got %d", total)
+	}
+	loans, total, _ = s.ListLoans(ctx, models.LoanQuery{CustomerKey: "cust_test", MinBalance: decimal.NewFromInt(999), Limit: 10})
+	if total != 5 {
+		t.Errorf("Expected 5 loans for cust_test with balance of at least 999, got %d", total)
+	}
+	after := created.Add(150 * time.Second)
+	if _, total, _ = s.ListLoans(ctx, models.LoanQuery{CreatedAfter: &after, Limit: 10}); total != 3 {
+		t.Errorf("Expected 3 loans created after %s, got %d", after, total)
+	}
+	loans, _, _ = s.ListLoans(ctx, models.LoanQuery{Sort: models.LoanSortBalance, Limit: 1})
+	if len(loans) != 1 || loans[0].ID != other.ID {
+		t.Error("Expected the smallest balance first when sorting by balance")
+	}
+	loans, _, _ = s.ListLoans(ctx, models.LoanQuery{Sort: models.LoanSortCreatedAtDesc, Limit: 1})
+	if len(loans) != 1 || loans[0].ID != other.ID {
+		t.Error("Expected the newest loan first when sorting by -created_at")
+	}
+
+	// Voided loans keep their deletion time and are only listed when asked for by status
+	deletedAt := time.Now().UTC().Truncate(time.Second)
+	other.Status = models.LoanStatusVoided
+	other.DeletedAt = &deletedAt
+	if err := s.UpdateLoan(ctx, other); err != nil {
+		t.Fatalf("Failed to void loan: %v", err)
+	}
+	voided, err := s.GetLoan(ctx, other.ID)
+	if err != nil {
+		t.Fatalf("Failed to get voided loan: %v", err)
+	}
+	if voided.DeletedAt == nil || !voided.DeletedAt.Equal(deletedAt) {
+		t.Errorf("Expected deleted_at %s, got %v", deletedAt, voided.DeletedAt)
+	}
+	if _, total, _ = s.ListLoans(ctx, models.LoanQuery{Limit: 10}); total != 5 {
+		t.Errorf("Expected the voided loan to be left out, got %d loans", total)
+	}
+	loans, total, _ = s.ListLoans(ctx, models.LoanQuery{Status: models.LoanStatusVoided, Limit: 10})
+	if total != 1 || loans[0].ID != other.ID {
+		t.Errorf("Expected only the voided loan when filtering by voided, got %d", total)
+	}
+	if _, total, _ = s.SearchLoans(ctx, models.LoanSearch{Limit: 10}); total != 5 {
+		t.Errorf("Expected search to leave out the voided loan, got %d loans", total)
+	}
+	if _, total, _ = s.SearchLoans(ctx, models.LoanSearch{Statuses: []models.LoanStatus{models.LoanStatusVoided}, Limit: 10}); total != 1 {
+		t.Errorf("Expected search by voided status to find the voided loan, got %d", total)
+	}
+}
+
+func TestSQLiteStore_SearchLoans(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_search.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	created := time.Now().Add(-48 * time.Hour)
+	loans := []struct {
+		customerKey string
+		balance     int64
+		rate        float64
+		status      models.LoanStatus
+	}{
+		{"acme-1", 500, 0.05, models.LoanStatusActive},
+		{"acme-2", 1500, 0.08, models.LoanStatusDelinquent},
+		{"acme-3", 2500, 0.12, models.LoanStatusClosed},
+		{"bolt-1", 1000, 0.10, models.LoanStatusActive},
+	}
+	ids := make([]uuid.UUID, len(loans))
+	for i, l := range loans {
+		ids[i] = uuid.New()
+		loan := &models.Loan{
+			ID:           ids[i],
+			CustomerKey:  l.customerKey,
+			Principal:    decimal.NewFromInt(l.balance),
+			Balance:      decimal.NewFromInt(l.balance),
+			InterestRate: decimal.NewFromFloat(l.rate),
+			Status:       l.status,
+			CreatedAt:    created.Add(time.Duration(i) * time.Hour),
+			UpdatedAt:    created,
+		}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+	}
+
+	amount := func(v float64) *decimal.Decimal {
+		d := decimal.NewFromFloat(v)
+		return &d
+	}
+	createdTo := created.Add(90 * time.Minute)
+	tests := []struct {
+		name   string
+		search models.LoanSearch
+		want   []uuid.UUID
+	}{
+		{"customer key prefix", models.LoanSearch{CustomerKeyPrefix: "acme-"}, ids[:3]},
+		{"status set", models.LoanSearch{Statuses: []models.LoanStatus{models.LoanStatusActive, models.LoanStatusDelinquent}}, []uuid.UUID{ids[0], ids[1], ids[3]}},
+		{"balance range", models.LoanSearch{MinBalance: amount(1000), MaxBalance: amount(2000)}, []uuid.UUID{ids[1], ids[3]}},
+		{"rate range", models.LoanSearch{MinRate: amount(0.08), MaxRate: amount(0.10)}, []uuid.UUID{ids[1], ids[3]}},
+		{"created range", models.LoanSearch{CreatedTo: &createdTo}, ids[:2]},
+		{"combined", models.LoanSearch{CustomerKeyPrefix: "acme", MinBalance: amount(1000), Sort: models.LoanSortBalanceDesc}, []uuid.UUID{ids[2], ids[1]}},
+	}
+	for _, tt := range tests {
+		tt.search.Limit = 10
+		found, total, err := s.SearchLoans(ctx, tt.search)
+		if err != nil {
+			t.Fatalf("%s: failed to search loans: %v", tt.name, err)
+		}
+		if total != len(tt.want) || len(found) != len(tt.want) {
+			t.Errorf("%s: expected %d loans, got %d", tt.name, len(tt.want), total)
+			continue
+		}
+		for i, loan := range found {
+			if loan.ID != tt.want[i] {
+				t.Errorf("%s: expected loan %d to be %s, got %s", tt.name, i, tt.want[i], loan.ID)
+			}
+		}
+	}
+
+	// Range conditions are index scans
+	var id, parent, notUsed int
+	var detail string
+	err = s.db.QueryRowContext(ctx, `EXPLAIN QUERY PLAN SELECT id FROM loans WHERE CAST(balance AS REAL) >= ? AND CAST(balance AS REAL) <= ?`, 1, 2).Scan(&id, &parent, &notUsed, &detail)
+	if err != nil {
+		t.Fatalf("Failed to explain search: %v", err)
+	}
+	if !strings.Contains(detail, "idx_loans_balance") {
+		t.Errorf("Expected the balance range to use idx_loans_balance, got %q", detail)
+	}
+}
+
+func TestSQLiteStore_GetLoansByCustomerKey(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_customer_loans.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	start := time.Now().Add(-time.Hour)
+	var expected []uuid.UUID
+	for i, customerKey := range []string{"cust_b", "cust_a", "cust_b", "cust_c", "cust_b"} {
+		loan := &models.Loan{
+			ID:                uuid.New(),
+			CustomerKey:       customerKey,
+			Principal:         decimal.NewFromInt(100),
+			Balance:           decimal.NewFromInt(100),
+			Status:            models.LoanStatusActive,
+			StatementCycleDay: 1,
+			CreatedAt:         start.Add(-time.Duration(i) * time.Minute), // Each older than the last
+			UpdatedAt:         start,
+		}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+		if customerKey == "cust_b" {
+			expected = append([]uuid.UUID{loan.ID}, expected...)
+		}
+	}
+
+	loans, err := s.GetLoansByCustomerKey(ctx, "cust_b")
+	if err != nil {
+		t.Fatalf("Failed to get customer's loans: %v", err)
+	}
+	var ids []uuid.UUID
+	for _, loan := range loans {
+		ids = append(ids, loan.ID)
+	}
+	if !slices.Equal(ids, expected) {
+		t.Errorf("Expected the customer's loans oldest first, %v, got %v", expected, ids)
+	}
+	if none, err := s.GetLoansByCustomerKey(ctx, "nobody"); err != nil || len(none) != 0 {
+		t.Errorf("Expected no loans for an unknown customer, got %d (%v)", len(none), err)
+	}
+
+	// The query looks the customer up in the index rather than scanning every loan
+	rows, err := s.db.QueryContext(ctx, `EXPLAIN QUERY PLAN SELECT `+loanColumns+` FROM loans WHERE customer_key = ? ORDER BY julianday(created_at) ASC, id ASC`, "cust_b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			t.Fatal(err)
+		}
+		plan = append(plan, detail)
+	}
+	if !slices.ContainsFunc(plan, func(detail string) bool { return strings.Contains(detail, "idx_loans_customer_key") }) {
+		t.Errorf("Expected the query to use idx_loans_customer_key, got plan %v", plan)
+	}
+}
+
+func TestSQLiteStore_CustomerSummary(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_summary.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	loans := []struct {
+		customerKey string
+		status      models.LoanStatus
+		balance     string
+		accrued     string
+		cycleDay    int
+	}{
+		{"cust_a", models.LoanStatusActive, "1000.10", "1.005", 20},
+		{"cust_a", models.LoanStatusDelinquent, "250.25", "0.50", 5},
+		{"cust_a", models.LoanStatusClosed, "0", "0", 12},
+		{"cust_b", models.LoanStatusActive, "500", "2", 1},
+	}
+	for _, l := range loans {
+		loan := &models.Loan{
+			ID:                uuid.New(),
+			CustomerKey:       l.customerKey,
+			Principal:         decimal.RequireFromString(l.balance),
+			Balance:           decimal.RequireFromString(l.balance),
+			AccruedInterest:   decimal.RequireFromString(l.accrued),
+			Status:            l.status,
+			StatementCycleDay: l.cycleDay,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+	}
+
+	summary, err := s.GetCustomerSummary(ctx, "cust_a")
+	if err != nil {
+		t.Fatalf("Failed to summarize customer: %v", err)
+	}
+	if summary.LoanCount != 3 || summary.OpenLoanCount != 2 {
+		t.Errorf("Expected 3 loans with 2 open, got %d and %d", summary.LoanCount, summary.OpenLoanCount)
+	}
+	if !summary.OutstandingBalance.Equal(decimal.RequireFromString("1250.35")) || !summary.AccruedInterest.Equal(decimal.RequireFromString("1.51")) {
+		t.Errorf("Expected balance 1250.35 and accrued 1.51, got %s and %s", summary.OutstandingBalance, summary.AccruedInterest)
+	}
+	if len(summary.NextStatements) != 2 || summary.NextStatements[0].StatementCycleDay != 5 {
+		t.Errorf("Expected the two open loans' cycle days, got %+v", summary.NextStatements)
+	}
+
+	empty, _ := s.GetCustomerSummary(ctx, "nobody")
+	if empty.LoanCount != 0 || !empty.OutstandingBalance.IsZero() {
+		t.Errorf("Expected an empty summary for an unknown customer, got %+v", empty)
+	}
+}
+
+func TestSQLiteStore_UpdateLoanIfVersion(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_version.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_test", Status: "active", Version: 1, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+
+	first, _ := s.GetLoan(ctx, loan.ID)
+	second, _ := s.GetLoan(ctx, loan.ID)
+
+	first.CustomerKey = "first"
+	if err := s.UpdateLoanIfVersion(ctx, first, 1); err != nil {
+		t.Fatalf("Expected the first conditional update to succeed: %v", err)
+	}
+	if first.Version != 2 {
+		t.Errorf("Expected version 2 after the update, got %d", first.Version)
+	}
+
+	second.CustomerKey = "second"
+	if err := s.UpdateLoanIfVersion(ctx, second, 1); err == nil || err.Error() != "loan version mismatch" {
+		t.Errorf("Expected the stale update to be rejected, got %v", err)
+	}
+	if fetched, _ := s.GetLoan(ctx, loan.ID); fetched.CustomerKey != "first" {
+		t.Errorf("Expected the stale update not to overwrite, got %s", fetched.CustomerKey)
+	}
+
+	// Unconditional updates still bump the version
+	if err := s.UpdateLoan(ctx, second); err != nil || second.Version != 3 {
+		t.Errorf("Expected UpdateLoan to move to version 3, got %d (%v)", second.Version, err)
+	}
+	missing := &models.Loan{ID: uuid.New()}
+	if err := s.UpdateLoanIfVersion(ctx, missing, 1); err == nil || err.Error() != "loan not found" {
+		t.Errorf("Expected loan not found, got %v", err)
+	}
+}
+
+func TestSQLiteStore_CancelledContext(t *testing.T) {
+	dbFile := "test_store_cancel.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.GetLoan(ctx, uuid.New()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the query, got %v", err)
+	}
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_cancel", Status: models.LoanStatusActive, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	if err := s.CreateLoan(ctx, loan); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled context to stop the insert, got %v", err)
+	}
+	if _, err := s.GetLoan(context.Background(), loan.ID); err == nil {
+		t.Error("Expected the cancelled insert not to have stored the loan")
+	}
+}
+
+func TestSQLiteStore_InTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_tx.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_tx", Principal: decimal.NewFromInt(1000), Balance: decimal.NewFromInt(1000), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now}
+	if err := s.CreateLoan(ctx, loan); err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	payment := func() *models.Transaction {
+		return &models.Transaction{ID: uuid.New(), LoanID: loan.ID, Amount: decimal.NewFromInt(400), Type: models.TransactionTypePayment, Timestamp: now}
+	}
+
+	// A failed insert rolls back the loan update made before it
+	loan.Balance = decimal.NewFromInt(600)
+	duplicate := payment()
+	s.CreateTransaction(ctx, duplicate)
+	err = s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		if err := tx.UpdateLoan(ctx, loan); err != nil {
+			return err
+		}
+		return tx.CreateTransaction(ctx, duplicate)
+	})
+	if err == nil {
+		t.Fatal("Expected the duplicate transaction to fail the unit of work")
+	}
+	if fetched, _ := s.GetLoan(ctx, loan.ID); !fetched.Balance.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("Expected the loan update rolled back, got balance %s", fetched.Balance)
+	}
+
+	// A nested unit joins the outer one, so both commit together
+	err = s.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		if err := tx.UpdateLoan(ctx, loan); err != nil {
+			return err
+		}
+		return tx.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+			return tx.CreateTransaction(ctx, payment())
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to run unit of work: %v", err)
+	}
+	fetched, _ := s.GetLoan(ctx, loan.ID)
+	transactions, _ := s.GetTransactionsForLoan(ctx, loan.ID)
+	if !fetched.Balance.Equal(decimal.NewFromInt(600)) || len(transactions) != 2 {
+		t.Errorf("Expected both changes kept, got balance %s and %d transactions", fetched.Balance, len(transactions))
+	}
+}
+
+func TestSQLiteStore_UpdateLoans(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_update_loans.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	var loans []*models.Loan
+	for i := 0; i < loanBatchSize+10; i++ {
+		loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_batch", Principal: decimal.NewFromInt(1000), Balance: decimal.NewFromInt(1000), Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now, Version: 1}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+		loan.AccruedInterest = decimal.NewFromInt(int64(i))
+		loans = append(loans, loan)
+	}
+	loans[0].CustomerKey = "cust_batch_moved"
+
+	if stale, err := s.UpdateLoans(ctx, loans); err != nil || len(stale) != 0 {
+		t.Fatalf("Failed to update loans: %v, %d skipped", err, len(stale))
+	}
+	for i, loan := range []*models.Loan{loans[0], loans[len(loans)-1]} {
+		fetched, _ := s.GetLoan(ctx, loan.ID)
+		if loan.Version != 2 || fetched.Version != 2 || !fetched.AccruedInterest.Equal(loan.AccruedInterest) || !fetched.CreatedAt.Equal(loan.CreatedAt) {
+			t.Errorf("Expected loan %d updated to version 2, got %+v", i, fetched)
+		}
+	}
+	if _, err := s.GetCustomer(ctx, "cust_batch_moved"); err != nil {
+		t.Errorf("Expected the moved loan to register its customer, got %v", err)
+	}
+
+	// A missing loan fails the whole update
+	loans[1].AccruedInterest = decimal.NewFromInt(999)
+	missing := &models.Loan{ID: uuid.New(), CustomerKey: "cust_batch", Status: models.LoanStatusActive, CreatedAt: now, UpdatedAt: now}
+	if _, err := s.UpdateLoans(ctx, []*models.Loan{loans[1], missing}); err != models.ErrLoanNotFound {
+		t.Errorf("Expected ErrLoanNotFound, got %v", err)
+	}
+	if fetched, _ := s.GetLoan(ctx, loans[1].ID); fetched.Version != 2 || fetched.AccruedInterest.Equal(decimal.NewFromInt(999)) {
+		t.Errorf("Expected the loan unchanged, got %+v", fetched)
+	}
+	if _, err := s.GetLoan(ctx, missing.ID); err == nil {
+		t.Error("Expected the missing loan not to be inserted")
+	}
+
+	// A loan changed since it was read is skipped rather than overwritten
+	changed, _ := s.GetLoan(ctx, loans[2].ID)
+	changed.Balance = decimal.NewFromInt(500)
+	if err := s.UpdateLoan(ctx, changed); err != nil {
+		t.Fatalf("Failed to update loan: %v", err)
+	}
+	loans[2].AccruedInterest = decimal.NewFromInt(777)
+	loans[3].AccruedInterest = decimal.NewFromInt(888)
+	stale, err := s.UpdateLoans(ctx, loans[2:4])
+	if err != nil || len(stale) != 1 || stale[0] != loans[2].ID {
+		t.Fatalf("Expected loan %s skipped, got %v (%v)", loans[2].ID, stale, err)
+	}
+	if fetched, _ := s.GetLoan(ctx, loans[2].ID); !fetched.Balance.Equal(decimal.NewFromInt(500)) || fetched.AccruedInterest.Equal(decimal.NewFromInt(777)) || fetched.Version != 3 || loans[2].Version != 2 {
+		t.Errorf("Expected the changed loan kept at version 3, got %+v", fetched)
+	}
+	if fetched, _ := s.GetLoan(ctx, loans[3].ID); !fetched.AccruedInterest.Equal(decimal.NewFromInt(888)) || fetched.Version != 3 || loans[3].Version != 3 {
+		t.Errorf("Expected the unchanged loan updated to version 3, got %+v", fetched)
+	}
+}
+
+func TestSQLiteStore_ForEachActiveLoan(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_for_each.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	for i := 0; i < activeLoanPageSize+5; i++ {
+		status := models.LoanStatusActive
+		if i%10 == 0 {
+			status = models.LoanStatusClosed
+		}
+		loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_stream", Principal: decimal.NewFromInt(1000), Balance: decimal.NewFromInt(1000), Status: status, CreatedAt: now, UpdatedAt: now, Version: 1}
+		if err := s.CreateLoan(ctx, loan); err != nil {
+			t.Fatalf("Failed to create loan: %v", err)
+		}
+	}
+	active, _ := s.GetAllActiveLoans(ctx)
+
+	// Writing from fn works while the loans are streamed, and every loan is seen once in order
+	var seen []uuid.UUID
+	err = s.ForEachActiveLoan(ctx, func(loan *models.Loan) error {
+		if len(seen) > 0 && loan.ID.String() <= seen[len(seen)-1].String() {
+			t.Errorf("Expected loans in ID order, got %s after %s", loan.ID, seen[len(seen)-1])
+		}
+		seen = append(seen, loan.ID)
+		loan.Status = models.LoanStatusDelinquent
+		return s.UpdateLoan(ctx, loan)
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream loans: %v", err)
+	}
+	if len(seen) != len(active) {
+		t.Errorf("Expected %d loans, got %d", len(active), len(seen))
+	}
+	if delinquent, _ := s.GetLoansByStatus(ctx, models.LoanStatusDelinquent); len(delinquent) != len(active) {
+		t.Errorf("Expected every streamed loan updated, got %d", len(delinquent))
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.ForEachActiveLoan(ctx, func(loan *models.Loan) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected iteration to stop at fn's error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestSQLiteStore_ListLoansAfter(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_store_list_after.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	sqlite, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer sqlite.Close()
+
+	// Pairs of loans share a creation time, so pages have to break ties on ID
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	memory := NewMemoryStore()
+	for _, s := range []Storage{sqlite, memory} {
+		for i := 0; i < 7; i++ {
+			status := models.LoanStatusActive
+			if i == 3 {
+				status = models.LoanStatusVoided
+			}
+			loan := &models.Loan{ID: uuid.New(), CustomerKey: "cust_keyset", Principal: decimal.NewFromInt(1000), Balance: decimal.NewFromInt(1000), Status: status, CreatedAt: created.Add(time.Duration(i/2) * time.Minute), UpdatedAt: created, Version: 1}
+			if err := s.CreateLoan(ctx, loan); err != nil {
+				t.Fatalf("Failed to create loan: %v", err)
+			}
+		}
+	}
+
+	for name, s := range map[string]Storage{"sqlite": sqlite, "memory": memory} {
+		all, _, _ := s.ListLoans(ctx, models.LoanQuery{CustomerKey: "cust_keyset", Limit: 10})
+
+		var paged []*models.Loan
+		var cursor *models.LoanCursor
+		for pages := 0; ; pages++ {
+			if pages > len(all) {
+				t.Fatalf("%s: expected paging to end", name)
+			}
+			page, next, err := s.ListLoansAfter(ctx, models.LoanQuery{CustomerKey: "cust_keyset"}, cursor, 2)
+			if err != nil {
+				t.Fatalf("%s: failed to list loans: %v", name, err)
+			}
+			paged = append(paged, page...)
+			if next == nil {
+				break
+			}
+			cursor = next
+		}
+		if len(paged) != 6 || len(paged) != len(all) {
+			t.Fatalf("%s: expected the 6 loans that are not voided, got %d", name, len(paged))
+		}
+		for i := range all {
+			if paged[i].ID != all[i].ID {
+				t.Errorf("%s: expected the pages in ListLoans order, got %s at %d", name, paged[i].ID, i)
+			}
+		}
+
+		if _, _, err := s.ListLoansAfter(ctx, models.LoanQuery{}, nil, 0); err == nil {
+			t.Errorf("%s: expected a limit of 0 to be rejected", name)
+		}
+	}
 }
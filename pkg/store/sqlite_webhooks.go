@@ -0,0 +1,184 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+)
+
+// webhookDeliveryColumns lists the webhook delivery columns in the order expected by
+// scanWebhookDelivery.
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_error, created_at, delivered_at`
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	var idStr, events string
+	if err := row.Scan(&idStr, &subscription.URL, &events, &subscription.Secret, &subscription.CreatedAt); err != nil {
+		return nil, err
+	}
+	subscription.ID = uuid.MustParse(idStr)
+	for _, event := range strings.Split(events, ",") {
+		subscription.Events = append(subscription.Events, models.WebhookEventType(event))
+	}
+	return &subscription, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	var idStr, subscriptionIDStr, eventIDStr string
+	if err := row.Scan(&idStr, &subscriptionIDStr, &eventIDStr, &delivery.EventType, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt); err != nil {
+		return nil, err
+	}
+	delivery.ID = uuid.MustParse(idStr)
+	delivery.SubscriptionID = uuid.MustParse(subscriptionIDStr)
+	delivery.EventID = uuid.MustParse(eventIDStr)
+	return &delivery, nil
+}
+
+// CreateWebhookSubscription inserts a new webhook subscription into the database.
+func (s *sqlStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	events := make([]string, len(subscription.Events))
+	for i, event := range subscription.Events {
+		events[i] = string(event)
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_subscriptions (id, url, events, secret, created_at) VALUES (?, ?, ?, ?, ?)`,
+		subscription.ID.String(), subscription.URL, strings.Join(events, ","), subscription.Secret, subscription.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookSubscription retrieves a webhook subscription by its ID.
+func (s *sqlStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	subscription, err := scanWebhookSubscription(s.db.QueryRowContext(ctx, `SELECT id, url, events, secret, created_at FROM webhook_subscriptions WHERE id = ?`, id.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, models.ErrWebhookSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+// GetWebhookSubscriptions retrieves every webhook subscription, oldest first.
+func (s *sqlStore) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, url, events, secret, created_at FROM webhook_subscriptions ORDER BY julianday(created_at) ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*models.WebhookSubscription
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, rows.Err()
+}
+
+// DeleteWebhookSubscription removes a webhook subscription and its deliveries.
+func (s *sqlStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE subscription_id = ?`, id.String()); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?`, id.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrWebhookSubscriptionNotFound
+	}
+	return tx.Commit()
+}
+
+// CreateWebhookDelivery queues an event for a subscriber.
+func (s *sqlStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (`+webhookDeliveryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		delivery.ID.String(), delivery.SubscriptionID.String(), delivery.EventID.String(), delivery.EventType, delivery.Payload,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.CreatedAt, delivery.DeliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt.
+func (s *sqlStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, delivered_at = ? WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt, delivery.ID.String(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return models.ErrWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+// GetDueWebhookDeliveries retrieves up to limit pending deliveries due at the given time,
+// oldest first.
+func (s *sqlStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	return s.queryWebhookDeliveries(ctx,
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND julianday(next_attempt_at) <= julianday(?)
+		ORDER BY julianday(created_at) ASC LIMIT ?`,
+		models.WebhookDeliveryPending, at, limit,
+	)
+}
+
+// GetWebhookDeliveriesForSubscription retrieves up to limit of a subscription's deliveries,
+// newest first.
+func (s *sqlStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	return s.queryWebhookDeliveries(ctx,
+		`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE subscription_id = ?
+		ORDER BY julianday(created_at) DESC LIMIT ?`,
+		subscriptionID.String(), limit,
+	)
+}
+
+func (s *sqlStore) queryWebhookDeliveries(ctx context.Context, query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,721 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/models"
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+)
+
+var _ Storage = (*TracedStore)(nil)
+
+// TracedStore decorates a Storage with a client span around every call, named after the
+// method (e.g. "store.GetLoan") and failed when the call returns an error. Each span is a
+// child of the span in the call's context, such as the request or batch job making the call,
+// or starts a trace of its own when there is none.
+type TracedStore struct {
+	inner  Storage
+	tracer *tracing.Tracer
+}
+
+// NewTracedStore wraps s so its calls are recorded by tracer.
+func NewTracedStore(s Storage, tracer *tracing.Tracer) *TracedStore {
+	return &TracedStore{inner: s, tracer: tracer}
+}
+
+func (t *TracedStore) start(ctx context.Context, method string) (context.Context, *tracing.Span) {
+	ctx, span := t.tracer.Start(ctx, "store."+method, tracing.SpanKindClient)
+	span.SetAttribute("db.operation", method)
+	return ctx, span
+}
+
+func (t *TracedStore) end(span *tracing.Span, err error) {
+	span.SetError(err)
+	span.End()
+}
+
+// Close closes the underlying store.
+func (t *TracedStore) Close() error {
+	return t.inner.Close()
+}
+
+// InTransaction spans the whole transaction, and fn's calls through the store it is given
+// are traced as its children.
+func (t *TracedStore) InTransaction(ctx context.Context, fn func(ctx context.Context, tx Storage) error) error {
+	ctx, span := t.start(ctx, "InTransaction")
+	err := t.inner.InTransaction(ctx, func(ctx context.Context, tx Storage) error {
+		return fn(ctx, &TracedStore{inner: tx, tracer: t.tracer})
+	})
+	t.end(span, err)
+	return err
+}
+
+// ForEachActiveLoan spans the whole iteration, including the time fn takes.
+func (t *TracedStore) ForEachActiveLoan(ctx context.Context, fn func(loan *models.Loan) error) error {
+	ctx, span := t.start(ctx, "ForEachActiveLoan")
+	err := t.inner.ForEachActiveLoan(ctx, fn)
+	t.end(span, err)
+	return err
+}
+
+// Storage methods below are wrapped in spans.
+
+func (t *TracedStore) CreateLoan(ctx context.Context, loan *models.Loan) error {
+	ctx, span := t.start(ctx, "CreateLoan")
+	err := t.inner.CreateLoan(ctx, loan)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetLoan")
+	result, err := t.inner.GetLoan(ctx, id)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdateLoan(ctx context.Context, loan *models.Loan) error {
+	ctx, span := t.start(ctx, "UpdateLoan")
+	err := t.inner.UpdateLoan(ctx, loan)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) UpdateLoanIfVersion(ctx context.Context, loan *models.Loan, version int) error {
+	ctx, span := t.start(ctx, "UpdateLoanIfVersion")
+	err := t.inner.UpdateLoanIfVersion(ctx, loan, version)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) UpdateLoans(ctx context.Context, loans []*models.Loan) ([]uuid.UUID, error) {
+	ctx, span := t.start(ctx, "UpdateLoans")
+	result, err := t.inner.UpdateLoans(ctx, loans)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetAllLoans(ctx context.Context) ([]*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetAllLoans")
+	result, err := t.inner.GetAllLoans(ctx)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) ListLoans(ctx context.Context, query models.LoanQuery) ([]*models.Loan, int, error) {
+	ctx, span := t.start(ctx, "ListLoans")
+	result, count, err := t.inner.ListLoans(ctx, query)
+	t.end(span, err)
+	return result, count, err
+}
+
+func (t *TracedStore) ListLoansAfter(ctx context.Context, query models.LoanQuery, cursor *models.LoanCursor, limit int) ([]*models.Loan, *models.LoanCursor, error) {
+	ctx, span := t.start(ctx, "ListLoansAfter")
+	result, next, err := t.inner.ListLoansAfter(ctx, query, cursor, limit)
+	t.end(span, err)
+	return result, next, err
+}
+
+func (t *TracedStore) SearchLoans(ctx context.Context, search models.LoanSearch) ([]*models.Loan, int, error) {
+	ctx, span := t.start(ctx, "SearchLoans")
+	result, count, err := t.inner.SearchLoans(ctx, search)
+	t.end(span, err)
+	return result, count, err
+}
+
+func (t *TracedStore) GetAllActiveLoans(ctx context.Context) ([]*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetAllActiveLoans")
+	result, err := t.inner.GetAllActiveLoans(ctx)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetLoansByStatus(ctx context.Context, status models.LoanStatus) ([]*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetLoansByStatus")
+	result, err := t.inner.GetLoansByStatus(ctx, status)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetLoansByCustomerKey(ctx context.Context, customerKey string) ([]*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetLoansByCustomerKey")
+	result, err := t.inner.GetLoansByCustomerKey(ctx, customerKey)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetCustomerSummary(ctx context.Context, customerKey string) (*models.CustomerSummary, error) {
+	ctx, span := t.start(ctx, "GetCustomerSummary")
+	result, err := t.inner.GetCustomerSummary(ctx, customerKey)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetDelinquentLoans(ctx context.Context, minDaysPastDue int) ([]*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetDelinquentLoans")
+	result, err := t.inner.GetDelinquentLoans(ctx, minDaysPastDue)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	ctx, span := t.start(ctx, "CreateTransaction")
+	err := t.inner.CreateTransaction(ctx, transaction)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	ctx, span := t.start(ctx, "GetTransactionsForLoan")
+	result, err := t.inner.GetTransactionsForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) QueryTransactions(ctx context.Context, loanID uuid.UUID, query models.TransactionQuery) ([]*models.Transaction, int, error) {
+	ctx, span := t.start(ctx, "QueryTransactions")
+	result, count, err := t.inner.QueryTransactions(ctx, loanID, query)
+	t.end(span, err)
+	return result, count, err
+}
+
+func (t *TracedStore) CreateLoanEvent(ctx context.Context, event *models.LoanEvent) error {
+	ctx, span := t.start(ctx, "CreateLoanEvent")
+	err := t.inner.CreateLoanEvent(ctx, event)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	ctx, span := t.start(ctx, "GetLoanEventsForLoan")
+	result, err := t.inner.GetLoanEventsForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateRateChange(ctx context.Context, change *models.RateChange) error {
+	ctx, span := t.start(ctx, "CreateRateChange")
+	err := t.inner.CreateRateChange(ctx, change)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	ctx, span := t.start(ctx, "GetRateHistory")
+	result, err := t.inner.GetRateHistory(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetRateInEffect(ctx context.Context, loanID uuid.UUID, date time.Time) (*models.RateChange, error) {
+	ctx, span := t.start(ctx, "GetRateInEffect")
+	result, err := t.inner.GetRateInEffect(ctx, loanID, date)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateIndexRate(ctx context.Context, rate *models.IndexRate) error {
+	ctx, span := t.start(ctx, "CreateIndexRate")
+	err := t.inner.CreateIndexRate(ctx, rate)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetLatestIndexRate(ctx context.Context, indexCode string) (*models.IndexRate, error) {
+	ctx, span := t.start(ctx, "GetLatestIndexRate")
+	result, err := t.inner.GetLatestIndexRate(ctx, indexCode)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetIndexRates(ctx context.Context, indexCode string) ([]*models.IndexRate, error) {
+	ctx, span := t.start(ctx, "GetIndexRates")
+	result, err := t.inner.GetIndexRates(ctx, indexCode)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) SavePortfolioSnapshot(ctx context.Context, snapshot *models.PortfolioSnapshot) error {
+	ctx, span := t.start(ctx, "SavePortfolioSnapshot")
+	err := t.inner.SavePortfolioSnapshot(ctx, snapshot)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetPortfolioSnapshots(ctx context.Context, from time.Time, to time.Time) ([]*models.PortfolioSnapshot, error) {
+	ctx, span := t.start(ctx, "GetPortfolioSnapshots")
+	result, err := t.inner.GetPortfolioSnapshots(ctx, from, to)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	ctx, span := t.start(ctx, "CreateInterestIntent")
+	err := t.inner.CreateInterestIntent(ctx, intent)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) UpdateInterestIntent(ctx context.Context, intent *models.InterestIntent) error {
+	ctx, span := t.start(ctx, "UpdateInterestIntent")
+	err := t.inner.UpdateInterestIntent(ctx, intent)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetInterestIntent(ctx context.Context, loanID uuid.UUID, cycle string) (*models.InterestIntent, error) {
+	ctx, span := t.start(ctx, "GetInterestIntent")
+	result, err := t.inner.GetInterestIntent(ctx, loanID, cycle)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetInterestIntentsByStatus(ctx context.Context, status models.IntentStatus) ([]*models.InterestIntent, error) {
+	ctx, span := t.start(ctx, "GetInterestIntentsByStatus")
+	result, err := t.inner.GetInterestIntentsByStatus(ctx, status)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetInterestIntentsForCycle(ctx context.Context, cycle string) ([]*models.InterestIntent, error) {
+	ctx, span := t.start(ctx, "GetInterestIntentsForCycle")
+	result, err := t.inner.GetInterestIntentsForCycle(ctx, cycle)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	ctx, span := t.start(ctx, "CreateIdempotencyRecord")
+	err := t.inner.CreateIdempotencyRecord(ctx, record)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetIdempotencyRecord(ctx context.Context, key string) (*models.IdempotencyRecord, error) {
+	ctx, span := t.start(ctx, "GetIdempotencyRecord")
+	result, err := t.inner.GetIdempotencyRecord(ctx, key)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdateIdempotencyRecord(ctx context.Context, record *models.IdempotencyRecord) error {
+	ctx, span := t.start(ctx, "UpdateIdempotencyRecord")
+	err := t.inner.UpdateIdempotencyRecord(ctx, record)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	ctx, span := t.start(ctx, "DeleteIdempotencyRecord")
+	err := t.inner.DeleteIdempotencyRecord(ctx, key)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) CreateStatement(ctx context.Context, statement *models.Statement) error {
+	ctx, span := t.start(ctx, "CreateStatement")
+	err := t.inner.CreateStatement(ctx, statement)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetStatement(ctx context.Context, loanID uuid.UUID, cycle string) (*models.Statement, error) {
+	ctx, span := t.start(ctx, "GetStatement")
+	result, err := t.inner.GetStatement(ctx, loanID, cycle)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetStatementsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Statement, error) {
+	ctx, span := t.start(ctx, "GetStatementsForLoan")
+	result, err := t.inner.GetStatementsForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) SaveAutopayEnrollment(ctx context.Context, enrollment *models.AutopayEnrollment) error {
+	ctx, span := t.start(ctx, "SaveAutopayEnrollment")
+	err := t.inner.SaveAutopayEnrollment(ctx, enrollment)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetAutopayEnrollment(ctx context.Context, loanID uuid.UUID) (*models.AutopayEnrollment, error) {
+	ctx, span := t.start(ctx, "GetAutopayEnrollment")
+	result, err := t.inner.GetAutopayEnrollment(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) DeleteAutopayEnrollment(ctx context.Context, loanID uuid.UUID) error {
+	ctx, span := t.start(ctx, "DeleteAutopayEnrollment")
+	err := t.inner.DeleteAutopayEnrollment(ctx, loanID)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetAutopayEnrollmentsForDay(ctx context.Context, day int) ([]*models.AutopayEnrollment, error) {
+	ctx, span := t.start(ctx, "GetAutopayEnrollmentsForDay")
+	result, err := t.inner.GetAutopayEnrollmentsForDay(ctx, day)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	ctx, span := t.start(ctx, "CreateCollateral")
+	err := t.inner.CreateCollateral(ctx, collateral)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetCollateral(ctx context.Context, id uuid.UUID) (*models.Collateral, error) {
+	ctx, span := t.start(ctx, "GetCollateral")
+	result, err := t.inner.GetCollateral(ctx, id)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdateCollateral(ctx context.Context, collateral *models.Collateral) error {
+	ctx, span := t.start(ctx, "UpdateCollateral")
+	err := t.inner.UpdateCollateral(ctx, collateral)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) DeleteCollateral(ctx context.Context, id uuid.UUID) error {
+	ctx, span := t.start(ctx, "DeleteCollateral")
+	err := t.inner.DeleteCollateral(ctx, id)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetCollateralForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Collateral, error) {
+	ctx, span := t.start(ctx, "GetCollateralForLoan")
+	result, err := t.inner.GetCollateralForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateForbearance(ctx context.Context, forbearance *models.Forbearance) error {
+	ctx, span := t.start(ctx, "CreateForbearance")
+	err := t.inner.CreateForbearance(ctx, forbearance)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetForbearancesForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Forbearance, error) {
+	ctx, span := t.start(ctx, "GetForbearancesForLoan")
+	result, err := t.inner.GetForbearancesForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) SaveAccrual(ctx context.Context, accrual *models.Accrual) error {
+	ctx, span := t.start(ctx, "SaveAccrual")
+	err := t.inner.SaveAccrual(ctx, accrual)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetAccrualsForLoan(ctx context.Context, loanID uuid.UUID, from time.Time, to time.Time) ([]*models.Accrual, error) {
+	ctx, span := t.start(ctx, "GetAccrualsForLoan")
+	result, err := t.inner.GetAccrualsForLoan(ctx, loanID, from, to)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) AppendLedgerEvent(ctx context.Context, event *models.LedgerEvent) error {
+	ctx, span := t.start(ctx, "AppendLedgerEvent")
+	err := t.inner.AppendLedgerEvent(ctx, event)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetLedgerEvents(ctx context.Context, loanID uuid.UUID) ([]*models.LedgerEvent, error) {
+	ctx, span := t.start(ctx, "GetLedgerEvents")
+	result, err := t.inner.GetLedgerEvents(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) SaveBureauRecord(ctx context.Context, record *models.BureauRecord) error {
+	ctx, span := t.start(ctx, "SaveBureauRecord")
+	err := t.inner.SaveBureauRecord(ctx, record)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetBureauRecordsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.BureauRecord, error) {
+	ctx, span := t.start(ctx, "GetBureauRecordsForLoan")
+	result, err := t.inner.GetBureauRecordsForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) ArchiveClosedLoans(ctx context.Context, closedBefore time.Time) (int, error) {
+	ctx, span := t.start(ctx, "ArchiveClosedLoans")
+	result, err := t.inner.ArchiveClosedLoans(ctx, closedBefore)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) MarkArchivedLoansExported(ctx context.Context, ids []uuid.UUID, exportedAt time.Time) error {
+	ctx, span := t.start(ctx, "MarkArchivedLoansExported")
+	err := t.inner.MarkArchivedLoansExported(ctx, ids, exportedAt)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) DeleteArchivedLoans(ctx context.Context, ids []uuid.UUID) error {
+	ctx, span := t.start(ctx, "DeleteArchivedLoans")
+	err := t.inner.DeleteArchivedLoans(ctx, ids)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetArchivedLoan(ctx context.Context, id uuid.UUID) (*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetArchivedLoan")
+	result, err := t.inner.GetArchivedLoan(ctx, id)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetArchivedTransactionsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.Transaction, error) {
+	ctx, span := t.start(ctx, "GetArchivedTransactionsForLoan")
+	result, err := t.inner.GetArchivedTransactionsForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetArchivedLoanEventsForLoan(ctx context.Context, loanID uuid.UUID) ([]*models.LoanEvent, error) {
+	ctx, span := t.start(ctx, "GetArchivedLoanEventsForLoan")
+	result, err := t.inner.GetArchivedLoanEventsForLoan(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetArchivedRateHistory(ctx context.Context, loanID uuid.UUID) ([]*models.RateChange, error) {
+	ctx, span := t.start(ctx, "GetArchivedRateHistory")
+	result, err := t.inner.GetArchivedRateHistory(ctx, loanID)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetArchivedLoansToExport(ctx context.Context, closedBefore time.Time, limit int) ([]*models.Loan, error) {
+	ctx, span := t.start(ctx, "GetArchivedLoansToExport")
+	result, err := t.inner.GetArchivedLoansToExport(ctx, closedBefore, limit)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	ctx, span := t.start(ctx, "CreatePaymentMethod")
+	err := t.inner.CreatePaymentMethod(ctx, method)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetPaymentMethod(ctx context.Context, id uuid.UUID) (*models.PaymentMethod, error) {
+	ctx, span := t.start(ctx, "GetPaymentMethod")
+	result, err := t.inner.GetPaymentMethod(ctx, id)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdatePaymentMethod(ctx context.Context, method *models.PaymentMethod) error {
+	ctx, span := t.start(ctx, "UpdatePaymentMethod")
+	err := t.inner.UpdatePaymentMethod(ctx, method)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetPaymentMethodsForCustomer(ctx context.Context, customerKey string) ([]*models.PaymentMethod, error) {
+	ctx, span := t.start(ctx, "GetPaymentMethodsForCustomer")
+	result, err := t.inner.GetPaymentMethodsForCustomer(ctx, customerKey)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	ctx, span := t.start(ctx, "CreatePaymentLink")
+	err := t.inner.CreatePaymentLink(ctx, link)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetPaymentLink(ctx context.Context, id uuid.UUID) (*models.PaymentLink, error) {
+	ctx, span := t.start(ctx, "GetPaymentLink")
+	result, err := t.inner.GetPaymentLink(ctx, id)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdatePaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	ctx, span := t.start(ctx, "UpdatePaymentLink")
+	err := t.inner.UpdatePaymentLink(ctx, link)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) RedeemPaymentLink(ctx context.Context, link *models.PaymentLink) error {
+	ctx, span := t.start(ctx, "RedeemPaymentLink")
+	err := t.inner.RedeemPaymentLink(ctx, link)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	ctx, span := t.start(ctx, "CreateWebhookSubscription")
+	err := t.inner.CreateWebhookSubscription(ctx, subscription)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetWebhookSubscription(ctx context.Context, id uuid.UUID) (*models.WebhookSubscription, error) {
+	ctx, span := t.start(ctx, "GetWebhookSubscription")
+	result, err := t.inner.GetWebhookSubscription(ctx, id)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetWebhookSubscriptions(ctx context.Context) ([]*models.WebhookSubscription, error) {
+	ctx, span := t.start(ctx, "GetWebhookSubscriptions")
+	result, err := t.inner.GetWebhookSubscriptions(ctx)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) DeleteWebhookSubscription(ctx context.Context, id uuid.UUID) error {
+	ctx, span := t.start(ctx, "DeleteWebhookSubscription")
+	err := t.inner.DeleteWebhookSubscription(ctx, id)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) CreateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, span := t.start(ctx, "CreateWebhookDelivery")
+	err := t.inner.CreateWebhookDelivery(ctx, delivery)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	ctx, span := t.start(ctx, "UpdateWebhookDelivery")
+	err := t.inner.UpdateWebhookDelivery(ctx, delivery)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetDueWebhookDeliveries(ctx context.Context, at time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	ctx, span := t.start(ctx, "GetDueWebhookDeliveries")
+	result, err := t.inner.GetDueWebhookDeliveries(ctx, at, limit)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) GetWebhookDeliveriesForSubscription(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*models.WebhookDelivery, error) {
+	ctx, span := t.start(ctx, "GetWebhookDeliveriesForSubscription")
+	result, err := t.inner.GetWebhookDeliveriesForSubscription(ctx, subscriptionID, limit)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateOutboxEvent(ctx context.Context, event *models.OutboxEvent) error {
+	ctx, span := t.start(ctx, "CreateOutboxEvent")
+	err := t.inner.CreateOutboxEvent(ctx, event)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetPendingOutboxEvents(ctx context.Context, limit int) ([]*models.OutboxEvent, error) {
+	ctx, span := t.start(ctx, "GetPendingOutboxEvents")
+	result, err := t.inner.GetPendingOutboxEvents(ctx, limit)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) MarkOutboxEventPublished(ctx context.Context, id uuid.UUID, at time.Time) error {
+	ctx, span := t.start(ctx, "MarkOutboxEventPublished")
+	err := t.inner.MarkOutboxEventPublished(ctx, id, at)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) CreateProduct(ctx context.Context, product *models.Product) error {
+	ctx, span := t.start(ctx, "CreateProduct")
+	err := t.inner.CreateProduct(ctx, product)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetProduct(ctx context.Context, code string) (*models.Product, error) {
+	ctx, span := t.start(ctx, "GetProduct")
+	result, err := t.inner.GetProduct(ctx, code)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdateProduct(ctx context.Context, product *models.Product) error {
+	ctx, span := t.start(ctx, "UpdateProduct")
+	err := t.inner.UpdateProduct(ctx, product)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetAllProducts(ctx context.Context) ([]*models.Product, error) {
+	ctx, span := t.start(ctx, "GetAllProducts")
+	result, err := t.inner.GetAllProducts(ctx)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateCustomer(ctx context.Context, customer *models.Customer) error {
+	ctx, span := t.start(ctx, "CreateCustomer")
+	err := t.inner.CreateCustomer(ctx, customer)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetCustomer(ctx context.Context, customerKey string) (*models.Customer, error) {
+	ctx, span := t.start(ctx, "GetCustomer")
+	result, err := t.inner.GetCustomer(ctx, customerKey)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) UpdateCustomer(ctx context.Context, customer *models.Customer) error {
+	ctx, span := t.start(ctx, "UpdateCustomer")
+	err := t.inner.UpdateCustomer(ctx, customer)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) DeleteCustomer(ctx context.Context, customerKey string) error {
+	ctx, span := t.start(ctx, "DeleteCustomer")
+	err := t.inner.DeleteCustomer(ctx, customerKey)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetAllCustomers(ctx context.Context) ([]*models.Customer, error) {
+	ctx, span := t.start(ctx, "GetAllCustomers")
+	result, err := t.inner.GetAllCustomers(ctx)
+	t.end(span, err)
+	return result, err
+}
+
+func (t *TracedStore) CreateJobRun(ctx context.Context, run *models.JobRun) error {
+	ctx, span := t.start(ctx, "CreateJobRun")
+	err := t.inner.CreateJobRun(ctx, run)
+	t.end(span, err)
+	return err
+}
+
+func (t *TracedStore) GetJobRuns(ctx context.Context, job models.JobName, limit int) ([]*models.JobRun, error) {
+	ctx, span := t.start(ctx, "GetJobRuns")
+	result, err := t.inner.GetJobRuns(ctx, job, limit)
+	t.end(span, err)
+	return result, err
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcclellann/fredLoan/pkg/tracing"
+)
+
+func TestTracedStore(t *testing.T) {
+	ctx := context.Background()
+
+	dbFile := "test_traced_dec.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	s, err := NewSQLiteStore(dbFile)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	exporter := &tracing.MemoryExporter{}
+	tracer := tracing.NewTracer(exporter, time.Hour)
+	defer tracer.Shutdown(context.Background())
+	traced := NewTracedStore(s, tracer)
+	defer traced.Close()
+
+	if err := traced.CreateLoan(ctx, newFaultTestLoan()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := traced.GetLoan(ctx, uuid.New()); err == nil {
+		t.Fatal("Expected an error for an unknown loan")
+	}
+	requestCtx, request := tracer.Start(ctx, "GET /loans", tracing.SpanKindServer)
+	traced.GetAllLoans(requestCtx)
+	request.End()
+	tracer.Flush()
+
+	spans := exporter.Spans()
+	if len(spans) != 4 {
+		t.Fatalf("Expected 4 spans, got %d", len(spans))
+	}
+	if spans[0].TraceID == spans[1].TraceID {
+		t.Error("Expected calls without a span in their context to start their own traces")
+	}
+	if spans[2].Name != "store.GetAllLoans" || spans[2].TraceID != spans[3].TraceID || spans[2].ParentSpanID != spans[3].SpanID {
+		t.Errorf("Expected the store span under the request span, got %+v", spans[2])
+	}
+	if spans[0].Name != "store.CreateLoan" || spans[0].Status != tracing.StatusUnset {
+		t.Errorf("Unexpected span %+v", spans[0])
+	}
+	if spans[1].Name != "store.GetLoan" || spans[1].Status != tracing.StatusError || spans[1].Kind != tracing.SpanKindClient {
+		t.Errorf("Expected a failed client span for GetLoan, got %+v", spans[1])
+	}
+}
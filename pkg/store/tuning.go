@@ -0,0 +1,78 @@
+package store
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SQLiteTuning holds the SQLite settings each connection to the database is opened with.
+// Zero values leave go-sqlite3's defaults: a 5 second busy timeout, NORMAL synchronous mode
+// and SQLite's cache of about 2 MB.
+type SQLiteTuning struct {
+	// BusyTimeout is how long a connection waits for another's lock before failing with
+	// "database is locked".
+	BusyTimeout time.Duration
+	// Synchronous is how often SQLite waits for writes to reach the disk: off, normal, full
+	// or extra. In WAL mode normal cannot corrupt the database, but a power loss may undo the
+	// last transactions; full loses none.
+	Synchronous string
+	// CacheSize is the page cache of each connection, as PRAGMA cache_size takes it: a number
+	// of pages, or when negative a number of KiB.
+	CacheSize int
+}
+
+// sqliteSynchronousModes are the synchronous modes SQLite knows.
+var sqliteSynchronousModes = []string{"off", "normal", "full", "extra"}
+
+// WithSQLiteTuning returns a SQLite data source whose connections are opened with the
+// tuning, as go-sqlite3 parameters that override any the data source sets. Other drivers'
+// data sources are returned as they are.
+func WithSQLiteTuning(driver string, dataSourceName string, tuning SQLiteTuning) (string, error) {
+	if driver != DriverSQLite && driver != "" {
+		return dataSourceName, nil
+	}
+	if tuning.BusyTimeout < 0 {
+		return "", fmt.Errorf("SQLite busy timeout must not be negative")
+	}
+	dsn, params := splitSQLiteParams(dataSourceName)
+	if tuning.BusyTimeout > 0 {
+		params.Set("_busy_timeout", fmt.Sprint(tuning.BusyTimeout.Milliseconds()))
+	}
+	if tuning.Synchronous != "" {
+		mode := strings.ToLower(tuning.Synchronous)
+		if !slices.Contains(sqliteSynchronousModes, mode) {
+			return "", fmt.Errorf("SQLite synchronous mode %q must be off, normal, full or extra", tuning.Synchronous)
+		}
+		params.Set("_synchronous", strings.ToUpper(mode))
+	}
+	if tuning.CacheSize != 0 {
+		params.Set("_cache_size", fmt.Sprint(tuning.CacheSize))
+	}
+	if len(params) == 0 {
+		return dataSourceName, nil
+	}
+	return dsn + "?" + params.Encode(), nil
+}
+
+// ConnectionPool is a store on a database/sql pool of connections. The memory and Bolt
+// stores have no connections to pool.
+type ConnectionPool interface {
+	// SetConnectionLimits caps the connections open at once, 0 for no cap, and those kept
+	// idle for reuse, 0 for none.
+	SetConnectionLimits(maxOpen int, maxIdle int)
+}
+
+var (
+	_ ConnectionPool = (*SQLiteStore)(nil)
+	_ ConnectionPool = (*PostgresStore)(nil)
+	_ ConnectionPool = (*MySQLStore)(nil)
+)
+
+// SetConnectionLimits caps the pool's open and idle connections. On SQLite a cap of one
+// connection serializes every call, trading concurrent reads for never waiting on a lock.
+func (s *sqlStore) SetConnectionLimits(maxOpen int, maxIdle int) {
+	s.db.SetMaxOpenConns(maxOpen)
+	s.db.SetMaxIdleConns(maxIdle)
+}
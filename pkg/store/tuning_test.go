@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWithSQLiteTuning(t *testing.T) {
+	tuning := SQLiteTuning{BusyTimeout: 30 * time.Second, Synchronous: "Full", CacheSize: -20000}
+	dsn, err := WithSQLiteTuning(DriverSQLite, "file:fredloan.db?_busy_timeout=1000&mode=rwc", tuning)
+	if err != nil {
+		t.Fatalf("Failed to tune data source: %v", err)
+	}
+	if expected := "file:fredloan.db?_busy_timeout=30000&_cache_size=-20000&_synchronous=FULL&mode=rwc"; dsn != expected {
+		t.Errorf("Expected %q, got %q", expected, dsn)
+	}
+	if unchanged, _ := WithSQLiteTuning(DriverSQLite, "fredloan.db", SQLiteTuning{}); unchanged != "fredloan.db" {
+		t.Errorf("Expected no tuning to leave the data source alone, got %q", unchanged)
+	}
+	if unchanged, _ := WithSQLiteTuning(DriverPostgres, "postgres://db/fredloan", tuning); unchanged != "postgres://db/fredloan" {
+		t.Errorf("Expected a PostgreSQL data source unchanged, got %q", unchanged)
+	}
+	if _, err := WithSQLiteTuning(DriverSQLite, "fredloan.db", SQLiteTuning{Synchronous: "sometimes"}); err == nil {
+		t.Error("Expected an unknown synchronous mode to be refused")
+	}
+	if _, err := WithSQLiteTuning(DriverSQLite, "fredloan.db", SQLiteTuning{BusyTimeout: -time.Second}); err == nil {
+		t.Error("Expected a negative busy timeout to be refused")
+	}
+}
+
+func TestSQLiteStore_Tuning(t *testing.T) {
+	dbFile := "test_store_tuning.db"
+	os.Remove(dbFile)
+	defer os.Remove(dbFile)
+
+	dsn, _ := WithSQLiteTuning(DriverSQLite, dbFile, SQLiteTuning{BusyTimeout: 12 * time.Second, Synchronous: "full", CacheSize: 500})
+	s, err := NewSQLiteStore(dsn)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer s.Close()
+	s.SetConnectionLimits(3, 1)
+
+	// Hold connections so the pool opens new ones, each of which must be tuned
+	ctx := context.Background()
+	for range 3 {
+		conn, err := s.db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		defer conn.Close()
+
+		var busyTimeout, synchronous, cacheSize int
+		if err := conn.QueryRowContext(ctx, `PRAGMA busy_timeout`).Scan(&busyTimeout); err != nil {
+			t.Fatalf("Failed to read busy timeout: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, `PRAGMA synchronous`).Scan(&synchronous); err != nil {
+			t.Fatalf("Failed to read synchronous mode: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, `PRAGMA cache_size`).Scan(&cacheSize); err != nil {
+			t.Fatalf("Failed to read cache size: %v", err)
+		}
+		if busyTimeout != 12000 || synchronous != 2 || cacheSize != 500 {
+			t.Errorf("Expected busy timeout 12000, synchronous 2 (FULL) and cache size 500, got %d, %d and %d", busyTimeout, synchronous, cacheSize)
+		}
+	}
+	if stats := s.db.Stats(); stats.MaxOpenConnections != 3 {
+		t.Errorf("Expected at most 3 open connections, got %d", stats.MaxOpenConnections)
+	}
+}
@@ -0,0 +1,27 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryExporter keeps exported spans in memory, for tests.
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// Export implements Exporter.
+func (e *MemoryExporter) Export(ctx context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
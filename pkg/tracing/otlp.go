@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// instrumentationScope names the code that produced the spans.
+const instrumentationScope = "github.com/mcclellann/fredLoan"
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP over HTTP with JSON
+// encoding (https://opentelemetry.io/docs/specs/otlp/#otlphttp).
+type OTLPExporter struct {
+	// Endpoint is the full URL spans are posted to, e.g. http://localhost:4318/v1/traces.
+	Endpoint string
+	// Headers are added to every request, typically for collector authentication.
+	Headers map[string]string
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+
+	HTTPClient *http.Client
+}
+
+// NewOTLPExporter creates an exporter posting to endpoint.
+func NewOTLPExporter(endpoint string, serviceName string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		Endpoint:    endpoint,
+		Headers:     headers,
+		ServiceName: serviceName,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// OTLP/JSON message shapes. IDs are hex and 64-bit integers are strings, as the JSON mapping
+// of the protobuf messages requires.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              SpanKind       `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    StatusCode `json:"code,omitempty"`
+		Message string     `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+func (e *OTLPExporter) request(spans []SpanData) otlpRequest {
+	converted := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		converted[i] = otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: span.Status, Message: span.StatusMessage},
+		}
+		if span.ParentSpanID.IsValid() {
+			converted[i].ParentSpanID = span.ParentSpanID.String()
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]any{"service.name": e.ServiceName})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: converted}},
+	}}}
+}
+
+// otlpAttributes converts attributes to OTLP key-values, sorted by key.
+func otlpAttributes(attributes map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, len(keys))
+	for i, key := range keys {
+		var value map[string]any
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]any{"stringValue": v}
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		kvs[i] = otlpKeyValue{Key: key, Value: value}
+	}
+	return kvs
+}
+
+// ParseHeaders parses the OTEL_EXPORTER_OTLP_HEADERS format: comma-separated name=value pairs
+// with URL-encoded values.
+func ParseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q: expected name=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid header %q: %w", pair, err)
+		}
+		headers[strings.TrimSpace(name)] = decoded
+	}
+	return headers, nil
+}
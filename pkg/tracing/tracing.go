@@ -0,0 +1,356 @@
+// Package tracing records spans and exports them to an OpenTelemetry collector. It implements
+// the small part of OpenTelemetry the service needs: spans with attributes and status, parents
+// carried in a context.Context, W3C traceparent propagation, and batched export over OTLP.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID identifies a trace; SpanID identifies a span within it.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the ID is non-zero, as W3C trace context requires.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+func (id SpanID) IsValid() bool  { return id != SpanID{} }
+
+// SpanKind is the role of a span in a trace, numbered as in OTLP.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// StatusCode is the outcome of a span, numbered as in OTLP.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// SpanContext is the part of a span that is propagated to its children, locally or across
+// process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	SpanContext
+	ParentSpanID  SpanID // Zero for a root span
+	Name          string
+	Kind          SpanKind
+	Start, End    time.Time
+	Attributes    map[string]any // string, bool, int, int64 or float64 values
+	Status        StatusCode
+	StatusMessage string
+}
+
+// Span is an operation being timed. All methods are safe on a nil span, which is what Start
+// returns when tracing is disabled.
+type Span struct {
+	tracer *Tracer
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the span's IDs.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// SetAttribute records a key-value pair on the span.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed with the error's message. A nil error does nothing.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Status = StatusError
+	s.data.StatusMessage = err.Error()
+}
+
+// End finishes the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.now()
+	data := s.data
+	s.mu.Unlock()
+
+	if data.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+type contextKey int
+
+const (
+	spanKey contextKey = iota
+	remoteParentKey
+)
+
+// ContextWithSpan returns a copy of ctx carrying the span as the parent of spans started from it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey, span)
+}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent returns a copy of ctx whose next span continues a trace begun in
+// another process, such as one named in an incoming traceparent header.
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteParentKey, parent)
+}
+
+// parentFromContext returns the span context new spans in ctx should be children of.
+func parentFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext(), true
+	}
+	parent, ok := ctx.Value(remoteParentKey).(SpanContext)
+	return parent, ok && parent.IsValid()
+}
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Default batching limits. Spans queued beyond maxQueuedSpans are dropped rather than holding
+// up the service when the collector is slow or unreachable.
+const (
+	defaultExportInterval = 5 * time.Second
+	maxExportBatch        = 512
+	maxQueuedSpans        = 4096
+)
+
+// Tracer starts spans and exports them in batches from a background goroutine. A nil Tracer is
+// valid and records nothing.
+type Tracer struct {
+	exporter Exporter
+	now      func() time.Time
+
+	mu      sync.Mutex
+	queue   []SpanData
+	dropped int
+
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// NewTracer creates a tracer exporting to exporter every interval, or sooner when a full batch
+// is waiting. An interval of zero uses five seconds. Call Shutdown to export the last spans.
+func NewTracer(exporter Exporter, interval time.Duration) *Tracer {
+	if interval <= 0 {
+		interval = defaultExportInterval
+	}
+	t := &Tracer{
+		exporter: exporter,
+		now:      time.Now,
+		flush:    make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run(interval)
+	return t
+}
+
+// Start begins a span named name, as a child of the span or remote parent carried by ctx, and
+// returns a context carrying the new span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	data := SpanData{Name: name, Kind: kind, Start: t.now(), Attributes: make(map[string]any)}
+	if parent, ok := parentFromContext(ctx); ok {
+		data.TraceID = parent.TraceID
+		data.ParentSpanID = parent.SpanID
+		data.Sampled = parent.Sampled // An upstream service may have decided not to record the trace
+	} else {
+		rand.Read(data.TraceID[:])
+		data.Sampled = true // Every trace begun here is recorded
+	}
+	rand.Read(data.SpanID[:])
+
+	span := &Span{tracer: t, data: data}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, data)
+}
+
+// Flush exports every queued span and waits for the export to finish.
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case t.flush <- done:
+		<-done
+	case <-t.done:
+	}
+}
+
+// Shutdown exports the queued spans and stops the background goroutine. The context bounds
+// how long it waits.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *Tracer) run(interval time.Duration) {
+	defer close(t.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.export()
+		case done := <-t.flush:
+			t.export()
+			close(done)
+		case <-t.stop:
+			t.export()
+			return
+		}
+	}
+}
+
+// export sends the queue to the exporter in batches.
+func (t *Tracer) export() {
+	t.mu.Lock()
+	queue, dropped := t.queue, t.dropped
+	t.queue, t.dropped = nil, 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("Tracing: dropped spans; the export queue was full", "spans", dropped)
+	}
+	for len(queue) > 0 {
+		batch := queue[:min(len(queue), maxExportBatch)]
+		queue = queue[len(batch):]
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := t.exporter.Export(ctx, batch); err != nil {
+			slog.Error("Tracing: failed to export spans", "spans", len(batch), "err", err)
+		}
+		cancel()
+	}
+}
+
+// TraceparentHeader is the W3C trace context header.
+const TraceparentHeader = "traceparent"
+
+// Extract reads the parent span from a traceparent header, reporting false when the header is
+// missing or malformed.
+func Extract(header http.Header) (SpanContext, bool) {
+	// version-traceid-spanid-flags, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	parts := strings.Split(strings.TrimSpace(header.Get(TraceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, false
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, sc.IsValid()
+}
+
+// Inject writes the span as a traceparent header, so a downstream service continues the trace.
+func Inject(header http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	header.Set(TraceparentHeader, fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags))
+}
+
+// decodeHex decodes lowercase hex of exactly the destination's length.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracerParentsAndExport(t *testing.T) {
+	exporter := &MemoryExporter{}
+	tracer := NewTracer(exporter, time.Hour)
+	defer tracer.Shutdown(context.Background())
+
+	ctx, root := tracer.Start(context.Background(), "root", SpanKindServer)
+	_, child := tracer.Start(ctx, "child", SpanKindInternal)
+	child.SetAttribute("loans", 3)
+	child.SetError(errors.New("boom"))
+	child.End()
+	child.End() // Ending twice records the span once
+	root.End()
+
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Fatalf("Expected spans to wait for the batch, got %d", len(spans))
+	}
+	tracer.Flush()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	c, r := spans[0], spans[1]
+	if c.Name != "child" || r.Name != "root" {
+		t.Fatalf("Expected child then root, got %s then %s", c.Name, r.Name)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || r.ParentSpanID.IsValid() {
+		t.Errorf("Expected child to be parented to root in the same trace, got %+v and %+v", c.SpanContext, r.SpanContext)
+	}
+	if c.Status != StatusError || c.StatusMessage != "boom" || c.Attributes["loans"] != 3 {
+		t.Errorf("Unexpected child span %+v", c)
+	}
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "ignored", SpanKindInternal)
+	span.SetAttribute("key", "value")
+	span.SetError(errors.New("ignored"))
+	span.End()
+	if SpanFromContext(ctx) != nil {
+		t.Error("Expected a nil tracer not to put spans in the context")
+	}
+	tracer.Flush()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc, ok := Extract(header)
+	if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Fatalf("Unexpected span context %+v (%v)", sc, ok)
+	}
+
+	out := http.Header{}
+	Inject(out, sc)
+	if out.Get(TraceparentHeader) != header.Get(TraceparentHeader) {
+		t.Errorf("Expected the header to round-trip, got %q", out.Get(TraceparentHeader))
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01", // Zero trace ID
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",    // Missing flags
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", // Uppercase
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", // Forbidden version
+	} {
+		header.Set(TraceparentHeader, invalid)
+		if _, ok := Extract(header); ok {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+
+	// A span started under an unsampled remote parent is not exported
+	exporter := &MemoryExporter{}
+	tracer := NewTracer(exporter, time.Hour)
+	defer tracer.Shutdown(context.Background())
+	sc.Sampled = false
+	_, span := tracer.Start(ContextWithRemoteParent(context.Background(), sc), "unsampled", SpanKindServer)
+	span.End()
+	tracer.Flush()
+	if len(exporter.Spans()) != 0 {
+		t.Error("Expected an unsampled trace not to be exported")
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body map[string]any
+	var auth string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	defer collector.Close()
+
+	headers, err := ParseHeaders("Authorization=Bearer%20secret, X-Tenant = loans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if headers["X-Tenant"] != "loans" {
+		t.Errorf("Expected trimmed header, got %+v", headers)
+	}
+	if _, err := ParseHeaders("missing-equals"); err == nil {
+		t.Error("Expected an error for a malformed header")
+	}
+
+	exporter := NewOTLPExporter(collector.URL+"/v1/traces", "fredloan-test", headers)
+	span := SpanData{
+		SpanContext: SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: true},
+		Name:        "POST /loans/{id}/payments",
+		Kind:        SpanKindServer,
+		Start:       time.Unix(0, 1000),
+		End:         time.Unix(0, 2000),
+		Attributes:  map[string]any{"http.response.status_code": 201},
+	}
+	if err := exporter.Export(context.Background(), []SpanData{span}); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Bearer secret" {
+		t.Errorf("Expected the configured headers to be sent, got %q", auth)
+	}
+	resource := body["resourceSpans"].([]any)[0].(map[string]any)
+	service := resource["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	if service["value"].(map[string]any)["stringValue"] != "fredloan-test" {
+		t.Errorf("Unexpected resource %+v", service)
+	}
+	got := resource["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if got["traceId"] != "01000000000000000000000000000000" || got["spanId"] != "0200000000000000" {
+		t.Errorf("Expected hex IDs, got %v and %v", got["traceId"], got["spanId"])
+	}
+	if got["startTimeUnixNano"] != "1000" || got["kind"] != float64(SpanKindServer) {
+		t.Errorf("Unexpected span %+v", got)
+	}
+	if _, ok := got["parentSpanId"]; ok {
+		t.Error("Expected no parent on a root span")
+	}
+	attr := got["attributes"].([]any)[0].(map[string]any)
+	if attr["value"].(map[string]any)["intValue"] != "201" {
+		t.Errorf("Expected integer attributes as strings, got %+v", attr)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	if err := NewOTLPExporter(failing.URL, "fredloan-test", nil).Export(context.Background(), []SpanData{span}); err == nil {
+		t.Error("Expected an error when the collector rejects spans")
+	}
+}